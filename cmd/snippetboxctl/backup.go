@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	backupdb "snippetbox.adcon.dev/internal/backup"
+	"snippetbox.adcon.dev/internal/migrations"
+)
+
+// backup writes a consistent snapshot of the application tables to a file or stdout, in the format
+// described in the backup package.
+func backup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	output := fs.String("o", "", "File to write the backup to (default stdout)")
+	fs.Parse(args)
+
+	// Read times as the text the database sends, so that they're restored exactly.
+	cfg, err := dbConfig.MySQL()
+	if err != nil {
+		return err
+	}
+	cfg.ParseTime = false
+
+	db, err := openDSN(cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	counts, err := backupdb.Write(context.Background(), db, out, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, c := range counts {
+		fmt.Fprintf(os.Stderr, "backed up %d rows of %s\n", c.Rows, c.Table)
+	}
+
+	return nil
+}
+
+// restore loads a backup from a file or stdin into an empty database whose applied migrations
+// match those of the backup. All rows are inserted in one transaction, so a failed restore leaves
+// the database empty.
+func restore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	input := fs.String("i", "", "File to read the backup from (default stdin)")
+	fs.Parse(args)
+
+	in := os.Stdin
+	if *input != "" {
+		var err error
+		in, err = os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	zr, err := gzip.NewReader(bufio.NewReader(in))
+	if err != nil {
+		return fmt.Errorf("not a snippetbox backup: %w", err)
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+
+	var header backupdb.Header
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+	if header.Format != backupdb.Format {
+		return errors.New("not a snippetbox backup")
+	}
+	if header.Version != backupdb.Version {
+		return fmt.Errorf("unsupported backup version %d", header.Version)
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := migrations.Applied(db)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(applied, header.Migrations) {
+		return fmt.Errorf("schema mismatch: the backup was taken at migrations [%s] but the database is at [%s]; migrate both to the same version first",
+			strings.Join(header.Migrations, ", "), strings.Join(applied, ", "))
+	}
+
+	for _, table := range backupdb.Tables {
+		var one int
+		err := db.QueryRow(`SELECT 1 FROM ` + table + ` LIMIT 1`).Scan(&one)
+		if err == nil {
+			return fmt.Errorf("table %s is not empty; restore into a freshly migrated database", table)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stmt *sql.Stmt
+	var table string
+	counts := map[string]int{}
+
+	for line := 2; ; line++ {
+		var l backupdb.Line
+		err := dec.Decode(&l)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch {
+		case l.Table != "":
+			if !slices.Contains(backupdb.Tables, l.Table) {
+				return fmt.Errorf("line %d: unexpected table %q", line, l.Table)
+			}
+			if stmt != nil {
+				stmt.Close()
+			}
+			table = l.Table
+			stmt, err = tx.Prepare(insertStatement(table, l.Columns))
+		case stmt != nil:
+			values := make([]any, len(l.Row))
+			for i, v := range l.Row {
+				// A nil []byte isn't reliably sent as NULL, so it's passed as an untyped nil.
+				if v != nil {
+					values[i] = v
+				}
+			}
+			_, err = stmt.Exec(values...)
+			counts[table]++
+		default:
+			err = errors.New("row before table")
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if stmt != nil {
+		stmt.Close()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, table := range backupdb.Tables {
+		fmt.Fprintf(os.Stderr, "restored %d rows of %s\n", counts[table], table)
+	}
+
+	return nil
+}
+
+// insertStatement returns an INSERT statement for the given table and columns.
+func insertStatement(table string, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = "`" + strings.ReplaceAll(c, "`", "``") + "`"
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	return `INSERT INTO ` + table + ` (` + strings.Join(quoted, ", ") + `) VALUES (` + placeholders + `)`
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"snippetbox.adcon.dev/internal/models"
+	"snippetbox.adcon.dev/internal/validator"
+)
+
+// newUser holds the details of an account created from the command line. It uses the same rules as
+// the signup form.
+type newUser struct {
+	Name                string `form:"name" validate:"required,maxrunes=255"`
+	Username            string `form:"username" validate:"required,minrunes=3,maxrunes=30,slug"`
+	Email               string `form:"email" validate:"required,email"`
+	Password            string `form:"password" validate:"required,minrunes=8"`
+	validator.Validator `form:"-"`
+}
+
+// createUser inserts a user account directly into the database, prompting for its password, so
+// that operators can bootstrap the first admin account without the web form.
+func createUser(args []string) error {
+	fs := flag.NewFlagSet("createuser", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	name := fs.String("name", "", "Display name of the user")
+	username := fs.String("username", "", "Username of the user")
+	email := fs.String("email", "", "Email address of the user")
+	admin := fs.Bool("admin", false, "Give the user admin rights")
+	fs.Parse(args)
+
+	// Default the username to the local part of the email address.
+	if *username == "" {
+		*username, _, _ = strings.Cut(strings.ToLower(*email), "@")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	user := newUser{Name: *name, Username: *username, Email: *email, Password: password}
+	user.CheckStruct(&user)
+	if !user.Valid() {
+		for _, key := range []string{"name", "username", "email", "password"} {
+			for _, message := range user.FieldErrors[key] {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", key, message)
+			}
+		}
+		return errors.New("invalid user details")
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	users, err := models.NewUserModel(db)
+	if err != nil {
+		return err
+	}
+
+	err = users.Insert(user.Name, user.Username, user.Email, user.Password)
+	if err != nil {
+		return err
+	}
+
+	if *admin {
+		err = users.SetAdmin(user.Email, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("created user %s <%s>", user.Username, user.Email)
+	if *admin {
+		fmt.Print(" with admin rights")
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// readPassword prompts for a password and its confirmation. When stdin is a terminal the input
+// isn't echoed; otherwise a single line is read, so that the password can be piped in.
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if string(password) != string(confirm) {
+		return "", errors.New("passwords do not match")
+	}
+
+	return string(password), nil
+}
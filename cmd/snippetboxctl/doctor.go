@@ -0,0 +1,284 @@
+package main
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"snippetbox.adcon.dev/internal/dbconfig"
+)
+
+// The outcome of a doctor check. A warning is something to look into that doesn't stop the server
+// from working yet.
+const (
+	doctorPass = "PASS"
+	doctorWarn = "WARN"
+	doctorFail = "FAIL"
+)
+
+// The tables the web server reads and writes, and the privileges it needs on each of them.
+var (
+	doctorTables     = []string{"snippets", "snippet_views", "snippet_accesses", "snippet_trending", "collections", "collection_snippets", "share_links", "short_links", "organizations", "organization_members", "organization_invitations", "snippet_permissions", "snippet_metadata", "digest_subscriptions", "expiry_reminders", "user_preferences", "saved_searches", "snippet_drafts", "snippet_locks", "data_exports", "erasures", "users", "sessions"}
+	doctorPrivileges = []string{"SELECT", "INSERT", "UPDATE", "DELETE"}
+)
+
+// doctor inspects a deployment for operational problems the server wouldn't report until they
+// bite: an expiring TLS certificate, missing database privileges or tables, directories it can't
+// write to and a clock that disagrees with the database's.
+func doctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	certFile := fs.String("tls-cert", "./tls/cert.pem", "TLS certificate used by the web server")
+	certWarn := fs.Duration("tls-warn", 30*24*time.Hour, "Warn when the TLS certificate expires within this long")
+	dirs := fs.String("dirs", os.TempDir(), "Comma-separated directories the server must be able to write to")
+	maxSkew := fs.Duration("max-skew", 2*time.Second, "Warn when the clocks of this host and the database differ by more than this")
+	fs.Parse(args)
+
+	failed := 0
+	report := func(name, status, detail string) {
+		fmt.Printf("%s %-18s %s\n", status, name, detail)
+		if status == doctorFail {
+			failed++
+		}
+	}
+
+	status, detail := checkCertificate(*certFile, *certWarn, time.Now())
+	report("TLS certificate", status, detail)
+
+	for _, dir := range strings.Split(*dirs, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			status, detail := checkWritable(dir)
+			report("directory", status, detail)
+		}
+	}
+
+	db, dbName, err := openDoctorDB(dbConfig)
+	if err != nil {
+		report("database", doctorFail, err.Error())
+	} else {
+		defer db.Close()
+		report("database", doctorPass, "connected to "+dbName)
+
+		status, detail := checkTables(db, dbName)
+		report("tables", status, detail)
+
+		status, detail = checkPrivileges(db, dbName)
+		report("privileges", status, detail)
+
+		status, detail = checkClockSkew(db, *maxSkew)
+		report("clock skew", status, detail)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+
+	return nil
+}
+
+// openDoctorDB opens the database with times parsed in UTC, for comparing clocks, and returns the
+// name of the database the settings select.
+func openDoctorDB(config *dbconfig.Config) (*sql.DB, string, error) {
+	cfg, err := config.MySQL()
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.DBName == "" {
+		return nil, "", errors.New("no database name given in -dsn or -db-name")
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+
+	db, err := openDSN(cfg.FormatDSN())
+	if err != nil {
+		return nil, "", err
+	}
+
+	return db, cfg.DBName, nil
+}
+
+// checkCertificate reports whether the first certificate in a PEM file is valid at now, warning
+// when it expires within the given period.
+func checkCertificate(file string, warnWithin time.Duration, now time.Time) (string, string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return doctorFail, err.Error()
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return doctorFail, file + " doesn't contain a PEM certificate"
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return doctorFail, err.Error()
+	}
+
+	expiry := cert.NotAfter.UTC().Format(time.RFC3339)
+
+	switch {
+	case now.Before(cert.NotBefore):
+		return doctorFail, "not valid before " + cert.NotBefore.UTC().Format(time.RFC3339)
+	case now.After(cert.NotAfter):
+		return doctorFail, "expired on " + expiry
+	case cert.NotAfter.Sub(now) < warnWithin:
+		return doctorWarn, fmt.Sprintf("expires in %d days, on %s", int(cert.NotAfter.Sub(now).Hours()/24), expiry)
+	}
+
+	return doctorPass, "valid until " + expiry
+}
+
+// checkWritable reports whether a file can be created in a directory.
+func checkWritable(dir string) (string, string) {
+	f, err := os.CreateTemp(dir, ".snippetbox-doctor-*")
+	if err != nil {
+		return doctorFail, fmt.Sprintf("%s is not writable: %v", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	os.Remove(name)
+
+	return doctorPass, filepath.Clean(dir) + " is writable"
+}
+
+// checkTables reports whether the tables the server uses exist, including the sessions table,
+// which is only created by the migrations.
+func checkTables(db *sql.DB, dbName string) (string, string) {
+	missing := []string{}
+
+	for _, table := range doctorTables {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?`, dbName, table).Scan(&n)
+		if err != nil {
+			return doctorFail, err.Error()
+		}
+		if n == 0 {
+			missing = append(missing, table)
+		}
+	}
+
+	if len(missing) > 0 {
+		return doctorFail, "missing " + strings.Join(missing, ", ") + "; run snippetboxctl migrate"
+	}
+
+	return doctorPass, strings.Join(doctorTables, ", ") + " exist"
+}
+
+// checkPrivileges reports whether the user of the DSN has the privileges the server needs on each
+// table. It warns when the user can also change the schema, which the web user shouldn't be able to.
+func checkPrivileges(db *sql.DB, dbName string) (string, string) {
+	rows, err := db.Query(`SHOW GRANTS FOR CURRENT_USER()`)
+	if err != nil {
+		return doctorFail, err.Error()
+	}
+	defer rows.Close()
+
+	grants := []string{}
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return doctorFail, err.Error()
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return doctorFail, err.Error()
+	}
+
+	missing := []string{}
+	for _, table := range doctorTables {
+		granted := grantedPrivileges(grants, dbName, table)
+		for _, priv := range doctorPrivileges {
+			if !granted[priv] && !granted["ALL PRIVILEGES"] {
+				missing = append(missing, priv+" on "+table)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return doctorFail, "missing " + strings.Join(missing, ", ")
+	}
+
+	granted := grantedPrivileges(grants, dbName, "")
+	if granted["ALL PRIVILEGES"] || granted["ALTER"] || granted["DROP"] {
+		return doctorWarn, "the user can change the schema; the web server only needs " + strings.Join(doctorPrivileges, ", ")
+	}
+
+	return doctorPass, strings.Join(doctorPrivileges, ", ") + " granted"
+}
+
+// grantRX matches the privileges and scope of a GRANT statement as returned by SHOW GRANTS.
+var grantRX = regexp.MustCompile("^GRANT (.+) ON (\\S+) TO ")
+
+// grantedPrivileges returns the privileges that the grants give on a table of a database, or on
+// the database as a whole if table is empty. Column privileges and roles are ignored.
+func grantedPrivileges(grants []string, dbName, table string) map[string]bool {
+	granted := map[string]bool{}
+
+	for _, grant := range grants {
+		m := grantRX.FindStringSubmatch(grant)
+		if m == nil {
+			continue
+		}
+
+		grantDB, grantTable, _ := strings.Cut(m[2], ".")
+		grantDB = strings.Trim(grantDB, "`")
+		grantTable = strings.Trim(grantTable, "`")
+
+		if grantDB != "*" && grantDB != dbName {
+			continue
+		}
+		if grantTable != "*" && grantTable != table {
+			continue
+		}
+
+		for _, priv := range strings.Split(m[1], ",") {
+			priv = strings.TrimSpace(priv)
+			if !strings.Contains(priv, "(") {
+				granted[priv] = true
+			}
+		}
+	}
+
+	return granted
+}
+
+// checkClockSkew compares the clock of this host with the database's. Snippet expiry is decided by
+// the server's clock and session expiry by the database's, so they need to agree.
+func checkClockSkew(db *sql.DB, maxSkew time.Duration) (string, string) {
+	before := time.Now()
+
+	var dbNow time.Time
+	if err := db.QueryRow(`SELECT UTC_TIMESTAMP(6)`).Scan(&dbNow); err != nil {
+		return doctorFail, err.Error()
+	}
+
+	after := time.Now()
+
+	// Compare with the middle of the round trip, which is the best guess of when the database read
+	// its clock.
+	local := before.Add(after.Sub(before) / 2)
+	skew := dbNow.Sub(local).Round(time.Millisecond)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	detail := fmt.Sprintf("the database clock is %s off", skew)
+
+	switch {
+	case skew > time.Minute:
+		return doctorFail, detail
+	case skew > maxSkew:
+		return doctorWarn, detail
+	}
+
+	return doctorPass, detail
+}
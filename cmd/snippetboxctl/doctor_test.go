@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestGrantedPrivileges(t *testing.T) {
+
+	t.Parallel()
+
+	grants := []string{
+		"GRANT USAGE ON *.* TO `web`@`localhost`",
+		"GRANT SELECT, INSERT, UPDATE ON `snippetbox`.* TO `web`@`localhost`",
+		"GRANT DELETE ON `snippetbox`.`sessions` TO `web`@`localhost`",
+		"GRANT SELECT (`id`), UPDATE (`title`) ON `snippetbox`.`snippets` TO `web`@`localhost`",
+		"GRANT ALL PRIVILEGES ON `other`.* TO `web`@`localhost`",
+	}
+
+	tests := []struct {
+		name  string
+		table string
+		priv  string
+		want  bool
+	}{
+		{"Database grant", "users", "INSERT", true},
+		{"Missing privilege", "users", "DELETE", false},
+		{"Table grant", "sessions", "DELETE", true},
+		{"Table grant on another table", "snippets", "DELETE", false},
+		{"Database as a whole", "", "UPDATE", true},
+		{"Other database", "", "ALL PRIVILEGES", false},
+		{"Usage only", "", "USAGE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			granted := grantedPrivileges(grants, "snippetbox", tt.table)
+			assert.Equal(t, granted[tt.priv], tt.want)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// erase erases the personal data of a user, or with -verify lists the erasures of an email address.
+// Sessions of the erased user stay in the database until they expire, but no longer log anyone in.
+func erase(args []string) error {
+	fs := flag.NewFlagSet("erase", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	userID := fs.Int("user-id", 0, "ID of the user to erase")
+	verify := fs.String("verify", "", "List the erasures of this email address instead of erasing anyone")
+	fs.Parse(args)
+
+	if *verify == "" && *userID < 1 {
+		return errors.New("-user-id or -verify is required")
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	erasures := &models.ErasureModel{DB: db}
+
+	if *verify != "" {
+		found, err := erasures.ByEmail(*verify)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			fmt.Printf("no erasures of %s\n", *verify)
+		}
+		for _, e := range found {
+			fmt.Printf("erasure %d: user %d erased at %s (%s)\n", e.ID, e.UserID, e.Erased.UTC().Format("2006-01-02 15:04:05"), e.Summary)
+		}
+		return nil
+	}
+
+	e, err := erasures.Erase(*userID, 0)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("erased user %d as erasure %d: %s\n", e.UserID, e.ID, e.Summary)
+
+	return nil
+}
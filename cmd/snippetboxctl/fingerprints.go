@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// backfillFingerprints computes the content fingerprints of the snippets created before they were
+// stored, so that duplicates of them are pointed out too.
+func backfillFingerprints(args []string) error {
+	fs := flag.NewFlagSet("backfill-fingerprints", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	contentCodec := contentFlags(fs)
+	batch := fs.Int("batch", 100, "Number of snippets to process per batch")
+	fs.Parse(args)
+
+	content, err := contentCodec()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	snippets := &models.SnippetModel{DB: db, Content: content}
+
+	n, err := snippets.BackfillFingerprints(*batch)
+	fmt.Printf("computed the fingerprints of %d snippets\n", n)
+
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"snippetbox.adcon.dev/internal/migrations"
+)
+
+// webPrivileges are the privileges the web server's database user is granted on the database.
+const webPrivileges = "SELECT, INSERT, UPDATE, DELETE"
+
+// initDB sets up the database of a new deployment: it creates the database named in the DSN if it
+// doesn't exist, creates every table by applying the migrations, and with -web-user creates the
+// web server's database user and grants it what it needs. Running it again only applies the
+// migrations added since, so it's safe to repeat. The database user needs the privileges to create
+// databases, tables and users, such as root's.
+func initDB(args []string) error {
+	fs := flag.NewFlagSet("init-db", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	webUser := fs.String("web-user", "", "Create this database user for the web server, prompting for its password")
+	webHost := fs.String("web-host", "localhost", "Host the web server's database user connects from")
+	fs.Parse(args)
+
+	cfg, err := dbConfig.MySQL()
+	if err != nil {
+		return err
+	}
+	name := cfg.DBName
+	if name == "" {
+		return errors.New("name the database to set up in -dsn or -db-name")
+	}
+
+	var webPassword string
+	if *webUser != "" {
+		if webPassword, err = readPassword(); err != nil {
+			return err
+		}
+		if webPassword == "" {
+			return errors.New("the web user needs a password")
+		}
+	}
+
+	// Connect without a database first, since it may not exist yet.
+	server := cfg.Clone()
+	server.DBName = ""
+	db, err := openDSN(server.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := db.Exec(`CREATE DATABASE IF NOT EXISTS ` + quoteIdentifier(name) + ` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`)
+	if err != nil {
+		return err
+	}
+	// MySQL reports a row for a database it created, and a warning without rows for one that exists.
+	if created, _ := result.RowsAffected(); created > 0 {
+		fmt.Printf("created database %s\n", name)
+	} else {
+		fmt.Printf("database %s exists\n", name)
+	}
+
+	if *webUser != "" {
+		user := quoteString(*webUser) + "@" + quoteString(*webHost)
+		if _, err := db.Exec(`CREATE USER IF NOT EXISTS ` + user + ` IDENTIFIED BY ` + quoteString(webPassword)); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`GRANT ` + webPrivileges + ` ON ` + quoteIdentifier(name) + `.* TO ` + user); err != nil {
+			return err
+		}
+		fmt.Printf("granted %s on %s to %s@%s\n", webPrivileges, name, *webUser, *webHost)
+	}
+
+	schema, err := openDSN(cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer schema.Close()
+
+	// Tables created by hand from the scripts in /sql may be older than the first migration, which
+	// only creates the tables that are missing.
+	var tables, managed int
+	err = schema.QueryRow(`SELECT COUNT(*), COUNT(CASE WHEN table_name = 'schema_migrations' THEN 1 END)
+    FROM information_schema.tables WHERE table_schema = DATABASE()`).Scan(&tables, &managed)
+	if err != nil {
+		return err
+	}
+	if tables > 0 && managed == 0 {
+		return fmt.Errorf("database %s has tables that weren't created by migrations; bring them up to date with the alter_*.sql scripts in /sql and run migrate", name)
+	}
+
+	applied, err := migrations.Apply(schema)
+	for _, version := range applied {
+		fmt.Printf("applied %s\n", version)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+
+	return err
+}
+
+// quoteIdentifier quotes a database name for MySQL statements, which can't take it as a parameter.
+func quoteIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// quoteString quotes a string literal for MySQL statements that can't take parameters, such as
+// CREATE USER.
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(s) + "'"
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestQuote(t *testing.T) {
+
+	t.Parallel()
+
+	assert.Equal(t, quoteIdentifier("snippetbox"), "`snippetbox`")
+	assert.Equal(t, quoteIdentifier("odd`name"), "`odd``name`")
+	assert.Equal(t, quoteString("web"), "'web'")
+	assert.Equal(t, quoteString(`it's a \ pass`), `'it''s a \\ pass'`)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/langdetect"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// detectLanguages sets the language of the snippets created before languages were detected.
+func detectLanguages(args []string) error {
+	fs := flag.NewFlagSet("detect-languages", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	contentCodec := contentFlags(fs)
+	batch := fs.Int("batch", 100, "Number of snippets to process per batch")
+	fs.Parse(args)
+
+	content, err := contentCodec()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	snippets := &models.SnippetModel{DB: db, Content: content}
+
+	n, err := snippets.DetectLanguages(langdetect.Detect, *batch)
+	fmt.Printf("detected the language of %d snippets\n", n)
+
+	return err
+}
@@ -0,0 +1,155 @@
+// Package main implements snippetboxctl, a command-line tool for operating a Snippetbox deployment.
+package main
+
+// Import the necessary packages.
+import (
+	"database/sql" // Package for interacting with SQL databases.
+	"flag"         // Package for parsing command-line flags.
+	"fmt"          // Package for formatted I/O.
+	"os"           // Package for interacting with the operating system.
+	"strings"      // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/dbconfig" // Import the database configuration package.
+	"snippetbox.adcon.dev/internal/models"   // Import the models package.
+
+	_ "github.com/go-sql-driver/mysql" // Import the MySQL driver.
+)
+
+// command describes a snippetboxctl subcommand.
+type command struct {
+	name    string                    // name is the subcommand name used on the command line.
+	summary string                    // summary is a one-line description shown in the usage message.
+	run     func(args []string) error // run executes the subcommand with the remaining arguments.
+}
+
+// commands lists the available subcommands in the order they are shown in the usage message.
+var commands = []command{
+	{"init-db", "create the database, its tables and the web server's user", initDB},
+	{"migrate", "apply pending database schema migrations", migrate},
+	{"doctor", "check certificates, database privileges, directories and clocks", doctor},
+	{"createuser", "create a user account, optionally with admin rights", createUser},
+	{"export", "write all users and snippets to a JSON lines file", export},
+	{"import", "load users and snippets from an export into an empty database", importData},
+	{"backup", "write a consistent, compressed snapshot of the database", backup},
+	{"restore", "load a backup into an empty database at the same schema version", restore},
+	{"purge", "delete expired snippets, sessions and other expired data", purge},
+	{"seed", "fill the database with fake users and snippets", seed},
+	{"rekey", "re-encrypt snippet content with the active key", rekey},
+	{"backfill-ulids", "assign public ULIDs to snippets created before they existed", backfillULIDs},
+	{"detect-languages", "detect the language of snippets that have none", detectLanguages},
+	{"backfill-fingerprints", "compute the duplicate detection fingerprints of older snippets", backfillFingerprints},
+	{"backfill-search", "index the content of older snippets for full-text search", backfillSearch},
+	{"erase", "erase the personal data of a user, or verify an erasure", erase},
+}
+
+// main dispatches to the subcommand named by the first argument.
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the list of subcommands to stderr.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: snippetboxctl <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// dbFlags registers the flags describing the database connection, -dsn and the -db-* flags, using
+// the same names as the web server.
+func dbFlags(fs *flag.FlagSet) *dbconfig.Config {
+	config := &dbconfig.Config{}
+	config.RegisterFlags(fs)
+	return config
+}
+
+// openDB builds the data source name from the database settings and opens a connection with it.
+func openDB(config *dbconfig.Config) (*sql.DB, error) {
+	dsn, err := config.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return openDSN(dsn)
+}
+
+// openDSN opens and verifies a database connection with the provided data source name (DSN).
+func openDSN(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// loadKeyring returns the content encryption keyring from either the inline specification or the
+// keyring file, using the same format as the web server. It returns nil if neither is set.
+func loadKeyring(spec, file string) (*models.Keyring, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		spec = strings.TrimSpace(string(data))
+	}
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	return models.ParseKeyring(spec)
+}
+
+// contentFlags registers the flags describing how snippet content is stored, using the same names
+// and defaults as the web server. The returned function builds the content settings once the flags
+// have been parsed.
+func contentFlags(fs *flag.FlagSet) func() (models.ContentCodec, error) {
+	keys := fs.String("content-keys", "", "Keyring for snippet content encryption (id:base64key,...; first key is active)")
+	keysFile := fs.String("content-keys-file", "", "File containing the snippet content keyring")
+	codecName := fs.String("compress-codec", "zstd", "Snippet content compression codec (none, gzip or zstd)")
+	threshold := fs.Int("compress-threshold", 4096, "Compress snippet content of at least this many bytes (0 disables)")
+
+	return func() (models.ContentCodec, error) {
+		codec, err := models.ParseCodec(*codecName)
+		if err != nil {
+			return models.ContentCodec{}, err
+		}
+
+		content := models.ContentCodec{Threshold: *threshold, Codec: codec}
+
+		keyring, err := loadKeyring(*keys, *keysFile)
+		if err != nil {
+			return models.ContentCodec{}, err
+		}
+		// Only set the keyring when there is one, so that Keys stays a nil interface otherwise.
+		if keyring != nil {
+			content.Keys = keyring
+		}
+
+		return content, nil
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/migrations"
+)
+
+// migrate applies the pending schema migrations, or lists them with -status. The database user
+// needs the privileges to create and alter tables, which the web user normally doesn't have.
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	status := fs.Bool("status", false, "List pending migrations without applying them")
+	fs.Parse(args)
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *status {
+		pending, err := migrations.Pending(db)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		for _, version := range pending {
+			fmt.Printf("pending %s\n", version)
+		}
+		return nil
+	}
+
+	applied, err := migrations.Apply(db)
+	for _, version := range applied {
+		fmt.Printf("applied %s\n", version)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+
+	return err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// purge deletes expired snippets, stale sessions and other expired data, printing a summary of what
+// was deleted, or of what would be with -dry-run.
+func purge(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	batchSize := fs.Int("batch-size", 1000, "Maximum number of rows to delete per statement")
+	dryRun := fs.Bool("dry-run", false, "Report what would be deleted without deleting anything")
+	fs.Parse(args)
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	purger := &models.PurgeModel{DB: db, BatchSize: *batchSize}
+
+	counts, err := purger.Purge(*dryRun)
+
+	verb := "deleted"
+	if *dryRun {
+		verb = "would delete"
+	}
+	for _, c := range counts {
+		fmt.Printf("%s %d %s\n", verb, c.Rows, c.Name)
+	}
+
+	return err
+}
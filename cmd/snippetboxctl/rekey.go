@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// rekey re-encrypts snippet content with the active key of the keyring. Run it after adding a new
+// key to the front of the keyring; once it completes, the old keys can be removed.
+func rekey(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	contentCodec := contentFlags(fs)
+	batch := fs.Int("batch", 100, "Number of snippets to process per batch")
+	fs.Parse(args)
+
+	content, err := contentCodec()
+	if err != nil {
+		return err
+	}
+	if content.Keys == nil {
+		return errors.New("a keyring is required (-content-keys or -content-keys-file)")
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	snippets := &models.SnippetModel{DB: db, Content: content}
+
+	n, err := snippets.Reencrypt(*batch)
+	fmt.Printf("re-encrypted %d snippets with key %q\n", n, content.Keys.ActiveKeyID())
+
+	return err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// backfillSearch stores the plain text of the snippets written before full-text search indexed
+// their content, so that searches find them by their content too.
+func backfillSearch(args []string) error {
+	fs := flag.NewFlagSet("backfill-search", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	contentCodec := contentFlags(fs)
+	batch := fs.Int("batch", 100, "Number of snippets to process per batch")
+	fs.Parse(args)
+
+	content, err := contentCodec()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	snippets := &models.SnippetModel{DB: db, Content: content}
+
+	n, err := snippets.BackfillSearchText(*batch)
+	fmt.Printf("indexed the content of %d snippets for search\n", n)
+
+	return err
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// seedPassword is the password of every seeded user.
+const seedPassword = "password"
+
+var (
+	seedFirstNames = []string{"Alice", "Bob", "Carol", "Dave", "Erin", "Frank", "Grace", "Heidi", "Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter", "Yasmin"}
+	seedLastNames  = []string{"Jones", "Smith", "Garcia", "Müller", "Rossi", "Kowalski", "Nakamura", "Okafor", "Silva", "Dubois", "Novak", "Larsen"}
+	seedTopics     = []string{"retry loop", "config parser", "HTTP handler", "rate limiter", "CSV reader", "cache", "worker pool", "date helper", "slugify", "binary search", "JSON encoder", "query builder"}
+	seedAdjectives = []string{"Simple", "Tiny", "Generic", "Concurrent", "Minimal", "Fast", "Safe", "Lazy", "Reusable", "Naive"}
+	seedHaiku      = []string{"An old silent pond", "A frog jumps into the pond", "Splash! Silence again", "Over the wintry forest", "winds howl in rage", "with no leaves to blow", "First autumn morning", "the mirror I stare into", "shows my father's face"}
+)
+
+// seedSamples holds short content templates for each seeded language. %s is replaced with an
+// identifier derived from the snippet's topic.
+var seedSamples = map[string][]string{
+	"go": {
+		"package main\n\nimport \"fmt\"\n\nfunc %s() {\n\tfor i := 0; i < 3; i++ {\n\t\tfmt.Println(\"attempt\", i)\n\t}\n}\n",
+		"func %s(items []string) map[string]int {\n\tcounts := make(map[string]int)\n\tfor _, item := range items {\n\t\tcounts[item]++\n\t}\n\treturn counts\n}\n",
+	},
+	"python": {
+		"def %s(items):\n    seen = set()\n    for item in items:\n        if item not in seen:\n            seen.add(item)\n            yield item\n",
+		"import json\n\n\ndef %s(path):\n    with open(path) as f:\n        return json.load(f)\n",
+	},
+	"javascript": {
+		"export function %s(fn, ms) {\n  let timer;\n  return (...args) => {\n    clearTimeout(timer);\n    timer = setTimeout(() => fn(...args), ms);\n  };\n}\n",
+		"const %s = async (url) => {\n  const res = await fetch(url);\n  if (!res.ok) throw new Error(res.statusText);\n  return res.json();\n};\n",
+	},
+	"sql": {
+		"-- %s\nSELECT user_id, COUNT(*) AS total\nFROM orders\nWHERE created > NOW() - INTERVAL 30 DAY\nGROUP BY user_id\nORDER BY total DESC\nLIMIT 10;\n",
+	},
+	"bash": {
+		"#!/usr/bin/env bash\n# %s\nset -euo pipefail\n\nfor f in *.log; do\n  gzip \"$f\"\ndone\n",
+	},
+	"text": {
+		"%s\n\n",
+	},
+}
+
+// seed fills the database with fake users and snippets for development and load testing. Every
+// seeded user has the password "password".
+func seed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	contentCodec := contentFlags(fs)
+	userCount := fs.Int("users", 10, "Number of users to create")
+	snippetCount := fs.Int("snippets", 100, "Number of snippets to create")
+	days := fs.Int("days", 90, "Spread creation times over this many past days")
+	randSeed := fs.Uint64("seed", 0, "Random seed for reproducible data (0 picks one at random)")
+	fs.Parse(args)
+
+	content, err := contentCodec()
+	if err != nil {
+		return err
+	}
+
+	if *randSeed == 0 {
+		*randSeed = rand.Uint64()
+	}
+	rng := rand.New(rand.NewPCG(*randSeed, *randSeed))
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Hash the shared password once; hashing it for every user would dominate the run time.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(seedPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	span := time.Duration(*days) * 24 * time.Hour
+
+	users := &models.UserModel{DB: db}
+	userIDs := []int{}
+
+	for i := 0; i < *userCount; i++ {
+		first := seedFirstNames[rng.IntN(len(seedFirstNames))]
+		last := seedLastNames[rng.IntN(len(seedLastNames))]
+		// The random suffix keeps usernames and emails unique across runs.
+		username := fmt.Sprintf("%s-%s", strings.ToLower(first), strings.ToLower(ulid.Make().String()[20:]))
+
+		id, err := users.Restore(&models.User{
+			Name:           first + " " + last,
+			Username:       username,
+			Email:          username + "@example.com",
+			HashedPassword: hashedPassword,
+			Created:        now.Add(-span - time.Duration(rng.Int64N(int64(24*time.Hour)))),
+		})
+		if err != nil {
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	snippets := &models.SnippetModel{DB: db, Content: content}
+	languages := []string{"go", "python", "javascript", "sql", "bash", "text"}
+	lifetimes := []int{1, 7, 365}
+
+	for i := 0; i < *snippetCount; i++ {
+		language := languages[rng.IntN(len(languages))]
+		topic := seedTopics[rng.IntN(len(seedTopics))]
+		title := seedAdjectives[rng.IntN(len(seedAdjectives))] + " " + topic
+
+		var body string
+		if language == "text" {
+			lines := []string{}
+			for j := 0; j < 3; j++ {
+				lines = append(lines, seedHaiku[rng.IntN(len(seedHaiku))])
+			}
+			body = strings.Join(lines, "\n") + "\n"
+		} else {
+			samples := seedSamples[language]
+			body = fmt.Sprintf(samples[rng.IntN(len(samples))], identifier(topic))
+		}
+
+		created := now.Add(-time.Duration(rng.Int64N(int64(span))))
+		updated := created
+		if rng.IntN(4) == 0 {
+			updated = created.Add(time.Duration(rng.Int64N(int64(now.Sub(created)))))
+		}
+
+		// About a fifth of the snippets are anonymous.
+		ownerID := 0
+		if len(userIDs) > 0 && rng.IntN(5) != 0 {
+			ownerID = userIDs[rng.IntN(len(userIDs))]
+		}
+
+		_, err := snippets.Restore(&models.Snippet{
+			ULID:      ulid.MustNew(ulid.Timestamp(created), ulid.DefaultEntropy()).String(),
+			Title:     title,
+			Content:   body,
+			Created:   created,
+			Expires:   created.AddDate(0, 0, lifetimes[rng.IntN(len(lifetimes))]),
+			Updated:   updated,
+			UpdatedBy: ownerID,
+			OwnerID:   ownerID,
+			Language:  language,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("created %d users and %d snippets (seed %d); users have the password %q\n", *userCount, *snippetCount, *randSeed, seedPassword)
+
+	return nil
+}
+
+// identifier turns a topic such as "retry loop" into a camelCase identifier such as "retryLoop".
+func identifier(topic string) string {
+	words := strings.Fields(strings.ToLower(topic))
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return strings.Join(words, "")
+}
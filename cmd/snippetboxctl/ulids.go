@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// backfillULIDs assigns a public ULID to every snippet that doesn't have one yet.
+func backfillULIDs(args []string) error {
+	fs := flag.NewFlagSet("backfill-ulids", flag.ExitOnError)
+	dbConfig := dbFlags(fs)
+	fs.Parse(args)
+
+	db, err := openDB(dbConfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	snippets := &models.SnippetModel{DB: db}
+
+	n, err := snippets.BackfillULIDs()
+	fmt.Printf("assigned ULIDs to %d snippets\n", n)
+
+	return err
+}
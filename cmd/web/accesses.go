@@ -0,0 +1,179 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http"  // Package for building HTTP servers and clients.
+	"net/netip" // Package for parsing IP addresses.
+	"net/url"   // Package for parsing the referrer.
+	"strings"   // Package for manipulating strings.
+	"time"      // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// The levels of the access log, set with the -access-log flag.
+const (
+	accessLogOff   = "off"   // Nothing is recorded.
+	accessLogBasic = "basic" // Only the time of each access is recorded.
+	accessLogFull  = "full"  // The coarse network of the client and the referring host are recorded too.
+)
+
+// accessHistoryLimit is the number of accesses shown on the access history page.
+const accessHistoryLimit = 200
+
+// validAccessLog reports whether level is a known access log level.
+func validAccessLog(level string) bool {
+	return level == accessLogOff || level == accessLogBasic || level == accessLogFull
+}
+
+// recordAccess queues an access to a snippet for its access log. Owners reading their own snippets
+// aren't recorded. Like recordView, it never blocks: if the queue is full the access is dropped.
+func (app *application) recordAccess(r *http.Request, snippet *models.Snippet) {
+	if app.accessQueue == nil {
+		return
+	}
+
+	if userID := app.authenticatedUserID(r); userID != 0 && userID == snippet.OwnerID {
+		return
+	}
+
+	select {
+	case app.accessQueue <- newAccess(r, snippet.ID, app.config.AccessLog, app.clock.Now()):
+	default:
+	}
+}
+
+// newAccess builds the access log entry of a request, keeping only what the level allows.
+func newAccess(r *http.Request, snippetID int, level string, now time.Time) models.Access {
+	access := models.Access{SnippetID: snippetID, Accessed: now.UTC()}
+
+	if level == accessLogFull {
+		access.Network = coarseNetwork(clientIP(r))
+		access.Referrer = referrerHost(r)
+	}
+
+	return access
+}
+
+// coarseNetwork reduces an IP address to the network it's in, a /24 for IPv4 and a /48 for IPv6,
+// which locates a client roughly without identifying it. It returns an empty string for anything
+// that isn't an IP address.
+func coarseNetwork(ip string) string {
+	prefix, ok := coarsePrefix(ip)
+	if !ok {
+		return ""
+	}
+
+	return prefix.String()
+}
+
+// coarsePrefix returns the /24 or /48 network of an IP address, and false if ip isn't one.
+func coarsePrefix(ip string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	return prefix, true
+}
+
+// referrerHost returns the host of the page that linked to the request, or an empty string if
+// there's none, it's a page of this site or the host isn't a valid hostname or IP address.
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Host == "" || u.Host == r.Host {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if _, err := netip.ParseAddr(host); err != nil && !validHostname(host) {
+		return ""
+	}
+
+	return host
+}
+
+// validHostname reports whether host is a DNS hostname: dot-separated labels of letters, digits
+// and hyphens, neither starting nor ending with a hyphen, of at most 63 characters and 253 in all.
+func validHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// startAccessRecorder starts a goroutine that writes queued accesses to the database every
+// interval, so that busy snippets don't cost a write per request.
+func (app *application) startAccessRecorder(interval time.Duration) {
+	app.accessQueue = make(chan models.Access, 1024)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		batch := []models.Access{}
+
+		for {
+			select {
+			case access := <-app.accessQueue:
+				batch = append(batch, access)
+			case <-ticker.C:
+				if len(batch) == 0 {
+					continue
+				}
+				app.runJob("record accesses", func() error {
+					return app.accesses.Add(batch)
+				})
+				batch = []models.Access{}
+			}
+		}
+	}()
+}
+
+// snippetAccesses serves the "/snippet/accesses/:id" URL. It shows the owner of a snippet its
+// recent accesses.
+func (app *application) snippetAccesses(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	accesses, err := app.accesses.BySnippet(snippet.ID, accessHistoryLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetData = snippet
+	data.Accesses = accesses
+	data.AccessLog = app.config.AccessLog
+	data.AccessRetentionDays = int(app.config.AccessLogRetention.Hours() / 24)
+
+	app.render(w, r, http.StatusOK, "accesses.html", data)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+func TestNewAccess(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		level        string
+		remoteAddr   string
+		referer      string
+		wantNetwork  string
+		wantReferrer string
+	}{
+		{"Basic", accessLogBasic, "203.0.113.77:5000", "https://example.org/post", "", ""},
+		{"IPv4", accessLogFull, "203.0.113.77:5000", "https://example.org/post", "203.0.113.0/24", "example.org"},
+		{"IPv6", accessLogFull, "[2001:db8:1234:5678::1]:5000", "", "2001:db8:1234::/48", ""},
+		{"Mapped IPv4", accessLogFull, "[::ffff:198.51.100.9]:5000", "", "198.51.100.0/24", ""},
+		{"Own site", accessLogFull, "203.0.113.77:5000", "https://snippetbox.test/", "203.0.113.0/24", ""},
+		{"Not an address", accessLogFull, "pipe", "not a url\x7f", "", ""},
+		{"Port", accessLogFull, "203.0.113.77:5000", "https://Example.org:8443/post", "203.0.113.0/24", "example.org"},
+		{"IP referrer", accessLogFull, "203.0.113.77:5000", "http://192.0.2.1/", "203.0.113.0/24", "192.0.2.1"},
+		{"Markup host", accessLogFull, "203.0.113.77:5000", "https://<script>alert(1)<%2Fscript>/", "203.0.113.0/24", ""},
+		{"Long label", accessLogFull, "203.0.113.77:5000", "https://" + strings.Repeat("a", 64) + ".org/", "203.0.113.0/24", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://snippetbox.test/snippet/view/1", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+
+			access := newAccess(r, 1, tt.level, now)
+
+			assert.Equal(t, access.SnippetID, 1)
+			assert.Equal(t, access.Accessed, now)
+			assert.Equal(t, access.Network, tt.wantNetwork)
+			assert.Equal(t, access.Referrer, tt.wantReferrer)
+		})
+	}
+}
+
+func TestSnippetAccesses(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.AccessLog = accessLogFull
+	app.config.AccessLogRetention = 30 * 24 * time.Hour
+	app.accessQueue = make(chan models.Access, 10)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	visitor := newTestServer(t, app.routes())
+	defer visitor.Close()
+
+	code, _, _ := visitor.follow(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+
+	access := <-app.accessQueue
+	assert.Equal(t, access.SnippetID, 1)
+	assert.Equal(t, access.Network, "127.0.0.0/24")
+
+	// The owner's own visits aren't logged.
+	ts.login(t, "alice@example.com", "pa$$word")
+	ts.follow(t, "/snippet/view/1")
+	assert.Equal(t, len(app.accessQueue), 0)
+
+	access.Referrer = "example.org"
+	assert.NilError(t, app.accesses.Add([]models.Access{access}))
+
+	// Referrers recorded before they were validated are escaped.
+	access.Referrer = "<script>x</script>"
+	assert.NilError(t, app.accesses.Add([]models.Access{access}))
+
+	code, _, body := ts.get(t, "/snippet/accesses/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>127.0.0.0/24</td>")
+	assert.StringContains(t, body, "<td>example.org</td>")
+	assert.StringContains(t, body, "<td>&lt;script&gt;x&lt;/script&gt;</td>")
+	assert.StringContains(t, body, "kept for 30 days")
+
+	// Only the owner can see the history.
+	visitor.login(t, "dupe@example.com", "pa$$word")
+	code, _, body = visitor.get(t, "/snippet/accesses/1")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, strings.Contains(body, "example.org"), false)
+}
@@ -0,0 +1,70 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"fmt"      // Package for formatted I/O.
+	"math"     // Package for rounding the retry delay up.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting numeric types to strings.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// The stricter limits of snippets created without logging in, with -anonymous-posting.
+const (
+	anonymousMaxExpires  = 7        // anonymousMaxExpires is the longest an anonymous snippet is kept, in days.
+	anonymousMaxContent  = 16 << 10 // anonymousMaxContent is the largest content of an anonymous snippet, in bytes.
+	anonymousPostsPerDay = 20       // anonymousPostsPerDay is the number of anonymous snippets a client can create per day on average.
+	anonymousPostsBurst  = 3        // anonymousPostsBurst is the number of anonymous snippets a client can create in a row.
+)
+
+// newAnonymousLimiter returns the limiter of anonymous snippet creation.
+func newAnonymousLimiter() *ipRateLimiter {
+	return newIPRateLimiter(anonymousPostsPerDay/(24*60*60.0), anonymousPostsBurst)
+}
+
+// rateLimitAnonymous is a middleware function like rateLimit, which only limits visitors who aren't
+// logged in. It must come after authenticate.
+func (app *application) rateLimitAnonymous(limiter *ipRateLimiter) func(http.Handler) http.Handler {
+	// Tell clients when they'll have a token again.
+	retryAfter := strconv.Itoa(int(math.Ceil(1 / float64(limiter.limit))))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.isAuthenticated(r) && !limiter.allow(clientIP(r)) {
+				w.Header().Set("Retry-After", retryAfter)
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// createNeedsCaptcha reports whether creating a snippet requires human verification: for visitors
+// who aren't logged in, and clients from the countries in -geoip-challenge.
+func (app *application) createNeedsCaptcha(r *http.Request) bool {
+	return geoChallenged(r) || !app.isAuthenticated(r)
+}
+
+// checkAnonymousSnippet applies the limits of anonymous snippets to the snippet form of a visitor
+// who isn't logged in. Anonymous snippets have no owner, so they can't be private.
+func checkAnonymousSnippet(form *snippetCreateForm) {
+	form.CheckField(form.Expires != models.NeverExpires && form.Expires <= anonymousMaxExpires, "expires", fmt.Sprintf("Snippets posted without logging in are deleted within %d days", anonymousMaxExpires))
+	form.CheckField(len(form.Content) <= anonymousMaxContent, "content", fmt.Sprintf("Snippets posted without logging in can be at most %d KB", anonymousMaxContent>>10))
+	form.CheckField(!form.Private, "private", "Log in to create private snippets")
+	form.CheckField(form.Slug == "", "slug", "Log in to choose a custom URL")
+}
+
+// checkAnonymousPosting validates the settings of anonymous posting, which needs human
+// verification to keep bots out.
+func checkAnonymousPosting(config configuration) error {
+	if config.AnonymousPosting && (config.CaptchaProvider == "" || config.CaptchaProvider == "none") {
+		return errors.New("-anonymous-posting needs -captcha-provider")
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"encoding/json" // Package for decoding API requests.
+	"errors"        // Package for creating error messages.
+	"fmt"           // Package for formatted I/O.
+	"net/http"      // Package for building HTTP servers and clients.
+	"time"          // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/filter"    // Import the content filter package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import the validator package.
+)
+
+// apiSnippet is a snippet in the responses of the JSON API.
+type apiSnippet struct {
+	ID       string     `json:"id"` // ID is the public identifier of the snippet, as used in its URL.
+	Title    string     `json:"title"`
+	Content  string     `json:"content"`
+	Language string     `json:"language,omitempty"`
+	License  string     `json:"license,omitempty"`
+	Private  bool       `json:"private"`
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires"` // Expires is null for snippets that never expire.
+	URL      string     `json:"url"`     // URL is the path of the page of the snippet.
+}
+
+// invalidProblem is the problem details of a request the API can't accept because of invalid
+// fields, with their messages.
+type invalidProblem struct {
+	problem
+	Errors   map[string][]string `json:"errors,omitempty"`   // Errors are the messages of each invalid field.
+	Messages []string            `json:"messages,omitempty"` // Messages are the messages that aren't about a single field.
+}
+
+// writeInvalid sends 422 Unprocessable Entity problem details with the messages of a validator.
+func (app *application) writeInvalid(w http.ResponseWriter, r *http.Request, detail string, v validator.Validator) {
+	js, _ := json.Marshal(invalidProblem{
+		problem: problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(http.StatusUnprocessableEntity),
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   detail,
+			Instance: r.URL.Path,
+		},
+		Errors:   v.FieldErrors,
+		Messages: v.NonFieldErrors,
+	})
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(append(js, '\n'))
+}
+
+// newAPISnippet returns the API representation of a snippet.
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	var expires *time.Time
+	if !s.Permanent() {
+		expires = &s.Expires
+	}
+
+	return apiSnippet{
+		ID:       s.PublicID(),
+		Title:    s.Title,
+		Content:  s.Content,
+		Language: s.Language,
+		License:  s.License,
+		Private:  s.Private,
+		Created:  s.Created,
+		Expires:  expires,
+		URL:      "/snippet/view/" + s.PublicID(),
+	}
+}
+
+// apiSnippetList serves GET requests to "/api/v1/snippets" with the latest listed snippets, as
+// shown on the home page.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	snippets, _, err := app.snippets.List(1, homePageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	list := make([]apiSnippet, 0, len(snippets))
+	for _, s := range snippets {
+		list = append(list, newAPISnippet(s))
+	}
+
+	app.writeJSON(w, r, http.StatusOK, map[string][]apiSnippet{"snippets": list})
+}
+
+// apiSnippetView serves GET requests to "/api/v1/snippets/:id", where the ID is a snippet's ULID
+// or integer ID. Snippets the client may not view are reported as not found, like on their page.
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippetFromParams(r)
+	if err != nil || !app.canView(r, snippet) {
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+		} else {
+			app.writeProblem(w, r, http.StatusNotFound, "There's no snippet with this ID.", r.URL.Path)
+		}
+		return
+	}
+
+	app.recordView(r, snippet.ID)
+	app.recordAccess(r, snippet)
+
+	app.writeJSON(w, r, http.StatusOK, newAPISnippet(snippet))
+}
+
+// apiSnippetCreate serves POST requests to "/api/v1/snippets". The JSON body holds the fields of
+// the create form, as in {"title": "...", "content": "...", "expires": 7, "private": false,
+// "language": "go"}, where an "expires" of -1 keeps the snippet forever. The snippet is validated
+// and screened like one created with the form, and the response is 201 Created with the snippet
+// and its URL in the Location header. Invalid snippets get 422 Unprocessable Entity with the
+// messages of the fields in the problem details.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+	if userID == 0 {
+		app.writeProblem(w, r, http.StatusUnauthorized, "Log in to create snippets.", r.URL.Path)
+		return
+	}
+
+	var input struct {
+		Title    string `json:"title"`
+		Content  string `json:"content"`
+		Expires  int    `json:"expires"`
+		Private  bool   `json:"private"`
+		Language string `json:"language"`
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.maxSnippetBody())
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The body is too large. Snippets can be at most %s, with a title of at most %d bytes.",
+				formatBytes(app.config.MaxContentBytes), app.config.MaxTitleBytes), r.URL.Path)
+			return
+		}
+		app.writeProblem(w, r, http.StatusBadRequest, "The body must be a JSON object with the fields of the snippet.", r.URL.Path)
+		return
+	}
+
+	form := snippetCreateForm{
+		Title:    input.Title,
+		Content:  input.Content,
+		Expires:  input.Expires,
+		Private:  input.Private,
+		Language: input.Language,
+	}
+	form.CheckStruct(form)
+	app.checkSize(&form.Validator, form.Title, form.Content)
+	form.CheckField(validLanguage(form.Language), "language", "Choose a language from the list")
+
+	// Screen the title and content against the content filter.
+	verdict, err := app.screen(form.Title, form.Content)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if verdict.Action == filter.Reject {
+		if err := app.recordFilterHit(r, verdict, 0); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.AddNonFieldError("This snippet contains content that isn't allowed")
+	}
+
+	if !form.Valid() {
+		app.writeInvalid(w, r, "The snippet isn't valid.", form.Validator)
+		return
+	}
+
+	id, err := app.snippets.InsertWith(form.Title, form.Content, form.Expires, userID, newVisibility(form.Private, verdict))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if app.config.CaptureClientInfo {
+		if err := app.snippets.RecordClient(id, app.storedIP(r), r.UserAgent()); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if form.Language != "" {
+		if err := app.snippets.SetLanguage(id, form.Language); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	} else {
+		app.detectLanguage(id, "", form.Title, form.Content)
+	}
+
+	if err := app.recordVerdict(r, verdict, id); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sendWebmentions(id)
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/snippets/"+snippet.PublicID())
+	app.writeJSON(w, r, http.StatusCreated, newAPISnippet(snippet))
+}
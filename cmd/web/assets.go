@@ -0,0 +1,190 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"bytes"           // Package for serving the compressed assets.
+	"crypto/sha256"   // Package for hashing the content of assets.
+	"crypto/sha512"   // Package for the Subresource Integrity hashes.
+	"encoding/base64" // Package for encoding the Subresource Integrity hashes.
+	"encoding/hex"    // Package for encoding the hashes.
+	"fmt"             // Package for formatted I/O.
+	"html/template"   // Package for marking the integrity attribute as safe.
+	"io"              // Package for serving the embedded files.
+	"io/fs"           // Package for walking the embedded files.
+	"net/http"        // Package for building HTTP servers and clients.
+	"path"            // Package for manipulating slash-separated paths.
+	"strings"         // Package for manipulating strings.
+	"time"            // Package for the modification time of the assets.
+
+	"snippetbox.adcon.dev/internal/version" // Import the build information package.
+)
+
+// assetHashLength is the number of hex digits of the content hash put in fingerprinted names.
+const assetHashLength = 12
+
+// assetCacheControl is the Cache-Control header of fingerprinted assets. Their content never
+// changes under the same name, so browsers may keep them for a year without revalidating.
+const assetCacheControl = "public, max-age=31536000, immutable"
+
+// assetManifest maps the static assets to fingerprinted names that hold a hash of their content,
+// such as "css/main.css" to "css/main.3f2a9c1b7d4e.css". Linking to the fingerprinted name lets
+// browsers cache an asset for good, since a new version gets a new name.
+//
+// Every asset is served with a strong ETag of its content hash and a Last-Modified time of the
+// build, so that browsers revalidate the plain names with a 304 instead of downloading them again.
+// Stylesheets and scripts also get a Subresource Integrity hash, which browsers check them against
+// before applying them, in case a proxy or CDN in between changed them. Text assets are compressed
+// with Brotli and gzip once, when the manifest is built, rather than on every request.
+type assetManifest struct {
+	root      string                       // root is the directory of the assets in the file system, such as "static".
+	names     map[string]string            // names maps the path of each asset to its fingerprinted path.
+	assets    map[string]string            // assets maps fingerprinted paths back to the path of the asset.
+	etags     map[string]string            // etags maps the path of each asset to its ETag, the quoted hash of its content.
+	integrity map[string]string            // integrity maps the path of each stylesheet and script to its SRI hash, such as "sha384-...".
+	variants  map[string]map[string][]byte // variants maps the path of each compressible asset to its content per content coding.
+	modified  time.Time                    // modified is when the assets last changed, which is when the binary was built.
+}
+
+// newAssetManifest fingerprints the files under root in fsys. It's built when the application
+// starts, from the files embedded in the binary.
+func newAssetManifest(fsys fs.FS, root string) (*assetManifest, error) {
+	m := &assetManifest{
+		root:      root,
+		names:     map[string]string{},
+		assets:    map[string]string{},
+		etags:     map[string]string{},
+		integrity: map[string]string{},
+		variants:  map[string]map[string][]byte{},
+		modified:  buildTime(),
+	}
+
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		asset := strings.TrimPrefix(name, root+"/")
+		ext := path.Ext(asset)
+		fingerprinted := strings.TrimSuffix(asset, ext) + "." + hash[:assetHashLength] + ext
+
+		m.names[asset] = fingerprinted
+		m.assets[fingerprinted] = asset
+		m.etags[asset] = `"` + hash + `"`
+
+		if ext == ".css" || ext == ".js" {
+			sri := sha512.Sum384(content)
+			m.integrity[asset] = "sha384-" + base64.StdEncoding.EncodeToString(sri[:])
+		}
+
+		variants, err := compressAsset(asset, content)
+		if err != nil {
+			return fmt.Errorf("compressing %s: %w", name, err)
+		}
+		if len(variants) > 0 {
+			m.variants[asset] = variants
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// url returns the URL to link an asset with, such as "/static/css/main.3f2a9c1b7d4e.css" for
+// "css/main.css". Assets that aren't in the manifest are linked by their own name.
+func (m *assetManifest) url(asset string) string {
+	asset = strings.TrimPrefix(asset, "/")
+	if fingerprinted, ok := m.names[asset]; ok {
+		asset = fingerprinted
+	}
+	return "/" + m.root + "/" + asset
+}
+
+// integrityAttr returns the integrity attribute to link an asset with, such as
+// "integrity='sha384-...'" for "css/main.css", or nothing for assets without an SRI hash, so that
+// templates can put it after any link: <link href='{{asset "css/main.css"}}' {{integrity "css/main.css"}}>.
+func (m *assetManifest) integrityAttr(asset string) template.HTMLAttr {
+	sri, ok := m.integrity[strings.TrimPrefix(asset, "/")]
+	if !ok {
+		return ""
+	}
+
+	return template.HTMLAttr("integrity='" + sri + "'")
+}
+
+// handler serves the assets from fsys under "/<root>/". Fingerprinted names are served with a
+// far-future Cache-Control header, and the plain names as they are, for links that predate the
+// manifest and for the URLs in the stylesheet. Both answer conditional requests with a 304, and are
+// served precompressed to clients that accept it.
+func (m *assetManifest) handler(fsys fs.FS) http.Handler {
+	fileServer := http.FileServer(http.FS(fsys))
+	prefix := "/" + m.root + "/"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+
+		asset, ok := m.assets[name]
+		switch {
+		case ok:
+			w.Header().Set("Cache-Control", assetCacheControl)
+		case m.etags[name] != "":
+			asset = name
+		default:
+			// Directories and anything else that isn't an asset.
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		// Each encoding is a representation of its own, with an ETag of its own.
+		etag := m.etags[asset]
+		if variants := m.variants[asset]; len(variants) > 0 {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), variants); encoding != "" {
+				w.Header().Set("Content-Encoding", encoding)
+				w.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+encoding+`"`)
+				http.ServeContent(w, r, asset, m.modified, bytes.NewReader(variants[encoding]))
+				return
+			}
+		}
+
+		f, err := fsys.Open(m.root + "/" + asset)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+
+		content, ok := f.(io.ReadSeeker)
+		if !ok {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		// ServeContent answers If-None-Match with the ETag and If-Modified-Since with the time,
+		// and sets the Content-Type from the extension.
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, asset, m.modified, content)
+	})
+}
+
+// buildTime returns when the binary was built, or the time of its commit, which is when the
+// embedded assets last changed. It falls back to the current time for builds that don't record
+// either.
+func buildTime() time.Time {
+	if t, err := time.Parse(time.RFC3339, version.Get().Date); err == nil {
+		return t.UTC()
+	}
+
+	return time.Now().UTC().Truncate(time.Second)
+}
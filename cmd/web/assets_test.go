@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/ui"
+)
+
+func TestAssetManifest(t *testing.T) {
+
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"static/css/main.css": {Data: []byte("body { color: red; }")},
+		"static/js/main.js":   {Data: []byte("console.log(1);")},
+	}
+
+	m, err := newAssetManifest(fsys, "static")
+	assert.NilError(t, err)
+
+	css := m.url("css/main.css")
+	assert.Equal(t, regexp.MustCompile(`^/static/css/main\.[0-9a-f]{12}\.css$`).MatchString(css), true)
+	assert.Equal(t, m.url("/css/main.css"), css)
+	assert.Equal(t, m.url("img/missing.png"), "/static/img/missing.png")
+
+	// Stylesheets and scripts have an SRI hash, other assets don't.
+	assert.Equal(t, m.integrityAttr("css/main.css"), "integrity='sha384-BN8siYsJqlPeNsRFs2pYbTW0uiUBy9v6JVVKpHaS+KNqD0ZFotD5OFKMkI6/s6sb'")
+	assert.Equal(t, m.integrityAttr("/js/main.js") != "", true)
+	assert.Equal(t, m.integrityAttr("img/missing.png"), "")
+
+	// A change to the content changes the name.
+	fsys["static/css/main.css"] = &fstest.MapFile{Data: []byte("body { color: blue; }")}
+	changed, err := newAssetManifest(fsys, "static")
+	assert.NilError(t, err)
+	assert.Equal(t, changed.url("css/main.css") != css, true)
+}
+
+func TestStaticAssets(t *testing.T) {
+
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Pages link the stylesheet by its fingerprinted name.
+	_, _, body := ts.get(t, "/")
+	css := app.uiCache.manifest().url("css/main.css")
+	assert.StringContains(t, body, "href='"+css+"' "+string(app.uiCache.manifest().integrityAttr("css/main.css")))
+	assert.StringContains(t, body, string(app.uiCache.manifest().integrityAttr("js/main.js")))
+
+	code, header, body := ts.get(t, css)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), assetCacheControl)
+	assert.StringContains(t, header.Get("Content-Type"), "text/css")
+	assert.StringContains(t, body, "background-image")
+
+	// The plain name still works, but isn't cached for good.
+	code, header, _ = ts.get(t, "/static/css/main.css")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), "")
+
+	// Both names carry the hash of the content as a strong ETag, and revalidate with a 304. The test
+	// client asks for gzip, which gets an ETag of its own.
+	etag := header.Get("ETag")
+	assert.Equal(t, regexp.MustCompile(`^"[0-9a-f]{64}-gzip"$`).MatchString(etag), true)
+	lastModified := header.Get("Last-Modified")
+	assert.Equal(t, lastModified != "", true)
+
+	for _, name := range []string{css, "/static/css/main.css"} {
+		code, header, body = ts.request(t, http.MethodGet, name, http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, code, http.StatusNotModified)
+		assert.Equal(t, header.Get("ETag"), etag)
+		assert.Equal(t, body, "")
+	}
+
+	code, _, _ = ts.request(t, http.MethodGet, css, http.Header{"If-None-Match": {`"stale"`}})
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = ts.request(t, http.MethodGet, css, http.Header{"If-Modified-Since": {lastModified}})
+	assert.Equal(t, code, http.StatusNotModified)
+
+	// Names with a stale hash aren't served.
+	code, _, _ = ts.get(t, "/static/css/main.000000000000.css")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestCompressedAssets(t *testing.T) {
+
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	css, err := fs.ReadFile(ui.Files, "static/css/main.css")
+	assert.NilError(t, err)
+	etag := app.uiCache.manifest().etags["css/main.css"]
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"Brotli", "gzip, deflate, br", "br"},
+		{"Gzip", "gzip", "gzip"},
+		{"Brotli refused", "br;q=0, gzip;q=0.5", "gzip"},
+		{"Wildcard", "*", "br"},
+		{"Identity", "identity", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.request(t, http.MethodGet, app.uiCache.manifest().url("css/main.css"), http.Header{"Accept-Encoding": {tt.acceptEncoding}})
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, header.Get("Content-Encoding"), tt.wantEncoding)
+			assert.Equal(t, header.Get("Vary"), "Accept-Encoding")
+			assert.StringContains(t, header.Get("Content-Type"), "text/css")
+
+			var r io.Reader = strings.NewReader(body)
+			switch tt.wantEncoding {
+			case "br":
+				r = brotli.NewReader(r)
+				assert.Equal(t, header.Get("ETag"), strings.TrimSuffix(etag, `"`)+`-br"`)
+			case "gzip":
+				r, err = gzip.NewReader(r)
+				assert.NilError(t, err)
+			default:
+				assert.Equal(t, header.Get("ETag"), etag)
+			}
+
+			content, err := io.ReadAll(r)
+			assert.NilError(t, err)
+			assert.Equal(t, string(content), string(css))
+		})
+	}
+
+	// Images are compressed already, and served as they are.
+	_, header, _ := ts.request(t, http.MethodGet, app.uiCache.manifest().url("img/logo.png"), http.Header{"Accept-Encoding": {"br, gzip"}})
+	assert.Equal(t, header.Get("Content-Encoding"), "")
+	assert.Equal(t, header.Get("Vary"), "")
+}
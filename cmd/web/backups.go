@@ -0,0 +1,229 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"       // Package for bounding how long a backup may take.
+	"crypto/sha256" // Package for hashing the backup for the upload.
+	"encoding/hex"  // Package for encoding the hash.
+	"errors"        // Package for creating error messages.
+	"expvar"        // Package for exposing counters to monitoring.
+	"fmt"           // Package for formatted I/O.
+	"io"            // Package for writing the backup to a file and the hash at once.
+	"net/url"       // Package for validating the endpoint.
+	"os"            // Package for the temporary file the backup is written to.
+	"sort"          // Package for ordering backups by age.
+	"strings"       // Package for matching the names of backups.
+	"sync"          // Package for guarding the status of the backups.
+	"time"          // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/s3" // Import the S3 client package.
+)
+
+// backupSuffix ends the names of the off-site backups, which start with -backup-s3-prefix and the
+// time they were taken, as in "snippetbox/snippetbox-20300601T120000Z.backup.gz".
+const backupSuffix = ".backup.gz"
+
+// backupTimeout bounds how long taking and uploading a backup may take.
+const backupTimeout = time.Hour
+
+// offsiteBackupVars counts the off-site backups for the admin metrics page.
+var offsiteBackupVars = expvar.NewMap("offsite_backups")
+
+// backupStore is the bucket off-site backups are uploaded to. It's an *s3.Client outside tests.
+type backupStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, hash string) error
+	List(ctx context.Context, prefix string) ([]s3.Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// offsiteBackups takes backups of the database in the format of snippetboxctl backup on a
+// schedule, uploads them to an S3-compatible bucket and deletes those past the retention period.
+type offsiteBackups struct {
+	store     backupStore
+	dump      func(ctx context.Context, w io.Writer) error // dump writes a backup of the database to w.
+	prefix    string
+	interval  time.Duration
+	retention time.Duration
+
+	mu          sync.Mutex
+	lastSuccess time.Time // lastSuccess is when the newest backup in the bucket was taken.
+	lastError   string    // lastError is the error of the last attempt, or empty if it succeeded.
+}
+
+// backupHealth is the state of the off-site backups reported by the health endpoint.
+type backupHealth struct {
+	Status      string     `json:"status"` // Status is ok, failing or pending before the first backup.
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// health returns the state of the backups.
+func (b *offsiteBackups) health() *backupHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := &backupHealth{Status: "ok", Error: b.lastError}
+	if !b.lastSuccess.IsZero() {
+		last := b.lastSuccess
+		h.LastSuccess = &last
+	}
+
+	switch {
+	case b.lastError != "":
+		h.Status = "failing"
+	case h.LastSuccess == nil:
+		h.Status = "pending"
+	}
+
+	return h
+}
+
+// run is the background job of the backups. It runs every hour, and uploads a backup when the
+// newest one in the bucket is older than the interval, so that restarting the server doesn't
+// take an extra one. Backups past the retention period are deleted afterwards, except the newest.
+func (b *offsiteBackups) run(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+
+	err := b.backUp(ctx, now)
+
+	b.mu.Lock()
+	if err != nil {
+		b.lastError = err.Error()
+		offsiteBackupVars.Add("failed", 1)
+	} else {
+		b.lastError = ""
+	}
+	b.mu.Unlock()
+
+	return err
+}
+
+// backUp uploads a backup if one is due and prunes the old ones.
+func (b *offsiteBackups) backUp(ctx context.Context, now time.Time) error {
+	objects, err := b.store.List(ctx, b.prefix)
+	if err != nil {
+		return err
+	}
+	backups := backupObjects(objects, b.prefix)
+
+	if len(backups) == 0 || now.Sub(backups[len(backups)-1].LastModified) >= b.interval {
+		backup, err := b.upload(ctx, now)
+		if err != nil {
+			return err
+		}
+		backups = append(backups, backup)
+	}
+
+	last := backups[len(backups)-1].LastModified
+	b.mu.Lock()
+	b.lastSuccess = last
+	b.mu.Unlock()
+	offsiteBackupVars.Set("last_success_unix", expvarInt(last.Unix()))
+
+	for _, o := range expiredBackups(backups, now, b.retention) {
+		if err := b.store.Delete(ctx, o.Key); err != nil {
+			return fmt.Errorf("deleting %s: %w", o.Key, err)
+		}
+		offsiteBackupVars.Add("pruned", 1)
+	}
+
+	return nil
+}
+
+// upload writes a backup to a temporary file, since the upload needs its size and hash up front,
+// and uploads it.
+func (b *offsiteBackups) upload(ctx context.Context, now time.Time) (s3.Object, error) {
+	f, err := os.CreateTemp("", "snippetbox-*"+backupSuffix)
+	if err != nil {
+		return s3.Object{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hash := sha256.New()
+	if err := b.dump(ctx, io.MultiWriter(f, hash)); err != nil {
+		return s3.Object{}, fmt.Errorf("taking backup: %w", err)
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return s3.Object{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return s3.Object{}, err
+	}
+
+	o := s3.Object{Key: b.prefix + "snippetbox-" + now.UTC().Format("20060102T150405Z") + backupSuffix, Size: size, LastModified: now}
+	if err := b.store.Put(ctx, o.Key, f, size, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return s3.Object{}, fmt.Errorf("uploading %s: %w", o.Key, err)
+	}
+
+	offsiteBackupVars.Add("uploaded", 1)
+	offsiteBackupVars.Set("last_bytes", expvarInt(size))
+
+	return o, nil
+}
+
+// backupObjects returns the backups among the objects under prefix, oldest first. Other objects
+// under the prefix are left alone.
+func backupObjects(objects []s3.Object, prefix string) []s3.Object {
+	var backups []s3.Object
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, prefix)
+		if strings.HasPrefix(name, "snippetbox-") && strings.HasSuffix(name, backupSuffix) && !strings.Contains(name, "/") {
+			backups = append(backups, o)
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].LastModified.Before(backups[j].LastModified) })
+
+	return backups
+}
+
+// expiredBackups returns the backups, oldest first, that are older than retention, except the
+// newest one, which is always kept.
+func expiredBackups(backups []s3.Object, now time.Time, retention time.Duration) []s3.Object {
+	var expired []s3.Object
+	for i, o := range backups {
+		if i < len(backups)-1 && now.Sub(o.LastModified) > retention {
+			expired = append(expired, o)
+		}
+	}
+
+	return expired
+}
+
+// expvarInt returns n as an expvar value.
+func expvarInt(n int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(n)
+	return v
+}
+
+// checkBackups validates the settings of the off-site backups, which are enabled by
+// -backup-s3-bucket.
+func checkBackups(config configuration) error {
+	if config.BackupS3Bucket == "" {
+		return nil
+	}
+
+	if config.BackupS3AccessKey == "" || config.BackupS3SecretKey == "" {
+		return errors.New("-backup-s3-bucket needs -backup-s3-access-key and -backup-s3-secret-key")
+	}
+	if u, err := url.Parse(config.BackupS3Endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("-backup-s3-endpoint %q isn't an http or https URL", config.BackupS3Endpoint)
+	}
+	if config.BackupS3Region == "" {
+		return errors.New("-backup-s3-region is empty")
+	}
+	if config.BackupInterval < time.Hour {
+		return errors.New("-backup-interval must be at least an hour")
+	}
+	if config.BackupRetention < config.BackupInterval {
+		return errors.New("-backup-retention must be at least -backup-interval")
+	}
+
+	return nil
+}
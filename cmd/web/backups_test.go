@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/s3"
+)
+
+// memStore is a backupStore that keeps objects in memory, timestamped with the time they were put
+// according to now.
+type memStore struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	objects map[string]s3.Object
+	content map[string]string
+}
+
+func newMemStore(now func() time.Time) *memStore {
+	return &memStore{now: now, objects: map[string]s3.Object{}, content: map[string]string{}}
+}
+
+func (m *memStore) Put(_ context.Context, key string, body io.Reader, size int64, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) != size {
+		return errors.New("size mismatch")
+	}
+
+	m.objects[key] = s3.Object{Key: key, Size: size, LastModified: m.now()}
+	m.content[key] = string(b)
+
+	return nil
+}
+
+func (m *memStore) List(_ context.Context, _ string) ([]s3.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var objects []s3.Object
+	for _, o := range m.objects {
+		objects = append(objects, o)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	return objects, nil
+}
+
+func (m *memStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+
+	return nil
+}
+
+func (m *memStore) keys() []string {
+	objects, _ := m.List(context.Background(), "")
+	keys := make([]string, len(objects))
+	for i, o := range objects {
+		keys[i] = o.Key
+	}
+	return keys
+}
+
+func TestOffsiteBackups(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemStore(func() time.Time { return now })
+
+	var dumpErr error
+	b := &offsiteBackups{
+		store: store,
+		dump: func(_ context.Context, w io.Writer) error {
+			if dumpErr != nil {
+				return dumpErr
+			}
+			_, err := io.WriteString(w, "backup")
+			return err
+		},
+		prefix:    "snippetbox/",
+		interval:  24 * time.Hour,
+		retention: 48 * time.Hour,
+	}
+
+	assert.Equal(t, b.health().Status, "pending")
+
+	// The first run uploads a backup, and runs before the interval is up don't.
+	assert.NilError(t, b.run(now))
+	assert.Equal(t, len(store.keys()), 1)
+	assert.Equal(t, store.content["snippetbox/snippetbox-20300601T120000Z.backup.gz"], "backup")
+	assert.Equal(t, b.health().Status, "ok")
+
+	now = now.Add(time.Hour)
+	assert.NilError(t, b.run(now))
+	assert.Equal(t, len(store.keys()), 1)
+
+	// Backups past the retention period are deleted, and so are no other objects.
+	store.objects["snippetbox/notes.txt"] = s3.Object{Key: "snippetbox/notes.txt", LastModified: now.Add(-365 * 24 * time.Hour)}
+	for i := 0; i < 3; i++ {
+		now = now.Add(24 * time.Hour)
+		assert.NilError(t, b.run(now))
+	}
+	assert.Equal(t, len(store.keys()), 4)
+	assert.Equal(t, store.keys()[0], "snippetbox/notes.txt")
+	assert.Equal(t, store.keys()[1], "snippetbox/snippetbox-20300602T130000Z.backup.gz")
+
+	// Failures are reported until a backup succeeds again, and the newest backup is kept however
+	// old it gets.
+	dumpErr = errors.New("database unavailable")
+	now = now.Add(30 * 24 * time.Hour)
+	assert.Equal(t, b.run(now) != nil, true)
+	assert.Equal(t, len(store.keys()), 4)
+
+	health := b.health()
+	assert.Equal(t, health.Status, "failing")
+	assert.Equal(t, health.Error, "taking backup: database unavailable")
+	assert.Equal(t, health.LastSuccess.Equal(time.Date(2030, 6, 4, 13, 0, 0, 0, time.UTC)), true)
+
+	dumpErr = nil
+	assert.NilError(t, b.run(now))
+	assert.Equal(t, len(store.keys()), 2)
+	assert.Equal(t, b.health().Status, "ok")
+}
+
+func TestHealthzBackups(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.backups = &offsiteBackups{lastError: "s3: AccessDenied: Access Denied"}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/healthz")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"backup":{"status":"failing","error":"s3: AccessDenied: Access Denied"}`)
+}
+
+func TestCheckBackups(t *testing.T) {
+	t.Parallel()
+
+	valid := configuration{
+		BackupS3Endpoint:  "https://s3.amazonaws.com",
+		BackupS3Region:    "us-east-1",
+		BackupS3Bucket:    "backups",
+		BackupS3AccessKey: "key",
+		BackupS3SecretKey: "secret",
+		BackupInterval:    24 * time.Hour,
+		BackupRetention:   30 * 24 * time.Hour,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *configuration)
+		wantErr bool
+	}{
+		{"Disabled", func(c *configuration) { *c = configuration{} }, false},
+		{"Valid", func(c *configuration) {}, false},
+		{"No secret", func(c *configuration) { c.BackupS3SecretKey = "" }, true},
+		{"Invalid endpoint", func(c *configuration) { c.BackupS3Endpoint = "s3.amazonaws.com" }, true},
+		{"Short interval", func(c *configuration) { c.BackupInterval = time.Minute }, true},
+		{"Short retention", func(c *configuration) { c.BackupRetention = time.Hour }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+
+			err := checkBackups(config)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"crypto/subtle" // Package for comparing the webhook secret in constant time.
+	"encoding/json" // Package for decoding the webhook body.
+	"errors"        // Package for creating error messages.
+	"net/http"      // Package for building HTTP servers and clients.
+	"net/mail"      // Package for parsing email addresses.
+	"strings"       // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/mailer" // Import the email package.
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// maxBounceWebhookBody is the largest webhook body accepted, in bytes.
+const maxBounceWebhookBody = 1 << 20
+
+// bounceEvent is a delivery failure an email provider reports to the bounce webhook.
+type bounceEvent struct {
+	Email  string `json:"email"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+// bounceWebhookPost serves the "/webhooks/bounce" URL. Email providers report the messages they
+// couldn't deliver there, as a JSON object or an array of objects with the "email" address, the
+// "type" of bounce (hard, soft or complaint) and an optional "detail". Requests need the
+// -bounce-webhook-secret as a bearer token.
+func (app *application) bounceWebhookPost(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(app.config.BounceWebhookSecret)) != 1 {
+		app.writeJSON(w, r, http.StatusUnauthorized, map[string]string{"error": "a valid bearer token is required"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBounceWebhookBody)
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		app.writeJSON(w, r, http.StatusBadRequest, map[string]string{"error": "the body must be a JSON object or array of objects"})
+		return
+	}
+
+	// Providers batch events, so both a single event and an array are accepted.
+	var events []bounceEvent
+	if err := json.Unmarshal(raw, &events); err != nil {
+		var event bounceEvent
+		if err := json.Unmarshal(raw, &event); err != nil {
+			app.writeJSON(w, r, http.StatusBadRequest, map[string]string{"error": "the body must be a JSON object or array of objects"})
+			return
+		}
+		events = []bounceEvent{event}
+	}
+
+	for _, e := range events {
+		if err := validBounceEvent(e); err != nil {
+			app.writeJSON(w, r, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	for _, e := range events {
+		if err := app.suppressions.RecordBounce(e.Email, mailer.Bounce{Kind: e.Type, Detail: e.Detail}); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		app.infoLog.Printf("Email to %s bounced (%s): %s", e.Email, e.Type, e.Detail)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validBounceEvent checks the address and type of a bounce reported to the webhook.
+func validBounceEvent(e bounceEvent) error {
+	if _, err := mail.ParseAddress(e.Email); err != nil {
+		return errors.New(`"email" must be an email address`)
+	}
+
+	switch e.Type {
+	case mailer.BounceHard, mailer.BounceSoft, mailer.BounceComplaint:
+		return nil
+	default:
+		return errors.New(`"type" must be hard, soft or complaint`)
+	}
+}
+
+// accountEmail serves the "/account/email" URL, which tells users whether email to their address
+// is delivered, and lets them have it sent again after it bounced.
+func (app *application) accountEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	suppression, err := app.suppressions.Get(user.Email)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Suppression = suppression
+
+	app.render(w, r, http.StatusOK, "email.html", data)
+}
+
+// accountEmailReactivatePost forgets the bounces of the current user's address, so that email is
+// sent to it again.
+func (app *application) accountEmailReactivatePost(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := app.suppressions.Reactivate(user.Email); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.infoLog.Printf("Email to %s reactivated by user %d", user.Email, user.ID)
+	app.sessionManager.Put(r.Context(), "flash", "Email will be sent to your address again.")
+
+	http.Redirect(w, r, "/account/email", http.StatusSeeOther)
+}
@@ -0,0 +1,286 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"bytes"    // Package for putting the nonce of the request in the cached page.
+	"errors"   // Package for creating error messages.
+	"expvar"   // Package for exposing counters to monitoring.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for formatting the Retry-After header.
+	"strings"  // Package for building route patterns.
+	"sync"     // Package for guarding the state of the breakers.
+	"time"     // Package for measuring and displaying time.
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// breakerVars counts, by route, how often a circuit breaker opened and how many requests it turned
+// away, for the admin metrics page.
+var breakerVars = expvar.NewMap("circuit_breakers")
+
+// breakerNonce stands in for the Content-Security-Policy nonce in the cached error page, and is
+// replaced with the nonce of each request the page is sent to.
+const breakerNonce = "circuit-breaker-nonce"
+
+// routeBreaker is the state of the circuit breaker of a single route.
+type routeBreaker struct {
+	windowStart time.Time // windowStart is when the current window of requests started.
+	requests    int       // requests is the number of requests that finished in the window.
+	failures    int       // failures is the number of those that failed.
+	openUntil   time.Time // openUntil is when an open breaker lets a trial request through, or zero if it's closed.
+	probing     bool      // probing reports whether a trial request is running.
+}
+
+// circuitBreaker keeps a route whose requests keep failing from hammering the database during an
+// incident. It counts the requests of each route that end in a server error or panic in a window
+// of time, and once at least -breaker-min-requests finished and -breaker-threshold of them failed,
+// the breaker opens: the route is answered with a cached error page for -breaker-cooldown, without
+// running its handler. After that, a single trial request goes through; if it succeeds the breaker
+// closes, and otherwise it stays open for another cooldown.
+type circuitBreaker struct {
+	app         *application
+	route       func(r *http.Request) string // route returns the route pattern of a request, or "" if it isn't routed.
+	threshold   float64
+	minRequests int
+	window      time.Duration
+	cooldown    time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*routeBreaker
+	page   []byte // page is the error page sent while a breaker is open, rendered when one opens.
+}
+
+// newCircuitBreaker returns the circuit breaker of the configuration, with routes identified by
+// the patterns they're registered under in router.
+func (app *application) newCircuitBreaker(router *httprouter.Router) *circuitBreaker {
+	return &circuitBreaker{
+		app:         app,
+		route:       func(r *http.Request) string { return routePattern(router, r) },
+		threshold:   app.config.BreakerThreshold,
+		minRequests: app.config.BreakerMinRequests,
+		window:      app.config.BreakerWindow,
+		cooldown:    app.config.BreakerCooldown,
+		routes:      map[string]*routeBreaker{},
+	}
+}
+
+// routePattern returns the method and the pattern of the route a request matches, such as
+// "GET /snippet/view/:id", so that requests for different snippets share a breaker. It returns an
+// empty string for requests that don't match a route.
+func routePattern(router *httprouter.Router, r *http.Request) string {
+	handle, params, _ := router.Lookup(r.Method, r.URL.Path)
+	if handle == nil {
+		return ""
+	}
+
+	// The parameters are in the order they appear in the path, so each value is replaced by the
+	// name of its parameter after the end of the previous one. A catch-all value includes its
+	// leading slash.
+	path := r.URL.Path
+	var pattern strings.Builder
+	for _, p := range params {
+		i := strings.Index(path, p.Value)
+		if i < 0 {
+			return ""
+		}
+		pattern.WriteString(path[:i])
+		if strings.HasPrefix(p.Value, "/") {
+			pattern.WriteString("/*" + p.Key)
+		} else {
+			pattern.WriteString(":" + p.Key)
+		}
+		path = path[i+len(p.Value):]
+	}
+	pattern.WriteString(path)
+
+	return r.Method + " " + pattern.String()
+}
+
+// allow reports whether a request for route may run its handler, and whether it's the trial
+// request of an open breaker.
+func (cb *circuitBreaker) allow(route string, now time.Time) (ok, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b := cb.routes[route]
+	if b == nil || b.openUntil.IsZero() {
+		return true, false
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false, false
+	}
+
+	b.probing = true
+	return true, true
+}
+
+// record counts a finished request for route, and opens or closes its breaker accordingly.
+func (cb *circuitBreaker) record(route string, failed, trial bool, now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b := cb.routes[route]
+	if b == nil {
+		b = &routeBreaker{windowStart: now}
+		cb.routes[route] = b
+	}
+
+	if trial {
+		b.probing = false
+		if failed {
+			b.openUntil = now.Add(cb.cooldown)
+			breakerVars.Add(route+" opened", 1)
+			cb.app.errorLog.Printf("Circuit breaker for %s stays open: the trial request failed; retrying in %s", route, cb.cooldown)
+			return
+		}
+		*b = routeBreaker{windowStart: now}
+		cb.app.infoLog.Printf("Circuit breaker for %s closed: the trial request succeeded", route)
+		return
+	}
+
+	// Requests that started before the breaker opened don't count towards the next window.
+	if !b.openUntil.IsZero() {
+		return
+	}
+
+	if now.Sub(b.windowStart) >= cb.window {
+		*b = routeBreaker{windowStart: now}
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+
+	if b.requests >= cb.minRequests && float64(b.failures) >= cb.threshold*float64(b.requests) {
+		b.openUntil = now.Add(cb.cooldown)
+		cb.page = nil
+		breakerVars.Add(route+" opened", 1)
+		cb.app.errorLog.Printf("Circuit breaker for %s opened: %d of %d requests failed in %s; answering with an error page for %s",
+			route, b.failures, b.requests, now.Sub(b.windowStart).Round(time.Second), cb.cooldown)
+	}
+}
+
+// errorPage returns the page sent while a breaker is open, rendering it the first time it's needed
+// after a breaker opens so that a reload of the templates is picked up.
+func (cb *circuitBreaker) errorPage() ([]byte, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.page != nil {
+		return cb.page, nil
+	}
+
+	data := &templateData{
+		CurrentYear: cb.app.clock.Now().Year(),
+		CSPNonce:    breakerNonce,
+		ErrorStatus: http.StatusServiceUnavailable,
+		ErrorDetail: "This page is having trouble right now, so we've paused it for a moment to let it recover. Please try again shortly.",
+	}
+	buf, err := cb.app.execute("error.html", data)
+	if err != nil {
+		return nil, err
+	}
+	defer cb.app.buffers.put("error.html", buf)
+
+	cb.page = bytes.Clone(buf.Bytes())
+	return cb.page, nil
+}
+
+// reject answers a request for a route whose breaker is open, without running its handler.
+func (cb *circuitBreaker) reject(w http.ResponseWriter, r *http.Request, route string) {
+	breakerVars.Add(route+" rejected", 1)
+	w.Header().Set("Retry-After", strconv.Itoa(int(cb.cooldown.Round(time.Second)/time.Second)))
+
+	detail := "This page is having trouble right now. Please try again shortly."
+	if isAPIRequest(r) {
+		cb.app.writeProblem(w, r, http.StatusServiceUnavailable, detail, r.URL.Path)
+		return
+	}
+
+	page, err := cb.errorPage()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable)+"\n"+detail, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write(bytes.ReplaceAll(page, []byte(breakerNonce), []byte(cspNonce(r))))
+}
+
+// breakRoutes is a middleware function that runs the requests of each route through its circuit
+// breaker. A request fails if it panics or ends in a server error other than 503 Service
+// Unavailable, which the application answers with on purpose, such as in read-only mode.
+func (cb *circuitBreaker) breakRoutes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := cb.route(r)
+		if route == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, trial := cb.allow(route, cb.app.clock.Now())
+		if !ok {
+			cb.reject(w, r, route)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		// A panic is counted as a failure before it's passed on to recoverPanic.
+		defer func() {
+			err := recover()
+			failed := err != nil || sw.status >= http.StatusInternalServerError && sw.status != http.StatusServiceUnavailable
+			cb.record(route, failed, trial, cb.app.clock.Now())
+			if err != nil {
+				panic(err)
+			}
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status and writes it.
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader && status >= http.StatusOK {
+		sw.wroteHeader = true
+		sw.status = status
+	}
+
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// checkBreaker validates the settings of the circuit breakers, which -breaker-threshold 0 disables.
+func checkBreaker(config configuration) error {
+	if config.BreakerThreshold == 0 {
+		return nil
+	}
+
+	if config.BreakerThreshold < 0 || config.BreakerThreshold > 1 {
+		return errors.New("-breaker-threshold must be between 0 and 1")
+	}
+	if config.BreakerMinRequests < 1 {
+		return errors.New("-breaker-min-requests must be at least 1")
+	}
+	if config.BreakerWindow <= 0 {
+		return errors.New("-breaker-window must be positive")
+	}
+	if config.BreakerCooldown < time.Second {
+		return errors.New("-breaker-cooldown must be at least a second")
+	}
+
+	return nil
+}
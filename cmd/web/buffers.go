@@ -0,0 +1,60 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"bytes"       // Package for manipulating byte slices.
+	"sync"        // Package for synchronization primitives.
+	"sync/atomic" // Package for atomic counters.
+)
+
+// maxPooledBuffer is the capacity above which a buffer isn't returned to the pool, so that one
+// unusually large page doesn't keep its memory alive for every later request.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool recycles the buffers that pages are rendered into. It remembers a running average of
+// each page's rendered size, so that a buffer can be grown once up front instead of doubling
+// repeatedly while the template executes. The zero value is ready to use.
+type bufferPool struct {
+	pool  sync.Pool
+	sizes sync.Map // sizes maps a page name to an *atomic.Int64 holding its average size in bytes.
+}
+
+// get returns an empty buffer with room for the typical output of page.
+func (bp *bufferPool) get(page string) *bytes.Buffer {
+	buf, ok := bp.pool.Get().(*bytes.Buffer)
+	if !ok {
+		buf = new(bytes.Buffer)
+	}
+
+	if size, ok := bp.sizes.Load(page); ok {
+		buf.Grow(int(size.(*atomic.Int64).Load()))
+	}
+
+	return buf
+}
+
+// put records the size of the output of page and returns the buffer to the pool. The buffer must
+// not be used afterwards.
+func (bp *bufferPool) put(page string, buf *bytes.Buffer) {
+	n := int64(buf.Len())
+
+	v, _ := bp.sizes.LoadOrStore(page, new(atomic.Int64))
+	size := v.(*atomic.Int64)
+
+	// Move the average an eighth of the way towards the latest size. Concurrent updates may lose
+	// one another, which only makes the estimate a little less precise.
+	old := size.Load()
+	if old == 0 {
+		size.Store(n)
+	} else {
+		size.Store(old + (n-old)/8)
+	}
+
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bp.pool.Put(buf)
+}
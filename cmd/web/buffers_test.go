@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestBufferPool(t *testing.T) {
+
+	t.Parallel()
+
+	var bp bufferPool
+
+	buf := bp.get("home.html")
+	assert.Equal(t, buf.Len(), 0)
+
+	buf.WriteString(strings.Repeat("x", 10000))
+	bp.put("home.html", buf)
+
+	// The next buffer for the page starts empty, with room for the size seen before.
+	buf = bp.get("home.html")
+	assert.Equal(t, buf.Len(), 0)
+	assert.Equal(t, buf.Cap() >= 10000, true)
+	bp.put("home.html", buf)
+
+	// Oversized buffers aren't kept.
+	big := bytes.NewBuffer(make([]byte, 0, 2*maxPooledBuffer))
+	bp.put("huge.html", big)
+	assert.Equal(t, big.Cap(), 2*maxPooledBuffer)
+}
+
+func BenchmarkRender(b *testing.B) {
+
+	app := newTestApplication(b)
+	data := &templateData{CurrentYear: 2024}
+
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			app.render(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "home.html", data)
+		}
+	})
+
+	// The way render worked before buffers were pooled, for comparison.
+	b.Run("Fresh buffer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := new(bytes.Buffer)
+			if err := app.uiCache.templates["home.html"].ExecuteTemplate(buf, "base", data); err != nil {
+				b.Fatal(err)
+			}
+			buf.WriteTo(httptest.NewRecorder())
+		}
+	})
+}
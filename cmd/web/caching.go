@@ -0,0 +1,125 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"bytes"         // Package for manipulating byte slices.
+	"crypto/sha256" // Package for hashing rendered pages into entity tags.
+	"encoding/hex"  // Package for encoding the hashes.
+	"net/http"      // Package for building HTTP servers and clients.
+	"strings"       // Package for manipulating strings.
+	"time"          // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// etagLength is the number of hex digits of the page hash kept in entity tags.
+const etagLength = 32
+
+// The Cache-Control values of snippet pages. Public pages may be stored by shared caches, but
+// must be revalidated, so that an edit or a deletion shows up on the next request. Pages that
+// depend on who's looking are kept out of shared caches, and held and private snippets aren't
+// stored at all.
+const (
+	cachePublic  = "public, no-cache"
+	cachePrivate = "private, no-cache"
+	cacheNoStore = "no-store"
+)
+
+// snippetCacheControl returns the Cache-Control value of a snippet's page. Pages fetched with a
+// share token aren't stored either, since the token in the URL is what grants access.
+func (app *application) snippetCacheControl(r *http.Request, snippet *models.Snippet) string {
+	switch {
+	case snippet.Held || snippet.Private || r.URL.Query().Has("token"):
+		return cacheNoStore
+	case app.isAuthenticated(r):
+		return cachePrivate
+	default:
+		return cachePublic
+	}
+}
+
+// lastModified returns when a snippet was last written.
+func lastModified(snippet *models.Snippet) time.Time {
+	if snippet.Edited() {
+		return snippet.Updated
+	}
+	return snippet.Created
+}
+
+// renderCacheable renders a page like render with a 200 status, along with an ETag, the hash of
+// the page, and a Last-Modified header. If the request's conditional headers show the client
+// already has the page, it sends a 304 status without a body instead.
+func (app *application) renderCacheable(w http.ResponseWriter, r *http.Request, page string, data *templateData, modified time.Time) {
+	buf, err := app.execute(page, data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer app.buffers.put(page, buf)
+
+	// The nonce of the Content-Security-Policy changes with every request, so it's left out of the
+	// hash for the tag to stay the same while the page does.
+	body := buf.Bytes()
+	if data.CSPNonce != "" {
+		body = bytes.ReplaceAll(body, []byte(data.CSPNonce), nil)
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:])[:etagLength] + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	// The page differs for logged-in users, so caches must tell visitors apart by their cookie.
+	h.Add("Vary", "Cookie")
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modified) {
+		// The client keeps the policy it got with its copy of the page, which holds the nonce of
+		// that copy, rather than taking this response's.
+		h.Del("Content-Security-Policy")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// notModified reports whether a GET or HEAD request's conditional headers match a page with the
+// given entity tag and modification time. If-None-Match takes precedence over If-Modified-Since,
+// which is only compared to the second, like HTTP dates.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether an If-None-Match list names etag. Entity tags are compared weakly,
+// as If-None-Match requires, so a W/ prefix is ignored.
+func etagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
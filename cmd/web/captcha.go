@@ -0,0 +1,112 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"slices"   // Package for manipulating slices.
+	"strings"  // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/captcha"   // Import the human verification package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// showCaptcha adds the human verification challenge to the data of a page and extends the
+// Content-Security-Policy of the response so that the provider's script and frames can load. It
+// does nothing when verification is disabled.
+func (app *application) showCaptcha(w http.ResponseWriter, r *http.Request, data *templateData) {
+	if app.captcha == nil {
+		return
+	}
+
+	data.Captcha = app.captcha.Widget()
+	if policy := w.Header().Get("Content-Security-Policy"); policy != "" {
+		w.Header().Set("Content-Security-Policy", captchaPolicy(policy, data.Captcha))
+	}
+}
+
+// captchaPolicy returns the Content-Security-Policy for a page showing the widget: policy, with the
+// provider's origins allowed to run scripts, be framed and connected to and style the widget.
+func captchaPolicy(policy string, widget *captcha.Widget) string {
+	return extendPolicy(policy, widget.Origins, "script-src", "frame-src", "connect-src", "style-src")
+}
+
+// extendPolicy adds origins to the given directives of a Content-Security-Policy. A directive the
+// policy doesn't have starts from its default-src, which applies in its place; without a default-src
+// the directive allows every origin already, and isn't added.
+func extendPolicy(policy string, origins []string, directives ...string) string {
+	var (
+		names  []string
+		values = map[string][]string{}
+	)
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+			values[name] = fields[1:]
+		}
+	}
+
+	for _, name := range directives {
+		sources, ok := values[name]
+		if !ok {
+			sources, ok = values["default-src"]
+			if !ok {
+				continue
+			}
+			names = append(names, name)
+		}
+		// 'none' can't be combined with other sources.
+		if len(sources) == 1 && sources[0] == "'none'" {
+			sources = nil
+		}
+		values[name] = append(slices.Clip(sources), origins...)
+	}
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = strings.Join(append([]string{name}, values[name]...), " ")
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// checkCaptcha verifies the challenge response submitted with a form. A failed challenge is
+// added to the form as a non-field error; the returned error is for problems reaching the
+// provider. It does nothing when verification is disabled.
+func (app *application) checkCaptcha(r *http.Request, form *validator.Validator) error {
+	if app.captcha == nil {
+		return nil
+	}
+
+	response := r.PostForm.Get(app.captcha.Widget().ResponseField)
+
+	// The provider doesn't need the client's address, so it isn't told it in privacy mode.
+	remoteIP := clientIP(r)
+	if app.config.AnonymizeIPs {
+		remoteIP = ""
+	}
+
+	err := app.captcha.Verify(r.Context(), response, remoteIP)
+	if errors.Is(err, captcha.ErrFailed) {
+		form.AddNonFieldError("Please complete the check that you're not a robot")
+		return nil
+	}
+
+	return err
+}
+
+// loginNeedsCaptcha reports whether logging in requires human verification, because there have
+// been too many recent failed attempts from the client or, when email isn't empty, for the account.
+func (app *application) loginNeedsCaptcha(r *http.Request, email string) bool {
+	if app.captcha == nil {
+		return false
+	}
+
+	return app.loginFailures.count(app.clock.Now(), loginFailureKeys(r, email)...) >= app.config.CaptchaLoginFailures
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/captcha"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// stubVerifier is a captcha.Verifier that accepts the response "human".
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(_ context.Context, response, _ string) error {
+	if response != "human" {
+		return captcha.ErrFailed
+	}
+	return nil
+}
+
+func (stubVerifier) Widget() *captcha.Widget {
+	return &captcha.Widget{
+		ScriptURL:     "https://captcha.example.com/api.js",
+		Class:         "stub-captcha",
+		SiteKey:       "site-key",
+		ResponseField: "stub-response",
+		Origins:       []string{"https://captcha.example.com"},
+	}
+}
+
+func TestSignupCaptcha(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.captcha = stubVerifier{}
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/user/signup")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<div class='stub-captcha' data-sitekey='site-key'></div>")
+	nonce := regexp.MustCompile(`nonce='([^']+)'`).FindStringSubmatch(body)[1]
+	assert.StringContains(t, header.Get("Content-Security-Policy"), "script-src 'self' 'nonce-"+nonce+"' https://captcha.example.com")
+
+	form := url.Values{
+		"name":             {"Bob"},
+		"username":         {"bob"},
+		"email":            {"bob@example.com"},
+		"password":         {"validPa$$word"},
+		"confirm_password": {"validPa$$word"},
+	}
+
+	code, _, body = ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "not a robot")
+
+	form.Set("stub-response", "human")
+	code, _, _ = ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestLoginCaptcha(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.captcha = stubVerifier{}
+	app.config.CaptchaLoginFailures = 2
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	wrong := url.Values{"email": {"alice@example.com"}, "password": {"wrong"}}
+	right := url.Values{"email": {"alice@example.com"}, "password": {"pa$$word"}}
+
+	// The first failure doesn't ask for verification yet.
+	_, _, body := ts.postForm(t, "/user/login", wrong)
+	assert.Equal(t, containsWidget(body), false)
+
+	// The second does, and from then on the right password alone isn't enough.
+	code, _, body := ts.postForm(t, "/user/login", wrong)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.Equal(t, containsWidget(body), true)
+
+	_, _, body = ts.get(t, "/user/login")
+	assert.Equal(t, containsWidget(body), true)
+
+	code, _, _ = ts.postForm(t, "/user/login", right)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	right.Set("stub-response", "human")
+	code, _, _ = ts.postForm(t, "/user/login", right)
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestLoginCaptchaWithoutCookies(t *testing.T) {
+	t.Parallel()
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	app := newTestApplication(t)
+	app.captcha = stubVerifier{}
+	app.clock = frozen
+	app.config.CaptchaLoginFailures = 2
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// forget drops the client's cookies, and with them its session, like a bot starting over.
+	forget := func() {
+		jar, err := cookiejar.New(nil)
+		assert.NilError(t, err)
+		ts.Client().Jar = jar
+	}
+
+	wrong := url.Values{"email": {"Alice@Example.com"}, "password": {"wrong"}}
+	right := url.Values{"email": {"alice@example.com"}, "password": {"pa$$word"}}
+
+	forget()
+	_, _, body := ts.postForm(t, "/user/login", wrong)
+	assert.Equal(t, containsWidget(body), false)
+
+	// Failures are still counted for the client and the account without a session.
+	forget()
+	_, _, body = ts.postForm(t, "/user/login", wrong)
+	assert.Equal(t, containsWidget(body), true)
+
+	forget()
+	code, _, _ := ts.postForm(t, "/user/login", right)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	forget()
+	right.Set("stub-response", "human")
+	code, _, _ = ts.postForm(t, "/user/login", right)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Logging in clears the account's count but not the client's.
+	forget()
+	_, _, body = ts.get(t, "/user/login")
+	assert.Equal(t, containsWidget(body), true)
+
+	// Both are forgotten after a while without failures.
+	frozen.Advance(loginFailureWindow + time.Minute)
+
+	forget()
+	_, _, body = ts.get(t, "/user/login")
+	assert.Equal(t, containsWidget(body), false)
+}
+
+// containsWidget reports whether a page shows the stub challenge.
+func containsWidget(body string) bool {
+	return strings.Contains(body, "class='stub-captcha'")
+}
+
+func TestAnonymousPosting(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Disabled", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	app := newTestApplication(t)
+	app.captcha = stubVerifier{}
+	app.config.CaptchaLoginFailures = 3
+	app.config.AnonymousPosting = true
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='/snippet/create' method='POST' data-draft=''>")
+	assert.Equal(t, containsWidget(body), true)
+	assert.Equal(t, strings.Contains(body, "name='private'"), false)
+
+	// Anonymous snippets are checked against the stricter limits.
+	form := url.Values{"title": {"Hello"}, "content": {strings.Repeat("x", anonymousMaxContent+1)}, "expires": {"365"}, "private": {"true"}}
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "not a robot")
+	assert.StringContains(t, body, "deleted within 7 days")
+	assert.StringContains(t, body, "at most 16 KB")
+	assert.StringContains(t, body, "Log in to create private snippets")
+
+	form = url.Values{"title": {"Hello"}, "content": {"World"}, "expires": {"7"}, "stub-response": {"human"}}
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The snippet has no owner, so no one can edit it.
+	snippet, err := app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/"+snippet.PublicID())
+	assert.Equal(t, snippet.OwnerID, 0)
+
+	// Every attempt counts against the limit of anonymous posts.
+	form.Set("content", "Again")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, header, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.Equal(t, header.Get("Retry-After"), "4320")
+
+	// Logged-in users aren't limited.
+	ts.login(t, "alice@example.com", "pa$$word")
+	form.Set("content", "Once more")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+}
+
+func TestCheckAnonymousPosting(t *testing.T) {
+	t.Parallel()
+
+	assert.NilError(t, checkAnonymousPosting(configuration{}))
+	assert.NilError(t, checkAnonymousPosting(configuration{AnonymousPosting: true, CaptchaProvider: "turnstile"}))
+	assert.Equal(t, checkAnonymousPosting(configuration{AnonymousPosting: true}) != nil, true)
+}
@@ -0,0 +1,260 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"crypto/tls" // Package for loading the TLS certificate.
+	"errors"     // Package for creating error messages.
+	"fmt"        // Package for formatted I/O.
+	"io"         // Package for I/O primitives.
+	"net"        // Package for parsing network addresses.
+	"net/mail"   // Package for parsing email addresses.
+	"net/url"    // Package for parsing the base URL.
+	"os"         // Package for interacting with the operating system.
+	"strings"    // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/captcha"    // Import the human verification package.
+	"snippetbox.adcon.dev/internal/dbconfig"   // Import the database configuration package.
+	"snippetbox.adcon.dev/internal/filter"     // Import the content filter package.
+	"snippetbox.adcon.dev/internal/geoip"      // Import the GeoIP package.
+	"snippetbox.adcon.dev/internal/migrations" // Import the migrations package.
+	"snippetbox.adcon.dev/internal/models"     // Import the models package.
+)
+
+// The TLS certificate and key the server is started with.
+const (
+	tlsCertFile = "./tls/cert.pem"
+	tlsKeyFile  = "./tls/key.pem"
+)
+
+// selfCheck verifies that the server can start with the given configuration: the flags are valid,
+// the files it needs can be read, the templates parse, the database is reachable and its schema is
+// up to date. It writes one line per check to w, with a hint for each failure, and reports whether
+// every check passed. It's run by the -check flag, for CI and container entrypoints.
+func selfCheck(w io.Writer, config configuration) bool {
+	checks := []struct {
+		name string
+		run  func() error
+		hint string
+	}{
+		{
+			name: "configuration",
+			run:  func() error { return checkConfig(config) },
+			hint: "run with -help to see the available flags",
+		},
+		{
+			name: "content keyring",
+			run: func() error {
+				_, err := loadKeyring(config.ContentKeys, config.ContentKeysFile)
+				return err
+			},
+			hint: "keys must be id:base64key pairs with 32-byte keys, separated by commas",
+		},
+		{
+			name: "session keyring",
+			run: func() error {
+				_, err := loadKeyring(config.SessionKeys, config.SessionKeysFile)
+				return err
+			},
+			hint: "keys must be id:base64key pairs with 32-byte keys, separated by commas",
+		},
+		{
+			name: "content filter",
+			run: func() error {
+				_, err := filter.Load(config.FilterFile)
+				return err
+			},
+			hint: "each rule must be \"action kind pattern\", see the filter package documentation",
+		},
+		{
+			name: "human verification",
+			run: func() error {
+				_, err := captcha.New(config.CaptchaProvider, config.CaptchaSiteKey, config.CaptchaSecret)
+				return err
+			},
+			hint: "-captcha-provider needs -captcha-site-key and -captcha-secret from the provider's dashboard",
+		},
+		{
+			name: "GeoIP database",
+			run: func() error {
+				if config.GeoIPDB == "" {
+					return nil
+				}
+				db, err := geoip.Open(config.GeoIPDB)
+				if err != nil {
+					return err
+				}
+				return db.Close()
+			},
+			hint: "-geoip-db must be a MaxMind Country or City database, such as GeoLite2-Country.mmdb",
+		},
+		{
+			name: "share key",
+			run: func() error {
+				_, err := models.ParseShareKey(config.ShareKey)
+				return err
+			},
+			hint: "-share-key must be a base64 encoded key of at least 32 bytes",
+		},
+		{
+			name: "templates",
+			run: func() error {
+				fsys, err := uiFiles(config.UIDir)
+				if err != nil {
+					return err
+				}
+				_, err = newUICache(fsys)
+				return err
+			},
+			hint: "fix the template named in the error",
+		},
+		{
+			name: "content pages",
+			run: func() error {
+				_, err := loadContentPages(config.ContentDir)
+				return err
+			},
+			hint: "each page needs front matter with a title, see the README",
+		},
+		{
+			name: "TLS certificate",
+			run: func() error {
+				_, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+				return err
+			},
+			hint: "create " + tlsCertFile + " and " + tlsKeyFile + ", for example with crypto/tls/generate_cert.go",
+		},
+		{
+			name: "database",
+			run:  func() error { return checkDatabase(config.DB) },
+			hint: "check -dsn or the -db-* flags and that MySQL is running; apply migrations with snippetboxctl migrate",
+		},
+	}
+
+	ok := true
+
+	for _, c := range checks {
+		if err := c.run(); err != nil {
+			fmt.Fprintf(w, "FAIL %s: %s\n     hint: %s\n", c.name, err, c.hint)
+			ok = false
+		} else {
+			fmt.Fprintf(w, "ok   %s\n", c.name)
+		}
+	}
+
+	return ok
+}
+
+// checkConfig validates the flag values that are only used later, such as the listen address.
+func checkConfig(config configuration) error {
+	problems := []string{}
+
+	if _, _, err := net.SplitHostPort(config.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("-addr %q is not a host:port address", config.Addr))
+	}
+	if info, err := os.Stat(config.StaticDir); err != nil || !info.IsDir() {
+		problems = append(problems, fmt.Sprintf("-static-dir %q is not a directory", config.StaticDir))
+	}
+	if config.CompressThreshold < 0 {
+		problems = append(problems, "-compress-threshold must not be negative")
+	}
+	if _, err := models.ParseCodec(config.CompressCodec); err != nil {
+		problems = append(problems, fmt.Sprintf("-compress-codec %q is not none, gzip or zstd", config.CompressCodec))
+	}
+	if config.FormMinFillTime < 0 {
+		problems = append(problems, "-form-min-fill-time must not be negative")
+	}
+	if config.CaptchaLoginFailures < 0 {
+		problems = append(problems, "-captcha-login-failures must not be negative")
+	}
+	if err := checkGeoIP(config); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := checkAnonymousPosting(config); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := checkBackups(config); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := checkLogFilter(config); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := checkBreaker(config); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if config.ClientInfoRetention <= 0 {
+		problems = append(problems, "-client-info-retention must be positive")
+	}
+	if !validAccessLog(config.AccessLog) {
+		problems = append(problems, fmt.Sprintf("-access-log %q is not off, basic or full", config.AccessLog))
+	}
+	if config.AccessLogRetention <= 0 {
+		problems = append(problems, "-access-log-retention must be positive")
+	}
+
+	if !validSessionStore(config.SessionStore) {
+		problems = append(problems, fmt.Sprintf("-session-store %q is not mysql or cookie", config.SessionStore))
+	}
+	if config.SessionStore == sessionStoreCookie && config.SessionKeys == "" && config.SessionKeysFile == "" {
+		problems = append(problems, "-session-store cookie needs -session-keys or -session-keys-file")
+	}
+
+	if !validFrameOptions(config.FrameOptions) {
+		problems = append(problems, fmt.Sprintf("-frame-options %q is not deny, sameorigin or empty", config.FrameOptions))
+	}
+	if !validReferrerPolicy(config.ReferrerPolicy) {
+		problems = append(problems, fmt.Sprintf("-referrer-policy %q is not a referrer policy", config.ReferrerPolicy))
+	}
+	if config.HSTSMaxAge < 0 {
+		problems = append(problems, "-hsts-max-age must not be negative")
+	}
+	// The preload lists only take sites that send HSTS for a year or more, with their subdomains.
+	if config.HSTSPreload && (config.HSTSMaxAge < hstsPreloadMinAge || !config.HSTSSubdomains) {
+		problems = append(problems, "-hsts-preload needs -hsts-max-age of at least 8760h and -hsts-include-subdomains")
+	}
+
+	if !validServerTiming(config.ServerTiming) {
+		problems = append(problems, fmt.Sprintf("-server-timing %q is not off, admin or on", config.ServerTiming))
+	}
+
+	if config.SMTPPort < 1 || config.SMTPPort > 65535 {
+		problems = append(problems, fmt.Sprintf("-smtp-port %d is not a valid port", config.SMTPPort))
+	}
+	if _, err := mail.ParseAddress(config.SMTPSender); err != nil {
+		problems = append(problems, fmt.Sprintf("-smtp-sender %q is not an email address", config.SMTPSender))
+	}
+	if u, err := url.Parse(config.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("-base-url %q is not an http or https URL", config.BaseURL))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// checkDatabase connects to the database, over TLS if it's configured, and verifies that every
+// migration has been applied.
+func checkDatabase(config dbconfig.Config) error {
+	dsn, err := config.Build()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(dsn, 0, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	pending, err := migrations.Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+
+	return nil
+}
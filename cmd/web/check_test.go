@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestCheckConfig(t *testing.T) {
+	t.Parallel()
+
+	valid := configuration{
+		Addr:                ":4000",
+		StaticDir:           "../../ui/static/",
+		CompressCodec:       "zstd",
+		ClientInfoRetention: time.Hour,
+		AccessLog:           "off",
+		AccessLogRetention:  time.Hour,
+		SessionStore:        "mysql",
+		SMTPPort:            587,
+		SMTPSender:          "Snippetbox <no-reply@example.com>",
+		BaseURL:             "https://snippetbox.example.com",
+		ServerTiming:        "off",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *configuration)
+		wantErr string
+	}{
+		{
+			name:   "Valid",
+			modify: func(c *configuration) {},
+		},
+		{
+			name:    "Bad address",
+			modify:  func(c *configuration) { c.Addr = "4000" },
+			wantErr: `-addr "4000" is not a host:port address`,
+		},
+		{
+			name:    "Missing static directory",
+			modify:  func(c *configuration) { c.StaticDir = "./no-such-dir" },
+			wantErr: `-static-dir "./no-such-dir" is not a directory`,
+		},
+		{
+			name:    "Unknown codec",
+			modify:  func(c *configuration) { c.CompressCodec = "lz4" },
+			wantErr: `-compress-codec "lz4" is not none, gzip or zstd`,
+		},
+		{
+			name:    "Unknown access log level",
+			modify:  func(c *configuration) { c.AccessLog = "everything" },
+			wantErr: `-access-log "everything" is not off, basic or full`,
+		},
+		{
+			name:    "Unknown server timing mode",
+			modify:  func(c *configuration) { c.ServerTiming = "debug" },
+			wantErr: `-server-timing "debug" is not off, admin or on`,
+		},
+		{
+			name:    "Unknown frame options",
+			modify:  func(c *configuration) { c.FrameOptions = "allow-from https://example.com" },
+			wantErr: `-frame-options "allow-from https://example.com" is not deny, sameorigin or empty`,
+		},
+		{
+			name:    "Unknown referrer policy",
+			modify:  func(c *configuration) { c.ReferrerPolicy = "no-referrer, sometimes" },
+			wantErr: `-referrer-policy "no-referrer, sometimes" is not a referrer policy`,
+		},
+		{
+			name: "Short HSTS preload",
+			modify: func(c *configuration) {
+				c.HSTSMaxAge = 24 * time.Hour
+				c.HSTSSubdomains = true
+				c.HSTSPreload = true
+			},
+			wantErr: "-hsts-preload needs -hsts-max-age of at least 8760h and -hsts-include-subdomains",
+		},
+		{
+			name:    "Unknown session store",
+			modify:  func(c *configuration) { c.SessionStore = "redis" },
+			wantErr: `-session-store "redis" is not mysql or cookie`,
+		},
+		{
+			name:    "Cookie sessions without keys",
+			modify:  func(c *configuration) { c.SessionStore = "cookie" },
+			wantErr: "-session-store cookie needs -session-keys or -session-keys-file",
+		},
+		{
+			name:    "Bad sender",
+			modify:  func(c *configuration) { c.SMTPSender = "Snippetbox" },
+			wantErr: `-smtp-sender "Snippetbox" is not an email address`,
+		},
+		{
+			name:    "Relative base URL",
+			modify:  func(c *configuration) { c.BaseURL = "snippetbox.example.com" },
+			wantErr: `-base-url "snippetbox.example.com" is not an http or https URL`,
+		},
+		{
+			name: "Several problems",
+			modify: func(c *configuration) {
+				c.CompressThreshold = -1
+				c.ClientInfoRetention = 0
+			},
+			wantErr: "-compress-threshold must not be negative; -client-info-retention must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+
+			err := checkConfig(config)
+
+			if tt.wantErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Equal(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"fmt"      // Package for formatted I/O.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// collectionForm represents the form for creating and editing a collection.
+type collectionForm struct {
+	Name                string `form:"name" validate:"required,maxrunes=100"`
+	Public              bool   `form:"public"`
+	validator.Validator `form:"-"`
+}
+
+// collectionList serves the "/collections" URL. It lists the collections of the current user.
+func (app *application) collectionList(w http.ResponseWriter, r *http.Request) {
+	collections, err := app.collections.ByOwner(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collections = collections
+
+	app.render(w, r, http.StatusOK, "collections.html", data)
+}
+
+// collectionCreate serves the "/collection/create" URL with an empty collection form.
+func (app *application) collectionCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = collectionForm{}
+
+	app.render(w, r, http.StatusOK, "collection_form.html", data)
+}
+
+// collectionCreatePost creates a collection for the current user and redirects to it.
+func (app *application) collectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	if form.Valid() {
+		id, err := app.collections.Insert(app.authenticatedUserID(r), form.Name, form.Public)
+		switch {
+		case err == nil:
+			app.sessionManager.Put(r.Context(), "flash", "Collection created!")
+			http.Redirect(w, r, fmt.Sprintf("/collection/view/%d", id), http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrDuplicateCollection):
+			form.AddFieldError("name", "You already have a collection with this name")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, r, http.StatusUnprocessableEntity, "collection_form.html", data)
+}
+
+// collectionView serves the "/collection/view/:id" URL. Public collections can be viewed by anyone,
+// private ones only by their owner. Snippets that have expired or that the visitor may not see are
+// left out.
+func (app *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	collection, err := app.collectionFromParams(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !collection.Public && collection.OwnerID != app.authenticatedUserID(r) {
+		app.notFound(w)
+		return
+	}
+
+	ids, err := app.collections.SnippetIDs(collection.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets := []*models.Snippet{}
+	for _, id := range ids {
+		snippet, err := app.snippets.Get(id)
+		if errors.Is(err, models.ErrNoRecord) {
+			continue
+		}
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if app.canView(r, snippet) {
+			snippets = append(snippets, snippet)
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.SnippetsData = snippets
+	data.IsOwner = collection.OwnerID == app.authenticatedUserID(r)
+
+	app.render(w, r, http.StatusOK, "collection.html", data)
+}
+
+// collectionEdit serves the "/collection/edit/:id" URL with the form filled in with the collection.
+func (app *application) collectionEdit(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Form = collectionForm{Name: collection.Name, Public: collection.Public}
+
+	app.render(w, r, http.StatusOK, "collection_form.html", data)
+}
+
+// collectionEditPost renames a collection or changes whether it's public.
+func (app *application) collectionEditPost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var form collectionForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	if form.Valid() {
+		err := app.collections.Update(collection.ID, form.Name, form.Public)
+		switch {
+		case err == nil:
+			app.sessionManager.Put(r.Context(), "flash", "Collection updated!")
+			http.Redirect(w, r, fmt.Sprintf("/collection/view/%d", collection.ID), http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrDuplicateCollection):
+			form.AddFieldError("name", "You already have a collection with this name")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Form = form
+	app.render(w, r, http.StatusUnprocessableEntity, "collection_form.html", data)
+}
+
+// collectionDeletePost deletes a collection. The snippets in it are kept.
+func (app *application) collectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.collections.Delete(collection.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection deleted.")
+	http.Redirect(w, r, "/collections", http.StatusSeeOther)
+}
+
+// collectionAddPost serves the "add to collection" form of the snippet page. The "collection_id"
+// and "snippet_id" form fields name the collection, which must belong to the current user, and the
+// public ID of a snippet the user can see.
+func (app *application) collectionAddPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	collectionID, err := strconv.Atoi(r.PostForm.Get("collection_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := app.collections.Get(collectionID)
+	if err != nil || collection.OwnerID != app.authenticatedUserID(r) {
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+		} else {
+			app.notFound(w)
+		}
+		return
+	}
+
+	snippet, err := app.snippetByPublicID(r.PostForm.Get("snippet_id"))
+	if err != nil || !app.canView(r, snippet) {
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+		} else {
+			app.notFound(w)
+		}
+		return
+	}
+
+	if err := app.collections.AddSnippet(collection.ID, snippet.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Added to %s!", collection.Name))
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// collectionRemovePost removes the snippet whose public ID is in the "snippet_id" form field from a
+// collection.
+func (app *application) collectionRemovePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet, err := app.snippetByPublicID(r.PostForm.Get("snippet_id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.collections.RemoveSnippet(collection.ID, snippet.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/view/%d", collection.ID), http.StatusSeeOther)
+}
+
+// collectionFromParams fetches the collection identified by the "id" URL parameter.
+func (app *application) collectionFromParams(r *http.Request) (*models.Collection, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	return app.collections.Get(id)
+}
+
+// ownCollection fetches the collection identified by the "id" URL parameter for a handler that
+// changes it. If the collection doesn't exist or belongs to someone else, it sends a 404 response
+// and returns false.
+func (app *application) ownCollection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	collection, err := app.collectionFromParams(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if collection.OwnerID != app.authenticatedUserID(r) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return collection, true
+}
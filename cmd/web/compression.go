@@ -0,0 +1,111 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"bytes"         // Package for buffering the compressed assets.
+	"compress/gzip" // Package for the gzip variants of assets.
+	"path"          // Package for manipulating slash-separated paths.
+	"strconv"       // Package for parsing quality values.
+	"strings"       // Package for manipulating strings.
+
+	"github.com/andybalholm/brotli" // Import the Brotli package.
+)
+
+// assetEncodings are the content codings assets are precompressed with, in order of preference.
+var assetEncodings = []string{"br", "gzip"}
+
+// compressibleAssets are the extensions of the assets worth compressing. Images such as PNGs are
+// compressed already.
+var compressibleAssets = map[string]bool{
+	".css":  true,
+	".html": true,
+	".ico":  true,
+	".js":   true,
+	".json": true,
+	".svg":  true,
+	".txt":  true,
+}
+
+// compressAsset returns the variants of the content of an asset for each of assetEncodings, keyed
+// by the content coding. Encodings that don't make the asset smaller are left out, and so are
+// assets that aren't compressible.
+func compressAsset(name string, content []byte) (map[string][]byte, error) {
+	if !compressibleAssets[path.Ext(name)] {
+		return nil, nil
+	}
+
+	variants := map[string][]byte{}
+	for _, encoding := range assetEncodings {
+		var buf bytes.Buffer
+		var err error
+
+		switch encoding {
+		case "br":
+			bw := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+			if _, err = bw.Write(content); err == nil {
+				err = bw.Close()
+			}
+		case "gzip":
+			var gw *gzip.Writer
+			gw, err = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = gw.Write(content); err == nil {
+				err = gw.Close()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() < len(content) {
+			variants[encoding] = buf.Bytes()
+		}
+	}
+
+	return variants, nil
+}
+
+// negotiateEncoding returns the first of the available content codings, in the order of
+// assetEncodings, that an Accept-Encoding header allows, or "" for the asset as it is. A coding is
+// allowed when it's listed, or covered by "*", without a quality of 0.
+func negotiateEncoding(acceptEncoding string, available map[string][]byte) string {
+	if acceptEncoding == "" || len(available) == 0 {
+		return ""
+	}
+
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[coding] = q
+	}
+
+	for _, encoding := range assetEncodings {
+		if _, ok := available[encoding]; !ok {
+			continue
+		}
+
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > 0 {
+			return encoding
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,156 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"bytes"         // Package for splitting the front matter from the page.
+	"errors"        // Package for creating error messages.
+	"fmt"           // Package for formatted I/O.
+	"html/template" // Package for marking the rendered pages as safe.
+	"io/fs"         // Package for reading the content directory.
+	"net/http"      // Package for building HTTP servers and clients.
+	"os"            // Package for interacting with the operating system.
+	"path"          // Package for manipulating slash-separated paths.
+	"regexp"        // Package for validating page names.
+	"strconv"       // Package for formatting the cache lifetime.
+	"strings"       // Package for manipulating strings.
+	"time"          // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/markdown" // Import the Markdown renderer.
+)
+
+// contentSlug is the pattern of the names of content pages, and so of their paths.
+var contentSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// frontMatterDelimiter opens and closes the front matter at the top of a content page.
+const frontMatterDelimiter = "---"
+
+// contentPage is a page written by the operator as a Markdown file in the content directory, such
+// as content/about.md, and served at /about.
+type contentPage struct {
+	Slug     string        // Slug is the name of the file without its extension, and the path of the page.
+	Title    string        // Title is the title from the front matter.
+	HTML     template.HTML // HTML is the rendered Markdown, which the operator wrote and so is shown as it is.
+	CacheFor time.Duration // CacheFor is how long browsers may keep the page without revalidating it, or 0.
+	Modified time.Time     // Modified is when the file was last changed.
+}
+
+// loadContentPages reads the Markdown files at the top of the content directory. A missing
+// directory means there are no pages; a file that can't be parsed is an error, so that a typo
+// stops the server instead of taking a page down.
+func loadContentPages(dir string) (map[string]*contentPage, error) {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return map[string]*contentPage{}, nil
+	}
+
+	return readContentPages(os.DirFS(dir))
+}
+
+// readContentPages reads the Markdown files at the top of fsys.
+func readContentPages(fsys fs.FS) (map[string]*contentPage, error) {
+	names, err := fs.Glob(fsys, "*.md")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := map[string]*contentPage{}
+	for _, name := range names {
+		source, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := parseContentPage(strings.TrimSuffix(name, path.Ext(name)), source)
+		if err != nil {
+			return nil, fmt.Errorf("content page %s: %w", name, err)
+		}
+		page.Modified = info.ModTime()
+
+		pages[page.Slug] = page
+	}
+
+	return pages, nil
+}
+
+// parseContentPage parses a content page: front matter between "---" lines, with a "title" and
+// optionally a "cache" lifetime such as "1h", followed by the Markdown of the page.
+func parseContentPage(slug string, source []byte) (*contentPage, error) {
+	if !contentSlug.MatchString(slug) {
+		return nil, fmt.Errorf("the name %q must be lower case letters, digits and dashes", slug)
+	}
+
+	source = append(bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n")), '\n')
+	rest, ok := bytes.CutPrefix(source, []byte(frontMatterDelimiter+"\n"))
+	if !ok {
+		return nil, errors.New("the page must start with front matter between --- lines")
+	}
+	frontMatter, body, ok := bytes.Cut(rest, []byte("\n"+frontMatterDelimiter+"\n"))
+	if !ok {
+		return nil, errors.New("the front matter must end with a --- line")
+	}
+
+	page := &contentPage{Slug: slug}
+	for i, line := range strings.Split(string(frontMatter), "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("front matter line %d: want key: value", i+2)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		switch strings.TrimSpace(key) {
+		case "title":
+			page.Title = value
+		case "cache":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("front matter line %d: cache must be a duration such as 1h", i+2)
+			}
+			page.CacheFor = d
+		default:
+			return nil, fmt.Errorf("front matter line %d: unknown key %q", i+2, key)
+		}
+	}
+
+	if page.Title == "" {
+		return nil, errors.New("the front matter must have a title")
+	}
+
+	page.HTML = template.HTML(markdown.Render(string(body)))
+
+	return page, nil
+}
+
+// contentPageView serves a content page at its path.
+func (app *application) contentPageView(w http.ResponseWriter, r *http.Request) {
+	page, ok := app.pages[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Page = page
+
+	// Content pages are the same for every visitor, apart from the navigation and flash messages.
+	// They're revalidated on every request unless the front matter says how long to keep them.
+	visibility := "public"
+	if app.isAuthenticated(r) || data.Flash != "" {
+		visibility = "private"
+	}
+	if page.CacheFor > 0 {
+		w.Header().Set("Cache-Control", visibility+", max-age="+strconv.Itoa(int(page.CacheFor.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", visibility+", no-cache")
+	}
+
+	app.renderCacheable(w, r, "content.html", data, page.Modified)
+}
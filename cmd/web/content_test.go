@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestParseContentPage(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		slug    string
+		source  string
+		want    *contentPage
+		wantErr string
+	}{
+		{
+			name:   "Valid",
+			slug:   "about",
+			source: "---\r\ntitle: \"About us\"\r\n# A comment\r\ncache: 1h\r\n---\r\n# Hello\r\n",
+			want:   &contentPage{Slug: "about", Title: "About us", CacheFor: time.Hour, HTML: "<h1>Hello</h1>\n"},
+		},
+		{
+			name:   "No cache",
+			slug:   "terms-2024",
+			source: "---\ntitle: Terms\n---\nPlain *text*",
+			want:   &contentPage{Slug: "terms-2024", Title: "Terms", HTML: "<p>Plain <em>text</em></p>\n"},
+		},
+		{name: "Bad slug", slug: "About_Us", source: "---\ntitle: About\n---\n", wantErr: `the name "About_Us" must be lower case letters, digits and dashes`},
+		{name: "No front matter", slug: "about", source: "# About\n", wantErr: "the page must start with front matter between --- lines"},
+		{name: "Unclosed front matter", slug: "about", source: "---\ntitle: About\n", wantErr: "the front matter must end with a --- line"},
+		{name: "No title", slug: "about", source: "---\ncache: 1h\n---\n", wantErr: "the front matter must have a title"},
+		{name: "Unknown key", slug: "about", source: "---\ntitle: About\nlayout: wide\n---\n", wantErr: `front matter line 3: unknown key "layout"`},
+		{name: "Bad cache", slug: "about", source: "---\ntitle: About\ncache: forever\n---\n", wantErr: "front matter line 3: cache must be a duration such as 1h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := parseContentPage(tt.slug, []byte(tt.source))
+			if tt.wantErr != "" {
+				assert.Equal(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, *page, *tt.want)
+		})
+	}
+}
+
+func TestContentPages(t *testing.T) {
+
+	t.Parallel()
+
+	modified := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	pages, err := readContentPages(fstest.MapFS{
+		"about.md":   {Data: []byte("---\ntitle: About\ncache: 1h\n---\nWe keep *snippets*."), ModTime: modified},
+		"privacy.md": {Data: []byte("---\ntitle: Privacy\n---\nNothing to see."), ModTime: modified},
+		"admin.md":   {Data: []byte("---\ntitle: Admin\n---\nShadowed."), ModTime: modified},
+		"notes.txt":  {Data: []byte("Not a page.")},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(pages), 3)
+
+	app := newTestApplication(t)
+	app.pages = pages
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/about")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<title>About - Snippetbox</title>")
+	assert.StringContains(t, body, "<p>We keep <em>snippets</em>.</p>")
+	assert.Equal(t, header.Get("Cache-Control"), "public, max-age=3600")
+	assert.Equal(t, header.Get("Last-Modified"), "Sat, 01 Jun 2030 12:00:00 GMT")
+
+	code, _, _ = ts.request(t, http.MethodGet, "/about", http.Header{"If-None-Match": {header.Get("ETag")}})
+	assert.Equal(t, code, http.StatusNotModified)
+
+	_, header, _ = ts.get(t, "/privacy")
+	assert.Equal(t, header.Get("Cache-Control"), "public, no-cache")
+
+	// Pages don't replace the application's own routes.
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.get(t, "/notes")
+	assert.Equal(t, code, http.StatusNotFound)
+}
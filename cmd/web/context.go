@@ -3,3 +3,20 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+const isAdminContextKey = contextKey("isAdmin")
+
+// cspNonceContextKey holds the nonce of the request's Content-Security-Policy.
+const cspNonceContextKey = contextKey("cspNonce")
+
+// serverTimingContextKey holds the timing of the request, when requests are timed.
+const serverTimingContextKey = contextKey("serverTiming")
+
+// countryContextKey holds the country code of the client, when a GeoIP database is configured.
+const countryContextKey = contextKey("country")
+
+// geoChallengeContextKey marks requests from countries that have to pass human verification.
+const geoChallengeContextKey = contextKey("geoChallenge")
+
+// apiTokenContextKey holds the API token a request was authenticated with, if any.
+const apiTokenContextKey = contextKey("apiToken")
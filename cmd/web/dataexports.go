@@ -0,0 +1,186 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"       // Package for iterating over sessions.
+	"encoding/json" // Package for encoding the archive.
+	"errors"        // Package for creating error messages.
+	"net/http"      // Package for building HTTP servers and clients.
+	"strconv"       // Package for converting strings to numeric types.
+	"strings"       // Package for manipulating strings.
+	"time"          // Package for measuring and displaying time.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/mailer" // Import the email package.
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+	"snippetbox.adcon.dev/ui"              // Import the embedded templates.
+)
+
+// The format of data export archives.
+const (
+	dataExportFormat  = "snippetbox-user-data"
+	dataExportVersion = 1
+)
+
+// dataExportArchive is the archive of a user's data: the data stored in the database and the
+// user's sessions.
+type dataExportArchive struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	Exported time.Time `json:"exported"`
+	*models.UserData
+	Sessions []exportSession `json:"sessions"`
+}
+
+// exportSession is a session the user is logged in with. Session tokens are credentials, so only
+// their expiry is exported.
+type exportSession struct {
+	Expires time.Time `json:"expires"`
+}
+
+// dataExportEmail is the data of the email sent when an archive is ready.
+type dataExportEmail struct {
+	Name        string // Name is the name of the recipient.
+	DownloadURL string // DownloadURL is the page where the archive can be downloaded.
+	Days        int    // Days is the number of days the archive can be downloaded.
+}
+
+// accountDataExport serves the "/account/data-export" URL, where users ask for an archive of
+// everything stored about them and download it once it's ready.
+func (app *application) accountDataExport(w http.ResponseWriter, r *http.Request) {
+	export, err := app.dataExports.Latest(app.authenticatedUserID(r))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.DataExport = export
+
+	app.render(w, r, http.StatusOK, "dataexport.html", data)
+}
+
+// accountDataExportPost asks for an archive of the current user's data. The archive is built by a
+// background job, which emails the user when it's ready.
+func (app *application) accountDataExportPost(w http.ResponseWriter, r *http.Request) {
+	if _, err := app.dataExports.Request(app.authenticatedUserID(r)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "We're preparing your data. We'll email you when it's ready to download.")
+	http.Redirect(w, r, "/account/data-export", http.StatusSeeOther)
+}
+
+// accountDataExportDownload serves the archive of a ready export of the current user as a JSON
+// attachment.
+func (app *application) accountDataExportDownload(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	archive, err := app.dataExports.Archive(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="snippetbox-data.json"`)
+	w.Write(archive)
+}
+
+// buildDataExports builds the archives users asked for and emails them when they're ready. It's
+// run periodically by a background job. Each export is claimed before it's built, so that servers
+// don't build the same archive.
+func (app *application) buildDataExports() error {
+	pending, err := app.dataExports.Pending()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, e := range pending {
+		claimed, err := app.dataExports.Claim(e)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := app.buildDataExport(e); err != nil {
+			errs = append(errs, err)
+			if err := app.dataExports.Fail(e.ID); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// buildDataExport builds the archive of one export and emails its owner.
+func (app *application) buildDataExport(e *models.DataExport) error {
+	data, err := app.dataExports.Collect(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := app.userSessions(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := json.MarshalIndent(dataExportArchive{
+		Format:   dataExportFormat,
+		Version:  dataExportVersion,
+		Exported: app.clock.Now().UTC(),
+		UserData: data,
+		Sessions: sessions,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := app.dataExports.Complete(e.ID, archive); err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(ui.Files, "email/data_export.tmpl", data.Profile.Email, dataExportEmail{
+		Name:        data.Profile.Name,
+		DownloadURL: strings.TrimSuffix(app.config.BaseURL, "/") + "/account/data-export",
+		Days:        int(models.DataExportLifetime / (24 * time.Hour)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(msg)
+}
+
+// userSessions returns the sessions a user is logged in with, by going through every session.
+func (app *application) userSessions(userID int) ([]exportSession, error) {
+	sessions := []exportSession{}
+
+	err := app.sessionManager.Iterate(context.Background(), func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") == userID {
+			sessions = append(sessions, exportSession{Expires: app.sessionManager.Deadline(ctx).UTC()})
+		}
+		return nil
+	})
+
+	return sessions, err
+}
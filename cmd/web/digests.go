@@ -0,0 +1,165 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strings"  // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/mailer"    // Import the email package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+	"snippetbox.adcon.dev/ui"                 // Import the embedded templates.
+)
+
+// The number of snippets listed in each section of a digest.
+const (
+	digestActivityLimit = 10
+	digestTrendingLimit = 5
+)
+
+// digestForm represents the form for choosing how often to get an activity digest.
+type digestForm struct {
+	Frequency           string `form:"frequency" validate:"oneof=off|daily|weekly"`
+	validator.Validator `form:"-"`
+}
+
+// digestLink is a snippet listed in a digest, with the number of views if it's one of the
+// recipient's.
+type digestLink struct {
+	Title string
+	URL   string
+	Views int
+}
+
+// digestEmail is the data of the digest email template.
+type digestEmail struct {
+	Name        string       // Name is the name of the recipient.
+	Frequency   string       // Frequency is "daily" or "weekly".
+	Activity    []digestLink // Activity lists the recipient's most viewed snippets in the period.
+	Views       int          // Views is the total of the views in Activity.
+	Trending    []digestLink // Trending lists the trending public snippets.
+	SettingsURL string       // SettingsURL is the page where the digest can be turned off.
+}
+
+// accountDigest serves the "/account/digest" URL, where users choose whether and how often they
+// get an activity digest by email.
+func (app *application) accountDigest(w http.ResponseWriter, r *http.Request) {
+	frequency, err := app.digests.Frequency(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = digestForm{Frequency: frequency}
+
+	app.render(w, r, http.StatusOK, "digest.html", data)
+}
+
+// accountDigestPost saves the digest frequency of the current user.
+func (app *application) accountDigestPost(w http.ResponseWriter, r *http.Request) {
+	var form digestForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "digest.html", data)
+		return
+	}
+
+	if err := app.digests.SetFrequency(app.authenticatedUserID(r), form.Frequency); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if form.Frequency == models.DigestOff {
+		app.sessionManager.Put(r.Context(), "flash", "You won't get activity digests anymore.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "You'll get a "+form.Frequency+" activity digest.")
+	}
+
+	http.Redirect(w, r, "/account/digest", http.StatusSeeOther)
+}
+
+// sendDigests emails the digests that are due. It's run periodically by a background job. Each
+// digest is claimed before it's sent, so a digest that fails to send isn't retried until the next
+// period; digests with nothing to report are claimed but not sent.
+func (app *application) sendDigests() error {
+	due, err := app.digests.Due()
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	trending, err := app.snippets.Trending(digestTrendingLimit)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, d := range due {
+		claimed, err := app.digests.Claim(d)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := app.sendDigest(d, trending); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendDigest builds and sends the digest of one recipient.
+func (app *application) sendDigest(d *models.DigestRecipient, trending []*models.Snippet) error {
+	activity, err := app.digests.Activity(d.UserID, d.Since(app.clock.Now()), digestActivityLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(activity) == 0 && len(trending) == 0 {
+		return nil
+	}
+
+	base := strings.TrimSuffix(app.config.BaseURL, "/")
+
+	data := digestEmail{
+		Name:        d.Name,
+		Frequency:   d.Frequency,
+		SettingsURL: base + "/account/digest",
+	}
+
+	for _, a := range activity {
+		data.Activity = append(data.Activity, digestLink{Title: a.Title, URL: base + "/snippet/view/" + a.PublicID(), Views: a.Views})
+		data.Views += a.Views
+	}
+
+	for _, s := range trending {
+		// Trending snippets of the recipient are already listed with their views.
+		if s.OwnerID == d.UserID {
+			continue
+		}
+		data.Trending = append(data.Trending, digestLink{Title: s.Title, URL: base + "/snippet/view/" + s.PublicID()})
+	}
+
+	msg, err := mailer.Render(ui.Files, "email/digest.tmpl", d.Email, data)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(msg)
+}
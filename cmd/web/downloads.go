@@ -0,0 +1,189 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"archive/zip"   // Package for writing zip archives.
+	"crypto/sha256" // Package for hashing content into entity tags.
+	"encoding/hex"  // Package for encoding the hashes.
+	"fmt"           // Package for formatted I/O.
+	"io"            // Package for I/O primitives.
+	"mime"          // Package for formatting the Content-Disposition header.
+	"net/http"      // Package for building HTTP servers and clients.
+	"path"          // Package for manipulating slash-separated paths.
+	"strings"       // Package for manipulating strings.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/langdetect" // Import the language detection package.
+	"snippetbox.adcon.dev/internal/models"     // Import the models package.
+)
+
+// snippetDownload serves the "/snippet/download/:file" URL. When file is the public ID of a
+// snippet, the content is sent as an attachment named after the snippet's title and language, as
+// snippetFileName does. When the ID is followed by ".zip", it streams a zip archive of the
+// snippet's file and a README with the snippet's details. Snippets hold a single file, so the
+// archive always has two entries; writeSnippetArchive takes a list so that it needn't change if
+// snippets ever hold more. The archive is written as it's built, so the response is never held in
+// memory as a whole. Like the raw content, both count as views of the snippet.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	file := httprouter.ParamsFromContext(r.Context()).ByName("file")
+
+	id, archive := strings.CutSuffix(file, ".zip")
+
+	snippet, ok := app.viewableSnippet(w, r, id)
+	if !ok {
+		return
+	}
+
+	app.recordView(r, snippet.ID)
+	app.recordAccess(r, snippet)
+
+	if !archive {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": snippetFileName(snippet)}))
+		w.Header().Set("Cache-Control", "private, no-cache")
+		io.WriteString(w, snippet.Content)
+		return
+	}
+
+	files := []snippetFile{{Name: snippetFileName(snippet), Content: snippet.Content}}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, snippet.PublicID()))
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	// The status has been sent once the archive is being written, so errors can only be logged.
+	if err := app.writeSnippetArchive(w, snippet, files); err != nil {
+		app.errorLog.Printf("writing archive of snippet %d: %v", snippet.ID, err)
+	}
+}
+
+// snippetRaw serves the "/snippet/raw/:id" URL with the content of a snippet as plain text, for
+// curl and pipes. Like the snippet's page, it has an ETag and a Last-Modified header so clients can
+// revalidate their copy, and answers range requests.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r, httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if !ok {
+		return
+	}
+
+	app.recordView(r, snippet.ID)
+	app.recordAccess(r, snippet)
+
+	sum := sha256.Sum256([]byte(snippet.Content))
+
+	h := w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("Cache-Control", app.snippetCacheControl(r, snippet))
+	h.Set("ETag", `"`+hex.EncodeToString(sum[:])[:etagLength]+`"`)
+	h.Add("Vary", "Cookie")
+
+	http.ServeContent(w, r, "", lastModified(snippet), strings.NewReader(snippet.Content))
+}
+
+// snippetFile is a file of a snippet in a download. Snippets hold a single file for now.
+type snippetFile struct {
+	Name    string
+	Content string
+}
+
+// writeSnippetArchive writes the zip archive of a snippet's files to w, followed by the README.
+func (app *application) writeSnippetArchive(w io.Writer, snippet *models.Snippet, files []snippetFile) error {
+	zw := zip.NewWriter(w)
+
+	readme := "README.md"
+	for _, f := range files {
+		if strings.EqualFold(f.Name, readme) {
+			readme = "SNIPPET.md"
+		}
+
+		if err := writeZipFile(zw, snippet, f.Name, f.Content); err != nil {
+			return err
+		}
+	}
+
+	if err := writeZipFile(zw, snippet, readme, app.snippetReadme(snippet, files)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeZipFile adds a file to an archive, dated like the snippet it's from.
+func writeZipFile(zw *zip.Writer, snippet *models.Snippet, name, content string) error {
+	modified := snippet.Updated
+	if modified.IsZero() {
+		modified = snippet.Created
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write([]byte(content))
+	return err
+}
+
+// snippetReadme returns the README of a snippet's archive: its title, where it's from, its license
+// and when it was written and expires.
+func (app *application) snippetReadme(snippet *models.Snippet, files []snippetFile) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", snippet.Title)
+	fmt.Fprintf(&b, "- Snippet: %s/snippet/view/%s\n", strings.TrimSuffix(app.config.BaseURL, "/"), snippet.PublicID())
+	if snippet.Language != "" {
+		fmt.Fprintf(&b, "- Language: %s\n", snippet.Language)
+	}
+	if url := snippet.LicenseURL(); url != "" {
+		fmt.Fprintf(&b, "- License: %s (%s)\n", snippet.License, url)
+	} else if snippet.License != "" {
+		fmt.Fprintf(&b, "- License: %s\n", snippet.License)
+	}
+	fmt.Fprintf(&b, "- Created: %s\n", humanDate(snippet.Created))
+	if snippet.Edited() {
+		fmt.Fprintf(&b, "- Last edited: %s\n", humanDate(snippet.Updated))
+	}
+	if snippet.Permanent() {
+		b.WriteString("- Never expires\n")
+	} else {
+		fmt.Fprintf(&b, "- Expires: %s\n", humanDate(snippet.Expires))
+	}
+
+	b.WriteString("\n## Files\n\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "- %s\n", f.Name)
+	}
+
+	return b.String()
+}
+
+// snippetFileName returns the name of a snippet's file in its download: the title if it's a file
+// name, such as "main.go" or "Dockerfile", and otherwise the title in URL form with the extension
+// of the snippet's language.
+func snippetFileName(snippet *models.Snippet) string {
+	title := strings.TrimSpace(snippet.Title)
+	if langdetect.Detect(title, "") != "" {
+		name := path.Base(strings.ReplaceAll(title, `\`, "/"))
+		if name != "." && name != ".." && name != "/" {
+			return name
+		}
+	}
+
+	name := titleSlug(snippet.Title)
+	if name == "" {
+		name = "snippet"
+	}
+
+	ext := langdetect.Extension(snippet.Language)
+	if ext == "" {
+		ext = ".txt"
+	}
+
+	return name + ext
+}
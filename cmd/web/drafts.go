@@ -0,0 +1,181 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"       // Package for creating error messages.
+	"net/http"     // Package for building HTTP servers and clients.
+	"time"         // Package for measuring and displaying time.
+	"unicode/utf8" // Package for truncating the title to whole characters.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// draftMaxBytes is the largest draft request body accepted.
+const draftMaxBytes = 1 << 20
+
+// draftMaxTitleRunes is the length titles are cut to in drafts, to fit the column.
+const draftMaxTitleRunes = 255
+
+// The session keys of the draft of a visitor who isn't logged in.
+const (
+	draftTitleKey   = "draftTitle"
+	draftContentKey = "draftContent"
+	draftUpdatedKey = "draftUpdated"
+)
+
+// draftsEnabled reports whether the snippet forms of the current user are saved as drafts. Drafts
+// of logged-in users are kept in the database, and those of visitors who aren't logged in, who can
+// only create snippets, in their session, unless sessions are kept in cookies, which are too small
+// for them.
+func (app *application) draftsEnabled(r *http.Request) bool {
+	return app.isAuthenticated(r) || app.cookieSessions == nil
+}
+
+// snippetDraftPost serves the "/snippet/draft" URL that the snippet forms post to as the user
+// types. It saves the "title" and "content" form fields as the user's draft, for the snippet in
+// the "snippet" field or for a new snippet if it's empty. An empty title and content discard the
+// draft. It responds with 204 No Content.
+//
+// Visitors who aren't logged in can save the draft of a new snippet, in their session, as long as
+// it fits the size limit of anonymous snippets.
+func (app *application) snippetDraftPost(w http.ResponseWriter, r *http.Request) {
+	if !app.draftsEnabled(r) {
+		app.notFound(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, draftMaxBytes)
+
+	if err := r.ParseForm(); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	draft := models.Draft{
+		UserID:  userID,
+		Title:   r.PostForm.Get("title"),
+		Content: r.PostForm.Get("content"),
+	}
+
+	if userID == 0 {
+		app.saveSessionDraft(w, r, draft)
+		return
+	}
+
+	// Drafts of edits are only kept for snippets the user may edit.
+	if id := r.PostForm.Get("snippet"); id != "" {
+		snippet, err := app.snippetByPublicID(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		level, err := app.snippets.Permission(snippet.ID, userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if !models.Allows(level, models.PermissionWrite) {
+			app.notFound(w)
+			return
+		}
+
+		draft.SnippetID = snippet.ID
+	}
+
+	if utf8.RuneCountInString(draft.Title) > draftMaxTitleRunes {
+		draft.Title = string([]rune(draft.Title)[:draftMaxTitleRunes])
+	}
+
+	var err error
+	if draft.Title == "" && draft.Content == "" {
+		err = app.drafts.Delete(userID, draft.SnippetID)
+	} else {
+		err = app.drafts.Save(draft)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// saveSessionDraft saves the draft of a new snippet of a visitor who isn't logged in in their
+// session, or discards it if its title and content are empty.
+func (app *application) saveSessionDraft(w http.ResponseWriter, r *http.Request, draft models.Draft) {
+	if r.PostForm.Get("snippet") != "" {
+		app.notFound(w)
+		return
+	}
+	if len(draft.Title)+len(draft.Content) > anonymousMaxContent {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if draft.Title == "" && draft.Content == "" {
+		app.clearDraft(r, 0)
+	} else {
+		app.sessionManager.Put(r.Context(), draftTitleKey, draft.Title)
+		app.sessionManager.Put(r.Context(), draftContentKey, draft.Content)
+		app.sessionManager.Put(r.Context(), draftUpdatedKey, app.clock.Now().Unix())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadDraft returns the current user's draft if it's for the given snippet, or for a new snippet
+// if snippetID is 0, and nil otherwise. A draft that can't be read is logged and ignored, since
+// the form works without it.
+func (app *application) loadDraft(r *http.Request, snippetID int) *models.Draft {
+	if !app.isAuthenticated(r) {
+		if snippetID != 0 || !app.sessionManager.Exists(r.Context(), draftUpdatedKey) {
+			return nil
+		}
+		return &models.Draft{
+			Title:   app.sessionManager.GetString(r.Context(), draftTitleKey),
+			Content: app.sessionManager.GetString(r.Context(), draftContentKey),
+			Updated: time.Unix(app.sessionManager.GetInt64(r.Context(), draftUpdatedKey), 0),
+		}
+	}
+
+	draft, err := app.drafts.Get(app.authenticatedUserID(r))
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.errorLog.Printf("loading draft: %v", err)
+		}
+		return nil
+	}
+
+	if draft.SnippetID != snippetID {
+		return nil
+	}
+
+	return draft
+}
+
+// clearDraft discards the current user's draft for a snippet that was just saved. Failing to is
+// logged rather than reported, since the snippet itself was saved.
+func (app *application) clearDraft(r *http.Request, snippetID int) {
+	if !app.isAuthenticated(r) {
+		for _, key := range []string{draftTitleKey, draftContentKey, draftUpdatedKey} {
+			app.sessionManager.Remove(r.Context(), key)
+		}
+		return
+	}
+
+	if err := app.drafts.Delete(app.authenticatedUserID(r), snippetID); err != nil {
+		app.errorLog.Printf("clearing draft: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"  // Package for iterating over sessions.
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// erasureListLimit is the number of recent erasures listed on the admin page.
+const erasureListLimit = 50
+
+// accountDeleteForm represents the form for deleting one's account. The password is asked again
+// so that an unattended session can't erase an account.
+type accountDeleteForm struct {
+	Password            string `form:"password" validate:"required"`
+	Confirm             bool   `form:"confirm"`
+	validator.Validator `form:"-"`
+}
+
+// adminEraseForm represents the form admins use to erase a user who asked them to.
+type adminEraseForm struct {
+	UserID              int `form:"user_id"`
+	validator.Validator `form:"-"`
+}
+
+// accountDelete serves the "/account/delete" URL, where users delete their account and erase their
+// personal data.
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{}
+
+	app.render(w, r, http.StatusOK, "delete.html", data)
+}
+
+// accountDeletePost erases the current user once they've confirmed it with their password, and
+// logs them out everywhere.
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+	form.CheckField(form.Confirm, "confirm", "Tick the box to confirm that you want to delete your account")
+
+	userID := app.authenticatedUserID(r)
+
+	if form.Valid() {
+		user, err := app.users.Get(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if id, err := app.users.Authenticate(user.Email, form.Password); err != nil || id != userID {
+			if err != nil && !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, r, err)
+				return
+			}
+			form.AddFieldError("password", "Password is incorrect")
+		}
+	}
+
+	if form.Valid() {
+		err := app.eraseUser(userID, userID)
+		switch {
+		case err == nil:
+			// Start a fresh session for the flash, since the user's sessions were destroyed.
+			if err := app.sessionManager.Destroy(r.Context()); err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted and your data erased.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrLastOwner):
+			form.AddNonFieldError("You're the only owner of an organization with other members. Make another member an owner before deleting your account.")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, r, http.StatusUnprocessableEntity, "delete.html", data)
+}
+
+// adminErasures serves the "/admin/erasures" URL. It lists the recent erasures, or those of the
+// address in the "email" query parameter so that admins can confirm an address was erased.
+func (app *application) adminErasures(w http.ResponseWriter, r *http.Request) {
+	app.renderErasures(w, r, http.StatusOK, adminEraseForm{})
+}
+
+// adminErasePost erases the user in the "user_id" form field on behalf of an admin.
+func (app *application) adminErasePost(w http.ResponseWriter, r *http.Request) {
+	var form adminEraseForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckField(form.UserID > 0, "user_id", "Enter the ID of a user")
+
+	if form.Valid() {
+		err := app.eraseUser(form.UserID, app.authenticatedUserID(r))
+		switch {
+		case err == nil:
+			app.sessionManager.Put(r.Context(), "flash", "User "+strconv.Itoa(form.UserID)+" has been erased.")
+			http.Redirect(w, r, "/admin/erasures", http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("user_id", "There's no user with this ID")
+		case errors.Is(err, models.ErrLastOwner):
+			form.AddFieldError("user_id", "This user is the only owner of an organization with other members")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.renderErasures(w, r, http.StatusUnprocessableEntity, form)
+}
+
+// renderErasures renders the admin erasures page with the given form for erasing a user.
+func (app *application) renderErasures(w http.ResponseWriter, r *http.Request, status int, form adminEraseForm) {
+	email := strings.TrimSpace(r.URL.Query().Get("email"))
+
+	var erasures []*models.Erasure
+	var err error
+	if email != "" {
+		erasures, err = app.erasures.ByEmail(email)
+	} else {
+		erasures, err = app.erasures.Recent(erasureListLimit)
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.Erasures = erasures
+	data.ErasureEmail = email
+
+	app.render(w, r, status, "erasures.html", data)
+}
+
+// eraseUser erases a user's personal data and then destroys their sessions. Failing to destroy the
+// sessions is logged rather than reported, since they no longer log anyone in once the user is
+// gone.
+func (app *application) eraseUser(userID, requestedBy int) error {
+	erasure, err := app.erasures.Erase(userID, requestedBy)
+	if err != nil {
+		return err
+	}
+
+	app.infoLog.Printf("erased user %d (erasure %d): %s", userID, erasure.ID, erasure.Summary)
+
+	if err := app.destroyUserSessions(userID); err != nil {
+		app.errorLog.Printf("destroying sessions of erased user %d: %v", userID, err)
+	}
+
+	return nil
+}
+
+// destroyUserSessions destroys the sessions a user is logged in with, by going through every
+// session.
+func (app *application) destroyUserSessions(userID int) error {
+	return app.sessionManager.Iterate(context.Background(), func(ctx context.Context) error {
+		if app.sessionManager.GetInt(ctx, "authenticatedUserID") != userID {
+			return nil
+		}
+		return app.sessionManager.Destroy(ctx)
+	})
+}
@@ -0,0 +1,72 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// snippetStarPost adds a snippet the current user may view to their favorites.
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	app.starAction(w, r, app.favorites.Star, "Snippet added to your favorites.")
+}
+
+// snippetUnstarPost removes a snippet from the favorites of the current user.
+func (app *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	app.starAction(w, r, app.favorites.Unstar, "Snippet removed from your favorites.")
+}
+
+// starAction applies action to the current user and the snippet identified by the "id" URL
+// parameter, if they may view it, and sends them back to the snippet with flash.
+func (app *application) starAction(w http.ResponseWriter, r *http.Request, action func(userID, snippetID int) error, flash string) {
+	snippet, err := app.snippetFromParams(r)
+	if err != nil || !app.canView(r, snippet) {
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+		} else {
+			app.notFound(w)
+		}
+		return
+	}
+
+	if err := action(app.authenticatedUserID(r), snippet.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// accountFavorites serves the "/account/favorites" URL, with the snippets the current user starred
+// that they may still view, most recently starred first.
+func (app *application) accountFavorites(w http.ResponseWriter, r *http.Request) {
+	ids, err := app.favorites.SnippetIDs(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets := []*models.Snippet{}
+	for _, id := range ids {
+		snippet, err := app.snippets.Get(id)
+		if errors.Is(err, models.ErrNoRecord) {
+			continue
+		}
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if app.canView(r, snippet) {
+			snippets = append(snippets, snippet)
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetsData = snippets
+
+	app.render(w, r, http.StatusOK, "favorites.html", data)
+}
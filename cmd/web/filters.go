@@ -0,0 +1,217 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/filter"    // Import the content filter package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// filterHitLimit is the number of recent filter hits listed on the admin page.
+const filterHitLimit = 100
+
+// adminFilterForm represents the form admins add content filter rules with.
+type adminFilterForm struct {
+	Action              string `form:"action" validate:"oneof=hold|shadow|reject"`
+	Kind                string `form:"kind" validate:"oneof=word|regex|urls"`
+	Pattern             string `form:"pattern" validate:"required,maxrunes=255"`
+	validator.Validator `form:"-"`
+}
+
+// screen checks the title and content of a snippet against the blocklist file and the rules admins
+// manage, and returns the strongest verdict. The managed rules are read on every check, so that
+// changes apply on every server straight away.
+func (app *application) screen(texts ...string) (filter.Verdict, error) {
+	rules, err := app.filters.Rules()
+	if err != nil {
+		return filter.Verdict{}, err
+	}
+
+	managed := &filter.Blocklist{}
+	for _, r := range rules {
+		rule, err := compileFilterRule(r)
+		if err != nil {
+			// Rules are compiled before they're saved, so this only happens if the filter package
+			// stopped accepting one. Skip it rather than failing every submission.
+			app.errorLog.Printf("content filter rule %d: %v", r.ID, err)
+			continue
+		}
+		managed.Rules = append(managed.Rules, rule)
+	}
+
+	return filter.Chain{app.contentFilter, managed}.Check(texts...), nil
+}
+
+// compileFilterRule compiles a stored rule with the filter package.
+func compileFilterRule(r *models.FilterRule) (filter.Rule, error) {
+	action, err := filter.ParseAction(r.Action)
+	if err != nil {
+		return filter.Rule{}, err
+	}
+
+	rule, err := filter.NewRule(action, r.Kind, r.Pattern)
+	if err != nil {
+		return filter.Rule{}, err
+	}
+	rule.ID = r.ID
+
+	return rule, nil
+}
+
+// recordFilterHit logs a snippet caught by the content filter and adds it to the audit trail.
+// snippetID is 0 for rejected snippets, which weren't saved.
+func (app *application) recordFilterHit(r *http.Request, verdict filter.Verdict, snippetID int) error {
+	userID := app.authenticatedUserID(r)
+
+	app.infoLog.Printf("Content filter: %s (%s) by user %d%s", verdict.Action, verdict.Rule, userID, logCountry(r))
+
+	return app.filters.RecordHit(models.FilterHit{
+		RuleID:    verdict.RuleID,
+		Rule:      verdict.Rule,
+		Action:    verdict.Action.String(),
+		UserID:    userID,
+		SnippetID: snippetID,
+		Country:   clientCountry(r),
+	})
+}
+
+// applyVerdict holds a saved snippet the content filter caught, and records the hit. Snippets
+// caught by a shadow rule are held too, but marked so that their owner isn't told.
+func (app *application) applyVerdict(r *http.Request, verdict filter.Verdict, snippetID int) error {
+	if verdict.Action == filter.Hold || verdict.Action == filter.Shadow {
+		if err := app.snippets.SetHeld(snippetID, true); err != nil {
+			return err
+		}
+		if err := app.snippets.SetShadowed(snippetID, verdict.Action == filter.Shadow); err != nil {
+			return err
+		}
+	}
+
+	return app.recordVerdict(r, verdict, snippetID)
+}
+
+// newVisibility returns the visibility a new snippet is inserted with: private if its author asked
+// for it, and held if the content filter gave it verdict, so that it's hidden from the start rather
+// than after it was saved.
+func newVisibility(private bool, verdict filter.Verdict) models.Visibility {
+	return models.Visibility{
+		Private:  private,
+		Held:     verdict.Action == filter.Hold || verdict.Action == filter.Shadow,
+		Shadowed: verdict.Action == filter.Shadow,
+	}
+}
+
+// recordVerdict records the hit of a saved snippet the content filter caught, if it caught it.
+func (app *application) recordVerdict(r *http.Request, verdict filter.Verdict, snippetID int) error {
+	if verdict.Action == filter.Allow {
+		return nil
+	}
+
+	return app.recordFilterHit(r, verdict, snippetID)
+}
+
+// adminFilters serves the "/admin/filters" URL, where admins manage the content filter rules and
+// review the snippets they caught.
+func (app *application) adminFilters(w http.ResponseWriter, r *http.Request) {
+	app.renderFilters(w, r, http.StatusOK, adminFilterForm{Action: "hold", Kind: "word"})
+}
+
+// adminFilterPost adds the content filter rule in the form.
+func (app *application) adminFilterPost(w http.ResponseWriter, r *http.Request) {
+	var form adminFilterForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.Pattern = strings.TrimSpace(form.Pattern)
+	form.CheckStruct(form)
+
+	// Compile the rule like the filter will, so that invalid regular expressions and URL counts
+	// are reported here rather than skipped later.
+	if form.Valid() {
+		_, err := compileFilterRule(&models.FilterRule{Action: form.Action, Kind: form.Kind, Pattern: form.Pattern})
+		if err != nil {
+			form.AddFieldError("pattern", strings.TrimPrefix(err.Error(), "filter: "))
+		}
+	}
+
+	if !form.Valid() {
+		app.renderFilters(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	_, err := app.filters.InsertRule(form.Action, form.Kind, form.Pattern, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Filter rule added!")
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
+
+// adminFilterDeletePost serves the "/admin/filters/delete/:id" URL. It deletes a content filter
+// rule; the hits it recorded are kept.
+func (app *application) adminFilterDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.filters.DeleteRule(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Filter rule deleted!")
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
+
+// renderFilters renders the admin filters page with the given form for adding a rule.
+func (app *application) renderFilters(w http.ResponseWriter, r *http.Request, status int, form adminFilterForm) {
+	rules, err := app.filters.Rules()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	hits, err := app.filters.RecentHits(filterHitLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.FilterRules = rules
+	data.FilterHits = hits
+
+	// The rules of the blocklist file are shown for reference; they're changed in the file.
+	if blocklist, ok := app.contentFilter.(*filter.Blocklist); ok {
+		for _, rule := range blocklist.Rules {
+			data.FilterFileRules = append(data.FilterFileRules, rule.String())
+		}
+	}
+
+	app.render(w, r, status, "filters.html", data)
+}
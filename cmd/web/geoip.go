@@ -0,0 +1,106 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"  // Package for passing the country of the client to the handlers.
+	"errors"   // Package for creating error messages.
+	"fmt"      // Package for formatted I/O.
+	"net"      // Package for parsing IP addresses.
+	"net/http" // Package for building HTTP servers and clients.
+
+	"snippetbox.adcon.dev/internal/geoip" // Import the GeoIP package.
+)
+
+// locate is a middleware function that looks up the country of the client in the GeoIP database,
+// for the request log, the audit trails and the access controls of geoRestrict. It's only used
+// when -geoip-db is set.
+func (app *application) locate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country, err := app.geoip.Country(net.ParseIP(clientIP(r)))
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), countryContextKey, country))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientCountry returns the country code of the client, or an empty string if there's no GeoIP
+// database or it doesn't know the client's address.
+func clientCountry(r *http.Request) string {
+	country, _ := r.Context().Value(countryContextKey).(string)
+	return country
+}
+
+// logCountry returns the country of the client to add to log lines, as in " [DE]", or an empty
+// string if it isn't known.
+func logCountry(r *http.Request) string {
+	if country := clientCountry(r); country != "" {
+		return " [" + country + "]"
+	}
+
+	return ""
+}
+
+// geoRestrict is a middleware function for signing up and creating snippets that refuses clients
+// from the countries in -geoip-block, and makes clients from the countries in -geoip-challenge
+// pass human verification. Clients whose country isn't known are let through.
+func (app *application) geoRestrict(next http.Handler) http.Handler {
+	// The lists were validated when the server started.
+	block, _ := geoip.ParseCountries(app.config.GeoIPBlock)
+	challenge, _ := geoip.ParseCountries(app.config.GeoIPChallenge)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country := clientCountry(r)
+
+		switch {
+		case block[country]:
+			app.infoLog.Printf("GeoIP: refused %s %s from %s (%s)", r.Method, r.URL.Path, app.logAddr(r), country)
+
+			if isAPIRequest(r) {
+				app.writeProblem(w, r, http.StatusForbidden, "This isn't available in your country.", r.URL.Path)
+				return
+			}
+			data := app.newTemplateData(r)
+			data.ErrorStatus = http.StatusForbidden
+			data.ErrorDetail = "This isn't available in your country."
+			app.render(w, r, http.StatusForbidden, "error.html", data)
+			return
+		case challenge[country]:
+			r = r.WithContext(context.WithValue(r.Context(), geoChallengeContextKey, true))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// geoChallenged reports whether the client has to pass human verification because of its country.
+func geoChallenged(r *http.Request) bool {
+	challenged, _ := r.Context().Value(geoChallengeContextKey).(bool)
+	return challenged
+}
+
+// checkGeoIP validates the country lists of the GeoIP access controls. The lists need a GeoIP
+// database to locate clients with, and challenges need human verification.
+func checkGeoIP(config configuration) error {
+	block, err := geoip.ParseCountries(config.GeoIPBlock)
+	if err != nil {
+		return fmt.Errorf("-geoip-block: %w", err)
+	}
+	challenge, err := geoip.ParseCountries(config.GeoIPChallenge)
+	if err != nil {
+		return fmt.Errorf("-geoip-challenge: %w", err)
+	}
+
+	if (len(block) > 0 || len(challenge) > 0) && config.GeoIPDB == "" {
+		return errors.New("-geoip-block and -geoip-challenge need -geoip-db")
+	}
+	if len(challenge) > 0 && (config.CaptchaProvider == "" || config.CaptchaProvider == "none") {
+		return errors.New("-geoip-challenge needs -captcha-provider")
+	}
+
+	return nil
+}
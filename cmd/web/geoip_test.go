@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+// stubLocator is a geoip.Locator that puts every client in the same country.
+type stubLocator struct {
+	country string
+}
+
+func (l stubLocator) Country(ip net.IP) (string, error) {
+	return l.country, nil
+}
+
+func TestGeoRestrict(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Blocked", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.geoip = stubLocator{country: "XA"}
+		app.config.GeoIPBlock = "XA,XB"
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, _, body := ts.get(t, "/user/signup")
+		assert.Equal(t, code, http.StatusForbidden)
+		assert.StringContains(t, body, "This isn&#39;t available in your country.")
+
+		// Other pages aren't restricted.
+		code, _, _ = ts.get(t, "/user/login")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Challenged", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.geoip = stubLocator{country: "XA"}
+		app.captcha = stubVerifier{}
+		app.config.CaptchaLoginFailures = 3
+		app.config.GeoIPChallenge = "xa"
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		code, _, body := ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<div class='stub-captcha' data-sitekey='site-key'></div>")
+
+		form := url.Values{"title": {"Hello"}, "content": {"World"}, "expires": {"7"}}
+		code, _, body = ts.postForm(t, "/snippet/create", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "not a robot")
+
+		form.Set("stub-response", "human")
+		code, _, _ = ts.postForm(t, "/snippet/create", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+	})
+
+	t.Run("Other countries", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.geoip = stubLocator{country: "XC"}
+		app.captcha = stubVerifier{}
+		app.config.CaptchaLoginFailures = 3
+		app.config.GeoIPBlock = "XA"
+		app.config.GeoIPChallenge = "XB"
+
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		code, _, body := ts.get(t, "/snippet/create")
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, strings.Contains(body, "stub-captcha"), false)
+	})
+}
+
+func TestCheckGeoIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  configuration
+		wantErr bool
+	}{
+		{"Disabled", configuration{}, false},
+		{"Block", configuration{GeoIPDB: "GeoLite2-Country.mmdb", GeoIPBlock: "CN,RU"}, false},
+		{"Challenge", configuration{GeoIPDB: "GeoLite2-Country.mmdb", GeoIPChallenge: "CN", CaptchaProvider: "turnstile"}, false},
+		{"Invalid code", configuration{GeoIPDB: "GeoLite2-Country.mmdb", GeoIPBlock: "China"}, true},
+		{"No database", configuration{GeoIPBlock: "CN"}, true},
+		{"Challenge without captcha", configuration{GeoIPDB: "GeoLite2-Country.mmdb", GeoIPChallenge: "CN", CaptchaProvider: "none"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGeoIP(tt.config)
+			assert.Equal(t, err != nil, tt.wantErr)
+		})
+	}
+}
@@ -4,50 +4,103 @@ package main
 // Import the necessary packages.
 import (
 	"errors"   // Package for creating error messages.
-	"fmt"      // Package for formatted I/O.
 	"net/http" // Package for building HTTP servers and clients.
+	"slices"   // Package for searching slices.
 	"strconv"  // Package for converting strings to numeric types.
 
 	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
 
-	"snippetbox.adcon.dev/internal/models"    // Import the models package.
-	"snippetbox.adcon.dev/internal/validator" // Import validator package
+	"snippetbox.adcon.dev/internal/filter"      // Import the content filter package.
+	"snippetbox.adcon.dev/internal/fingerprint" // Import the duplicate detection package.
+	"snippetbox.adcon.dev/internal/models"      // Import the models package.
+	"snippetbox.adcon.dev/internal/validator"   // Import validator package
+	"snippetbox.adcon.dev/internal/version"     // Import the build information package.
 )
 
 // snippetCreateForm represents the form that captures user input for creating a new snippet.
 // It includes fields for the title, content, and expiration of the snippet, as well as a Validator
 // for validating the form fields.
 type snippetCreateForm struct {
-	Title               string     `form:"title"`   // Title is the title of the snippet provided by the user.
-	Content             string     `form:"content"` // Content is the actual code snippet provided by the user.
-	Expires             int        `form:"expires"` // Expires is the duration after which the snippet expires.
+	Title               string     `form:"title" validate:"required,maxrunes=100"` // Title is the title of the snippet provided by the user.
+	Content             string     `form:"content" validate:"required"`            // Content is the actual code snippet provided by the user.
+	Expires             int        `form:"expires" validate:"oneof=-1|1|7|365"`    // Expires is the number of days after which the snippet expires, or models.NeverExpires.
+	Private             bool       `form:"private"`                                // Private keeps the snippet out of listings.
+	Org                 int        `form:"org"`                                    // Org is the ID of the organization to own the snippet, or 0.
+	Format              bool       `form:"format"`                                 // Format asks for the content to be formatted before it's saved.
+	AllowDuplicate      bool       `form:"allow_duplicate"`                        // AllowDuplicate publishes the snippet even if a copy is already published.
+	License             string     `form:"license"`                                // License is a well-known license, "custom" or empty.
+	CustomLicense       string     `form:"custom_license" validate:"maxrunes=100"` // CustomLicense names the license when License is "custom".
+	Metadata            string     `form:"metadata"`                               // Metadata holds the snippet's "name: value" pairs, one per line.
+	Language            string     `form:"language"`                               // Language tags the snippet, or is empty to detect it.
+	Slug                string     `form:"slug" validate:"maxrunes=60"`            // Slug is the custom slug of the snippet's /s/ URL, or empty.
 	validator.Validator `form:"-"` // Validator is used to validate the form fields.
 }
 
+// reservedUsernames can't be registered because they clash with routes or could be used to
+// impersonate the site. Users who registered one before it was reserved get no vanity URLs. They
+// can't be chosen as snippet slugs either.
+var reservedUsernames = []string{
+	"admin", "administrator", "api", "static", "login", "logout", "signup", "user", "users",
+	"account", "snippet", "snippets", "profile", "settings", "help", "support", "root", "system",
+	"ping", "snippetbox", "about", "privacy", "terms", "security", "abuse", "postmaster",
+	"webmaster", "www", "mail", "moderator", "staff", "official",
+}
+
 type userSignupForm struct {
-	Name                string `form:"name"`
-	Email               string `form:"email"`
-	Password            string `form:"password"`
+	Name                string `form:"name" validate:"required,maxrunes=255"`
+	Username            string `form:"username" validate:"required,minrunes=3,maxrunes=30,slug"`
+	Email               string `form:"email" validate:"required,email"`
+	Password            string `form:"password" validate:"required,minrunes=8"`
+	ConfirmPassword     string `form:"confirm_password" validate:"required"`
 	validator.Validator `form:"-"`
 }
 
 type userLoginForm struct {
-	Email               string `form:"email"`
-	Password            string `form:"password"`
+	Email               string `form:"email" validate:"required,email"`
+	Password            string `form:"password" validate:"required"`
+	validator.Validator `form:"-"`
+}
+
+type accountPasswordUpdateForm struct {
+	CurrentPassword     string `form:"current_password" validate:"required"`
+	NewPassword         string `form:"new_password" validate:"required,minrunes=8"`
+	ConfirmPassword     string `form:"confirm_password" validate:"required"`
 	validator.Validator `form:"-"`
 }
 
-// home serves the root URL ("/"). It fetches the most recent snippets from the database
-// and renders them on the home page. If an error occurs (for example, a database error),
-// it sends a server error response.
+// homePageSize is the number of snippets listed on a page of the home page.
+const homePageSize = 10
+
+// home serves the root URL ("/"). It fetches a page of the most recent snippets from the database,
+// picked by the "page" query parameter, and renders them on the home page with links to the other
+// pages. If an error occurs (for example, a database error), it sends a server error response.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	// Fetch the latest snippets from the database.
-	// The Latest method is expected to return the most recent snippets.
-	snippets, err := app.snippets.Latest()
+	// The "tab" query parameter switches between the latest and the trending snippets.
+	tab := r.URL.Query().Get("tab")
+	if tab != "trending" {
+		tab = "latest"
+	}
+
+	// The "page" query parameter picks a page of the latest snippets.
+	page, ok := pageParam(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Fetch a page of the latest snippets from the database, or the top of the trending ranking.
+	var snippets []*models.Snippet
+	var total int
+	var err error
+	if tab == "trending" {
+		snippets, err = app.snippets.Trending(homePageSize)
+	} else {
+		snippets, total, err = app.snippets.List(page, homePageSize)
+	}
 
 	// If there's an error (for example, a database error), send a server error response.
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -55,61 +108,242 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// This map will be passed to the template for rendering.
 	data := app.newTemplateData(r)
 	data.SnippetsData = snippets
+	data.Tab = tab
+
+	// Only the latest snippets are paginated; pages past the last one don't exist.
+	if tab == "latest" {
+		data.Pagination = newCountedPagination("/", nil, page, total, homePageSize)
+		if page > data.Pagination.Pages {
+			app.notFound(w)
+			return
+		}
+	}
 
 	// Render the home page with the snippets.
 	// The render method is expected to render the "home.html" template with the provided data.
-	app.render(w, http.StatusOK, "home.html", data)
+	app.render(w, r, http.StatusOK, "home.html", data)
 }
 
 // snippetView serves the "/snippet/view" URL. It fetches a snippet with a given ID from the database
 // and renders it on the page. If the snippet is not found or an error occurs, it sends an appropriate HTTP response.
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
-	params := httprouter.ParamsFromContext(r.Context())
-
-	id, err := strconv.Atoi(params.ByName("id"))
-	// If the ID is not a valid integer or is less than 1, respond with a 404 status.
-	if err != nil || id < 1 {
-		app.notFound(w)
+	// Fetch the snippet identified by the "id" URL parameter from the database, if the user may
+	// see it.
+	snippet, ok := app.viewableSnippet(w, r, httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if !ok {
 		return
 	}
 
-	// Fetch the snippet with the given ID from the database.
-	snippet, err := app.snippets.Get(id)
-	// If an error occurs, handle it appropriately.
+	// Send snippets that have a vanity URL there, for now: it changes with the title.
+	vanity, err := app.snippetVanityURL(snippet)
 	if err != nil {
-		// If no snippet with the given ID was found, respond with a 404 status.
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			// For any other kind of error, respond with a 500 status.
-			app.serverError(w, err)
-		}
+		app.serverError(w, r, err)
 		return
 	}
+	if vanity != "" {
+		redirectKeepingQuery(w, r, vanity, http.StatusFound)
+		return
+	}
+
+	app.showSnippet(w, r, snippet)
+}
+
+// showSnippet renders the page of a snippet the visitor may view.
+func (app *application) showSnippet(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	var err error
+
+	// Count the view and log the access in the background.
+	app.recordView(r, snippet.ID)
+	app.recordAccess(r, snippet)
 
 	// If no error occurs, create a new template data map and add the snippet to it.
 	data := app.newTemplateData(r)
 	data.SnippetData = snippet
 
+	// Mark the lines selected with the "lines" query parameter, and give the link to them.
+	data.Permalink = "/snippet/view/" + snippet.PublicID()
+	data.Lines = selectedLines(r.URL.Query().Get("lines"), snippet.Stats.Lines)
+	if data.Lines != nil {
+		data.LineLink = lineLink(data.Permalink, *data.Lines)
+	}
+
+	data.Metadata, err = app.snippets.Metadata(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Show the owner of the snippet how often it has been viewed recently, and let them manage
+	// whether it's private and who it's shared with.
+	if userID := app.authenticatedUserID(r); userID != 0 && userID == snippet.OwnerID {
+		data.IsOwner = true
+
+		data.ViewStats, err = app.views.SnippetStats(snippet.ID, statsDays)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if snippet.Private {
+			data.Shares, err = app.shareLinks(r, snippet)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+	}
+
+	// Show how many users starred the snippet, and whether the current user did.
+	data.Stars, err = app.favorites.Count(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if userID := app.authenticatedUserID(r); userID != 0 {
+		data.Starred, err = app.favorites.Starred(userID, snippet.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Show the short link of the snippet if it has one, and how often it was followed.
+	link, err := app.shortLinks.ForSnippet(snippet.ID)
+	switch {
+	case err == nil:
+		data.ShortLink = shortLinkURL(r, link)
+		data.ShortLinkClicks = link.Clicks
+	case !errors.Is(err, models.ErrNoRecord):
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Name the organization that owns the snippet, if any.
+	if snippet.OrgID != 0 {
+		data.Organization, err = app.organizations.Get(snippet.OrgID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Offer to edit the snippet to those who may write it and to admins, and to change who may
+	// access it to those who can manage an organization's snippet.
+	if userID := app.authenticatedUserID(r); userID != 0 {
+		level, err := app.snippets.Permission(snippet.ID, userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data.CanEdit = models.Allows(level, models.PermissionWrite) || app.isAdmin(r)
+
+		if level == models.PermissionManage && snippet.OrgID != 0 {
+			data.Permissions, err = app.permissionRows(snippet)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+		}
+	}
+
+	// Offer logged-in users to add the snippet to one of their collections.
+	if userID := app.authenticatedUserID(r); userID != 0 {
+		data.Collections, err = app.collections.ByOwner(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Let sites that unfurl links with oEmbed find the description of a public snippet.
+	if !snippet.Held && !snippet.Private {
+		data.OEmbed = oembedDiscovery(r, data.Permalink)
+	}
+
+	// Show the pages of other sites that mention a public snippet, and tell them where to send
+	// mentions. A page changes when a mention is verified, even if the snippet doesn't.
+	modified := lastModified(snippet)
+	if app.config.Webmentions && !snippet.Held && !snippet.Private {
+		w.Header().Add("Link", `</webmention>; rel="webmention"`)
+
+		data.Webmentions, err = app.webmentions.BySnippet(snippet.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		for _, m := range data.Webmentions {
+			if m.Verified.After(modified) {
+				modified = m.Verified
+			}
+		}
+	}
+
+	// Let browsers and caches revalidate the page instead of fetching it again. A page showing a
+	// flash message is only meant for the visitor it was shown to.
+	cacheControl := app.snippetCacheControl(r, snippet)
+	if cacheControl == cachePublic && data.Flash != "" {
+		cacheControl = cachePrivate
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if cacheControl == cacheNoStore {
+		app.render(w, r, http.StatusOK, "view.html", data)
+		return
+	}
+
 	// Render the "view.html" template with the provided data.
-	app.render(w, http.StatusOK, "view.html", data)
+	app.renderCacheable(w, r, "view.html", data, modified)
 }
 
 // snippetCreate serves the "/snippet/create" URL. It initializes a new snippetCreateForm
-// with a default expiration of 365 days and renders the "create.html" template.
+// with the user's default expiration, visibility and language and renders the "create.html" template.
 // This method is used to display the form for creating a new snippet.
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// Create a new template data map.
 	data := app.newTemplateData(r)
 
-	// Initialize a new snippetCreateForm with a default expiration of 365 days.
-	data.Form = snippetCreateForm{
-		Expires: 365,
+	// Initialize a new snippetCreateForm with the defaults the user chose, a year before the
+	// snippet expires unless they chose otherwise. Visitors who aren't logged in get the longest
+	// time anonymous snippets are kept.
+	form := snippetCreateForm{Expires: anonymousMaxExpires}
+
+	if app.isAuthenticated(r) {
+		preferences, err := app.preferences.Get(app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.Expires = preferences.DefaultExpires
+		form.Private = preferences.DefaultPrivate
+		form.Language = preferences.DefaultLanguage
+	}
+
+	// Pick up where the user left off if they have a draft of a new snippet.
+	if draft := app.loadDraft(r, 0); draft != nil {
+		form.Title, form.Content = draft.Title, draft.Content
+		data.Draft = draft
+	}
+
+	data.Form = form
+
+	// Offer the organizations of the user as owners of the snippet.
+	var err error
+	data.Organizations, err = app.organizations.ByMember(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Visitors who aren't logged in and clients from the countries in -geoip-challenge have to
+	// pass human verification.
+	if app.createNeedsCaptcha(r) {
+		app.showCaptcha(w, r, data)
 	}
 
 	// Render the "create.html" template with the provided data.
-	app.render(w, http.StatusOK, "create.html", data)
+	app.render(w, r, http.StatusOK, "create.html", data)
 }
 
 // snippetCreatePost serves the "/snippet/create" URL for POST requests. It validates the form data
@@ -121,84 +355,246 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 	var form snippetCreateForm
 
-	err := app.decodePostForm(r, &form)
+	err := app.decodePostForm(w, r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, err)
+		return
+	}
+
+	// Validate the form values against the rules declared on the form struct.
+	form.CheckStruct(form)
+	app.checkSize(&form.Validator, form.Title, form.Content)
+	license := checkLicense(&form.Validator, form.License, form.CustomLicense)
+	metadata := checkMetadata(&form.Validator, form.Metadata)
+	form.CheckField(validLanguage(form.Language), "language", "Choose a language from the list")
+	if !app.isAuthenticated(r) {
+		checkAnonymousSnippet(&form)
+	}
+	if form.Slug != "" {
+		if err := app.checkSlug(&form.Validator, form.Slug); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Screen the title and content against the content filter.
+	verdict, err := app.screen(form.Title, form.Content)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if verdict.Action == filter.Reject {
+		if err := app.recordFilterHit(r, verdict, 0); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.AddNonFieldError("This snippet contains content that isn't allowed")
+	}
+
+	// Only members can put snippets in an organization.
+	orgs, err := app.organizations.ByMember(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
+	if form.Org != 0 && !slices.ContainsFunc(orgs, func(o *models.Organization) bool { return o.ID == form.Org }) {
+		form.AddNonFieldError("You aren't a member of this organization")
+	}
 
-	// Validate the form values.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxRunes(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.AllowedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	if app.createNeedsCaptcha(r) {
+		if err := app.checkCaptcha(r, &form.Validator); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
 	// If the form is not valid, re-render the form with error messages.
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		data.Organizations = orgs
+		if app.createNeedsCaptcha(r) {
+			app.showCaptcha(w, r, data)
+		}
+		app.render(w, r, http.StatusUnprocessableEntity, "create.html", data)
 		return
 	}
 
-	// Insert the new snippet into the database.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// Format the content if the user asked for it. Content that can't be formatted is saved as
+	// written, with a warning.
+	var warning string
+	if form.Format {
+		form.Content, warning = formatContent(form.Title, form.Content)
+	}
+
+	// Point the user at an already published copy of the snippet rather than publishing another,
+	// unless they were shown it and chose to publish theirs anyway.
+	if !form.AllowDuplicate {
+		duplicate, err := app.snippets.Duplicate(form.Content)
+		switch {
+		case err == nil:
+			data := app.newTemplateData(r)
+			data.Form = form
+			data.Organizations = orgs
+			data.Duplicate = duplicate
+			data.DuplicateExact = fingerprint.Hash(duplicate.Content) == fingerprint.Hash(form.Content)
+			if app.createNeedsCaptcha(r) {
+				app.showCaptcha(w, r, data)
+			}
+			app.render(w, r, http.StatusOK, "create.html", data)
+			return
+		case !errors.Is(err, models.ErrNoRecord):
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Insert the new snippet into the database, recording the current user as its last writer.
+	// Private snippets are kept out of listings, and snippets the content filter caught are held for
+	// moderation, from the start.
+	id, err := app.snippets.InsertWith(form.Title, form.Content, form.Expires, app.authenticatedUserID(r), newVisibility(form.Private, verdict))
 	// If there's an error (for example, a database error), send a server error response.
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Record the creating client for abuse handling, if the deployment allows it.
+	if app.config.CaptureClientInfo {
+		err = app.snippets.RecordClient(id, app.storedIP(r), r.UserAgent())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if license != "" {
+		err = app.snippets.SetLicense(id, license)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if len(metadata) > 0 {
+		err = app.snippets.SetMetadata(id, metadata)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Let the members of the chosen organization see and find the snippet.
+	if form.Org != 0 {
+		err = app.snippets.SetOrg(id, form.Org)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Another snippet may have taken the slug since it was checked, in which case the snippet is
+	// published without one.
+	if form.Slug != "" {
+		err = app.snippets.SetSlug(id, form.Slug)
+		if errors.Is(err, models.ErrDuplicateSlug) {
+			warning = withWarning("The custom URL was taken in the meantime, so the snippet has none.", warning)
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// Tag the snippet with the language the author chose, or else the language of its content.
+	if form.Language != "" {
+		err = app.snippets.SetLanguage(id, form.Language)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	} else {
+		app.detectLanguage(id, "", form.Title, form.Content)
+	}
+
+	// The draft has been published.
+	app.clearDraft(r, 0)
+
+	// The snippet was inserted held if the filter asked for it. Snippets caught by a shadow rule are
+	// held as well, but their author is told it was created as usual.
+	if err := app.recordVerdict(r, verdict, id); err != nil {
+		app.serverError(w, r, err)
 		return
 	}
+	app.sendWebmentions(id)
 
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	if verdict.Action == filter.Hold {
+		app.sessionManager.Put(r.Context(), "flash", withWarning("Snippet created! It will be listed once a moderator has reviewed it.", warning))
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", withWarning("Snippet successfully created!", warning))
+	}
 
 	// If there's no error, the snippet was inserted successfully.
-	// Redirect the client to the page for the new snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	// Redirect the client to the page for the new snippet, by its public ID.
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
 }
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
+	app.showCaptcha(w, r, data)
 
-	app.render(w, http.StatusOK, "signup.html", data)
+	app.render(w, r, http.StatusOK, "signup.html", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 	var form userSignupForm
 
-	err := app.decodePostForm(r, &form)
+	err := app.decodePostForm(w, r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, err)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.MaxRunes(form.Name, 255), "name", "Field is too long (255)")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinRunes(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckStruct(form)
+	form.CheckField(!validator.OneOfString(form.Username, reservedUsernames...), "username", "This username is reserved")
+	form.CheckField(validator.Equal(form.Password, form.ConfirmPassword), "confirm_password", "Passwords do not match")
+
+	if err := app.checkCaptcha(r, &form.Validator); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
+		app.showCaptcha(w, r, data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.html", data)
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	err = app.users.Insert(form.Name, form.Username, form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
+		switch {
+		case errors.Is(err, models.ErrDuplicateEmail):
 			form.AddFieldError("email", "Email address is already in use")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
-		} else {
-			app.serverError(w, err)
+		case errors.Is(err, models.ErrDuplicateUsername):
+			form.AddFieldError("username", "Username is already taken")
+		default:
+			app.serverError(w, r, err)
+			return
 		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.showCaptcha(w, r, data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.html", data)
 		return
 	}
 	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
@@ -210,29 +606,42 @@ func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
+	if app.loginNeedsCaptcha(r, "") {
+		app.showCaptcha(w, r, data)
+	}
 
-	app.render(w, http.StatusOK, "login.html", data)
+	app.render(w, r, http.StatusOK, "login.html", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 	var form userLoginForm
 
-	err := app.decodePostForm(r, &form)
+	err := app.decodePostForm(w, r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.formError(w, err)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckStruct(form)
+
+	// After too many failed attempts, a person has to prove they're not a bot guessing passwords.
+	if app.loginNeedsCaptcha(r, form.Email) {
+		if err := app.checkCaptcha(r, &form.Validator); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
+		if app.loginNeedsCaptcha(r, form.Email) {
+			app.showCaptcha(w, r, data)
+		}
 
-		app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.html", data)
+		return
 	}
 
 	id, err := app.users.Authenticate(form.Email, form.Password)
@@ -240,22 +649,32 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Email or password is incorrect")
 
+			// Failures are counted on the server, for the client and the account, so that a bot
+			// can't start over by dropping its session cookie.
+			app.loginFailures.add(app.clock.Now(), loginFailureKeys(r, form.Email)...)
+
 			data := app.newTemplateData(r)
 			data.Form = form
+			if app.loginNeedsCaptcha(r, form.Email) {
+				app.showCaptcha(w, r, data)
+			}
 
-			app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.html", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
+	// The account's failures are forgotten, but not the client's: one account of its own would
+	// otherwise let a bot reset its count between guesses at others.
+	app.loginFailures.reset(accountFailureKey(form.Email))
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
 
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
@@ -263,9 +682,16 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 
-	err := app.sessionManager.RenewToken(r.Context())
+	// Logging out of an impersonation ends it, and logs the admin out too.
+	_, err := app.endImpersonation(r, "logged out while")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -276,6 +702,293 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// adminDashboard serves the "/admin" URL. It shows the site-wide daily views over the last days
+// and links to the other admin pages, and lets admins impersonate users.
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	app.renderAdminDashboard(w, r, http.StatusOK, adminImpersonateForm{})
+}
+
+// renderAdminDashboard renders the admin dashboard with the given form for impersonating a user.
+func (app *application) renderAdminDashboard(w http.ResponseWriter, r *http.Request, status int, form adminImpersonateForm) {
+	stats, err := app.views.SiteStats(statsDays)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	impersonations, err := app.impersonations.Recent(impersonationListLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ViewStats = stats
+	data.Form = form
+	data.Impersonations = impersonations
+
+	app.render(w, r, status, "admin.html", data)
+}
+
+// adminModeration serves the "/admin/moderation" URL. It lists the most recently created snippets,
+// including expired ones, together with the client information recorded when they were created.
+func (app *application) adminModeration(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Recent(50)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetsData = snippets
+
+	app.render(w, r, http.StatusOK, "moderation.html", data)
+}
+
+// userCheck serves the "/user/check" URL. It reports whether the email address and/or username in
+// the query string are still available, so that the signup form can warn about taken values before
+// it's submitted. Values that aren't valid are reported as unavailable along with the reason.
+func (app *application) userCheck(w http.ResponseWriter, r *http.Request) {
+	type availability struct {
+		Available bool   `json:"available"`
+		Message   string `json:"message,omitempty"`
+	}
+
+	query := r.URL.Query()
+	response := map[string]availability{}
+
+	if query.Has("email") {
+		email := query.Get("email")
+
+		if !validator.Matches(email, validator.EmailRX) {
+			response["email"] = availability{Message: "This field must be a valid email address"}
+		} else {
+			exists, err := app.users.ExistsByEmail(email)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			if exists {
+				response["email"] = availability{Message: "Email address is already in use"}
+			} else {
+				response["email"] = availability{Available: true}
+			}
+		}
+	}
+
+	if query.Has("username") {
+		username := query.Get("username")
+
+		switch {
+		case !validator.IsSlug(username):
+			response["username"] = availability{Message: "This field may only contain lowercase letters, digits and hyphens"}
+		case validator.OneOfString(username, reservedUsernames...):
+			response["username"] = availability{Message: "This username is reserved"}
+		default:
+			exists, err := app.users.ExistsByUsername(username)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			if exists {
+				response["username"] = availability{Message: "Username is already taken"}
+			} else {
+				response["username"] = availability{Available: true}
+			}
+		}
+	}
+
+	if len(response) == 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, response)
+}
+
+// userProfile serves the "/~:username" URL. It shows the public details of a user and their most
+// recent snippets.
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	username := httprouter.ParamsFromContext(r.Context()).ByName("username")
+
+	if !hasVanityURL(username) {
+		app.notFound(w)
+		return
+	}
+
+	app.showProfile(w, r, username)
+}
+
+// showProfile renders the profile page of the user with the given username.
+func (app *application) showProfile(w http.ResponseWriter, r *http.Request, username string) {
+	user, err := app.users.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	snippets, err := app.snippets.ByOwner(user.ID, 50)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.SnippetsData = snippets
+
+	app.render(w, r, http.StatusOK, "profile.html", data)
+}
+
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+
+	app.render(w, r, http.StatusOK, "password.html", data)
+}
+
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+
+	var form accountPasswordUpdateForm
+
+	err := app.decodePostForm(w, r, &form)
+	if err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+	form.CheckField(validator.Equal(form.NewPassword, form.ConfirmPassword), "confirm_password", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.html", data)
+		return
+	}
+
+	err = app.users.PasswordUpdate(app.authenticatedUserID(r), form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("current_password", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// adminSnippetApprovePost serves the "/admin/snippet/approve/:id" URL. It releases a snippet that
+// was held or shadow-hidden by the content filter so that it appears in listings.
+func (app *application) adminSnippetApprovePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.snippets.SetHeld(id, false)
+	if err == nil {
+		err = app.snippets.SetShadowed(id, false)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet approved!")
+
+	http.Redirect(w, r, "/admin/moderation", http.StatusSeeOther)
+}
+
+// adminSnippetPinPost serves the "/admin/snippet/pin/:id" URL. The "pinned" form field says
+// whether the snippet is pinned to the top of the home page or unpinned.
+func (app *application) adminSnippetPinPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	pinned, err := strconv.ParseBool(r.PostForm.Get("pinned"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.SetPinned(id, pinned)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if pinned {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet pinned to the home page!")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet unpinned.")
+	}
+
+	http.Redirect(w, r, "/admin/moderation", http.StatusSeeOther)
+}
+
 func ping(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte("OK"))
 }
+
+// healthz serves the "/healthz" URL for load balancers and monitoring. It reports the build that's
+// running and whether the database is reachable, with a 503 status if it isn't, and the state of
+// the off-site backups if they're enabled. Failing backups don't change the status, since the
+// server can still serve requests.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	health := struct {
+		Status string        `json:"status"`
+		Build  version.Info  `json:"build"`
+		Backup *backupHealth `json:"backup,omitempty"`
+	}{Status: "ok", Build: version.Get()}
+
+	if app.backups != nil {
+		health.Backup = app.backups.health()
+	}
+
+	status := http.StatusOK
+
+	if app.pingDB != nil {
+		if err := app.pingDB(); err != nil {
+			app.errorLog.Printf("health check: %v", err)
+			health.Status = "database unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	app.writeJSON(w, r, status, health)
+}
@@ -1,11 +1,33 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/filter"
+	"snippetbox.adcon.dev/internal/mailer"
+	"snippetbox.adcon.dev/internal/models"
+	"snippetbox.adcon.dev/internal/models/mocks"
+	"snippetbox.adcon.dev/internal/version"
+	"snippetbox.adcon.dev/ui"
 )
 
 func TestPing(t *testing.T) {
@@ -21,12 +43,62 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, body, "OK")
 }
 
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		pingDB     func() error
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "Healthy",
+			pingDB:     func() error { return nil },
+			wantCode:   http.StatusOK,
+			wantStatus: `"status":"ok"`,
+		},
+		{
+			name:       "Database down",
+			pingDB:     func() error { return errors.New("connection refused") },
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: `"status":"database unavailable"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.pingDB = tt.pingDB
+			app.config.VersionHeader = true
+
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			code, header, body := ts.get(t, "/healthz")
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.StringContains(t, body, tt.wantStatus)
+			assert.StringContains(t, body, `"version":"`+version.Get().Version+`"`)
+			assert.Equal(t, header.Get("X-App-Version"), version.Get().Version)
+		})
+	}
+}
+
 func TestSnippetView(t *testing.T) {
 
 	t.Parallel()
 
 	app := newTestApplication(t)
 
+	// Snippets without an owner have no vanity URL, so they're shown at their ID-based URL.
+	// TestSnippetViewRedirect covers the redirect of those that have one.
+	snippet, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	anonymous := *snippet
+	anonymous.OwnerID = 0
+	app.snippets.(*mocks.SnippetModel).Add(&anonymous)
+
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
@@ -42,6 +114,23 @@ func TestSnippetView(t *testing.T) {
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Valid ULID",
+			urlPath:  "/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Statistics",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<span>1 line</span>\n                    <span>21 characters</span>",
+		},
+		{
+			name:     "Non-existent ULID",
+			urlPath:  "/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W7",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -83,6 +172,185 @@ func TestSnippetView(t *testing.T) {
 
 }
 
+func TestSnippetViewRedirect(t *testing.T) {
+
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const vanity = "/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond"
+
+	// The redirect is temporary, since the vanity URL changes with the title.
+	code, header, _ := ts.get(t, "/snippet/view/1?tab=raw")
+	assert.Equal(t, code, http.StatusFound)
+	assert.Equal(t, header.Get("Location"), vanity+"?tab=raw")
+
+	code, _, body := ts.get(t, header.Get("Location"))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond...")
+}
+
+func TestSnippetViewCaching(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	id := snippets.Add(&models.Snippet{
+		Title:   "Cached",
+		Content: "Seen before",
+		Created: created,
+		Expires: created.Add(30 * 24 * time.Hour),
+		OwnerID: 1,
+	})
+	private := snippets.Add(&models.Snippet{
+		Title:   "Not cached",
+		Content: "Never stored",
+		Created: created,
+		Expires: created.Add(30 * 24 * time.Hour),
+		OwnerID: 1,
+		Private: true,
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	path := "/~alice/" + strconv.Itoa(id) + "-cached"
+
+	code, header, body := ts.get(t, path)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Seen before")
+	assert.Equal(t, header.Get("Cache-Control"), "public, no-cache")
+	assert.Equal(t, header.Get("Last-Modified"), "Sat, 01 Jun 2030 12:00:00 GMT")
+	etag := header.Get("ETag")
+	assert.Equal(t, len(etag), etagLength+2)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+	}{
+		{"Matching ETag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"Weak ETag", http.Header{"If-None-Match": {"W/" + etag}}, http.StatusNotModified},
+		{"ETag in list", http.Header{"If-None-Match": {`"other", ` + etag}}, http.StatusNotModified},
+		{"Other ETag", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"Other ETag wins over date", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {"Sat, 01 Jun 2030 12:00:00 GMT"}}, http.StatusOK},
+		{"Not modified since", http.Header{"If-Modified-Since": {"Sat, 01 Jun 2030 12:00:00 GMT"}}, http.StatusNotModified},
+		{"Modified since", http.Header{"If-Modified-Since": {"Sat, 01 Jun 2030 11:59:59 GMT"}}, http.StatusOK},
+		{"Invalid date", http.Header{"If-Modified-Since": {"yesterday"}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.request(t, http.MethodGet, path, tt.header)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("ETag"), etag)
+
+			// The nonce differs on every request, but clients with the page keep the policy
+			// they got with it.
+			if tt.wantCode == http.StatusNotModified {
+				assert.Equal(t, body, "")
+				assert.Equal(t, header.Get("Content-Security-Policy"), "")
+			} else {
+				assert.StringContains(t, header.Get("Content-Security-Policy"), "'nonce-")
+			}
+		})
+	}
+
+	// Pages of logged-in users are kept out of shared caches, and private snippets aren't stored.
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, header, _ = ts.get(t, path)
+	assert.Equal(t, header.Get("Cache-Control"), "private, no-cache")
+
+	_, header, _ = ts.follow(t, "/snippet/view/"+strconv.Itoa(private))
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+	assert.Equal(t, header.Get("ETag"), "")
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Pages render the error page with the methods the path takes.
+	code, header, body := ts.request(t, http.MethodDelete, "/user/login", nil)
+	assert.Equal(t, code, http.StatusMethodNotAllowed)
+	assert.Equal(t, header.Get("Allow"), "GET, OPTIONS, POST")
+	assert.StringContains(t, header.Get("Content-Type"), "text/html")
+	assert.StringContains(t, body, "<h2>Method Not Allowed</h2>")
+	assert.StringContains(t, body, "DELETE isn&#39;t supported here; use GET, OPTIONS, POST.")
+
+	// The API answers with problem details.
+	code, header, body = ts.request(t, http.MethodGet, "/api/v1/shortlinks", nil)
+	assert.Equal(t, code, http.StatusMethodNotAllowed)
+	assert.Equal(t, header.Get("Allow"), "OPTIONS, POST")
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+
+	var p problem
+	assert.NilError(t, json.Unmarshal([]byte(body), &p))
+	assert.Equal(t, p, problem{
+		Type:     "about:blank",
+		Title:    "Method Not Allowed",
+		Status:   http.StatusMethodNotAllowed,
+		Detail:   "GET isn't supported here; use OPTIONS, POST.",
+		Instance: "/api/v1/shortlinks",
+	})
+
+	// OPTIONS lists the methods without a body.
+	code, header, body = ts.request(t, http.MethodOptions, "/snippet/view/1", nil)
+	assert.Equal(t, code, http.StatusNoContent)
+	assert.Equal(t, header.Get("Allow"), "GET, OPTIONS")
+	assert.Equal(t, body, "")
+
+	// Paths that aren't routed at all are still not found.
+	code, _, _ = ts.request(t, http.MethodDelete, "/missing", nil)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestServerError(t *testing.T) {
+
+	t.Parallel()
+
+	app := newTestApplication(t)
+	var logged strings.Builder
+	app.errorLog = log.New(&logged, "", 0)
+
+	// Pages show the incident ID, which is logged with the request and the error.
+	rr := httptest.NewRecorder()
+	app.serverError(rr, httptest.NewRequest(http.MethodGet, "/snippet/view/1?tab=raw", nil), errors.New("database on fire"))
+
+	incident := rr.Header().Get("X-Incident-Id")
+	assert.Equal(t, regexp.MustCompile(`^[0-9A-Z]{26}$`).MatchString(incident), true)
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.StringContains(t, rr.Header().Get("Content-Type"), "text/html")
+	assert.StringContains(t, rr.Body.String(), "<h2>Internal Server Error</h2>")
+	assert.StringContains(t, rr.Body.String(), "<code>"+incident+"</code>")
+	assert.StringContains(t, logged.String(), "incident "+incident+": GET /snippet/view/1?tab=raw from 192.0.2.1:1234 (anonymous): database on fire\n")
+	assert.StringContains(t, logged.String(), "runtime/debug.Stack")
+
+	// The API puts it in the problem details.
+	rr = httptest.NewRecorder()
+	app.serverError(rr, httptest.NewRequest(http.MethodPost, "/api/v1/shortlinks", nil), errors.New("database on fire"))
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/problem+json")
+
+	var p problem
+	assert.NilError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	assert.Equal(t, p.Incident, rr.Header().Get("X-Incident-Id"))
+	assert.Equal(t, p.Instance, "/api/v1/shortlinks")
+	assert.Equal(t, p.Incident != incident, true)
+}
+
 func TestUserSignup(t *testing.T) {
 	t.Parallel()
 
@@ -95,6 +363,7 @@ func TestUserSignup(t *testing.T) {
 
 	const (
 		validName     = "Bob"
+		validUsername = "bob"
 		validPassword = "validPa$$word"
 		validEmail    = "bob@example.com"
 		formTag       = "<form action='/user/signup' method='POST' novalidate>"
@@ -103,8 +372,10 @@ func TestUserSignup(t *testing.T) {
 	tests := []struct {
 		name         string
 		userName     string
+		userUsername string
 		userEmail    string
 		userPassword string
+		userConfirm  string
 		pattern      string
 		wantCode     int
 		wantFormTag  string
@@ -112,16 +383,20 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Valid submission",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: validPassword,
+			userConfirm:  validPassword,
 			pattern:      validPattern,
 			wantCode:     http.StatusSeeOther,
 		},
 		{
 			name:         "Empty name",
 			userName:     "",
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: validPassword,
+			userConfirm:  validPassword,
 			pattern:      validPattern,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -129,8 +404,10 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Empty email",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    "",
 			userPassword: validPassword,
+			userConfirm:  validPassword,
 			pattern:      validPattern,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -138,8 +415,10 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Empty password",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: "",
+			userConfirm:  "",
 			pattern:      validPattern,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -147,8 +426,10 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Invalid email",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    "bob@example.",
 			userPassword: validPassword,
+			userConfirm:  validPassword,
 			pattern:      validPattern,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -156,8 +437,54 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Short password",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    validEmail,
 			userPassword: "pa$$",
+			userConfirm:  "pa$$",
+			pattern:      validPattern,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Mismatched confirmation",
+			userName:     validName,
+			userUsername: validUsername,
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			userConfirm:  "otherPa$$word",
+			pattern:      validPattern,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Invalid username",
+			userName:     validName,
+			userUsername: "Bob Smith",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			userConfirm:  validPassword,
+			pattern:      validPattern,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Reserved username",
+			userName:     validName,
+			userUsername: "admin",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			userConfirm:  validPassword,
+			pattern:      validPattern,
+			wantCode:     http.StatusUnprocessableEntity,
+			wantFormTag:  formTag,
+		},
+		{
+			name:         "Duplicate username",
+			userName:     validName,
+			userUsername: "dupe",
+			userEmail:    validEmail,
+			userPassword: validPassword,
+			userConfirm:  validPassword,
 			pattern:      validPattern,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -165,8 +492,10 @@ func TestUserSignup(t *testing.T) {
 		{
 			name:         "Duplicate email",
 			userName:     validName,
+			userUsername: validUsername,
 			userEmail:    "dupe@example.com",
 			userPassword: validPassword,
+			userConfirm:  validPassword,
 			pattern:      validPattern,
 			wantCode:     http.StatusUnprocessableEntity,
 			wantFormTag:  formTag,
@@ -177,8 +506,10 @@ func TestUserSignup(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			form := url.Values{}
 			form.Add("name", tt.userName)
+			form.Add("username", tt.userUsername)
 			form.Add("email", tt.userEmail)
 			form.Add("password", tt.userPassword)
+			form.Add("confirm_password", tt.userConfirm)
 			form.Add("pattern", tt.pattern)
 
 			code, _, body := ts.postForm(t, "/user/signup", form)
@@ -192,3 +523,3147 @@ func TestUserSignup(t *testing.T) {
 		})
 	}
 }
+
+func TestAdminModeration(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/admin/moderation")
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	// The recorded client of a snippet is shown as text, whatever its user agent holds.
+	assert.NilError(t, app.snippets.RecordClient(1, "192.0.2.1", "<script>alert(1)</script>"))
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin/moderation")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>&lt;script&gt;alert(1)&lt;/script&gt;</td>")
+	assert.Equal(t, strings.Contains(body, "<script>alert(1)"), false)
+}
+
+func TestAdminSnippetApprove(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.postForm(t, "/admin/snippet/approve/1", url.Values{})
+
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+}
+
+func TestAdminImpersonate(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	frozen := clock.NewFrozen(time.Now())
+	app.clock = frozen
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// Admins can't impersonate themselves or other admins.
+	code, _, body := ts.postForm(t, "/admin/impersonate", url.Values{"username": {"alice"}, "reason": {"Testing"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You can&#39;t impersonate yourself")
+
+	code, _, body = ts.postForm(t, "/admin/impersonate", url.Values{"username": {"dupe"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	code, header, _ := ts.postForm(t, "/admin/impersonate", url.Values{"username": {"~dupe"}, "reason": {"Ticket #42"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/")
+
+	// The session is the user's, under a banner.
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "You're impersonating <strong>dupe</strong>")
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+
+	// Sensitive account actions are refused, starting with API tokens, which would outlive the
+	// impersonation.
+	code, _, _ = ts.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusOK)
+	for _, path := range []string{"/account/tokens", "/account/data-export", "/account/import", "/account/delete", "/account/preferences", "/account/password/update"} {
+		code, _, _ = ts.postForm(t, path, url.Values{})
+		assert.Equal(t, code, http.StatusForbidden)
+	}
+	code, _, _ = ts.postForm(t, "/account/tokens", url.Values{"name": {"Backdoor"}, "scope": {"write"}})
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _, _ = ts.get(t, "/account/data-export/download/1")
+	assert.Equal(t, code, http.StatusForbidden)
+	tokens, err := app.tokens.ByUser(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 0)
+
+	code, header, _ = ts.postForm(t, "/impersonation/stop", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/admin")
+
+	code, _, body = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "You're impersonating"), false)
+	assert.StringContains(t, body, "<td>Ticket #42</td>")
+
+	recent, err := app.impersonations.Recent(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(recent), 1)
+	assert.Equal(t, recent[0].AdminID, 1)
+	assert.Equal(t, recent[0].UserID, 2)
+	assert.Equal(t, recent[0].Ended.IsZero(), false)
+
+	// Every request made during the impersonation is recorded, refused ones too, up to stopping it.
+	actions, err := app.impersonations.Actions(recent[0].ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(actions), 12)
+	assert.Equal(t, recent[0].Actions, 12)
+
+	code, _, body = ts.get(t, "/admin/impersonation/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<code>POST /account/tokens</code></td>\n            <td>403</td>")
+	assert.StringContains(t, body, "<code>POST /impersonation/stop</code></td>\n            <td>303</td>")
+	code, _, _ = ts.get(t, "/admin/impersonation/99")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// The reason is escaped.
+	code, _, body = ts.postForm(t, "/admin/impersonate", url.Values{"username": {"nobody"}, "reason": {"<b>Ticket</b>"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "value='&lt;b&gt;Ticket&lt;/b&gt;'")
+
+	// Impersonations time out.
+	ts.postForm(t, "/admin/impersonate", url.Values{"username": {"dupe"}, "reason": {"Ticket #43"}})
+	frozen.Advance(impersonationLimit)
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "Your impersonation timed out.")
+	code, _, _ = ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestAdminSnippetPin(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	snippets.Add(&models.Snippet{
+		Title:   "Newer snippet",
+		Content: "Newer content",
+		Created: time.Now(),
+		Expires: time.Now().Add(time.Hour),
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Titles appear in listing order on the home page.
+	order := func() bool {
+		_, _, body := ts.get(t, "/")
+		return strings.Index(body, "An old silent pond") < strings.Index(body, "Newer snippet")
+	}
+
+	assert.Equal(t, order(), false)
+
+	code, header, _ := ts.postForm(t, "/admin/snippet/pin/1", url.Values{"pinned": {"true"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ = ts.postForm(t, "/admin/snippet/pin/1", url.Values{"pinned": {"true"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, order(), true)
+
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<span class='pinned'>Pinned</span>")
+
+	code, _, _ = ts.postForm(t, "/admin/snippet/pin/1", url.Values{"pinned": {"false"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, order(), false)
+
+	code, _, _ = ts.postForm(t, "/admin/snippet/pin/99", url.Values{"pinned": {"true"}})
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAdminReload(t *testing.T) {
+	t.Parallel()
+
+	// Serve a copy of the templates and assets, as with -ui-dir.
+	dir := t.TempDir()
+	err := fs.WalkDir(ui.Files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(ui.Files, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, name), content, 0o644)
+	})
+	assert.NilError(t, err)
+
+	fsys, err := uiFiles(dir)
+	assert.NilError(t, err)
+
+	app := newTestApplication(t)
+	app.uiCache, err = newUICache(fsys)
+	assert.NilError(t, err)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.postForm(t, "/admin/reload", nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// Changes on disk show up once the cache is reloaded.
+	css := app.uiCache.manifest().url("css/main.css")
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "static/css/main.css"), []byte("body { color: teal; }"), 0o644))
+	page := filepath.Join(dir, "html/pages/home.html")
+	home, err := os.ReadFile(page)
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(page, bytes.Replace(home, []byte(`{{define "main"}}`), []byte(`{{define "main"}}<p>Theme changed</p>`), 1), 0o644))
+
+	_, _, body := ts.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "Theme changed"), false)
+
+	code, header, _ = ts.postForm(t, "/admin/reload", nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/admin")
+
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "Theme changed")
+	newCSS := app.uiCache.manifest().url("css/main.css")
+	assert.Equal(t, newCSS != css, true)
+	assert.StringContains(t, body, "href='"+newCSS+"'")
+
+	_, _, body = ts.get(t, newCSS)
+	assert.Equal(t, body, "body { color: teal; }")
+
+	// A template that doesn't parse leaves the old ones in use.
+	assert.NilError(t, os.WriteFile(page, []byte(`{{define "main"}}{{end`), 0o644))
+	ts.postForm(t, "/admin/reload", nil)
+
+	_, _, body = ts.get(t, "/admin")
+	assert.StringContains(t, body, "couldn&#39;t be reloaded")
+	_, _, body = ts.get(t, "/")
+	assert.StringContains(t, body, "Theme changed")
+}
+
+func TestHomeTrending(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	id := snippets.Add(&models.Snippet{
+		Title:   "Hot snippet",
+		Content: "Everyone is reading this",
+		Created: time.Now(),
+		Expires: time.Now().Add(time.Hour),
+	})
+	snippets.TrendingIDs = []int{id}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/?tab=trending")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Trending Snippets")
+	assert.StringContains(t, body, "Hot snippet")
+	assert.Equal(t, strings.Contains(body, "An old silent pond"), false)
+
+	code, _, body = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Latest Snippets")
+	assert.StringContains(t, body, "An old silent pond")
+}
+
+func TestHomePagination(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	for i := 0; i < homePageSize; i++ {
+		snippets.Add(&models.Snippet{
+			Title:   "Newer snippet " + strconv.Itoa(i),
+			Content: "More",
+			Created: time.Now(),
+			Expires: time.Now().Add(time.Hour),
+		})
+	}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The oldest of the eleven snippets is on the second page.
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Newer snippet 9")
+	assert.Equal(t, strings.Contains(body, "An old silent pond"), false)
+	assert.StringContains(t, body, "<span>1</span>")
+	assert.StringContains(t, body, "<a href='/?page=2'>2</a>")
+	assert.StringContains(t, body, "<a href='/?page=2'>Next</a>")
+
+	code, _, body = ts.get(t, "/?page=2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond")
+	assert.Equal(t, strings.Contains(body, "Newer snippet"), false)
+	assert.StringContains(t, body, "<a href='/'>Previous</a>")
+
+	code, _, _ = ts.get(t, "/?page=3")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/?page=two")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestPopular(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	id := snippets.Add(&models.Snippet{
+		Title:   "Much viewed",
+		Content: "Everyone came here",
+		Created: time.Now(),
+		Expires: time.Now().Add(time.Hour),
+	})
+	app.snippets = snippets
+	app.startViewRecorder(10 * time.Millisecond)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/popular")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No snippets have been viewed yet.")
+
+	// A visitor's repeated views are counted once.
+	for i := 0; i < 3; i++ {
+		code, _, _ = ts.get(t, "/snippet/raw/"+strconv.Itoa(id))
+		assert.Equal(t, code, http.StatusOK)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(body, "Much viewed") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		_, _, body = ts.get(t, "/popular")
+	}
+	assert.StringContains(t, body, "Much viewed")
+	assert.StringContains(t, body, "<td>1</td>")
+	assert.Equal(t, strings.Contains(body, "An old silent pond"), false)
+
+	code, _, body = ts.get(t, "/snippet/raw/"+strconv.Itoa(id))
+	assert.Equal(t, code, http.StatusOK)
+	time.Sleep(50 * time.Millisecond)
+
+	_, _, body = ts.get(t, "/popular")
+	assert.StringContains(t, body, "<td>1</td>")
+}
+
+func TestHomeSummaries(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	snippets.Add(&models.Snippet{
+		Title:   "Long snippet",
+		Content: "<script>alert(1)</script>\nline two\nline three\nline four is left out",
+		Created: time.Now(),
+		Expires: time.Now().Add(time.Hour),
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<pre class='summary'>&lt;script&gt;alert(1)&lt;/script&gt;\nline two\nline three ... more</pre>")
+	assert.Equal(t, strings.Contains(body, "line four"), false)
+	assert.Equal(t, strings.Contains(body, "<script>"), false)
+}
+
+func TestSnippetTitleEscaping(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	id := snippets.Add(&models.Snippet{
+		Title:   "<b>Bold</b> & 'quoted'",
+		Content: "Nothing to see",
+		Created: time.Now(),
+		Expires: time.Now().Add(time.Hour),
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Titles are escaped wherever they're shown, in text and in attributes alike.
+	for _, path := range []string{"/", "/snippet/view/" + strconv.Itoa(id)} {
+		code, _, body := ts.get(t, path)
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "&lt;b&gt;Bold&lt;/b&gt; &amp; &#39;quoted&#39;")
+		assert.Equal(t, strings.Contains(body, "<b>Bold</b>"), false)
+	}
+}
+
+func TestSnippetDownload(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	created := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	named := snippets.Add(&models.Snippet{
+		Title:    "main.go",
+		Content:  "package main\n",
+		Language: "go",
+		License:  "MIT",
+		Created:  created,
+		Expires:  created.Add(24 * time.Hour),
+		OwnerID:  1,
+	})
+	private := snippets.Add(&models.Snippet{
+		Title:   "Secret notes",
+		Content: "Not for everyone",
+		Created: created,
+		Expires: created.Add(24 * time.Hour),
+		OwnerID: 1,
+		Private: true,
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// unzip reads the files of an archive by name.
+	unzip := func(t *testing.T, body string) map[string]string {
+		zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+		assert.NilError(t, err)
+
+		files := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			assert.NilError(t, err)
+			content, err := io.ReadAll(rc)
+			assert.NilError(t, err)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		return files
+	}
+
+	code, header, body := ts.get(t, "/snippet/download/"+strconv.Itoa(named)+".zip")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/zip")
+	assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename="`+strconv.Itoa(named)+`.zip"`)
+
+	files := unzip(t, body)
+	assert.Equal(t, len(files), 2)
+	assert.Equal(t, files["main.go"], "package main\n")
+	assert.StringContains(t, files["README.md"], "# main.go\n")
+	assert.StringContains(t, files["README.md"], "- Language: go\n")
+	assert.StringContains(t, files["README.md"], "- License: MIT (https://spdx.org/licenses/MIT.html)\n")
+	assert.StringContains(t, files["README.md"], "- Created: 01 Jun 2030 at 12:00\n")
+	assert.StringContains(t, files["README.md"], "## Files\n\n- main.go\n")
+
+	// Private snippets can only be downloaded by their owner.
+	code, _, _ = ts.get(t, "/snippet/download/"+strconv.Itoa(private)+".zip")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/download/"+strconv.Itoa(private))
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = ts.get(t, "/snippet/download/"+strconv.Itoa(private)+".zip")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, unzip(t, body)["secret-notes.txt"], "Not for everyone")
+
+	code, header, body = ts.get(t, "/snippet/download/"+strconv.Itoa(private))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename=secret-notes.txt`)
+	assert.Equal(t, body, "Not for everyone")
+}
+
+func TestSnippetDownloadFile(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	id := snippets.Add(&models.Snippet{
+		Title:    "main.go",
+		Content:  "package main\n",
+		Language: "go",
+		Created:  time.Now(),
+		Expires:  time.Now().Add(24 * time.Hour),
+	})
+	app.snippets = snippets
+	app.viewQueue = make(chan queuedView, 10)
+	app.accessQueue = make(chan models.Access, 10)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/snippet/download/"+strconv.Itoa(id))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename=main.go`)
+	assert.Equal(t, body, "package main\n")
+
+	// Downloads count as views, like the raw content.
+	view := <-app.viewQueue
+	assert.Equal(t, view.SnippetID, id)
+	access := <-app.accessQueue
+	assert.Equal(t, access.SnippetID, id)
+
+	code, _, _ = ts.get(t, "/snippet/download/999")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetRaw(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	created := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	public := snippets.Add(&models.Snippet{
+		Title:   "main.go",
+		Content: "package main\n\n// <b>not markup</b>\n",
+		Created: created,
+		Expires: time.Now().Add(24 * time.Hour),
+		OwnerID: 1,
+	})
+	private := snippets.Add(&models.Snippet{
+		Title:   "Secret notes",
+		Content: "Not for everyone",
+		Created: created,
+		Expires: time.Now().Add(24 * time.Hour),
+		OwnerID: 1,
+		Private: true,
+	})
+	expired := snippets.Add(&models.Snippet{
+		Title:   "Old notes",
+		Content: "Long gone",
+		Created: created,
+		Expires: time.Now().Add(-time.Hour),
+		OwnerID: 1,
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/snippet/raw/"+strconv.Itoa(public))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, header.Get("Cache-Control"), cachePublic)
+	assert.Equal(t, header.Get("Last-Modified"), "Sat, 01 Jun 2030 12:00:00 GMT")
+	assert.Equal(t, body, "package main\n\n// <b>not markup</b>\n")
+
+	// Clients revalidate their copy with the entity tag.
+	code, _, body = ts.request(t, http.MethodGet, "/snippet/raw/"+strconv.Itoa(public), http.Header{"If-None-Match": {header.Get("ETag")}})
+	assert.Equal(t, code, http.StatusNotModified)
+	assert.Equal(t, body, "")
+
+	code, _, _ = ts.get(t, "/snippet/raw/"+strconv.Itoa(expired))
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Private snippets are only served to their owner, and never stored by caches.
+	code, _, _ = ts.get(t, "/snippet/raw/"+strconv.Itoa(private))
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, header, body = ts.get(t, "/snippet/raw/"+strconv.Itoa(private))
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Cache-Control"), cacheNoStore)
+	assert.Equal(t, body, "Not for everyone")
+}
+
+func TestSnippetFileName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		title    string
+		language string
+		want     string
+	}{
+		{"main.go", "go", "main.go"},
+		{"Dockerfile", "", "Dockerfile"},
+		{"scripts/deploy.sh", "bash", "deploy.sh"},
+		{`..\..\evil.py`, "python", "evil.py"},
+		{"Parse the config", "python", "parse-the-config.py"},
+		{"Notes", "", "notes.txt"},
+		{"!!!", "", "snippet.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, snippetFileName(&models.Snippet{Title: tt.title, Language: tt.language}), tt.want)
+		})
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Existing user",
+			urlPath:  "/~alice",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Unknown user",
+			urlPath:  "/~bob",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Name with markup",
+			urlPath:  "/~carol",
+			wantCode: http.StatusOK,
+			wantBody: "<h2>&lt;b&gt;Carol&lt;/b&gt; <small>@carol</small></h2>",
+		},
+	}
+
+	assert.NilError(t, app.users.Insert("<b>Carol</b>", "carol", "carol@example.com", "pa$$word"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserCheck(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Available email",
+			urlPath:  "/user/check?email=bob@example.com",
+			wantCode: http.StatusOK,
+			wantBody: `{"email":{"available":true}}`,
+		},
+		{
+			name:     "Taken email",
+			urlPath:  "/user/check?email=alice@example.com",
+			wantCode: http.StatusOK,
+			wantBody: `{"email":{"available":false,"message":"Email address is already in use"}}`,
+		},
+		{
+			name:     "Taken username",
+			urlPath:  "/user/check?username=alice",
+			wantCode: http.StatusOK,
+			wantBody: `{"username":{"available":false,"message":"Username is already taken"}}`,
+		},
+		{
+			name:     "Reserved username",
+			urlPath:  "/user/check?username=admin",
+			wantCode: http.StatusOK,
+			wantBody: `{"username":{"available":false,"message":"This username is reserved"}}`,
+		},
+		{
+			name:     "No fields",
+			urlPath:  "/user/check",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	blocklist, err := filter.Parse(strings.NewReader("reject word spam\nhold word casino"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.contentFilter = blocklist
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	t.Run("Authenticated", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/create' method='POST' data-draft=''>")
+	})
+
+	tests := []struct {
+		name     string
+		title    string
+		content  string
+		wantCode int
+		wantID   int // wantID is the snippet the client is sent to, by its public ID.
+		wantBody string
+	}{
+		{
+			name:     "Valid submission",
+			title:    "Over the wintry forest",
+			content:  "Over the wintry forest, winds howl in rage",
+			wantCode: http.StatusSeeOther,
+			wantID:   2,
+		},
+		{
+			name:     "Blank title",
+			title:    "",
+			content:  "Some content",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Rejected by the content filter",
+			title:    "Cheap spam",
+			content:  "Some content",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This snippet contains content that",
+		},
+		{
+			name:     "Held by the content filter",
+			title:    "Casino night",
+			content:  "Some content",
+			wantCode: http.StatusSeeOther,
+			wantID:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", "7")
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantID != 0 {
+				created, err := app.snippets.Get(tt.wantID)
+				assert.NilError(t, err)
+				assert.Equal(t, header.Get("Location"), "/snippet/view/"+created.PublicID())
+			} else {
+				assert.Equal(t, header.Get("Location"), "")
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Created snippet is shown", func(t *testing.T) {
+		code, _, body := ts.follow(t, "/snippet/view/2")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Over the wintry forest, winds howl in rage")
+	})
+
+	t.Run("Held snippet is hidden from others", func(t *testing.T) {
+		other := newTestServer(t, app.routes())
+		defer other.Close()
+
+		code, _, _ := other.follow(t, "/snippet/view/3")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestSnippetExpiry(t *testing.T) {
+	t.Parallel()
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	app := newTestApplication(t)
+	app.clock = frozen
+	snippets := mocks.NewSnippetModel()
+	snippets.Clock = frozen
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{}
+	form.Add("title", "Ephemeral")
+	form.Add("content", "Gone tomorrow")
+	form.Add("expires", "1")
+
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	location := header.Get("Location")
+
+	code, _, body := ts.follow(t, location)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Expires: 02 Jun 2030 at 12:00")
+	assert.StringContains(t, body, "in 2030")
+
+	frozen.Advance(24*time.Hour - time.Second)
+	code, _, _ = ts.follow(t, location)
+	assert.Equal(t, code, http.StatusOK)
+
+	frozen.Advance(time.Second)
+	code, _, _ = ts.follow(t, location)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+// benchmarkGet serves GET requests for urlPath straight from the routes, without a network round
+// trip, so that the benchmark measures the handler, middleware and rendering.
+func benchmarkGet(b *testing.B, urlPath string) {
+
+	app := newTestApplication(b)
+	routes := app.routes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, urlPath, nil))
+		if rr.Code != http.StatusOK {
+			b.Fatalf("got status %d", rr.Code)
+		}
+	}
+}
+
+func BenchmarkHome(b *testing.B) {
+	benchmarkGet(b, "/")
+}
+
+func BenchmarkSnippetView(b *testing.B) {
+	benchmarkGet(b, "/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond")
+}
+
+func TestFavorites(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Only logged-in users can star snippets.
+	code, header, _ := ts.postForm(t, "/snippet/star/1", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<span>0 stars</span>")
+	assert.StringContains(t, body, "<button>Star</button>")
+
+	code, header, _ = ts.postForm(t, "/snippet/star/1", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6")
+
+	_, _, body = ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<span>1 star</span>")
+	assert.StringContains(t, body, "<button>Unstar</button>")
+
+	code, _, body = ts.get(t, "/account/favorites")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond")
+
+	code, _, _ = ts.postForm(t, "/snippet/star/999", url.Values{})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.postForm(t, "/snippet/unstar/1", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/account/favorites")
+	assert.StringContains(t, body, "You haven't starred any snippets yet.")
+}
+
+func TestCollections(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The mock collection is private, so it's hidden from visitors.
+	code, _, _ := ts.get(t, "/collection/view/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, header, _ := ts.get(t, "/collections")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/collection/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<h2>Poems</h2>")
+	assert.StringContains(t, body, "An old silent pond")
+
+	// The snippet page offers the user's collections.
+	_, _, body = ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<option value='1'>Poems</option>")
+
+	code, _, body = ts.postForm(t, "/collection/create", url.Values{"name": {""}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be blank")
+
+	code, _, body = ts.postForm(t, "/collection/create", url.Values{"name": {"Poems"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You already have a collection with this name")
+
+	code, header, _ = ts.postForm(t, "/collection/create", url.Values{"name": {"Haiku"}, "public": {"true"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/collection/view/2")
+
+	code, _, _ = ts.postForm(t, "/collection/add", url.Values{"collection_id": {"2"}, "snippet_id": {"1"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/collections")
+	assert.StringContains(t, body, "<td><a href='/collection/view/2'>Haiku</a></td>")
+
+	code, _, _ = ts.postForm(t, "/collection/add", url.Values{"collection_id": {"2"}, "snippet_id": {"99"}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.postForm(t, "/collection/remove/1", url.Values{"snippet_id": {"1"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/collection/view/1")
+	assert.StringContains(t, body, "This collection is empty.")
+
+	code, _, _ = ts.postForm(t, "/collection/delete/1", nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.get(t, "/collection/view/1")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Other users can view the public collection but not change it.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, body = other.get(t, "/collection/view/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond")
+
+	code, _, _ = other.get(t, "/collection/edit/2")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = other.postForm(t, "/collection/add", url.Values{"collection_id": {"2"}, "snippet_id": {"1"}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = other.postForm(t, "/collection/delete/2", nil)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Collection names are shown as text.
+	code, header, _ = ts.postForm(t, "/collection/create", url.Values{"name": {"<i>Haiku</i>"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, header.Get("Location"))
+	assert.StringContains(t, body, "<h2>&lt;i&gt;Haiku&lt;/i&gt;</h2>")
+	_, _, body = ts.get(t, "/collections")
+	assert.StringContains(t, body, ">&lt;i&gt;Haiku&lt;/i&gt;</a></td>")
+	code, _, body = ts.postForm(t, "/collection/add", url.Values{"collection_id": {"3"}, "snippet_id": {"1"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "Added to &lt;i&gt;Haiku&lt;/i&gt;!")
+}
+
+func TestSnippetShare(t *testing.T) {
+	t.Parallel()
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	app := newTestApplication(t)
+	app.clock = frozen
+	snippets := mocks.NewSnippetModel()
+	snippets.Clock = frozen
+	id := snippets.Add(&models.Snippet{
+		Title:   "Secret recipe",
+		Content: "Mostly butter",
+		Created: frozen.Now(),
+		Expires: frozen.Now().Add(30 * 24 * time.Hour),
+		OwnerID: 1,
+	})
+	other := snippets.Add(&models.Snippet{
+		Title:   "Another secret",
+		Content: "More butter",
+		Created: frozen.Now(),
+		Expires: frozen.Now().Add(30 * 24 * time.Hour),
+		OwnerID: 1,
+		Private: true,
+	})
+	app.snippets = snippets
+	shares := mocks.NewShareModel()
+	shares.Clock = frozen
+	app.shares = shares
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	visitor := newTestServer(t, app.routes())
+	defer visitor.Close()
+
+	path := "/snippet/view/" + strconv.Itoa(id)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ := ts.postForm(t, "/snippet/private/"+strconv.Itoa(id), url.Values{"private": {"true"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Private snippets are unlisted and hidden from visitors.
+	_, _, body := visitor.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "Secret recipe"), false)
+
+	code, _, _ = visitor.get(t, path)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.postForm(t, "/snippet/share/"+strconv.Itoa(id), url.Values{"hours": {"1"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = ts.postForm(t, "/snippet/share/"+strconv.Itoa(id), url.Values{"hours": {"24"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = ts.postForm(t, "/snippet/share/"+strconv.Itoa(id), url.Values{"hours": {"5"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// The owner's page lists the links, newest first.
+	_, _, body = ts.get(t, path)
+	links := shareLinkPattern.FindAllStringSubmatch(body, -1)
+	assert.Equal(t, len(links), 2)
+	day, hour := links[0][1], links[1][1]
+
+	code, header, body := visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+hour)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Mostly butter")
+	assert.Equal(t, header.Get("Cache-Control"), "no-store")
+
+	// The shared page is the snippet's usual one, with its numbered lines linking to its permalink.
+	assert.StringContains(t, body, "data-permalink='/snippet/view/"+strconv.Itoa(id)+"'")
+	assert.StringContains(t, body, `<a class="lnlinks" href="#L1">1</a>`)
+
+	// Tokens only work for the snippet they were made for, and can't be altered.
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(other)+"?token="+hour)
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+hour[:len(hour)-2]+"AA")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(id))
+	assert.Equal(t, code, http.StatusNotFound)
+
+	frozen.Advance(2 * time.Hour)
+
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+hour)
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+day)
+	assert.Equal(t, code, http.StatusOK)
+
+	// Only the owner can manage the links.
+	visitor.login(t, "dupe@example.com", "pa$$word")
+	code, _, _ = visitor.postForm(t, "/snippet/share/"+strconv.Itoa(id), url.Values{"hours": {"24"}})
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = visitor.postForm(t, "/snippet/unshare/"+strconv.Itoa(id), url.Values{"share_id": {"2"}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.postForm(t, "/snippet/unshare/"+strconv.Itoa(id), url.Values{"share_id": {"2"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+day)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetSharePermanent(t *testing.T) {
+	t.Parallel()
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	app := newTestApplication(t)
+	app.clock = frozen
+	snippets := mocks.NewSnippetModel()
+	snippets.Clock = frozen
+	id := snippets.Add(&models.Snippet{
+		Title:   "Secret recipe",
+		Content: "Mostly butter",
+		Created: frozen.Now(),
+		OwnerID: 1,
+		Private: true,
+	})
+	app.snippets = snippets
+	shares := mocks.NewShareModel()
+	shares.Clock = frozen
+	app.shares = shares
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	visitor := newTestServer(t, app.routes())
+	defer visitor.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// Links to snippets that never expire last as long as asked.
+	code, _, _ := ts.postForm(t, "/snippet/share/"+strconv.Itoa(id), url.Values{"hours": {"168"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body := ts.get(t, "/snippet/view/"+strconv.Itoa(id))
+	links := shareLinkPattern.FindAllStringSubmatch(body, -1)
+	assert.Equal(t, len(links), 1)
+
+	code, _, body = visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+links[0][1])
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Mostly butter")
+
+	frozen.Advance(169 * time.Hour)
+
+	code, _, _ = visitor.get(t, "/s/"+strconv.Itoa(id)+"?token="+links[0][1])
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestShortLinks(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Minting a link through the API needs a login. Errors are problem details, like the rest of
+	// the API's.
+	code, header, body := ts.postJSON(t, "/api/v1/shortlinks", `{"snippet": "1"}`)
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+	assert.StringContains(t, body, `"detail":"Log in to create short links."`)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = ts.postJSON(t, "/api/v1/shortlinks", `{"snippet": "01HV6Z9K1QX8M3N5P7R9T2V4W6"}`)
+	assert.Equal(t, code, http.StatusCreated)
+	assert.StringContains(t, body, `"snippet":"01HV6Z9K1QX8M3N5P7R9T2V4W6"`)
+
+	link, err := app.shortLinks.ForSnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(link.Code), models.ShortCodeLength)
+
+	// Each snippet keeps a single link.
+	code, _, body = ts.postJSON(t, "/api/v1/shortlinks", `{"snippet": "1"}`)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `"code":"`+link.Code+`"`)
+
+	code, header, body = ts.postJSON(t, "/api/v1/shortlinks", `{"snippet": "99"}`)
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+	assert.StringContains(t, body, `"status":404`)
+	code, header, _ = ts.postJSON(t, "/api/v1/shortlinks", `not json`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+
+	_, _, body = ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "/x/"+link.Code+"</a> (0 clicks)")
+
+	code, header, _ = ts.get(t, "/x/"+link.Code)
+	assert.Equal(t, code, http.StatusFound)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6")
+
+	link, err = app.shortLinks.Get(link.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, link.Clicks, 1)
+
+	_, _, body = ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "/x/"+link.Code+"</a> (1 click)")
+
+	code, _, _ = ts.get(t, "/x/nope00")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, "/x/not-a-code")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Links to private snippets only lead those who can see them there, and only their clicks count.
+	assert.NilError(t, app.snippets.SetPrivate(1, true))
+
+	visitor := newTestServer(t, app.routes())
+	defer visitor.Close()
+
+	code, header, _ = visitor.get(t, "/x/"+link.Code)
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, header.Get("Location"), "")
+
+	link, err = app.shortLinks.Get(link.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, link.Clicks, 1)
+
+	code, header, _ = ts.get(t, "/x/"+link.Code)
+	assert.Equal(t, code, http.StatusFound)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6")
+
+	link, err = app.shortLinks.Get(link.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, link.Clicks, 2)
+}
+
+func TestAPISnippets(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/api/v1/snippets")
+	assert.Equal(t, code, http.StatusOK)
+	var list struct {
+		Snippets []apiSnippet `json:"snippets"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(body), &list))
+	assert.Equal(t, len(list.Snippets), 1)
+	assert.Equal(t, list.Snippets[0].ID, "01HV6Z9K1QX8M3N5P7R9T2V4W6")
+
+	code, header, body := ts.get(t, "/api/v1/snippets/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+	assert.StringContains(t, body, `"title":"An old silent pond"`)
+
+	code, header, _ = ts.get(t, "/api/v1/snippets/99")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, header.Get("Content-Type"), "application/problem+json")
+
+	// Creating snippets needs a login.
+	code, _, _ = ts.postJSON(t, "/api/v1/snippets", `{"title": "Hello", "content": "world", "expires": 7}`)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ = ts.postJSON(t, "/api/v1/snippets", `not json`)
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, body = ts.postJSON(t, "/api/v1/snippets", `{"title": "", "content": "world", "expires": 3}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	var p invalidProblem
+	assert.NilError(t, json.Unmarshal([]byte(body), &p))
+	assert.Equal(t, p.Status, http.StatusUnprocessableEntity)
+	assert.Equal(t, len(p.Errors["title"]), 1)
+	assert.Equal(t, len(p.Errors["expires"]), 1)
+
+	code, header, body = ts.postJSON(t, "/api/v1/snippets", `{"title": "main.go", "content": "package main", "expires": 7, "private": true}`)
+	assert.Equal(t, code, http.StatusCreated)
+	var created apiSnippet
+	assert.NilError(t, json.Unmarshal([]byte(body), &created))
+	assert.Equal(t, header.Get("Location"), "/api/v1/snippets/"+created.ID)
+	assert.Equal(t, created.Title, "main.go")
+	assert.Equal(t, created.Private, true)
+
+	code, _, _ = ts.get(t, header.Get("Location"))
+	assert.Equal(t, code, http.StatusOK)
+}
+
+func TestAPITokens(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.postForm(t, "/account/tokens", url.Values{"name": {""}, "scope": {"admin"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Choose whether the token can only read or also write")
+
+	// The new token is shown once.
+	code, _, body = ts.postForm(t, "/account/tokens", url.Values{"name": {"reader"}, "scope": {"read"}})
+	assert.Equal(t, code, http.StatusOK)
+	reader := regexp.MustCompile(models.TokenPrefix + `[A-Za-z0-9_-]+`).FindString(body)
+	assert.Equal(t, reader != "", true)
+	_, _, body = ts.get(t, "/account/tokens")
+	assert.Equal(t, strings.Contains(body, reader), false)
+	assert.StringContains(t, body, "reader")
+
+	_, _, body = ts.postForm(t, "/account/tokens", url.Values{"name": {"writer"}, "scope": {"write"}})
+	writer := regexp.MustCompile(models.TokenPrefix + `[A-Za-z0-9_-]+`).FindString(body)
+
+	// Tokens authenticate API requests without a session.
+	api := newTestServer(t, app.routes())
+	defer api.Close()
+
+	snippet := `{"title": "main.go", "content": "package main", "expires": 7}`
+	code, _, _ = api.postJSON(t, "/api/v1/snippets", snippet)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	code, _, _ = api.request(t, http.MethodGet, "/api/v1/snippets/1", http.Header{"Authorization": {"Bearer " + reader}})
+	assert.Equal(t, code, http.StatusOK)
+
+	code, header, _ := api.sendJSON(t, http.MethodPost, "/api/v1/snippets", http.Header{"Authorization": {"Bearer " + reader}}, snippet)
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, header.Get("WWW-Authenticate"), `error="insufficient_scope"`)
+
+	code, _, body = api.sendJSON(t, http.MethodPost, "/api/v1/snippets", http.Header{"Authorization": {"Bearer " + writer}}, snippet)
+	assert.Equal(t, code, http.StatusCreated)
+	var created apiSnippet
+	assert.NilError(t, json.Unmarshal([]byte(body), &created))
+	s, err := app.snippets.GetByULID(created.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, s.OwnerID, 1)
+
+	code, header, _ = api.request(t, http.MethodGet, "/api/v1/snippets", http.Header{"Authorization": {"Bearer nope"}})
+	assert.Equal(t, code, http.StatusUnauthorized)
+	assert.StringContains(t, header.Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	// Revoked tokens stop working, and users can only revoke their own.
+	tokens, err := app.tokens.ByUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 2)
+	code, _, _ = ts.postForm(t, fmt.Sprintf("/account/tokens/revoke/%d", tokens[1].ID), nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = ts.postForm(t, fmt.Sprintf("/account/tokens/revoke/%d", tokens[1].ID), nil)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = api.request(t, http.MethodGet, "/api/v1/snippets", http.Header{"Authorization": {"Bearer " + reader}})
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	// Names are escaped, in the form and in the list.
+	code, _, body = ts.postForm(t, "/account/tokens", url.Values{"name": {"<b>bold</b>"}, "scope": {"admin"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "value='&lt;b&gt;bold&lt;/b&gt;'")
+	code, _, body = ts.postForm(t, "/account/tokens", url.Values{"name": {"<b>bold</b>"}, "scope": {"read"}})
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>&lt;b&gt;bold&lt;/b&gt;</td>")
+}
+
+func TestOrganizations(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	owner := newTestServer(t, app.routes())
+	defer owner.Close()
+	owner.login(t, "alice@example.com", "pa$$word")
+
+	member := newTestServer(t, app.routes())
+	defer member.Close()
+	member.login(t, "dupe@example.com", "pa$$word")
+
+	code, header, _ := owner.postForm(t, "/org/create", url.Values{"name": {"Haiku Club"}, "slug": {"haiku"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/org/view/haiku")
+
+	code, _, body := owner.postForm(t, "/org/create", url.Values{"name": {"Other"}, "slug": {"haiku"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This address is already taken")
+
+	// A private snippet of the organization.
+	code, header, _ = owner.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Team haiku"},
+		"content": {"Five, seven, then five"},
+		"expires": {"7"},
+		"private": {"true"},
+		"org":     {"1"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippetURL := header.Get("Location")
+
+	// Only members can put snippets in an organization.
+	code, _, body = member.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Intruder"},
+		"content": {"Not a member"},
+		"expires": {"7"},
+		"org":     {"1"},
+	})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You aren&#39;t a member of this organization")
+
+	// Outsiders see neither the dashboard nor the private snippet.
+	code, _, _ = member.get(t, "/org/view/haiku")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = member.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, body = owner.postForm(t, "/org/invite/haiku", url.Values{"email": {"not an address"}, "role": {"member"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must be a valid email address")
+
+	code, _, _ = owner.postForm(t, "/org/invite/haiku", url.Values{"email": {"dupe@example.com"}, "role": {"member"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	msg := app.mailer.(*testMailer).receive(t)
+	assert.Equal(t, msg.To, "dupe@example.com")
+	assert.StringContains(t, msg.Subject, "Haiku Club")
+
+	matches := regexp.MustCompile(`/org/invitation\?token=([A-Za-z0-9_-]+)`).FindStringSubmatch(msg.Body)
+	if matches == nil {
+		t.Fatalf("no invitation link in %q", msg.Body)
+	}
+	token := matches[1]
+
+	// Only the invited account can accept.
+	_, _, body = owner.get(t, "/org/invitation?token="+token)
+	assert.StringContains(t, body, "This invitation was sent to dupe@example.com")
+	code, _, _ = owner.postForm(t, "/org/invitation", url.Values{"token": {token}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	_, _, body = member.get(t, "/org/invitation?token="+token)
+	assert.StringContains(t, body, "Accept invitation")
+
+	code, header, _ = member.postForm(t, "/org/invitation", url.Values{"token": {token}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/org/view/haiku")
+
+	// Invitations can only be used once.
+	code, _, _ = member.postForm(t, "/org/invitation", url.Values{"token": {token}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, body = member.get(t, "/org/view/haiku")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Team haiku")
+	code, _, _ = member.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = member.postForm(t, "/org/invite/haiku", url.Values{"email": {"eve@example.com"}, "role": {"owner"}})
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _, _ = member.postForm(t, "/org/remove/haiku", url.Values{"user_id": {"1"}})
+	assert.Equal(t, code, http.StatusForbidden)
+
+	// The last owner can't leave.
+	code, _, _ = owner.postForm(t, "/org/remove/haiku", url.Values{"user_id": {"1"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	role, err := app.organizations.Role(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, role, models.RoleOwner)
+
+	code, header, _ = member.postForm(t, "/org/remove/haiku", url.Values{"user_id": {"2"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/orgs")
+
+	code, _, _ = member.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Names are escaped on the dashboard and the list.
+	code, _, _ = owner.postForm(t, "/org/create", url.Values{"name": {"<b>Bold</b>"}, "slug": {"bold"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	for _, path := range []string{"/org/view/bold", "/orgs"} {
+		_, _, body = owner.get(t, path)
+		assert.StringContains(t, body, "&lt;b&gt;Bold&lt;/b&gt;")
+		assert.Equal(t, strings.Contains(body, "<b>Bold</b>"), false)
+	}
+}
+
+func TestSnippetPermissions(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	owner := newTestServer(t, app.routes())
+	defer owner.Close()
+	owner.login(t, "alice@example.com", "pa$$word")
+
+	member := newTestServer(t, app.routes())
+	defer member.Close()
+	member.login(t, "dupe@example.com", "pa$$word")
+
+	orgID, err := app.organizations.Insert("Haiku Club", "haiku", 1)
+	assert.NilError(t, err)
+	token, err := app.organizations.Invite(orgID, 1, "dupe@example.com", models.RoleMember, time.Now().Add(time.Hour))
+	assert.NilError(t, err)
+	_, err = app.organizations.AcceptInvitation(token, 2, "dupe@example.com")
+	assert.NilError(t, err)
+
+	code, header, _ := owner.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Team haiku"},
+		"content": {"Five, seven, then five"},
+		"expires": {"7"},
+		"private": {"true"},
+		"org":     {strconv.Itoa(orgID)},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippetURL := header.Get("Location")
+	id := strings.TrimPrefix(snippetURL, "/snippet/view/")
+
+	// Without rules, members can edit but not manage the snippet.
+	_, _, body := member.get(t, snippetURL)
+	assert.StringContains(t, body, "/snippet/edit/")
+	assert.Equal(t, strings.Contains(body, "<h2 class='section'>Permissions</h2>"), false)
+
+	code, _, _ = member.postForm(t, "/snippet/edit/"+id, url.Values{"title": {"Team haiku, revised"}, "content": {"Five, seven, five"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippetByPublicID(id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Title, "Team haiku, revised")
+	assert.Equal(t, snippet.UpdatedBy, 2)
+
+	code, _, _ = member.postForm(t, "/snippet/permission/"+id, url.Values{"role": {"member"}, "level": {"write"}})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// A rule for the role makes members readers.
+	_, _, body = owner.get(t, snippetURL)
+	assert.StringContains(t, body, "<h2 class='section'>Permissions</h2>")
+	assert.StringContains(t, body, "Dupe")
+
+	code, _, _ = owner.postForm(t, "/snippet/permission/"+id, url.Values{"role": {"member"}, "level": {"read"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = member.get(t, "/snippet/edit/"+id)
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _, _ = member.postForm(t, "/snippet/edit/"+id, url.Values{"title": {"Vandalised"}, "content": {"Oops"}})
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _, body = member.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "/snippet/edit/"), false)
+
+	// A rule for the member wins over the rule for their role.
+	code, _, _ = owner.postForm(t, "/snippet/permission/"+id, url.Values{"user_id": {"2"}, "level": {"none"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = member.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusNotFound)
+	_, _, body = member.get(t, "/org/view/haiku")
+	assert.Equal(t, strings.Contains(body, "Team haiku"), false)
+
+	code, _, _ = owner.postForm(t, "/snippet/permission/"+id, url.Values{"user_id": {"2"}, "level": {"write"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = member.get(t, "/snippet/edit/"+id)
+	assert.Equal(t, code, http.StatusOK)
+
+	code, _, _ = owner.postForm(t, "/snippet/permission/"+id, url.Values{"user_id": {"2"}, "role": {"member"}, "level": {"read"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, _, _ = owner.postForm(t, "/snippet/permission/"+id, url.Values{"role": {"member"}, "level": {"manage"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// Snippets outside organizations can only be edited by their owner.
+	code, _, _ = member.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusForbidden)
+	code, _, _ = owner.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	code, _, _ = owner.postForm(t, "/snippet/permission/1", url.Values{"role": {"member"}, "level": {"read"}})
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestSnippetEditByAdmin(t *testing.T) {
+	t.Parallel()
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	snippets.Clock = frozen
+	app.snippets = snippets
+
+	author := newTestServer(t, app.routes())
+	defer author.Close()
+	author.login(t, "dupe@example.com", "pa$$word")
+
+	admin := newTestServer(t, app.routes())
+	defer admin.Close()
+	admin.login(t, "alice@example.com", "pa$$word")
+
+	code, header, _ := author.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Typo haiku"},
+		"content": {"Five, sevn, five"},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippetURL := header.Get("Location")
+	id := strings.TrimPrefix(snippetURL, "/snippet/view/")
+
+	// Admins are offered the form for anyone's snippet, prefilled with it.
+	_, header, _ = admin.get(t, snippetURL)
+	_, _, body := admin.get(t, header.Get("Location"))
+	assert.StringContains(t, body, "/snippet/edit/")
+
+	code, _, body = admin.get(t, "/snippet/edit/"+id)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Five, sevn, five")
+
+	frozen.Advance(time.Hour)
+
+	code, _, _ = admin.postForm(t, "/snippet/edit/"+id, url.Values{"title": {"Typo haiku"}, "content": {"Five, seven, five"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippetByPublicID(id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Content, "Five, seven, five")
+	assert.Equal(t, snippet.UpdatedBy, 1)
+	assert.Equal(t, snippet.Updated, frozen.Now())
+}
+
+func TestSnippetTrash(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	owner := newTestServer(t, app.routes())
+	defer owner.Close()
+	owner.login(t, "dupe@example.com", "pa$$word")
+
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "alice@example.com", "pa$$word")
+
+	code, header, _ := owner.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Regrettable haiku"},
+		"content": {"Five, seven, five"},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+	snippetURL := header.Get("Location")
+	id := strings.TrimPrefix(snippetURL, "/snippet/view/")
+
+	// Only the owner can delete the snippet.
+	code, _, _ = other.postForm(t, "/snippet/delete/"+id, nil)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, header, _ = owner.postForm(t, "/snippet/delete/"+id, nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/trash")
+
+	code, _, _ = owner.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusNotFound)
+	_, _, body := owner.get(t, "/")
+	assert.Equal(t, strings.Contains(body, "Regrettable haiku"), false)
+
+	_, _, body = owner.get(t, "/trash")
+	assert.StringContains(t, body, "Regrettable haiku")
+	assert.StringContains(t, body, "/trash/restore/"+id)
+	_, _, body = other.get(t, "/trash")
+	assert.Equal(t, strings.Contains(body, "Regrettable haiku"), false)
+
+	code, _, _ = other.postForm(t, "/trash/restore/"+id, nil)
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = owner.postForm(t, "/trash/restore/"+id, nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = owner.get(t, snippetURL)
+	assert.Equal(t, code, http.StatusFound)
+
+	// Snippets are only purged from the trash.
+	code, _, _ = owner.postForm(t, "/trash/purge/"+id, nil)
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = owner.postForm(t, "/snippet/delete/"+id, nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = owner.postForm(t, "/trash/purge/"+id, nil)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = owner.get(t, "/trash")
+	assert.StringContains(t, body, "The trash is empty.")
+	code, _, _ = owner.postForm(t, "/trash/restore/"+id, nil)
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetNeverExpires(t *testing.T) {
+	t.Parallel()
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	snippets.Clock = frozen
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='-1' > Never")
+
+	code, header, _ := ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Evergreen haiku"},
+		"content": {"Five, seven, five"},
+		"expires": {"-1"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+	id := strings.TrimPrefix(header.Get("Location"), "/snippet/view/")
+
+	// The snippet is still there decades later.
+	frozen.Advance(50 * 365 * 24 * time.Hour)
+
+	snippet, err := app.snippetByPublicID(id)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Permanent(), true)
+
+	_, header, _ = ts.get(t, "/snippet/view/"+id)
+	_, _, body = ts.get(t, header.Get("Location"))
+	assert.StringContains(t, body, "Never expires")
+
+	_, _, body = ts.get(t, "/api/v1/snippets/"+id)
+	assert.StringContains(t, body, `"expires":null`)
+
+	code, _, _ = ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Ancient haiku"},
+		"content": {"Five, seven, five"},
+		"expires": {"-7"},
+	})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+}
+
+func TestSnippetEditLocks(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	owner := newTestServer(t, app.routes())
+	defer owner.Close()
+	owner.login(t, "alice@example.com", "pa$$word")
+
+	member := newTestServer(t, app.routes())
+	defer member.Close()
+	member.login(t, "dupe@example.com", "pa$$word")
+
+	orgID, err := app.organizations.Insert("Haiku Club", "haiku", 1)
+	assert.NilError(t, err)
+	token, err := app.organizations.Invite(orgID, 1, "dupe@example.com", models.RoleMember, time.Now().Add(time.Hour))
+	assert.NilError(t, err)
+	_, err = app.organizations.AcceptInvitation(token, 2, "dupe@example.com")
+	assert.NilError(t, err)
+
+	code, header, _ := owner.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Team haiku"},
+		"content": {"Five, seven, then five"},
+		"expires": {"7"},
+		"org":     {strconv.Itoa(orgID)},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+	id := strings.TrimPrefix(header.Get("Location"), "/snippet/view/")
+
+	// The first editor takes the lock and isn't told about anyone else.
+	code, _, body := owner.get(t, "/snippet/edit/"+id)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<div class='lock' hidden>")
+
+	// The second is told who holds it, but can still save.
+	code, _, body = member.get(t, "/snippet/edit/"+id)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Alice Jones has been editing this snippet since")
+
+	code, header, body = member.postForm(t, "/snippet/lock/"+id, nil)
+	assert.Equal(t, code, http.StatusConflict)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+	assert.StringContains(t, body, `"holder":"Alice Jones"`)
+
+	code, _, _ = owner.postForm(t, "/snippet/lock/"+id, nil)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	code, _, _ = member.postForm(t, "/snippet/edit/"+id, url.Values{"title": {"Team haiku, revised"}, "content": {"Five, seven, five"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Saving or leaving the form releases the lock.
+	code, _, _ = owner.postForm(t, "/snippet/unlock/"+id, nil)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	code, _, _ = member.postForm(t, "/snippet/lock/"+id, nil)
+	assert.Equal(t, code, http.StatusNoContent)
+	code, _, _ = member.postForm(t, "/snippet/edit/"+id, url.Values{"title": {"Team haiku, again"}, "content": {"Five, seven, five"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = owner.postForm(t, "/snippet/lock/"+id, nil)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	// Users who can't edit the snippet can't lock it.
+	code, _, _ = member.postForm(t, "/snippet/lock/1", nil)
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestAccountDigest(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/account/digest")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<option value='off' selected>")
+
+	code, _, body = ts.postForm(t, "/account/digest", url.Values{"frequency": {"hourly"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must equal")
+
+	code, header, _ := ts.postForm(t, "/account/digest", url.Values{"frequency": {"weekly"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/digest")
+
+	code, _, body = ts.get(t, "/account/digest")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You&#39;ll get a weekly activity digest.")
+	assert.StringContains(t, body, "<option value='weekly' selected>")
+
+	frequency, err := app.digests.Frequency(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, frequency, models.DigestWeekly)
+}
+
+func TestAccountPreferences(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// Without preferences, new snippets are kept for a year and their language is detected.
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='365' checked>")
+	assert.StringContains(t, body, "<option value=''>Detect automatically</option>")
+
+	code, _, body = ts.postForm(t, "/account/preferences", url.Values{"expires": {"7"}, "language": {"cobol"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Choose a language from the list")
+
+	code, header, _ := ts.postForm(t, "/account/preferences", url.Values{"expires": {"7"}, "private": {"true"}, "language": {"python"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/preferences")
+
+	preferences, err := app.preferences.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, preferences, models.Preferences{DefaultExpires: 7, DefaultPrivate: true, DefaultLanguage: "python"})
+
+	code, _, body = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='radio' name='expires' value='7' checked>")
+	assert.StringContains(t, body, "<input type='checkbox' name='private' value='true' checked>")
+	assert.StringContains(t, body, "<option value='python' selected>")
+
+	// A language chosen on the form is kept instead of the detected one.
+	code, _, _ = ts.postForm(t, "/snippet/create", url.Values{
+		"title":    {"main.go"},
+		"content":  {"package main"},
+		"expires":  {"7"},
+		"language": {"python"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Language, "python")
+}
+
+func TestSendDigests(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.BaseURL = "https://snippetbox.example.com/"
+
+	digests := app.digests.(*mocks.DigestModel)
+	digests.Activities[1] = []*models.SnippetActivity{{SnippetID: 1, ULID: "01HV6Z9K1QX8M3N5P7R9T2V4W6", Title: "An old silent pond", Views: 3}}
+
+	if err := app.digests.SetFrequency(1, models.DigestDaily); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing happened for the second user, so they get no email.
+	if err := app.digests.SetFrequency(2, models.DigestWeekly); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.sendDigests(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := app.mailer.(*testMailer).receive(t)
+	assert.Equal(t, msg.To, "alice@example.com")
+	assert.Equal(t, msg.Subject, "Your daily Snippetbox digest")
+	assert.StringContains(t, msg.Body, "Your snippets were viewed 3 times yesterday")
+	assert.StringContains(t, msg.Body, "https://snippetbox.example.com/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6")
+	assert.StringContains(t, msg.Body, "https://snippetbox.example.com/account/digest")
+
+	// Digests that were sent aren't due again until the next period.
+	if err := app.sendDigests(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-app.mailer.(*testMailer).sent:
+		t.Errorf("unexpected email to %s", msg.To)
+	default:
+	}
+}
+
+func TestSearchFullText(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	for i := 0; i < searchLimit+1; i++ {
+		snippets.Add(&models.Snippet{
+			Title:   "Retry " + strconv.Itoa(i),
+			Content: "// Wait before the next attempt.\ntime.Sleep(backoff) // <b>",
+			Created: time.Now(),
+			Expires: time.Now().Add(24 * time.Hour),
+		})
+	}
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Content is searched, and the matching line is shown escaped with the words marked.
+	code, _, body := ts.get(t, "/search?q=BACKOFF")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "time.Sleep(<mark>backoff</mark>) // &lt;b&gt;")
+	assert.StringContains(t, body, "<span>Page 1</span>")
+	assert.StringContains(t, body, `<a href='/search?page=2&amp;q=BACKOFF'>Next</a>`)
+
+	code, _, body = ts.get(t, "/search?q=BACKOFF&page=2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<a href='/search?q=BACKOFF'>Previous</a>`)
+	assert.Equal(t, strings.Contains(body, "Next</a>"), false)
+
+	code, _, _ = ts.get(t, "/search?q=backoff&page=0")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
+
+func TestSavedSearches(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.BaseURL = "https://snippetbox.example.com"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anyone can search, but only logged-in users can save searches.
+	code, _, body := ts.get(t, "/search?q=SILENT+pond")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond")
+	assert.Equal(t, strings.Contains(body, "Save this search"), false)
+
+	code, _, body = ts.get(t, "/search?q=frog")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No snippets found.")
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = ts.postForm(t, "/search/save", url.Values{"name": {"Everything"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Enter something to search for")
+
+	code, header, _ := ts.postForm(t, "/search/save", url.Values{"name": {"Frogs"}, "q": {"frog"}, "notify": {"true"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/searches")
+
+	code, _, body = ts.postForm(t, "/search/save", url.Values{"name": {"Frogs"}, "q": {"toad"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "You already have a saved search with this name")
+
+	code, _, body = ts.get(t, "/searches")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/search?q=frog'>Frogs</a>")
+
+	// Names and words are escaped, and the link is built from encoded parts.
+	code, _, _ = ts.postForm(t, "/search/save", url.Values{"name": {"<i>Quotes</i>"}, "q": {"it's a 'b'"}, "language": {"go&c"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = ts.get(t, "/searches")
+	assert.StringContains(t, body, "<a href='/search?q=it%27s%20a%20%27b%27&amp;language=go%26c'>&lt;i&gt;Quotes&lt;/i&gt;</a>")
+	assert.StringContains(t, body, "<td>it&#39;s a &#39;b&#39; in go&amp;c</td>")
+	code, _, _ = ts.postForm(t, "/search/delete/2", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Nothing new matches yet.
+	if err := app.notifySavedSearches(); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := app.snippets.Insert("A frog jumps in", "The sound of water", 7, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.notifySavedSearches(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := app.mailer.(*testMailer).receive(t)
+	assert.Equal(t, msg.To, "alice@example.com")
+	assert.Equal(t, msg.Subject, "New snippets matching Frogs")
+	assert.StringContains(t, msg.Body, "https://snippetbox.example.com/snippet/view/"+snippet.PublicID())
+
+	// Matches are only reported once.
+	if err := app.notifySavedSearches(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-app.mailer.(*testMailer).sent:
+		t.Errorf("unexpected email about %q", msg.Subject)
+	default:
+	}
+
+	// Other users can't change someone's saved searches.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, _ = other.postForm(t, "/search/delete/1", url.Values{})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.postForm(t, "/search/notify/1", url.Values{"notify": {"false"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = ts.postForm(t, "/search/delete/1", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, body = ts.get(t, "/searches")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You haven't saved any searches yet.")
+}
+
+func TestSnippetDraft(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// Saving the same draft again keeps a single draft.
+	for i := 0; i < 2; i++ {
+		code, _, _ := ts.postForm(t, "/snippet/draft", url.Values{"title": {"Half a haiku"}, "content": {"Over the wintry"}})
+		assert.Equal(t, code, http.StatusNoContent)
+	}
+
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Restored your draft")
+	assert.StringContains(t, body, "<input type='text' name='title' value='Half a haiku'>")
+	assert.StringContains(t, body, "Over the wintry</textarea>")
+
+	// The draft of a new snippet isn't restored into the edit form of another.
+	code, _, body = ts.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "Restored your draft"), false)
+
+	code, _, _ = ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Half a haiku"},
+		"content": {"Over the wintry forest"},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// Publishing the snippet discards the draft.
+	code, _, body = ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Contains(body, "Restored your draft"), false)
+
+	code, _, _ = ts.postForm(t, "/snippet/draft", url.Values{"snippet": {"1"}, "title": {"An old silent pond"}, "content": {"A frog jumps in"}})
+	assert.Equal(t, code, http.StatusNoContent)
+
+	code, _, body = ts.get(t, "/snippet/edit/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "A frog jumps in</textarea>")
+
+	// Drafts can't be kept for snippets the user may not edit.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, _ = other.postForm(t, "/snippet/draft", url.Values{"snippet": {"1"}, "title": {"Mine now"}})
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetDraftAnonymous(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.captcha = stubVerifier{}
+	app.config.AnonymousPosting = true
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Visitors who aren't logged in keep the draft of a new snippet in their session.
+	code, _, _ := ts.postForm(t, "/snippet/draft", url.Values{"title": {"Half a haiku"}, "content": {"Over the wintry"}})
+	assert.Equal(t, code, http.StatusNoContent)
+
+	code, _, body := ts.get(t, "/snippet/create")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Restored your draft")
+	assert.StringContains(t, body, "Over the wintry</textarea>")
+
+	// They can't keep drafts of edits, or drafts larger than an anonymous snippet.
+	code, _, _ = ts.postForm(t, "/snippet/draft", url.Values{"snippet": {"1"}, "title": {"Mine now"}})
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.postForm(t, "/snippet/draft", url.Values{"content": {strings.Repeat("x", anonymousMaxContent+1)}})
+	assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+
+	// Publishing the snippet discards the draft.
+	code, _, _ = ts.postForm(t, "/snippet/create", url.Values{
+		"title":         {"Half a haiku"},
+		"content":       {"Over the wintry forest"},
+		"expires":       {"7"},
+		"stub-response": {"human"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/snippet/create")
+	assert.Equal(t, strings.Contains(body, "Restored your draft"), false)
+
+	// Without anonymous posting, drafts need a login like the form.
+	app = newTestApplication(t)
+	closed := newTestServer(t, app.routes())
+	defer closed.Close()
+
+	code, header, _ := closed.postForm(t, "/snippet/draft", url.Values{"title": {"Half a haiku"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+}
+
+func TestSnippetLanguage(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, header, _ := ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"main.go"},
+		"content": {"package main\n\nfunc main() {}\n"},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/"+snippet.PublicID())
+	assert.Equal(t, snippet.Language, "go")
+
+	code, _, body := ts.follow(t, "/snippet/view/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<span>go</span>")
+
+	// Editing the snippet detects the language again.
+	code, _, _ = ts.postForm(t, "/snippet/edit/2", url.Values{
+		"title":   {"Greeting"},
+		"content": {"#!/usr/bin/env python3\nprint('hello')\n"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err = app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Language, "python")
+}
+
+func TestSnippetLicense(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{
+		"title":   {"Hello"},
+		"content": {"World"},
+		"expires": {"7"},
+		"license": {"WTFPL"},
+	}
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Choose a license from the list")
+
+	form.Set("license", "custom")
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Name the license")
+
+	form.Set("license", "Apache-2.0")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, body = ts.follow(t, "/snippet/view/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "License: <a href='https://spdx.org/licenses/Apache-2.0.html' rel='license'>Apache-2.0</a>")
+
+	// A license that isn't well-known is stored as named, and the edit form selects it as custom.
+	code, _, _ = ts.postForm(t, "/snippet/edit/2", url.Values{
+		"title":          {"Hello"},
+		"content":        {"World"},
+		"license":        {"custom"},
+		"custom_license": {" Company internal use only "},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.License, "Company internal use only")
+
+	code, _, body = ts.get(t, "/snippet/edit/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<option value='custom' selected>")
+	assert.StringContains(t, body, "value='Company internal use only'")
+}
+
+func TestSnippetMetadata(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{
+		"title":    {"Hello"},
+		"content":  {"World"},
+		"expires":  {"7"},
+		"metadata": {"os: linux\nos: darwin"},
+	}
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "The name os is used twice")
+
+	form.Set("metadata", "os linux")
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "isn&#39;t a name: value pair")
+
+	form.Set("metadata", "os: linux\r\n\r\nshell : bash ")
+	code, _, _ = ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	metadata, err := app.snippets.Metadata(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(metadata), 2)
+	assert.Equal(t, metadata[1], models.MetadataField{Name: "shell", Value: "bash"})
+
+	code, _, body = ts.follow(t, "/snippet/view/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<summary>Details</summary>")
+	assert.StringContains(t, body, "<th>shell</th>")
+
+	code, _, body = ts.get(t, "/snippet/edit/2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "os: linux\nshell: bash</textarea>")
+
+	code, _, body = ts.get(t, "/search?meta=os:linux")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, ">Hello</a><pre class='summary'>World</pre>")
+	assert.Equal(t, strings.Contains(body, "Save this search"), false)
+
+	code, _, body = ts.get(t, "/search?meta=os:darwin")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No snippets found.")
+
+	code, _, _ = ts.get(t, "/search?meta=os")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	// Clearing the field removes the metadata.
+	code, _, _ = ts.postForm(t, "/snippet/edit/2", url.Values{
+		"title":   {"Hello"},
+		"content": {"World"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	metadata, err = app.snippets.Metadata(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(metadata), 0)
+}
+
+func TestSnippetFormat(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ := ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"config.json"},
+		"content": {`{"port":4000}`},
+		"expires": {"7"},
+		"format":  {"true"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err := app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Content, "{\n  \"port\": 4000\n}\n")
+
+	// Content that can't be formatted is saved anyway, with a warning.
+	code, _, _ = ts.postForm(t, "/snippet/edit/2", url.Values{
+		"title":   {"config.json"},
+		"content": {`{"port":}`},
+		"format":  {"true"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	snippet, err = app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Content, `{"port":}`)
+
+	_, _, body := ts.follow(t, "/snippet/view/2")
+	assert.StringContains(t, body, "Snippet updated! It couldn&#39;t be formatted, so it was saved as written")
+}
+
+func TestSnippetDuplicate(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	form := url.Values{
+		"title":   {"Pond"},
+		"content": {"An old silent pond...  \r\n"},
+		"expires": {"7"},
+	}
+
+	// The poster is pointed at the existing snippet and nothing is stored.
+	code, _, body := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "This snippet has already been published as")
+	assert.StringContains(t, body, "<a href='/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6'>An old silent pond</a>")
+	assert.StringContains(t, body, "<input type='hidden' name='allow_duplicate' value='true'>")
+
+	_, err := app.snippets.Get(2)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	// They can publish theirs anyway.
+	form.Set("allow_duplicate", "true")
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	created, err := app.snippets.Get(2)
+	assert.NilError(t, err)
+	assert.Equal(t, header.Get("Location"), "/snippet/view/"+created.PublicID())
+}
+
+func TestExpiryReminders(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.BaseURL = "https://snippetbox.example.com"
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.postForm(t, "/account/reminders", url.Values{"days": {"2"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field must equal")
+
+	code, _, _ = ts.postForm(t, "/account/reminders", url.Values{"days": {"3"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, body = ts.get(t, "/account/reminders")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "You&#39;ll be reminded 3 days before your snippets expire.")
+	assert.StringContains(t, body, "<option value='3' selected>")
+
+	// Alice's snippet expires tomorrow.
+	if err := app.sendExpiryReminders(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := app.mailer.(*testMailer).receive(t)
+	assert.Equal(t, msg.To, "alice@example.com")
+	assert.Equal(t, msg.Subject, `Your snippet "An old silent pond" expires soon`)
+	assert.StringContains(t, msg.Body, "https://snippetbox.example.com/account/reminders")
+
+	link := regexp.MustCompile(`https://snippetbox\.example\.com(/snippet/extend/1\?token=\S+)`).FindStringSubmatch(msg.Body)
+	if link == nil {
+		t.Fatalf("no extension link in %q", msg.Body)
+	}
+
+	// Reminders are only sent once per expiry.
+	if err := app.sendExpiryReminders(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-app.mailer.(*testMailer).sent:
+		t.Errorf("unexpected email to %s", msg.To)
+	default:
+	}
+
+	before, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	expires := before.Expires
+
+	// The link works without logging in, and only once.
+	anonymous := newTestServer(t, app.routes())
+	defer anonymous.Close()
+
+	code, _, body = anonymous.get(t, link[1])
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "will now be kept until")
+
+	after, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, after.Expires.Equal(expires.Add(expiryExtension)), true)
+
+	code, _, _ = anonymous.get(t, link[1])
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = anonymous.get(t, "/snippet/extend/1?token=forged")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestDataExport(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.BaseURL = "https://snippetbox.example.com"
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ := ts.postForm(t, "/account/data-export", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, body := ts.get(t, "/account/data-export")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "We'll email you when it's ready")
+
+	if err := app.buildDataExports(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := app.mailer.(*testMailer).receive(t)
+	assert.Equal(t, msg.To, "alice@example.com")
+	assert.Equal(t, msg.Subject, "Your Snippetbox data is ready")
+	assert.StringContains(t, msg.Body, "https://snippetbox.example.com/account/data-export")
+
+	code, _, body = ts.get(t, "/account/data-export")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/account/data-export/download/1'>Download</a>")
+
+	code, header, body := ts.get(t, "/account/data-export/download/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Disposition"), `attachment; filename="snippetbox-data.json"`)
+
+	var archive struct {
+		Format   string
+		Profile  struct{ Email string }
+		Snippets []struct{ Title string }
+		Sessions []struct{ Expires time.Time }
+	}
+	if err := json.Unmarshal([]byte(body), &archive); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, archive.Format, "snippetbox-user-data")
+	assert.Equal(t, archive.Profile.Email, "alice@example.com")
+	assert.Equal(t, len(archive.Snippets), 1)
+	assert.Equal(t, archive.Snippets[0].Title, "An old silent pond")
+	assert.Equal(t, len(archive.Sessions), 1)
+
+	// Archives can only be downloaded by their owner.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, _ = other.get(t, "/account/data-export/download/1")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestAccountDelete(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "dupe@example.com", "pa$$word")
+
+	// A second login, which is logged out by the erasure too.
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+	other.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, body := ts.postForm(t, "/account/delete", url.Values{"password": {"wrong"}, "confirm": {"true"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Password is incorrect")
+
+	code, _, body = ts.postForm(t, "/account/delete", url.Values{"password": {"pa$$word"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Tick the box to confirm")
+
+	code, header, _ := ts.postForm(t, "/account/delete", url.Values{"password": {"pa$$word"}, "confirm": {"true"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/")
+
+	code, _, body = ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Your account has been deleted and your data erased.")
+
+	code, header, _ = other.get(t, "/account/digest")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/user/login")
+
+	// The tombstone identifies the user by the hash of their address.
+	admin := newTestServer(t, app.routes())
+	defer admin.Close()
+	admin.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body = admin.get(t, "/admin/erasures?email=DUPE@example.com")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>#2</td>")
+	assert.StringContains(t, body, "<td>The user</td>")
+
+	code, _, body = admin.get(t, "/admin/erasures?email=nobody@example.com")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "No user with this address has been erased.")
+}
+
+func TestAdminErase(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.postForm(t, "/admin/erasures", url.Values{"user_id": {"99"}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "There&#39;s no user with this ID")
+
+	code, _, _ = ts.postForm(t, "/admin/erasures", url.Values{"user_id": {"2"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, body = ts.get(t, "/admin/erasures")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "User 2 has been erased.")
+	assert.StringContains(t, body, "<td>Admin #1</td>")
+
+	exists, err := app.users.Exists(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, exists, false)
+}
+
+func TestAdminFilters(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+
+	admin := newTestServer(t, app.routes())
+	defer admin.Close()
+	admin.login(t, "alice@example.com", "pa$$word")
+
+	author := newTestServer(t, app.routes())
+	defer author.Close()
+	author.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, body := admin.postForm(t, "/admin/filters", url.Values{"action": {"shadow"}, "kind": {"regex"}, "pattern": {"("}})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "missing closing )")
+
+	code, _, _ = admin.postForm(t, "/admin/filters", url.Values{"action": {"shadow"}, "kind": {"word"}, "pattern": {"casino"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The author of a shadow-hidden snippet is told it was created and sees it as usual.
+	code, header, _ := author.postForm(t, "/snippet/create", url.Values{"title": {"Casino night"}, "content": {"Some content"}, "expires": {"7"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	location := header.Get("Location")
+
+	code, _, body = author.follow(t, location)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippet successfully created!")
+	assert.Equal(t, strings.Contains(body, "awaiting moderation"), false)
+
+	// Everyone else is kept out, and admins see why.
+	anonymous := newTestServer(t, app.routes())
+	defer anonymous.Close()
+
+	code, _, _ = anonymous.follow(t, location)
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, body = admin.follow(t, location)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "awaiting moderation")
+
+	code, _, body = admin.get(t, "/admin/filters")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<code>shadow word casino</code>")
+	assert.StringContains(t, body, "<td>#2</td>")
+	assert.StringContains(t, body, "<a href='"+location+"'>"+strings.TrimPrefix(location, "/snippet/view/")+"</a>")
+
+	// Patterns and the rules recorded with hits are escaped.
+	code, _, _ = admin.postForm(t, "/admin/filters", url.Values{"action": {"hold"}, "kind": {"regex"}, "pattern": {"<b>bold</b>"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	code, _, _ = author.postForm(t, "/snippet/create", url.Values{"title": {"<b>bold</b>"}, "content": {"Some content"}, "expires": {"7"}})
+	assert.Equal(t, code, http.StatusSeeOther)
+	_, _, body = admin.get(t, "/admin/filters")
+	assert.StringContains(t, body, "<code>hold regex &lt;b&gt;bold&lt;/b&gt;</code>")
+	assert.Equal(t, strings.Contains(body, "<b>bold</b>"), false)
+
+	code, _, body = admin.get(t, "/admin/moderation")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Shadow-hidden")
+
+	code, _, _ = admin.postForm(t, "/admin/filters/delete/1", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	code, _, _ = admin.postForm(t, "/admin/filters/delete/1", url.Values{})
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = author.postForm(t, "/admin/filters", url.Values{"action": {"hold"}, "kind": {"word"}, "pattern": {"spam"}})
+	assert.Equal(t, code, http.StatusForbidden)
+}
+
+func TestWebmentions(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.Webmentions = true
+	app.config.BaseURL = "https://snippetbox.example"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	target := "https://snippetbox.example/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond"
+
+	// A page of another site that links to the snippet, with a title that must be escaped.
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><title>Ponds &lt;3</title><a href="%s">A haiku</a></html>`, target)
+	}))
+	defer source.Close()
+
+	tests := []struct {
+		name   string
+		source string
+		target string
+		want   int
+	}{
+		{"Not a URL", "pond", target, http.StatusBadRequest},
+		{"Other site", source.URL, "https://elsewhere.example/snippet/view/1", http.StatusBadRequest},
+		{"Not a snippet", source.URL, "https://snippetbox.example/about", http.StatusBadRequest},
+		{"Missing snippet", source.URL, "https://snippetbox.example/snippet/view/99", http.StatusBadRequest},
+		{"Valid", source.URL, target, http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.postForm(t, "/webmention", url.Values{"source": {tt.source}, "target": {tt.target}})
+			assert.Equal(t, code, tt.want)
+		})
+	}
+
+	// Mentions are only shown once they're verified.
+	code, header, body := ts.follow(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Link"), `</webmention>; rel="webmention"`)
+	assert.Equal(t, strings.Contains(body, "Mentions"), false)
+
+	assert.NilError(t, app.verifyWebmentions())
+
+	_, _, body = ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<a href='"+source.URL+"' rel='nofollow ugc'>Ponds &lt;3</a>")
+
+	// Markdown snippets send mentions to the pages they link to.
+	received := make(chan url.Values, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			received <- r.PostForm
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Link", `</endpoint>; rel="webmention"`)
+	}))
+	defer receiver.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, _ = ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"notes.md"},
+		"content": {"# Notes\n\nSee [this post](" + receiver.URL + "/post).\n"},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	select {
+	case form := <-received:
+		assert.Equal(t, form.Get("target"), receiver.URL+"/post")
+		assert.Equal(t, strings.HasPrefix(form.Get("source"), "https://snippetbox.example/~alice/"), true)
+	case <-time.After(time.Second):
+		t.Fatal("no webmention was sent")
+	}
+}
+
+func TestAccountImport(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	expires := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	archive := `{"format": "snippetbox-user-data", "version": 1, "exported": "2024-04-01T00:00:00Z",
+		"profile": {"id": 1, "name": "Alice", "email": "alice@example.com"},
+		"snippets": [
+			{"id": 1, "ulid": "01HV6Z9K1QX8M3N5P7R9T2V4W6", "title": "A restored pond", "content": "Restored", "expires": "` + expires + `"},
+			{"id": 7, "title": "Imported", "content": "Imported content", "expires": "` + expires + `", "private": true},
+			{"id": 8, "title": "Gone", "content": "Expired content", "expires": "2024-01-01T00:00:00Z"},
+			{"id": 9, "title": "Team", "content": "Organization content", "expires": "` + expires + `", "org_id": 3}
+		],
+		"collections": [{"id": 1, "name": "Favourites", "public": true, "snippet_ids": [1, 7, 42]}],
+		"saved_searches": [{"name": "Go", "query": "func", "language": "go", "notify": true}],
+		"digest_frequency": "weekly",
+		"expiry_reminder_days": 3}`
+
+	// A dry run reports what would be imported without changing anything.
+	before, err := app.collections.ByOwner(1)
+	assert.NilError(t, err)
+
+	code, _, body := ts.postFile(t, "/account/import", url.Values{"conflicts": {"skip"}, "dry_run": {"true"}}, archive)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Nothing has been changed yet.")
+	assert.StringContains(t, body, "Would be created")
+	assert.StringContains(t, body, "It has expired.")
+	assert.StringContains(t, body, "It belongs to an organization.")
+
+	collections, err := app.collections.ByOwner(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(collections), len(before))
+
+	// Importing keeps the snippet the user already has, and creates the rest.
+	code, _, body = ts.postFile(t, "/account/import", url.Values{"conflicts": {"skip"}}, archive)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The archive has been imported.")
+	assert.StringContains(t, body, "1 of its snippets weren&#39;t imported.")
+
+	pond, err := app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, pond.Title, "An old silent pond")
+
+	collections, err = app.collections.ByOwner(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(collections), len(before)+1)
+
+	var favourites int
+	for _, c := range collections {
+		if c.Name == "Favourites" {
+			favourites = c.ID
+		}
+	}
+
+	ids, err := app.collections.SnippetIDs(favourites)
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 2)
+
+	imported, err := app.snippets.Get(ids[0])
+	assert.NilError(t, err)
+	assert.Equal(t, imported.Title, "Imported")
+	assert.Equal(t, imported.Private, true)
+
+	frequency, err := app.digests.Frequency(1)
+	assert.NilError(t, err)
+	assert.Equal(t, frequency, "weekly")
+
+	searches, err := app.savedSearches.ByUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(searches), 1)
+
+	// Replacing overwrites the snippet, and merges the collection the first import created.
+	code, _, body = ts.postFile(t, "/account/import", url.Values{"conflicts": {"replace"}}, archive)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "merged")
+
+	pond, err = app.snippets.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, pond.Title, "A restored pond")
+
+	code, _, body = ts.postFile(t, "/account/import", url.Values{"conflicts": {"skip"}}, `{"format": "something-else"}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This file isn&#39;t a Snippetbox data archive")
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.ReadOnly = true
+	app.config.ReadOnlyLogins = true
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Snippetbox is read-only for maintenance.")
+
+	code, header, body := ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Read-only"},
+		"content": {"Nothing to see"},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, header.Get("Retry-After"), "300")
+	assert.StringContains(t, body, "nothing was changed")
+
+	code, _, body = ts.postJSON(t, "/api/v1/shortlinks", `{"snippet": "1"}`)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.StringContains(t, body, `"detail":"The site is read-only for maintenance; nothing was changed."`)
+
+	// Without read-only logins, logging in is a write like any other.
+	app.config.ReadOnlyLogins = false
+
+	other := newTestServer(t, app.routes())
+	defer other.Close()
+
+	code, _, _ = other.postForm(t, "/user/login", url.Values{"email": {"alice@example.com"}, "password": {"pa$$word"}})
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+}
+
+func TestBounceWebhook(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.BounceWebhookSecret = "s3cret"
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	auth := http.Header{"Authorization": {"Bearer s3cret"}}
+
+	code, _, _ := ts.sendJSON(t, http.MethodPost, "/webhooks/bounce", http.Header{"Authorization": {"Bearer wrong"}},
+		`{"email": "dupe@example.com", "type": "hard"}`)
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	code, _, body := ts.sendJSON(t, http.MethodPost, "/webhooks/bounce", auth, `{"email": "dupe@example.com", "type": "bounced"}`)
+	assert.Equal(t, code, http.StatusBadRequest)
+	assert.StringContains(t, body, "hard, soft or complaint")
+
+	code, _, _ = ts.sendJSON(t, http.MethodPost, "/webhooks/bounce", auth,
+		`[{"email": "dupe@example.com", "type": "hard", "detail": "550 5.1.1 No such user"}, {"email": "bob@example.com", "type": "soft"}]`)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	suppressed, err := app.suppressions.Suppressed("dupe@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, suppressed, true)
+
+	// Email to the suppressed address is skipped.
+	sent := app.mailer.(*testMailer).sent
+	app.mailer = &mailer.Suppressing{Sender: app.mailer, List: app.suppressions, Logger: app.infoLog}
+	assert.NilError(t, app.mailer.Send(mailer.Message{To: "dupe@example.com", Subject: "Hello"}))
+	assert.Equal(t, len(sent), 0)
+
+	// The user sees the state and can have email sent again.
+	ts.login(t, "dupe@example.com", "pa$$word")
+
+	code, _, body = ts.get(t, "/account/email")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "We've stopped sending email to this address")
+	assert.StringContains(t, body, "550 5.1.1 No such user")
+
+	code, _, _ = ts.postForm(t, "/account/email/reactivate", url.Values{})
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body = ts.get(t, "/account/email")
+	assert.StringContains(t, body, "Email to this address is being delivered.")
+}
+
+func TestSnippetDiff(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	id := app.snippets.(*mocks.SnippetModel).Add(&models.Snippet{
+		ULID:    "01HV6Z9K1QX8M3N5P7R9T2V4X8",
+		Title:   "Haiku",
+		Content: "An old silent pond\nA frog jumps into the pond\nsplash! Silence again.\n",
+		Expires: time.Now().Add(time.Hour),
+	})
+	err := app.snippets.Update(id, "Haiku", "An old silent pond\nA frog jumps into the pond\nsplash! <Silence> again.\n", 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := fmt.Sprintf("/snippet/diff/%d", id)
+
+	// Without parameters the last two revisions are compared.
+	code, _, body := ts.get(t, path)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<span class='header'>--- revision 1</span>")
+	assert.StringContains(t, body, "<span class='header'>&#43;&#43;&#43; revision 2</span>")
+	assert.StringContains(t, body, "<span class='removed'>-splash! Silence again.</span>")
+	assert.StringContains(t, body, "<span class='added'>&#43;splash! &lt;Silence&gt; again.</span>")
+	assert.StringContains(t, body, "<span class='context'> A frog jumps into the pond</span>")
+
+	code, _, body = ts.get(t, path+"?from=2&to=2")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The content is the same in both revisions.")
+
+	code, _, _ = ts.get(t, path+"?from=abc")
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	code, _, _ = ts.get(t, path+"?to=3")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	code, _, _ = ts.get(t, "/snippet/diff/999")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	// Revisions too long to compare quickly aren't.
+	long := strings.Repeat("line\n", maxDiffLines+1)
+	assert.NilError(t, app.snippets.Update(id, "Haiku", long, 1, true))
+	code, _, body = ts.get(t, path)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "These revisions are too long to compare here.")
+	assert.Equal(t, strings.Contains(body, "<span class='added'>"), false)
+}
+
+func TestSnippetSlug(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	tests := []struct {
+		name     string
+		slug     string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid", "frog-haiku", http.StatusSeeOther, ""},
+		{"Taken", "frog-haiku", http.StatusUnprocessableEntity, "This URL is already taken"},
+		{"Reserved", "admin", http.StatusUnprocessableEntity, "This URL is reserved"},
+		{"Invalid characters", "Frog_Haiku", http.StatusUnprocessableEntity, "This field may only contain lowercase letters, digits and hyphens"},
+		{"Integer ID", "1234", http.StatusUnprocessableEntity, "This URL can&#39;t look like a snippet ID"},
+		{"ULID", "01hv6z9k1qx8m3n5p7r9t2v4w6", http.StatusUnprocessableEntity, "This URL can&#39;t look like a snippet ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A frog jumps")
+			form.Add("content", "A frog jumps into the pond "+tt.name)
+			form.Add("expires", "7")
+			form.Add("slug", tt.slug)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	code, _, body := ts.get(t, "/s/frog-haiku")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<strong>A frog jumps</strong>")
+	assert.StringContains(t, body, "<a href='/s/frog-haiku'>/s/frog-haiku</a>")
+
+	// Public IDs still work at the same URL.
+	code, _, body = ts.get(t, "/s/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<strong>An old silent pond</strong>")
+
+	code, _, _ = ts.get(t, "/s/no-such-slug")
+	assert.Equal(t, code, http.StatusNotFound)
+}
+
+func TestSnippetViewLines(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	id := app.snippets.(*mocks.SnippetModel).Add(&models.Snippet{
+		Title:   "Four lines",
+		Content: "one\ntwo\nthree\nfour\n",
+		Expires: time.Now().Add(time.Hour),
+	})
+	path := fmt.Sprintf("/snippet/view/%d", id)
+
+	// Every line number links to its line.
+	code, _, body := ts.get(t, path)
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, `<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`)
+	assert.StringContains(t, body, "data-link='' hidden>Copy link to selection</button>")
+
+	code, _, body = ts.get(t, path+"?lines=2-3")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, strings.Count(body, `<span class="line hl">`), 2)
+	assert.StringContains(t, body, fmt.Sprintf("data-link='/snippet/view/%d?lines=2-3#L2-L3'>Copy link to selection</button>", id))
+
+	// Ranges past the last line are cut to it, and invalid ones are ignored.
+	_, _, body = ts.get(t, path+"?lines=L3-L99")
+	assert.Equal(t, strings.Count(body, `<span class="line hl">`), 2)
+
+	_, _, body = ts.get(t, path+"?lines=3-1")
+	assert.Equal(t, strings.Count(body, `<span class="line hl">`), 0)
+}
+
+func TestSnippetSizeLimits(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.MaxTitleBytes = 20
+	app.config.MaxContentBytes = 1 << 10
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	// Titles and content over the limits are rejected with the form.
+	code, _, body := ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Über den Gipfeln ist Ruh"},
+		"content": {strings.Repeat("x", 1<<10+1)},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "This field cannot be more than 20 bytes long")
+	assert.StringContains(t, body, "Snippets can be at most 1 KB")
+
+	code, _, body = ts.postForm(t, "/snippet/edit/1", url.Values{
+		"title":   {"An old silent pond"},
+		"content": {strings.Repeat("x", 1<<10+1)},
+	})
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Snippets can be at most 1 KB")
+
+	// Bodies too large to decode are answered with the limits rather than a bare 400.
+	code, _, body = ts.postForm(t, "/snippet/create", url.Values{
+		"title":   {"Too much"},
+		"content": {strings.Repeat("x", int(app.maxSnippetBody()))},
+		"expires": {"7"},
+	})
+	assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+	assert.StringContains(t, body, "Snippets can be at most 1 KB, with a title of at most 20 bytes.")
+
+	code, _, body = ts.postJSON(t, "/api/v1/snippets", `{"title": "Too much", "content": "`+strings.Repeat("x", int(app.maxSnippetBody()))+`", "expires": 7}`)
+	assert.Equal(t, code, http.StatusRequestEntityTooLarge)
+	assert.StringContains(t, body, "Snippets can be at most 1 KB")
+
+	code, _, body = ts.postJSON(t, "/api/v1/snippets", `{"title": "Too much", "content": "`+strings.Repeat("x", 1<<10+1)+`", "expires": 7}`)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Snippets can be at most 1 KB")
+}
+
+func TestOEmbed(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.snippets.(*mocks.SnippetModel).Add(&models.Snippet{
+		ULID:    "01HV6Z9K1QX8M3N5P7R9T2V4W7",
+		Title:   "Secret",
+		Content: "for your eyes only",
+		Expires: time.Now().Add(time.Hour),
+		OwnerID: 1,
+		Private: true,
+	})
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	base := siteURL(&http.Request{Host: strings.TrimPrefix(ts.URL, "https://")})
+	oembedURL := func(link string) string {
+		return "/oembed?url=" + url.QueryEscape(link)
+	}
+
+	// The page of a public snippet links to its description.
+	_, _, body := ts.follow(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<link rel='alternate' type='application/json+oembed' href='"+base+oembedURL(base+"/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6")+"&amp;format=json'")
+
+	// Every URL of the snippet is described, with its author and a preview.
+	for _, link := range []string{"/snippet/view/1", "/s/01HV6Z9K1QX8M3N5P7R9T2V4W6", "/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond"} {
+		code, header, body := ts.get(t, oembedURL(base+link))
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+		var resp oembedResponse
+		assert.NilError(t, json.Unmarshal([]byte(body), &resp))
+		assert.Equal(t, resp.Type, "rich")
+		assert.Equal(t, resp.Version, "1.0")
+		assert.Equal(t, resp.Title, "An old silent pond")
+		assert.Equal(t, resp.AuthorName, "Alice Jones")
+		assert.Equal(t, resp.AuthorURL, base+"/~alice")
+		assert.Equal(t, resp.HTML, "<blockquote class='snippetbox'><p><a href='"+base+"/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond'>An old silent pond</a></p><pre><code>An old silent pond...</code></pre></blockquote>")
+		assert.Equal(t, resp.Width, oembedWidth)
+	}
+
+	code, header, body := ts.get(t, oembedURL(base+"/snippet/view/1")+"&format=xml&maxwidth=300&maxheight=50")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/xml; charset=utf-8")
+	assert.StringContains(t, body, "<oembed><type>rich</type><version>1.0</version><title>An old silent pond</title>")
+	assert.StringContains(t, body, "<width>300</width><height>50</height>")
+
+	// Private snippets can't be embedded, and other URLs and formats aren't supported.
+	code, _, _ = ts.get(t, oembedURL(base+"/snippet/view/2"))
+	assert.Equal(t, code, http.StatusUnauthorized)
+	code, _, _ = ts.get(t, oembedURL(base+"/snippet/view/99"))
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, oembedURL("https://example.com/snippet/view/1"))
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, oembedURL(base+"/~dupe/01HV6Z9K1QX8M3N5P7R9T2V4W6"))
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _, _ = ts.get(t, oembedURL(base+"/snippet/view/1")+"&format=yaml")
+	assert.Equal(t, code, http.StatusNotImplemented)
+}
@@ -3,28 +3,80 @@ package main
 
 // Import the necessary packages.
 import (
-	"bytes" // Package for manipulating byte slices.
+	"bytes"         // Package for the buffers pages are rendered into.
+	"encoding/json" // Package for encoding JSON responses.
 	"errors"
 	"fmt"      // Package for formatted I/O.
+	"net"      // Package for parsing network addresses.
 	"net/http" // Package for building HTTP servers and clients.
+	"net/url"  // Package for building the links of paginated listings.
 
 	// Package for manipulating file paths.
 	"runtime/debug" // Package for providing information about the Go runtime.
+	"strconv"       // Package for converting strings to numeric types.
 	"time"          // Package for measuring and displaying time.
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+	"github.com/oklog/ulid/v2"            // Import the ULID package for incident IDs.
+
+	"snippetbox.adcon.dev/internal/mailer"       // Import the email package.
+	"snippetbox.adcon.dev/internal/models"       // Import the models package.
+	"snippetbox.adcon.dev/internal/servertiming" // Import the request timing package.
+	"snippetbox.adcon.dev/ui"                    // Import the embedded templates.
 )
 
 // serverError is a helper function that writes an error message and stack trace to the errorLog,
 // then sends a 500 Internal Server Error response to the user. It takes an http.ResponseWriter to
-// write the response to, and an error to log and respond with.
-func (app *application) serverError(w http.ResponseWriter, err error) {
-	// Create a stack trace and store it in the variable trace.
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	// Write the error message and stack trace to the errorLog.
+// write the response to, the request that failed, and an error to log.
+//
+// Each error gets an incident ID, which is logged with the request and shown on the error page, or
+// in the problem details of API requests, so that users can quote it when they report the problem.
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	incident := ulid.Make().String()
+
+	// Log the incident with the request, the error and the stack trace.
+	user := "anonymous"
+	if app.isAuthenticated(r) {
+		user = fmt.Sprintf("user %d", app.authenticatedUserID(r))
+	}
+	trace := fmt.Sprintf("incident %s: %s %s from %s%s (%s): %s\n%s",
+		incident, r.Method, r.URL.RequestURI(), app.logAddr(r), logCountry(r), user, err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
-	// Use the http.Error function to send a 500 status to the user.
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+	w.Header().Set("X-Incident-Id", incident)
+
+	if isAPIRequest(r) {
+		app.writeProblemDetails(w, problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(http.StatusInternalServerError),
+			Status:   http.StatusInternalServerError,
+			Detail:   "The server couldn't complete the request. Quote the incident ID when you report the problem.",
+			Instance: r.URL.Path,
+			Incident: incident,
+		})
+		return
+	}
+
+	// The error page is rendered with as little as possible, since the session or the templates may
+	// be what failed, and falls back to plain text.
+	data := &templateData{
+		CurrentYear: app.clock.Now().Year(),
+		CSPNonce:    cspNonce(r),
+		ErrorStatus: http.StatusInternalServerError,
+		ErrorDetail: "Something went wrong on our side.",
+		IncidentID:  incident,
+	}
+	buf, err := app.execute("error.html", data)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError)+"\nIncident ID: "+incident, http.StatusInternalServerError)
+		return
+	}
+	defer app.buffers.put("error.html", buf)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	buf.WriteTo(w)
 }
 
 // clientError is a helper function that sends a specific status code and corresponding description
@@ -46,33 +98,59 @@ func (app *application) notFound(w http.ResponseWriter) {
 // http.ResponseWriter, along with the provided HTTP status code. If the template does not exist
 // in the cache, it sends a server error response. If there's an error when executing the template,
 // it also sends a server error response.
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
+	// Render the page into a buffer from the pool, and return the buffer once the response has been
+	// written. Rendering into a buffer first means that a template error can still be reported
+	// with a 500 status instead of a half-written page.
+	buf, err := app.execute(page, data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer app.buffers.put(page, buf)
+
+	// Write the HTTP status code to the http.ResponseWriter header.
+	w.WriteHeader(status)
+	// Write the contents of the buffer to the http.ResponseWriter.
+	// This sends the rendered template as the response body.
+	buf.WriteTo(w)
+}
+
+// execute renders a page into a buffer taken from the pool, which the caller puts back once it's
+// done with it. It returns an error if the page doesn't exist or its template fails.
+func (app *application) execute(page string, data *templateData) (*bytes.Buffer, error) {
 	// Try to get the template set for the provided page from the cache.
-	ts, ok := app.templateCache[page]
 	// If the template set is not in the cache, that means the template does not exist.
-	// In that case, send a server error response.
+	ts, ok := app.uiCache.template(page)
 	if !ok {
-		err := fmt.Errorf("the template %s does not exist", page)
-		app.serverError(w, err)
-		return
+		return nil, fmt.Errorf("the template %s does not exist", page)
+	}
+
+	// Count the rendering time towards the request's Server-Timing.
+	start := time.Now()
+	defer func() { servertiming.Add("render", time.Since(start)) }()
+
+	buf := app.buffers.get(page)
+	if err := ts.ExecuteTemplate(buf, "base", data); err != nil {
+		app.buffers.put(page, buf)
+		return nil, err
 	}
 
-	// Create a new bytes.Buffer to hold the rendered template.
-	// This buffer is an io.Writer, so we can write the rendered template to it.
-	buf := new(bytes.Buffer)
-	// Render the template and write it to the buffer.
-	// If there's an error, send a server error response.
-	err := ts.ExecuteTemplate(buf, "base", data)
+	return buf, nil
+}
+
+// writeJSON is a helper function that encodes data as JSON and writes it to the http.ResponseWriter
+// with the provided HTTP status code. If the data can't be encoded, it sends a server error response.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.Marshal(data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
-	// Write the HTTP status code to the http.ResponseWriter header.
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	// Write the contents of the buffer to the http.ResponseWriter.
-	// This sends the rendered template as the response body.
-	buf.WriteTo(w)
+	w.Write(append(js, '\n'))
 }
 
 // newTemplateData is a helper function that creates a new instance of templateData.
@@ -82,13 +160,32 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 	// Create a new templateData instance.
 	// Set the CurrentYear field to the current year.
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
+		CurrentYear:      app.clock.Now().Year(),
+		Flash:            app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:  app.isAuthenticated(r),
+		IsAdmin:          app.isAdmin(r),
+		CurrentUserID:    app.authenticatedUserID(r),
+		FormStarted:      app.clock.Now().Unix(),
+		ReadOnly:         app.config.ReadOnly,
+		AnonymousPosting: app.config.AnonymousPosting,
+		CSPNonce:         cspNonce(r),
+		Impersonating:    app.sessionManager.GetString(r.Context(), impersonatedUsernameKey),
+		Drafts:           app.draftsEnabled(r),
 	}
 }
 
-func (app *application) decodePostForm(r *http.Request, target any) error {
+// cspNonce returns the nonce of the request's Content-Security-Policy, or an empty string if the
+// response has no policy.
+func cspNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceContextKey).(string)
+	return nonce
+}
+
+// decodePostForm decodes the form in the body of r into target. Bodies larger than the size limits
+// of snippets allow are cut off with an *http.MaxBytesError, which formError reports.
+func (app *application) decodePostForm(w http.ResponseWriter, r *http.Request, target any) error {
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.maxSnippetBody())
 
 	err := r.ParseForm()
 	if err != nil {
@@ -115,3 +212,208 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// authenticatedUserID returns the ID of the logged-in user, or of the owner of the API token the
+// request was authenticated with, or 0 for anonymous visitors.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	if t := requestToken(r); t != nil {
+		return t.UserID
+	}
+
+	return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// isAdmin reports whether the current request was made by an authenticated admin.
+func (app *application) isAdmin(r *http.Request) bool {
+	isAdmin, ok := r.Context().Value(isAdminContextKey).(bool)
+	if !ok {
+		return false
+	}
+
+	return isAdmin
+}
+
+// clientIP returns the address of the client that made the request, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// canView reports whether the current user may see a snippet. Snippets held for moderation and
+// private snippets are only visible to their owner and to admins, and private snippets of an
+// organization to the members its permission rules let read them; share links are checked
+// separately.
+func (app *application) canView(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Held && !snippet.Private {
+		return true
+	}
+
+	if app.isAdmin(r) {
+		return true
+	}
+
+	userID := app.authenticatedUserID(r)
+	if userID == 0 {
+		return false
+	}
+	if userID == snippet.OwnerID {
+		return true
+	}
+
+	if snippet.OrgID != 0 && !snippet.Held {
+		level, err := app.snippets.Permission(snippet.ID, userID)
+		return err == nil && models.Allows(level, models.PermissionRead)
+	}
+
+	return false
+}
+
+// sendEmail renders the named template of ui/email for a recipient and sends it in the background,
+// so that a slow mail server doesn't hold up the response. Failures are logged.
+func (app *application) sendEmail(to, name string, data any) {
+	go app.runJob("send "+name, func() error {
+		msg, err := mailer.Render(ui.Files, "email/"+name, to, data)
+		if err != nil {
+			return err
+		}
+		return app.mailer.Send(msg)
+	})
+}
+
+// snippetFromParams fetches the snippet identified by the "id" URL parameter, which may be either
+// the snippet's ULID or its integer ID. It returns models.ErrNoRecord if the parameter is neither
+// or if no matching snippet exists.
+func (app *application) snippetFromParams(r *http.Request) (*models.Snippet, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	return app.snippetByPublicID(params.ByName("id"))
+}
+
+// viewableSnippet fetches the snippet with a public ID for a handler that shows it. If the snippet
+// doesn't exist or the user may not see it, it sends a 404 response and returns false.
+func (app *application) viewableSnippet(w http.ResponseWriter, r *http.Request, id string) (*models.Snippet, bool) {
+	snippet, err := app.snippetByPublicID(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	// Held and private snippets are only visible to their owner and to admins.
+	if !app.canView(r, snippet) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// snippetByPublicID fetches a snippet by an identifier taken from a URL, which may be either the
+// snippet's ULID or its integer ID.
+func (app *application) snippetByPublicID(param string) (*models.Snippet, error) {
+	if models.IsULID(param) {
+		return app.snippets.GetByULID(param)
+	}
+
+	id, err := strconv.Atoi(param)
+	// If the ID is not a valid integer or is less than 1, treat it as a missing record.
+	if err != nil || id < 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	return app.snippets.Get(id)
+}
+
+// maxPage is the last page of a listing that can be asked for, since later pages get slower to
+// fetch and nobody reads that far.
+const maxPage = 1000
+
+// pageLinkSpan is the number of pages linked on either side of the page shown.
+const pageLinkSpan = 3
+
+// pagination holds the links between the pages of a listing.
+type pagination struct {
+	Page  int        // Page is the number of the page shown, starting at 1.
+	Pages int        // Pages is the number of pages, or 0 when the listing isn't counted.
+	Prev  string     // Prev is the URL of the previous page, or empty on the first page.
+	Next  string     // Next is the URL of the next page, or empty on the last page.
+	Links []pageLink // Links holds the numbered pages around the page shown, when there are several.
+}
+
+// pageLink is a link to a numbered page of a listing.
+type pageLink struct {
+	Number  int    // Number is the number of the page.
+	URL     string // URL is the address of the page.
+	Current bool   // Current reports whether it's the page shown.
+}
+
+// newPagination returns the pagination of a page of the listing at path with the query parameters
+// params, where more reports whether there are results after the page.
+func newPagination(path string, params url.Values, page int, more bool) *pagination {
+	p := &pagination{Page: page}
+	if page > 1 {
+		p.Prev = pageURL(path, params, page-1)
+	}
+	if more && page < maxPage {
+		p.Next = pageURL(path, params, page+1)
+	}
+
+	return p
+}
+
+// newCountedPagination returns the pagination of a page of the listing at path with the query
+// parameters params, which holds total results in pages of pageSize, with links to the pages
+// around it.
+func newCountedPagination(path string, params url.Values, page, total, pageSize int) *pagination {
+	pages := min(max(1, (total+pageSize-1)/pageSize), maxPage)
+
+	p := newPagination(path, params, page, page < pages)
+	p.Pages = pages
+
+	if pages > 1 {
+		for n := max(1, page-pageLinkSpan); n <= min(pages, page+pageLinkSpan); n++ {
+			p.Links = append(p.Links, pageLink{Number: n, URL: pageURL(path, params, n), Current: n == page})
+		}
+	}
+
+	return p
+}
+
+// pageURL returns the URL of a page of the listing at path with the query parameters params. The
+// first page has no "page" parameter.
+func pageURL(path string, params url.Values, page int) string {
+	v := url.Values{}
+	for name, values := range params {
+		v[name] = values
+	}
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	if len(v) == 0 {
+		return path
+	}
+	return path + "?" + v.Encode()
+}
+
+// pageParam returns the page of a listing asked for by the "page" query parameter, which is the
+// first one when it's missing. It returns false if the parameter isn't a page number.
+func pageParam(r *http.Request) (int, bool) {
+	param := r.URL.Query().Get("page")
+	if param == "" {
+		return 1, true
+	}
+
+	page, err := strconv.Atoi(param)
+	if err != nil || page < 1 || page > maxPage {
+		return 0, false
+	}
+
+	return page, true
+}
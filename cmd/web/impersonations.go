@@ -0,0 +1,252 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+	"time"     // Package for measuring and displaying time.
+
+	"github.com/julienschmidt/httprouter" // Router package for handling HTTP requests.
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// The session keys of an impersonation. While an admin impersonates a user, authenticatedUserID
+// holds the user's ID and impersonatorKey the admin's.
+const (
+	impersonatorKey         = "impersonatorID"
+	impersonationKey        = "impersonationID"
+	impersonatedUsernameKey = "impersonatedUsername"
+	impersonationExpiresKey = "impersonationExpires"
+)
+
+// impersonationLimit is how long an impersonation lasts before the admin gets their own session
+// back.
+const impersonationLimit = time.Hour
+
+// impersonationListLimit is the number of recent impersonations listed on the admin dashboard.
+const impersonationListLimit = 20
+
+// adminImpersonateForm represents the form admins start impersonating a user with.
+type adminImpersonateForm struct {
+	Username            string `form:"username" validate:"required"`
+	Reason              string `form:"reason" validate:"required,maxrunes=200"`
+	validator.Validator `form:"-"`
+}
+
+// impersonatorID returns the ID of the admin impersonating the current user, or 0 if the session
+// isn't an impersonation.
+func (app *application) impersonatorID(r *http.Request) int {
+	return app.sessionManager.GetInt(r.Context(), impersonatorKey)
+}
+
+// adminImpersonatePost serves the "/admin/impersonate" URL. It records why the admin impersonates
+// the user in the form and switches the session over to the user, so that the admin sees the site
+// the way the user does. Other admins can't be impersonated.
+func (app *application) adminImpersonatePost(w http.ResponseWriter, r *http.Request) {
+	var form adminImpersonateForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.Username = strings.TrimPrefix(strings.TrimSpace(form.Username), "~")
+	form.Reason = strings.TrimSpace(form.Reason)
+	form.CheckStruct(form)
+
+	adminID := app.authenticatedUserID(r)
+
+	var user *models.User
+	if form.Valid() {
+		var err error
+		user, err = app.users.GetByUsername(form.Username)
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("username", "There's no user with this username")
+		case err != nil:
+			app.serverError(w, r, err)
+			return
+		case user.ID == adminID:
+			form.AddFieldError("username", "You can't impersonate yourself")
+		case user.Admin:
+			form.AddFieldError("username", "Admins can't be impersonated")
+		}
+	}
+
+	if !form.Valid() {
+		app.renderAdminDashboard(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	id, err := app.impersonations.Start(adminID, user.ID, form.Reason, app.storedIP(r), clientCountry(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.infoLog.Printf("Impersonation %d: admin %d%s started impersonating user %d (%s): %s", id, adminID, logCountry(r), user.ID, user.Username, form.Reason)
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), impersonatorKey, adminID)
+	app.sessionManager.Put(r.Context(), impersonationKey, id)
+	app.sessionManager.Put(r.Context(), impersonatedUsernameKey, user.Username)
+	app.sessionManager.Put(r.Context(), impersonationExpiresKey, app.clock.Now().Add(impersonationLimit).Unix())
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", user.ID)
+	app.sessionManager.Put(r.Context(), "flash", "You're now impersonating "+user.Username+".")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// impersonationStopPost serves the "/impersonation/stop" URL. It gives the admin impersonating the
+// current user their own session back.
+func (app *application) impersonationStopPost(w http.ResponseWriter, r *http.Request) {
+	adminID, err := app.endImpersonation(r, "stopped")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if adminID == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've stopped impersonating.")
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// endImpersonation records the end of the session's impersonation, if it is one, and logs the admin
+// back in as themselves. It returns the ID of the admin, or 0 if the session wasn't an
+// impersonation.
+func (app *application) endImpersonation(r *http.Request, how string) (int, error) {
+	ctx := r.Context()
+
+	adminID := app.sessionManager.GetInt(ctx, impersonatorKey)
+	if adminID == 0 {
+		return 0, nil
+	}
+
+	id := app.sessionManager.GetInt(ctx, impersonationKey)
+	if err := app.impersonations.Stop(id); err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	app.infoLog.Printf("Impersonation %d: admin %d %s impersonating user %d", id, adminID, how, app.authenticatedUserID(r))
+
+	if err := app.sessionManager.RenewToken(ctx); err != nil {
+		return 0, err
+	}
+
+	app.sessionManager.Remove(ctx, impersonatorKey)
+	app.sessionManager.Remove(ctx, impersonationKey)
+	app.sessionManager.Remove(ctx, impersonatedUsernameKey)
+	app.sessionManager.Remove(ctx, impersonationExpiresKey)
+	app.sessionManager.Put(ctx, "authenticatedUserID", adminID)
+
+	return adminID, nil
+}
+
+// expireImpersonation ends the session's impersonation once it's past impersonationLimit. It's
+// called by the authenticate middleware before the session's user is looked up.
+func (app *application) expireImpersonation(r *http.Request) error {
+	if app.impersonatorID(r) == 0 {
+		return nil
+	}
+
+	// The expiry is kept as a Unix time, which every session store can encode.
+	expires := app.sessionManager.GetInt64(r.Context(), impersonationExpiresKey)
+	if app.clock.Now().Unix() < expires {
+		return nil
+	}
+
+	if _, err := app.endImpersonation(r, "timed out"); err != nil {
+		return err
+	}
+	app.sessionManager.Put(r.Context(), "flash", "Your impersonation timed out.")
+
+	return nil
+}
+
+// refuseImpersonation is a middleware function that keeps admins impersonating a user away from
+// sensitive account actions: creating API tokens, which would outlive the impersonation, and
+// exporting, importing or erasing the account or changing its settings. They get a 403 Forbidden
+// response.
+func (app *application) refuseImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.impersonatorID(r) != 0 {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditImpersonation is a middleware function that records every request made while an admin
+// impersonates a user, with the status of its response, in the audit trail of the impersonation.
+// Refused requests are recorded too. It runs after authenticate, so that impersonations that timed
+// out have already ended.
+func (app *application) auditImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.sessionManager.GetInt(r.Context(), impersonationKey)
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		app.infoLog.Printf("Impersonation %d: %s %s answered %d", id, r.Method, r.URL.Path, status)
+		if err := app.impersonations.RecordAction(id, r.Method, r.URL.Path, status); err != nil {
+			app.errorLog.Printf("recording action of impersonation %d: %v", id, err)
+		}
+	})
+}
+
+// adminImpersonation serves the "/admin/impersonation/:id" URL, which lists the requests an admin
+// made during an impersonation.
+func (app *application) adminImpersonation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	impersonation, err := app.impersonations.Get(id)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	actions, err := app.impersonations.Actions(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Impersonation = impersonation
+	data.ImpersonationActions = actions
+
+	app.render(w, r, http.StatusOK, "impersonation.html", data)
+}
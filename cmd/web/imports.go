@@ -0,0 +1,479 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"encoding/json" // Package for decoding the archive.
+	"errors"        // Package for creating error messages.
+	"fmt"           // Package for formatted I/O.
+	"net/http"      // Package for building HTTP servers and clients.
+	"time"          // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/filter"    // Import the content filter package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// importMaxBytes is the size of the largest archive that can be imported.
+const importMaxBytes = 10 << 20
+
+// How an import treats data the user already has.
+const (
+	importSkip    = "skip"    // Keep what the user has and skip the data of the archive.
+	importReplace = "replace" // Overwrite what the user has with the data of the archive.
+	importCopy    = "copy"    // Import snippets the user has again as new snippets, and skip the rest.
+)
+
+// accountImportForm represents the form for importing a data export archive.
+type accountImportForm struct {
+	Conflicts           string `form:"conflicts" validate:"oneof=skip|replace|copy"`
+	DryRun              bool   `form:"dry_run"`
+	validator.Validator `form:"-"`
+}
+
+// importReport lists what an import did with each item of an archive, or would do for a dry run.
+type importReport struct {
+	DryRun bool         // DryRun reports whether nothing was written.
+	Items  []importItem // Items lists the items in the order they were imported.
+}
+
+// importItem is one line of an import report.
+type importItem struct {
+	Kind    string // Kind is the kind of item, such as "Snippet".
+	Name    string // Name identifies the item, such as the title of a snippet.
+	Outcome string // Outcome is what happened to it, such as "created" or "skipped".
+	Note    string // Note explains the outcome, if it needs explaining.
+}
+
+// add appends an item to the report.
+func (rep *importReport) add(kind, name, outcome, note string) {
+	rep.Items = append(rep.Items, importItem{Kind: kind, Name: name, Outcome: outcome, Note: note})
+}
+
+// accountImport serves the "/account/import" URL, where users restore the data of an archive from
+// their data export.
+func (app *application) accountImport(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountImportForm{Conflicts: importSkip, DryRun: true}
+
+	app.render(w, r, http.StatusOK, "import.html", data)
+}
+
+// accountImportPost imports the uploaded archive into the current user's account and shows the
+// report of the import. With dry_run set, nothing is written and the report tells what would be.
+func (app *application) accountImportPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+	var form accountImportForm
+
+	if err := r.ParseMultipartForm(importMaxBytes); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if !errors.As(err, &maxBytesError) {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		form.Conflicts = importSkip
+		form.AddFieldError("archive", "This file is too large to be a Snippetbox archive")
+		app.renderImport(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	if err := app.formDecoder.Decode(&form, r.PostForm); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	var archive dataExportArchive
+	file, _, err := r.FormFile("archive")
+	if err != nil {
+		form.AddFieldError("archive", "Choose the archive to import")
+	} else {
+		defer file.Close()
+		err = json.NewDecoder(file).Decode(&archive)
+		switch {
+		case err != nil || archive.Format != dataExportFormat || archive.UserData == nil:
+			form.AddFieldError("archive", "This file isn't a Snippetbox data archive")
+		case archive.Version != dataExportVersion:
+			form.AddFieldError("archive", fmt.Sprintf("Archives of version %d can't be imported", archive.Version))
+		}
+	}
+
+	if !form.Valid() {
+		app.renderImport(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	report, err := app.importUserData(r, archive.UserData, form.Conflicts, form.DryRun)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderImport(w, r, http.StatusOK, form, report)
+}
+
+// renderImport renders the import page with the given form and, after an import, its report.
+func (app *application) renderImport(w http.ResponseWriter, r *http.Request, status int, form accountImportForm, report *importReport) {
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.ImportReport = report
+
+	app.render(w, r, status, "import.html", data)
+}
+
+// importUserData recreates the snippets, collections, saved searches and preferences of an archive
+// in the current user's account. Snippets are matched to those the user has by their public ID,
+// and collections and saved searches by their name. Imported snippets are screened by the content
+// filter like new ones. Organization snippets, links, memberships and sessions aren't imported.
+func (app *application) importUserData(r *http.Request, d *models.UserData, conflicts string, dryRun bool) (*importReport, error) {
+	report := &importReport{DryRun: dryRun}
+
+	// ids maps the IDs of the archive's snippets to the IDs of the snippets holding them now, so
+	// that collections can be filled. In a dry run, snippets that would be created map to 0.
+	ids, err := app.importSnippets(r, d.Snippets, conflicts, dryRun, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.importCollections(r, d.Collections, ids, conflicts, dryRun, report); err != nil {
+		return nil, err
+	}
+
+	if err := app.importSavedSearches(r, d.SavedSearches, conflicts, dryRun, report); err != nil {
+		return nil, err
+	}
+
+	if err := app.importPreferences(r, d, conflicts, dryRun, report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// importSnippets imports the snippets of an archive and returns the IDs they're held under.
+func (app *application) importSnippets(r *http.Request, snippets []models.UserSnippet, conflicts string, dryRun bool, report *importReport) (map[int]int, error) {
+	userID := app.authenticatedUserID(r)
+	now := app.clock.Now()
+	ids := map[int]int{}
+
+	for _, s := range snippets {
+		switch {
+		case s.OrgID != 0:
+			report.add("Snippet", s.Title, "skipped", "It belongs to an organization.")
+			continue
+		case !validator.NotBlank(s.Title) || !validator.MaxRunes(s.Title, 100) || !validator.NotBlank(s.Content):
+			report.add("Snippet", s.Title, "skipped", "It has no title, a title over 100 characters or no content.")
+			continue
+		case !validator.MaxRunes(s.License, 100):
+			report.add("Snippet", s.Title, "skipped", "Its license has a name over 100 characters.")
+			continue
+		case !validMetadata(s.Metadata):
+			report.add("Snippet", s.Title, "skipped", fmt.Sprintf("Its metadata has a malformed pair, a name used twice or over %d pairs.", models.MaxMetadataFields))
+			continue
+		case !s.Expires.IsZero() && !s.Expires.After(now):
+			report.add("Snippet", s.Title, "skipped", "It has expired.")
+			continue
+		}
+
+		var existing *models.Snippet
+		if models.IsULID(s.ULID) {
+			snippet, err := app.snippets.GetByULID(s.ULID)
+			switch {
+			case err == nil && snippet.OwnerID == userID:
+				existing = snippet
+			case err != nil && !errors.Is(err, models.ErrNoRecord):
+				return nil, err
+			}
+		}
+
+		if existing != nil && conflicts == importSkip {
+			ids[s.ID] = existing.ID
+			report.add("Snippet", s.Title, "skipped", "You already have it.")
+			continue
+		}
+
+		verdict, err := app.screen(s.Title, s.Content)
+		if err != nil {
+			return nil, err
+		}
+		if verdict.Action == filter.Reject {
+			if !dryRun {
+				if err := app.recordFilterHit(r, verdict, 0); err != nil {
+					return nil, err
+				}
+			}
+			report.add("Snippet", s.Title, "skipped", "It contains content that isn't allowed.")
+			continue
+		}
+
+		var note string
+		if verdict.Action == filter.Hold {
+			note = "It will be listed once a moderator has reviewed it."
+		}
+
+		if existing != nil && conflicts == importReplace {
+			ids[s.ID] = existing.ID
+			report.add("Snippet", s.Title, "replaced", note)
+			if dryRun {
+				continue
+			}
+
+			if err := app.snippets.Update(existing.ID, s.Title, s.Content, userID, false); err != nil {
+				return nil, err
+			}
+			if err := app.snippets.SetPrivate(existing.ID, s.Private); err != nil {
+				return nil, err
+			}
+			if err := app.snippets.SetLicense(existing.ID, s.License); err != nil {
+				return nil, err
+			}
+			if err := app.snippets.SetMetadata(existing.ID, s.Metadata); err != nil {
+				return nil, err
+			}
+			app.detectLanguage(existing.ID, existing.Language, s.Title, s.Content)
+			if err := app.applyVerdict(r, verdict, existing.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		outcome := "created"
+		if existing != nil {
+			outcome = "copied"
+		}
+		report.add("Snippet", s.Title, outcome, note)
+		if dryRun {
+			ids[s.ID] = 0
+			continue
+		}
+
+		// The snippet keeps the day it expires on, rounded up to whole days from now.
+		days := models.NeverExpires
+		if !s.Expires.IsZero() {
+			days = int((s.Expires.Sub(now) + 24*time.Hour - 1) / (24 * time.Hour))
+		}
+
+		id, err := app.snippets.InsertWith(s.Title, s.Content, days, userID, newVisibility(s.Private, verdict))
+		if err != nil {
+			return nil, err
+		}
+		ids[s.ID] = id
+
+		if s.License != "" {
+			if err := app.snippets.SetLicense(id, s.License); err != nil {
+				return nil, err
+			}
+		}
+		if len(s.Metadata) > 0 {
+			if err := app.snippets.SetMetadata(id, s.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		app.detectLanguage(id, "", s.Title, s.Content)
+		if err := app.recordVerdict(r, verdict, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
+
+// importCollections imports the collections of an archive with the snippets that were imported. A
+// collection the user already has is left alone, unless the archive replaces it, in which case it
+// takes the visibility of the archive's and its snippets are added.
+func (app *application) importCollections(r *http.Request, collections []models.UserCollection, ids map[int]int, conflicts string, dryRun bool, report *importReport) error {
+	userID := app.authenticatedUserID(r)
+
+	owned, err := app.collections.ByOwner(userID)
+	if err != nil {
+		return err
+	}
+	existing := map[string]*models.Collection{}
+	for _, c := range owned {
+		existing[c.Name] = c
+	}
+
+	for _, c := range collections {
+		if !validator.NotBlank(c.Name) || !validator.MaxRunes(c.Name, 100) {
+			report.add("Collection", c.Name, "skipped", "It has no name or a name over 100 characters.")
+			continue
+		}
+
+		var snippetIDs []int
+		for _, id := range c.SnippetIDs {
+			if newID, ok := ids[id]; ok {
+				snippetIDs = append(snippetIDs, newID)
+			}
+		}
+
+		var note string
+		if left := len(c.SnippetIDs) - len(snippetIDs); left > 0 {
+			note = fmt.Sprintf("%d of its snippets weren't imported.", left)
+		}
+
+		target, ok := existing[c.Name]
+		switch {
+		case ok && conflicts != importReplace:
+			report.add("Collection", c.Name, "skipped", "You already have a collection with this name.")
+			continue
+		case ok:
+			report.add("Collection", c.Name, "merged", note)
+			if dryRun {
+				continue
+			}
+			if err := app.collections.Update(target.ID, c.Name, c.Public); err != nil {
+				return err
+			}
+		default:
+			report.add("Collection", c.Name, "created", note)
+			if dryRun {
+				continue
+			}
+			id, err := app.collections.Insert(userID, c.Name, c.Public)
+			if err != nil {
+				return err
+			}
+			target = &models.Collection{ID: id}
+		}
+
+		for _, id := range snippetIDs {
+			if err := app.collections.AddSnippet(target.ID, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// importSavedSearches imports the saved searches of an archive. A search the user already has under
+// the same name is only overwritten if the archive replaces it.
+func (app *application) importSavedSearches(r *http.Request, searches []models.UserSavedSearch, conflicts string, dryRun bool, report *importReport) error {
+	userID := app.authenticatedUserID(r)
+
+	saved, err := app.savedSearches.ByUser(userID)
+	if err != nil {
+		return err
+	}
+	existing := map[string]*models.SavedSearch{}
+	for _, s := range saved {
+		existing[s.Name] = s
+	}
+
+	for _, s := range searches {
+		form := savedSearchForm{Name: s.Name, Query: s.Query, Language: s.Language}
+		if form.CheckStruct(form); !form.Valid() {
+			report.add("Saved search", s.Name, "skipped", "Its name or query is too long, or it has no name.")
+			continue
+		}
+
+		old, ok := existing[s.Name]
+		switch {
+		case ok && conflicts != importReplace:
+			report.add("Saved search", s.Name, "skipped", "You already have a saved search with this name.")
+			continue
+		case ok:
+			report.add("Saved search", s.Name, "replaced", "")
+			if dryRun {
+				continue
+			}
+			if err := app.savedSearches.Delete(old.ID); err != nil {
+				return err
+			}
+		default:
+			report.add("Saved search", s.Name, "created", "")
+			if dryRun {
+				continue
+			}
+		}
+
+		_, err := app.savedSearches.Insert(userID, s.Name, models.SearchQuery{Text: s.Query, Language: s.Language}, s.Notify)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importPreferences imports the digest, expiry reminder and snippet default settings of an archive. A setting the
+// user changed from its default is only overwritten if the archive replaces it.
+func (app *application) importPreferences(r *http.Request, d *models.UserData, conflicts string, dryRun bool, report *importReport) error {
+	userID := app.authenticatedUserID(r)
+
+	frequency, err := app.digests.Frequency(userID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !validator.OneOfString(d.DigestFrequency, models.DigestOff, models.DigestDaily, models.DigestWeekly):
+		report.add("Preference", "Activity digest", "skipped", fmt.Sprintf("%q isn't a digest frequency.", d.DigestFrequency))
+	case d.DigestFrequency == frequency:
+		report.add("Preference", "Activity digest", "unchanged", "")
+	case frequency != models.DigestOff && conflicts != importReplace:
+		report.add("Preference", "Activity digest", "skipped", "You already chose "+frequency+".")
+	default:
+		report.add("Preference", "Activity digest", "set", "Set to "+d.DigestFrequency+".")
+		if !dryRun {
+			if err := app.digests.SetFrequency(userID, d.DigestFrequency); err != nil {
+				return err
+			}
+		}
+	}
+
+	days, err := app.reminders.Days(userID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !validator.AllowedValue(d.ReminderDays, 0, 1, 3, 7):
+		report.add("Preference", "Expiry reminders", "skipped", fmt.Sprintf("%d days isn't a reminder setting.", d.ReminderDays))
+	case d.ReminderDays == days:
+		report.add("Preference", "Expiry reminders", "unchanged", "")
+	case days != 0 && conflicts != importReplace:
+		report.add("Preference", "Expiry reminders", "skipped", fmt.Sprintf("You already chose %d days before.", days))
+	default:
+		note := fmt.Sprintf("Set to %d days before.", d.ReminderDays)
+		if d.ReminderDays == 0 {
+			note = "Turned off."
+		}
+		report.add("Preference", "Expiry reminders", "set", note)
+		if !dryRun {
+			if err := app.reminders.SetDays(userID, d.ReminderDays); err != nil {
+				return err
+			}
+		}
+	}
+
+	preferences, err := app.preferences.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	// Archives from before snippet defaults existed have none.
+	if d.Preferences == (models.Preferences{}) {
+		d.Preferences = models.DefaultPreferences
+	}
+
+	switch {
+	case !models.ValidExpiryDays(d.Preferences.DefaultExpires) || !validLanguage(d.Preferences.DefaultLanguage):
+		report.add("Preference", "Snippet defaults", "skipped", "They aren't settings the snippet form offers.")
+	case d.Preferences == preferences:
+		report.add("Preference", "Snippet defaults", "unchanged", "")
+	case preferences != models.DefaultPreferences && conflicts != importReplace:
+		report.add("Preference", "Snippet defaults", "skipped", "You already chose yours.")
+	default:
+		report.add("Preference", "Snippet defaults", "set", "")
+		if !dryRun {
+			if err := app.preferences.Set(userID, d.Preferences); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
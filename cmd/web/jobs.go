@@ -0,0 +1,41 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"fmt"  // Package for formatted I/O.
+	"time" // Package for measuring and displaying time.
+)
+
+// backgroundJob runs fn in a new goroutine once immediately and then every interval for as long as
+// the application is running. Errors and panics are written to the errorLog so that a failing job
+// never takes the server down.
+func (app *application) backgroundJob(name string, interval time.Duration, fn func() error) {
+	// Every job writes to the database, so none run in read-only mode.
+	if app.config.ReadOnly {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			app.runJob(name, fn)
+			<-ticker.C
+		}
+	}()
+}
+
+// runJob runs a single iteration of a background job, logging any error or panic.
+func (app *application) runJob(name string, fn func() error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.errorLog.Output(2, fmt.Sprintf("job %q panicked: %s", name, err))
+		}
+	}()
+
+	if err := fn(); err != nil {
+		app.errorLog.Output(2, fmt.Sprintf("job %q failed: %s", name, err))
+	}
+}
@@ -0,0 +1,55 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors" // Package for creating error messages.
+	"slices" // Package for searching the languages.
+
+	"snippetbox.adcon.dev/internal/codefmt"    // Import the code formatting package.
+	"snippetbox.adcon.dev/internal/langdetect" // Import the language detection package.
+)
+
+// detectLanguage stores the language detected from the title and content of a snippet that was
+// just saved, if it differs from the current one. The title is used as a file name, which is what
+// many snippets are titled with. Failing to store it is logged rather than reported, since the
+// snippet itself was saved.
+func (app *application) detectLanguage(id int, current, title, content string) {
+	language := langdetect.Detect(title, content)
+	if language == current {
+		return
+	}
+
+	if err := app.snippets.SetLanguage(id, language); err != nil {
+		app.errorLog.Printf("storing language of snippet %d: %v", id, err)
+	}
+}
+
+// validLanguage reports whether a snippet can be tagged with language by hand: it's empty, to
+// detect the language, or one of the languages detection knows.
+func validLanguage(language string) bool {
+	return language == "" || slices.Contains(langdetect.Languages(), language)
+}
+
+// formatContent formats the content of a snippet in its detected language. If it can't, it
+// returns the content unchanged with a warning for the user, since formatting is only a courtesy.
+func formatContent(title, content string) (string, string) {
+	formatted, err := codefmt.Format(langdetect.Detect(title, content), content)
+	switch {
+	case errors.Is(err, codefmt.ErrUnsupported):
+		return content, "Only Go and JSON snippets can be formatted, so it was saved as written."
+	case err != nil:
+		return content, "It couldn't be formatted, so it was saved as written: " + err.Error()
+	}
+
+	return formatted, ""
+}
+
+// withWarning appends a warning to a flash message, if there is one.
+func withWarning(message, warning string) string {
+	if warning == "" {
+		return message
+	}
+
+	return message + " " + warning
+}
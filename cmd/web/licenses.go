@@ -0,0 +1,41 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"strings" // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// licenseCustom is the value of the license field of the snippet forms for a license that isn't
+// well-known, whose name is in the custom_license field.
+const licenseCustom = "custom"
+
+// checkLicense validates the license fields of a snippet form and returns the license to store:
+// the SPDX identifier of a well-known license, the name of a custom license, or empty for none.
+func checkLicense(v *validator.Validator, license, custom string) string {
+	switch license {
+	case "":
+		return ""
+	case licenseCustom:
+		custom = strings.TrimSpace(custom)
+		v.CheckField(custom != "", "custom_license", "Name the license, or choose one from the list")
+		return custom
+	default:
+		_, ok := models.KnownLicense(license)
+		v.CheckField(ok, "license", "Choose a license from the list")
+		return license
+	}
+}
+
+// licenseFields returns the values of the license and custom_license fields of the snippet forms
+// that select license.
+func licenseFields(license string) (string, string) {
+	if _, ok := models.KnownLicense(license); ok || license == "" {
+		return license, ""
+	}
+
+	return licenseCustom, license
+}
@@ -0,0 +1,77 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"fmt"      // Package for formatted I/O.
+	"net/http" // Package for building HTTP servers and clients.
+
+	"snippetbox.adcon.dev/internal/validator" // Import the validator package.
+)
+
+// The size limits of snippets, set with -max-title-bytes and -max-content-bytes.
+const (
+	defaultMaxTitleBytes   = 400     // defaultMaxTitleBytes fits the longest title the database holds.
+	defaultMaxContentBytes = 1 << 20 // defaultMaxContentBytes is the default largest content of a snippet, in bytes.
+
+	maxTitleColumn   = 400      // maxTitleColumn is the size of the title column, 100 characters of up to 4 bytes.
+	maxContentColumn = 15 << 20 // maxContentColumn leaves room in the content column for the encryption and compression headers.
+
+	// formOverhead is what a form body may hold besides the title and content: the other fields,
+	// the honeypot fields and the captcha response.
+	formOverhead = 64 << 10
+)
+
+// maxSnippetBody returns the size of the largest form or API request body accepted. Percent-encoding
+// turns each byte of the title and content into at most 3 bytes, and JSON escapes the characters of
+// ordinary text in at most 2.
+func (app *application) maxSnippetBody() int64 {
+	return 3*int64(app.config.MaxTitleBytes+app.config.MaxContentBytes) + formOverhead
+}
+
+// checkSize applies the size limits to the title and content of a snippet.
+func (app *application) checkSize(v *validator.Validator, title, content string) {
+	v.CheckField(validator.MaxBytes(title, app.config.MaxTitleBytes), "title", fmt.Sprintf("This field cannot be more than %d bytes long", app.config.MaxTitleBytes))
+	v.CheckField(validator.MaxBytes(content, app.config.MaxContentBytes), "content", fmt.Sprintf("Snippets can be at most %s", formatBytes(app.config.MaxContentBytes)))
+}
+
+// formError answers a form decodePostForm couldn't decode: with 413 and the size limits when the
+// body was too large, and 400 otherwise.
+func (app *application) formError(w http.ResponseWriter, err error) {
+	var maxBytesError *http.MaxBytesError
+	if !errors.As(err, &maxBytesError) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	message := fmt.Sprintf("This form is too large to be sent. Snippets can be at most %s, with a title of at most %d bytes.",
+		formatBytes(app.config.MaxContentBytes), app.config.MaxTitleBytes)
+	http.Error(w, message, http.StatusRequestEntityTooLarge)
+}
+
+// formatBytes returns n bytes in whole megabytes or kilobytes when it is a multiple of one, such as
+// "1 MB" or "16 KB", and in bytes otherwise.
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// checkSizeLimits validates the size limits of snippets, which must be positive and fit the
+// columns of the snippets table.
+func checkSizeLimits(config configuration) error {
+	if config.MaxTitleBytes < 1 || config.MaxTitleBytes > maxTitleColumn {
+		return fmt.Errorf("-max-title-bytes must be between 1 and %d", maxTitleColumn)
+	}
+	if config.MaxContentBytes < 1 || config.MaxContentBytes > maxContentColumn {
+		return fmt.Errorf("-max-content-bytes must be between 1 and %d", maxContentColumn)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"html/template" // Package for marking the highlighted HTML as safe.
+	"strconv"       // Package for converting strings to numeric types.
+	"strings"       // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/highlight" // Import the syntax highlighting package.
+)
+
+// lineRange is a range of lines of a snippet, from First to Last inclusive.
+type lineRange struct {
+	First, Last int
+}
+
+// parseLineRange parses a range of lines as written in the "lines" query parameter or in a URL
+// fragment: "10" or "L10" for a single line, and "10-20" or "L10-L20" for a range. It returns
+// false for anything else and for ranges that run backwards.
+func parseLineRange(s string) (lineRange, bool) {
+	number := func(s string) (int, bool) {
+		s = strings.TrimPrefix(s, highlight.LinePrefix)
+		if s == "" || s[0] < '0' || s[0] > '9' {
+			return 0, false
+		}
+		n, err := strconv.Atoi(s)
+		return n, err == nil && n >= 1
+	}
+
+	first, last, isRange := strings.Cut(s, "-")
+	if !isRange {
+		last = first
+	}
+
+	lr := lineRange{}
+	var okFirst, okLast bool
+	lr.First, okFirst = number(first)
+	lr.Last, okLast = number(last)
+	if !okFirst || !okLast || lr.First > lr.Last {
+		return lineRange{}, false
+	}
+
+	return lr, true
+}
+
+// Query returns the range as written in the "lines" query parameter, such as "10-20", or "10" for
+// a single line.
+func (lr lineRange) Query() string {
+	if lr.First == lr.Last {
+		return strconv.Itoa(lr.First)
+	}
+	return strconv.Itoa(lr.First) + "-" + strconv.Itoa(lr.Last)
+}
+
+// Fragment returns the range as written in a URL fragment, such as "L10-L20", or "L10" for a single
+// line, which is the ID of its first line.
+func (lr lineRange) Fragment() string {
+	if lr.First == lr.Last {
+		return highlight.LinePrefix + strconv.Itoa(lr.First)
+	}
+	return highlight.LinePrefix + strconv.Itoa(lr.First) + "-" + highlight.LinePrefix + strconv.Itoa(lr.Last)
+}
+
+// selectedLines returns the range of lines of a snippet selected with the "lines" query parameter,
+// cut to the lines the snippet has, or nil if none is selected or the parameter isn't a range of
+// its lines.
+func selectedLines(param string, lines int) *lineRange {
+	lr, ok := parseLineRange(param)
+	if !ok || lr.First > lines {
+		return nil
+	}
+
+	lr.Last = min(lr.Last, lines)
+
+	return &lr
+}
+
+// lineLink returns the link to a range of lines of the page at path: the range in the "lines"
+// query parameter marks the lines on the server, and the fragment scrolls to them.
+func lineLink(path string, lr lineRange) string {
+	return path + "?lines=" + lr.Query() + "#" + lr.Fragment()
+}
+
+// highlightSnippet returns the highlighted HTML of a snippet's content, marking the selected lines,
+// if any. The highlighter escapes the content, so the templates show the HTML as it is.
+func highlightSnippet(language, content string, selected *lineRange) template.HTML {
+	if selected == nil {
+		return template.HTML(highlight.HTML(language, content))
+	}
+	return template.HTML(highlight.HTML(language, content, [2]int{selected.First, selected.Last}))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestParseLineRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in     string
+		want   lineRange
+		wantOK bool
+	}{
+		{"10", lineRange{10, 10}, true},
+		{"L10", lineRange{10, 10}, true},
+		{"10-20", lineRange{10, 20}, true},
+		{"L10-L20", lineRange{10, 20}, true},
+		{"", lineRange{}, false},
+		{"0", lineRange{}, false},
+		{"20-10", lineRange{}, false},
+		{"+5", lineRange{}, false},
+		{"L+5", lineRange{}, false},
+		{"10-", lineRange{}, false},
+		{"a-b", lineRange{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := parseLineRange(tt.in)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestLineLink(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, lineLink("/snippet/view/5", lineRange{10, 20}), "/snippet/view/5?lines=10-20#L10-L20")
+	assert.Equal(t, lineLink("/snippet/view/5", lineRange{7, 7}), "/snippet/view/5?lines=7#L7")
+}
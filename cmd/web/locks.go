@@ -0,0 +1,69 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// lockEditor takes or renews the current user's edit lock on a snippet, and returns the lock of
+// another user who is editing it, or nil. Locks are only advisory, so a lock that can't be taken
+// is logged and ignored, and none are taken in read-only mode.
+func (app *application) lockEditor(r *http.Request, snippetID int) *models.EditLock {
+	if app.config.ReadOnly {
+		return nil
+	}
+
+	lock, err := app.locks.Acquire(snippetID, app.authenticatedUserID(r))
+	switch {
+	case errors.Is(err, models.ErrLocked):
+		return lock
+	case err != nil:
+		app.errorLog.Printf("locking snippet %d: %v", snippetID, err)
+	}
+
+	return nil
+}
+
+// snippetLockPost serves the "/snippet/lock/:id" URL that the edit form posts to while it's open,
+// see main.js. It renews the user's lock on the snippet and responds with 204 No Content, or if
+// another user holds the lock, with 409 Conflict and who they are.
+func (app *application) snippetLockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.writableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	lock, err := app.locks.Acquire(snippet.ID, app.authenticatedUserID(r))
+	switch {
+	case errors.Is(err, models.ErrLocked):
+		app.writeJSON(w, r, http.StatusConflict, map[string]string{
+			"holder": lock.UserName,
+			"since":  humanDate(lock.Acquired),
+		})
+	case err != nil:
+		app.serverError(w, r, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// snippetUnlockPost serves the "/snippet/unlock/:id" URL that the edit form posts to when it's
+// closed. It releases the user's lock on the snippet, if they hold it, and responds with 204 No
+// Content.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.writableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.locks.Release(snippet.ID, app.authenticatedUserID(r)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"       // Package for creating error messages.
+	"fmt"          // Package for formatted I/O.
+	"math/rand/v2" // Package for sampling requests.
+	"strconv"      // Package for parsing sampling rates.
+	"strings"      // Package for manipulating strings.
+)
+
+// logRule decides how often requests for the paths matching a pattern are logged.
+type logRule struct {
+	pattern string  // pattern is a path, or a path prefix ending in "*", such as "/static/*".
+	rate    float64 // rate is the fraction of the requests that are logged, 0 for none.
+}
+
+// logFilter keeps asset requests, health probes and busy routes from drowning out the rest of the
+// request log. Requests for excluded paths aren't logged, and a fraction of those for sampled paths
+// are. The first rule that matches a path applies, exclusions first, and other paths are all logged.
+type logFilter struct {
+	rules []logRule
+}
+
+// parseLogFilter parses the -log-exclude and -log-sample settings. exclude lists patterns, such as
+// "/static/*,/healthz", and sample lists patterns with the fraction of requests to log, such as
+// "/snippet/view/*=0.1".
+func parseLogFilter(exclude, sample string) (*logFilter, error) {
+	f := &logFilter{}
+
+	for _, pattern := range strings.Split(exclude, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if err := checkLogPattern(pattern); err != nil {
+			return nil, fmt.Errorf("-log-exclude: %w", err)
+		}
+		f.rules = append(f.rules, logRule{pattern: pattern})
+	}
+
+	for _, item := range strings.Split(sample, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pattern, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("-log-sample: %q isn't pattern=rate", item)
+		}
+		if err := checkLogPattern(pattern); err != nil {
+			return nil, fmt.Errorf("-log-sample: %w", err)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return nil, fmt.Errorf("-log-sample: the rate of %s must be more than 0 and at most 1", pattern)
+		}
+		f.rules = append(f.rules, logRule{pattern: pattern, rate: rate})
+	}
+
+	return f, nil
+}
+
+// checkLogPattern reports whether a pattern is a path, with a "*" at the end at most.
+func checkLogPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("%q doesn't start with /", pattern)
+	}
+	if strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+		return errors.New("patterns can only end in *")
+	}
+
+	return nil
+}
+
+// rate returns the fraction of the requests for a path that are logged.
+func (f *logFilter) rate(path string) float64 {
+	for _, rule := range f.rules {
+		if prefix, ok := strings.CutSuffix(rule.pattern, "*"); ok && strings.HasPrefix(path, prefix) || path == rule.pattern {
+			return rule.rate
+		}
+	}
+
+	return 1
+}
+
+// sample reports whether to log a request for a path, and the rate it was sampled at.
+func (f *logFilter) sample(path string) (bool, float64) {
+	rate := f.rate(path)
+	return rate >= 1 || rand.Float64() < rate, rate
+}
+
+// checkLogFilter validates the request log filtering settings.
+func checkLogFilter(config configuration) error {
+	_, err := parseLogFilter(config.LogExclude, config.LogSample)
+	return err
+}
@@ -0,0 +1,103 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+	"strings"  // Package for normalizing the email addresses counted.
+	"sync"     // Package for synchronizing access to the counts.
+	"time"     // Package for measuring and displaying time.
+)
+
+// loginFailureWindow is how long failed logins are remembered: a count is forgotten once there
+// hasn't been a failure for it for this long.
+const loginFailureWindow = time.Hour
+
+// loginFailures counts failed logins on the server, per client IP address and per account, so that
+// a client can't escape human verification by dropping its session cookie. Counts that have been
+// idle for loginFailureWindow are removed so that the map doesn't grow without bound. The zero
+// value is ready to use.
+type loginFailures struct {
+	mu      sync.Mutex
+	counts  map[string]*failureCount
+	lastRun time.Time
+}
+
+// failureCount is the number of failed logins for one client or account, and when the last was.
+type failureCount struct {
+	n    int
+	last time.Time
+}
+
+// loginFailureKeys returns the keys a login attempt is counted under: the client's IP address and,
+// when the attempt names one, the account's email address.
+func loginFailureKeys(r *http.Request, email string) []string {
+	keys := []string{"ip:" + clientIP(r)}
+	if strings.TrimSpace(email) != "" {
+		keys = append(keys, accountFailureKey(email))
+	}
+	return keys
+}
+
+// accountFailureKey returns the key the failed logins for an account's email address are counted
+// under, whatever its case.
+func accountFailureKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// add counts a failed login under each of keys.
+func (f *loginFailures) add(now time.Time, keys ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sweep(now)
+
+	for _, key := range keys {
+		c, ok := f.counts[key]
+		if !ok || now.Sub(c.last) > loginFailureWindow {
+			c = &failureCount{}
+			f.counts[key] = c
+		}
+		c.n++
+		c.last = now
+	}
+}
+
+// count returns the highest number of recent failed logins under any of keys.
+func (f *loginFailures) count(now time.Time, keys ...string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, key := range keys {
+		if c, ok := f.counts[key]; ok && now.Sub(c.last) <= loginFailureWindow {
+			n = max(n, c.n)
+		}
+	}
+	return n
+}
+
+// reset forgets the failed logins under key.
+func (f *loginFailures) reset(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.counts, key)
+}
+
+// sweep removes the counts that have expired, every few minutes. The caller must hold f.mu.
+func (f *loginFailures) sweep(now time.Time) {
+	if f.counts == nil {
+		f.counts = map[string]*failureCount{}
+	}
+	if now.Sub(f.lastRun) < 3*time.Minute {
+		return
+	}
+
+	for key, c := range f.counts {
+		if now.Sub(c.last) > loginFailureWindow {
+			delete(f.counts, key)
+		}
+	}
+	f.lastRun = now
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestLoginFailures(t *testing.T) {
+
+	t.Parallel()
+
+	var failures loginFailures
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	failures.add(now, "ip:192.0.2.1", "email:alice@example.com")
+	failures.add(now, "ip:192.0.2.2", "email:alice@example.com")
+
+	// An attempt counts as many failures as the worst of its client and its account.
+	assert.Equal(t, failures.count(now, "ip:192.0.2.1"), 1)
+	assert.Equal(t, failures.count(now, "ip:192.0.2.3", "email:alice@example.com"), 2)
+	assert.Equal(t, failures.count(now, "ip:192.0.2.3"), 0)
+
+	failures.reset("email:alice@example.com")
+	assert.Equal(t, failures.count(now, "ip:192.0.2.3", "email:alice@example.com"), 0)
+	assert.Equal(t, failures.count(now, "ip:192.0.2.2"), 1)
+
+	// Counts are forgotten once they've been idle for the window, and start over.
+	later := now.Add(loginFailureWindow + time.Minute)
+	assert.Equal(t, failures.count(later, "ip:192.0.2.1"), 0)
+
+	failures.add(later, "ip:192.0.2.1")
+	assert.Equal(t, failures.count(later, "ip:192.0.2.1"), 1)
+	assert.Equal(t, len(failures.counts), 1)
+}
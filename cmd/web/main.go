@@ -3,21 +3,36 @@ package main
 
 // Import the necessary packages.
 import (
+	"context" // Package for bounding how long a backup may take.
 	"crypto/tls"
-	"database/sql"  // Package for interacting with SQL databases.
-	"flag"          // Package for parsing command-line flags.
-	"log"           // Package for logging.
-	"net/http"      // Package for building HTTP servers and clients.
-	"os"            // Package for interacting with the operating system.
-	"text/template" // Package for manipulating text templates.
+	"database/sql" // Package for interacting with SQL databases.
+	"flag"         // Package for parsing command-line flags.
+	"fmt"          // Package for formatted I/O.
+	"io"           // Package for writing backups.
+	"log"          // Package for logging.
+	"net/http"     // Package for building HTTP servers and clients.
+	"os"           // Package for interacting with the operating system.
+	"strings"      // Package for manipulating strings.
 	"time"
 
-	"snippetbox.adcon.dev/internal/models" // Import the models package.
+	"snippetbox.adcon.dev/internal/backup"       // Import the backup format package.
+	"snippetbox.adcon.dev/internal/captcha"      // Import the human verification package.
+	"snippetbox.adcon.dev/internal/clock"        // Import the clock package.
+	"snippetbox.adcon.dev/internal/cookiestore"  // Import the cookie session store.
+	"snippetbox.adcon.dev/internal/dbconfig"     // Import the database configuration package.
+	"snippetbox.adcon.dev/internal/filter"       // Import the content filter package.
+	"snippetbox.adcon.dev/internal/geoip"        // Import the GeoIP package.
+	"snippetbox.adcon.dev/internal/mailer"       // Import the email package.
+	"snippetbox.adcon.dev/internal/models"       // Import the models package.
+	"snippetbox.adcon.dev/internal/s3"           // Import the S3 client package.
+	"snippetbox.adcon.dev/internal/servertiming" // Import the request timing package.
+	"snippetbox.adcon.dev/internal/slowquery"    // Import the slow query logging package.
+	"snippetbox.adcon.dev/internal/version"      // Import the build information package.
+	"snippetbox.adcon.dev/internal/webmention"   // Import the Webmention package.
 
-	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
-	_ "github.com/go-sql-driver/mysql" // Import the MySQL driver.
+	"github.com/go-sql-driver/mysql" // Import the MySQL driver.
 )
 
 // configuration represents the application configuration. It includes fields for each configuration option.
@@ -26,7 +41,93 @@ import (
 type configuration struct {
 	Addr      string // Addr is the network address that the application should listen on.
 	StaticDir string // StaticDir is the directory where static files are stored.
-	Dsn       string // Secret is the secret key used for session authentication.
+
+	DB        dbconfig.Config // DB holds the database connection settings, set with -dsn and the -db-* flags.
+	SlowQuery time.Duration   // SlowQuery is how long a statement may run before it's logged as slow (0 disables).
+
+	CompressThreshold int    // CompressThreshold is the snippet content size in bytes above which content is compressed.
+	CompressCodec     string // CompressCodec is the codec used to compress snippet content (none, gzip or zstd).
+	ContentKeys       string // ContentKeys is the keyring used to encrypt snippet content at rest ("id:base64key,...").
+	ContentKeysFile   string // ContentKeysFile is a file holding the keyring, for keys mounted from a secret store.
+
+	CaptureClientInfo   bool          // CaptureClientInfo records the IP address and user agent of snippet creators.
+	AnonymizeIPs        bool          // AnonymizeIPs zeroes the host part of client IP addresses before they're logged or stored.
+	ClientInfoRetention time.Duration // ClientInfoRetention is how long recorded client information is kept.
+
+	ReadOnly       bool // ReadOnly rejects everything that writes to the database, for maintenance.
+	ReadOnlyLogins bool // ReadOnlyLogins keeps logging in and out working in read-only mode.
+
+	FilterFile string // FilterFile is the blocklist used to screen snippet titles and content.
+
+	MaxTitleBytes   int // MaxTitleBytes is the longest title of a snippet, in bytes.
+	MaxContentBytes int // MaxContentBytes is the largest content of a snippet, in bytes.
+
+	FormMinFillTime time.Duration // FormMinFillTime is how long a person takes at least to fill in a form; faster submissions are discarded.
+
+	CaptchaProvider      string // CaptchaProvider is the human verification service (none, recaptcha, hcaptcha or turnstile).
+	CaptchaSiteKey       string // CaptchaSiteKey is the public key of the site with the provider.
+	CaptchaSecret        string // CaptchaSecret is the private key used to verify responses with the provider.
+	CaptchaLoginFailures int    // CaptchaLoginFailures is the number of failed logins after which logging in needs verification.
+
+	AnonymousPosting bool // AnonymousPosting lets visitors create snippets without logging in, with stricter limits.
+
+	GeoIPDB        string // GeoIPDB is the MaxMind Country or City database clients are located with; empty disables GeoIP.
+	GeoIPBlock     string // GeoIPBlock lists the countries that can't sign up or create snippets ("CN,RU").
+	GeoIPChallenge string // GeoIPChallenge lists the countries that need human verification to sign up or create snippets.
+
+	ShareKey string // ShareKey is the base64 key share links are signed with.
+
+	AccessLog          string        // AccessLog is what the access log of snippets records (off, basic or full).
+	AccessLogRetention time.Duration // AccessLogRetention is how long access log entries are kept.
+
+	BackupS3Endpoint  string        // BackupS3Endpoint is the URL of the S3-compatible service off-site backups are uploaded to.
+	BackupS3Region    string        // BackupS3Region is the region of the bucket.
+	BackupS3Bucket    string        // BackupS3Bucket is the bucket off-site backups are uploaded to; empty disables them.
+	BackupS3Prefix    string        // BackupS3Prefix starts the names of the backups in the bucket, such as "snippetbox/".
+	BackupS3AccessKey string        // BackupS3AccessKey is the ID of the access key of the bucket.
+	BackupS3SecretKey string        // BackupS3SecretKey is the secret of the access key.
+	BackupInterval    time.Duration // BackupInterval is how often a backup is taken.
+	BackupRetention   time.Duration // BackupRetention is how long backups are kept in the bucket.
+
+	SMTPHost     string // SMTPHost is the mail server email is sent through; without one, email is only logged.
+	SMTPPort     int    // SMTPPort is the submission port of the mail server.
+	SMTPUsername string // SMTPUsername authenticates with the mail server.
+	SMTPPassword string // SMTPPassword is the password of SMTPUsername.
+	SMTPSender   string // SMTPSender is the From address of email, such as "Snippetbox <no-reply@example.com>".
+
+	BounceWebhookSecret string // BounceWebhookSecret is the bearer token email providers report bounces with; empty disables the webhook.
+
+	BaseURL string // BaseURL is the address of the site, used for links in email sent without a request.
+
+	VersionHeader bool // VersionHeader adds an X-App-Version header with the build version to every response.
+	MinifyHTML    bool // MinifyHTML collapses whitespace and drops comments in HTML responses.
+
+	ContentDir string // ContentDir is the directory of the Markdown content pages, such as about.md.
+
+	SessionStore    string // SessionStore is where sessions are kept (mysql or cookie).
+	SessionKeys     string // SessionKeys is the keyring session cookies are encrypted with ("id:base64key,...").
+	SessionKeysFile string // SessionKeysFile is a file holding the session keyring.
+
+	Webmentions bool // Webmentions accepts mentions of public snippets and sends mentions for links in Markdown snippets.
+
+	CSP            string        // CSP is the Content-Security-Policy of every response; empty leaves it out.
+	ReferrerPolicy string        // ReferrerPolicy is the Referrer-Policy of every response; empty leaves it out.
+	FrameOptions   string        // FrameOptions is the X-Frame-Options of every response (deny, sameorigin or empty).
+	HSTSMaxAge     time.Duration // HSTSMaxAge is the max-age of the Strict-Transport-Security header; 0 leaves it out.
+	HSTSSubdomains bool          // HSTSSubdomains extends HSTS to the subdomains of the site.
+	HSTSPreload    bool          // HSTSPreload asks browsers to include the site in their HSTS preload lists.
+
+	ServerTiming string // ServerTiming times requests and sets who gets the Server-Timing header (off, admin or on).
+
+	UIDir string // UIDir is a directory to read the templates and static assets from instead of the embedded files.
+
+	LogExclude string // LogExclude lists the paths whose requests aren't logged, such as "/static/*,/healthz".
+	LogSample  string // LogSample lists paths with the fraction of their requests that are logged, such as "/snippet/view/*=0.1".
+
+	BreakerThreshold   float64       // BreakerThreshold is the fraction of failed requests that opens the circuit breaker of a route (0 disables).
+	BreakerMinRequests int           // BreakerMinRequests is how many requests a route needs in a window before its breaker can open.
+	BreakerWindow      time.Duration // BreakerWindow is how long requests are counted for before the counts start over.
+	BreakerCooldown    time.Duration // BreakerCooldown is how long an open breaker answers with an error page before a trial request.
 }
 
 type application struct {
@@ -34,25 +135,74 @@ type application struct {
 	infoLog        *log.Logger
 	config         configuration
 	snippets       models.SnippetModelInterface
-	templateCache  map[string]*template.Template
+	uiCache        *uiCache                // uiCache holds the templates and the fingerprinted static assets.
+	pages          map[string]*contentPage // pages holds the content pages by their path.
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	cookieSessions *cookiestore.Store // cookieSessions is the session store when sessions are kept in cookies, or nil.
 	users          models.UserModelInterface
+	views          models.ViewModelInterface
+	collections    models.CollectionModelInterface
+	favorites      models.FavoriteModelInterface
+	shares         models.ShareModelInterface
+	accesses       models.AccessModelInterface
+	shortLinks     models.ShortLinkModelInterface
+	organizations  models.OrganizationModelInterface
+	digests        models.DigestModelInterface
+	reminders      models.ReminderModelInterface
+	preferences    models.PreferenceModelInterface
+	dataExports    models.DataExportModelInterface
+	erasures       models.ErasureModelInterface
+	filters        models.FilterModelInterface
+	webmentions    models.WebmentionModelInterface
+	savedSearches  models.SavedSearchModelInterface
+	drafts         models.DraftModelInterface
+	locks          models.EditLockModelInterface
+	impersonations models.ImpersonationModelInterface
+	suppressions   models.SuppressionModelInterface
+	tokens         models.TokenModelInterface
+	mailer         mailer.Sender
+	accessQueue    chan models.Access
+	viewQueue      chan queuedView
+	contentFilter  filter.Filter
+	captcha        captcha.Verifier // captcha is nil when human verification is disabled.
+	geoip          geoip.Locator    // geoip is nil when no GeoIP database is configured.
+	httpClient     *http.Client     // httpClient fetches and notifies the pages of other sites for Webmentions.
+	clock          clock.Clock
+	pingDB         func() error    // pingDB reports whether the database is reachable, for the health endpoint.
+	backups        *offsiteBackups // backups is nil when off-site backups are disabled.
+	buffers        bufferPool      // buffers recycles the buffers pages are rendered into.
+	loginFailures  loginFailures   // loginFailures counts failed logins, to ask for human verification after too many.
 }
 
 // openDB opens a new database connection with the provided data source name (DSN).
-// It uses the sql.Open function to open a new database connection and the db.Ping function to establish a connection
+// It uses the sql.OpenDB function to open a new database connection and the db.Ping function to establish a connection
 // and verify that the given DSN is valid. If there's an error when opening the connection or when pinging the database,
 // it returns nil and the error. If there's no error, it returns the database connection and nil for the error.
-func openDB(dsn string) (*sql.DB, error) {
-	// Open a new database connection with the provided DSN.
-	// sql.Open does not establish any connections to the database, nor does it validate driver connection parameters.
-	db, err := sql.Open("mysql", dsn)
+// Statements that take slowQuery or longer are passed to report; a slowQuery of 0 doesn't time them.
+// If observe isn't nil, it's called with the duration of every statement.
+func openDB(dsn string, slowQuery time.Duration, report func(slowquery.Query), observe func(time.Duration)) (*sql.DB, error) {
+	// Parse the DSN and create a connector for it. This validates the driver connection parameters,
+	// but doesn't establish any connections to the database.
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(cfg)
 	// If there's an error, return nil and the error.
 	if err != nil {
 		return nil, err
 	}
 
+	// Time the statements run on the connections, to report the slow ones.
+	if slowQuery > 0 {
+		connector = slowquery.Connector(connector, slowQuery, report)
+	}
+	if observe != nil {
+		connector = slowquery.Observe(connector, observe)
+	}
+	db := sql.OpenDB(connector)
+
 	// Ping the database to establish a connection and verify that the given DSN is valid.
 	if err = db.Ping(); err != nil {
 		// If there's an error, return nil and the error.
@@ -63,6 +213,61 @@ func openDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
+// openBackupDB opens the connection off-site backups are read over. Unlike the connection of the
+// application, it doesn't parse times, so that they're backed up as the text the database sends,
+// and it holds a single connection, since backups are taken one at a time.
+func openBackupDB(dsn string) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ParseTime = false
+
+	db, err := openDB(cfg.FormatDSN(), 0, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// loadKeyring returns a keyring, for snippet content or session cookies, from either the inline
+// specification or the keyring file. It returns nil if neither is set, which leaves snippet content
+// unencrypted.
+func loadKeyring(spec, file string) (*models.Keyring, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		spec = strings.TrimSpace(string(data))
+	}
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	return models.ParseKeyring(spec)
+}
+
+// newMailer returns the SMTP mailer of the configuration, or a mailer that writes email to the log
+// if no mail server is set.
+func newMailer(config configuration, infoLog *log.Logger) mailer.Sender {
+	if config.SMTPHost == "" {
+		infoLog.Print("No -smtp-host set; email will be written to the log instead of sent")
+		return &mailer.Log{Logger: infoLog}
+	}
+
+	return &mailer.SMTP{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUsername,
+		Password: config.SMTPPassword,
+		From:     config.SMTPSender,
+	}
+}
+
 // main is the application's entry point. It sets up the application configuration, loggers, database connection,
 // and HTTP server. It also handles any errors that occur during setup.
 func main() {
@@ -71,9 +276,85 @@ func main() {
 	var config configuration
 	flag.StringVar(&config.Addr, "addr", ":4000", "HTTP network address")
 	flag.StringVar(&config.StaticDir, "static-dir", "./ui/static/", "Path to static assets")
-	flag.StringVar(&config.Dsn, "dsn", "", "MySQL data source name")
+	config.DB.RegisterFlags(flag.CommandLine)
+	flag.DurationVar(&config.SlowQuery, "slow-query", 500*time.Millisecond, "Log database statements that take at least this long (0 disables)")
+	flag.IntVar(&config.CompressThreshold, "compress-threshold", 4096, "Compress snippet content of at least this many bytes (0 disables)")
+	flag.StringVar(&config.CompressCodec, "compress-codec", "zstd", "Snippet content compression codec (none, gzip or zstd)")
+	flag.StringVar(&config.ContentKeys, "content-keys", "", "Keyring for snippet content encryption (id:base64key,...; first key is active)")
+	flag.StringVar(&config.ContentKeysFile, "content-keys-file", "", "File containing the snippet content keyring")
+	flag.BoolVar(&config.CaptureClientInfo, "capture-client-info", false, "Record the IP address and user agent of snippet creators")
+	flag.BoolVar(&config.AnonymizeIPs, "anonymize-ips", false, "Zero the host part of client IP addresses in logs and stored data (privacy mode)")
+	flag.DurationVar(&config.ClientInfoRetention, "client-info-retention", 30*24*time.Hour, "How long to keep recorded client information")
+	flag.StringVar(&config.FilterFile, "filter-file", "", "Blocklist file used to screen snippet titles and content")
+	flag.IntVar(&config.MaxTitleBytes, "max-title-bytes", defaultMaxTitleBytes, "Longest title of a snippet, in bytes")
+	flag.IntVar(&config.MaxContentBytes, "max-content-bytes", defaultMaxContentBytes, "Largest content of a snippet, in bytes")
+	flag.DurationVar(&config.FormMinFillTime, "form-min-fill-time", 2*time.Second, "Discard signup and snippet forms submitted sooner than this after loading (0 disables)")
+	flag.StringVar(&config.CaptchaProvider, "captcha-provider", "none", "Human verification service (none, recaptcha, hcaptcha or turnstile)")
+	flag.StringVar(&config.CaptchaSiteKey, "captcha-site-key", "", "Site key for the human verification service")
+	flag.StringVar(&config.CaptchaSecret, "captcha-secret", "", "Secret for the human verification service")
+	flag.IntVar(&config.CaptchaLoginFailures, "captcha-login-failures", 3, "Require human verification to log in after this many failed attempts")
+	flag.BoolVar(&config.AnonymousPosting, "anonymous-posting", false, "Let visitors create snippets without logging in, with human verification and stricter limits")
+	flag.StringVar(&config.GeoIPDB, "geoip-db", "", "MaxMind Country or City database (.mmdb) to locate clients with (empty disables GeoIP)")
+	flag.StringVar(&config.GeoIPBlock, "geoip-block", "", "Countries that can't sign up or create snippets, as ISO codes such as CN,RU")
+	flag.StringVar(&config.GeoIPChallenge, "geoip-challenge", "", "Countries that need human verification to sign up or create snippets, as ISO codes")
+	flag.StringVar(&config.ShareKey, "share-key", "", "Base64 key of at least 32 bytes for signing share links and expiry reminder links (random if empty)")
+	flag.StringVar(&config.AccessLog, "access-log", "off", "What to record in the access log owners see for their snippets (off, basic or full)")
+	flag.DurationVar(&config.AccessLogRetention, "access-log-retention", 30*24*time.Hour, "How long to keep access log entries")
+	flag.StringVar(&config.BackupS3Endpoint, "backup-s3-endpoint", "https://s3.amazonaws.com", "URL of the S3-compatible service to upload backups to")
+	flag.StringVar(&config.BackupS3Region, "backup-s3-region", "us-east-1", "Region of the backup bucket")
+	flag.StringVar(&config.BackupS3Bucket, "backup-s3-bucket", "", "Bucket to upload backups to on a schedule (empty disables off-site backups)")
+	flag.StringVar(&config.BackupS3Prefix, "backup-s3-prefix", "snippetbox/", "Prefix of the names of the backups in the bucket")
+	flag.StringVar(&config.BackupS3AccessKey, "backup-s3-access-key", "", "Access key ID for the backup bucket")
+	flag.StringVar(&config.BackupS3SecretKey, "backup-s3-secret-key", "", "Secret access key for the backup bucket")
+	flag.DurationVar(&config.BackupInterval, "backup-interval", 24*time.Hour, "How often to upload a backup")
+	flag.DurationVar(&config.BackupRetention, "backup-retention", 30*24*time.Hour, "How long to keep backups in the bucket")
+	flag.StringVar(&config.SMTPHost, "smtp-host", "", "Mail server for sending email (email is logged if empty)")
+	flag.IntVar(&config.SMTPPort, "smtp-port", 587, "Submission port of the mail server")
+	flag.StringVar(&config.SMTPUsername, "smtp-username", "", "Username for the mail server")
+	flag.StringVar(&config.SMTPPassword, "smtp-password", "", "Password for the mail server")
+	flag.StringVar(&config.SMTPSender, "smtp-sender", "Snippetbox <no-reply@snippetbox.adcon.dev>", "From address of email")
+	flag.StringVar(&config.BounceWebhookSecret, "bounce-webhook-secret", "", "Bearer token email providers report bounces to /webhooks/bounce with (empty disables the webhook)")
+	flag.StringVar(&config.BaseURL, "base-url", "https://localhost:4000", "Address of the site, used for links in digest emails")
+	flag.BoolVar(&config.ReadOnly, "read-only", false, "Reject changes to the database and pause background jobs, for maintenance")
+	flag.BoolVar(&config.ReadOnlyLogins, "read-only-logins", false, "Keep logging in and out working in read-only mode")
+	flag.BoolVar(&config.VersionHeader, "version-header", false, "Add an X-App-Version header to every response")
+	flag.BoolVar(&config.MinifyHTML, "minify-html", false, "Minify HTML responses by collapsing whitespace and dropping comments")
+	flag.StringVar(&config.ContentDir, "content-dir", "./content", "Directory of Markdown pages served at /<name>, such as about.md at /about")
+	flag.StringVar(&config.SessionStore, "session-store", "mysql", "Where to keep sessions (mysql or cookie)")
+	flag.StringVar(&config.SessionKeys, "session-keys", "", "Keyring for encrypting session cookies with -session-store cookie (id:base64key,...; first key is active)")
+	flag.StringVar(&config.SessionKeysFile, "session-keys-file", "", "File containing the session cookie keyring")
+	flag.BoolVar(&config.Webmentions, "webmentions", true, "Accept Webmentions of public snippets and send them for links in Markdown snippets")
+	flag.StringVar(&config.CSP, "csp", contentSecurityPolicy, "Content-Security-Policy of responses (empty leaves it out)")
+	flag.StringVar(&config.ReferrerPolicy, "referrer-policy", defaultReferrerPolicy, "Referrer-Policy of responses (empty leaves it out)")
+	flag.StringVar(&config.FrameOptions, "frame-options", defaultFrameOptions, "X-Frame-Options of responses (deny, sameorigin or empty to leave it out)")
+	flag.DurationVar(&config.HSTSMaxAge, "hsts-max-age", 0, "max-age of the Strict-Transport-Security header, such as 8760h (0 leaves it out)")
+	flag.BoolVar(&config.HSTSSubdomains, "hsts-include-subdomains", false, "Extend HSTS to the subdomains of the site")
+	flag.BoolVar(&config.HSTSPreload, "hsts-preload", false, "Ask browsers to include the site in their HSTS preload lists")
+	flag.StringVar(&config.ServerTiming, "server-timing", serverTimingOff, "Log request timings and send them in a Server-Timing header (off, admin or on)")
+	flag.StringVar(&config.UIDir, "ui-dir", "", "Directory laid out like ui/ to read templates and static assets from, reloadable at runtime (empty uses the embedded files)")
+	flag.StringVar(&config.LogExclude, "log-exclude", "", "Paths whose requests aren't logged, such as /static/*,/healthz (a trailing * matches any suffix)")
+	flag.StringVar(&config.LogSample, "log-sample", "", "Paths of which only a fraction of requests are logged, such as /snippet/view/*=0.1")
+	flag.Float64Var(&config.BreakerThreshold, "breaker-threshold", 0.5, "Fraction of failed requests of a route that makes it answer with an error page for a while (0 disables)")
+	flag.IntVar(&config.BreakerMinRequests, "breaker-min-requests", 20, "Requests a route needs in -breaker-window before its circuit breaker can open")
+	flag.DurationVar(&config.BreakerWindow, "breaker-window", time.Minute, "How long the requests of a route are counted for the circuit breaker")
+	flag.DurationVar(&config.BreakerCooldown, "breaker-cooldown", 30*time.Second, "How long an open circuit breaker answers with an error page before trying the route again")
+	showVersion := flag.Bool("version", false, "Print the version and exit")
+	check := flag.Bool("check", false, "Check the configuration, templates, TLS certificate and database, then exit")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Printf("snippetbox %s\n", version.Get())
+		return
+	}
+
+	// With -check, report whether the server could start and exit without starting it.
+	if *check {
+		if !selfCheck(os.Stdout, config) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Create a new logger for informational messages and write them to os.Stdout.
 	infoLog := log.New(
 		os.Stdout,
@@ -88,8 +369,21 @@ func main() {
 		log.Ldate|log.Ltime|log.LUTC|log.Llongfile,
 	)
 
-	// Call the openDB function to open a new database connection.
-	db, err := openDB(config.Dsn)
+	// Build the data source name from the database settings, registering their TLS settings with the
+	// driver, and call the openDB function to open a new database connection.
+	dsn, err := config.DB.Build()
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if !validServerTiming(config.ServerTiming) {
+		errorLog.Fatalf("-server-timing %q is not off, admin or on", config.ServerTiming)
+	}
+	// Count the time of database statements towards the Server-Timing of the request that ran them.
+	var observeDB func(time.Duration)
+	if config.ServerTiming != serverTimingOff {
+		observeDB = func(d time.Duration) { servertiming.Add("db", d) }
+	}
+	db, err := openDB(dsn, config.SlowQuery, slowQueryReporter(infoLog), observeDB)
 	// If there's an error, log the error message and stop the application.
 	if err != nil {
 		errorLog.Fatal(err)
@@ -105,10 +399,27 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	// Configure the compression of snippet content.
+	codec, err := models.ParseCodec(config.CompressCodec)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	snippets.Content = models.ContentCodec{Threshold: config.CompressThreshold, Codec: codec}
+
+	// Enable at-rest encryption of snippet content if a keyring is configured.
+	keyring, err := loadKeyring(config.ContentKeys, config.ContentKeysFile)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if keyring != nil {
+		snippets.Content.Keys = keyring
+	}
+
 	// Close the prepared statements when the main function exits.
 	defer snippets.InsertStmt.Close()
 	defer snippets.GetStmt.Close()
-	defer snippets.LatestStmt.Close()
+	defer snippets.GetByULIDStmt.Close()
+	defer snippets.ListStmt.Close()
 
 	users, err := models.NewUserModel(db)
 	if err != nil {
@@ -118,20 +429,95 @@ func main() {
 	defer users.InsertStmt.Close()
 	defer users.AuthStmt.Close()
 	defer users.ExistsStmt.Close()
+	defer users.AdminStmt.Close()
 
 	formDecoder := form.NewDecoder()
 
-	// Call the newTemplateCache function to create a new template cache.
-	templateCache, err := newTemplateCache()
+	// Load the blocklist used to screen new snippets.
+	contentFilter, err := filter.Load(config.FilterFile)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Set up human verification for signup and repeated failed logins.
+	verifier, err := captcha.New(config.CaptchaProvider, config.CaptchaSiteKey, config.CaptchaSecret)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Locate clients for the logs, audit trails and access controls by country.
+	var locator geoip.Locator
+	if config.GeoIPDB != "" {
+		geoDB, err := geoip.Open(config.GeoIPDB)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		defer geoDB.Close()
+		locator = geoDB
+	}
+	if err := checkGeoIP(config); err != nil {
+		errorLog.Fatal(err)
+	}
+	if err := checkAnonymousPosting(config); err != nil {
+		errorLog.Fatal(err)
+	}
+	if err := checkSizeLimits(config); err != nil {
+		errorLog.Fatal(err)
+	}
+	if err := checkBackups(config); err != nil {
+		errorLog.Fatal(err)
+	}
+	if err := checkLogFilter(config); err != nil {
+		errorLog.Fatal(err)
+	}
+	if err := checkBreaker(config); err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Share links are signed with a configured key. Without one a random key is used, and links
+	// stop working when the server restarts.
+	shareKey, err := models.ParseShareKey(config.ShareKey)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	if shareKey == nil {
+		infoLog.Print("No -share-key set; share links and reminder links will stop working when the server restarts")
+		shareKey, err = models.NewShareKey()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	// Fingerprint the static assets, so that pages can link them under names that change with
+	// their content, and create the template cache. Both are read from -ui-dir if it's set.
+	uiFS, err := uiFiles(config.UIDir)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	uiCache, err := newUICache(uiFS)
 	// If there's an error, log the error message and stop the application.
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
-	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.Cookie.Secure = true
+	// Load the content pages the operator wrote.
+	pages, err := loadContentPages(config.ContentDir)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Keep sessions in the database, or in encrypted cookies if the deployment has no state to share.
+	sessionManager, cookieSessions, err := newSessionManager(config, db)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	views := &models.ViewModel{DB: db}
+	accesses := &models.AccessModel{DB: db}
+
+	// Stop sending email to addresses that bounce.
+	suppressions := &models.SuppressionModel{DB: db}
+	sender := &mailer.Suppressing{Sender: newMailer(config, infoLog), List: suppressions, Logger: infoLog}
 
 	// Create a new application struct and assign the loggers, configuration, snippets model, and template cache.
 	app := &application{
@@ -139,12 +525,146 @@ func main() {
 		infoLog:        infoLog,
 		config:         config,
 		snippets:       snippets,
-		templateCache:  templateCache,
+		uiCache:        uiCache,
+		pages:          pages,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		cookieSessions: cookieSessions,
 		users:          users,
+		views:          views,
+		collections:    &models.CollectionModel{DB: db},
+		favorites:      &models.FavoriteModel{DB: db},
+		shares:         &models.ShareModel{DB: db, Key: shareKey},
+		accesses:       accesses,
+		shortLinks:     &models.ShortLinkModel{DB: db},
+		organizations:  &models.OrganizationModel{DB: db},
+		digests:        &models.DigestModel{DB: db},
+		reminders:      &models.ReminderModel{DB: db, Key: shareKey},
+		preferences:    &models.PreferenceModel{DB: db},
+		dataExports:    &models.DataExportModel{DB: db, Content: snippets.Content},
+		erasures:       &models.ErasureModel{DB: db},
+		filters:        &models.FilterModel{DB: db},
+		webmentions:    &models.WebmentionModel{DB: db},
+		savedSearches:  &models.SavedSearchModel{DB: db},
+		drafts:         &models.DraftModel{DB: db, Content: snippets.Content},
+		locks:          &models.EditLockModel{DB: db},
+		impersonations: &models.ImpersonationModel{DB: db},
+		suppressions:   suppressions,
+		tokens:         &models.TokenModel{DB: db},
+		mailer:         sender,
+		contentFilter:  contentFilter,
+		captcha:        verifier,
+		geoip:          locator,
+		httpClient:     webmention.SafeClient(10 * time.Second),
+		clock:          clock.System{},
+		pingDB:         db.Ping,
+	}
+
+	// Rebuild the templates and assets on SIGUSR1, to pick up changes in -ui-dir.
+	app.reloadOnSignal()
+
+	// Start aggregating snippet views in the background. In read-only mode neither views nor
+	// accesses are recorded, and background jobs don't run.
+	if config.ReadOnly {
+		infoLog.Print("Read-only mode: changes are rejected and background jobs are paused")
+	} else {
+		app.startViewRecorder(10 * time.Second)
+	}
+
+	// Scrub recorded client information once it's past the retention period. This runs even when
+	// capture is disabled, so that data recorded before it was turned off is still removed.
+	app.backgroundJob("scrub client info", time.Hour, func() error {
+		n, err := snippets.ScrubClientInfo(config.ClientInfoRetention)
+		if n > 0 {
+			infoLog.Printf("Scrubbed client information from %d snippets", n)
+		}
+		return err
+	})
+
+	// Record accesses to snippets for their owners if the deployment allows it, and delete entries
+	// past the retention period. Like the client information scrub, the purge runs even when the
+	// log is off.
+	if !validAccessLog(config.AccessLog) {
+		errorLog.Fatalf("-access-log %q is not off, basic or full", config.AccessLog)
+	}
+	if config.AccessLog != accessLogOff && !config.ReadOnly {
+		app.startAccessRecorder(10 * time.Second)
+	}
+	app.backgroundJob("purge access log", time.Hour, func() error {
+		n, err := accesses.Purge(config.AccessLogRetention)
+		if n > 0 {
+			infoLog.Printf("Purged %d access log entries", n)
+		}
+		return err
+	})
+
+	// Rebuild the trending ranking from the views of the last week, halving the weight of views
+	// every day.
+	app.backgroundJob("refresh trending", 15*time.Minute, func() error {
+		_, err := views.RefreshTrending(7, 24*time.Hour)
+		return err
+	})
+
+	// Email the activity digests users opted in to. The job runs more often than the shortest
+	// period so that digests go out close to when they're due.
+	app.backgroundJob("send digests", time.Hour, app.sendDigests)
+
+	// Remind the owners of snippets that are about to expire, if they asked to be.
+	app.backgroundJob("send expiry reminders", time.Hour, app.sendExpiryReminders)
+
+	// Build the archives users asked for.
+	app.backgroundJob("build data exports", time.Minute, app.buildDataExports)
+
+	// Email users about new snippets matching the saved searches they get notifications for.
+	app.backgroundJob("notify saved searches", 15*time.Minute, app.notifySavedSearches)
+
+	// Verify the Webmentions other sites sent, so that they're shown under the snippets.
+	if config.Webmentions {
+		app.backgroundJob("verify webmentions", time.Minute, app.verifyWebmentions)
 	}
 
+	// Upload backups to the off-site bucket, if there is one. They're read over a connection of
+	// their own that doesn't parse times, so that times are backed up as the database stores them.
+	if config.BackupS3Bucket != "" {
+		backupDB, err := openBackupDB(dsn)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		defer backupDB.Close()
+
+		app.backups = &offsiteBackups{
+			store: &s3.Client{
+				Endpoint:  config.BackupS3Endpoint,
+				Region:    config.BackupS3Region,
+				Bucket:    config.BackupS3Bucket,
+				AccessKey: config.BackupS3AccessKey,
+				SecretKey: config.BackupS3SecretKey,
+			},
+			dump: func(ctx context.Context, w io.Writer) error {
+				_, err := backup.Write(ctx, backupDB, w, app.clock.Now())
+				return err
+			},
+			prefix:    config.BackupS3Prefix,
+			interval:  config.BackupInterval,
+			retention: config.BackupRetention,
+		}
+		app.backgroundJob("upload backup", time.Hour, func() error {
+			return app.backups.run(app.clock.Now())
+		})
+	}
+
+	// Delete expired snippets, sessions and other expired data.
+	purger := &models.PurgeModel{DB: db}
+	app.backgroundJob("purge expired data", time.Hour, func() error {
+		counts, err := purger.Purge(false)
+		for _, c := range counts {
+			if c.Rows > 0 {
+				infoLog.Printf("Purged %d %s", c.Rows, c.Name)
+			}
+		}
+		return err
+	})
+
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 		MinVersion:       tls.VersionTLS11,
@@ -172,9 +692,9 @@ func main() {
 	}
 
 	// Log a message to indicate that the server is starting.
-	infoLog.Printf("Starting server on %s", config.Addr)
+	infoLog.Printf("Starting snippetbox %s on %s", version.Get(), config.Addr)
 	// Start the server and listen for requests.
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	err = srv.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
 
 	// If there's an error (for example, if the server can't start), log the error message and stop the application.
 	errorLog.Fatal(err)
@@ -0,0 +1,105 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"fmt"          // Package for formatted I/O.
+	"strings"      // Package for manipulating strings.
+	"unicode"      // Package for classifying the characters of names.
+	"unicode/utf8" // Package for counting the characters of names and values.
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// parseMetadataField parses a "name: value" pair of snippet metadata, as written on a line of the
+// snippet forms or in a "meta" parameter of the search page. Names are made of letters, digits,
+// dots, hyphens and underscores. Its errors are written for the user.
+func parseMetadataField(s string) (models.MetadataField, error) {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return models.MetadataField{}, fmt.Errorf("%q isn't a name: value pair", s)
+	}
+
+	f := models.MetadataField{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)}
+
+	switch {
+	case f.Name == "":
+		return f, fmt.Errorf("%q has no name", s)
+	case utf8.RuneCountInString(f.Name) > models.MaxMetadataName:
+		return f, fmt.Errorf("The name %q is longer than %d characters", f.Name, models.MaxMetadataName)
+	case strings.IndexFunc(f.Name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '-' && r != '_'
+	}) >= 0:
+		return f, fmt.Errorf("The name %q may only contain letters, digits, dots, hyphens and underscores", f.Name)
+	case f.Value == "":
+		return f, fmt.Errorf("The name %q has no value", f.Name)
+	case utf8.RuneCountInString(f.Value) > models.MaxMetadataValue:
+		return f, fmt.Errorf("The value of %s is longer than %d characters", f.Name, models.MaxMetadataValue)
+	}
+
+	return f, nil
+}
+
+// checkMetadata validates the metadata field of a snippet form, one "name: value" pair per line, and
+// returns the pairs. Problems are added to the form as errors of the metadata field.
+func checkMetadata(v *validator.Validator, text string) []models.MetadataField {
+	fields := []models.MetadataField{}
+	names := map[string]bool{}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		f, err := parseMetadataField(line)
+		if err != nil {
+			v.AddFieldError("metadata", err.Error())
+			return nil
+		}
+		if names[f.Name] {
+			v.AddFieldError("metadata", "The name "+f.Name+" is used twice")
+			return nil
+		}
+		names[f.Name] = true
+
+		fields = append(fields, f)
+	}
+
+	v.CheckField(len(fields) <= models.MaxMetadataFields, "metadata", fmt.Sprintf("A snippet can have at most %d metadata pairs", models.MaxMetadataFields))
+
+	return fields
+}
+
+// validMetadata reports whether the metadata of a snippet in an imported archive could have been
+// written on the snippet forms.
+func validMetadata(fields []models.MetadataField) bool {
+	if len(fields) > models.MaxMetadataFields {
+		return false
+	}
+
+	names := map[string]bool{}
+	for _, f := range fields {
+		if strings.ContainsAny(f.Value, "\r\n") {
+			return false
+		}
+		parsed, err := parseMetadataField(f.Name + ":" + f.Value)
+		if err != nil || parsed != f || names[f.Name] {
+			return false
+		}
+		names[f.Name] = true
+	}
+
+	return true
+}
+
+// formatMetadata returns the metadata of a snippet as the metadata field of the snippet forms.
+func formatMetadata(fields []models.MetadataField) string {
+	lines := make([]string, len(fields))
+	for i, f := range fields {
+		lines[i] = f.Name + ": " + f.Value
+	}
+
+	return strings.Join(lines, "\n")
+}
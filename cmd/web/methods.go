@@ -0,0 +1,71 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"encoding/json" // Package for encoding problem details.
+	"net/http"      // Package for building HTTP servers and clients.
+	"strings"       // Package for manipulating strings.
+)
+
+// problem is an RFC 9457 problem details object, the body of errors from the API that aren't tied
+// to an endpoint.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Incident string `json:"incident,omitempty"` // Incident is the incident ID of server errors, see serverError.
+}
+
+// isAPIRequest reports whether a request is for the API, whose errors are problem details rather
+// than pages.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// methodNotAllowed answers a request whose path is routed, but not for its method. The router has
+// already listed the methods the path takes in the Allow header. API clients get problem details,
+// and browsers the error page.
+func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	detail := r.Method + " isn't supported here; use " + w.Header().Get("Allow") + "."
+
+	if isAPIRequest(r) {
+		app.writeProblem(w, r, http.StatusMethodNotAllowed, detail, r.URL.Path)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ErrorStatus = http.StatusMethodNotAllowed
+	data.ErrorDetail = detail
+	app.render(w, r, http.StatusMethodNotAllowed, "error.html", data)
+}
+
+// globalOptions answers OPTIONS requests. The router has already listed the methods the path
+// takes in the Allow header, so there's nothing else to send.
+func globalOptions(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeProblem sends problem details with the given status. Problems have no type of their own,
+// so they're described by the status alone.
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, status int, detail, instance string) {
+	app.writeProblemDetails(w, problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: instance,
+	})
+}
+
+// writeProblemDetails sends p with its status. Problem details only hold strings and a number, so
+// encoding them can't fail.
+func (app *application) writeProblemDetails(w http.ResponseWriter, p problem) {
+	js, _ := json.Marshal(p)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(append(js, '\n'))
+}
@@ -4,21 +4,57 @@ package main
 // Import the necessary packages.
 import (
 	"context"
-	"fmt"      // Package for formatted I/O.
-	"net/http" // Package for building HTTP servers and clients.
+	"crypto/rand"     // Package for generating the nonces of the Content-Security-Policy.
+	"encoding/base64" // Package for encoding the nonces.
+	"fmt"             // Package for formatted I/O.
+	"net/http"        // Package for building HTTP servers and clients.
+	"slices"          // Package for searching slices.
+	"strings"         // Package for manipulating strings.
+	"time"            // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/version" // Import the build information package.
+)
+
+// The default security headers of every response, which the -csp, -referrer-policy and
+// -frame-options flags override. Pages with a human verification challenge extend the
+// Content-Security-Policy with the origins of the provider; see captchaPolicy.
+const (
+	contentSecurityPolicy = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+	defaultReferrerPolicy = "origin-when-cross-origin"
+	defaultFrameOptions   = "deny"
 )
 
 // secureHeaders is a middleware function that adds secure headers to the HTTP response.
 // It takes an http.Handler as input and returns an http.Handler.
 // The returned http.Handler adds several secure headers to the response header and then calls the ServeHTTP method of the input handler.
 // This function is useful for adding secure headers to all responses in a centralized way.
-func secureHeaders(next http.Handler) http.Handler {
+// The policies come from the configuration, and headers configured as empty are left out. The
+// Content-Security-Policy gets a new nonce for every request, which templates add to inline scripts
+// and styles as CSPNonce so that they run without 'unsafe-inline'.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
+	headers := map[string]string{
+		"Referrer-Policy":           app.config.ReferrerPolicy,
+		"X-Frame-Options":           app.config.FrameOptions,
+		"Strict-Transport-Security": hstsHeader(app.config.HSTSMaxAge, app.config.HSTSSubdomains, app.config.HSTSPreload),
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Add secure headers to the response.
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
-		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+		for name, value := range headers {
+			if value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+		if app.config.CSP != "" {
+			nonce, err := newCSPNonce()
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Security-Policy", noncePolicy(app.config.CSP, nonce))
+			r = r.WithContext(context.WithValue(r.Context(), cspNonceContextKey, nonce))
+		}
 		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "deny")
 		w.Header().Set("X-XSS-Protection", "0")
 
 		// Call the next handler in the chain.
@@ -26,14 +62,100 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// newCSPNonce returns a random nonce for a Content-Security-Policy. It uses the URL-safe alphabet,
+// which the templates write into attributes as it is, rather than escaping "+" as "&#43;".
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// noncePolicy returns policy with the nonce allowed to run scripts and apply styles.
+func noncePolicy(policy, nonce string) string {
+	return extendPolicy(policy, []string{"'nonce-" + nonce + "'"}, "script-src", "style-src")
+}
+
+// hstsPreloadMinAge is the shortest HSTS max-age the browsers' preload lists accept.
+const hstsPreloadMinAge = 365 * 24 * time.Hour
+
+// referrerPolicies are the values of the Referrer-Policy header.
+var referrerPolicies = []string{
+	"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin", "same-origin",
+	"strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+}
+
+// validReferrerPolicy reports whether policy is a Referrer-Policy, or empty to leave the header out.
+// Like browsers, it accepts a comma-separated list of fallbacks.
+func validReferrerPolicy(policy string) bool {
+	if policy == "" {
+		return true
+	}
+	for _, p := range strings.Split(policy, ",") {
+		if !slices.Contains(referrerPolicies, strings.TrimSpace(p)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validFrameOptions reports whether value is an X-Frame-Options value, or empty to leave the header
+// out.
+func validFrameOptions(value string) bool {
+	return value == "" || strings.EqualFold(value, "deny") || strings.EqualFold(value, "sameorigin")
+}
+
+// hstsHeader returns the Strict-Transport-Security header for the given max-age, or an empty string
+// when HSTS is off.
+func hstsHeader(maxAge time.Duration, subdomains, preload bool) string {
+	if maxAge <= 0 {
+		return ""
+	}
+
+	header := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+	if subdomains {
+		header += "; includeSubDomains"
+	}
+	if preload {
+		header += "; preload"
+	}
+
+	return header
+}
+
+// versionHeader is a middleware function that adds the build version to every response in the
+// X-App-Version header. It's enabled with the -version-header flag, since it tells clients exactly
+// which release is running.
+func versionHeader(next http.Handler) http.Handler {
+	v := version.Get().Version
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-App-Version", v)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // logRequest is a middleware function that logs the details of each HTTP request.
 // It takes an http.Handler as input and returns an http.Handler.
 // The returned http.Handler logs the remote address, protocol, method, and URL of the request, and then calls the ServeHTTP method of the input handler.
 // This function is useful for logging the details of each request in a centralized way.
+// Requests for the paths in -log-exclude aren't logged, and only some of those in -log-sample are.
 func (app *application) logRequest(next http.Handler) http.Handler {
+	// The rules were validated when the server started.
+	filter, _ := parseLogFilter(app.config.LogExclude, app.config.LogSample)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Log the remote address, protocol, method, and URL of the request.
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		// Log the remote address, protocol, method, and URL of the request, noting the rate of
+		// sampled requests so that the volume can be worked out from the log.
+		if ok, rate := filter.sample(r.URL.Path); ok {
+			sampled := ""
+			if rate < 1 {
+				sampled = fmt.Sprintf(" (sampled %g%%)", rate*100)
+			}
+			app.infoLog.Printf("%s%s - %s %s %s%s", app.logAddr(r), logCountry(r), r.Proto, r.Method, r.URL.RequestURI(), sampled)
+		}
 
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
@@ -54,7 +176,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				// If a panic occurred, set the connection header to "close".
 				w.Header().Set("Connection", "close")
 				// Log the error and send a 500 Internal Server Error response.
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 
@@ -76,10 +198,35 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireAdmin is a middleware function that only lets authenticated admins through. Anonymous
+// visitors are redirected to the login page and other users get a 403 Forbidden response.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		if !app.isAdmin(r) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
+		if err := app.expireImpersonation(r); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
 		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 		if id == 0 {
 			next.ServeHTTP(w, r)
@@ -88,11 +235,22 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		exists, err := app.users.Exists(id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+
+			admin, err := app.users.IsAdmin(id)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			ctx = context.WithValue(ctx, isAdminContextKey, admin)
+			if admin {
+				app.showServerTiming(r)
+			}
+
 			r = r.WithContext(ctx)
 		}
 
@@ -2,12 +2,18 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
 	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -21,15 +27,20 @@ func TestSecureHeaders(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	var nonce string
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = cspNonce(r)
 		w.Write([]byte("OK"))
 	})
 
-	secureHeaders(next).ServeHTTP(rr, r)
+	newTestApplication(t).secureHeaders(next).ServeHTTP(rr, r)
 
 	rs := rr.Result()
 
-	expectedValue := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+	// The handler gets the nonce the policy allows.
+	assert.Equal(t, len(nonce), 22)
+	expectedValue := "default-src 'self'; style-src 'self' fonts.googleapis.com 'nonce-" + nonce + "'; font-src fonts.gstatic.com; " +
+		"script-src 'self' 'nonce-" + nonce + "'"
 	assert.Equal(t, rs.Header.Get("Content-Security-Policy"), expectedValue)
 
 	expectedValue = "origin-when-cross-origin"
@@ -44,6 +55,8 @@ func TestSecureHeaders(t *testing.T) {
 	expectedValue = "0"
 	assert.Equal(t, rs.Header.Get("X-XSS-Protection"), expectedValue)
 
+	assert.Equal(t, rs.Header.Get("Strict-Transport-Security"), "")
+
 	assert.Equal(t, rs.StatusCode, http.StatusOK)
 
 	defer rs.Body.Close()
@@ -55,3 +68,283 @@ func TestSecureHeaders(t *testing.T) {
 
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestConfiguredSecureHeaders(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.CSP = "default-src 'none'"
+	app.config.FrameOptions = ""
+	app.config.HSTSMaxAge = 365 * 24 * time.Hour
+	app.config.HSTSSubdomains = true
+	app.config.HSTSPreload = true
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.secureHeaders(http.NotFoundHandler()).ServeHTTP(rr, r)
+
+	assert.StringContains(t, rr.Header().Get("Content-Security-Policy"), "default-src 'none'; script-src 'nonce-")
+	assert.Equal(t, rr.Header().Get("Referrer-Policy"), "origin-when-cross-origin")
+	assert.Equal(t, rr.Header().Values("X-Frame-Options") == nil, true)
+	assert.Equal(t, rr.Header().Get("Strict-Transport-Security"), "max-age=31536000; includeSubDomains; preload")
+}
+
+func TestExtendPolicy(t *testing.T) {
+	t.Parallel()
+
+	origins := []string{"https://captcha.example.com"}
+
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+	}{
+		{
+			name:   "Default policy",
+			policy: contentSecurityPolicy,
+			want: "default-src 'self'; style-src 'self' fonts.googleapis.com https://captcha.example.com; font-src fonts.gstatic.com; " +
+				"script-src 'self' https://captcha.example.com; frame-src 'self' https://captcha.example.com",
+		},
+		{
+			name:   "None",
+			policy: "default-src 'none'; script-src 'none'",
+			want:   "default-src 'none'; script-src https://captcha.example.com; frame-src https://captcha.example.com; style-src https://captcha.example.com",
+		},
+		{
+			name:   "No default",
+			policy: "frame-ancestors 'none'",
+			want:   "frame-ancestors 'none'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, extendPolicy(tt.policy, origins, "script-src", "frame-src", "style-src"), tt.want)
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"HTML", "text/html; charset=utf-8", "<!-- Comment -->\n<p>\n    Hello\n</p>\n", "<p> Hello </p>"},
+		{"Sniffed HTML", "", "<!doctype html>\n\n<html>\n</html>", "<!doctype html> <html> </html>"},
+		{"JSON", "application/json", "{\n  \"a\": 1\n}", "{\n  \"a\": 1\n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("Content-Length", "999")
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte(tt.body))
+			})
+
+			rr := httptest.NewRecorder()
+			minifyHTML(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, rr.Code, http.StatusTeapot)
+			assert.Equal(t, rr.Body.String(), tt.want)
+			assert.Equal(t, rr.Header().Get("Content-Length") == "", tt.want != tt.body)
+		})
+	}
+}
+
+func TestMinifyHTMLPages(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	app.config.MinifyHTML = true
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.follow(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "An old silent pond...")
+	assert.Equal(t, strings.Contains(body, "<!--"), false)
+	assert.Equal(t, strings.Contains(body, "\n    "), false)
+}
+
+func TestServerTiming(t *testing.T) {
+	t.Parallel()
+
+	t.Run("On", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.config.ServerTiming = serverTimingOn
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		code, header, _ := ts.follow(t, "/snippet/view/1")
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, header.Get("Server-Timing"), "render;dur=")
+		assert.StringContains(t, header.Get("Server-Timing"), "total;dur=")
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.config.ServerTiming = serverTimingAdmin
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		_, header, _ := ts.follow(t, "/snippet/view/1")
+		assert.Equal(t, header.Get("Server-Timing"), "")
+
+		ts.login(t, "alice@example.com", "pa$$word")
+		_, header, _ = ts.follow(t, "/snippet/view/1")
+		assert.StringContains(t, header.Get("Server-Timing"), "render;dur=")
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+
+		_, header, _ := ts.follow(t, "/snippet/view/1")
+		assert.Equal(t, header.Get("Server-Timing"), "")
+	})
+}
+
+func TestLogFilter(t *testing.T) {
+	t.Parallel()
+
+	f, err := parseLogFilter("/static/*, /healthz", "/snippet/view/*=0.25,/=1")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		path string
+		want float64
+	}{
+		{"/static/css/main.css", 0},
+		{"/healthz", 0},
+		{"/healthz/extra", 1},
+		{"/snippet/view/1", 0.25},
+		{"/", 1},
+		{"/user/login", 1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, f.rate(tt.path), tt.want)
+	}
+
+	for _, tt := range []struct{ exclude, sample string }{
+		{"static/*", ""},
+		{"/static/*/css", ""},
+		{"", "/snippet/view/*"},
+		{"", "/snippet/view/*=0"},
+		{"", "/snippet/view/*=1.5"},
+	} {
+		_, err := parseLogFilter(tt.exclude, tt.sample)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	var logged strings.Builder
+	app.infoLog = log.New(&logged, "", 0)
+	app.config.LogExclude = "/static/*"
+	app.config.LogSample = "/ping=0.000001"
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.get(t, app.uiCache.manifest().url("css/main.css"))
+	ts.get(t, "/ping")
+	ts.get(t, "/user/login")
+
+	assert.Equal(t, strings.Count(logged.String(), "\n"), 1)
+	assert.StringContains(t, logged.String(), "GET /user/login")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	app := newTestApplication(t)
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	app.clock = frozen
+	app.config.BreakerThreshold = 0.5
+	app.config.BreakerMinRequests = 4
+	app.config.BreakerWindow = time.Minute
+	app.config.BreakerCooldown = 30 * time.Second
+
+	failing := true
+	calls := 0
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/snippet/view/:id", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			panic("database is down")
+		}
+		w.Write([]byte("OK"))
+	})
+	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	handler := app.recoverPanic(app.secureHeaders(app.newCircuitBreaker(router).breakRoutes(router)))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	// Requests for different snippets count towards the same route, and the breaker opens once
+	// enough of them failed.
+	for i := 1; i <= 4; i++ {
+		assert.Equal(t, get(fmt.Sprintf("/snippet/view/%d", i)).Code, http.StatusInternalServerError)
+	}
+	rr := get("/snippet/view/5")
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "30")
+	assert.StringContains(t, rr.Body.String(), "paused it for a moment")
+	assert.Equal(t, strings.Contains(rr.Body.String(), breakerNonce), false)
+	assert.Equal(t, calls, 4)
+
+	// Other routes aren't affected.
+	assert.Equal(t, get("/ping").Code, http.StatusOK)
+
+	// After the cooldown a failing trial request keeps the breaker open.
+	frozen.Advance(30 * time.Second)
+	assert.Equal(t, get("/snippet/view/1").Code, http.StatusInternalServerError)
+	assert.Equal(t, get("/snippet/view/1").Code, http.StatusServiceUnavailable)
+	assert.Equal(t, calls, 5)
+
+	// A successful trial request closes it.
+	failing = false
+	frozen.Advance(30 * time.Second)
+	assert.Equal(t, get("/snippet/view/1").Code, http.StatusOK)
+	assert.Equal(t, get("/snippet/view/2").Code, http.StatusOK)
+	assert.Equal(t, calls, 7)
+}
+
+func TestRoutePattern(t *testing.T) {
+	t.Parallel()
+
+	router := httprouter.New()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandlerFunc(http.MethodGet, "/snippet/view/:id", noop)
+	router.HandlerFunc(http.MethodGet, "/~:username/:slug", noop)
+	router.HandlerFunc(http.MethodGet, "/static/*filepath", noop)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/snippet/view/42", "GET /snippet/view/:id"},
+		{http.MethodGet, "/~alice/alice", "GET /~:username/:slug"},
+		{http.MethodGet, "/static/css/main.css", "GET /static/*filepath"},
+		{http.MethodPost, "/snippet/view/42", ""},
+		{http.MethodGet, "/missing", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, routePattern(router, httptest.NewRequest(tt.method, tt.path, nil)), tt.want)
+	}
+}
@@ -0,0 +1,94 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+	"strings"  // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/htmlmin" // Import the HTML minifier.
+)
+
+// minifyHTML is a middleware that minifies HTML responses as they're written. Other responses, and
+// responses that are already encoded, are passed through untouched.
+func minifyHTML(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw := &minifyWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(mw, r)
+		mw.finish()
+	})
+}
+
+// minifyWriter is the http.ResponseWriter minifyHTML hands to the handlers. It holds the status
+// back until the first write, when the content type of the response is known.
+type minifyWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool            // wroteHeader reports whether the status was passed on.
+	minifier    *htmlmin.Writer // minifier minifies the body, or is nil if it's passed through.
+}
+
+// WriteHeader records the status to send with the first write.
+func (mw *minifyWriter) WriteHeader(status int) {
+	if mw.wroteHeader {
+		return
+	}
+
+	// Informational responses don't end the response, so they're passed on right away.
+	if status < http.StatusOK {
+		mw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	mw.status = status
+}
+
+// Write decides on the first write whether the response is minified, and writes p.
+func (mw *minifyWriter) Write(p []byte) (int, error) {
+	if !mw.wroteHeader {
+		h := mw.Header()
+
+		// Sniff the type the way net/http would, so that the decision sticks.
+		contentType := h.Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(p)
+			h.Set("Content-Type", contentType)
+		}
+
+		if strings.HasPrefix(contentType, "text/html") && h.Get("Content-Encoding") == "" {
+			h.Del("Content-Length")
+			mw.minifier = htmlmin.NewWriter(mw.ResponseWriter)
+		}
+
+		mw.sendHeader()
+	}
+
+	if mw.minifier != nil {
+		return mw.minifier.Write(p)
+	}
+
+	return mw.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController.
+func (mw *minifyWriter) Unwrap() http.ResponseWriter {
+	return mw.ResponseWriter
+}
+
+// finish sends the status of a response without a body and writes what the minifier held back.
+// A failure to write means the client went away, so it's ignored like net/http does.
+func (mw *minifyWriter) finish() {
+	if !mw.wroteHeader {
+		mw.sendHeader()
+	}
+
+	if mw.minifier != nil {
+		mw.minifier.Close()
+	}
+}
+
+// sendHeader passes the recorded status on.
+func (mw *minifyWriter) sendHeader() {
+	mw.wroteHeader = true
+	mw.ResponseWriter.WriteHeader(mw.status)
+}
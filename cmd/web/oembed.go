@@ -0,0 +1,217 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"encoding/xml" // Package for encoding oEmbed responses as XML.
+	"errors"       // Package for creating error messages.
+	"fmt"          // Package for formatted I/O.
+	"html"         // Package for escaping the preview.
+	"net/http"     // Package for building HTTP servers and clients.
+	"net/url"      // Package for parsing the URLs asked about.
+	"strconv"      // Package for converting strings to numeric types.
+	"strings"      // Package for manipulating strings.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// The size of the preview in oEmbed responses.
+const (
+	oembedPreviewLines = 10  // oembedPreviewLines is the number of lines of content shown in the preview.
+	oembedWidth        = 600 // oembedWidth is the width of the preview in pixels, unless the consumer wants it narrower.
+	oembedLineHeight   = 20  // oembedLineHeight is the height of a line of the preview in pixels.
+	oembedTitleHeight  = 40  // oembedTitleHeight is the height of the title above the lines in pixels.
+)
+
+// oembedResponse is the oEmbed description of a snippet: a "rich" embed with a preview of its first
+// lines, linking to the snippet.
+type oembedResponse struct {
+	XMLName      xml.Name `json:"-" xml:"oembed"`
+	Type         string   `json:"type" xml:"type"`
+	Version      string   `json:"version" xml:"version"`
+	Title        string   `json:"title" xml:"title"`
+	AuthorName   string   `json:"author_name,omitempty" xml:"author_name,omitempty"`
+	AuthorURL    string   `json:"author_url,omitempty" xml:"author_url,omitempty"`
+	ProviderName string   `json:"provider_name" xml:"provider_name"`
+	ProviderURL  string   `json:"provider_url" xml:"provider_url"`
+	HTML         string   `json:"html" xml:"html"`
+	Width        int      `json:"width" xml:"width"`
+	Height       int      `json:"height" xml:"height"`
+}
+
+// oembed serves the "/oembed" URL, which describes the snippet at the "url" query parameter to
+// sites that unfurl links with oEmbed, as JSON or, with "format=xml", as XML. It answers 404 for
+// URLs that aren't a snippet of this site, 401 for private snippets and 501 for other formats.
+func (app *application) oembed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "xml" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	snippet, err := app.oembedSnippet(r, query.Get("url"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Held snippets are hidden, and private ones can't be embedded where others can see them.
+	if snippet.Held {
+		app.notFound(w)
+		return
+	}
+	if snippet.Private {
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	link, err := app.snippetVanityURL(snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if link == "" {
+		link = "/snippet/view/" + snippet.PublicID()
+	}
+
+	base := siteURL(r)
+	lines, more := previewLines(snippet.Content)
+
+	resp := oembedResponse{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        snippet.Title,
+		ProviderName: "Snippetbox",
+		ProviderURL:  base + "/",
+		HTML:         oembedHTML(base+link, snippet.Title, lines, more),
+		Width:        oembedWidth,
+		Height:       oembedTitleHeight + len(lines)*oembedLineHeight,
+	}
+	if maxWidth := positiveParam(query, "maxwidth"); maxWidth != 0 {
+		resp.Width = min(resp.Width, maxWidth)
+	}
+	if maxHeight := positiveParam(query, "maxheight"); maxHeight != 0 {
+		resp.Height = min(resp.Height, maxHeight)
+	}
+
+	// Name the author of snippets that have an owner.
+	if snippet.OwnerID != 0 {
+		owner, err := app.users.Get(snippet.OwnerID)
+		switch {
+		case err == nil:
+			resp.AuthorName = owner.Name
+			resp.AuthorURL = base + profileURL(owner.Username)
+			if !hasVanityURL(owner.Username) {
+				resp.AuthorURL = base + "/user/profile/" + url.PathEscape(owner.Username)
+			}
+		case !errors.Is(err, models.ErrNoRecord):
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if format == "json" {
+		app.writeJSON(w, r, http.StatusOK, resp)
+		return
+	}
+
+	out, err := xml.Marshal(resp)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(append(out, '\n'))
+}
+
+// oembedSnippet fetches the snippet an oEmbed request asks about. The URL must be on this site and
+// be the page of a snippet, its vanity URL or its "/s/" URL; otherwise it returns models.ErrNoRecord.
+func (app *application) oembedSnippet(r *http.Request, rawURL string) (*models.Snippet, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Host, r.Host) {
+		return nil, models.ErrNoRecord
+	}
+
+	switch parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/"); {
+	case len(parts) == 3 && parts[0] == "snippet" && parts[1] == "view":
+		return app.snippetByPublicID(parts[2])
+	case len(parts) == 2 && parts[0] == "s":
+		return app.snippetBySlug(parts[1])
+	case len(parts) == 2 && strings.HasPrefix(parts[0], "~"):
+		id, _, _ := strings.Cut(parts[1], "-")
+		snippet, err := app.snippetByPublicID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		// Like the page itself, the snippet must belong to the user in the URL.
+		vanity, err := app.snippetVanityURL(snippet)
+		if err != nil {
+			return nil, err
+		}
+		if vanity == "" || !strings.HasPrefix(vanity, "/"+parts[0]+"/") {
+			return nil, models.ErrNoRecord
+		}
+		return snippet, nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// oembedDiscovery returns the absolute "/oembed" URL that describes the snippet at link, without
+// the format, for the discovery links of the snippet's page.
+func oembedDiscovery(r *http.Request, link string) string {
+	return siteURL(r) + "/oembed?url=" + url.QueryEscape(siteURL(r)+link)
+}
+
+// oembedHTML returns the preview of a snippet in oEmbed responses: its title, linking to the
+// snippet at link, above its first lines.
+func oembedHTML(link, title string, lines []string, more bool) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<blockquote class='snippetbox'><p><a href='%s'>%s</a></p><pre><code>", html.EscapeString(link), html.EscapeString(title))
+	b.WriteString(html.EscapeString(strings.Join(lines, "\n")))
+	if more {
+		b.WriteString("\n…")
+	}
+	b.WriteString("</code></pre></blockquote>")
+
+	return b.String()
+}
+
+// previewLines returns the first lines of content shown in a preview, and whether there are more.
+func previewLines(content string) ([]string, bool) {
+	// Browsers submit textareas with CRLF line endings.
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if len(lines) > oembedPreviewLines {
+		return lines[:oembedPreviewLines], true
+	}
+	return lines, false
+}
+
+// siteURL returns the address of the site the request was made to, such as
+// "https://snippetbox.example.com".
+func siteURL(r *http.Request) string {
+	u := url.URL{Scheme: "https", Host: r.Host}
+	return u.String()
+}
+
+// positiveParam returns the positive integer in the named query parameter, or 0 if it's missing or
+// isn't one.
+func positiveParam(query url.Values, name string) int {
+	n, err := strconv.Atoi(query.Get(name))
+	if err != nil || n < 1 {
+		return 0
+	}
+	return n
+}
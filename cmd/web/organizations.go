@@ -0,0 +1,352 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"net/url"  // Package for building the invitation URLs.
+	"strconv"  // Package for converting strings to numeric types.
+	"time"     // Package for measuring and displaying time.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// invitationLifetime is how long an invitation to an organization can be accepted.
+const invitationLifetime = 7 * 24 * time.Hour
+
+// orgSnippetLimit is the number of snippets listed on an organization's dashboard.
+const orgSnippetLimit = 100
+
+// orgForm represents the form for creating an organization.
+type orgForm struct {
+	Name                string `form:"name" validate:"required,maxrunes=100"`
+	Slug                string `form:"slug" validate:"required,maxrunes=50,slug"`
+	validator.Validator `form:"-"`
+}
+
+// orgInviteForm represents the form for inviting someone to an organization.
+type orgInviteForm struct {
+	Email               string `form:"email" validate:"required,maxbytes=255,email"`
+	Role                string `form:"role" validate:"oneof=member|owner"`
+	validator.Validator `form:"-"`
+}
+
+// orgInvitationEmail is the data of the invitation email template.
+type orgInvitationEmail struct {
+	OrgName   string    // OrgName is the name of the organization.
+	InvitedBy string    // InvitedBy is the name of the member who sent the invitation.
+	Role      string    // Role is the role offered.
+	URL       string    // URL is the link that accepts the invitation.
+	Expires   time.Time // Expires is when the invitation stops working.
+}
+
+// orgList serves the "/orgs" URL. It lists the organizations of the current user.
+func (app *application) orgList(w http.ResponseWriter, r *http.Request) {
+	orgs, err := app.organizations.ByMember(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Organizations = orgs
+
+	app.render(w, r, http.StatusOK, "orgs.html", data)
+}
+
+// orgCreate serves the "/org/create" URL with an empty organization form.
+func (app *application) orgCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = orgForm{}
+
+	app.render(w, r, http.StatusOK, "org_form.html", data)
+}
+
+// orgCreatePost creates an organization with the current user as its owner and redirects to its
+// dashboard.
+func (app *application) orgCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form orgForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	if form.Valid() {
+		_, err := app.organizations.Insert(form.Name, form.Slug, app.authenticatedUserID(r))
+		switch {
+		case err == nil:
+			app.sessionManager.Put(r.Context(), "flash", "Organization created!")
+			http.Redirect(w, r, "/org/view/"+form.Slug, http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrDuplicateOrganization):
+			form.AddFieldError("slug", "This address is already taken")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	app.render(w, r, http.StatusUnprocessableEntity, "org_form.html", data)
+}
+
+// orgView serves the "/org/view/:slug" URL, the dashboard of an organization. It lists the members
+// and the snippets of the organization, and lets owners invite people. Only members can see it.
+func (app *application) orgView(w http.ResponseWriter, r *http.Request) {
+	org, role, ok := app.memberOrg(w, r)
+	if !ok {
+		return
+	}
+
+	app.renderOrg(w, r, http.StatusOK, org, role, orgInviteForm{Role: models.RoleMember})
+}
+
+// orgInvitePost invites the address in the "email" form field to an organization and emails them a
+// link to accept. Only owners can invite.
+func (app *application) orgInvitePost(w http.ResponseWriter, r *http.Request) {
+	org, role, ok := app.memberOrg(w, r)
+	if !ok {
+		return
+	}
+
+	if role != models.RoleOwner {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	var form orgInviteForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	if !form.Valid() {
+		app.renderOrg(w, r, http.StatusUnprocessableEntity, org, role, form)
+		return
+	}
+
+	expires := app.clock.Now().Add(invitationLifetime)
+
+	token, err := app.organizations.Invite(org.ID, app.authenticatedUserID(r), form.Email, form.Role, expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	inviter, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	u := url.URL{
+		Scheme:   "https",
+		Host:     r.Host,
+		Path:     "/org/invitation",
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	}
+
+	app.sendEmail(form.Email, "org_invitation.tmpl", orgInvitationEmail{
+		OrgName:   org.Name,
+		InvitedBy: inviter.Name,
+		Role:      form.Role,
+		URL:       u.String(),
+		Expires:   expires,
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", "Invitation sent to "+form.Email+".")
+	http.Redirect(w, r, "/org/view/"+org.Slug, http.StatusSeeOther)
+}
+
+// orgRemovePost removes the member in the "user_id" form field from an organization. Owners can
+// remove anyone; other members can only remove themselves, which is how they leave.
+func (app *application) orgRemovePost(w http.ResponseWriter, r *http.Request) {
+	org, role, ok := app.memberOrg(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	self := userID == app.authenticatedUserID(r)
+
+	if role != models.RoleOwner && !self {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.organizations.RemoveMember(org.ID, userID)
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w)
+		return
+	case errors.Is(err, models.ErrLastOwner):
+		app.sessionManager.Put(r.Context(), "flash", "An organization needs at least one owner.")
+		http.Redirect(w, r, "/org/view/"+org.Slug, http.StatusSeeOther)
+		return
+	case err != nil:
+		app.serverError(w, r, err)
+		return
+	}
+
+	if self {
+		app.sessionManager.Put(r.Context(), "flash", "You left "+org.Name+".")
+		http.Redirect(w, r, "/orgs", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Member removed.")
+	http.Redirect(w, r, "/org/view/"+org.Slug, http.StatusSeeOther)
+}
+
+// orgInvitation serves the "/org/invitation" URL that invitation emails link to. It shows the
+// invitation in the "token" query parameter, with a button to accept it if the current user is
+// the one who was invited.
+func (app *application) orgInvitation(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	invitation, err := app.organizations.Invitation(token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The token grants membership, so keep the page out of caches.
+	w.Header().Set("Cache-Control", "no-store")
+
+	data := app.newTemplateData(r)
+	data.Invitation = invitation
+	data.InvitationToken = token
+
+	if userID := app.authenticatedUserID(r); userID != 0 {
+		user, err := app.users.Get(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		data.User = user
+	}
+
+	app.render(w, r, http.StatusOK, "org_invitation.html", data)
+}
+
+// orgInvitationPost accepts the invitation in the "token" form field for the current user.
+func (app *application) orgInvitationPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	invitation, err := app.organizations.AcceptInvitation(r.PostForm.Get("token"), user.ID, user.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Welcome to "+invitation.OrgName+"!")
+	http.Redirect(w, r, "/org/view/"+invitation.OrgSlug, http.StatusSeeOther)
+}
+
+// renderOrg renders the dashboard of an organization with the given invitation form.
+func (app *application) renderOrg(w http.ResponseWriter, r *http.Request, status int, org *models.Organization, role string, form orgInviteForm) {
+	members, err := app.organizations.Members(org.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	all, err := app.snippets.ByOrg(org.ID, orgSnippetLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Permission rules can hide private snippets from some members.
+	snippets := []*models.Snippet{}
+	for _, s := range all {
+		if app.canView(r, s) {
+			snippets = append(snippets, s)
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Organization = org
+	data.OrgRole = role
+	data.Members = members
+	data.SnippetsData = snippets
+	data.Form = form
+
+	if role == models.RoleOwner {
+		data.Invitations, err = app.organizations.Invitations(org.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.render(w, r, status, "org.html", data)
+}
+
+// memberOrg fetches the organization identified by the "slug" URL parameter and the current user's
+// role in it. If the organization doesn't exist or the user isn't a member, it sends a 404
+// response and returns false.
+func (app *application) memberOrg(w http.ResponseWriter, r *http.Request) (*models.Organization, string, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	org, err := app.organizations.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, "", false
+	}
+
+	role, err := app.organizations.Role(org.ID, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, "", false
+	}
+
+	if role == "" {
+		app.notFound(w)
+		return nil, "", false
+	}
+
+	return org, role, true
+}
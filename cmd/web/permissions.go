@@ -0,0 +1,271 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+
+	"snippetbox.adcon.dev/internal/filter"    // Import the content filter package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// snippetEditForm represents the form for editing the title and content of a snippet.
+type snippetEditForm struct {
+	Title               string `form:"title" validate:"required,maxrunes=100"`
+	Content             string `form:"content" validate:"required"`
+	Format              bool   `form:"format"`
+	License             string `form:"license"`
+	CustomLicense       string `form:"custom_license" validate:"maxrunes=100"`
+	Metadata            string `form:"metadata"`
+	validator.Validator `form:"-"`
+}
+
+// permissionRow is a line of the permissions table of an organization's snippet: a role or a
+// member, and the level its rule sets, which is empty if it has no rule.
+type permissionRow struct {
+	Label  string // Label names the role or the member.
+	UserID int    // UserID is the member the row is for, or 0 for a role.
+	Role   string // Role is the role the row is for, or empty for a member.
+	Level  string // Level is the level of the rule, or empty for the default.
+}
+
+// snippetEdit serves the "/snippet/edit/:id" URL with the form filled in with the snippet. Only
+// users who may write the snippet and admins can edit it.
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.writableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetData = snippet
+	form := snippetEditForm{Title: snippet.Title, Content: snippet.Content}
+	form.License, form.CustomLicense = licenseFields(snippet.License)
+
+	metadata, err := app.snippets.Metadata(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	form.Metadata = formatMetadata(metadata)
+
+	if draft := app.loadDraft(r, snippet.ID); draft != nil {
+		form.Title, form.Content = draft.Title, draft.Content
+		data.Draft = draft
+	}
+	data.Form = form
+
+	// Tell the user if someone else has the snippet open for editing.
+	data.EditLock = app.lockEditor(r, snippet.ID)
+
+	app.render(w, r, http.StatusOK, "edit.html", data)
+}
+
+// snippetEditPost saves the edited title and content of a snippet. Like new snippets, edits are
+// screened by the content filter.
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.writableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+	app.checkSize(&form.Validator, form.Title, form.Content)
+	license := checkLicense(&form.Validator, form.License, form.CustomLicense)
+	metadata := checkMetadata(&form.Validator, form.Metadata)
+
+	verdict, err := app.screen(form.Title, form.Content)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if verdict.Action == filter.Reject {
+		if err := app.recordFilterHit(r, verdict, 0); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.AddNonFieldError("This snippet contains content that isn't allowed")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.SnippetData = snippet
+		data.Form = form
+		data.EditLock = app.lockEditor(r, snippet.ID)
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.html", data)
+		return
+	}
+
+	var warning string
+	if form.Format {
+		form.Content, warning = formatContent(form.Title, form.Content)
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, app.authenticatedUserID(r), app.isAdmin(r))
+	if err != nil {
+		if errors.Is(err, models.ErrPermissionDenied) {
+			app.clientError(w, http.StatusForbidden)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if license != snippet.License {
+		if err := app.snippets.SetLicense(snippet.ID, license); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+	if err := app.snippets.SetMetadata(snippet.ID, metadata); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.detectLanguage(snippet.ID, snippet.Language, form.Title, form.Content)
+	app.clearDraft(r, snippet.ID)
+	if err := app.locks.Release(snippet.ID, app.authenticatedUserID(r)); err != nil {
+		app.errorLog.Printf("unlocking snippet %d: %v", snippet.ID, err)
+	}
+
+	if err := app.applyVerdict(r, verdict, snippet.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.sendWebmentions(snippet.ID)
+
+	if verdict.Action == filter.Hold {
+		app.sessionManager.Put(r.Context(), "flash", withWarning("Snippet updated! It will be listed again once a moderator has reviewed it.", warning))
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", withWarning("Snippet updated!", warning))
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// snippetPermissionPost sets the permission rule of an organization's snippet for the member in
+// the "user_id" form field or the role in the "role" field, to the level in the "level" field. An
+// empty level removes the rule. Only the snippet's owner and the organization's owners can do this.
+func (app *application) snippetPermissionPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippetFromParams(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	rule := models.PermissionRule{
+		SnippetID: snippet.ID,
+		Role:      r.PostForm.Get("role"),
+		Level:     r.PostForm.Get("level"),
+	}
+	if id := r.PostForm.Get("user_id"); id != "" {
+		rule.UserID, err = strconv.Atoi(id)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	valid := snippet.OrgID != 0 &&
+		(rule.UserID == 0) != (rule.Role == "") &&
+		(rule.Role == "" || models.ValidRole(rule.Role)) &&
+		(rule.Level == "" || models.ValidRuleLevel(rule.Level))
+	if !valid {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.SetPermission(app.authenticatedUserID(r), rule)
+	if err != nil {
+		if errors.Is(err, models.ErrPermissionDenied) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Permissions updated.")
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// permissionRows returns the permissions table of an organization's snippet: a row for the member
+// role and one for each member who isn't an owner, since owners can always manage the snippet.
+func (app *application) permissionRows(snippet *models.Snippet) ([]permissionRow, error) {
+	members, err := app.organizations.Members(snippet.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := app.snippets.Permissions(snippet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := map[permissionRow]string{}
+	for _, rule := range rules {
+		levels[permissionRow{UserID: rule.UserID, Role: rule.Role}] = rule.Level
+	}
+
+	rows := []permissionRow{{Label: "All members", Role: models.RoleMember, Level: levels[permissionRow{Role: models.RoleMember}]}}
+
+	for _, m := range members {
+		if m.Role == models.RoleOwner || m.UserID == snippet.OwnerID {
+			continue
+		}
+		rows = append(rows, permissionRow{Label: m.Name, UserID: m.UserID, Level: levels[permissionRow{UserID: m.UserID}]})
+	}
+
+	return rows, nil
+}
+
+// writableSnippet fetches the snippet identified by the "id" URL parameter for a handler that
+// edits it. Admins can edit any snippet. If the snippet doesn't exist or the user can't see it, it
+// sends a 404 response, and if they can only read it a 403 response, and returns false.
+func (app *application) writableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippetFromParams(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	level, err := app.snippets.Permission(snippet.ID, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	switch {
+	case models.Allows(level, models.PermissionWrite), app.isAdmin(r):
+		return snippet, true
+	case app.canView(r, snippet):
+		app.clientError(w, http.StatusForbidden)
+	default:
+		app.notFound(w)
+	}
+
+	return nil, false
+}
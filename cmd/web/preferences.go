@@ -0,0 +1,67 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// preferencesForm represents the form for choosing the defaults of new snippets.
+type preferencesForm struct {
+	Expires             int    `form:"expires" validate:"oneof=-1|1|7|365"`
+	Private             bool   `form:"private"`
+	Language            string `form:"language"`
+	validator.Validator `form:"-"`
+}
+
+// accountPreferences serves the "/account/preferences" URL, where users choose what the snippet
+// form is filled in with: when new snippets expire, whether they're private and their language.
+func (app *application) accountPreferences(w http.ResponseWriter, r *http.Request) {
+	preferences, err := app.preferences.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = preferencesForm{
+		Expires:  preferences.DefaultExpires,
+		Private:  preferences.DefaultPrivate,
+		Language: preferences.DefaultLanguage,
+	}
+
+	app.render(w, r, http.StatusOK, "preferences.html", data)
+}
+
+// accountPreferencesPost saves the snippet defaults of the current user.
+func (app *application) accountPreferencesPost(w http.ResponseWriter, r *http.Request) {
+	var form preferencesForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+	form.CheckField(validLanguage(form.Language), "language", "Choose a language from the list")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "preferences.html", data)
+		return
+	}
+
+	preferences := models.Preferences{DefaultExpires: form.Expires, DefaultPrivate: form.Private, DefaultLanguage: form.Language}
+	if err := app.preferences.Set(app.authenticatedUserID(r), preferences); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "New snippets will start out with your defaults.")
+
+	http.Redirect(w, r, "/account/preferences", http.StatusSeeOther)
+}
@@ -0,0 +1,38 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+)
+
+// anonymizeIP zeroes the host part of an IP address, the last octet of IPv4 addresses and all but
+// the first 48 bits of IPv6 addresses, as in "192.0.2.0". It returns an empty string for anything
+// that isn't an IP address, so that nothing identifying slips through.
+func anonymizeIP(ip string) string {
+	prefix, ok := coarsePrefix(ip)
+	if !ok {
+		return ""
+	}
+
+	return prefix.Addr().String()
+}
+
+// logAddr returns the client address to write to the logs: the remote address of the request, or
+// its anonymized IP address in privacy mode.
+func (app *application) logAddr(r *http.Request) string {
+	if app.config.AnonymizeIPs {
+		return anonymizeIP(clientIP(r))
+	}
+
+	return r.RemoteAddr
+}
+
+// storedIP returns the client IP address to store with data, anonymized in privacy mode.
+func (app *application) storedIP(r *http.Request) string {
+	if app.config.AnonymizeIPs {
+		return anonymizeIP(clientIP(r))
+	}
+
+	return clientIP(r)
+}
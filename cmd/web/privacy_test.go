@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.77", "203.0.113.0"},
+		{"2001:db8:1234:5678::1", "2001:db8:1234::"},
+		{"::ffff:198.51.100.9", "198.51.100.0"},
+		{"pipe", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, anonymizeIP(tt.ip), tt.want)
+	}
+}
+
+func TestLogRequestPrivacy(t *testing.T) {
+	t.Parallel()
+
+	for _, anonymize := range []bool{false, true} {
+		var buf bytes.Buffer
+
+		app := newTestApplication(t)
+		app.infoLog = log.New(&buf, "", 0)
+		app.config.AnonymizeIPs = anonymize
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "203.0.113.77:5000"
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+		app.logRequest(next).ServeHTTP(httptest.NewRecorder(), r)
+
+		if anonymize {
+			assert.Equal(t, strings.HasPrefix(buf.String(), "203.0.113.0 - "), true)
+		} else {
+			assert.Equal(t, strings.HasPrefix(buf.String(), "203.0.113.77:5000 - "), true)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+	"sync"     // Package for synchronizing access to the limiter map.
+	"time"     // Package for measuring and displaying time.
+
+	"golang.org/x/time/rate"
+)
+
+// ipRateLimiter keeps a token-bucket limiter per client IP address. Limiters for clients that
+// haven't been seen for a while are removed so that the map doesn't grow without bound.
+type ipRateLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	clients  map[string]*rateClient
+	lastSeen time.Time
+}
+
+// rateClient is the limiter state for a single client.
+type rateClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newIPRateLimiter creates a limiter that allows each client rps requests per second on average,
+// with bursts of up to burst requests.
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		clients: map[string]*rateClient{},
+	}
+}
+
+// allow reports whether a request from ip may proceed.
+func (l *ipRateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	// Every few minutes, forget the clients that have been idle long enough for their bucket to refill.
+	if now.Sub(l.lastSeen) > 3*time.Minute {
+		for key, c := range l.clients {
+			if now.Sub(c.lastSeen) > 3*time.Minute {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSeen = now
+	}
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &rateClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = now
+
+	return c.limiter.Allow()
+}
+
+// rateLimit is a middleware function that responds with 429 Too Many Requests when a client
+// exceeds the limits of the given limiter.
+func (app *application) rateLimit(limiter *ipRateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.allow(clientIP(r)) {
+				w.Header().Set("Retry-After", "1")
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+
+	t.Parallel()
+
+	limiter := newIPRateLimiter(0.001, 2)
+
+	assert.Equal(t, limiter.allow("192.0.2.1"), true)
+	assert.Equal(t, limiter.allow("192.0.2.1"), true)
+	assert.Equal(t, limiter.allow("192.0.2.1"), false)
+
+	// Other clients have their own buckets.
+	assert.Equal(t, limiter.allow("192.0.2.2"), true)
+}
@@ -0,0 +1,48 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+)
+
+// readOnlyRetryAfter is the number of seconds clients are asked to wait before retrying a write
+// rejected in read-only mode.
+const readOnlyRetryAfter = "300"
+
+// rejectWrites is a middleware that, in read-only mode, answers requests that would change data
+// with 503 Service Unavailable. Reads go through, and so do logging in and out if the deployment
+// allows it, since those only touch the sessions.
+func (app *application) rejectWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.ReadOnly || safeMethod(r.Method) || app.readOnlyAllows(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		app.readOnly(w, r)
+	})
+}
+
+// safeMethod reports whether requests with the method only read data.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// readOnlyAllows reports whether a write is allowed in read-only mode.
+func (app *application) readOnlyAllows(r *http.Request) bool {
+	return app.config.ReadOnlyLogins && (r.URL.Path == "/user/login" || r.URL.Path == "/user/logout")
+}
+
+// readOnly sends the response to a write rejected in read-only mode: problem details for the API and
+// a page explaining the maintenance otherwise.
+func (app *application) readOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", readOnlyRetryAfter)
+
+	if isAPIRequest(r) {
+		app.writeProblem(w, r, http.StatusServiceUnavailable, "The site is read-only for maintenance; nothing was changed.", r.URL.Path)
+		return
+	}
+
+	app.render(w, r, http.StatusServiceUnavailable, "readonly.html", app.newTemplateData(r))
+}
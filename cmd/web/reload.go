@@ -0,0 +1,123 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"fmt"           // Package for formatted I/O.
+	"html/template" // Package for the template sets.
+	"io/fs"         // Package for reading the templates and assets.
+	"net/http"      // Package for building HTTP servers and clients.
+	"os"            // Package for reading -ui-dir.
+	"sync"          // Package for guarding the cache while it's rebuilt.
+
+	"snippetbox.adcon.dev/ui" // Import the embedded templates and assets.
+)
+
+// uiCache holds the template cache and the static asset manifest. They're built together, since
+// the templates link the assets by their fingerprinted names, and can be rebuilt while the server
+// runs, from the admin dashboard or with SIGUSR1, so that changes to the templates and assets in
+// -ui-dir are picked up without a restart.
+type uiCache struct {
+	fsys fs.FS // fsys holds the html and static directories: the embedded files, or -ui-dir.
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	assets    *assetManifest
+	static    http.Handler // static serves the assets of the manifest.
+}
+
+// uiFiles returns the files the templates and static assets are read from: the directory in
+// -ui-dir, laid out like the ui directory of the repository, or the files embedded in the binary.
+func uiFiles(dir string) (fs.FS, error) {
+	if dir == "" {
+		return ui.Files, nil
+	}
+
+	fsys := os.DirFS(dir)
+	if _, err := fs.Stat(fsys, "html/base.html"); err != nil {
+		return nil, fmt.Errorf("-ui-dir %q has no html/base.html", dir)
+	}
+
+	return fsys, nil
+}
+
+// newUICache builds the template cache and the asset manifest from fsys.
+func newUICache(fsys fs.FS) (*uiCache, error) {
+	c := &uiCache{fsys: fsys}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// reload rebuilds the templates and the asset manifest. If either fails to build, for instance
+// because a template doesn't parse, the ones in use are kept.
+func (c *uiCache) reload() error {
+	assets, err := newAssetManifest(c.fsys, "static")
+	if err != nil {
+		return err
+	}
+
+	templates, err := newTemplateCache(c.fsys, assets)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.templates = templates
+	c.assets = assets
+	c.static = assets.handler(c.fsys)
+
+	return nil
+}
+
+// template returns the template set of a page.
+func (c *uiCache) template(page string) (*template.Template, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ts, ok := c.templates[page]
+	return ts, ok
+}
+
+// manifest returns the asset manifest in use.
+func (c *uiCache) manifest() *assetManifest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.assets
+}
+
+// ServeHTTP serves the static assets.
+func (c *uiCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	static := c.static
+	c.mu.RUnlock()
+
+	static.ServeHTTP(w, r)
+}
+
+// reloadUI rebuilds the templates and assets, and logs who asked for it.
+func (app *application) reloadUI(by string) error {
+	if err := app.uiCache.reload(); err != nil {
+		app.errorLog.Printf("reloading templates and assets (%s): %v", by, err)
+		return err
+	}
+
+	app.infoLog.Printf("reloaded templates and assets (%s)", by)
+	return nil
+}
+
+// adminReloadPost rebuilds the templates and assets, for the button on the admin dashboard.
+func (app *application) adminReloadPost(w http.ResponseWriter, r *http.Request) {
+	if err := app.reloadUI(fmt.Sprintf("admin %d", app.authenticatedUserID(r))); err != nil {
+		app.sessionManager.Put(r.Context(), "flash", "The templates and assets couldn't be reloaded, so the old ones are still in use: "+err.Error())
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "The templates and assets were reloaded.")
+	}
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
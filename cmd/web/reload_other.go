@@ -0,0 +1,8 @@
+//go:build !unix
+
+// Package main is the main package for this application.
+package main
+
+// reloadOnSignal does nothing on systems without SIGUSR1, where the templates and assets are only
+// reloaded from the admin dashboard.
+func (app *application) reloadOnSignal() {}
@@ -0,0 +1,24 @@
+//go:build unix
+
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"os"        // Package for the signal channel.
+	"os/signal" // Package for receiving SIGUSR1.
+	"syscall"   // Package for the signal numbers.
+)
+
+// reloadOnSignal rebuilds the templates and assets whenever the process receives SIGUSR1, as in
+// kill -USR1 <pid>.
+func (app *application) reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	go func() {
+		for range signals {
+			app.reloadUI("SIGUSR1")
+		}
+	}()
+}
@@ -0,0 +1,186 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+	"time"     // Package for measuring and displaying time.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/mailer"    // Import the email package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+	"snippetbox.adcon.dev/ui"                 // Import the embedded templates.
+)
+
+// expiryExtension is how much longer a snippet is kept when it's extended from a reminder.
+const expiryExtension = 30 * 24 * time.Hour
+
+// reminderForm represents the form for choosing how many days before expiry to be reminded.
+type reminderForm struct {
+	Days                int `form:"days" validate:"oneof=0|1|3|7"`
+	validator.Validator `form:"-"`
+}
+
+// reminderEmail is the data of the expiry reminder email template.
+type reminderEmail struct {
+	Name        string // Name is the name of the recipient.
+	Title       string // Title is the title of the expiring snippet.
+	Expires     string // Expires is when the snippet expires.
+	SnippetURL  string // SnippetURL is the page of the snippet.
+	ExtendURL   string // ExtendURL extends the snippet in one click.
+	Extension   int    // Extension is the number of days ExtendURL adds.
+	SettingsURL string // SettingsURL is the page where reminders can be turned off.
+}
+
+// extendedPage is the data of the page shown after a snippet was extended from a reminder.
+type extendedPage struct {
+	Title      string    // Title is the title of the snippet.
+	Expires    time.Time // Expires is the new expiry of the snippet.
+	SnippetURL string    // SnippetURL is the page of the snippet.
+}
+
+// accountReminders serves the "/account/reminders" URL, where users choose whether and how long
+// before their snippets expire they get a reminder by email.
+func (app *application) accountReminders(w http.ResponseWriter, r *http.Request) {
+	days, err := app.reminders.Days(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = reminderForm{Days: days}
+
+	app.render(w, r, http.StatusOK, "reminders.html", data)
+}
+
+// accountRemindersPost saves the reminder setting of the current user.
+func (app *application) accountRemindersPost(w http.ResponseWriter, r *http.Request) {
+	var form reminderForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "reminders.html", data)
+		return
+	}
+
+	if err := app.reminders.SetDays(app.authenticatedUserID(r), form.Days); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	switch form.Days {
+	case 0:
+		app.sessionManager.Put(r.Context(), "flash", "You won't be reminded about expiring snippets anymore.")
+	case 1:
+		app.sessionManager.Put(r.Context(), "flash", "You'll be reminded a day before your snippets expire.")
+	default:
+		app.sessionManager.Put(r.Context(), "flash", "You'll be reminded "+strconv.Itoa(form.Days)+" days before your snippets expire.")
+	}
+
+	http.Redirect(w, r, "/account/reminders", http.StatusSeeOther)
+}
+
+// snippetExtend serves the "/snippet/extend/:id" links of reminder emails. The "token" query
+// parameter authorizes pushing the expiry of the snippet back by expiryExtension, once, without
+// logging in.
+func (app *application) snippetExtend(w http.ResponseWriter, r *http.Request) {
+	// Following the link changes the snippet, even though it's a GET.
+	if app.config.ReadOnly {
+		app.readOnly(w, r)
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	expires, err := app.reminders.Extend(id, r.URL.Query().Get("token"), expiryExtension)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = extendedPage{Title: snippet.Title, Expires: expires, SnippetURL: "/snippet/view/" + snippet.PublicID()}
+
+	app.render(w, r, http.StatusOK, "extended.html", data)
+}
+
+// sendExpiryReminders emails the owners of snippets that are about to expire. It's run
+// periodically by a background job. Like digests, each reminder is claimed before it's sent, so it's
+// never sent twice.
+func (app *application) sendExpiryReminders() error {
+	due, err := app.reminders.Due()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, reminder := range due {
+		claimed, err := app.reminders.Claim(reminder)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := app.sendExpiryReminder(reminder); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendExpiryReminder builds and sends one reminder.
+func (app *application) sendExpiryReminder(reminder *models.ExpiryReminder) error {
+	base := strings.TrimSuffix(app.config.BaseURL, "/")
+
+	data := reminderEmail{
+		Name:        reminder.Name,
+		Title:       reminder.Title,
+		Expires:     humanDate(reminder.Expires),
+		SnippetURL:  base + "/snippet/view/" + reminder.PublicID(),
+		ExtendURL:   base + "/snippet/extend/" + strconv.Itoa(reminder.SnippetID) + "?token=" + app.reminders.Token(reminder),
+		Extension:   int(expiryExtension / (24 * time.Hour)),
+		SettingsURL: base + "/account/reminders",
+	}
+
+	msg, err := mailer.Render(ui.Files, "email/expiry_reminder.tmpl", reminder.Email, data)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(msg)
+}
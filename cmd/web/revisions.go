@@ -0,0 +1,163 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"fmt"      // Package for formatting strings.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// diffContext is the number of unchanged lines shown around each change of a diff.
+const diffContext = 3
+
+// maxDiffLines is the number of lines either revision may have for the diff page to compare them.
+// Finding the changes takes time quadratic in the number of lines, so longer revisions would tie
+// up the server.
+const maxDiffLines = 2000
+
+// errDiffTooLarge is returned by unifiedDiff for revisions of more than maxDiffLines lines.
+var errDiffTooLarge = errors.New("revisions too long to compare")
+
+// revisionDiff is the comparison of two revisions of a snippet shown on its diff page.
+type revisionDiff struct {
+	Revisions []*models.Revision // Revisions lists every revision of the snippet, for choosing which to compare.
+	From, To  *models.Revision   // From and To are the compared revisions.
+	Lines     []diffLine         // Lines is the unified diff from From to To, empty if they're the same.
+	TooLarge  bool               // TooLarge reports whether the revisions were too long to compare.
+}
+
+// diffLine is a line of a unified diff, with its kind for styling: "header", "hunk", "added",
+// "removed" or "context".
+type diffLine struct {
+	Kind string
+	Text string
+}
+
+// snippetDiff serves the "/snippet/diff/:id" URL, with the changes between two revisions of a
+// snippet given by the "from" and "to" query parameters. They default to the last two revisions.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.viewableSnippet(w, r, httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	latest := revisions[len(revisions)-1].Number
+	from, okFrom := revisionParam(r, "from", max(latest-1, 1))
+	to, okTo := revisionParam(r, "to", latest)
+	if !okFrom || !okTo {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	diff := &revisionDiff{Revisions: revisions}
+	for _, rev := range revisions {
+		if rev.Number == from {
+			diff.From = rev
+		}
+		if rev.Number == to {
+			diff.To = rev
+		}
+	}
+	if diff.From == nil || diff.To == nil {
+		app.notFound(w)
+		return
+	}
+
+	diff.Lines, err = unifiedDiff(diff.From, diff.To)
+	switch {
+	case errors.Is(err, errDiffTooLarge):
+		diff.TooLarge = true
+	case err != nil:
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetData = snippet
+	data.Diff = diff
+
+	app.render(w, r, http.StatusOK, "diff.html", data)
+}
+
+// revisionParam returns the revision number in the query parameter, or def if it's missing. It
+// returns false if the parameter isn't a positive integer.
+func revisionParam(r *http.Request, name string, def int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// unifiedDiff returns the lines of the unified diff between the contents of two revisions, or
+// errDiffTooLarge if either has more than maxDiffLines lines.
+func unifiedDiff(from, to *models.Revision) ([]diffLine, error) {
+	// Browsers submit textareas with CRLF line endings, which would otherwise show as changes.
+	normalize := func(s string) []string {
+		return difflib.SplitLines(strings.ReplaceAll(s, "\r\n", "\n"))
+	}
+
+	a, b := normalize(from.Content), normalize(to.Content)
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return nil, errDiffTooLarge
+	}
+
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        a,
+		B:        b,
+		FromFile: fmt.Sprintf("revision %d", from.Number),
+		ToFile:   fmt.Sprintf("revision %d", to.Number),
+		Context:  diffContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []diffLine{}
+	for i, text := range strings.SplitAfter(text, "\n") {
+		if text == "" {
+			continue
+		}
+
+		kind := "context"
+		switch {
+		case i < 2:
+			// The first two lines name the compared revisions.
+			kind = "header"
+		case strings.HasPrefix(text, "@@"):
+			kind = "hunk"
+		case strings.HasPrefix(text, "+"):
+			kind = "added"
+		case strings.HasPrefix(text, "-"):
+			kind = "removed"
+		}
+
+		lines = append(lines, diffLine{Kind: kind, Text: strings.TrimSuffix(text, "\n")})
+	}
+
+	return lines, nil
+}
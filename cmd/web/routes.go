@@ -3,9 +3,9 @@ package main
 
 // Import the necessary packages.
 import (
+	"expvar"   // Package for exposing counters to monitoring.
 	"net/http" // Package for building HTTP servers and clients.
-
-	"snippetbox.adcon.dev/ui"
+	"sort"     // Package for sorting the content pages.
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -24,36 +24,199 @@ func (app *application) routes() http.Handler {
 		app.notFound(w)
 	})
 
-	fileServer := http.FileServer(http.FS(ui.Files))
-	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
+	// Answer requests with a method the path doesn't take with the error page, or problem details
+	// for the API, and OPTIONS requests with the methods it does take. The router sets the Allow
+	// header for both. The error page needs the session to show the navigation.
+	router.HandleMethodNotAllowed = true
+	router.MethodNotAllowed = alice.New(app.loadAndSave, app.authenticate).ThenFunc(app.methodNotAllowed)
+	router.HandleOPTIONS = true
+	router.GlobalOPTIONS = http.HandlerFunc(globalOptions)
+
+	router.Handler(http.MethodGet, "/static/*filepath", app.uiCache)
 
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
+	router.HandlerFunc(http.MethodGet, "/healthz", app.healthz)
 
-	dynamic := alice.New(app.sessionManager.LoadAndSave, app.authenticate)
+	dynamic := alice.New(app.loadAndSave, app.authenticate, app.auditImpersonation, app.rejectWrites)
 
 	// Register handler functions for URL patterns.
 	// When a request URL matches one of these patterns, the corresponding handler function is called.
-	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
+	router.Handler(http.MethodGet, "/user/signup", dynamic.Append(app.geoRestrict).ThenFunc(app.userSignup))
+	router.Handler(http.MethodPost, "/user/signup", dynamic.Append(app.geoRestrict, app.discardBots("/user/login")).ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
 
+	// The availability check is rate limited per client to make account enumeration expensive.
+	checkLimiter := newIPRateLimiter(1, 10)
+	router.Handler(http.MethodGet, "/user/check", alice.New(app.rateLimit(checkLimiter)).ThenFunc(app.userCheck))
+
+	// Other sites send Webmentions of snippets without a session, so the endpoint is rate limited
+	// per client rather than protected.
+	if app.config.Webmentions {
+		webmentionLimiter := newIPRateLimiter(1, 10)
+		router.Handler(http.MethodPost, "/webmention", alice.New(app.rateLimit(webmentionLimiter)).Extend(dynamic).ThenFunc(app.webmentionPost))
+	}
+
+	// Email providers report bounces with a shared secret rather than a session.
+	if app.config.BounceWebhookSecret != "" {
+		router.Handler(http.MethodPost, "/webhooks/bounce", dynamic.ThenFunc(app.bounceWebhookPost))
+	}
+
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	router.Handler(http.MethodGet, "/popular", dynamic.ThenFunc(app.popular))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/extend/:id", dynamic.ThenFunc(app.snippetExtend))
+	router.Handler(http.MethodGet, "/snippet/download/:file", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/diff/:id", dynamic.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodGet, "/s/:slug", dynamic.ThenFunc(app.snippetShared))
+	router.Handler(http.MethodGet, "/x/:code", dynamic.ThenFunc(app.shortLinkRedirect))
+	router.Handler(http.MethodGet, "/oembed", dynamic.ThenFunc(app.oembed))
+
+	// The API answers unauthenticated requests with JSON errors rather than redirects. Scripts
+	// authenticate with API tokens rather than sessions.
+	api := dynamic.Append(app.authenticateToken)
+	router.Handler(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodPost, "/api/v1/snippets", api.Append(app.geoRestrict).ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", api.ThenFunc(app.apiSnippetView))
+	router.Handler(http.MethodPost, "/api/v1/shortlinks", api.ThenFunc(app.apiShortLinkCreate))
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
+	router.Handler(http.MethodGet, "/user/profile/:username", dynamic.ThenFunc(app.userProfileRedirect))
+	router.Handler(http.MethodGet, "/~:username", dynamic.ThenFunc(app.userProfile))
+	router.Handler(http.MethodGet, "/~:username/:slug", dynamic.ThenFunc(app.vanitySnippetView))
+	router.Handler(http.MethodGet, "/collection/view/:id", dynamic.ThenFunc(app.collectionView))
+	router.Handler(http.MethodGet, "/org/invitation", dynamic.ThenFunc(app.orgInvitation))
 
 	protected := dynamic.Append(app.requireAuthentication)
 
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	// Admins impersonating a user can't take actions that outlive the impersonation, such as
+	// creating API tokens, or that act on the account as a whole.
+	sensitive := protected.Append(app.refuseImpersonation)
+
+	// With -anonymous-posting, visitors can create snippets without logging in, at a limited rate.
+	create := protected
+	if app.config.AnonymousPosting {
+		create = dynamic
+	}
+	anonymousLimiter := newAnonymousLimiter()
+
+	router.Handler(http.MethodGet, "/snippet/create", create.Append(app.geoRestrict).ThenFunc(app.snippetCreate))
+	router.Handler(http.MethodPost, "/snippet/create", create.Append(app.geoRestrict, app.rateLimitAnonymous(anonymousLimiter), app.discardBots("/")).ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodPost, "/snippet/draft", create.ThenFunc(app.snippetDraftPost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/lock/:id", protected.ThenFunc(app.snippetLockPost))
+	router.Handler(http.MethodPost, "/snippet/unlock/:id", protected.ThenFunc(app.snippetUnlockPost))
+	router.Handler(http.MethodPost, "/snippet/permission/:id", protected.ThenFunc(app.snippetPermissionPost))
+	router.Handler(http.MethodGet, "/snippet/accesses/:id", protected.ThenFunc(app.snippetAccesses))
+	router.Handler(http.MethodPost, "/snippet/shorten/:id", protected.ThenFunc(app.snippetShortenPost))
+	router.Handler(http.MethodPost, "/snippet/private/:id", protected.ThenFunc(app.snippetPrivatePost))
+	router.Handler(http.MethodPost, "/snippet/share/:id", protected.ThenFunc(app.snippetSharePost))
+	router.Handler(http.MethodPost, "/snippet/unshare/:id", protected.ThenFunc(app.snippetUnsharePost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(app.snippetDeletePost))
+	router.Handler(http.MethodPost, "/snippet/star/:id", protected.ThenFunc(app.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/unstar/:id", protected.ThenFunc(app.snippetUnstarPost))
+	router.Handler(http.MethodGet, "/account/favorites", protected.ThenFunc(app.accountFavorites))
+	router.Handler(http.MethodGet, "/trash", protected.ThenFunc(app.trash))
+	router.Handler(http.MethodPost, "/trash/restore/:id", protected.ThenFunc(app.trashRestorePost))
+	router.Handler(http.MethodPost, "/trash/purge/:id", protected.ThenFunc(app.trashPurgePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodPost, "/impersonation/stop", protected.ThenFunc(app.impersonationStopPost))
+	router.Handler(http.MethodGet, "/account/password/update", sensitive.ThenFunc(app.accountPasswordUpdate))
+	router.Handler(http.MethodPost, "/account/password/update", sensitive.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodGet, "/account/digest", protected.ThenFunc(app.accountDigest))
+	router.Handler(http.MethodPost, "/account/digest", sensitive.ThenFunc(app.accountDigestPost))
+	router.Handler(http.MethodGet, "/account/email", protected.ThenFunc(app.accountEmail))
+	router.Handler(http.MethodPost, "/account/email/reactivate", sensitive.ThenFunc(app.accountEmailReactivatePost))
+	router.Handler(http.MethodGet, "/account/reminders", protected.ThenFunc(app.accountReminders))
+	router.Handler(http.MethodPost, "/account/reminders", sensitive.ThenFunc(app.accountRemindersPost))
+	router.Handler(http.MethodGet, "/account/preferences", protected.ThenFunc(app.accountPreferences))
+	router.Handler(http.MethodPost, "/account/preferences", sensitive.ThenFunc(app.accountPreferencesPost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", sensitive.ThenFunc(app.accountTokensPost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", sensitive.ThenFunc(app.accountTokenRevokePost))
+	router.Handler(http.MethodGet, "/account/data-export", protected.ThenFunc(app.accountDataExport))
+	router.Handler(http.MethodPost, "/account/data-export", sensitive.ThenFunc(app.accountDataExportPost))
+	router.Handler(http.MethodGet, "/account/data-export/download/:id", sensitive.ThenFunc(app.accountDataExportDownload))
+	router.Handler(http.MethodGet, "/account/import", protected.ThenFunc(app.accountImport))
+	router.Handler(http.MethodPost, "/account/import", sensitive.ThenFunc(app.accountImportPost))
+	router.Handler(http.MethodGet, "/account/delete", sensitive.ThenFunc(app.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", sensitive.ThenFunc(app.accountDeletePost))
+	router.Handler(http.MethodGet, "/collections", protected.ThenFunc(app.collectionList))
+	router.Handler(http.MethodGet, "/collection/create", protected.ThenFunc(app.collectionCreate))
+	router.Handler(http.MethodPost, "/collection/create", protected.ThenFunc(app.collectionCreatePost))
+	router.Handler(http.MethodGet, "/collection/edit/:id", protected.ThenFunc(app.collectionEdit))
+	router.Handler(http.MethodPost, "/collection/edit/:id", protected.ThenFunc(app.collectionEditPost))
+	router.Handler(http.MethodPost, "/collection/delete/:id", protected.ThenFunc(app.collectionDeletePost))
+	router.Handler(http.MethodPost, "/collection/add", protected.ThenFunc(app.collectionAddPost))
+	router.Handler(http.MethodPost, "/collection/remove/:id", protected.ThenFunc(app.collectionRemovePost))
+	router.Handler(http.MethodPost, "/search/save", protected.ThenFunc(app.searchSavePost))
+	router.Handler(http.MethodGet, "/searches", protected.ThenFunc(app.searchList))
+	router.Handler(http.MethodPost, "/search/notify/:id", protected.ThenFunc(app.searchNotifyPost))
+	router.Handler(http.MethodPost, "/search/delete/:id", protected.ThenFunc(app.searchDeletePost))
+	router.Handler(http.MethodGet, "/orgs", protected.ThenFunc(app.orgList))
+	router.Handler(http.MethodGet, "/org/create", protected.ThenFunc(app.orgCreate))
+	router.Handler(http.MethodPost, "/org/create", protected.ThenFunc(app.orgCreatePost))
+	router.Handler(http.MethodGet, "/org/view/:slug", protected.ThenFunc(app.orgView))
+	router.Handler(http.MethodPost, "/org/invite/:slug", protected.ThenFunc(app.orgInvitePost))
+	router.Handler(http.MethodPost, "/org/remove/:slug", protected.ThenFunc(app.orgRemovePost))
+	router.Handler(http.MethodPost, "/org/invitation", protected.ThenFunc(app.orgInvitationPost))
+
+	admin := dynamic.Append(app.requireAdmin)
+
+	router.Handler(http.MethodGet, "/admin", admin.ThenFunc(app.adminDashboard))
+	router.Handler(http.MethodGet, "/admin/moderation", admin.ThenFunc(app.adminModeration))
+	router.Handler(http.MethodPost, "/admin/impersonate", admin.ThenFunc(app.adminImpersonatePost))
+	router.Handler(http.MethodGet, "/admin/impersonation/:id", admin.ThenFunc(app.adminImpersonation))
+	router.Handler(http.MethodPost, "/admin/snippet/approve/:id", admin.ThenFunc(app.adminSnippetApprovePost))
+	router.Handler(http.MethodPost, "/admin/snippet/pin/:id", admin.ThenFunc(app.adminSnippetPinPost))
+	router.Handler(http.MethodPost, "/admin/reload", admin.ThenFunc(app.adminReloadPost))
+	router.Handler(http.MethodGet, "/admin/metrics", admin.Then(expvar.Handler()))
+	router.Handler(http.MethodGet, "/admin/erasures", admin.ThenFunc(app.adminErasures))
+	router.Handler(http.MethodPost, "/admin/erasures", admin.ThenFunc(app.adminErasePost))
+	router.Handler(http.MethodGet, "/admin/filters", admin.ThenFunc(app.adminFilters))
+	router.Handler(http.MethodPost, "/admin/filters", admin.ThenFunc(app.adminFilterPost))
+	router.Handler(http.MethodPost, "/admin/filters/delete/:id", admin.ThenFunc(app.adminFilterDeletePost))
+
+	// Serve the content pages at their name, unless the application already uses the path.
+	slugs := make([]string, 0, len(app.pages))
+	for slug := range app.pages {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		if handle, _, _ := router.Lookup(http.MethodGet, "/"+slug); handle != nil {
+			app.errorLog.Printf("content page %s.md isn't served: /%s is taken by the application", slug, slug)
+			continue
+		}
+		router.Handler(http.MethodGet, "/"+slug, dynamic.ThenFunc(app.contentPageView))
+	}
 
 	// Wrap the router with the recoverPanic, logRequest, and secureHeaders middleware functions.
 	// This means that every request will go through these middleware functions in the order they are listed.
-	standard := alice.New(
-		app.recoverPanic,
+	standard := alice.New(app.recoverPanic)
+	// Locate the client before the request is logged, so that the log has its country.
+	if app.geoip != nil {
+		standard = standard.Append(app.locate)
+	}
+	standard = standard.Append(
 		app.logRequest,
-		secureHeaders,
+		app.secureHeaders,
 	)
+	// Answer routes that keep failing with an error page for a while, to take the load off the
+	// database during an incident.
+	if app.config.BreakerThreshold > 0 {
+		standard = standard.Append(app.newCircuitBreaker(router).breakRoutes)
+	}
+	if app.config.VersionHeader {
+		standard = standard.Append(versionHeader)
+	}
+	if app.config.MinifyHTML {
+		standard = standard.Append(minifyHTML)
+	}
+	if app.config.ServerTiming == serverTimingAdmin || app.config.ServerTiming == serverTimingOn {
+		standard = standard.Append(app.serverTiming)
+	}
 
 	// Return the router.
 	return standard.Then(router)
@@ -0,0 +1,385 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"        // Package for creating error messages.
+	"html"          // Package for escaping the excerpts of search results.
+	"html/template" // Package for marking the escaped excerpts as safe.
+	"net/http"      // Package for building HTTP servers and clients.
+	"strconv"       // Package for converting strings to numeric types.
+	"strings"       // Package for manipulating strings.
+	"unicode"       // Package for classifying the characters of excerpts.
+	"unicode/utf8"  // Package for counting the characters of the query.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/mailer"    // Import the email package.
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+	"snippetbox.adcon.dev/ui"                 // Import the embedded templates.
+)
+
+// searchLimit is the number of results shown on the search page.
+const searchLimit = 50
+
+// savedSearchNotifyLimit is the number of new matches listed in a notification email.
+const savedSearchNotifyLimit = 10
+
+// savedSearchForm represents the form for saving the search shown on the search page.
+type savedSearchForm struct {
+	Name                string `form:"name" validate:"required,maxrunes=100"`
+	Query               string `form:"q" validate:"maxrunes=255"`
+	Language            string `form:"language" validate:"maxrunes=32"`
+	Notify              bool   `form:"notify"`
+	validator.Validator `form:"-"`
+}
+
+// savedSearchEmail is the data of the email about new matches of a saved search.
+type savedSearchEmail struct {
+	Name        string       // Name is the name of the recipient.
+	SearchName  string       // SearchName is the name of the saved search.
+	Matches     []digestLink // Matches lists the new matches, newest first.
+	SearchURL   string       // SearchURL runs the search.
+	SettingsURL string       // SettingsURL is the page where notifications can be turned off.
+}
+
+// search serves the "/search" URL. It lists the snippets matching the "q" and "language" query
+// parameters, best matches first, a page at a time, and lets logged-in users save the search.
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	page, ok := pageParam(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	q := models.SearchQuery{
+		Text:     strings.TrimSpace(r.URL.Query().Get("q")),
+		Language: strings.TrimSpace(r.URL.Query().Get("language")),
+	}
+
+	if utf8.RuneCountInString(q.Text) > 255 || utf8.RuneCountInString(q.Language) > 32 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Each "meta" parameter is a "name:value" pair the snippets must have in their metadata.
+	metas := r.URL.Query()["meta"]
+	if len(metas) > models.MaxMetadataFields {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	for _, meta := range metas {
+		if strings.TrimSpace(meta) == "" {
+			continue
+		}
+		f, err := parseMetadataField(meta)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		q.Metadata = append(q.Metadata, f)
+	}
+
+	app.renderSearch(w, r, http.StatusOK, q, page, savedSearchForm{Query: q.Text, Language: q.Language})
+}
+
+// searchSavePost saves the search in the "q" and "language" form fields for the current user under
+// the name in the "name" field, and redirects to their saved searches.
+func (app *application) searchSavePost(w http.ResponseWriter, r *http.Request) {
+	var form savedSearchForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	q := models.SearchQuery{Text: strings.TrimSpace(form.Query), Language: strings.TrimSpace(form.Language)}
+
+	form.CheckStruct(form)
+	if q.IsZero() {
+		form.AddNonFieldError("Enter something to search for before saving the search")
+	}
+
+	if form.Valid() {
+		_, err := app.savedSearches.Insert(app.authenticatedUserID(r), form.Name, q, form.Notify)
+		switch {
+		case err == nil:
+			app.sessionManager.Put(r.Context(), "flash", "Search saved!")
+			http.Redirect(w, r, "/searches", http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrDuplicateSavedSearch):
+			form.AddFieldError("name", "You already have a saved search with this name")
+		default:
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.renderSearch(w, r, http.StatusUnprocessableEntity, q, 1, form)
+}
+
+// searchList serves the "/searches" URL. It lists the saved searches of the current user, with
+// links that run them.
+func (app *application) searchList(w http.ResponseWriter, r *http.Request) {
+	searches, err := app.savedSearches.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SavedSearches = searches
+
+	app.render(w, r, http.StatusOK, "searches.html", data)
+}
+
+// searchNotifyPost turns the notifications of a saved search on or off, according to the "notify"
+// form field.
+func (app *application) searchNotifyPost(w http.ResponseWriter, r *http.Request) {
+	search, ok := app.ownSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	notify := r.PostForm.Get("notify") == "true"
+
+	if err := app.savedSearches.SetNotify(search.ID, notify); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if notify {
+		app.sessionManager.Put(r.Context(), "flash", "You'll be emailed about new matches of "+search.Name+".")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "You won't be emailed about "+search.Name+" anymore.")
+	}
+
+	http.Redirect(w, r, "/searches", http.StatusSeeOther)
+}
+
+// searchDeletePost deletes a saved search.
+func (app *application) searchDeletePost(w http.ResponseWriter, r *http.Request) {
+	search, ok := app.ownSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.savedSearches.Delete(search.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Saved search deleted.")
+	http.Redirect(w, r, "/searches", http.StatusSeeOther)
+}
+
+// notifySavedSearches emails users about the snippets created since their saved searches were last
+// checked. It's run periodically by a background job. Like digests, each search is advanced before
+// the email is sent, so that matches are never reported twice.
+func (app *application) notifySavedSearches() error {
+	searches, err := app.savedSearches.Notifying()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, s := range searches {
+		if err := app.notifySavedSearch(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// notifySavedSearch emails the owner of a saved search about its new matches, if there are any.
+func (app *application) notifySavedSearch(s *models.SavedSearch) error {
+	matches, err := app.snippets.Search(s.Query, s.LastSeenID, savedSearchNotifyLimit)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+
+	advanced, err := app.savedSearches.Advance(s, matches[0].ID)
+	if err != nil || !advanced {
+		return err
+	}
+
+	user, err := app.users.Get(s.UserID)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(app.config.BaseURL, "/")
+
+	data := savedSearchEmail{
+		Name:        user.Name,
+		SearchName:  s.Name,
+		SearchURL:   base + s.URL(),
+		SettingsURL: base + "/searches",
+	}
+
+	for _, m := range matches {
+		data.Matches = append(data.Matches, digestLink{Title: m.Title, URL: base + "/snippet/view/" + m.PublicID()})
+	}
+
+	msg, err := mailer.Render(ui.Files, "email/saved_search.tmpl", user.Email, data)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(msg)
+}
+
+// renderSearch renders the search page with the results of a query and the given form for saving
+// it.
+func (app *application) renderSearch(w http.ResponseWriter, r *http.Request, status int, q models.SearchQuery, page int, form savedSearchForm) {
+	data := app.newTemplateData(r)
+	data.Search = q
+	data.Form = form
+
+	if !q.IsZero() {
+		// One more result than fits on the page tells whether there's a next page.
+		results, err := app.snippets.FullTextSearch(q, (page-1)*searchLimit, searchLimit+1)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		more := len(results) > searchLimit
+		if more {
+			results = results[:searchLimit]
+		}
+		data.SnippetsData = results
+		data.Pagination = newPagination("/search", q.Values(), page, more)
+	}
+
+	app.render(w, r, status, "search.html", data)
+}
+
+// excerptChars is the number of characters of content shown around the first match of a search.
+const excerptChars = 160
+
+// excerpt returns the line of content where the words of query first appear, cut to excerptChars
+// around the first of them and with every word marked, as escaped HTML for the search results. It
+// falls back to the first line of content when no word appears in it, since the title matched.
+func excerpt(content, query string) template.HTML {
+	words := models.SearchQuery{Text: query}.Words()
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	line, at := "", -1
+	for _, l := range lines {
+		if i := firstMatch(strings.ToLower(l), words); i >= 0 {
+			line, at = l, i
+			break
+		}
+		if line == "" {
+			line = l
+		}
+	}
+
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\t' {
+			return -1
+		}
+		return r
+	}, line)
+
+	// Start a little before the first match, so that it's shown with what leads to it.
+	start := 0
+	if at > 0 {
+		start = max(0, utf8.RuneCountInString(line[:min(at, len(line))])-excerptChars/4)
+	}
+	runes := []rune(line)
+	end := min(len(runes), start+excerptChars)
+	cut := strings.TrimSpace(string(runes[start:end]))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("... ")
+	}
+	b.WriteString(markWords(cut, words))
+	if end < len(runes) {
+		b.WriteString(" ...")
+	}
+
+	return template.HTML(b.String())
+}
+
+// firstMatch returns the index of the first of words in the lowercase text, or -1 if there's none.
+func firstMatch(text string, words []string) int {
+	first := -1
+	for _, word := range words {
+		if i := strings.Index(text, word); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
+
+// markWords escapes text as HTML, wrapping the words in it in <mark> elements. Text whose length
+// changes when lowercased can't be matched by position, so it's only escaped.
+func markWords(text string, words []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return html.EscapeString(text)
+	}
+
+	var b strings.Builder
+	for len(text) > 0 {
+		at := firstMatch(lower, words)
+		if at < 0 {
+			break
+		}
+
+		// Of the words starting at the match, mark the longest.
+		n := 0
+		for _, word := range words {
+			if strings.HasPrefix(lower[at:], word) {
+				n = max(n, len(word))
+			}
+		}
+
+		b.WriteString(html.EscapeString(text[:at]))
+		b.WriteString("<mark>" + html.EscapeString(text[at:at+n]) + "</mark>")
+		text, lower = text[at+n:], lower[at+n:]
+	}
+	b.WriteString(html.EscapeString(text))
+
+	return b.String()
+}
+
+// ownSavedSearch fetches the saved search identified by the "id" URL parameter. If it doesn't exist
+// or belongs to another user, it sends a 404 response and returns false.
+func (app *application) ownSavedSearch(w http.ResponseWriter, r *http.Request) (*models.SavedSearch, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	search, err := app.savedSearches.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if search.UserID != app.authenticatedUserID(r) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return search, true
+}
@@ -0,0 +1,112 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"  // Package for passing the timing of a request to the handlers.
+	"net/http" // Package for building HTTP servers and clients.
+	"time"     // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/servertiming" // Import the request timing package.
+)
+
+// The values of the -server-timing flag, which sets who gets the Server-Timing header.
+const (
+	serverTimingOff   = "off"   // Requests aren't timed.
+	serverTimingAdmin = "admin" // Requests are timed and logged; only admins get the header.
+	serverTimingOn    = "on"    // Requests are timed and logged, and every response gets the header.
+)
+
+// validServerTiming reports whether mode is a value of the -server-timing flag.
+func validServerTiming(mode string) bool {
+	return mode == serverTimingOff || mode == serverTimingAdmin || mode == serverTimingOn
+}
+
+// requestTiming is the timing of a request, which the handlers find in its context.
+type requestTiming struct {
+	timings *servertiming.Timings
+	start   time.Time
+	show    bool // show reports whether the response gets the Server-Timing header.
+}
+
+// serverTiming is a middleware function that measures the database and template rendering time of
+// each request, and the time until its response is written. The measurements are logged, and sent
+// in the Server-Timing header to the clients the -server-timing flag allows, so that they show up
+// in the browser's developer tools.
+func (app *application) serverTiming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt := &requestTiming{
+			timings: servertiming.New(),
+			start:   time.Now(),
+			show:    app.config.ServerTiming == serverTimingOn,
+		}
+		stop := servertiming.Track(rt.timings)
+		defer stop()
+
+		r = r.WithContext(context.WithValue(r.Context(), serverTimingContextKey, rt))
+		tw := &timingWriter{ResponseWriter: w, timing: rt, status: http.StatusOK}
+
+		next.ServeHTTP(tw, r)
+
+		var db, render servertiming.Metric
+		for _, m := range rt.timings.Metrics() {
+			switch m.Name {
+			case "db":
+				db = m
+			case "render":
+				render = m
+			}
+		}
+		app.infoLog.Printf("timing method=%s path=%s status=%d total=%s db=%s queries=%d render=%s",
+			r.Method, r.URL.Path, tw.status, time.Since(rt.start).Round(time.Microsecond),
+			db.Duration.Round(time.Microsecond), db.Count, render.Duration.Round(time.Microsecond))
+	})
+}
+
+// showServerTiming sends the Server-Timing header to the client of the request if it's an admin
+// and the -server-timing flag lets admins see it.
+func (app *application) showServerTiming(r *http.Request) {
+	rt, ok := r.Context().Value(serverTimingContextKey).(*requestTiming)
+	if ok && app.config.ServerTiming == serverTimingAdmin {
+		rt.show = true
+	}
+}
+
+// timingWriter adds the Server-Timing header to the response, with the time taken until the
+// handler wrote its status.
+type timingWriter struct {
+	http.ResponseWriter
+	timing      *requestTiming
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader adds the Server-Timing header and writes the status.
+func (tw *timingWriter) WriteHeader(status int) {
+	// Informational responses don't end the response, so the header waits for the final status.
+	if !tw.wroteHeader && status >= http.StatusOK {
+		tw.wroteHeader = true
+		tw.status = status
+
+		if tw.timing.show {
+			tw.timing.timings.Add("total", time.Since(tw.timing.start))
+			tw.Header().Set("Server-Timing", tw.timing.timings.Header())
+		}
+	}
+
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the status of the response, if the handler didn't, and then p.
+func (tw *timingWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+
+	return tw.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController.
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
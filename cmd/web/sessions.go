@@ -0,0 +1,65 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"database/sql" // Package for the database the MySQL store keeps sessions in.
+	"errors"       // Package for creating error messages.
+	"net/http"     // Package for building HTTP servers and clients.
+	"time"         // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/cookiestore" // Import the cookie session store.
+
+	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/v2"
+)
+
+// The session stores, set with the -session-store flag.
+const (
+	sessionStoreMySQL  = "mysql"  // Sessions are kept in the sessions table.
+	sessionStoreCookie = "cookie" // Sessions are kept, encrypted, in their cookie.
+)
+
+// validSessionStore reports whether name is a known session store.
+func validSessionStore(name string) bool {
+	return name == sessionStoreMySQL || name == sessionStoreCookie
+}
+
+// newSessionManager returns the session manager for the configured store. With the cookie store it
+// also returns the store, whose middleware loads and saves sessions instead of the manager's.
+func newSessionManager(config configuration, db *sql.DB) (*scs.SessionManager, *cookiestore.Store, error) {
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	switch config.SessionStore {
+	case sessionStoreMySQL:
+		sessionManager.Store = mysqlstore.New(db)
+		return sessionManager, nil, nil
+
+	case sessionStoreCookie:
+		keyring, err := loadKeyring(config.SessionKeys, config.SessionKeysFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if keyring == nil {
+			return nil, nil, errors.New("-session-store cookie needs -session-keys or -session-keys-file")
+		}
+
+		store := &cookiestore.Store{Keys: keyring}
+		sessionManager.Store = store
+		return sessionManager, store, nil
+
+	default:
+		return nil, nil, errors.New("-session-store must be mysql or cookie")
+	}
+}
+
+// loadAndSave loads the session of a request and saves it once the handler is done, with the
+// middleware of the session store in use.
+func (app *application) loadAndSave(next http.Handler) http.Handler {
+	if app.cookieSessions != nil {
+		return app.cookieSessions.LoadAndSave(app.sessionManager)(next)
+	}
+	return app.sessionManager.LoadAndSave(next)
+}
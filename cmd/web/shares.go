@@ -0,0 +1,207 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"net/url"  // Package for building the share link URLs.
+	"strconv"  // Package for converting strings to numeric types.
+	"time"     // Package for measuring and displaying time.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// shareLink is a share link as shown to the owner of a snippet.
+type shareLink struct {
+	ID      int       // ID identifies the link for revoking it.
+	URL     string    // URL is the full link, including its token.
+	Expires time.Time // Expires is when the link stops working.
+}
+
+// shareDurations are the lifetimes, in hours, a share link can be created with.
+var shareDurations = map[int]bool{1: true, 24: true, 168: true, 720: true}
+
+// snippetShared serves the "/s/:slug" URL, where the slug is the public ID of a snippet or its
+// custom slug. It shows a private snippet to its owner, to admins and to anyone presenting a valid
+// share token in the "token" query parameter, without requiring them to log in. The page isn't
+// stored by caches when it has a token, see snippetCacheControl.
+func (app *application) snippetShared(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.snippetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Snippets held for moderation can't be shared; public snippets don't need to be.
+	if snippet.Held {
+		app.notFound(w)
+		return
+	}
+
+	if snippet.Private && !app.canView(r, snippet) {
+		_, err = app.shares.Verify(snippet.ID, r.URL.Query().Get("token"))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
+	// The page is the snippet's usual one, with its highlighting, line anchors and statistics.
+	app.showSnippet(w, r, snippet)
+}
+
+// snippetPrivatePost makes a snippet private or lists it again, from the "private" form field.
+// Only the owner can change it.
+func (app *application) snippetPrivatePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	private, err := strconv.ParseBool(r.PostForm.Get("private"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := app.snippets.SetPrivate(snippet.ID, private); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if private {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet is now private.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet is now listed.")
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// snippetSharePost creates a share link for a private snippet, lasting the number of hours in the
+// "hours" form field.
+func (app *application) snippetSharePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	hours, err := strconv.Atoi(r.PostForm.Get("hours"))
+	if err != nil || !shareDurations[hours] || !snippet.Private {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	expires := app.clock.Now().Add(time.Duration(hours) * time.Hour)
+
+	// A link never outlives the snippet, unless the snippet never expires.
+	if !snippet.Permanent() && expires.After(snippet.Expires) {
+		expires = snippet.Expires
+	}
+
+	if _, err := app.shares.Insert(snippet.ID, app.authenticatedUserID(r), expires); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Share link created!")
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// snippetUnsharePost revokes the share link named by the "share_id" form field.
+func (app *application) snippetUnsharePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("share_id"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.shares.Delete(snippet.ID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Share link revoked.")
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// shareLinks returns the unexpired share links of a snippet with their full URLs.
+func (app *application) shareLinks(r *http.Request, snippet *models.Snippet) ([]shareLink, error) {
+	shares, err := app.shares.BySnippet(snippet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []shareLink{}
+	for _, s := range shares {
+		u := url.URL{
+			Scheme:   "https",
+			Host:     r.Host,
+			Path:     "/s/" + snippet.PublicID(),
+			RawQuery: url.Values{"token": {app.shares.Token(s)}}.Encode(),
+		}
+		links = append(links, shareLink{ID: s.ID, URL: u.String(), Expires: s.Expires})
+	}
+
+	return links, nil
+}
+
+// ownSnippet fetches the snippet identified by the "id" URL parameter for a handler that changes
+// it. If the snippet doesn't exist or belongs to someone else, it sends a 404 response and returns
+// false.
+func (app *application) ownSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.snippetFromParams(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if snippet.OwnerID == 0 || snippet.OwnerID != app.authenticatedUserID(r) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
@@ -0,0 +1,143 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"encoding/json" // Package for decoding the API request.
+	"errors"        // Package for creating error messages.
+	"net/http"      // Package for building HTTP servers and clients.
+	"net/url"       // Package for building the short link URLs.
+
+	"github.com/julienschmidt/httprouter" // Import advanced routing and validation package
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// shortLinkResponse is the JSON representation of a short link.
+type shortLinkResponse struct {
+	Code    string `json:"code"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"` // Snippet is the public identifier of the snippet.
+	Clicks  int    `json:"clicks"`
+}
+
+// shortLinkRedirect serves the "/x/:code" URL. It redirects to the snippet of a short link and
+// counts the click. Links to snippets the visitor can't see answer 404, so that they don't give
+// away the snippet's public ID, and aren't counted.
+func (app *application) shortLinkRedirect(w http.ResponseWriter, r *http.Request) {
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+
+	if !models.IsShortCode(code) {
+		app.notFound(w)
+		return
+	}
+
+	link, err := app.shortLinks.Get(code)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// The snippet may have expired, been made private or been held since the link was created.
+	snippet, err := app.snippets.Get(link.SnippetID)
+	if err != nil || !app.canView(r, snippet) {
+		if err == nil || errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Clicks aren't counted in read-only mode, but the link still works.
+	if !app.config.ReadOnly {
+		if err := app.shortLinks.Click(code); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusFound)
+}
+
+// snippetShortenPost serves the "get a short link" button of the snippet page.
+func (app *application) snippetShortenPost(w http.ResponseWriter, r *http.Request) {
+	snippet, err := app.snippetFromParams(r)
+	if err != nil || !app.canView(r, snippet) {
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+		} else {
+			app.notFound(w)
+		}
+		return
+	}
+
+	link, _, err := app.shortLinks.Create(snippet.ID, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Short link: "+shortLinkURL(r, link))
+	http.Redirect(w, r, "/snippet/view/"+snippet.PublicID(), http.StatusSeeOther)
+}
+
+// apiShortLinkCreate serves POST requests to "/api/v1/shortlinks". The JSON body names a snippet by
+// its ULID or integer ID, as in {"snippet": "01HV6Z9K1QX8M3N5P7R9T2V4W6"}. It responds with the
+// snippet's short link: 201 if it was created, 200 if the snippet already had one. Errors are
+// reported as problem details, like the rest of the API.
+func (app *application) apiShortLinkCreate(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+	if userID == 0 {
+		app.writeProblem(w, r, http.StatusUnauthorized, "Log in to create short links.", r.URL.Path)
+		return
+	}
+
+	var input struct {
+		Snippet string `json:"snippet"`
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Snippet == "" {
+		app.writeProblem(w, r, http.StatusBadRequest, `The body must be a JSON object with a "snippet" identifier.`, r.URL.Path)
+		return
+	}
+
+	snippet, err := app.snippetByPublicID(input.Snippet)
+	if err != nil || !app.canView(r, snippet) {
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+		} else {
+			app.writeProblem(w, r, http.StatusNotFound, "There's no snippet with this ID.", r.URL.Path)
+		}
+		return
+	}
+
+	link, created, err := app.shortLinks.Create(snippet.ID, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	app.writeJSON(w, r, status, shortLinkResponse{
+		Code:    link.Code,
+		URL:     shortLinkURL(r, link),
+		Snippet: snippet.PublicID(),
+		Clicks:  link.Clicks,
+	})
+}
+
+// shortLinkURL returns the full URL of a short link on the host of the request.
+func shortLinkURL(r *http.Request, link *models.ShortLink) string {
+	u := url.URL{Scheme: "https", Host: r.Host, Path: "/x/" + link.Code}
+	return u.String()
+}
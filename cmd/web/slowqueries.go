@@ -0,0 +1,25 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"expvar" // Package for exposing counters to monitoring.
+	"log"    // Package for logging.
+	"time"   // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/slowquery" // Import the slow query logging package.
+)
+
+// slowQueries counts the database statements that took longer than the -slow-query threshold, by
+// the function that ran them. It's published with the other expvar counters on the admin metrics
+// page.
+var slowQueries = expvar.NewMap("slow_queries")
+
+// slowQueryReporter returns the function slow statements are reported to: each one is counted and
+// logged with the function that ran it, the statement and a summary of its parameters.
+func slowQueryReporter(infoLog *log.Logger) func(slowquery.Query) {
+	return func(q slowquery.Query) {
+		slowQueries.Add(q.Name, 1)
+		infoLog.Printf("Slow query in %s took %s: %s [%s]", q.Name, q.Duration.Round(time.Millisecond), q.SQL, q.Args)
+	}
+}
@@ -0,0 +1,70 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"expvar"   // Package for exposing counters to monitoring.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"time"     // Package for measuring and displaying time.
+)
+
+// The fields the "honeypot" template adds to a form. The honeypot field is hidden from people with
+// CSS, so only bots filling in every input set it; the start field records when the form was
+// rendered, as Unix seconds.
+const (
+	honeypotField    = "website"
+	formStartedField = "form_started"
+)
+
+// spamSubmissions counts the form submissions discarded as spam, by reason. It's published with
+// the other expvar counters on the admin metrics page.
+var spamSubmissions = expvar.NewMap("spam_submissions")
+
+// discardBots is a middleware function for POST routes of forms that include the "honeypot"
+// template. Submissions that fill in the honeypot field, or that come back sooner than the
+// configured minimum fill time, are counted and answered with a redirect to target as if they had
+// succeeded, without reaching the handler, so that bots get no hint that they were caught.
+func (app *application) discardBots(target string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The handler can't limit the body any more once it's parsed, so limit it like
+			// decodePostForm does.
+			r.Body = http.MaxBytesReader(w, r.Body, app.maxSnippetBody())
+			if err := r.ParseForm(); err != nil {
+				app.formError(w, err)
+				return
+			}
+
+			if reason := app.botReason(r); reason != "" {
+				spamSubmissions.Add(reason, 1)
+				app.infoLog.Printf("Discarded %s submission to %s from %s: %s", r.Method, r.URL.Path, app.logAddr(r), reason)
+				http.Redirect(w, r, target, http.StatusSeeOther)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// botReason returns why a parsed form submission looks automated, or "" if it doesn't.
+func (app *application) botReason(r *http.Request) string {
+	if r.PostForm.Get(honeypotField) != "" {
+		return "honeypot"
+	}
+
+	if app.config.FormMinFillTime <= 0 {
+		return ""
+	}
+
+	started, err := strconv.ParseInt(r.PostForm.Get(formStartedField), 10, 64)
+	if err != nil {
+		return "missing start time"
+	}
+	if app.clock.Now().Sub(time.Unix(started, 0)) < app.config.FormMinFillTime {
+		return "too fast"
+	}
+
+	return ""
+}
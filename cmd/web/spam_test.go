@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestDiscardBots(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	app := newTestApplication(t)
+	app.clock = clock.NewFrozen(now)
+	app.config.FormMinFillTime = 3 * time.Second
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	started := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(-d).Unix(), 10)
+	}
+
+	tests := []struct {
+		name         string
+		honeypot     string
+		started      string
+		wantLocation string
+	}{
+		{
+			name:         "Honeypot filled in",
+			honeypot:     "http://spam.example.com",
+			started:      started(time.Minute),
+			wantLocation: "/",
+		},
+		{
+			name:         "Too fast",
+			started:      started(time.Second),
+			wantLocation: "/",
+		},
+		{
+			name:         "Missing start time",
+			wantLocation: "/",
+		},
+		{
+			name:         "Person",
+			started:      started(time.Minute),
+			wantLocation: "/snippet/view/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Over the wintry forest")
+			form.Add("content", "Over the wintry forest, winds howl in rage")
+			form.Add("expires", "7")
+			form.Add(honeypotField, tt.honeypot)
+			if tt.started != "" {
+				form.Add(formStartedField, tt.started)
+			}
+
+			code, header, _ := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, http.StatusSeeOther)
+			if tt.wantLocation == "/" {
+				assert.Equal(t, header.Get("Location"), tt.wantLocation)
+			} else {
+				// New snippets are shown at their public ID.
+				created, err := app.snippets.Get(2)
+				assert.NilError(t, err)
+				assert.Equal(t, header.Get("Location"), tt.wantLocation+created.PublicID())
+			}
+		})
+	}
+
+	t.Run("Form includes the fields", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/create")
+
+		assert.StringContains(t, body, "name='"+honeypotField+"'")
+		assert.StringContains(t, body, "name='"+formStartedField+"' value='"+started(0)+"'")
+	})
+}
@@ -3,29 +3,124 @@ package main
 
 // Import the necessary packages.
 import (
-	"io/fs"
+	"html/template" // Package for HTML templates, which escape what they show by its context.
+	"io/fs"         // Package for reading the templates.
+	"net/http"      // Package for the names of HTTP statuses.
 	"path/filepath" // Package for manipulating file paths.
-	"text/template" // Package for manipulating text templates.
 	"time"          // Package for measuring and displaying time.
 
-	"snippetbox.adcon.dev/internal/models" // Import the models package.
-	"snippetbox.adcon.dev/ui"
+	"snippetbox.adcon.dev/internal/captcha"    // Import the human verification package.
+	"snippetbox.adcon.dev/internal/langdetect" // Import the language detection package.
+	"snippetbox.adcon.dev/internal/models"     // Import the models package.
 )
 
 // templateData holds data to be passed into templates. It is used to provide a consistent
 // structure for passing data to templates, making it easier to manage and evolve over time.
 type templateData struct {
-	CurrentYear     int               // CurrentYear holds the current year.
-	SnippetData     *models.Snippet   // SnippetData holds data for a single snippet.
-	SnippetsData    []*models.Snippet // SnippetsData holds data for multiple snippets.
-	Form            any               // Form holds form data.
-	Flash           string
-	IsAuthenticated bool
+	CurrentYear      int               // CurrentYear holds the current year.
+	SnippetData      *models.Snippet   // SnippetData holds data for a single snippet.
+	SnippetsData     []*models.Snippet // SnippetsData holds data for multiple snippets.
+	Form             any               // Form holds form data.
+	Flash            string
+	IsAuthenticated  bool
+	IsAdmin          bool
+	ViewStats        *models.ViewStats    // ViewStats holds view statistics for the owner or admin panels.
+	User             *models.User         // User holds the user shown on a profile page.
+	FormStarted      int64                // FormStarted is when the page was rendered, for the minimum fill time of forms.
+	ReadOnly         bool                 // ReadOnly reports whether the site is in read-only mode.
+	AnonymousPosting bool                 // AnonymousPosting reports whether visitors can create snippets without logging in.
+	CSPNonce         string               // CSPNonce is the nonce inline scripts and styles need to run under the Content-Security-Policy.
+	Captcha          *captcha.Widget      // Captcha is the human verification challenge to show on a form, if any.
+	Tab              string               // Tab is the selected listing of the home page, "latest" or "trending".
+	Collection       *models.Collection   // Collection holds the collection shown on a collection page.
+	Collections      []*models.Collection // Collections holds the current user's collections.
+	IsOwner          bool                 // IsOwner reports whether the current user owns the page's collection or snippet.
+	Shares           []shareLink          // Shares holds the share links of a private snippet, for its owner.
+	ShortLink        string               // ShortLink is the URL of the snippet's short link, if it has one.
+	ShortLinkClicks  int                  // ShortLinkClicks is the number of times ShortLink was followed.
+	Webmentions      []*models.Webmention // Webmentions holds the verified mentions of a public snippet by other sites.
+	OEmbed           string               // OEmbed is the oEmbed URL describing a public snippet, without the format.
+
+	Accesses            []*models.Access // Accesses holds the access history of a snippet, for its owner.
+	AccessLog           string           // AccessLog is what the access log records (off, basic or full).
+	AccessRetentionDays int              // AccessRetentionDays is how many days access log entries are kept.
+
+	CurrentUserID   int                    // CurrentUserID is the ID of the logged-in user, or 0.
+	Organization    *models.Organization   // Organization holds the organization shown on its dashboard.
+	Organizations   []*models.Organization // Organizations holds the organizations of the current user.
+	OrgRole         string                 // OrgRole is the current user's role in Organization.
+	Members         []*models.Member       // Members holds the members of Organization.
+	Invitations     []*models.Invitation   // Invitations holds the pending invitations of Organization, for its owners.
+	Invitation      *models.Invitation     // Invitation holds the invitation shown on the invitation page.
+	InvitationToken string                 // InvitationToken is the token of Invitation, for accepting it.
+
+	Stars   int  // Stars is the number of users who starred the snippet.
+	Starred bool // Starred reports whether the current user starred the snippet.
+
+	CanEdit     bool            // CanEdit reports whether the current user may edit the snippet.
+	Permissions []permissionRow // Permissions holds the permissions table of an organization's snippet, for those who manage it.
+
+	Metadata []models.MetadataField // Metadata holds the name/value pairs the author described the snippet with.
+
+	Search        models.SearchQuery    // Search is the query of the search page.
+	SavedSearches []*models.SavedSearch // SavedSearches holds the saved searches of the current user.
+
+	Draft  *models.Draft // Draft is the draft the snippet form was restored from, if any.
+	Drafts bool          // Drafts reports whether the snippet forms are saved as drafts, see draftsEnabled.
+
+	EditLock *models.EditLock // EditLock is the lock of another user editing the snippet, if any.
+
+	Duplicate      *models.Snippet // Duplicate is a published snippet with the same content as the one being created.
+	DuplicateExact bool            // DuplicateExact reports whether Duplicate is an exact copy rather than a near one.
+
+	DataExport   *models.DataExport // DataExport is the latest data export of the current user, if any.
+	ImportReport *importReport      // ImportReport lists what an import of a data export did, after one.
+
+	Erasures     []*models.Erasure // Erasures holds the erasures listed on the admin erasures page.
+	ErasureEmail string            // ErasureEmail is the address Erasures were looked up by, or empty for the recent erasures.
+
+	FilterRules     []*models.FilterRule // FilterRules holds the content filter rules admins manage.
+	FilterFileRules []string             // FilterFileRules lists the rules of the blocklist file.
+	FilterHits      []*models.FilterHit  // FilterHits holds the recent hits of the content filter.
+
+	APITokens   []*models.APIToken // APITokens holds the API tokens of the current user.
+	NewAPIToken string             // NewAPIToken is a token that was just created, shown only this once.
+
+	Suppression *models.Suppression // Suppression is the delivery state of the current user's address, if it bounced.
+
+	Impersonating        string                        // Impersonating is the username of the user an admin is impersonating, if any.
+	Impersonations       []*models.Impersonation       // Impersonations holds the recent impersonations listed on the admin dashboard.
+	Impersonation        *models.Impersonation         // Impersonation is the impersonation whose audit trail is shown.
+	ImpersonationActions []*models.ImpersonationAction // ImpersonationActions holds the requests made during the impersonation.
+
+	ErrorStatus int    // ErrorStatus is the HTTP status shown on the error page.
+	ErrorDetail string // ErrorDetail explains the error on the error page.
+	IncidentID  string // IncidentID identifies a server error in the log, see serverError.
+
+	Page *contentPage // Page is the content page being shown.
+
+	Pagination *pagination // Pagination links the pages of a paginated listing.
+
+	Diff *revisionDiff // Diff is the comparison of two revisions shown on a snippet's diff page.
+
+	Lines     *lineRange // Lines is the range of lines selected with the "lines" query parameter, marked on the snippet page.
+	Permalink string     // Permalink is the path of the snippet page that links to selected lines start with.
+	LineLink  string     // LineLink is the link to Lines, for the "copy link to selection" button.
 }
 
 // functions is a map that acts as a lookup for functions that can be used in templates.
 var functions = template.FuncMap{
-	"humanDate": humanDate, // Map the "humanDate" key to the humanDate function.
+	"humanDate":  humanDate,            // Map the "humanDate" key to the humanDate function.
+	"statusText": http.StatusText,      // Map the "statusText" key to the name of an HTTP status.
+	"licenses":   licenses,             // Map the "licenses" key to the well-known licenses of snippets.
+	"languages":  langdetect.Languages, // Map the "languages" key to the languages snippets can be tagged with.
+	"highlight":  highlightSnippet,     // Map the "highlight" key to the highlighted HTML of snippet content.
+	"excerpt":    excerpt,              // Map the "excerpt" key to the part of snippet content matching a search.
+}
+
+// licenses returns the well-known licenses offered in the snippet forms.
+func licenses() []models.License {
+	return models.Licenses
 }
 
 // humanDate formats a time.Time object to a human-friendly date format.
@@ -42,12 +137,15 @@ func humanDate(t time.Time) string {
 // The cache is a map where the keys are page names (like 'home.page.html') and the values are the corresponding templates.
 // This function is useful for preloading all the templates into the cache on application startup.
 // This means that the templates do not need to be loaded from the disk every time a request is made, which improves the performance of the application.
-func newTemplateCache() (map[string]*template.Template, error) {
+// The templates link the static assets through the "asset" function, which looks them up in assets,
+// and add their Subresource Integrity hash through the "integrity" function. They're read from
+// fsys, which is the embedded ui.Files unless -ui-dir is set.
+func newTemplateCache(fsys fs.FS, assets *assetManifest) (map[string]*template.Template, error) {
 	// Create a new template cache.
 	cache := map[string]*template.Template{}
 
 	// Get a slice of all filepaths with the .html extension in the ui/html/pages folder.
-	pages, err := fs.Glob(ui.Files, "html/pages/*.html")
+	pages, err := fs.Glob(fsys, "html/pages/*.html")
 	// If there's an error, return the cache and the error.
 	if err != nil {
 		return nil, err
@@ -65,7 +163,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		}
 
 		// Create a new template set.
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		ts, err := template.New(name).Funcs(functions).Funcs(template.FuncMap{"asset": assets.url, "integrity": assets.integrityAttr}).ParseFS(fsys, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -1,12 +1,62 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"snippetbox.adcon.dev/internal/assert"
 )
 
+func TestExcerpt(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    string
+	}{
+		{
+			name:    "Matching line",
+			content: "package main\n\nfunc retry() {}",
+			query:   "RETRY",
+			want:    "func <mark>retry</mark>() {}",
+		},
+		{
+			name:    "Prefix",
+			content: "backoff := 2 * time.Second",
+			query:   "back sec",
+			want:    "<mark>back</mark>off := 2 * time.<mark>Sec</mark>ond",
+		},
+		{
+			name:    "Escaped",
+			content: "<script>alert(1)</script>",
+			query:   "alert",
+			want:    "&lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;",
+		},
+		{
+			name:    "Title match",
+			content: "\nfirst line\nsecond line",
+			query:   "missing",
+			want:    "first line",
+		},
+		{
+			name:    "Long line",
+			content: strings.Repeat("a ", 100) + "needle" + strings.Repeat(" b", 100),
+			query:   "needle",
+			want:    "... " + strings.Repeat("a ", 20) + "<mark>needle</mark>" + strings.Repeat(" b", 57) + " ...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(excerpt(tt.content, tt.query)), tt.want)
+		})
+	}
+}
+
 func TestHumanDate(t *testing.T) {
 
 	t.Parallel()
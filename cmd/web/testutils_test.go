@@ -5,24 +5,59 @@ import (
 	"html"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/filter"
+	"snippetbox.adcon.dev/internal/mailer"
 	"snippetbox.adcon.dev/internal/models/mocks"
+	"snippetbox.adcon.dev/ui"
 )
 
 var pattern = regexp.MustCompile(`<form action='/user/signup' method='POST' novalidate>`)
 
-func newTestApplication(t *testing.T) *application {
+// shareLinkPattern captures the tokens of the share links listed on a snippet page.
+var shareLinkPattern = regexp.MustCompile(`/s/[0-9A-Z]+\?token=([A-Za-z0-9_-]+)`)
 
-	templateCache, err := newTemplateCache()
+// testMailer collects the email sent through it on a channel, so that tests can wait for email
+// sent in the background.
+type testMailer struct {
+	sent chan mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+// receive returns the next email sent, failing the test if none is sent within a second.
+func (m *testMailer) receive(t *testing.T) mailer.Message {
+
+	select {
+	case msg := <-m.sent:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no email was sent")
+		return mailer.Message{}
+	}
+}
+
+// newTestApplication returns an application backed by the in-memory models from the mocks
+// package, with logging discarded. Each call gets its own models, so tests can write data without
+// affecting each other.
+func newTestApplication(t testing.TB) *application {
+
+	uiCache, err := newUICache(ui.Files)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -33,12 +68,47 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	// Permissions on organizations' snippets depend on the roles of their members.
+	organizations := mocks.NewOrganizationModel()
+	snippets := mocks.NewSnippetModel()
+	snippets.Organizations = organizations
+	savedSearches := mocks.NewSavedSearchModel()
+	savedSearches.Snippets = snippets
+	filters := mocks.NewFilterModel()
+	filters.Snippets = snippets
+	users := mocks.NewUserModel()
+
 	return &application{
 		errorLog:       log.New(io.Discard, "", 0),
 		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
-		templateCache:  templateCache,
+		config:         configuration{MaxTitleBytes: defaultMaxTitleBytes, MaxContentBytes: defaultMaxContentBytes, CSP: contentSecurityPolicy, ReferrerPolicy: defaultReferrerPolicy, FrameOptions: defaultFrameOptions},
+		snippets:       snippets,
+		users:          users,
+		views:          mocks.NewViewModel(),
+		collections:    mocks.NewCollectionModel(),
+		favorites:      mocks.NewFavoriteModel(),
+		shares:         mocks.NewShareModel(),
+		accesses:       mocks.NewAccessModel(),
+		shortLinks:     mocks.NewShortLinkModel(),
+		organizations:  organizations,
+		digests:        mocks.NewDigestModel(),
+		reminders:      mocks.NewReminderModel(snippets),
+		preferences:    mocks.NewPreferenceModel(),
+		dataExports:    mocks.NewDataExportModel(snippets),
+		erasures:       mocks.NewErasureModel(users),
+		filters:        filters,
+		webmentions:    mocks.NewWebmentionModel(),
+		savedSearches:  savedSearches,
+		drafts:         mocks.NewDraftModel(),
+		locks:          mocks.NewEditLockModel(users),
+		impersonations: mocks.NewImpersonationModel(),
+		suppressions:   mocks.NewSuppressionModel(),
+		tokens:         mocks.NewTokenModel(),
+		mailer:         &testMailer{sent: make(chan mailer.Message, 10)},
+		contentFilter:  &filter.Blocklist{},
+		httpClient:     http.DefaultClient,
+		clock:          clock.System{},
+		uiCache:        uiCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 	}
@@ -49,8 +119,32 @@ type testServer struct {
 }
 
 func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	return ts.request(t, http.MethodGet, urlPath, nil)
+}
+
+// follow gets urlPath like get, and follows a redirect to another page, such as the one from the
+// ID-based URL of a snippet to its vanity URL.
+func (ts *testServer) follow(t *testing.T, urlPath string) (int, http.Header, string) {
+	code, header, body := ts.get(t, urlPath)
+	if code != http.StatusMovedPermanently && code != http.StatusFound {
+		return code, header, body
+	}
+
+	return ts.get(t, header.Get("Location"))
+}
+
+// request sends a request without a body, with the given method and request headers.
+func (ts *testServer) request(t *testing.T, method, urlPath string, header http.Header) (int, http.Header, string) {
 
-	rs, err := ts.Client().Get(ts.URL + urlPath)
+	req, err := http.NewRequest(method, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rs, err := ts.Client().Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -82,6 +176,84 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	return rs.StatusCode, rs.Header, string(body)
 }
 
+func (ts *testServer) postJSON(t *testing.T, urlPath string, body string) (int, http.Header, string) {
+	return ts.sendJSON(t, http.MethodPost, urlPath, nil, body)
+}
+
+// sendJSON sends body as JSON with the given method and request headers.
+func (ts *testServer) sendJSON(t *testing.T, method, urlPath string, header http.Header, body string) (int, http.Header, string) {
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	data, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(data))
+}
+
+// postFile sends a multipart form with the given fields and a file in the "archive" field.
+func (ts *testServer) postFile(t *testing.T, urlPath string, fields url.Values, file string) (int, http.Header, string) {
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, values := range fields {
+		for _, value := range values {
+			mw.WriteField(name, value)
+		}
+	}
+	fw, err := mw.CreateFormFile("archive", "snippetbox-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, file)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+urlPath, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	data, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(data))
+}
+
+// login signs in through the login form. The session cookie is kept in the client's cookie jar, so
+// the following requests on the test server are authenticated.
+func (ts *testServer) login(t *testing.T, email, password string) {
+
+	code, _, _ := ts.postForm(t, "/user/login", url.Values{
+		"email":    {email},
+		"password": {password},
+	})
+	if code != http.StatusSeeOther {
+		t.Fatalf("logging in as %s: got status %d", email, code)
+	}
+}
+
+// newTestServer starts a TLS test server for h whose client keeps cookies and doesn't follow
+// redirects. The server uses the certificate built into httptest, so no certificate files are needed.
 func newTestServer(t *testing.T, h http.Handler) *testServer {
 
 	ts := httptest.NewTLSServer(h)
@@ -0,0 +1,144 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"  // Package for passing the token of a request to the handlers.
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+
+	"github.com/julienschmidt/httprouter"
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// tokenForm represents the form for creating an API token.
+type tokenForm struct {
+	Name                string `form:"name" validate:"required,maxrunes=100"`
+	Scope               string `form:"scope"`
+	validator.Validator `form:"-"`
+}
+
+// authenticateToken is a middleware function that authenticates API requests with a bearer token
+// in the Authorization header, in place of a session. Requests without one go on as they are, and
+// requests with an unknown or revoked token are refused. Tokens that only read can't be used for
+// anything but GET and HEAD requests. Token requests are never treated as an admin's.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+			app.writeProblem(w, r, http.StatusUnauthorized, "Authenticate with an API token as a bearer token.", r.URL.Path)
+			return
+		}
+
+		t, err := app.tokens.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox", error="invalid_token"`)
+				app.writeProblem(w, r, http.StatusUnauthorized, "The API token is unknown or was revoked.", r.URL.Path)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		if !t.CanWrite() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox", error="insufficient_scope", scope="write"`)
+			app.writeProblem(w, r, http.StatusForbidden, "The API token can only read.", r.URL.Path)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiTokenContextKey, t)
+		ctx = context.WithValue(ctx, isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, isAdminContextKey, false)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestToken returns the API token a request was authenticated with, or nil if it wasn't.
+func requestToken(r *http.Request) *models.APIToken {
+	t, _ := r.Context().Value(apiTokenContextKey).(*models.APIToken)
+	return t
+}
+
+// accountTokens serves the "/account/tokens" URL, where users create API tokens and revoke them.
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, http.StatusOK, tokenForm{Scope: models.ScopeRead}, "")
+}
+
+// accountTokensPost creates an API token for the current user. The token is shown on the page
+// that's rendered in response, and never again.
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	var form tokenForm
+
+	if err := app.decodePostForm(w, r, &form); err != nil {
+		app.formError(w, err)
+		return
+	}
+
+	form.CheckStruct(form)
+	form.CheckField(models.ValidScope(form.Scope), "scope", "Choose whether the token can only read or also write")
+
+	if !form.Valid() {
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	token, _, err := app.tokens.Insert(app.authenticatedUserID(r), form.Name, form.Scope)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.renderTokens(w, r, http.StatusOK, tokenForm{Scope: models.ScopeRead}, token)
+}
+
+// accountTokenRevokePost revokes an API token of the current user.
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokens.Revoke(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "API token revoked.")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// renderTokens renders the API tokens page with the tokens of the current user, and a token that
+// was just created, if any.
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, form tokenForm, token string) {
+	tokens, err := app.tokens.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.APITokens = tokens
+	data.NewAPIToken = token
+
+	app.render(w, r, status, "tokens.html", data)
+}
@@ -0,0 +1,92 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"slices"   // Package for searching slices.
+
+	"github.com/julienschmidt/httprouter"
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// snippetDeletePost moves a snippet to the trash of its owner, who can restore it from there until
+// it's purged. Only the owner can delete it.
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID, snippet.OwnerID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet moved to the trash.")
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// trash serves the "/trash" URL, with the snippets the current user deleted, which they can
+// restore or purge for good.
+func (app *application) trash(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.Trash(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetsData = snippets
+
+	app.render(w, r, http.StatusOK, "trash.html", data)
+}
+
+// trashRestorePost restores a snippet of the current user from the trash.
+func (app *application) trashRestorePost(w http.ResponseWriter, r *http.Request) {
+	app.trashAction(w, r, app.snippets.Undelete, "Snippet restored.")
+}
+
+// trashPurgePost deletes a snippet of the current user in the trash for good.
+func (app *application) trashPurgePost(w http.ResponseWriter, r *http.Request) {
+	app.trashAction(w, r, app.snippets.Purge, "Snippet deleted for good.")
+}
+
+// trashAction applies action to the snippet of the current user in the trash whose public ID is
+// the "id" URL parameter, and sends them back to the trash with flash.
+func (app *application) trashAction(w http.ResponseWriter, r *http.Request, action func(id, ownerID int) error, flash string) {
+	userID := app.authenticatedUserID(r)
+
+	// Snippets in the trash can't be fetched by their public ID, so look for it among the user's.
+	trash, err := app.snippets.Trash(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+	i := slices.IndexFunc(trash, func(s *models.Snippet) bool { return s.PublicID() == id })
+	if i < 0 {
+		app.notFound(w)
+		return
+	}
+
+	err = action(trash[i].ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
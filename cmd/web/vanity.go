@@ -0,0 +1,180 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"strings"  // Package for manipulating strings.
+
+	"github.com/julienschmidt/httprouter" // Router package for handling HTTP requests.
+
+	"snippetbox.adcon.dev/internal/models"    // Import the models package.
+	"snippetbox.adcon.dev/internal/validator" // Import validator package
+)
+
+// maxTitleSlug is the length the title part of a snippet's vanity URL is cut to.
+const maxTitleSlug = 60
+
+// hasVanityURL reports whether a user with the given username gets vanity URLs. Usernames that
+// were registered before they became reserved don't, so that they can't pass for the site.
+func hasVanityURL(username string) bool {
+	return validator.IsSlug(username) && !validator.OneOfString(username, reservedUsernames...)
+}
+
+// profileURL returns the vanity URL of a user's profile, such as "/~alice".
+func profileURL(username string) string {
+	return "/~" + username
+}
+
+// titleSlug returns the URL form of a snippet title: its ASCII letters and digits in lower case,
+// with the runs of other characters in between replaced by single dashes.
+func titleSlug(title string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, c := range strings.ToLower(title) {
+		if 'a' <= c && c <= 'z' || '0' <= c && c <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= maxTitleSlug {
+			break
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// snippetSlug returns the last part of a snippet's vanity URL: its public ID, then its title, such
+// as "01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond". Only the ID is needed to find the snippet.
+func snippetSlug(snippet *models.Snippet) string {
+	if slug := titleSlug(snippet.Title); slug != "" {
+		return snippet.PublicID() + "-" + slug
+	}
+	return snippet.PublicID()
+}
+
+// snippetVanityURL returns the vanity URL of a snippet, such as "/~alice/01HV6...-an-old-silent-pond",
+// or an empty string if it has none. Only listed snippets of users with a vanity URL have one:
+// held and private snippets keep their ID-based URL, which doesn't give away their title.
+func (app *application) snippetVanityURL(snippet *models.Snippet) (string, error) {
+	if snippet.OwnerID == 0 || snippet.Held || snippet.Private {
+		return "", nil
+	}
+
+	owner, err := app.users.Get(snippet.OwnerID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !hasVanityURL(owner.Username) {
+		return "", nil
+	}
+
+	return profileURL(owner.Username) + "/" + snippetSlug(snippet), nil
+}
+
+// redirectKeepingQuery redirects to target with the given status, keeping the query string of the
+// request. Profiles move for good with 301, but snippets use 302: their vanity URL changes with
+// their title and goes away when they're made private.
+func redirectKeepingQuery(w http.ResponseWriter, r *http.Request, target string, status int) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, status)
+}
+
+// userProfileRedirect serves the "/user/profile/:username" URL profiles had before vanity URLs.
+// It redirects to the vanity URL, or shows the profile of users who don't have one.
+func (app *application) userProfileRedirect(w http.ResponseWriter, r *http.Request) {
+	username := httprouter.ParamsFromContext(r.Context()).ByName("username")
+
+	if hasVanityURL(username) {
+		redirectKeepingQuery(w, r, profileURL(username), http.StatusMovedPermanently)
+		return
+	}
+
+	app.showProfile(w, r, username)
+}
+
+// vanitySnippetView serves the "/~:username/:slug" URL of a snippet. The title in the slug isn't
+// checked to find the snippet, so that links keep working when it changes, but requests with an
+// outdated or missing title are redirected to the current URL.
+func (app *application) vanitySnippetView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, _, _ := strings.Cut(params.ByName("slug"), "-")
+
+	snippet, err := app.snippetByPublicID(id)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	vanity, err := app.snippetVanityURL(snippet)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The snippet must belong to the user in the URL and still have a vanity URL.
+	if vanity == "" || !strings.HasPrefix(vanity, profileURL(params.ByName("username"))+"/") {
+		app.notFound(w)
+		return
+	}
+
+	if r.URL.Path != vanity {
+		redirectKeepingQuery(w, r, vanity, http.StatusFound)
+		return
+	}
+
+	app.showSnippet(w, r, snippet)
+}
+
+// checkSlug validates the custom slug an author chose for a snippet: it must be a slug that isn't
+// reserved and that no other snippet has.
+func (app *application) checkSlug(v *validator.Validator, slug string) error {
+	v.CheckField(validator.IsSlug(slug), "slug", "This field may only contain lowercase letters, digits and hyphens")
+	v.CheckField(!validator.OneOfString(slug, reservedUsernames...), "slug", "This URL is reserved")
+	v.CheckField(!isPublicID(slug), "slug", "This URL can't look like a snippet ID")
+	if len(v.FieldErrors["slug"]) > 0 {
+		return nil
+	}
+
+	taken, err := app.snippets.SlugTaken(slug)
+	if err != nil {
+		return err
+	}
+	v.CheckField(!taken, "slug", "This URL is already taken")
+
+	return nil
+}
+
+// snippetBySlug fetches a snippet by the last part of its "/s/" URL, which is either its public ID
+// or the custom slug its author chose.
+func (app *application) snippetBySlug(slug string) (*models.Snippet, error) {
+	if isPublicID(slug) {
+		return app.snippetByPublicID(slug)
+	}
+	return app.snippets.GetBySlug(slug)
+}
+
+// isPublicID reports whether s has the form of a snippet's public ID, a ULID or an integer ID,
+// which custom slugs can't have.
+func isPublicID(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil || models.IsULID(strings.ToUpper(s))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/models"
+	"snippetbox.adcon.dev/internal/models/mocks"
+)
+
+func TestTitleSlug(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"An old silent pond", "an-old-silent-pond"},
+		{"  main.go -- v2!  ", "main-go-v2"},
+		{"Ünïcode ünd ASCII", "n-code-nd-ascii"},
+		{"日本語", ""},
+		{"abcdefghij-bcdefghij-bcdefghij-bcdefghij-bcdefghij-bcdefghij-bcdefghij", "abcdefghij-bcdefghij-bcdefghij-bcdefghij-bcdefghij-bcdefghij"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, titleSlug(tt.title), tt.want)
+		})
+	}
+}
+
+func TestVanityURLs(t *testing.T) {
+
+	t.Parallel()
+
+	app := newTestApplication(t)
+	snippets := mocks.NewSnippetModel()
+	private := snippets.Add(&models.Snippet{
+		Title:   "Private notes",
+		Content: "Not for everyone",
+		Created: time.Now(),
+		Expires: time.Now().Add(24 * time.Hour),
+		OwnerID: 1,
+		Private: true,
+	})
+	app.snippets = snippets
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	const vanity = "/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond"
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{name: "Profile", urlPath: "/~alice", wantCode: http.StatusOK},
+		{name: "Old profile URL", urlPath: "/user/profile/alice?tab=latest", wantCode: http.StatusMovedPermanently, wantLocation: "/~alice?tab=latest"},
+		{name: "Reserved username", urlPath: "/~admin", wantCode: http.StatusNotFound},
+		{name: "Snippet", urlPath: vanity, wantCode: http.StatusOK},
+		{name: "Old snippet URL", urlPath: "/snippet/view/1", wantCode: http.StatusFound, wantLocation: vanity},
+		{name: "Old snippet ULID URL", urlPath: "/snippet/view/01HV6Z9K1QX8M3N5P7R9T2V4W6", wantCode: http.StatusFound, wantLocation: vanity},
+		{name: "Outdated title", urlPath: "/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6-old-title", wantCode: http.StatusFound, wantLocation: vanity},
+		{name: "ID only", urlPath: "/~alice/1", wantCode: http.StatusFound, wantLocation: vanity},
+		{name: "Other user", urlPath: "/~dupe/01HV6Z9K1QX8M3N5P7R9T2V4W6-an-old-silent-pond", wantCode: http.StatusNotFound},
+		{name: "Unknown snippet", urlPath: "/~alice/99-missing", wantCode: http.StatusNotFound},
+		{name: "Private snippet", urlPath: "/~alice/" + strconv.Itoa(private) + "-private-notes", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	// Private snippets keep their ID-based URL, which doesn't give their title away.
+	ts.login(t, "alice@example.com", "pa$$word")
+	code, _, body := ts.get(t, "/snippet/view/"+strconv.Itoa(private))
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Not for everyone")
+}
@@ -0,0 +1,133 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"net/http" // Package for building HTTP servers and clients.
+	"strconv"  // Package for converting strings to numeric types.
+	"time"     // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/models" // Import the models package.
+)
+
+// statsDays is the number of days covered by the view statistics panels.
+const statsDays = 30
+
+// popularLimit is the number of snippets listed on the popular page.
+const popularLimit = 50
+
+// viewDedupWindow is how long a visitor's views of a snippet after the first are not counted.
+const viewDedupWindow = 30 * time.Minute
+
+// viewDedupSize is the number of recent views remembered for deduplication. Past it, views are
+// counted without being remembered, so that a flood of visitors can't exhaust memory.
+const viewDedupSize = 100000
+
+// queuedView is a view of a snippet waiting to be recorded.
+type queuedView struct {
+	SnippetID int    // SnippetID is the ID of the viewed snippet.
+	Visitor   string // Visitor tells visitors apart, see viewVisitor.
+}
+
+// recordView queues a view of the snippet with the given ID by the client of r. It never blocks:
+// if the recorder isn't running or its queue is full, the view is dropped.
+func (app *application) recordView(r *http.Request, id int) {
+	if app.viewQueue == nil {
+		return
+	}
+
+	select {
+	case app.viewQueue <- queuedView{SnippetID: id, Visitor: app.viewVisitor(r)}:
+	default:
+	}
+}
+
+// viewVisitor identifies the client of r for view deduplication: by their user ID when they're
+// logged in, by their session otherwise, and by their address when they have no session yet or
+// sessions are kept in cookies, whose tokens are all alike.
+func (app *application) viewVisitor(r *http.Request) string {
+	if id := app.authenticatedUserID(r); id != 0 {
+		return "user:" + strconv.Itoa(id)
+	}
+	if app.cookieSessions == nil {
+		if token := app.sessionManager.Token(r.Context()); token != "" {
+			return "session:" + token
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// startViewRecorder starts a goroutine that aggregates queued views by snippet and day, and writes
+// the totals to the database every interval. Batching keeps the number of writes independent of the
+// traffic, at the cost of losing up to one interval of views if the process stops. A visitor's
+// views of a snippet within viewDedupWindow of one that was counted aren't counted again.
+func (app *application) startViewRecorder(interval time.Duration) {
+	app.viewQueue = make(chan queuedView, 1024)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		counts := map[models.ViewKey]int{}
+		seen := map[queuedView]time.Time{}
+
+		for {
+			select {
+			case view := <-app.viewQueue:
+				now := app.clock.Now()
+				if last, ok := seen[view]; ok && now.Sub(last) < viewDedupWindow {
+					continue
+				}
+				if len(seen) < viewDedupSize {
+					seen[view] = now
+				}
+
+				day := now.UTC().Truncate(24 * time.Hour)
+				counts[models.ViewKey{SnippetID: view.SnippetID, Day: day}]++
+			case <-ticker.C:
+				now := app.clock.Now()
+				for view, last := range seen {
+					if now.Sub(last) >= viewDedupWindow {
+						delete(seen, view)
+					}
+				}
+
+				if len(counts) == 0 {
+					continue
+				}
+				app.runJob("record views", func() error {
+					return app.flushViews(counts)
+				})
+				counts = map[models.ViewKey]int{}
+			}
+		}
+	}()
+}
+
+// flushViews adds counts to the daily views and to the view counts of the snippets.
+func (app *application) flushViews(counts map[models.ViewKey]int) error {
+	if err := app.views.Add(counts); err != nil {
+		return err
+	}
+
+	totals := map[int]int{}
+	for key, n := range counts {
+		totals[key.SnippetID] += n
+	}
+
+	return app.snippets.IncrementViews(totals)
+}
+
+// popular serves the "/popular" URL, with the listed snippets that were viewed the most.
+func (app *application) popular(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.MostViewed(popularLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.SnippetsData = snippets
+
+	app.render(w, r, http.StatusOK, "popular.html", data)
+}
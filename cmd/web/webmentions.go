@@ -0,0 +1,191 @@
+// Package main is the main package for this application.
+package main
+
+// Import the necessary packages.
+import (
+	"context"  // Package for carrying deadlines across API boundaries.
+	"errors"   // Package for creating error messages.
+	"net/http" // Package for building HTTP servers and clients.
+	"net/url"  // Package for parsing URLs.
+	"strings"  // Package for manipulating strings.
+	"time"     // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/models"     // Import the models package.
+	"snippetbox.adcon.dev/internal/webmention" // Import the Webmention package.
+)
+
+const (
+	// webmentionBatch is the number of pending mentions verified per run of the verification job.
+	webmentionBatch = 20
+
+	// maxWebmentionLinks is the number of links of a snippet mentions are sent to.
+	maxWebmentionLinks = 20
+
+	// maxWebmentionURL is the length of the source and target URLs accepted, like the columns
+	// they're stored in.
+	maxWebmentionURL = 500
+
+	// webmentionTimeout bounds the requests of one verification or of the mentions of one snippet.
+	webmentionTimeout = time.Minute
+)
+
+// webmentionPost serves the "/webmention" endpoint other sites send mentions of snippets to. The
+// mention is only checked for being about a public snippet here; whether the source links to it is
+// verified in the background, after the request has been accepted.
+func (app *application) webmentionPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "The request body isn't a form.", http.StatusBadRequest)
+		return
+	}
+
+	source, target := r.PostForm.Get("source"), r.PostForm.Get("target")
+
+	switch {
+	case !webmention.IsWebURL(source) || !webmention.IsWebURL(target):
+		http.Error(w, "The source and target must be http or https URLs.", http.StatusBadRequest)
+		return
+	case len(source) > maxWebmentionURL || len(target) > maxWebmentionURL:
+		http.Error(w, "The source and target URLs are too long.", http.StatusBadRequest)
+		return
+	case source == target:
+		http.Error(w, "The source and target must differ.", http.StatusBadRequest)
+		return
+	}
+
+	snippet, err := app.webmentionTarget(target)
+	if errors.Is(err, models.ErrNoRecord) {
+		http.Error(w, "The target isn't a public snippet of this site.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if _, err := app.webmentions.Receive(snippet.ID, source, target); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// webmentionTarget returns the snippet a mention's target URL points at: the ID-based or vanity URL
+// of a public snippet on this site. It returns ErrNoRecord for any other URL.
+func (app *application) webmentionTarget(target string) (*models.Snippet, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, models.ErrNoRecord
+	}
+
+	base, err := url.Parse(app.config.BaseURL)
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return nil, models.ErrNoRecord
+	}
+
+	var id string
+	if rest, ok := strings.CutPrefix(u.Path, "/snippet/view/"); ok {
+		id = rest
+	} else if rest, ok := strings.CutPrefix(u.Path, "/~"); ok {
+		_, slug, _ := strings.Cut(rest, "/")
+		id, _, _ = strings.Cut(slug, "-")
+	}
+	if id == "" || strings.Contains(id, "/") {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet, err := app.snippetByPublicID(id)
+	if err != nil {
+		return nil, err
+	}
+	if snippet.Held || snippet.Private {
+		return nil, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// verifyWebmentions fetches the sources of pending mentions, and marks each mention verified if the
+// source links to its target, and rejected otherwise. Sources that can't be fetched are rejected
+// too; sending the mention again queues it for another try.
+func (app *application) verifyWebmentions() error {
+	pending, err := app.webmentions.Pending(webmentionBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		ctx, cancel := context.WithTimeout(context.Background(), webmentionTimeout)
+		title, err := webmention.Verify(ctx, app.httpClient, m.Source, m.Target)
+		cancel()
+
+		status := models.WebmentionVerified
+		if err != nil {
+			app.infoLog.Printf("Rejected webmention %d from %s: %v", m.ID, m.Source, err)
+			status = models.WebmentionRejected
+		}
+
+		if err := app.webmentions.SetStatus(m.ID, status, title); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendWebmentions sends mentions to the pages a Markdown snippet links to, in the background. Only
+// public snippets mention other pages, since the receivers fetch the snippet to verify the mention.
+// Failures are logged.
+func (app *application) sendWebmentions(snippetID int) {
+	if !app.config.Webmentions {
+		return
+	}
+
+	go app.runJob("send webmentions", func() error {
+		snippet, err := app.snippets.Get(snippetID)
+		if err != nil {
+			return err
+		}
+		if snippet.Held || snippet.Private || snippet.Language != "markdown" {
+			return nil
+		}
+
+		baseURL := strings.TrimSuffix(app.config.BaseURL, "/")
+		source, err := app.snippetVanityURL(snippet)
+		if err != nil {
+			return err
+		}
+		if source == "" {
+			source = "/snippet/view/" + snippet.PublicID()
+		}
+		source = baseURL + source
+
+		ctx, cancel := context.WithTimeout(context.Background(), webmentionTimeout)
+		defer cancel()
+
+		links := webmention.Links(snippet.Content)
+		if len(links) > maxWebmentionLinks {
+			links = links[:maxWebmentionLinks]
+		}
+
+		for _, target := range links {
+			// Links to the site itself aren't mentions.
+			if strings.HasPrefix(target, baseURL+"/") {
+				continue
+			}
+
+			endpoint, err := webmention.Discover(ctx, app.httpClient, target)
+			if errors.Is(err, webmention.ErrNoEndpoint) {
+				continue
+			}
+			if err == nil {
+				err = webmention.Send(ctx, app.httpClient, endpoint, source, target)
+			}
+			if err != nil {
+				app.infoLog.Printf("Sending webmention to %s: %v", target, err)
+			}
+		}
+
+		return nil
+	})
+}
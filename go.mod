@@ -3,13 +3,25 @@ module snippetbox.adcon.dev
 go 1.22.1
 
 require (
+	github.com/alecthomas/chroma/v2 v2.14.0
 	github.com/alexedwards/scs/mysqlstore v0.0.0-20240316134038-7e11d57e8885
 	github.com/alexedwards/scs/v2 v2.8.0
+	github.com/andybalholm/brotli v1.2.6
 	github.com/go-playground/form/v4 v4.2.1
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/justinas/alice v1.2.0
+	github.com/klauspost/compress v1.18.0
+	github.com/oklog/ulid/v2 v2.1.2
+	github.com/oschwald/maxminddb-golang v1.13.1
+	github.com/pmezard/go-difflib v1.0.0
 	golang.org/x/crypto v0.22.0
+	golang.org/x/term v0.19.0
+	golang.org/x/time v0.5.0
 )
 
-require filippo.io/edwards25519 v1.1.0 // indirect
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
@@ -0,0 +1,79 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestNew(t *testing.T) {
+
+	t.Parallel()
+
+	v, err := New("none", "", "")
+	assert.NilError(t, err)
+	assert.Equal(t, v, nil)
+
+	v, err = New("turnstile", "site", "secret")
+	assert.NilError(t, err)
+	assert.Equal(t, v.Widget().ResponseField, "cf-turnstile-response")
+	assert.Equal(t, v.Widget().SiteKey, "site")
+
+	_, err = New("hcaptcha", "site", "")
+	assert.Equal(t, err != nil, true)
+
+	_, err = New("clicky", "site", "secret")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestSiteVerify(t *testing.T) {
+
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		switch {
+		case r.PostForm.Get("secret") != "secret":
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-secret"]}`))
+		case r.PostForm.Get("response") == "solved" && r.PostForm.Get("remoteip") == "192.0.2.1":
+			w.Write([]byte(`{"success": true}`))
+		default:
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		secret   string
+		response string
+		wantErr  error
+	}{
+		{"Solved", "secret", "solved", nil},
+		{"Not solved", "secret", "guessed", ErrFailed},
+		{"Missing response", "secret", "", ErrFailed},
+		{"Bad secret", "wrong", "solved", errors.New("captcha: the secret was rejected (invalid-input-secret)")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := &SiteVerify{VerifyURL: server.URL, Secret: tt.secret, Client: server.Client()}
+
+			err := sv.Verify(context.Background(), tt.response, "192.0.2.1")
+
+			switch {
+			case tt.wantErr == nil:
+				assert.NilError(t, err)
+			case errors.Is(tt.wantErr, ErrFailed):
+				assert.Equal(t, errors.Is(err, ErrFailed), true)
+			default:
+				assert.Equal(t, err.Error(), tt.wantErr.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestFrozen(t *testing.T) {
+
+	t.Parallel()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFrozen(start)
+
+	assert.Equal(t, c.Now(), start)
+
+	c.Advance(36 * time.Hour)
+	assert.Equal(t, c.Now(), start.Add(36*time.Hour))
+
+	c.Set(start)
+	assert.Equal(t, Now(c), start)
+}
+
+func TestNowNil(t *testing.T) {
+
+	t.Parallel()
+
+	before := time.Now()
+	now := Now(nil)
+
+	assert.Equal(t, now.Location(), time.UTC)
+	assert.Equal(t, now.Before(before), false)
+}
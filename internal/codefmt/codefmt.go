@@ -0,0 +1,61 @@
+// Package codefmt normalizes the layout of snippet content in the languages it knows how to
+// format: Go, the way gofmt does, and JSON, indented by two spaces.
+package codefmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/format"
+	"strings"
+)
+
+// ErrUnsupported is returned by Format for languages it can't format.
+var ErrUnsupported = errors.New("codefmt: language not supported")
+
+// formatters maps the languages, as named by langdetect, to the functions formatting them.
+var formatters = map[string]func(content string) (string, error){
+	"go":   formatGo,
+	"json": formatJSON,
+}
+
+// Supported reports whether Format can format content in language.
+func Supported(language string) bool {
+	_, ok := formatters[language]
+	return ok
+}
+
+// Format returns content formatted according to the conventions of language, ending with a
+// newline. It returns ErrUnsupported for languages it doesn't know, and a syntax error for content
+// that isn't valid in the language.
+func Format(language, content string) (string, error) {
+	formatter, ok := formatters[language]
+	if !ok {
+		return "", ErrUnsupported
+	}
+
+	// Browsers submit textareas with CRLF line endings.
+	return formatter(strings.ReplaceAll(content, "\r\n", "\n"))
+}
+
+// formatGo formats a Go source file, or a list of declarations or statements as snippets often are.
+func formatGo(content string) (string, error) {
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		return "", err
+	}
+
+	// Fragments keep the trailing whitespace they came with.
+	return strings.TrimRight(string(formatted), "\n") + "\n", nil
+}
+
+// formatJSON indents a JSON document by two spaces.
+func formatJSON(content string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(content), "", "  "); err != nil {
+		return "", err
+	}
+
+	// json.Indent keeps the whitespace around the value.
+	return strings.TrimSpace(buf.String()) + "\n", nil
+}
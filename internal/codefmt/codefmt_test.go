@@ -0,0 +1,37 @@
+package codefmt
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     string
+		wantErr  bool
+	}{
+		{name: "Go file", language: "go", content: "package main\nfunc main(){\nx:=1\n_ = x}", want: "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n"},
+		{name: "Go statements", language: "go", content: "x:=1\r\nfmt.Println( x )", want: "x := 1\nfmt.Println(x)\n"},
+		{name: "Go syntax error", language: "go", content: "func main() {", wantErr: true},
+		{name: "JSON", language: "json", content: ` {"a":[1,2],"b":{}} `, want: "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n"},
+		{name: "JSON syntax error", language: "json", content: `{"a":}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.language, tt.content)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+
+	_, err := Format("python", "print('hi')")
+	assert.Equal(t, errors.Is(err, ErrUnsupported), true)
+}
@@ -0,0 +1,253 @@
+// Package cookiestore is a session store for scs that keeps each session in its cookie instead of
+// a database. The session data is encrypted and authenticated with AES-GCM, so clients can
+// neither read nor change it, and the keys can be rotated without logging everyone out.
+//
+// Since the store has no state, sessions can't be listed or revoked before they expire: a copy of
+// an old cookie stays valid until its expiry, even after logging out. And since browsers cap
+// cookies at about 4 KB, sessions must stay small.
+//
+// The store needs its own middleware, Store.LoadAndSave, in place of the session manager's.
+package cookiestore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// MaxSize is the longest cookie value the store writes. Browsers keep cookies of up to 4096 bytes
+// including their name and attributes, and the rest leaves room for those.
+const MaxSize = 3800
+
+// sessionToken is the token sessions loaded from a cookie get. The token only tells the session
+// manager that the session exists; the session is in the cookie.
+const sessionToken = "cookie"
+
+var (
+	// ErrTooLarge is returned when a session doesn't fit in a cookie.
+	ErrTooLarge = errors.New("cookiestore: session too large for a cookie")
+
+	// ErrNoRequest is returned when the store is used outside Store.LoadAndSave.
+	ErrNoRequest = errors.New("cookiestore: session used outside LoadAndSave")
+)
+
+// Keys seals and opens session data. models.Keyring implements it: the first key of the keyring
+// seals new cookies, and the others open cookies sealed before a rotation.
+type Keys interface {
+	WrapKey(data []byte) (keyID string, sealed []byte, err error)
+	UnwrapKey(keyID string, sealed []byte) ([]byte, error)
+}
+
+// Store is an scs.Store, and scs.CtxStore, that keeps sessions in their cookie.
+type Store struct {
+	Keys  Keys        // Keys seals the cookies.
+	Clock clock.Clock // Clock tells whether cookies have expired. It defaults to the system clock.
+}
+
+// cookie carries the session of a request between LoadAndSave and the store.
+type cookie struct {
+	data  []byte // data is the session data from the request's cookie, or nil.
+	value string // value is the sealed session to send back, once it's committed.
+}
+
+// contextKey is the key of the request's cookie in its context.
+type contextKey struct{}
+
+// LoadAndSave is the middleware that loads the session from the request's cookie and writes the
+// session back to a cookie if it changes, like scs.SessionManager.LoadAndSave does for other
+// stores. sm must use the store.
+func (s *Store) LoadAndSave(sm *scs.SessionManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Cookie")
+
+			c := &cookie{}
+			token := ""
+			if rc, err := r.Cookie(sm.Cookie.Name); err == nil {
+				if data, ok := s.open(rc.Value); ok {
+					c.data = data
+					token = sessionToken
+				}
+			}
+
+			ctx, err := sm.Load(context.WithValue(r.Context(), contextKey{}, c), token)
+			if err != nil {
+				sm.ErrorFunc(w, r, err)
+				return
+			}
+
+			sr := r.WithContext(ctx)
+			sw := &responseWriter{ResponseWriter: w, save: func() { s.save(sm, w, sr, c) }}
+
+			next.ServeHTTP(sw, sr)
+
+			if !sw.saved {
+				sw.save()
+			}
+		})
+	}
+}
+
+// save writes the session cookie, or deletes it, if the session changed.
+func (s *Store) save(sm *scs.SessionManager, w http.ResponseWriter, r *http.Request, c *cookie) {
+	ctx := r.Context()
+
+	switch sm.Status(ctx) {
+	case scs.Modified:
+		_, expiry, err := sm.Commit(ctx)
+		if err != nil {
+			sm.ErrorFunc(w, r, err)
+			return
+		}
+		sm.WriteSessionCookie(ctx, w, c.value, expiry)
+	case scs.Destroyed:
+		sm.WriteSessionCookie(ctx, w, "", time.Time{})
+	}
+}
+
+// FindCtx returns the session data from the request's cookie.
+func (s *Store) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	c, ok := ctx.Value(contextKey{}).(*cookie)
+	if !ok {
+		return nil, false, ErrNoRequest
+	}
+
+	return c.data, c.data != nil, nil
+}
+
+// CommitCtx seals the session data into the value of the cookie to send back. It returns
+// ErrTooLarge if the sealed session doesn't fit in a cookie.
+func (s *Store) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	c, ok := ctx.Value(contextKey{}).(*cookie)
+	if !ok {
+		return ErrNoRequest
+	}
+
+	value, err := s.seal(b, expiry)
+	if err != nil {
+		return err
+	}
+	if len(value) > MaxSize {
+		return ErrTooLarge
+	}
+
+	c.value = value
+	return nil
+}
+
+// DeleteCtx forgets the session data of the request. The cookie is deleted by LoadAndSave.
+func (s *Store) DeleteCtx(ctx context.Context, token string) error {
+	if c, ok := ctx.Value(contextKey{}).(*cookie); ok {
+		c.data = nil
+	}
+	return nil
+}
+
+// Find is only there to implement scs.Store: the session manager calls FindCtx instead.
+func (s *Store) Find(token string) ([]byte, bool, error) {
+	return nil, false, ErrNoRequest
+}
+
+// Commit is only there to implement scs.Store: the session manager calls CommitCtx instead.
+func (s *Store) Commit(token string, b []byte, expiry time.Time) error {
+	return ErrNoRequest
+}
+
+// Delete is only there to implement scs.Store: the session manager calls DeleteCtx instead.
+func (s *Store) Delete(token string) error {
+	return ErrNoRequest
+}
+
+// All returns no sessions, since the store can't see the cookies of other requests. It lets code
+// that goes through sessions with scs.SessionManager.Iterate run, and find nothing.
+func (s *Store) All() (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+
+// seal encodes session data and its expiry into a cookie value:
+//
+//	base64url([key ID length][key ID][sealed [expiry (8 bytes)][data]])
+func (s *Store) seal(b []byte, expiry time.Time) (string, error) {
+	plaintext := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(b)), uint64(expiry.Unix()))
+	plaintext = append(plaintext, b...)
+
+	keyID, sealed, err := s.Keys.WrapKey(plaintext)
+	if err != nil {
+		return "", err
+	}
+	if len(keyID) > 255 {
+		return "", errors.New("cookiestore: key ID too long")
+	}
+
+	value := append([]byte{byte(len(keyID))}, keyID...)
+	value = append(value, sealed...)
+
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// open returns the session data in a cookie value, and false if the value was tampered with,
+// sealed with a key that's no longer in use, or has expired.
+func (s *Store) open(value string) ([]byte, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) < 1 || len(raw) < 1+int(raw[0]) {
+		return nil, false
+	}
+	keyID, sealed := string(raw[1:1+raw[0]]), raw[1+raw[0]:]
+
+	plaintext, err := s.Keys.UnwrapKey(keyID, sealed)
+	if err != nil || len(plaintext) < 8 {
+		return nil, false
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(plaintext)), 0)
+	if !s.now().Before(expiry) {
+		return nil, false
+	}
+
+	return plaintext[8:], true
+}
+
+// now returns the current time of the store's clock.
+func (s *Store) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// responseWriter saves the session before the response is written, while headers can still be set.
+type responseWriter struct {
+	http.ResponseWriter
+	save  func()
+	saved bool
+}
+
+// WriteHeader saves the session and writes the status.
+func (sw *responseWriter) WriteHeader(code int) {
+	if !sw.saved {
+		sw.saved = true
+		sw.save()
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Write saves the session and writes b.
+func (sw *responseWriter) Write(b []byte) (int, error) {
+	if !sw.saved {
+		sw.saved = true
+		sw.save()
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController.
+func (sw *responseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
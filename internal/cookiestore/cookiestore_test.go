@@ -0,0 +1,198 @@
+package cookiestore
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// newKeyring returns a keyring of 32-byte keys filled with the given bytes, the first one active.
+func newKeyring(t *testing.T, keys ...byte) *models.Keyring {
+	t.Helper()
+
+	var entries []string
+	for _, k := range keys {
+		key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(k), 32)))
+		entries = append(entries, string(k)+":"+key)
+	}
+
+	kr, err := models.ParseKeyring(strings.Join(entries, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+// newServer returns a handler that stores the "put" query parameter in the session, destroys the
+// session if it's "destroy", and writes back the stored value.
+func newServer(store *Store) (*scs.SessionManager, http.Handler) {
+	sm := scs.New()
+	sm.Store = store
+	sm.Lifetime = time.Hour
+
+	handler := store.LoadAndSave(sm)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch v := r.URL.Query().Get("put"); v {
+		case "":
+		case "destroy":
+			if err := sm.Destroy(r.Context()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		default:
+			sm.Put(r.Context(), "value", v)
+		}
+		w.Write([]byte(sm.GetString(r.Context(), "value")))
+	}))
+
+	return sm, handler
+}
+
+// do sends a request to handler with the given session cookie, and returns the body and the
+// session cookie of the response, if there is one.
+func do(t *testing.T, handler http.Handler, url string, cookie *http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "session" {
+			return rr.Body.String(), c
+		}
+	}
+	return rr.Body.String(), nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	store := &Store{Keys: newKeyring(t, 'a')}
+	_, handler := newServer(store)
+
+	body, cookie := do(t, handler, "/?put=hello", nil)
+	if body != "hello" || cookie == nil {
+		t.Fatalf("got body %q and cookie %v; want hello and a cookie", body, cookie)
+	}
+	if strings.Contains(cookie.Value, "hello") {
+		t.Errorf("cookie %q holds the session in the clear", cookie.Value)
+	}
+
+	body, again := do(t, handler, "/", cookie)
+	if body != "hello" {
+		t.Errorf("got body %q; want hello", body)
+	}
+	if again != nil {
+		t.Errorf("an unchanged session was written back")
+	}
+
+	body, deleted := do(t, handler, "/?put=destroy", cookie)
+	if body != "" {
+		t.Errorf("got body %q after destroying the session; want nothing", body)
+	}
+	if deleted == nil || deleted.MaxAge >= 0 {
+		t.Errorf("got cookie %v after destroying the session; want it deleted", deleted)
+	}
+}
+
+func TestRejectedCookies(t *testing.T) {
+	now := time.Now()
+	frozen := clock.NewFrozen(now)
+	store := &Store{Keys: newKeyring(t, 'a'), Clock: frozen}
+	_, handler := newServer(store)
+
+	_, cookie := do(t, handler, "/?put=hello", nil)
+
+	raw, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+
+	tests := []struct {
+		name  string
+		store *Store
+		value string
+	}{
+		{
+			name:  "Tampered",
+			store: store,
+			value: base64.RawURLEncoding.EncodeToString(raw),
+		},
+		{
+			name:  "Garbage",
+			store: store,
+			value: "not a session",
+		},
+		{
+			name:  "Retired key",
+			store: &Store{Keys: newKeyring(t, 'b'), Clock: frozen},
+			value: cookie.Value,
+		},
+		{
+			name:  "Expired",
+			store: &Store{Keys: newKeyring(t, 'a'), Clock: clock.NewFrozen(now.Add(2 * time.Hour))},
+			value: cookie.Value,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newServer(tt.store)
+
+			body, _ := do(t, handler, "/", &http.Cookie{Name: "session", Value: tt.value})
+			if body != "" {
+				t.Errorf("got body %q; want an empty session", body)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, old := newServer(&Store{Keys: newKeyring(t, 'a')})
+	_, cookie := do(t, old, "/?put=hello", nil)
+
+	// The new key seals cookies, and the old one still opens those sealed before the rotation.
+	_, rotated := newServer(&Store{Keys: newKeyring(t, 'b', 'a')})
+
+	body, _ := do(t, rotated, "/", cookie)
+	if body != "hello" {
+		t.Fatalf("got body %q with the rotated keyring; want hello", body)
+	}
+
+	_, resealed := do(t, rotated, "/?put=again", cookie)
+	_, retired := newServer(&Store{Keys: newKeyring(t, 'b')})
+
+	body, _ = do(t, retired, "/", resealed)
+	if body != "again" {
+		t.Errorf("got body %q once the old key is retired; want again", body)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	store := &Store{Keys: newKeyring(t, 'a')}
+	sm, handler := newServer(store)
+
+	var got error
+	sm.ErrorFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+		got = err
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
+
+	_, cookie := do(t, handler, "/?put="+strings.Repeat("x", MaxSize), nil)
+	if got != ErrTooLarge {
+		t.Errorf("got error %v; want ErrTooLarge", got)
+	}
+	if cookie != nil {
+		t.Errorf("got a cookie of %d bytes; want none", len(cookie.Value))
+	}
+}
@@ -0,0 +1,165 @@
+// Package dbconfig builds the MySQL data source name from structured settings, so that the database
+// address, credentials and TLS options can be given as separate flags rather than a hand-written DSN.
+//
+// A DSN can still be given as a base, with the other settings overriding its parts. When TLS is
+// configured, the TLS settings are registered with the driver under TLSKey and the DSN refers to them.
+package dbconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLSKey is the name the TLS settings are registered with the driver under.
+const TLSKey = "snippetbox"
+
+// The TLS modes.
+const (
+	TLSOff        = "off"         // TLSOff connects without TLS, unless the base DSN asks for it.
+	TLSOn         = "on"          // TLSOn requires TLS and verifies the server's certificate.
+	TLSSkipVerify = "skip-verify" // TLSSkipVerify requires TLS without verifying the certificate, for development.
+	TLSPreferred  = "preferred"   // TLSPreferred uses TLS without verification when the server supports it.
+)
+
+// Config holds the settings of the database connection.
+type Config struct {
+	DSN      string // DSN is a data source name the other settings override, for existing deployments.
+	Addr     string // Addr is the host:port of the MySQL server.
+	User     string // User is the database user.
+	Password string // Password is the password of the user.
+	Name     string // Name is the database to use.
+
+	TLS           string // TLS is the TLS mode: off, on, skip-verify or preferred.
+	TLSCA         string // TLSCA is a PEM file of the CA certificates the server's certificate is verified with.
+	TLSCert       string // TLSCert is a PEM file of the client certificate, for servers that require one.
+	TLSKey        string // TLSKey is the PEM file of the client certificate's private key.
+	TLSServerName string // TLSServerName is the name the server's certificate is verified for, if it isn't the host in Addr.
+}
+
+// RegisterFlags registers the flags setting c on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.DSN, "dsn", "", "MySQL data source name; the -db-* flags override its parts")
+	fs.StringVar(&c.Addr, "db-addr", "", "Address (host:port) of the MySQL server")
+	fs.StringVar(&c.User, "db-user", "", "MySQL user")
+	fs.StringVar(&c.Password, "db-password", "", "Password of the MySQL user")
+	fs.StringVar(&c.Name, "db-name", "", "MySQL database name")
+	fs.StringVar(&c.TLS, "db-tls", TLSOff, "TLS for the MySQL connection (off, on, skip-verify or preferred)")
+	fs.StringVar(&c.TLSCA, "db-tls-ca", "", "PEM file of the CA certificates to verify the MySQL server with (default system roots)")
+	fs.StringVar(&c.TLSCert, "db-tls-cert", "", "PEM file of the client certificate for the MySQL connection")
+	fs.StringVar(&c.TLSKey, "db-tls-key", "", "PEM file of the client certificate's private key")
+	fs.StringVar(&c.TLSServerName, "db-tls-server-name", "", "Name to verify the MySQL server's certificate for (default the host of the address)")
+}
+
+// MySQL returns the driver configuration: the base DSN, if any, with the other settings applied.
+// Times are always parsed, since the models rely on it. With TLS, the TLS settings are registered
+// with the driver.
+func (c Config) MySQL() (*mysql.Config, error) {
+	cfg := mysql.NewConfig()
+	if c.DSN != "" {
+		var err error
+		if cfg, err = mysql.ParseDSN(c.DSN); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Addr != "" {
+		cfg.Net = "tcp"
+		cfg.Addr = c.Addr
+	}
+	if c.User != "" {
+		cfg.User = c.User
+	}
+	if c.Password != "" {
+		cfg.Passwd = c.Password
+	}
+	if c.Name != "" {
+		cfg.DBName = c.Name
+	}
+	cfg.ParseTime = true
+
+	tlsConfig, err := c.tlsConfig(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		if err := mysql.RegisterTLSConfig(TLSKey, tlsConfig); err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = TLSKey
+		cfg.AllowFallbackToPlaintext = c.TLS == TLSPreferred
+	}
+
+	return cfg, nil
+}
+
+// Build returns the data source name for c.
+func (c Config) Build() (string, error) {
+	cfg, err := c.MySQL()
+	if err != nil {
+		return "", err
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// tlsConfig returns the TLS settings for connecting to addr, or nil when TLS is off.
+func (c Config) tlsConfig(addr string) (*tls.Config, error) {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return nil, errors.New("dbconfig: -db-tls-cert and -db-tls-key must be given together")
+	}
+
+	switch c.TLS {
+	case "", TLSOff:
+		if c.TLSCA != "" || c.TLSCert != "" || c.TLSServerName != "" {
+			return nil, errors.New("dbconfig: TLS files and server name need -db-tls")
+		}
+		return nil, nil
+	case TLSOn, TLSSkipVerify, TLSPreferred:
+	default:
+		return nil, fmt.Errorf("dbconfig: unknown TLS mode %q", c.TLS)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.TLSServerName,
+		// Servers offering TLS opportunistically usually have self-signed certificates, which is
+		// why the driver doesn't verify them in preferred mode either.
+		InsecureSkipVerify: c.TLS != TLSOn,
+	}
+
+	if tlsConfig.ServerName == "" {
+		host := addr
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			host = h
+		}
+		tlsConfig.ServerName = host
+	}
+
+	if c.TLSCA != "" {
+		pem, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("dbconfig: no certificates in %s", c.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
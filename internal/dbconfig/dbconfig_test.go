@@ -0,0 +1,194 @@
+package dbconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+// writeCertificate writes a self-signed certificate and its key to PEM files in a temporary
+// directory and returns their paths.
+func writeCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "db.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NilError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NilError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NilError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestBuild(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantDSN string
+		wantErr string
+	}{
+		{
+			name:    "Raw DSN",
+			config:  Config{DSN: "web:pass@/snippetbox?parseTime=true"},
+			wantDSN: "web:pass@tcp(127.0.0.1:3306)/snippetbox?parseTime=true",
+		},
+		{
+			name:    "Raw DSN without parseTime",
+			config:  Config{DSN: "web:pass@/snippetbox"},
+			wantDSN: "web:pass@tcp(127.0.0.1:3306)/snippetbox?parseTime=true",
+		},
+		{
+			name:    "Structured",
+			config:  Config{Addr: "db.example.com:3306", User: "web", Password: "p@ss", Name: "snippetbox"},
+			wantDSN: "web:p@ss@tcp(db.example.com:3306)/snippetbox?parseTime=true",
+		},
+		{
+			name:    "Overrides DSN",
+			config:  Config{DSN: "web:pass@/snippetbox", Password: "secret", Name: "other"},
+			wantDSN: "web:secret@tcp(127.0.0.1:3306)/other?parseTime=true",
+		},
+		{
+			name:    "TLS",
+			config:  Config{Addr: "db.example.com:3306", User: "web", Name: "snippetbox", TLS: TLSOn, TLSCA: certFile},
+			wantDSN: "web@tcp(db.example.com:3306)/snippetbox?parseTime=true&tls=" + TLSKey,
+		},
+		{
+			name:    "Preferred TLS",
+			config:  Config{Addr: "db:3306", User: "web", Name: "snippetbox", TLS: TLSPreferred},
+			wantDSN: "web@tcp(db:3306)/snippetbox?allowFallbackToPlaintext=true&parseTime=true&tls=" + TLSKey,
+		},
+		{
+			name:    "Unknown mode",
+			config:  Config{TLS: "maybe"},
+			wantErr: `unknown TLS mode "maybe"`,
+		},
+		{
+			name:    "Certificate without key",
+			config:  Config{TLS: TLSOn, TLSCert: certFile},
+			wantErr: "must be given together",
+		},
+		{
+			name:    "CA without TLS",
+			config:  Config{TLSCA: certFile},
+			wantErr: "need -db-tls",
+		},
+		{
+			name:    "Missing CA file",
+			config:  Config{TLS: TLSOn, TLSCA: filepath.Join(t.TempDir(), "missing.pem")},
+			wantErr: "no such file",
+		},
+		{
+			name:    "CA file without certificates",
+			config:  Config{TLS: TLSOn, TLSCA: keyFile},
+			wantErr: "no certificates",
+		},
+		{
+			name:    "Invalid DSN",
+			config:  Config{DSN: "not a dsn"},
+			wantErr: "invalid DSN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := tt.config.Build()
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("got DSN %q; want error containing %q", dsn, tt.wantErr)
+				}
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, dsn, tt.wantDSN)
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	t.Run("Verified", func(t *testing.T) {
+		c := Config{TLS: TLSOn, TLSCA: certFile, TLSCert: certFile, TLSKey: keyFile}
+
+		tlsConfig, err := c.tlsConfig("db.example.com:3306")
+		assert.NilError(t, err)
+
+		assert.Equal(t, tlsConfig.ServerName, "db.example.com")
+		assert.Equal(t, tlsConfig.InsecureSkipVerify, false)
+		assert.Equal(t, tlsConfig.RootCAs != nil, true)
+		assert.Equal(t, len(tlsConfig.Certificates), 1)
+	})
+
+	t.Run("Server name", func(t *testing.T) {
+		c := Config{TLS: TLSOn, TLSServerName: "mysql.internal"}
+
+		tlsConfig, err := c.tlsConfig("10.0.0.5:3306")
+		assert.NilError(t, err)
+
+		assert.Equal(t, tlsConfig.ServerName, "mysql.internal")
+	})
+
+	t.Run("Skip verify", func(t *testing.T) {
+		c := Config{TLS: TLSSkipVerify}
+
+		tlsConfig, err := c.tlsConfig("localhost:3306")
+		assert.NilError(t, err)
+
+		assert.Equal(t, tlsConfig.InsecureSkipVerify, true)
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		tlsConfig, err := Config{}.tlsConfig("localhost:3306")
+		assert.NilError(t, err)
+
+		assert.Equal(t, tlsConfig == nil, true)
+	})
+}
+
+func TestRegisterFlags(t *testing.T) {
+	var c Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+
+	err := fs.Parse([]string{"-db-addr=db:3306", "-db-user=web", "-db-name=snippetbox", "-db-tls=skip-verify"})
+	assert.NilError(t, err)
+
+	dsn, err := c.Build()
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(dsn, "web@tcp(db:3306)/snippetbox?"), true)
+	assert.StringContains(t, dsn, "tls="+TLSKey)
+}
@@ -0,0 +1,222 @@
+// Package filter screens user-submitted text, such as snippet titles and content, against
+// configurable rules and decides whether it should be accepted, held for moderation, quietly hidden
+// or rejected.
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Action is the outcome of a filter rule. Higher actions take precedence over lower ones.
+type Action int
+
+const (
+	Allow  Action = iota // Allow accepts the text.
+	Hold                 // Hold accepts the text but hides it until a moderator approves it.
+	Shadow               // Shadow accepts the text but hides it from everyone but its author, without telling them.
+	Reject               // Reject refuses the text.
+)
+
+// String returns the name of the action as used in blocklist files.
+func (a Action) String() string {
+	switch a {
+	case Hold:
+		return "hold"
+	case Shadow:
+		return "shadow"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// ParseAction returns the action with the given name. Allow isn't a rule action, so it's refused.
+func ParseAction(name string) (Action, error) {
+	switch name {
+	case "hold":
+		return Hold, nil
+	case "shadow":
+		return Shadow, nil
+	case "reject":
+		return Reject, nil
+	default:
+		return Allow, fmt.Errorf("filter: unknown action %q", name)
+	}
+}
+
+// Verdict is the result of checking text against a filter.
+type Verdict struct {
+	Action Action // Action is the strongest action of the matching rules.
+	Rule   string // Rule describes the rule that decided the action, for logs and moderators.
+	RuleID int    // RuleID is the ID of the rule that decided the action, or 0 if it has none.
+}
+
+// Filter is implemented by anything that can screen text. Check is given every piece of text of a
+// submission (for example a title and content) and returns the combined verdict.
+type Filter interface {
+	Check(texts ...string) Verdict
+}
+
+// Rule is a single blocklist rule.
+type Rule struct {
+	ID      int            // ID identifies rules kept in a database. It's 0 for rules from blocklist files.
+	Action  Action         // Action is applied when the rule matches.
+	Kind    string         // Kind is "word", "regex" or "urls".
+	Pattern string         // Pattern is the rule's argument as written in the blocklist.
+	re      *regexp.Regexp // re is the compiled pattern for word and regex rules.
+	max     int            // max is the maximum number of URLs for urls rules.
+}
+
+// urlRX matches http and https URLs for the urls rule.
+var urlRX = regexp.MustCompile(`(?i)\bhttps?://`)
+
+// NewRule compiles a rule. Word rules match whole words case-insensitively, regex rules match a
+// regular expression, and urls rules match text containing more than the given number of URLs.
+func NewRule(action Action, kind, pattern string) (Rule, error) {
+	r := Rule{Action: action, Kind: kind, Pattern: pattern}
+
+	var err error
+
+	switch kind {
+	case "word":
+		r.re, err = regexp.Compile(`(?i)\b` + regexp.QuoteMeta(pattern) + `\b`)
+	case "regex":
+		r.re, err = regexp.Compile(pattern)
+	case "urls":
+		r.max, err = strconv.Atoi(pattern)
+	default:
+		err = fmt.Errorf("unknown rule kind %q", kind)
+	}
+	if err != nil {
+		return Rule{}, fmt.Errorf("filter: %w", err)
+	}
+
+	return r, nil
+}
+
+// Matches reports whether the rule matches text.
+func (r Rule) Matches(text string) bool {
+	if r.Kind == "urls" {
+		return len(urlRX.FindAllStringIndex(text, -1)) > r.max
+	}
+
+	return r.re.MatchString(text)
+}
+
+// String returns the rule in blocklist file syntax.
+func (r Rule) String() string {
+	return r.Action.String() + " " + r.Kind + " " + r.Pattern
+}
+
+// Blocklist is a Filter made of an ordered list of rules.
+type Blocklist struct {
+	Rules []Rule
+}
+
+// Check returns the strongest action of the rules matching any of the texts. The zero value of a
+// Blocklist allows everything.
+func (b *Blocklist) Check(texts ...string) Verdict {
+	verdict := Verdict{Action: Allow}
+
+	for _, r := range b.Rules {
+		if r.Action <= verdict.Action {
+			continue
+		}
+
+		for _, text := range texts {
+			if r.Matches(text) {
+				verdict = Verdict{Action: r.Action, Rule: r.String(), RuleID: r.ID}
+				break
+			}
+		}
+	}
+
+	return verdict
+}
+
+// Chain is a Filter that checks text against several filters, such as a blocklist file and the
+// rules admins manage, and returns the strongest of their verdicts. Ties go to the first filter.
+type Chain []Filter
+
+// Check returns the strongest verdict of the filters.
+func (c Chain) Check(texts ...string) Verdict {
+	verdict := Verdict{Action: Allow}
+
+	for _, f := range c {
+		if v := f.Check(texts...); v.Action > verdict.Action {
+			verdict = v
+		}
+	}
+
+	return verdict
+}
+
+// Parse reads a blocklist in the following format, one rule per line:
+//
+//	# action  kind   pattern
+//	reject    word   viagra
+//	hold      regex  (?i)free\s+money
+//	shadow    word   casino
+//	hold      urls   5
+//
+// Blank lines and lines starting with # are ignored. The pattern is the rest of the line after the
+// kind, so regular expressions may contain spaces.
+func Parse(r io.Reader) (*Blocklist, error) {
+	b := &Blocklist{}
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("filter: line %d: expected \"action kind pattern\"", n)
+		}
+
+		action, err := ParseAction(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("filter: line %d: unknown action %q", n, fields[0])
+		}
+
+		// The pattern is everything after the kind, with the surrounding whitespace removed.
+		rest := strings.TrimSpace(line[len(fields[0]):])
+		pattern := strings.TrimSpace(rest[len(fields[1]):])
+
+		rule, err := NewRule(action, fields[1], pattern)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		b.Rules = append(b.Rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Load reads a blocklist file. An empty path returns an empty blocklist, which allows everything.
+func Load(path string) (*Blocklist, error) {
+	if path == "" {
+		return &Blocklist{}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
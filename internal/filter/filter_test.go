@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+const testBlocklist = `
+# Spam
+reject word   viagra
+hold   regex  (?i)free\s+money
+hold   urls   2
+shadow word   casino
+`
+
+func TestBlocklistCheck(t *testing.T) {
+
+	t.Parallel()
+
+	b, err := Parse(strings.NewReader(testBlocklist))
+	assert.NilError(t, err)
+	assert.Equal(t, len(b.Rules), 4)
+
+	tests := []struct {
+		name       string
+		texts      []string
+		wantAction Action
+		wantRule   string
+	}{
+		{
+			name:       "Clean",
+			texts:      []string{"An old silent pond", "A frog jumps into the pond"},
+			wantAction: Allow,
+		},
+		{
+			name:       "Word in content",
+			texts:      []string{"Title", "Buy VIAGRA now"},
+			wantAction: Reject,
+			wantRule:   "reject word viagra",
+		},
+		{
+			name:       "Word inside another word",
+			texts:      []string{"Title", "viagrass"},
+			wantAction: Allow,
+		},
+		{
+			name:       "Regex with spaces",
+			texts:      []string{"Free   money inside", ""},
+			wantAction: Hold,
+			wantRule:   `hold regex (?i)free\s+money`,
+		},
+		{
+			name:       "Too many URLs",
+			texts:      []string{"Links", "http://a.example https://b.example http://c.example"},
+			wantAction: Hold,
+			wantRule:   "hold urls 2",
+		},
+		{
+			name:       "Shadow",
+			texts:      []string{"Casino tips", ""},
+			wantAction: Shadow,
+			wantRule:   "shadow word casino",
+		},
+		{
+			name:       "Shadow beats hold",
+			texts:      []string{"free money", "casino"},
+			wantAction: Shadow,
+			wantRule:   "shadow word casino",
+		},
+		{
+			name:       "Strongest action wins",
+			texts:      []string{"free money", "viagra"},
+			wantAction: Reject,
+			wantRule:   "reject word viagra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := b.Check(tt.texts...)
+
+			assert.Equal(t, v.Action, tt.wantAction)
+			assert.Equal(t, v.Rule, tt.wantRule)
+		})
+	}
+}
+
+func TestChainCheck(t *testing.T) {
+
+	t.Parallel()
+
+	file, err := Parse(strings.NewReader("hold word casino"))
+	assert.NilError(t, err)
+
+	rule, err := NewRule(Reject, "word", "viagra")
+	assert.NilError(t, err)
+	rule.ID = 7
+	managed := &Blocklist{Rules: []Rule{rule}}
+
+	chain := Chain{file, managed}
+
+	v := chain.Check("Casino", "")
+	assert.Equal(t, v.Action, Hold)
+	assert.Equal(t, v.RuleID, 0)
+
+	v = chain.Check("Casino", "viagra")
+	assert.Equal(t, v.Action, Reject)
+	assert.Equal(t, v.Rule, "reject word viagra")
+	assert.Equal(t, v.RuleID, 7)
+
+	v = Chain{}.Check("anything")
+	assert.Equal(t, v.Action, Allow)
+}
+
+func TestParseErrors(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Unknown action", input: "block word spam"},
+		{name: "Unknown kind", input: "hold phrase spam"},
+		{name: "Missing pattern", input: "hold word"},
+		{name: "Invalid regex", input: "hold regex ("},
+		{name: "Invalid URL count", input: "hold urls many"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package fingerprint computes fingerprints of snippet content for spotting duplicates: a hash
+// that matches content identical up to whitespace, and a simhash that is close for content that
+// only differs in a few places.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// MaxDistance is the number of bits two simhashes may differ by for their content to count as
+// nearly identical.
+const MaxDistance = 3
+
+// minTokens is the number of words content needs for its simhash to mean anything. A few words
+// are shared with too many other texts.
+const minTokens = 10
+
+// Normalize returns content with CRLF line endings converted, trailing whitespace removed from
+// every line, and blank lines removed from both ends, so that copies pasted from different places
+// compare equal.
+func Normalize(content string) string {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// Hash returns the hex-encoded SHA-256 of the normalized content.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(Normalize(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Simhash returns the 64-bit simhash of the words of content, ignoring case and punctuation, so that
+// content differing in a few words has simhashes differing in a few bits. Words are used rather
+// than shingles of consecutive words because snippets are short: one changed word changes every
+// shingle it's part of. It reports false for content too short for its simhash to be meaningful.
+func Simhash(content string) (uint64, bool) {
+	tokens := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(tokens) < minTokens {
+		return 0, false
+	}
+
+	var weights [64]int
+	for _, token := range tokens {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var simhash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			simhash |= 1 << bit
+		}
+	}
+
+	return simhash, true
+}
+
+// Distance returns the number of bits two simhashes differ by.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,43 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+const haiku = `An old silent pond
+A frog jumps into the pond
+Splash! Silence again.
+Over the wintry forest, winds howl in rage
+with no leaves to blow.`
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Hash("a := 1  \r\nb := 2\n\n"), Hash("\na := 1\nb := 2"))
+	assert.Equal(t, Hash("a := 1\nb := 2") == Hash("a := 1\nb := 3"), false)
+
+	// Indentation is significant.
+	assert.Equal(t, Hash("if x {\n\ty()\n}") == Hash("if x {\ny()\n}"), false)
+}
+
+func TestSimhash(t *testing.T) {
+	t.Parallel()
+
+	_, ok := Simhash("Too short to tell")
+	assert.Equal(t, ok, false)
+
+	a, ok := Simhash(haiku)
+	assert.Equal(t, ok, true)
+
+	b, _ := Simhash("AN OLD SILENT POND!\n" + haiku[len("An old silent pond\n"):])
+	assert.Equal(t, Distance(a, b), 0)
+
+	b, _ = Simhash(strings.Replace(haiku, "frog", "toad", 1))
+	assert.Equal(t, Distance(a, b) <= MaxDistance, true)
+
+	c, _ := Simhash("The light of a candle is transferred to another candle, spring twilight. In the twilight rain these brilliant-hued hibiscus, a lovely sunset.")
+	assert.Equal(t, Distance(a, c) <= MaxDistance, false)
+}
@@ -0,0 +1,85 @@
+// Package geoip looks up the country of IP addresses in a MaxMind database, such as the free
+// GeoLite2 Country database or the commercial GeoIP2 Country and City databases.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Locator returns the country of IP addresses.
+type Locator interface {
+	// Country returns the ISO 3166-1 alpha-2 code of the country ip is in, such as "DE", or an
+	// empty string if the database doesn't know.
+	Country(ip net.IP) (string, error)
+}
+
+// DB is a MaxMind database opened from a file. It's safe for concurrent use.
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+// record is the part of a database record lookups decode. Country databases only have the
+// country; City databases have it too.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// Open opens the database file at path.
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: %w", err)
+	}
+
+	if !strings.Contains(reader.Metadata.DatabaseType, "Country") && !strings.Contains(reader.Metadata.DatabaseType, "City") {
+		reader.Close()
+		return nil, fmt.Errorf("geoip: %s is a %s database, not a Country or City database", path, reader.Metadata.DatabaseType)
+	}
+
+	return &DB{reader: reader}, nil
+}
+
+// Country returns the country code of ip, or an empty string if the database doesn't have it.
+func (db *DB) Country(ip net.IP) (string, error) {
+	if ip == nil {
+		return "", errors.New("geoip: no IP address")
+	}
+
+	var r record
+	if err := db.reader.Lookup(ip, &r); err != nil {
+		return "", fmt.Errorf("geoip: %w", err)
+	}
+
+	return r.Country.ISOCode, nil
+}
+
+// Close closes the database file.
+func (db *DB) Close() error {
+	return db.reader.Close()
+}
+
+// ParseCountries parses a comma-separated list of ISO 3166-1 alpha-2 country codes, such as
+// "CN, ru", into a set of upper case codes.
+func ParseCountries(list string) (map[string]bool, error) {
+	countries := map[string]bool{}
+
+	for _, code := range strings.Split(list, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, fmt.Errorf("geoip: %q is not a two-letter country code", code)
+		}
+		countries[code] = true
+	}
+
+	return countries, nil
+}
@@ -0,0 +1,35 @@
+package geoip
+
+import (
+	"path/filepath"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestParseCountries(t *testing.T) {
+
+	t.Parallel()
+
+	countries, err := ParseCountries(" cn, RU,,de ")
+	assert.NilError(t, err)
+	assert.Equal(t, len(countries), 3)
+	assert.Equal(t, countries["CN"] && countries["RU"] && countries["DE"], true)
+
+	countries, err = ParseCountries("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(countries), 0)
+
+	for _, list := range []string{"CHN", "C", "C1", "DE,Germany"} {
+		_, err := ParseCountries(list)
+		assert.Equal(t, err != nil, true)
+	}
+}
+
+func TestOpen(t *testing.T) {
+
+	t.Parallel()
+
+	_, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Equal(t, err != nil, true)
+}
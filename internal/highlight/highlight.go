@@ -0,0 +1,66 @@
+// Package highlight renders snippet content as HTML with its syntax colored and its lines
+// numbered, so that pages show highlighted code without running scripts in the browser.
+package highlight
+
+import (
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Style is the color scheme of highlighted code.
+const Style = "github"
+
+// LinePrefix prefixes the IDs of line numbers, so that "#L10" links to line 10.
+const LinePrefix = "L"
+
+// options write highlighted code with CSS classes rather than inline styles, which the
+// Content-Security-Policy doesn't allow, and with line numbers that link to their line. The classes
+// are styled by CSS.
+var options = []html.Option{html.WithClasses(true), html.WithLineNumbers(true), html.WithLinkableLineNumbers(true, LinePrefix)}
+
+// formatter writes highlighted code without marked lines.
+var formatter = html.New(options...)
+
+// HTML returns content as a highlighted, escaped HTML block with numbered lines, marking the lines
+// in the given ranges of first and last line numbers. The language is a language as named by
+// langdetect; content in languages it doesn't know, or without one, is shown as plain text.
+func HTML(language, content string, marked ...[2]int) string {
+	lexer := lexers.Get(language)
+	if lexer == nil || language == "" {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	// Browsers submit textareas with CRLF line endings.
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	f := formatter
+	if len(marked) > 0 {
+		f = html.New(append(options, html.HighlightLines(marked))...)
+	}
+
+	var b strings.Builder
+	iterator, err := lexer.Tokenise(nil, content)
+	if err == nil {
+		err = f.Format(&b, styles.Get(Style), iterator)
+	}
+	if err != nil {
+		// Lexers don't fail on input they don't understand, but if one does, show the content as
+		// plain text rather than not at all.
+		b.Reset()
+		iterator, _ = lexers.Fallback.Tokenise(nil, content)
+		f.Format(&b, styles.Get(Style), iterator)
+	}
+
+	return b.String()
+}
+
+// WriteCSS writes the CSS for the classes of highlighted code.
+func WriteCSS(w io.Writer) error {
+	return formatter.WriteCSS(w, styles.Get(Style))
+}
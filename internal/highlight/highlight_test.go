@@ -0,0 +1,61 @@
+package highlight
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestHTML(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     []string
+	}{
+		{"Go", "go", "package main\r\n\r\nfunc main() {}\r\n", []string{`<span class="kn">package</span>`, `<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`}},
+		{"Escaped", "html", "<script>alert(1)</script>", []string{"&lt;", "alert"}},
+		{"Unknown language", "klingon", "<b>Qapla'</b>", []string{"&lt;b&gt;Qapla&#39;&lt;/b&gt;", `<span class="ln" id="L1">`}},
+		{"No language", "", "a < b", []string{"a &lt; b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTML(tt.language, tt.content)
+			assert.Equal(t, strings.HasPrefix(got, `<pre class="chroma">`), true)
+			assert.Equal(t, strings.Contains(got, "<script>"), false)
+			for _, want := range tt.want {
+				assert.StringContains(t, got, want)
+			}
+		})
+	}
+}
+
+func TestHTMLMarked(t *testing.T) {
+
+	t.Parallel()
+
+	got := HTML("", "one\ntwo\nthree\nfour\n", [2]int{2, 3})
+	assert.Equal(t, strings.Count(got, `<span class="line hl">`), 2)
+	assert.StringContains(t, got, `<span class="line hl"><span class="ln" id="L2">`)
+	assert.StringContains(t, got, `<span class="line"><span class="ln" id="L4">`)
+}
+
+// TestCSS checks that the stylesheet served with the pages is up to date with the style.
+func TestCSS(t *testing.T) {
+
+	t.Parallel()
+
+	var b bytes.Buffer
+	assert.NilError(t, WriteCSS(&b))
+
+	served, err := os.ReadFile("../../ui/static/css/highlight.css")
+	assert.NilError(t, err)
+	assert.StringContains(t, string(served), b.String())
+}
@@ -0,0 +1,235 @@
+// Package htmlmin shrinks HTML as it's written, by collapsing runs of whitespace between tags and
+// in text to a single space and dropping comments. It doesn't parse the document: tags are copied
+// unchanged, and so is the content of the elements whose whitespace matters (pre, textarea, script
+// and style), which makes it safe for pages whose markup it knows nothing about.
+package htmlmin
+
+import (
+	"bytes"
+	"io"
+)
+
+// rawElements are the elements whose content is copied as is.
+var rawElements = map[string]bool{
+	"pre":      true,
+	"textarea": true,
+	"script":   true,
+	"style":    true,
+}
+
+// maxTagName is the length of the longest name in rawElements; longer tag names aren't collected.
+const maxTagName = 8
+
+// The states of a Writer.
+const (
+	stateText    = iota // Between tags: whitespace is collapsed.
+	stateOpen           // After a '<', until it's known whether a comment starts.
+	stateTag            // Inside a tag: copied as is.
+	stateComment        // Inside a comment: dropped.
+	stateRaw            // Inside a raw element: copied as is until its closing tag.
+)
+
+// commentStart opens a comment.
+const commentStart = "<!--"
+
+// Writer minifies the HTML written to it and writes the result to an underlying writer. Documents
+// may be written in chunks of any size; Close must be called at the end to write what's left.
+type Writer struct {
+	w   io.Writer
+	out bytes.Buffer // out collects the output of a Write before it's passed on.
+
+	state     int
+	space     bool   // space reports whether whitespace was skipped since the last byte written in text.
+	wroteText bool   // wroteText reports whether anything was written, so that leading whitespace is dropped.
+	open      []byte // open holds the start of a tag or comment in stateOpen.
+
+	tagName   []byte // tagName collects the name of the current tag.
+	nameDone  bool   // nameDone reports whether the whole name is in tagName.
+	closing   bool   // closing reports whether the current tag is an end tag.
+	quote     byte   // quote is the quote of the attribute value the tag is in, or 0.
+	dashes    int    // dashes counts the '-' just seen in a comment.
+	rawCloser []byte // rawCloser is the end tag that ends the raw element, such as "</pre".
+	matched   int    // matched is how much of rawCloser was just seen.
+}
+
+// NewWriter returns a Writer that writes minified HTML to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write minifies p. It reports len(p) written unless the underlying writer fails.
+func (m *Writer) Write(p []byte) (int, error) {
+	m.out.Reset()
+
+	for _, c := range p {
+		m.writeByte(c)
+	}
+
+	if _, err := m.w.Write(m.out.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close writes the start of a tag still being looked at. It doesn't close the underlying writer.
+// Whitespace at the end of the document is dropped.
+func (m *Writer) Close() error {
+	if m.state != stateOpen {
+		return nil
+	}
+
+	m.out.Reset()
+	m.flushSpace()
+	m.out.Write(m.open)
+	m.open = m.open[:0]
+	m.state = stateText
+
+	_, err := m.w.Write(m.out.Bytes())
+
+	return err
+}
+
+// writeByte moves the state machine on by one byte of input.
+func (m *Writer) writeByte(c byte) {
+	switch m.state {
+	case stateText:
+		switch {
+		case isSpace(c):
+			m.space = true
+		case c == '<':
+			// Whitespace before a comment is kept pending, since the comment goes away.
+			m.open = append(m.open[:0], c)
+			m.state = stateOpen
+		default:
+			m.flushSpace()
+			m.out.WriteByte(c)
+		}
+
+	case stateOpen:
+		m.open = append(m.open, c)
+		switch {
+		case string(m.open) == commentStart:
+			m.open = m.open[:0]
+			m.dashes = 0
+			m.state = stateComment
+		case !bytes.HasPrefix([]byte(commentStart), m.open):
+			// Not a comment: replay what was held back as the start of a tag.
+			m.flushSpace()
+			m.startTag()
+			for _, b := range m.open {
+				m.tagByte(b)
+			}
+			m.open = m.open[:0]
+		}
+
+	case stateTag:
+		m.tagByte(c)
+
+	case stateComment:
+		switch {
+		case c == '>' && m.dashes >= 2:
+			m.state = stateText
+		case c == '-':
+			m.dashes++
+		default:
+			m.dashes = 0
+		}
+
+	case stateRaw:
+		m.out.WriteByte(c)
+		switch {
+		case lower(c) == m.rawCloser[m.matched]:
+			m.matched++
+		case c == '<':
+			m.matched = 1
+		default:
+			m.matched = 0
+		}
+		if m.matched == len(m.rawCloser) {
+			// The rest of the end tag is copied like any tag.
+			m.state = stateTag
+			m.tagName = m.tagName[:0]
+			m.nameDone = true
+			m.closing = true
+			m.quote = 0
+		}
+	}
+}
+
+// startTag begins copying a tag.
+func (m *Writer) startTag() {
+	m.state = stateTag
+	m.tagName = m.tagName[:0]
+	m.nameDone = false
+	m.closing = false
+	m.quote = 0
+}
+
+// tagByte copies a byte of a tag, collecting its name and watching for its end.
+func (m *Writer) tagByte(c byte) {
+	m.out.WriteByte(c)
+	m.wroteText = true
+
+	if m.quote != 0 {
+		if c == m.quote {
+			m.quote = 0
+		}
+		return
+	}
+
+	switch {
+	case c == '<' && len(m.tagName) == 0 && !m.closing:
+		// The '<' that opened the tag.
+	case c == '/' && len(m.tagName) == 0 && !m.nameDone:
+		m.closing = true
+	case c == '>':
+		m.endTag()
+	case c == '"' || c == '\'':
+		m.nameDone = true
+		m.quote = c
+	case isSpace(c) || c == '/':
+		m.nameDone = true
+	case !m.nameDone:
+		if len(m.tagName) < maxTagName+1 {
+			m.tagName = append(m.tagName, lower(c))
+		}
+	}
+}
+
+// endTag leaves a tag, for the content of a raw element if it opened one.
+func (m *Writer) endTag() {
+	m.state = stateText
+	m.space = false
+
+	name := string(m.tagName)
+	if m.closing || !rawElements[name] {
+		return
+	}
+
+	m.rawCloser = append(append(m.rawCloser[:0], "</"...), name...)
+	m.matched = 0
+	m.state = stateRaw
+}
+
+// flushSpace writes the single space standing for the whitespace skipped in text, if any.
+func (m *Writer) flushSpace() {
+	if m.space && m.wroteText {
+		m.out.WriteByte(' ')
+	}
+	m.space = false
+	m.wroteText = true
+}
+
+// isSpace reports whether c is HTML whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// lower returns the lower case of an ASCII letter, and other bytes unchanged.
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
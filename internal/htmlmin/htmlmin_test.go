@@ -0,0 +1,52 @@
+package htmlmin
+
+import (
+	"bytes"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Whitespace", "\n<p>\n    Hello,\n\t world!  </p>\n", "<p> Hello, world! </p>"},
+		{"Between tags", "<div>\n    <a href='/'>Home</a>\n    <a href='/x'>X</a>\n</div>", "<div> <a href='/'>Home</a> <a href='/x'>X</a> </div>"},
+		{"Comments", "<!-- The title -->\n<h1>Title</h1><!--\n multi\n line -->\n<p>x</p>", "<h1>Title</h1> <p>x</p>"},
+		{"Comment with dashes", "a<!-- a -- b --->b", "ab"},
+		{"Doctype", "<!doctype html>\n<html lang='en'>", "<!doctype html> <html lang='en'>"},
+		{"Attributes", "<input  value='a   b >  c'   name=\"x\">", "<input  value='a   b >  c'   name=\"x\">"},
+		{"Pre", "<pre><code>func main() {\n\t<!-- kept -->\n}\n</code></pre>\n\n<p>after</p>", "<pre><code>func main() {\n\t<!-- kept -->\n}\n</code></pre> <p>after</p>"},
+		{"Upper case", "<PRE class='x'>a\n  b</Pre > <p>c</p>", "<PRE class='x'>a\n  b</Pre > <p>c</p>"},
+		{"Script", "<script>\nif (a < b) {\n  x()\n}\n</script>", "<script>\nif (a < b) {\n  x()\n}\n</script>"},
+		{"Textarea", "<textarea name='content'>  keep\n  this</textarea>", "<textarea name='content'>  keep\n  this</textarea>"},
+		{"Prefix of raw name", "<preview>\n  a\n</preview>", "<preview> a </preview>"},
+		{"Unfinished tag", "text <", "text <"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			m := NewWriter(&buf)
+			_, err := m.Write([]byte(tt.in))
+			assert.NilError(t, err)
+			assert.NilError(t, m.Close())
+			assert.Equal(t, buf.String(), tt.want)
+
+			// Writing a byte at a time gives the same result as writing everything at once.
+			buf.Reset()
+			m = NewWriter(&buf)
+			for i := 0; i < len(tt.in); i++ {
+				_, err := m.Write([]byte{tt.in[i]})
+				assert.NilError(t, err)
+			}
+			assert.NilError(t, m.Close())
+			assert.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,305 @@
+// Package langdetect guesses the programming language of a snippet from its file name and content,
+// with the kind of heuristics linguist and enry use: the file name first, then an interpreter
+// line, then the shape of the content, and finally keywords and idioms typical of each language.
+package langdetect
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxScan is the number of bytes of content looked at. The start of a file says enough about its
+// language, and it bounds the time spent on large snippets.
+const maxScan = 64 * 1024
+
+// minScore is the score the best language needs for the content heuristics to settle on it.
+const minScore = 3
+
+// extensions maps file extensions to languages.
+var extensions = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".pyw":  "python",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".jsx":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".cxx":  "cpp",
+	".hpp":  "cpp",
+	".cs":   "csharp",
+	".rs":   "rust",
+	".rb":   "ruby",
+	".php":  "php",
+	".sql":  "sql",
+	".sh":   "bash",
+	".bash": "bash",
+	".zsh":  "bash",
+	".html": "html",
+	".htm":  "html",
+	".css":  "css",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".md":   "markdown",
+	".txt":  "text",
+}
+
+// filenames maps file names without a telling extension to languages.
+var filenames = map[string]string{
+	"dockerfile":    "dockerfile",
+	"makefile":      "makefile",
+	"gnumakefile":   "makefile",
+	"gemfile":       "ruby",
+	"rakefile":      "ruby",
+	".bashrc":       "bash",
+	".bash_profile": "bash",
+	".zshrc":        "bash",
+}
+
+// interpreters maps the programs of interpreter lines ("#!/usr/bin/env python3") to languages.
+var interpreters = map[string]string{
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"ruby":    "ruby",
+	"php":     "php",
+	"sh":      "bash",
+	"bash":    "bash",
+	"zsh":     "bash",
+	"dash":    "bash",
+}
+
+// rule adds weight to a language's score if the content matches pattern.
+type rule struct {
+	language string
+	weight   int
+	pattern  *regexp.Regexp
+}
+
+// rules are the keywords and idioms of each language. A language only scores once per rule, so
+// long files of one idiom don't drown out the others.
+var rules = []rule{
+	{"go", 4, regexp.MustCompile(`(?m)^package [a-z_][a-z0-9_]*\s*$`)},
+	{"go", 2, regexp.MustCompile(`(?m)^func (\([^)]*\) )?[A-Za-z_]\w*\(`)},
+	{"go", 2, regexp.MustCompile(`:= `)},
+	{"go", 1, regexp.MustCompile(`(?m)^import \($`)},
+	{"go", 1, regexp.MustCompile(`\bif err != nil\b`)},
+
+	{"python", 3, regexp.MustCompile(`(?m)^\s*def \w+\(.*\)( -> [^:]+)?:\s*$`)},
+	{"python", 2, regexp.MustCompile(`(?m)^\s*(from [\w.]+ )?import [\w.]+( as \w+)?\s*$`)},
+	{"python", 2, regexp.MustCompile(`(?m)^\s*class \w+(\(.*\))?:\s*$`)},
+	{"python", 2, regexp.MustCompile(`(?m)^if __name__ == ['"]__main__['"]:`)},
+	{"python", 1, regexp.MustCompile(`\bself\.\w+`)},
+	{"python", 1, regexp.MustCompile(`(?m)^\s*(elif|except|with) .*:\s*$`)},
+
+	{"javascript", 3, regexp.MustCompile(`\b(const|let) \w+ = require\(`)},
+	{"javascript", 2, regexp.MustCompile(`\bconsole\.log\(`)},
+	{"javascript", 2, regexp.MustCompile(`\bfunction\s*\w*\s*\([^)]*\)\s*\{`)},
+	{"javascript", 2, regexp.MustCompile(`=>\s*\{`)},
+	{"javascript", 1, regexp.MustCompile(`\b(const|let|var) \w+ = `)},
+	{"javascript", 1, regexp.MustCompile(`\bdocument\.\w+`)},
+
+	{"typescript", 3, regexp.MustCompile(`\b(interface|type) \w+ (=|\{)`)},
+	{"typescript", 3, regexp.MustCompile(`\b\w+\??: (string|number|boolean|any|void)\b`)},
+	{"typescript", 2, regexp.MustCompile(`(?m)^import .* from ['"].*['"];?\s*$`)},
+
+	{"java", 4, regexp.MustCompile(`\bpublic static void main\(String`)},
+	{"java", 2, regexp.MustCompile(`(?m)^\s*(public|private|protected) (static )?(final )?(class|interface|enum|void|int|String|boolean) `)},
+	{"java", 2, regexp.MustCompile(`\bSystem\.out\.print`)},
+	{"java", 2, regexp.MustCompile(`(?m)^import java\.`)},
+
+	{"c", 3, regexp.MustCompile(`(?m)^#include <(stdio|stdlib|string|unistd|stdint)\.h>`)},
+	{"c", 2, regexp.MustCompile(`\bprintf\(`)},
+	{"c", 2, regexp.MustCompile(`\b(malloc|free|sizeof)\(`)},
+	{"c", 1, regexp.MustCompile(`(?m)^int main\(`)},
+
+	{"cpp", 4, regexp.MustCompile(`(?m)^#include <(iostream|vector|string|map|memory|algorithm)>`)},
+	{"cpp", 3, regexp.MustCompile(`\bstd::\w+`)},
+	{"cpp", 2, regexp.MustCompile(`(?m)^using namespace \w+;`)},
+	{"cpp", 1, regexp.MustCompile(`(?m)^int main\(`)},
+
+	{"csharp", 4, regexp.MustCompile(`(?m)^using System(\.\w+)*;`)},
+	{"csharp", 3, regexp.MustCompile(`\bConsole\.Write(Line)?\(`)},
+	{"csharp", 2, regexp.MustCompile(`(?m)^\s*namespace [\w.]+`)},
+	{"csharp", 2, regexp.MustCompile(`\{ get; (private )?set; \}`)},
+
+	{"rust", 4, regexp.MustCompile(`(?m)^\s*(pub )?fn \w+(<[^>]*>)?\(`)},
+	{"rust", 3, regexp.MustCompile(`\blet mut \w+`)},
+	{"rust", 2, regexp.MustCompile(`\bprintln!\(`)},
+	{"rust", 2, regexp.MustCompile(`(?m)^use (std|crate)::`)},
+	{"rust", 1, regexp.MustCompile(`(?m)^\s*impl(<[^>]*>)? \w+`)},
+
+	{"ruby", 3, regexp.MustCompile(`(?m)^\s*def \w+[?!]?(\(.*\))?\s*$`)},
+	{"ruby", 2, regexp.MustCompile(`(?m)^\s*end\s*$`)},
+	{"ruby", 2, regexp.MustCompile(`(?m)^require ['"][\w/]+['"]`)},
+	{"ruby", 2, regexp.MustCompile(`\bputs\b`)},
+	{"ruby", 1, regexp.MustCompile(`\.each do \|`)},
+
+	{"php", 5, regexp.MustCompile(`<\?php`)},
+	{"php", 2, regexp.MustCompile(`\$\w+->\w+`)},
+	{"php", 1, regexp.MustCompile(`\becho \$`)},
+
+	{"sql", 3, regexp.MustCompile(`(?im)^\s*SELECT .+ FROM `)},
+	{"sql", 3, regexp.MustCompile(`(?im)^\s*(CREATE|ALTER|DROP) (TABLE|INDEX|VIEW|DATABASE) `)},
+	{"sql", 3, regexp.MustCompile(`(?im)^\s*INSERT INTO \w+`)},
+	{"sql", 2, regexp.MustCompile(`(?im)^\s*(UPDATE \w+ SET|DELETE FROM) `)},
+	{"sql", 1, regexp.MustCompile(`(?i)\b(WHERE|GROUP BY|ORDER BY|JOIN)\b`)},
+
+	{"bash", 2, regexp.MustCompile(`(?m)^\s*(if|while) \[\[? .* \]\]?; (then|do)`)},
+	{"bash", 2, regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`)},
+	{"bash", 2, regexp.MustCompile(`\$\{?\w+\}?`)},
+	{"bash", 1, regexp.MustCompile(`(?m)^\s*(export|echo|sudo|apt-get|cd|mkdir|curl) `)},
+
+	{"css", 1, regexp.MustCompile(`(?m)^\s*[.#]?[\w-]+(\s*[,>+~]?\s*[.#]?[\w-]+)*\s*\{\s*$`)},
+	{"css", 3, regexp.MustCompile(`(?m)^\s*(color|margin|padding|display|font-size|background(-color)?|border): [^;]+;`)},
+
+	{"yaml", 2, regexp.MustCompile(`(?m)^[\w-]+:(\s+[^{\s].*)?$`)},
+	{"yaml", 2, regexp.MustCompile(`(?m)^\s+- [\w"']`)},
+	{"yaml", 1, regexp.MustCompile(`(?m)^---\s*$`)},
+
+	{"markdown", 3, regexp.MustCompile("(?m)^```")},
+	{"markdown", 2, regexp.MustCompile(`(?m)^#{1,6} \S`)},
+	{"markdown", 2, regexp.MustCompile(`\[[^\]]+\]\(https?://[^)]+\)`)},
+}
+
+// Detect returns the language of a snippet, such as "go" or "python", or an empty string if it
+// can't tell. filename may be empty, or the title of a snippet that doesn't look like a file name.
+func Detect(filename, content string) string {
+	if language := byFilename(filename); language != "" {
+		return language
+	}
+
+	if len(content) > maxScan {
+		content = content[:maxScan]
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	if language := byInterpreter(content); language != "" {
+		return language
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return ""
+	}
+
+	// Content that parses as a JSON object or array is JSON, even if it happens to contain keywords.
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	lower := strings.ToLower(trimmed)
+	if strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html") {
+		return "html"
+	}
+
+	return byRules(content)
+}
+
+// Extension returns the file extension for a language, such as ".py" for "python", or an empty
+// string for unknown languages. Of the extensions of a language, the shortest is used.
+func Extension(language string) string {
+	ext := ""
+	for e, l := range extensions {
+		if l == language && (ext == "" || len(e) < len(ext) || len(e) == len(ext) && e < ext) {
+			ext = e
+		}
+	}
+	return ext
+}
+
+// Languages returns the languages Detect can tell, in alphabetical order.
+func Languages() []string {
+	languages := []string{}
+	for _, m := range []map[string]string{extensions, filenames, interpreters} {
+		for _, l := range m {
+			if !slices.Contains(languages, l) {
+				languages = append(languages, l)
+			}
+		}
+	}
+	slices.Sort(languages)
+
+	return languages
+}
+
+// byFilename returns the language of a file name, or an empty string if it doesn't tell.
+func byFilename(filename string) string {
+	name := strings.ToLower(path.Base(strings.TrimSpace(filename)))
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	if language, ok := filenames[name]; ok {
+		return language
+	}
+
+	return extensions[path.Ext(name)]
+}
+
+// byInterpreter returns the language of the interpreter line at the start of content, or an empty
+// string if there's none or the interpreter is unknown.
+func byInterpreter(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	program := path.Base(fields[0])
+	if program == "env" {
+		// Skip the options of env, as in "#!/usr/bin/env -S python3 -u".
+		program = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				program = f
+				break
+			}
+		}
+	}
+
+	return interpreters[program]
+}
+
+// byRules scores content against the rules and returns the language with the highest score, or an
+// empty string if no language scores enough or two languages tie.
+func byRules(content string) string {
+	scores := map[string]int{}
+	for _, r := range rules {
+		if r.pattern.MatchString(content) {
+			scores[r.language] += r.weight
+		}
+	}
+
+	best, bestScore, tie := "", 0, false
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = language, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+
+	if bestScore < minScore || tie {
+		return ""
+	}
+
+	return best
+}
@@ -0,0 +1,99 @@
+package langdetect
+
+import (
+	"slices"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     string
+	}{
+		{name: "Extension", filename: "main.go", content: "whatever", want: "go"},
+		{name: "Extension case", filename: "Query.SQL", content: "", want: "sql"},
+		{name: "File name", filename: "Dockerfile", content: "FROM golang", want: "dockerfile"},
+		{name: "Title", filename: "Retry loop", content: "package retry\n\nfunc Do(f func() error) error {\n\treturn f()\n}\n", want: "go"},
+		{name: "Interpreter", content: "#!/usr/bin/env python3\nprint('hi')\n", want: "python"},
+		{name: "Interpreter with options", content: "#!/usr/bin/env -S node --no-warnings\nx()\n", want: "javascript"},
+		{name: "Shell", content: "#!/bin/sh\necho hi\n", want: "bash"},
+		{name: "JSON", content: `{"name": "snippetbox", "import": "os"}`, want: "json"},
+		{name: "HTML", content: "<!DOCTYPE html>\n<html></html>", want: "html"},
+		{
+			name:    "Python",
+			content: "import os\n\ndef walk(root):\n    for name in os.listdir(root):\n        yield name\n",
+			want:    "python",
+		},
+		{
+			name:    "JavaScript",
+			content: "const fs = require('fs');\n\nfunction read(path) {\n  console.log(path);\n}\n",
+			want:    "javascript",
+		},
+		{
+			name:    "TypeScript",
+			content: "interface User {\n  name: string;\n}\n\nfunction greet(user: User): void {}\n",
+			want:    "typescript",
+		},
+		{
+			name:    "SQL",
+			content: "SELECT id, title FROM snippets\nWHERE expires > NOW()\nORDER BY id DESC;\n",
+			want:    "sql",
+		},
+		{
+			name:    "Rust",
+			content: "fn main() {\n    let mut total = 0;\n    println!(\"{}\", total);\n}\n",
+			want:    "rust",
+		},
+		{
+			name:    "C",
+			content: "#include <stdio.h>\n\nint main(void) {\n    printf(\"hi\\n\");\n}\n",
+			want:    "c",
+		},
+		{
+			name:    "CRLF",
+			content: "package main\r\n\r\nfunc main() {\r\n\tx := 1\r\n}\r\n",
+			want:    "go",
+		},
+		{name: "Prose", content: "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.", want: ""},
+		{name: "Empty", content: "  \n", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Detect(tt.filename, tt.content), tt.want)
+		})
+	}
+}
+
+func TestExtension(t *testing.T) {
+	tests := map[string]string{
+		"go":       ".go",
+		"python":   ".py",
+		"bash":     ".sh",
+		"yaml":     ".yml",
+		"markdown": ".md",
+		"cobol":    "",
+		"":         "",
+	}
+
+	for language, want := range tests {
+		assert.Equal(t, Extension(language), want)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	t.Parallel()
+
+	languages := Languages()
+
+	assert.Equal(t, slices.IsSorted(languages), true)
+	assert.Equal(t, slices.Contains(languages, "go"), true)
+	assert.Equal(t, slices.Contains(languages, "dockerfile"), true)
+	assert.Equal(t, slices.Contains(languages, ""), false)
+}
@@ -0,0 +1,195 @@
+// Package mailer sends the email the application writes to its users. Messages are rendered from
+// templates that define a "subject" and a "plainBody", and are delivered over SMTP, or written to
+// a log when no SMTP server is configured, which is convenient in development.
+//
+// Suppressing wraps a Sender to stop sending to addresses that bounce: delivery failures are
+// recorded on a SuppressionList, and addresses it suppresses are skipped.
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string // To is the address of the recipient.
+	Subject string // Subject is the subject line.
+	Body    string // Body is the plain text content.
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Render executes the "subject" and "plainBody" templates of the named file in fsys with data and
+// returns the message for the recipient.
+func Render(fsys fs.FS, name, to string, data any) (Message, error) {
+	ts, err := template.New("").ParseFS(fsys, name)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, body bytes.Buffer
+
+	if err := ts.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := ts.ExecuteTemplate(&body, "plainBody", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{To: to, Subject: strings.TrimSpace(subject.String()), Body: strings.TrimSpace(body.String()) + "\n"}, nil
+}
+
+// SMTP sends messages through an SMTP server, using STARTTLS when the server offers it.
+type SMTP struct {
+	Host     string // Host is the name of the server.
+	Port     int    // Port is the submission port of the server, usually 587.
+	Username string // Username authenticates with the server; no authentication is used if it's empty.
+	Password string // Password is the password of Username.
+	From     string // From is the sender address, optionally with a name, such as "Snippetbox <no-reply@example.com>".
+}
+
+// Send implements Sender.
+func (s *SMTP) Send(msg Message) error {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+
+	data, err := format(s.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+
+	if err := smtp.SendMail(addr, auth, from.Address, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+
+	return nil
+}
+
+// Log writes messages to a logger instead of sending them.
+type Log struct {
+	Logger *log.Logger
+}
+
+// Send implements Sender.
+func (l *Log) Send(msg Message) error {
+	l.Logger.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// The kinds of bounce, from SMTP replies and from the webhooks of email providers.
+const (
+	BounceHard      = "hard"      // The address doesn't accept email, such as when the mailbox doesn't exist.
+	BounceSoft      = "soft"      // Delivery failed for now, such as when the mailbox is full.
+	BounceComplaint = "complaint" // The recipient marked a message as spam.
+)
+
+// Bounce is a failure to deliver a message to an address.
+type Bounce struct {
+	Kind   string // Kind is BounceHard, BounceSoft or BounceComplaint.
+	Detail string // Detail explains the failure, such as the reply of the receiving server.
+}
+
+// SuppressionList records bounces and tells which addresses email must no longer be sent to.
+type SuppressionList interface {
+	Suppressed(email string) (bool, error)
+	RecordBounce(email string, bounce Bounce) error
+}
+
+// Suppressing is a Sender that skips recipients on a suppression list, and records the messages
+// the wrapped Sender fails to deliver as bounces.
+type Suppressing struct {
+	Sender Sender          // Sender delivers the messages.
+	List   SuppressionList // List holds the suppressed addresses.
+	Logger *log.Logger     // Logger notes the messages that are skipped.
+}
+
+// Send implements Sender. Messages to suppressed recipients are skipped without an error, since
+// not sending them is the point.
+func (s *Suppressing) Send(msg Message) error {
+	suppressed, err := s.List.Suppressed(msg.To)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		s.Logger.Printf("Email to %s skipped: the address is suppressed after bouncing", msg.To)
+		return nil
+	}
+
+	err = s.Sender.Send(msg)
+	if bounce, ok := Classify(err); ok {
+		if recordErr := s.List.RecordBounce(msg.To, bounce); recordErr != nil {
+			return errors.Join(err, recordErr)
+		}
+	}
+
+	return err
+}
+
+// Classify returns the bounce a failure to send is, if the server rejected the message: a hard
+// bounce for a permanent 5xx reply and a soft bounce for a transient 4xx reply. Other errors, such
+// as a server that can't be reached, say nothing about the recipient.
+func Classify(err error) (Bounce, bool) {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return Bounce{}, false
+	}
+
+	switch {
+	case reply.Code >= 500:
+		return Bounce{Kind: BounceHard, Detail: reply.Error()}, true
+	case reply.Code >= 400:
+		return Bounce{Kind: BounceSoft, Detail: reply.Error()}, true
+	default:
+		return Bounce{}, false
+	}
+}
+
+// format builds the wire format of a message. It refuses recipients and subjects containing line
+// breaks, which would let them add headers.
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return nil, errors.New("mailer: line break in recipient or subject")
+	}
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("mailer: invalid recipient: %w", err)
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"greeting.tmpl": {Data: []byte(`{{define "subject"}}Hello {{.}}{{end}}
+{{define "plainBody"}}
+Hi {{.}},
+
+Welcome!
+{{end}}`)},
+	}
+
+	msg, err := Render(fsys, "greeting.tmpl", "bob@example.com", "Bob")
+	assert.NilError(t, err)
+	assert.Equal(t, msg.To, "bob@example.com")
+	assert.Equal(t, msg.Subject, "Hello Bob")
+	assert.Equal(t, msg.Body, "Hi Bob,\n\nWelcome!\n")
+}
+
+func TestFormat(t *testing.T) {
+
+	t.Parallel()
+
+	date := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	data, err := format("Snippetbox <no-reply@example.com>", Message{To: "bob@example.com", Subject: "Héllo", Body: "One\nTwo\n"}, date)
+	assert.NilError(t, err)
+
+	s := string(data)
+	assert.StringContains(t, s, "To: bob@example.com\r\n")
+	assert.StringContains(t, s, "Subject: =?utf-8?q?H=C3=A9llo?=\r\n")
+	assert.StringContains(t, s, "Date: Wed, 02 Jan 2030 03:04:05 +0000\r\n")
+	assert.Equal(t, strings.HasSuffix(s, "\r\n\r\nOne\r\nTwo\r\n"), true)
+
+	_, err = format("no-reply@example.com", Message{To: "bob@example.com", Subject: "Hi\r\nBcc: eve@example.com"}, date)
+	assert.Equal(t, err != nil, true)
+
+	_, err = format("no-reply@example.com", Message{To: "not an address", Subject: "Hi"}, date)
+	assert.Equal(t, err != nil, true)
+}
+
+// fakeSender fails to send to the addresses in errs with their error.
+type fakeSender struct {
+	errs map[string]error
+	sent []string
+}
+
+func (f *fakeSender) Send(msg Message) error {
+	if err := f.errs[msg.To]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, msg.To)
+	return nil
+}
+
+// fakeList suppresses addresses after a hard bounce.
+type fakeList struct {
+	bounces map[string][]Bounce
+}
+
+func (f *fakeList) Suppressed(email string) (bool, error) {
+	for _, b := range f.bounces[email] {
+		if b.Kind == BounceHard {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeList) RecordBounce(email string, bounce Bounce) error {
+	f.bounces[email] = append(f.bounces[email], bounce)
+	return nil
+}
+
+func TestSuppressing(t *testing.T) {
+
+	t.Parallel()
+
+	sender := &fakeSender{errs: map[string]error{
+		"gone@example.com": fmt.Errorf("mailer: %w", &textproto.Error{Code: 550, Msg: "5.1.1 No such user"}),
+		"full@example.com": fmt.Errorf("mailer: %w", &textproto.Error{Code: 452, Msg: "4.2.2 Mailbox full"}),
+		"down@example.com": errors.New("dial tcp: connection refused"),
+	}}
+	list := &fakeList{bounces: map[string][]Bounce{}}
+	s := &Suppressing{Sender: sender, List: list, Logger: log.New(io.Discard, "", 0)}
+
+	assert.NilError(t, s.Send(Message{To: "bob@example.com"}))
+
+	// A permanent failure suppresses the address, so the next message isn't sent.
+	err := s.Send(Message{To: "gone@example.com"})
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, len(list.bounces["gone@example.com"]), 1)
+	assert.Equal(t, list.bounces["gone@example.com"][0].Kind, BounceHard)
+	assert.StringContains(t, list.bounces["gone@example.com"][0].Detail, "No such user")
+
+	delete(sender.errs, "gone@example.com")
+	assert.NilError(t, s.Send(Message{To: "gone@example.com"}))
+
+	// Transient failures are soft bounces, and failures to reach the server aren't bounces.
+	assert.Equal(t, s.Send(Message{To: "full@example.com"}) != nil, true)
+	assert.Equal(t, list.bounces["full@example.com"][0].Kind, BounceSoft)
+	assert.Equal(t, s.Send(Message{To: "down@example.com"}) != nil, true)
+	assert.Equal(t, len(list.bounces["down@example.com"]), 0)
+
+	assert.Equal(t, strings.Join(sender.sent, ","), "bob@example.com")
+}
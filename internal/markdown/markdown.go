@@ -0,0 +1,226 @@
+// Package markdown renders the subset of Markdown used for the site's content pages as HTML:
+// headings, paragraphs, lists, block quotes, fenced code blocks, horizontal rules, and emphasis,
+// code spans and links within text. Raw HTML isn't supported; it's escaped like any other text, so
+// the output is safe to include in a page whatever the input.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Patterns of the lines that start blocks.
+var (
+	headingLine = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	ruleLine    = regexp.MustCompile(`^ {0,3}([-*_])( *[-*_]){2,} *$`)
+	bulletLine  = regexp.MustCompile(`^ {0,3}[-*+]\s+(.*)$`)
+	orderedLine = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)]\s+(.*)$`)
+	quoteLine   = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	fenceLine   = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([\\w+-]*)")
+)
+
+// Render returns the HTML of a Markdown document.
+func Render(source string) string {
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, lines)
+	return b.String()
+}
+
+// renderBlocks writes the HTML of the blocks made of lines.
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceLine.MatchString(line):
+			m := fenceLine.FindStringSubmatch(line)
+			fence, language := m[1], m[2]
+			i++
+
+			var code []string
+			for ; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			i++ // Skip the closing fence, if there is one.
+
+			if language != "" {
+				b.WriteString(`<pre><code class='language-` + html.EscapeString(language) + `'>`)
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			for _, c := range code {
+				b.WriteString(html.EscapeString(c) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case headingLine.MatchString(line):
+			m := headingLine.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case ruleLine.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case quoteLine.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && quoteLine.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case bulletLine.MatchString(line):
+			i = renderList(b, lines, i, bulletLine, "ul")
+
+		case orderedLine.MatchString(line):
+			i = renderList(b, lines, i, orderedLine, "ol")
+
+		default:
+			// A paragraph runs until a blank line or the start of another block.
+			var text []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && (len(text) == 0 || !startsBlock(lines[i])); i++ {
+				text = append(text, strings.TrimSpace(lines[i]))
+			}
+			b.WriteString("<p>" + renderInline(strings.Join(text, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// renderList writes the list whose items start with lines matching item, from lines[i] on, and
+// returns the index of the line after it. Indented lines continue the item above them.
+func renderList(b *strings.Builder, lines []string, i int, item *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag + ">\n")
+
+	var text []string
+	flush := func() {
+		if text != nil {
+			b.WriteString("<li>" + renderInline(strings.Join(text, "\n")) + "</li>\n")
+		}
+		text = nil
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := item.FindStringSubmatch(line); m != nil {
+			flush()
+			text = []string{m[len(m)-1]}
+			continue
+		}
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+
+	flush()
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// startsBlock reports whether line starts a block that interrupts a paragraph.
+func startsBlock(line string) bool {
+	return headingLine.MatchString(line) || ruleLine.MatchString(line) || quoteLine.MatchString(line) ||
+		bulletLine.MatchString(line) || orderedLine.MatchString(line) || fenceLine.MatchString(line)
+}
+
+// renderInline returns the HTML of the text of a block, with its code spans, links and emphasis.
+func renderInline(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()#+-.!>", text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				b.WriteString("<code>" + html.EscapeString(text[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		case c == '[':
+			if label, url, n, ok := parseLink(text[i:]); ok {
+				if href, ok := safeURL(url); ok {
+					b.WriteString("<a href='" + html.EscapeString(href) + "'>" + renderInline(label) + "</a>")
+				} else {
+					b.WriteString(renderInline(label))
+				}
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_' && (i == 0 || !isWordByte(text[i-1])):
+			// Underscores inside words, as in snake_case, aren't emphasis.
+			delim := text[i : i+1]
+			tag := "em"
+			if strings.HasPrefix(text[i:], delim+delim) {
+				delim += delim
+				tag = "strong"
+			}
+			start := i + len(delim)
+			if end := strings.Index(text[start:], delim); end > 0 && text[start] != ' ' {
+				b.WriteString("<" + tag + ">" + renderInline(text[start:start+end]) + "</" + tag + ">")
+				i = start + end + len(delim)
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+
+	return b.String()
+}
+
+// parseLink parses a link of the form [label](url) at the start of text, and returns its parts
+// and length.
+func parseLink(text string) (label, url string, n int, ok bool) {
+	closing := strings.Index(text, "](")
+	if closing < 0 {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(text[closing+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+
+	return text[1:closing], strings.TrimSpace(text[closing+2 : closing+2+end]), closing + 3 + end, true
+}
+
+// safeURL returns url if it's safe to link to: a web or mail address, or a path on the site.
+// Other schemes, such as javascript:, are refused.
+func safeURL(url string) (string, bool) {
+	lower := strings.ToLower(url)
+	for _, prefix := range []string{"http://", "https://", "mailto:", "/", "#"} {
+		if strings.HasPrefix(lower, prefix) {
+			return url, true
+		}
+	}
+
+	// Relative paths have no scheme before their first slash.
+	if url != "" && !strings.Contains(strings.SplitN(url, "/", 2)[0], ":") {
+		return url, true
+	}
+
+	return "", false
+}
+
+// isWordByte reports whether c is an ASCII letter or digit.
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,42 @@
+package markdown
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{name: "Heading", source: "# About *us* #", want: "<h1>About <em>us</em></h1>\n"},
+		{name: "Paragraphs", source: "One\r\nline.\n\nTwo.", want: "<p>One\nline.</p>\n<p>Two.</p>\n"},
+		{name: "Emphasis", source: "**bold**, *it* and _it_ but snake_case_name", want: "<p><strong>bold</strong>, <em>it</em> and <em>it</em> but snake_case_name</p>\n"},
+		{name: "Code span", source: "Run `go <test>`.", want: "<p>Run <code>go &lt;test&gt;</code>.</p>\n"},
+		{name: "Escapes", source: `\*not emphasis\*`, want: "<p>*not emphasis*</p>\n"},
+		{name: "Link", source: "See [the *docs*](https://go.dev/doc).", want: "<p>See <a href='https://go.dev/doc'>the <em>docs</em></a>.</p>\n"},
+		{name: "Relative link", source: "[Home](/) and [terms](terms)", want: "<p><a href='/'>Home</a> and <a href='terms'>terms</a></p>\n"},
+		{name: "Unsafe link", source: "[click](javascript:alert(1))", want: "<p>click)</p>\n"},
+		{name: "Raw HTML", source: "<script>alert('x')</script>", want: "<p>&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;</p>\n"},
+		{name: "Bullet list", source: "- one\n  continued\n* two\n\nAfter", want: "<ul>\n<li>one\ncontinued</li>\n<li>two</li>\n</ul>\n<p>After</p>\n"},
+		{name: "Ordered list", source: "1. one\n2) two", want: "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{name: "Block quote", source: "> Quoted\n> **text**", want: "<blockquote>\n<p>Quoted\n<strong>text</strong></p>\n</blockquote>\n"},
+		{name: "Rule", source: "Above\n\n---\n\nBelow", want: "<p>Above</p>\n<hr>\n<p>Below</p>\n"},
+		{name: "Heading interrupts paragraph", source: "Text\n## Next", want: "<p>Text</p>\n<h2>Next</h2>\n"},
+		{name: "Fenced code", source: "```go\nif a < b {\n\n}\n```\nAfter", want: "<pre><code class='language-go'>if a &lt; b {\n\n}\n</code></pre>\n<p>After</p>\n"},
+		{name: "Unclosed fence", source: "~~~\ncode", want: "<pre><code>code\n</code></pre>\n"},
+		{name: "Unclosed emphasis", source: "2 * 3 = 6 and **oops", want: "<p>2 * 3 = 6 and **oops</p>\n"},
+		{name: "Empty", source: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Render(tt.source), tt.want)
+		})
+	}
+}
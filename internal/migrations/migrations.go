@@ -0,0 +1,201 @@
+// Package migrations keeps the database schema up to date. Migrations are SQL files embedded from
+// the sql directory and named NNNN_description.sql; they're applied in name order and recorded in
+// the schema_migrations table so that each runs once.
+//
+// To change the schema, add a new file with the next number rather than editing an existing one.
+package migrations
+
+import (
+	"bufio"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Migration is a single schema change.
+type Migration struct {
+	Version    string   // Version is the file name without the .sql extension, such as "0001_initial".
+	Statements []string // Statements are the SQL statements of the migration in order.
+}
+
+// All returns every embedded migration in the order they're applied.
+func All() ([]Migration, error) {
+	names, err := fs.Glob(files, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	migrations := []Migration{}
+
+	for _, name := range names {
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "sql/"), ".sql")
+		migrations = append(migrations, Migration{Version: version, Statements: split(string(data))})
+	}
+
+	return migrations, nil
+}
+
+// split breaks a migration file into statements. Lines starting with -- are comments, and a
+// statement ends with a line ending in a semicolon.
+func split(script string) []string {
+	statements := []string{}
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(line, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+// Querier is implemented by both *sql.DB and *sql.Tx, so that the applied migrations can be read
+// inside a transaction.
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// ensureTable creates the schema_migrations table if it doesn't exist.
+func ensureTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version VARCHAR(255) NOT NULL PRIMARY KEY,
+    applied DATETIME NOT NULL
+)`)
+	return err
+}
+
+// applied returns the set of versions recorded in the schema_migrations table. A missing table
+// means that no migrations have been applied.
+func applied(q Querier) (map[string]bool, error) {
+	rows, err := q.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1146 {
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+
+	return versions, rows.Err()
+}
+
+// Applied returns the versions of the migrations that have been applied to the database, in order.
+func Applied(q Querier) ([]string, error) {
+	done, err := applied(q)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for version := range done {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	return versions, nil
+}
+
+// Pending returns the versions of the migrations that haven't been applied to the database yet. It
+// only reads from the database, so it works with users that can't change the schema.
+func Pending(db *sql.DB) ([]string, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []string{}
+	for _, m := range all {
+		if !done[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+
+	return pending, nil
+}
+
+// Apply runs every pending migration in order and returns the versions it applied. MySQL commits
+// schema changes implicitly, so a migration that fails halfway isn't rolled back; it's left
+// unrecorded and has to be fixed up by hand.
+func Apply(db *sql.DB) ([]string, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+
+	for _, m := range all {
+		if done[m.Version] {
+			continue
+		}
+
+		for i, stmt := range m.Statements {
+			if _, err := db.Exec(stmt); err != nil {
+				return versions, fmt.Errorf("migrations: %s statement %d: %w", m.Version, i+1, err)
+			}
+		}
+
+		_, err := db.Exec(`INSERT INTO schema_migrations (version, applied) VALUES (?, UTC_TIMESTAMP())`, m.Version)
+		if err != nil {
+			return versions, err
+		}
+
+		versions = append(versions, m.Version)
+	}
+
+	return versions, nil
+}
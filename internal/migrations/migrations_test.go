@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestSplit(t *testing.T) {
+
+	t.Parallel()
+
+	script := `-- A comment; with a semicolon.
+CREATE TABLE a (
+    id INTEGER
+);
+
+-- Another comment.
+INSERT INTO a VALUES (1);
+INSERT INTO a VALUES (2)`
+
+	statements := split(script)
+
+	assert.Equal(t, len(statements), 3)
+	assert.Equal(t, statements[0], "CREATE TABLE a (\n    id INTEGER\n);")
+	assert.Equal(t, statements[1], "INSERT INTO a VALUES (1);")
+	assert.Equal(t, statements[2], "INSERT INTO a VALUES (2)")
+}
+
+func TestAll(t *testing.T) {
+
+	t.Parallel()
+
+	all, err := All()
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(all) > 0, true)
+	assert.Equal(t, all[0].Version, "0001_initial")
+
+	for i := 1; i < len(all); i++ {
+		assert.Equal(t, all[i-1].Version < all[i].Version, true)
+	}
+}
@@ -0,0 +1,49 @@
+-- The schema as it was when migrations were introduced. Tables are only created if they don't
+-- exist, so that databases set up with the scripts in /sql can adopt migrations; run the matching
+-- alter_*.sql scripts first if such a database is older.
+
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    ulid CHAR(26) NULL,
+    title VARCHAR(100) NOT NULL,
+    content MEDIUMBLOB NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    updated DATETIME NOT NULL,
+    updated_by INTEGER NULL,
+    owner_id INTEGER NULL,
+    held BOOLEAN NOT NULL DEFAULT FALSE,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    creator_ip VARCHAR(45) NULL,
+    creator_ua VARCHAR(255) NULL,
+    INDEX idx_snippets_created (created),
+    UNIQUE INDEX idx_snippets_ulid (ulid),
+    INDEX idx_snippets_owner (owner_id)
+);
+
+CREATE TABLE IF NOT EXISTS snippet_views (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day),
+    INDEX idx_snippet_views_day (day)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    username VARCHAR(30) NULL,
+    email VARCHAR(255) NOT NULL,
+    hashed_password CHAR(60) NOT NULL,
+    created DATETIME NOT NULL,
+    admin BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT users_uc_email UNIQUE (email),
+    CONSTRAINT users_uc_username UNIQUE (username)
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL,
+    INDEX sessions_expiry_idx (expiry)
+);
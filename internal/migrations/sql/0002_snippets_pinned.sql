@@ -0,0 +1,3 @@
+-- Admins can pin snippets so that they're listed first on the home page.
+
+ALTER TABLE snippets ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- The trending ranking, rebuilt periodically from the recent views of each snippet.
+
+CREATE TABLE snippet_trending (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    score DOUBLE NOT NULL,
+    computed DATETIME NOT NULL,
+    INDEX idx_snippet_trending_score (score)
+);
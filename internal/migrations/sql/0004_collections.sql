@@ -0,0 +1,19 @@
+-- Collections let users group their snippets. A collection is private to its owner unless it's
+-- made public.
+
+CREATE TABLE collections (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    owner_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    created DATETIME NOT NULL,
+    CONSTRAINT collections_uc_owner_name UNIQUE (owner_id, name)
+);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    added DATETIME NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id),
+    INDEX idx_collection_snippets_snippet (snippet_id)
+);
@@ -0,0 +1,14 @@
+-- Private snippets are only visible to their owner, admins and holders of a share link. Share
+-- links expire; the tokens in them are signed by the server rather than stored, and deleting a
+-- link's row revokes it.
+
+ALTER TABLE snippets ADD COLUMN private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE share_links (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    INDEX idx_share_links_snippet (snippet_id)
+);
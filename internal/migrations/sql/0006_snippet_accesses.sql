@@ -0,0 +1,13 @@
+-- The access log shows owners who has been reading their snippets. Which columns are filled in
+-- depends on the -access-log setting of the server, and rows are deleted after the retention
+-- period.
+
+CREATE TABLE snippet_accesses (
+    id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    accessed DATETIME NOT NULL,
+    network VARCHAR(50) NOT NULL DEFAULT '',
+    referrer VARCHAR(255) NOT NULL DEFAULT '',
+    INDEX idx_snippet_accesses_snippet (snippet_id, accessed),
+    INDEX idx_snippet_accesses_accessed (accessed)
+);
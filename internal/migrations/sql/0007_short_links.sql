@@ -0,0 +1,11 @@
+-- Short links redirect a compact code to a snippet and count how often they're followed. Codes
+-- are base62, so the column compares them case-sensitively. Each snippet has at most one code.
+
+CREATE TABLE short_links (
+    code VARCHAR(16) CHARACTER SET ascii COLLATE ascii_bin NOT NULL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    clicks INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT short_links_uc_snippet UNIQUE (snippet_id)
+);
@@ -0,0 +1,36 @@
+-- Organizations let a team own snippets together. Members have a role: owners manage the
+-- membership, members read and write the organization's snippets. People join by accepting an
+-- invitation sent to their email address; only a hash of each invitation token is stored.
+
+CREATE TABLE organizations (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(50) NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT organizations_uc_slug UNIQUE (slug)
+);
+
+CREATE TABLE organization_members (
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    joined DATETIME NOT NULL,
+    PRIMARY KEY (org_id, user_id),
+    INDEX idx_organization_members_user (user_id)
+);
+
+CREATE TABLE organization_invitations (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    org_id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(10) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    invited_by INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    CONSTRAINT organization_invitations_uc_token UNIQUE (token_hash),
+    INDEX idx_organization_invitations_org (org_id)
+);
+
+ALTER TABLE snippets ADD COLUMN org_id INTEGER NULL;
+CREATE INDEX idx_snippets_org ON snippets (org_id);
@@ -0,0 +1,12 @@
+-- Permission rules refine what the members of an organization may do with one of its snippets.
+-- A rule applies either to one member (user_id set, role empty) or to every member with a role
+-- (role set, user_id 0); a member's own rule wins over the rule for their role. Without a rule,
+-- members can read and write the organization's snippets.
+
+CREATE TABLE snippet_permissions (
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    role VARCHAR(10) NOT NULL DEFAULT '',
+    level VARCHAR(10) NOT NULL,
+    PRIMARY KEY (snippet_id, user_id, role)
+);
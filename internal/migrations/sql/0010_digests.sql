@@ -0,0 +1,9 @@
+-- Users can opt in to a daily or weekly email digest of the activity on their snippets. Users
+-- without a row get no digest. last_sent is claimed before a digest is sent, so that several
+-- servers running the digest job don't send it twice.
+
+CREATE TABLE digest_subscriptions (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    frequency VARCHAR(10) NOT NULL,
+    last_sent DATETIME NULL
+);
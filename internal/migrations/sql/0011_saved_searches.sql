@@ -0,0 +1,17 @@
+-- Users can save searches under a name to run them again later. Saved searches with notify set
+-- email their owner about new matches; last_seen_id is the newest snippet they were checked
+-- against, so only snippets created since are reported.
+
+CREATE TABLE saved_searches (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    query VARCHAR(255) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    notify BOOLEAN NOT NULL DEFAULT FALSE,
+    last_seen_id INTEGER NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL,
+    CONSTRAINT saved_searches_uc_user_name UNIQUE (user_id, name)
+);
+
+CREATE INDEX idx_saved_searches_notify ON saved_searches(notify);
@@ -0,0 +1,14 @@
+-- Each user has at most one draft: the snippet form they're filling in, saved as they type so
+-- that it survives a closed tab or a crashed browser. snippet_id is the snippet being edited, or
+-- 0 for a new snippet. Content is encoded like snippet content. Drafts expire if left alone.
+
+CREATE TABLE snippet_drafts (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL DEFAULT 0,
+    title VARCHAR(255) NOT NULL,
+    content MEDIUMBLOB NOT NULL,
+    updated DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_drafts_expires ON snippet_drafts(expires);
@@ -0,0 +1,8 @@
+-- Fingerprints of snippet content, for telling people about existing copies of what they're about
+-- to post. content_hash is the SHA-256 of the normalized content and simhash is close for nearly
+-- identical content; see internal/fingerprint. simhash is NULL for content too short to compare.
+-- Both are NULL for snippets created before this migration until they're backfilled.
+
+ALTER TABLE snippets ADD COLUMN content_hash CHAR(64) NULL, ADD COLUMN simhash BIGINT NULL;
+
+CREATE INDEX idx_snippets_content_hash ON snippets(content_hash);
@@ -0,0 +1,10 @@
+-- Users can opt in to an email some days before each of their snippets expires. Users without a
+-- row get no reminders. reminded_expires is the expiry a reminder was sent for; it's claimed
+-- before the email is sent, and extending the snippet makes it due for a reminder again.
+
+CREATE TABLE expiry_reminders (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    days INTEGER NOT NULL
+);
+
+ALTER TABLE snippets ADD COLUMN reminded_expires DATETIME NULL;
@@ -0,0 +1,15 @@
+-- Users can download everything stored about them. Archives are built by a background job:
+-- requests start out pending, are claimed by one server while building, and hold the archive,
+-- encoded like snippet content, once ready. Ready archives expire and are purged.
+
+CREATE TABLE data_exports (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    requested DATETIME NOT NULL,
+    claimed DATETIME NULL,
+    completed DATETIME NULL,
+    expires DATETIME NULL,
+    data MEDIUMBLOB NULL,
+    INDEX idx_data_exports_user (user_id)
+);
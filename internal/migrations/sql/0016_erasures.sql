@@ -0,0 +1,12 @@
+-- Users can have their personal data erased. Erasing a user deletes or anonymizes their rows in
+-- every table and leaves a tombstone, so that an admin can later prove the erasure happened. The
+-- tombstone keeps a hash of the email address rather than the address itself.
+CREATE TABLE erasures (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    email_hash CHAR(64) NOT NULL,
+    requested_by INTEGER NOT NULL,
+    erased DATETIME NOT NULL,
+    summary VARCHAR(1000) NOT NULL,
+    INDEX idx_erasures_email_hash (email_hash)
+);
@@ -0,0 +1,24 @@
+-- Admins manage content filter rules in the application, next to the blocklist file, and every
+-- time a rule catches a snippet the hit is recorded for review. Snippets caught by a shadow rule
+-- are held like other snippets, but their author isn't told.
+ALTER TABLE snippets ADD COLUMN shadowed BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE filter_rules (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    action VARCHAR(10) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    pattern VARCHAR(255) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE filter_hits (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    rule_id INTEGER NOT NULL,
+    rule VARCHAR(300) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    INDEX idx_filter_hits_created (created)
+);
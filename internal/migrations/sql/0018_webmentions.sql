@@ -0,0 +1,15 @@
+-- Webmentions other sites send about public snippets. A mention is stored as pending when it's
+-- received, and shown under the snippet once a background job has verified that the source page
+-- links to it. A source sending the same mention again resets it to pending.
+CREATE TABLE webmentions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    source VARCHAR(500) NOT NULL,
+    target VARCHAR(500) NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    title VARCHAR(200) NOT NULL DEFAULT '',
+    received DATETIME NOT NULL,
+    verified DATETIME,
+    UNIQUE INDEX idx_webmentions_snippet_source (snippet_id, source),
+    INDEX idx_webmentions_status (status)
+);
@@ -0,0 +1,13 @@
+-- Admins can take over the session of a user to reproduce a problem they reported. Every
+-- impersonation is recorded with the reason the admin gave, from the moment it starts until the
+-- admin stops it or it times out.
+CREATE TABLE impersonations (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    admin_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    reason VARCHAR(200) NOT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    started DATETIME NOT NULL,
+    ended DATETIME,
+    INDEX idx_impersonations_started (started)
+);
@@ -0,0 +1,11 @@
+-- Addresses email couldn't be delivered to. An address is suppressed, and no longer sent email,
+-- after a hard bounce or a spam complaint, or after repeated soft bounces. Users see the state on
+-- their email page and can ask for email to be sent again once they've fixed their mailbox.
+CREATE TABLE email_suppressions (
+    email VARCHAR(255) NOT NULL PRIMARY KEY,
+    soft_bounces INTEGER NOT NULL DEFAULT 0,
+    last_kind VARCHAR(10) NOT NULL,
+    last_detail VARCHAR(500) NOT NULL,
+    last_bounce DATETIME NOT NULL,
+    suppressed DATETIME
+);
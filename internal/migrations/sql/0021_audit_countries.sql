@@ -0,0 +1,4 @@
+-- With a GeoIP database configured, the audit trails record the country of the client, as an
+-- ISO 3166-1 alpha-2 code. It's empty for events recorded without one.
+ALTER TABLE filter_hits ADD COLUMN country CHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE impersonations ADD COLUMN country CHAR(2) NOT NULL DEFAULT '';
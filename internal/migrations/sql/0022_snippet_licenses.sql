@@ -0,0 +1,3 @@
+-- Authors can put their snippets under a license: the SPDX identifier of a well-known license,
+-- such as MIT, or the name of any other license as they wrote it. Snippets without one are empty.
+ALTER TABLE snippets ADD COLUMN license VARCHAR(100) NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+-- Authors can describe their snippets with their own name/value pairs, such as "os: linux" or
+-- "tested-with: go1.22". A snippet has at most a handful of them, listed in the order the author
+-- wrote them. The index finds the snippets with a given pair for searches.
+CREATE TABLE snippet_metadata (
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(40) NOT NULL,
+    value VARCHAR(200) NOT NULL,
+    PRIMARY KEY (snippet_id, name),
+    INDEX idx_snippet_metadata_name_value (name, value)
+);
@@ -0,0 +1,9 @@
+-- The defaults the snippet form is filled in with for each user. Users without a row get the
+-- site's defaults: a year before the snippet expires, listed, and the language detected.
+
+CREATE TABLE user_preferences (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    default_expires INTEGER NOT NULL,
+    default_private BOOLEAN NOT NULL DEFAULT FALSE,
+    default_language VARCHAR(32) NOT NULL DEFAULT ''
+);
@@ -0,0 +1,9 @@
+-- Statistics of snippet content, computed when it's written, since the stored content may be
+-- compressed and encrypted. They're NULL for snippets written before this migration, whose
+-- statistics are computed from their content when they're loaded until they're written again.
+
+ALTER TABLE snippets
+    ADD COLUMN line_count INTEGER NULL,
+    ADD COLUMN char_count INTEGER NULL,
+    ADD COLUMN byte_size INTEGER NULL,
+    ADD COLUMN reading_seconds INTEGER NULL;
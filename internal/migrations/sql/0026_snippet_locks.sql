@@ -0,0 +1,12 @@
+-- Advisory locks on snippets, taken by a user while they have the edit form of the snippet open.
+-- The form renews the lock while it's open, and it expires soon after it's closed. Locks don't
+-- stop anyone from saving; other editors are only told who else is editing.
+
+CREATE TABLE snippet_locks (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    acquired DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_locks_expires ON snippet_locks(expires);
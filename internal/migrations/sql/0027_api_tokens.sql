@@ -0,0 +1,14 @@
+-- Tokens scripts authenticate to the JSON API with, as a bearer token instead of a session. Only
+-- the hash of a token is stored; the user sees the token once, when it's created. A token either
+-- only reads or also writes, and is deleted when it's revoked.
+CREATE TABLE api_tokens (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    scope VARCHAR(10) NOT NULL,
+    created DATETIME NOT NULL,
+    last_used DATETIME,
+    CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash),
+    INDEX idx_api_tokens_user (user_id)
+);
@@ -0,0 +1,7 @@
+-- Snippets their owner deleted are kept in the trash, hidden everywhere else, until the owner
+-- restores or purges them, or they've been there for the retention period of the purge job.
+
+ALTER TABLE snippets
+    ADD COLUMN deleted_at DATETIME NULL;
+
+CREATE INDEX idx_snippets_owner_deleted ON snippets(owner_id, deleted_at);
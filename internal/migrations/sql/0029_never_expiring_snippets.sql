@@ -0,0 +1,4 @@
+-- Snippets whose author chose to keep them forever have no expiry.
+
+ALTER TABLE snippets
+    MODIFY COLUMN expires DATETIME NULL;
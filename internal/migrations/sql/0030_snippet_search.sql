@@ -0,0 +1,8 @@
+-- Full-text search looks in the title and the content of snippets. Content is stored compressed or
+-- encrypted, so its plain text is kept in search_text for the index, except where content is
+-- encrypted at rest, where only titles are searched.
+
+ALTER TABLE snippets
+    ADD COLUMN search_text MEDIUMTEXT NULL;
+
+CREATE FULLTEXT INDEX idx_snippets_search ON snippets(title, search_text);
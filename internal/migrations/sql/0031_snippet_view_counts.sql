@@ -0,0 +1,9 @@
+-- The total number of views of each snippet, kept next to it so that it can be shown on its page
+-- and snippets can be listed by popularity without adding up their daily views.
+
+ALTER TABLE snippets
+    ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
+
+UPDATE snippets s SET views = (SELECT COALESCE(SUM(v.views), 0) FROM snippet_views v WHERE v.snippet_id = s.id);
+
+CREATE INDEX idx_snippets_views ON snippets(views);
@@ -0,0 +1,9 @@
+-- The snippets users starred, listed on their favorites page and counted on the snippet's page.
+
+CREATE TABLE favorites (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id),
+    INDEX idx_favorites_snippet (snippet_id)
+);
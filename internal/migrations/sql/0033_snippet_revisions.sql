@@ -0,0 +1,16 @@
+-- Every version of a snippet, numbered from 1, so that the changes between two of them can be
+-- shown. The content is stored like the snippet's, compressed or encrypted. Existing snippets
+-- start with their current version.
+
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content MEDIUMBLOB NOT NULL,
+    created DATETIME NOT NULL,
+    created_by INTEGER NULL,
+    PRIMARY KEY (snippet_id, number)
+);
+
+INSERT INTO snippet_revisions (snippet_id, number, title, content, created, created_by)
+    SELECT id, 1, title, content, updated, updated_by FROM snippets;
@@ -0,0 +1,6 @@
+-- Authors can give a snippet a custom slug, which makes it reachable at /s/<slug>. Slugs are unique
+-- across all snippets, including expired ones and those in the trash, until they're purged.
+
+ALTER TABLE snippets
+    ADD COLUMN slug VARCHAR(60) NULL,
+    ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
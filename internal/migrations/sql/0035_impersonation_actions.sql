@@ -0,0 +1,11 @@
+-- Every request an admin makes while impersonating a user is recorded with the impersonation, so
+-- that the audit trail shows what was done and not only when the impersonation started and ended.
+CREATE TABLE impersonation_actions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    impersonation_id INTEGER NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    status SMALLINT NOT NULL,
+    created DATETIME NOT NULL,
+    INDEX idx_impersonation_actions_impersonation (impersonation_id)
+);
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// Access is a single entry of the access log of a snippet.
+type Access struct {
+	SnippetID int       // SnippetID is the ID of the accessed snippet.
+	Accessed  time.Time // Accessed is the time of the access.
+	Network   string    // Network is the coarse location of the client, such as "203.0.113.0/24", or empty if not recorded.
+	Referrer  string    // Referrer is the host of the page that linked to the snippet, or empty if unknown or not recorded.
+}
+
+// AccessModel wraps a sql.DB connection pool and provides methods for the snippet_accesses table.
+type AccessModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock decides which entries are past the retention period. It defaults to the system clock.
+}
+
+type AccessModelInterface interface {
+	Add(accesses []Access) error
+	BySnippet(snippetID int, limit int) ([]*Access, error)
+	Purge(retention time.Duration) (int64, error)
+}
+
+// Add writes a batch of accesses with a single statement.
+func (am *AccessModel) Add(accesses []Access) error {
+
+	if len(accesses) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(accesses))
+	args := make([]any, 0, 4*len(accesses))
+	for i, a := range accesses {
+		placeholders[i] = "(?, ?, ?, ?)"
+		args = append(args, a.SnippetID, a.Accessed.UTC().Truncate(time.Second), a.Network, a.Referrer)
+	}
+
+	stmt := `INSERT INTO snippet_accesses (snippet_id, accessed, network, referrer) VALUES ` + strings.Join(placeholders, ", ")
+
+	_, err := am.DB.Exec(stmt, args...)
+
+	return err
+}
+
+// BySnippet returns the most recent accesses of a snippet, newest first.
+func (am *AccessModel) BySnippet(snippetID int, limit int) ([]*Access, error) {
+
+	stmt := `SELECT snippet_id, accessed, network, referrer FROM snippet_accesses
+    WHERE snippet_id = ? ORDER BY accessed DESC, id DESC LIMIT ?`
+
+	rows, err := am.DB.Query(stmt, snippetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accesses := []*Access{}
+	for rows.Next() {
+		a := &Access{}
+		if err := rows.Scan(&a.SnippetID, &a.Accessed, &a.Network, &a.Referrer); err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, a)
+	}
+
+	return accesses, rows.Err()
+}
+
+// Purge deletes the accesses older than the retention period and returns the number deleted.
+func (am *AccessModel) Purge(retention time.Duration) (int64, error) {
+
+	cutoff := currentTime(am.Clock).Add(-retention)
+
+	res, err := am.DB.Exec(`DELETE FROM snippet_accesses WHERE accessed < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestAccessModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	now := clock.NewFrozen(time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	am := &AccessModel{DB: db, Clock: now}
+
+	err := am.Add([]Access{
+		{SnippetID: 1, Accessed: now.Now().AddDate(0, 0, -40), Network: "203.0.113.0/24"},
+		{SnippetID: 1, Accessed: now.Now().Add(-time.Hour), Referrer: "example.org"},
+		{SnippetID: 2, Accessed: now.Now()},
+	})
+	assert.NilError(t, err)
+
+	accesses, err := am.BySnippet(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(accesses), 2)
+	assert.Equal(t, accesses[0].Referrer, "example.org")
+	assert.Equal(t, accesses[1].Network, "203.0.113.0/24")
+
+	n, err := am.Purge(30 * 24 * time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, n, int64(1))
+
+	accesses, err = am.BySnippet(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(accesses), 1)
+}
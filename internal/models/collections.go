@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// Collection is a named group of snippets belonging to a user.
+type Collection struct {
+	ID       int       // ID is the unique identifier of the collection.
+	OwnerID  int       // OwnerID is the ID of the user who created the collection.
+	Name     string    // Name is unique among the collections of the owner.
+	Public   bool      // Public collections can be viewed by anyone with the link; others only by the owner.
+	Created  time.Time // Created is the time the collection was created.
+	Snippets int       // Snippets is the number of snippets in the collection.
+}
+
+// CollectionModel wraps a sql.DB connection pool and provides methods for the collections and
+// collection_snippets tables.
+type CollectionModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps new collections and additions. It defaults to the system clock.
+}
+
+type CollectionModelInterface interface {
+	Insert(ownerID int, name string, public bool) (int, error)
+	Get(id int) (*Collection, error)
+	ByOwner(ownerID int) ([]*Collection, error)
+	Update(id int, name string, public bool) error
+	Delete(id int) error
+	AddSnippet(collectionID, snippetID int) error
+	RemoveSnippet(collectionID, snippetID int) error
+	SnippetIDs(collectionID int) ([]int, error)
+}
+
+// collectionColumns is the column list selected by every query that returns collections.
+const collectionColumns = `c.id, c.owner_id, c.name, c.public, c.created,
+    (SELECT COUNT(*) FROM collection_snippets cs WHERE cs.collection_id = c.id)`
+
+// Insert creates a collection and returns its ID. It returns ErrDuplicateCollection if the owner
+// already has a collection with the same name.
+func (cm *CollectionModel) Insert(ownerID int, name string, public bool) (int, error) {
+
+	stmt := `INSERT INTO collections (owner_id, name, public, created) VALUES (?, ?, ?, ?)`
+
+	res, err := cm.DB.Exec(stmt, ownerID, name, public, currentTime(cm.Clock))
+	if err != nil {
+		return 0, duplicateCollection(err)
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
+
+// Get returns a collection by its ID.
+func (cm *CollectionModel) Get(id int) (*Collection, error) {
+
+	stmt := `SELECT ` + collectionColumns + ` FROM collections c WHERE c.id = ?`
+
+	c := &Collection{}
+	err := cm.DB.QueryRow(stmt, id).Scan(&c.ID, &c.OwnerID, &c.Name, &c.Public, &c.Created, &c.Snippets)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ByOwner returns the collections of a user ordered by name.
+func (cm *CollectionModel) ByOwner(ownerID int) ([]*Collection, error) {
+
+	stmt := `SELECT ` + collectionColumns + ` FROM collections c WHERE c.owner_id = ? ORDER BY c.name`
+
+	rows, err := cm.DB.Query(stmt, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+	for rows.Next() {
+		c := &Collection{}
+		if err := rows.Scan(&c.ID, &c.OwnerID, &c.Name, &c.Public, &c.Created, &c.Snippets); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	return collections, rows.Err()
+}
+
+// Update renames a collection and sets whether it's public. It returns ErrDuplicateCollection if
+// the owner has another collection with the new name.
+func (cm *CollectionModel) Update(id int, name string, public bool) error {
+
+	_, err := cm.DB.Exec(`UPDATE collections SET name = ?, public = ? WHERE id = ?`, name, public, id)
+
+	return duplicateCollection(err)
+}
+
+// Delete removes a collection. The snippets in it aren't affected.
+func (cm *CollectionModel) Delete(id int) error {
+
+	tx, err := cm.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM collection_snippets WHERE collection_id = ?`, id); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`DELETE FROM collections WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// AddSnippet adds a snippet to a collection. Adding a snippet that's already in the collection
+// does nothing.
+func (cm *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, added) VALUES (?, ?, ?)`
+
+	_, err := cm.DB.Exec(stmt, collectionID, snippetID, currentTime(cm.Clock))
+
+	return err
+}
+
+// RemoveSnippet removes a snippet from a collection.
+func (cm *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+
+	_, err := cm.DB.Exec(`DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`, collectionID, snippetID)
+
+	return err
+}
+
+// SnippetIDs returns the IDs of the snippets in a collection, most recently added first.
+func (cm *CollectionModel) SnippetIDs(collectionID int) ([]int, error) {
+
+	rows, err := cm.DB.Query(`SELECT snippet_id FROM collection_snippets WHERE collection_id = ? ORDER BY added DESC, snippet_id DESC`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// duplicateCollection translates a violation of the unique owner and name constraint into
+// ErrDuplicateCollection.
+func duplicateCollection(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "collections_uc_owner_name") {
+		return ErrDuplicateCollection
+	}
+	return err
+}
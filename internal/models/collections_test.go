@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestCollectionModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	first, err := sm.Insert("First", "a", 30, 0)
+	assert.NilError(t, err)
+	second, err := sm.Insert("Second", "b", 30, 0)
+	assert.NilError(t, err)
+
+	cm := &CollectionModel{DB: db}
+
+	id, err := cm.Insert(1, "Favourites", false)
+	assert.NilError(t, err)
+
+	_, err = cm.Insert(1, "Favourites", true)
+	assert.Equal(t, errors.Is(err, ErrDuplicateCollection), true)
+
+	// Names only have to be unique per owner.
+	_, err = cm.Insert(2, "Favourites", true)
+	assert.NilError(t, err)
+
+	assert.NilError(t, cm.AddSnippet(id, first))
+	assert.NilError(t, cm.AddSnippet(id, second))
+	assert.NilError(t, cm.AddSnippet(id, second))
+
+	c, err := cm.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, c.Name, "Favourites")
+	assert.Equal(t, c.Snippets, 2)
+
+	ids, err := cm.SnippetIDs(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 2)
+
+	assert.NilError(t, cm.RemoveSnippet(id, first))
+	assert.NilError(t, cm.Update(id, "Best", true))
+
+	owned, err := cm.ByOwner(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owned), 1)
+	assert.Equal(t, owned[0].Name, "Best")
+	assert.Equal(t, owned[0].Public, true)
+	assert.Equal(t, owned[0].Snippets, 1)
+
+	assert.NilError(t, cm.Delete(id))
+
+	_, err = cm.Get(id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	assert.Equal(t, errors.Is(cm.Delete(id), ErrNoRecord), true)
+}
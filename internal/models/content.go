@@ -0,0 +1,148 @@
+// Package models contains the application's data models.
+package models
+
+// Import the necessary packages.
+import (
+	"bytes"         // Package for manipulating byte slices.
+	"compress/gzip" // Package for reading and writing gzip compressed data.
+	"errors"        // Package for creating error messages.
+	"fmt"           // Package for formatted I/O.
+	"io"            // Package for basic I/O primitives.
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec identifiers stored in the second byte of encoded content.
+const (
+	CodecNone byte = 0 // CodecNone stores the content as-is after the header.
+	CodecGzip byte = 1 // CodecGzip compresses the content with gzip.
+	CodecZstd byte = 2 // CodecZstd compresses the content with zstd.
+)
+
+// contentFlag marks a content value that starts with a codec header. Rows written before
+// compression was introduced hold plain text and never start with this byte.
+const contentFlag byte = 0x01
+
+// ErrUnknownCodec is returned when encoded content references a codec that isn't supported.
+var ErrUnknownCodec = errors.New("models: unknown content codec")
+
+// zstd encoders and decoders are safe for concurrent use with EncodeAll/DecodeAll, so a single
+// instance of each is shared by all requests.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// ContentCodec controls how snippet content is stored in the database. Content that is at least
+// Threshold bytes long is compressed with Codec and prefixed with a flag byte and the codec ID.
+// Shorter content is stored as plain text, so existing rows and small pastes are left untouched.
+// When Keys is set, the result is then wrapped in an encryption envelope.
+type ContentCodec struct {
+	Threshold int          // Threshold is the minimum content size in bytes to compress. Zero disables compression.
+	Codec     byte         // Codec is the compression codec used for content above the threshold.
+	Keys      KeyEncrypter // Keys encrypts content at rest. Nil stores content unencrypted.
+}
+
+// ParseCodec returns the codec ID for a codec name as used in the configuration.
+func ParseCodec(name string) (byte, error) {
+	switch name {
+	case "none", "":
+		return CodecNone, nil
+	case "gzip":
+		return CodecGzip, nil
+	case "zstd":
+		return CodecZstd, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+}
+
+// Encode converts content into the representation stored in the content column.
+func (c ContentCodec) Encode(content string) ([]byte, error) {
+	data, err := c.compress(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Keys == nil {
+		return data, nil
+	}
+
+	return encrypt(c.Keys, data)
+}
+
+// compress applies the configured compression to content.
+func (c ContentCodec) compress(content string) ([]byte, error) {
+	if c.Threshold <= 0 || len(content) < c.Threshold || c.Codec == CodecNone {
+		// Plain content that happens to start with a flag byte is wrapped in a header so it
+		// isn't mistaken for encoded content when it's read back.
+		if len(content) > 0 && (content[0] == contentFlag || content[0] == encryptedFlag) {
+			return append([]byte{contentFlag, CodecNone}, content...), nil
+		}
+		return []byte(content), nil
+	}
+
+	var payload []byte
+
+	switch c.Codec {
+	case CodecGzip:
+		buf := new(bytes.Buffer)
+		zw := gzip.NewWriter(buf)
+		if _, err := zw.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+	case CodecZstd:
+		payload = zstdEncoder.EncodeAll([]byte(content), nil)
+	default:
+		return nil, ErrUnknownCodec
+	}
+
+	return append([]byte{contentFlag, c.Codec}, payload...), nil
+}
+
+// Decode converts a value read from the content column back into the original content.
+// Values without a header are returned unchanged.
+func (c ContentCodec) Decode(data []byte) (string, error) {
+	if len(data) > 0 && data[0] == encryptedFlag {
+		var err error
+		data, err = decrypt(c.Keys, data)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(data) < 2 || data[0] != contentFlag {
+		return string(data), nil
+	}
+
+	payload := data[2:]
+
+	switch data[1] {
+	case CodecNone:
+		return string(payload), nil
+	case CodecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return "", err
+		}
+		defer zr.Close()
+
+		content, err := io.ReadAll(zr)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	case CodecZstd:
+		content, err := zstdDecoder.DecodeAll(payload, nil)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	default:
+		return "", ErrUnknownCodec
+	}
+}
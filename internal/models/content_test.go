@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestContentCodec(t *testing.T) {
+
+	t.Parallel()
+
+	large := strings.Repeat("An old silent pond...\n", 100)
+
+	tests := []struct {
+		name       string
+		codec      ContentCodec
+		content    string
+		wantHeader bool
+	}{
+		{
+			name:    "Disabled",
+			codec:   ContentCodec{},
+			content: large,
+		},
+		{
+			name:    "Below threshold",
+			codec:   ContentCodec{Threshold: 4096, Codec: CodecZstd},
+			content: "An old silent pond...",
+		},
+		{
+			name:       "Gzip",
+			codec:      ContentCodec{Threshold: 64, Codec: CodecGzip},
+			content:    large,
+			wantHeader: true,
+		},
+		{
+			name:       "Zstd",
+			codec:      ContentCodec{Threshold: 64, Codec: CodecZstd},
+			content:    large,
+			wantHeader: true,
+		},
+		{
+			name:       "Leading flag byte",
+			codec:      ContentCodec{Threshold: 4096, Codec: CodecZstd},
+			content:    "\x01raw",
+			wantHeader: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.codec.Encode(tt.content)
+			assert.NilError(t, err)
+
+			assert.Equal(t, len(encoded) > 0 && encoded[0] == contentFlag, tt.wantHeader)
+
+			decoded, err := tt.codec.Decode(encoded)
+			assert.NilError(t, err)
+
+			assert.Equal(t, decoded, tt.content)
+		})
+	}
+}
+
+func TestContentCodecLegacy(t *testing.T) {
+
+	t.Parallel()
+
+	decoded, err := ContentCodec{}.Decode([]byte("An old silent pond..."))
+
+	assert.NilError(t, err)
+	assert.Equal(t, decoded, "An old silent pond...")
+}
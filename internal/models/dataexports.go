@@ -0,0 +1,582 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// The statuses of a data export.
+const (
+	ExportPending  = "pending"  // The export waits for the job to build it.
+	ExportBuilding = "building" // A server is building the export.
+	ExportReady    = "ready"    // The archive can be downloaded until the export expires.
+	ExportFailed   = "failed"   // Building the export failed; the user can request another.
+)
+
+// DataExportLifetime is how long a ready archive can be downloaded.
+const DataExportLifetime = 7 * 24 * time.Hour
+
+// exportClaimTimeout is how long an export may be building before it's assumed that the server
+// building it died, and it's built again.
+const exportClaimTimeout = time.Hour
+
+// DataExport is a user's request for an archive of their data.
+type DataExport struct {
+	ID        int       // ID is the unique identifier of the export.
+	UserID    int       // UserID is the ID of the user whose data is exported.
+	Status    string    // Status is one of the Export constants.
+	Requested time.Time // Requested is when the user asked for the export.
+	Completed time.Time // Completed is when the archive was built, or the zero time.
+	Expires   time.Time // Expires is when a ready archive is deleted, or the zero time.
+}
+
+// UserData is everything stored about a user, as written to their data export. Secrets that only
+// matter to the server, such as the password hash and token hashes, are left out.
+type UserData struct {
+	Profile         UserProfile          `json:"profile"`
+	Snippets        []UserSnippet        `json:"snippets"`
+	EditedSnippets  []int                `json:"edited_snippets"`
+	Collections     []UserCollection     `json:"collections"`
+	Favorites       []int                `json:"favorites"` // Favorites holds the IDs of the snippets the user starred.
+	ShareLinks      []UserShareLink      `json:"share_links"`
+	ShortLinks      []UserShortLink      `json:"short_links"`
+	Organizations   []UserOrganization   `json:"organizations"`
+	Invitations     []UserInvitation     `json:"invitations"`
+	Permissions     []UserPermission     `json:"permissions"`
+	SavedSearches   []UserSavedSearch    `json:"saved_searches"`
+	Draft           *UserDraft           `json:"draft,omitempty"`
+	DigestFrequency string               `json:"digest_frequency"`
+	ReminderDays    int                  `json:"expiry_reminder_days"`
+	Preferences     Preferences          `json:"preferences"`
+	DataExports     []UserDataExportInfo `json:"data_exports"`
+}
+
+// UserProfile is the users row of a user.
+type UserProfile struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Username string    `json:"username,omitempty"`
+	Email    string    `json:"email"`
+	Created  time.Time `json:"created"`
+	Admin    bool      `json:"admin"`
+}
+
+// UserSnippet is a snippet owned by a user, including the client information recorded when it
+// was created.
+type UserSnippet struct {
+	ID        int             `json:"id"`
+	ULID      string          `json:"ulid,omitempty"`
+	Title     string          `json:"title"`
+	Content   string          `json:"content"`
+	Language  string          `json:"language,omitempty"`
+	License   string          `json:"license,omitempty"`
+	Metadata  []MetadataField `json:"metadata,omitempty"`
+	Created   time.Time       `json:"created"`
+	Expires   time.Time       `json:"expires"` // Expires is the zero time for snippets that never expire.
+	Updated   time.Time       `json:"updated"`
+	Held      bool            `json:"held"`
+	Private   bool            `json:"private"`
+	OrgID     int             `json:"org_id,omitempty"`
+	CreatorIP string          `json:"creator_ip,omitempty"`
+	CreatorUA string          `json:"creator_user_agent,omitempty"`
+}
+
+// UserCollection is a collection owned by a user, with the IDs of its snippets.
+type UserCollection struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Public     bool      `json:"public"`
+	Created    time.Time `json:"created"`
+	SnippetIDs []int     `json:"snippet_ids"`
+}
+
+// UserShareLink is a share link created by a user.
+type UserShareLink struct {
+	SnippetID int       `json:"snippet_id"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// UserShortLink is a short link created by a user.
+type UserShortLink struct {
+	Code      string    `json:"code"`
+	SnippetID int       `json:"snippet_id"`
+	Created   time.Time `json:"created"`
+	Clicks    int       `json:"clicks"`
+}
+
+// UserOrganization is a user's membership of an organization.
+type UserOrganization struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Role   string    `json:"role"`
+	Joined time.Time `json:"joined"`
+}
+
+// UserInvitation is an invitation sent by or to a user. Invitations sent by the user are listed
+// without the address they were sent to, which is someone else's data.
+type UserInvitation struct {
+	OrgID   int       `json:"org_id"`
+	Role    string    `json:"role"`
+	Sent    bool      `json:"sent"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// UserPermission is a permission rule naming a user.
+type UserPermission struct {
+	SnippetID int    `json:"snippet_id"`
+	Level     string `json:"level"`
+}
+
+// UserSavedSearch is a saved search of a user.
+type UserSavedSearch struct {
+	Name     string    `json:"name"`
+	Query    string    `json:"query"`
+	Language string    `json:"language,omitempty"`
+	Notify   bool      `json:"notify"`
+	Created  time.Time `json:"created"`
+}
+
+// UserDraft is the draft of a user.
+type UserDraft struct {
+	SnippetID int       `json:"snippet_id,omitempty"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Updated   time.Time `json:"updated"`
+}
+
+// UserDataExportInfo is a data export requested by a user.
+type UserDataExportInfo struct {
+	Status    string    `json:"status"`
+	Requested time.Time `json:"requested"`
+}
+
+// DataExportModel wraps a sql.DB connection pool and provides methods for the data_exports table
+// and for collecting the data of a user from every table.
+type DataExportModel struct {
+	DB      *sql.DB      // DB is the database connection pool.
+	Content ContentCodec // Content encodes archives, and decodes snippet and draft content.
+	Clock   clock.Clock  // Clock timestamps exports and decides which have expired. It defaults to the system clock.
+}
+
+type DataExportModelInterface interface {
+	Request(userID int) (*DataExport, error)
+	Latest(userID int) (*DataExport, error)
+	Pending() ([]*DataExport, error)
+	Claim(e *DataExport) (bool, error)
+	Complete(id int, archive []byte) error
+	Fail(id int) error
+	Archive(id, userID int) ([]byte, error)
+	Collect(userID int) (*UserData, error)
+}
+
+// dataExportColumns is the column list selected by every query that returns data exports.
+const dataExportColumns = `id, user_id, status, requested, completed, expires`
+
+// Request asks for an archive of a user's data. If the user already has an export waiting to be
+// built, it's returned instead of requesting another.
+func (dm *DataExportModel) Request(userID int) (*DataExport, error) {
+
+	latest, err := dm.Latest(userID)
+	if err == nil && (latest.Status == ExportPending || latest.Status == ExportBuilding) {
+		return latest, nil
+	}
+	if err != nil && !errors.Is(err, ErrNoRecord) {
+		return nil, err
+	}
+
+	e := &DataExport{UserID: userID, Status: ExportPending, Requested: currentTime(dm.Clock)}
+
+	res, err := dm.DB.Exec(`INSERT INTO data_exports (user_id, status, requested) VALUES (?, ?, ?)`, e.UserID, e.Status, e.Requested)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	e.ID = int(id)
+
+	return e, err
+}
+
+// Latest returns the most recent export of a user that hasn't expired, or ErrNoRecord.
+func (dm *DataExportModel) Latest(userID int) (*DataExport, error) {
+
+	stmt := `SELECT ` + dataExportColumns + ` FROM data_exports
+    WHERE user_id = ? AND (expires IS NULL OR expires > ?) ORDER BY id DESC LIMIT 1`
+
+	e, err := scanDataExport(dm.DB.QueryRow(stmt, userID, currentTime(dm.Clock)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+
+	return e, err
+}
+
+// Pending returns the exports waiting to be built, including those whose build was claimed so
+// long ago that it must have been abandoned.
+func (dm *DataExportModel) Pending() ([]*DataExport, error) {
+
+	stmt := `SELECT ` + dataExportColumns + ` FROM data_exports
+    WHERE status = ? OR (status = ? AND claimed < ?) ORDER BY id`
+
+	rows, err := dm.DB.Query(stmt, ExportPending, ExportBuilding, currentTime(dm.Clock).Add(-exportClaimTimeout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []*DataExport{}
+	for rows.Next() {
+		e, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+
+	return exports, rows.Err()
+}
+
+// Claim marks an export returned by Pending as being built. It reports false if another server
+// claimed it since, in which case it mustn't be built.
+func (dm *DataExportModel) Claim(e *DataExport) (bool, error) {
+
+	now := currentTime(dm.Clock)
+
+	res, err := dm.DB.Exec(`UPDATE data_exports SET status = ?, claimed = ?
+    WHERE id = ? AND (status = ? OR (status = ? AND claimed < ?))`,
+		ExportBuilding, now, e.ID, ExportPending, ExportBuilding, now.Add(-exportClaimTimeout))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// Complete stores the archive of an export and makes it available for DataExportLifetime.
+func (dm *DataExportModel) Complete(id int, archive []byte) error {
+
+	encoded, err := dm.Content.Encode(string(archive))
+	if err != nil {
+		return err
+	}
+
+	now := currentTime(dm.Clock)
+
+	_, err = dm.DB.Exec(`UPDATE data_exports SET status = ?, completed = ?, expires = ?, data = ? WHERE id = ?`,
+		ExportReady, now, now.Add(DataExportLifetime), encoded, id)
+
+	return err
+}
+
+// Fail records that an export couldn't be built.
+func (dm *DataExportModel) Fail(id int) error {
+
+	_, err := dm.DB.Exec(`UPDATE data_exports SET status = ? WHERE id = ?`, ExportFailed, id)
+
+	return err
+}
+
+// Archive returns the archive of a ready export of a user. It returns ErrNoRecord if the export
+// isn't the user's, isn't ready or has expired.
+func (dm *DataExportModel) Archive(id, userID int) ([]byte, error) {
+
+	var data []byte
+
+	err := dm.DB.QueryRow(`SELECT data FROM data_exports WHERE id = ? AND user_id = ? AND status = ? AND expires > ?`,
+		id, userID, ExportReady, currentTime(dm.Clock)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := dm.Content.Decode(data)
+
+	return []byte(archive), err
+}
+
+// Collect gathers everything stored about a user. It returns ErrNoRecord if the user doesn't
+// exist.
+func (dm *DataExportModel) Collect(userID int) (*UserData, error) {
+
+	d := &UserData{
+		DigestFrequency: DigestOff,
+		Snippets:        []UserSnippet{},
+		EditedSnippets:  []int{},
+		Collections:     []UserCollection{},
+		Favorites:       []int{},
+		ShareLinks:      []UserShareLink{},
+		ShortLinks:      []UserShortLink{},
+		Organizations:   []UserOrganization{},
+		Invitations:     []UserInvitation{},
+		Permissions:     []UserPermission{},
+		SavedSearches:   []UserSavedSearch{},
+		DataExports:     []UserDataExportInfo{},
+	}
+
+	var username sql.NullString
+	err := dm.DB.QueryRow(`SELECT id, name, username, email, created, admin FROM users WHERE id = ?`, userID).
+		Scan(&d.Profile.ID, &d.Profile.Name, &username, &d.Profile.Email, &d.Profile.Created, &d.Profile.Admin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.Profile.Username = username.String
+
+	err = dm.each(`SELECT id, COALESCE(ulid, ''), title, content, language, license, created, expires, updated, held, private,
+    COALESCE(org_id, 0), COALESCE(creator_ip, ''), COALESCE(creator_ua, '') FROM snippets WHERE owner_id = ? ORDER BY id`,
+		[]any{userID}, func(row rowScanner) error {
+			var s UserSnippet
+			var data []byte
+			var expires sql.NullTime
+			if err := row.Scan(&s.ID, &s.ULID, &s.Title, &data, &s.Language, &s.License, &s.Created, &expires, &s.Updated, &s.Held, &s.Private,
+				&s.OrgID, &s.CreatorIP, &s.CreatorUA); err != nil {
+				return err
+			}
+			s.Expires = expires.Time
+			content, err := dm.Content.Decode(data)
+			if err != nil {
+				return fmt.Errorf("models: snippet %d: %w", s.ID, err)
+			}
+			s.Content = content
+			d.Snippets = append(d.Snippets, s)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range d.Snippets {
+		s := &d.Snippets[i]
+		err = dm.each(`SELECT name, value FROM snippet_metadata WHERE snippet_id = ? ORDER BY position`,
+			[]any{s.ID}, func(row rowScanner) error {
+				var f MetadataField
+				if err := row.Scan(&f.Name, &f.Value); err != nil {
+					return err
+				}
+				s.Metadata = append(s.Metadata, f)
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = dm.each(`SELECT id FROM snippets WHERE updated_by = ? AND NOT owner_id <=> ? ORDER BY id`,
+		[]any{userID, userID}, func(row rowScanner) error {
+			var id int
+			if err := row.Scan(&id); err != nil {
+				return err
+			}
+			d.EditedSnippets = append(d.EditedSnippets, id)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT id, name, public, created FROM collections WHERE owner_id = ? ORDER BY id`,
+		[]any{userID}, func(row rowScanner) error {
+			c := UserCollection{SnippetIDs: []int{}}
+			if err := row.Scan(&c.ID, &c.Name, &c.Public, &c.Created); err != nil {
+				return err
+			}
+			d.Collections = append(d.Collections, c)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range d.Collections {
+		c := &d.Collections[i]
+		err = dm.each(`SELECT snippet_id FROM collection_snippets WHERE collection_id = ? ORDER BY added`,
+			[]any{c.ID}, func(row rowScanner) error {
+				var id int
+				if err := row.Scan(&id); err != nil {
+					return err
+				}
+				c.SnippetIDs = append(c.SnippetIDs, id)
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = dm.each(`SELECT snippet_id FROM favorites WHERE user_id = ? ORDER BY created`,
+		[]any{userID}, func(row rowScanner) error {
+			var id int
+			if err := row.Scan(&id); err != nil {
+				return err
+			}
+			d.Favorites = append(d.Favorites, id)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT snippet_id, created, expires FROM share_links WHERE created_by = ? ORDER BY id`,
+		[]any{userID}, func(row rowScanner) error {
+			var l UserShareLink
+			if err := row.Scan(&l.SnippetID, &l.Created, &l.Expires); err != nil {
+				return err
+			}
+			d.ShareLinks = append(d.ShareLinks, l)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT code, snippet_id, created, clicks FROM short_links WHERE created_by = ? ORDER BY created`,
+		[]any{userID}, func(row rowScanner) error {
+			var l UserShortLink
+			if err := row.Scan(&l.Code, &l.SnippetID, &l.Created, &l.Clicks); err != nil {
+				return err
+			}
+			d.ShortLinks = append(d.ShortLinks, l)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT o.id, o.name, m.role, m.joined FROM organization_members m
+    JOIN organizations o ON o.id = m.org_id WHERE m.user_id = ? ORDER BY o.id`,
+		[]any{userID}, func(row rowScanner) error {
+			var o UserOrganization
+			if err := row.Scan(&o.ID, &o.Name, &o.Role, &o.Joined); err != nil {
+				return err
+			}
+			d.Organizations = append(d.Organizations, o)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT org_id, role, invited_by = ?, created, expires FROM organization_invitations
+    WHERE invited_by = ? OR email = ? ORDER BY id`,
+		[]any{userID, userID, d.Profile.Email}, func(row rowScanner) error {
+			var i UserInvitation
+			if err := row.Scan(&i.OrgID, &i.Role, &i.Sent, &i.Created, &i.Expires); err != nil {
+				return err
+			}
+			d.Invitations = append(d.Invitations, i)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT snippet_id, level FROM snippet_permissions WHERE user_id = ? ORDER BY snippet_id`,
+		[]any{userID}, func(row rowScanner) error {
+			var p UserPermission
+			if err := row.Scan(&p.SnippetID, &p.Level); err != nil {
+				return err
+			}
+			d.Permissions = append(d.Permissions, p)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT name, query, language, notify, created FROM saved_searches WHERE user_id = ? ORDER BY id`,
+		[]any{userID}, func(row rowScanner) error {
+			var s UserSavedSearch
+			if err := row.Scan(&s.Name, &s.Query, &s.Language, &s.Notify, &s.Created); err != nil {
+				return err
+			}
+			d.SavedSearches = append(d.SavedSearches, s)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := &DraftModel{DB: dm.DB, Content: dm.Content, Clock: dm.Clock}
+	draft, err := drafts.Get(userID)
+	switch {
+	case err == nil:
+		d.Draft = &UserDraft{SnippetID: draft.SnippetID, Title: draft.Title, Content: draft.Content, Updated: draft.Updated}
+	case !errors.Is(err, ErrNoRecord):
+		return nil, err
+	}
+
+	err = dm.DB.QueryRow(`SELECT frequency FROM digest_subscriptions WHERE user_id = ?`, userID).Scan(&d.DigestFrequency)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	err = dm.DB.QueryRow(`SELECT days FROM expiry_reminders WHERE user_id = ?`, userID).Scan(&d.ReminderDays)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	d.Preferences = DefaultPreferences
+	err = dm.DB.QueryRow(`SELECT default_expires, default_private, default_language FROM user_preferences WHERE user_id = ?`, userID).
+		Scan(&d.Preferences.DefaultExpires, &d.Preferences.DefaultPrivate, &d.Preferences.DefaultLanguage)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	err = dm.each(`SELECT status, requested FROM data_exports WHERE user_id = ? ORDER BY id`,
+		[]any{userID}, func(row rowScanner) error {
+			var e UserDataExportInfo
+			if err := row.Scan(&e.Status, &e.Requested); err != nil {
+				return err
+			}
+			d.DataExports = append(d.DataExports, e)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// each runs a query and calls fn for every row.
+func (dm *DataExportModel) each(stmt string, args []any, fn func(row rowScanner) error) error {
+
+	rows, err := dm.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// scanDataExport scans a row of dataExportColumns.
+func scanDataExport(row rowScanner) (*DataExport, error) {
+	e := &DataExport{}
+
+	var completed, expires sql.NullTime
+	if err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.Requested, &completed, &expires); err != nil {
+		return nil, err
+	}
+	e.Completed, e.Expires = completed.Time, expires.Time
+
+	return e, nil
+}
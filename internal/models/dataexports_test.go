@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestDataExportModel(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	id, err := sm.Insert("Mine", "content", 30, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, sm.SetPrivate(id, true))
+
+	dm := &DataExportModel{DB: db}
+
+	e, err := dm.Request(1)
+	assert.NilError(t, err)
+
+	// Asking again while the export is pending doesn't queue another.
+	again, err := dm.Request(1)
+	assert.NilError(t, err)
+	assert.Equal(t, again.ID, e.ID)
+
+	pending, err := dm.Pending()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 1)
+
+	claimed, err := dm.Claim(pending[0])
+	assert.NilError(t, err)
+	assert.Equal(t, claimed, true)
+
+	claimed, err = dm.Claim(pending[0])
+	assert.NilError(t, err)
+	assert.Equal(t, claimed, false)
+
+	data, err := dm.Collect(1)
+	assert.NilError(t, err)
+	assert.Equal(t, data.Profile.Email, "alice@example.com")
+	assert.Equal(t, len(data.Snippets), 1)
+	assert.Equal(t, data.Snippets[0].Private, true)
+	assert.Equal(t, data.Snippets[0].Content, "content")
+	assert.Equal(t, data.DigestFrequency, DigestOff)
+
+	_, err = dm.Archive(e.ID, 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	assert.NilError(t, dm.Complete(e.ID, []byte(`{"format":"test"}`)))
+
+	archive, err := dm.Archive(e.ID, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, string(archive), `{"format":"test"}`)
+
+	_, err = dm.Archive(e.ID, 2)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	_, err = dm.Collect(999)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// The frequencies of activity digests.
+const (
+	DigestOff    = "off"    // No digest is sent.
+	DigestDaily  = "daily"  // A digest is sent every day.
+	DigestWeekly = "weekly" // A digest is sent every week.
+)
+
+// digestPeriods is the time between two digests of each frequency.
+var digestPeriods = map[string]time.Duration{DigestDaily: 24 * time.Hour, DigestWeekly: 7 * 24 * time.Hour}
+
+// ValidDigest reports whether frequency is a known digest frequency.
+func ValidDigest(frequency string) bool {
+	return frequency == DigestOff || digestPeriods[frequency] != 0
+}
+
+// DigestRecipient is a user whose digest is due.
+type DigestRecipient struct {
+	UserID    int       // UserID is the ID of the user.
+	Name      string    // Name is the name of the user.
+	Email     string    // Email is the address the digest is sent to.
+	Frequency string    // Frequency is DigestDaily or DigestWeekly.
+	LastSent  time.Time // LastSent is when the previous digest was sent, or the zero time if none was.
+}
+
+// Since returns the start of the period the digest covers: the time the previous digest was sent,
+// or one period ago for a first digest.
+func (d *DigestRecipient) Since(now time.Time) time.Time {
+	if d.LastSent.IsZero() {
+		return now.Add(-digestPeriods[d.Frequency])
+	}
+	return d.LastSent
+}
+
+// SnippetActivity is the activity on one snippet over the period of a digest.
+type SnippetActivity struct {
+	SnippetID int    // SnippetID is the ID of the snippet.
+	ULID      string // ULID is the public identifier of the snippet, or empty for old snippets.
+	Title     string // Title is the title of the snippet.
+	Views     int    // Views is the number of views in the period.
+}
+
+// PublicID returns the identifier to use in links to the snippet.
+func (a *SnippetActivity) PublicID() string {
+	s := Snippet{ID: a.SnippetID, ULID: a.ULID}
+	return s.PublicID()
+}
+
+// DigestModel wraps a sql.DB connection pool and provides methods for the digest_subscriptions
+// table and the activity digests are made of.
+type DigestModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock decides which digests are due. It defaults to the system clock.
+}
+
+type DigestModelInterface interface {
+	Frequency(userID int) (string, error)
+	SetFrequency(userID int, frequency string) error
+	Due() ([]*DigestRecipient, error)
+	Claim(d *DigestRecipient) (bool, error)
+	Activity(userID int, since time.Time, limit int) ([]*SnippetActivity, error)
+}
+
+// Frequency returns the digest frequency a user chose, DigestOff if they never did.
+func (dm *DigestModel) Frequency(userID int) (string, error) {
+
+	var frequency string
+
+	err := dm.DB.QueryRow(`SELECT frequency FROM digest_subscriptions WHERE user_id = ?`, userID).Scan(&frequency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DigestOff, nil
+	}
+
+	return frequency, err
+}
+
+// SetFrequency sets how often a user gets a digest. Turning the digest off removes the
+// subscription; changing the frequency keeps the time the last digest was sent.
+func (dm *DigestModel) SetFrequency(userID int, frequency string) error {
+
+	if !ValidDigest(frequency) {
+		return errors.New("models: invalid digest frequency " + frequency)
+	}
+
+	if frequency == DigestOff {
+		_, err := dm.DB.Exec(`DELETE FROM digest_subscriptions WHERE user_id = ?`, userID)
+		return err
+	}
+
+	stmt := `INSERT INTO digest_subscriptions (user_id, frequency) VALUES (?, ?)
+    ON DUPLICATE KEY UPDATE frequency = VALUES(frequency)`
+
+	_, err := dm.DB.Exec(stmt, userID, frequency)
+
+	return err
+}
+
+// Due returns the users whose digest is due: those who never got one and those whose last one is
+// at least a period old.
+func (dm *DigestModel) Due() ([]*DigestRecipient, error) {
+
+	now := currentTime(dm.Clock)
+
+	stmt := `SELECT u.id, u.name, u.email, d.frequency, d.last_sent FROM digest_subscriptions d
+    JOIN users u ON u.id = d.user_id
+    WHERE d.last_sent IS NULL OR (d.frequency = ? AND d.last_sent <= ?) OR (d.frequency = ? AND d.last_sent <= ?)
+    ORDER BY u.id`
+
+	rows, err := dm.DB.Query(stmt, DigestDaily, now.Add(-digestPeriods[DigestDaily]), DigestWeekly, now.Add(-digestPeriods[DigestWeekly]))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []*DigestRecipient{}
+	for rows.Next() {
+		d := &DigestRecipient{}
+		var lastSent sql.NullTime
+		if err := rows.Scan(&d.UserID, &d.Name, &d.Email, &d.Frequency, &lastSent); err != nil {
+			return nil, err
+		}
+		d.LastSent = lastSent.Time
+		due = append(due, d)
+	}
+
+	return due, rows.Err()
+}
+
+// Claim records that the digest of a recipient returned by Due is being sent now. It reports false
+// if the digest was claimed since, by another server or because the user changed their
+// subscription, in which case it mustn't be sent.
+func (dm *DigestModel) Claim(d *DigestRecipient) (bool, error) {
+
+	lastSent := sql.NullTime{Time: d.LastSent, Valid: !d.LastSent.IsZero()}
+
+	res, err := dm.DB.Exec(`UPDATE digest_subscriptions SET last_sent = ? WHERE user_id = ? AND frequency = ? AND last_sent <=> ?`,
+		currentTime(dm.Clock), d.UserID, d.Frequency, lastSent)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// Activity returns the most viewed unexpired snippets of a user over the whole days since the
+// given time, up to but not including today, with their views in that time.
+func (dm *DigestModel) Activity(userID int, since time.Time, limit int) ([]*SnippetActivity, error) {
+
+	now := currentTime(dm.Clock)
+
+	stmt := `SELECT s.id, COALESCE(s.ulid, ''), s.title, SUM(v.views) AS total FROM snippet_views v
+    JOIN snippets s ON s.id = v.snippet_id
+    WHERE s.owner_id = ? AND (s.expires IS NULL OR s.expires > ?) AND s.deleted_at IS NULL AND v.day >= ? AND v.day < ?
+    GROUP BY s.id, s.ulid, s.title ORDER BY total DESC, s.id DESC LIMIT ?`
+
+	rows, err := dm.DB.Query(stmt, userID, now, since.UTC().Format(time.DateOnly), now.Format(time.DateOnly), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []*SnippetActivity{}
+	for rows.Next() {
+		a := &SnippetActivity{}
+		if err := rows.Scan(&a.SnippetID, &a.ULID, &a.Title, &a.Views); err != nil {
+			return nil, err
+		}
+		activity = append(activity, a)
+	}
+
+	return activity, rows.Err()
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestDigestModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	id, err := sm.Insert("Popular", "content", 30, 1)
+	assert.NilError(t, err)
+
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+
+	vm := &ViewModel{DB: db}
+	assert.NilError(t, vm.Add(map[ViewKey]int{
+		{SnippetID: id, Day: today.AddDate(0, 0, -3)}: 5, // Before the period.
+		{SnippetID: id, Day: today.AddDate(0, 0, -1)}: 2,
+		{SnippetID: id, Day: today}:                   7, // Not over yet.
+	}))
+
+	frozen := clock.NewFrozen(now)
+	dm := &DigestModel{DB: db, Clock: frozen}
+
+	frequency, err := dm.Frequency(1)
+	assert.NilError(t, err)
+	assert.Equal(t, frequency, DigestOff)
+
+	assert.NilError(t, dm.SetFrequency(1, DigestDaily))
+
+	due, err := dm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].Email, "alice@example.com")
+	assert.Equal(t, due[0].LastSent.IsZero(), true)
+
+	activity, err := dm.Activity(1, due[0].Since(now), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(activity), 1)
+	assert.Equal(t, activity[0].Views, 2)
+
+	claimed, err := dm.Claim(due[0])
+	assert.NilError(t, err)
+	assert.Equal(t, claimed, true)
+
+	// Another server that read the same recipient can't send the digest again.
+	claimed, err = dm.Claim(due[0])
+	assert.NilError(t, err)
+	assert.Equal(t, claimed, false)
+
+	due, err = dm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 0)
+
+	frozen.Advance(24 * time.Hour)
+
+	due, err = dm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 1)
+
+	assert.NilError(t, dm.SetFrequency(1, DigestOff))
+
+	due, err = dm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 0)
+}
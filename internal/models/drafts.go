@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// DraftLifetime is how long a draft is kept after it was last saved.
+const DraftLifetime = 30 * 24 * time.Hour
+
+// Draft is the in-progress content of the snippet form of a user.
+type Draft struct {
+	UserID    int       // UserID is the ID of the user writing the draft.
+	SnippetID int       // SnippetID is the ID of the snippet being edited, or 0 for a new snippet.
+	Title     string    // Title is the title typed so far.
+	Content   string    // Content is the content typed so far.
+	Updated   time.Time // Updated is when the draft was last saved.
+}
+
+// DraftModel wraps a sql.DB connection pool and provides methods for the snippet_drafts table.
+type DraftModel struct {
+	DB      *sql.DB      // DB is the database connection pool.
+	Content ContentCodec // Content encodes draft content like snippet content, so it's compressed and encrypted too.
+	Clock   clock.Clock  // Clock timestamps drafts. It defaults to the system clock.
+}
+
+type DraftModelInterface interface {
+	Save(d Draft) error
+	Get(userID int) (*Draft, error)
+	Delete(userID, snippetID int) error
+}
+
+// Save stores the draft of a user, replacing the one they had. Saving the same draft twice leaves
+// a single row.
+func (dm *DraftModel) Save(d Draft) error {
+
+	encoded, err := dm.Content.Encode(d.Content)
+	if err != nil {
+		return err
+	}
+
+	now := currentTime(dm.Clock)
+
+	stmt := `INSERT INTO snippet_drafts (user_id, snippet_id, title, content, updated, expires) VALUES (?, ?, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE snippet_id = VALUES(snippet_id), title = VALUES(title), content = VALUES(content),
+    updated = VALUES(updated), expires = VALUES(expires)`
+
+	_, err = dm.DB.Exec(stmt, d.UserID, d.SnippetID, d.Title, encoded, now, now.Add(DraftLifetime))
+
+	return err
+}
+
+// Get returns the unexpired draft of a user, or ErrNoRecord if they have none.
+func (dm *DraftModel) Get(userID int) (*Draft, error) {
+
+	d := &Draft{}
+	var content []byte
+
+	stmt := `SELECT user_id, snippet_id, title, content, updated FROM snippet_drafts WHERE user_id = ? AND expires > ?`
+
+	err := dm.DB.QueryRow(stmt, userID, currentTime(dm.Clock)).Scan(&d.UserID, &d.SnippetID, &d.Title, &content, &d.Updated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	d.Content, err = dm.Content.Decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Delete removes the draft of a user if it's for the given snippet, or for a new snippet if
+// snippetID is 0, so that saving one form doesn't throw away the draft of another.
+func (dm *DraftModel) Delete(userID, snippetID int) error {
+
+	_, err := dm.DB.Exec(`DELETE FROM snippet_drafts WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+
+	return err
+}
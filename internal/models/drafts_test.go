@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestDraftModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	dm := &DraftModel{DB: db, Content: ContentCodec{Threshold: 1, Codec: CodecGzip}}
+
+	_, err := dm.Get(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	assert.NilError(t, dm.Save(Draft{UserID: 1, Title: "First", Content: "one"}))
+	assert.NilError(t, dm.Save(Draft{UserID: 1, Title: "Second", Content: "two"}))
+
+	var n int
+	assert.NilError(t, db.QueryRow(`SELECT COUNT(*) FROM snippet_drafts`).Scan(&n))
+	assert.Equal(t, n, 1)
+
+	d, err := dm.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, d.Title, "Second")
+	assert.Equal(t, d.Content, "two")
+	assert.Equal(t, d.SnippetID, 0)
+
+	// Saving an edit doesn't discard the draft of a new snippet, and the other way round.
+	assert.NilError(t, dm.Delete(1, 5))
+	_, err = dm.Get(1)
+	assert.NilError(t, err)
+
+	assert.NilError(t, dm.Delete(1, 0))
+	_, err = dm.Get(1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+
+	"snippetbox.adcon.dev/internal/fingerprint"
+)
+
+// fingerprints returns the values of the content_hash and simhash columns for content. The simhash
+// is nil for content too short to compare.
+func fingerprints(content string) (string, any) {
+	hash := fingerprint.Hash(content)
+
+	simhash, ok := fingerprint.Simhash(content)
+	if !ok {
+		return hash, nil
+	}
+
+	// The column is signed, and the driver only takes uint64 values that fit an int64.
+	return hash, int64(simhash)
+}
+
+// Duplicate returns the listed snippet whose content is identical to content once normalized, or
+// failing that the one whose content is nearest to it within fingerprint.MaxDistance, preferring
+// older snippets. It returns ErrNoRecord if there's none.
+func (sm *SnippetModel) Duplicate(content string) (*Snippet, error) {
+
+	hash, simhash := fingerprints(content)
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private AND (content_hash = ? OR BIT_COUNT(simhash ^ ?) <= ?)
+    ORDER BY content_hash = ? DESC, BIT_COUNT(simhash ^ ?), id LIMIT 1`
+
+	snippets, err := sm.query(stmt, currentTime(sm.Clock), hash, simhash, fingerprint.MaxDistance, hash, simhash)
+	if err != nil {
+		return nil, err
+	}
+	if len(snippets) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return snippets[0], nil
+}
+
+// BackfillFingerprints computes the fingerprints of the snippets created before they were stored,
+// in batches of batchSize, and returns the number of snippets updated.
+func (sm *SnippetModel) BackfillFingerprints(batchSize int) (int, error) {
+
+	count := 0
+
+	for {
+		rows, err := sm.DB.Query(`SELECT id, content FROM snippets WHERE content_hash IS NULL ORDER BY id LIMIT ?`, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		contents := map[int]string{}
+		for rows.Next() {
+			var id int
+			var data []byte
+			if err := rows.Scan(&id, &data); err != nil {
+				rows.Close()
+				return count, err
+			}
+
+			content, err := sm.Content.Decode(data)
+			if err != nil {
+				rows.Close()
+				return count, fmt.Errorf("models: snippet %d: %w", id, err)
+			}
+			contents[id] = content
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, err
+		}
+
+		for id, content := range contents {
+			hash, simhash := fingerprints(content)
+			if _, err := sm.DB.Exec(`UPDATE snippets SET content_hash = ?, simhash = ? WHERE id = ?`, hash, simhash, id); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(contents) < batchSize {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestSnippetModelDuplicate(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	content := "An old silent pond\nA frog jumps into the pond\nSplash! Silence again.\nOver the wintry forest, winds howl in rage"
+
+	id, err := sm.Insert("Haiku", content, 30, 1)
+	assert.NilError(t, err)
+
+	// Copies differing in line endings and trailing whitespace are identical.
+	s, err := sm.Duplicate(strings.ReplaceAll(content, "\n", "  \r\n") + "\n")
+	assert.NilError(t, err)
+	assert.Equal(t, s.ID, id)
+
+	s, err = sm.Duplicate(strings.Replace(content, "frog", "toad", 1))
+	assert.NilError(t, err)
+	assert.Equal(t, s.ID, id)
+
+	_, err = sm.Duplicate("Something else entirely")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// Private snippets aren't pointed out.
+	assert.NilError(t, sm.SetPrivate(id, true))
+	_, err = sm.Duplicate(content)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,228 @@
+// Package models contains the application's data models.
+package models
+
+// Import the necessary packages.
+import (
+	"crypto/aes"      // Package for the AES block cipher.
+	"crypto/cipher"   // Package for the GCM authenticated cipher mode.
+	"crypto/rand"     // Package for cryptographically secure random numbers.
+	"encoding/base64" // Package for decoding base64 keys.
+	"encoding/binary" // Package for encoding lengths in the envelope header.
+	"errors"          // Package for creating error messages.
+	"fmt"             // Package for formatted I/O.
+	"strings"         // Package for manipulating strings.
+)
+
+// encryptedFlag marks a content value that is wrapped in an encryption envelope.
+const encryptedFlag byte = 0x02
+
+var (
+	// ErrUnknownKey is returned when encrypted content references a key that isn't in the keyring.
+	ErrUnknownKey = errors.New("models: unknown encryption key")
+
+	// ErrMalformedEnvelope is returned when encrypted content can't be parsed.
+	ErrMalformedEnvelope = errors.New("models: malformed encryption envelope")
+)
+
+// KeyEncrypter wraps and unwraps the per-snippet data keys used for envelope encryption.
+// Keyring implements it with locally configured keys; a KMS client can implement it to keep
+// the key-encryption keys outside the application.
+type KeyEncrypter interface {
+	// WrapKey encrypts a data key with the active key-encryption key and returns the ID of that key.
+	WrapKey(dek []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key that was wrapped with the key identified by keyID.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+	// ActiveKeyID returns the ID of the key used for new data keys.
+	ActiveKeyID() string
+}
+
+// Keyring holds AES-256 key-encryption keys by ID. The first key in the configuration is the
+// active key; the others are kept so that content encrypted before a rotation can still be read.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// ParseKeyring parses a keyring specification of the form "id:base64key[,id:base64key...]".
+// Each key must decode to 32 bytes. The first key is used to encrypt new content.
+func ParseKeyring(spec string) (*Keyring, error) {
+	kr := &Keyring{keys: map[string][]byte{}}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("models: invalid keyring entry %q", entry)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("models: invalid key %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("models: key %q must be 32 bytes, got %d", id, len(key))
+		}
+
+		if kr.active == "" {
+			kr.active = id
+		}
+		kr.keys[id] = key
+	}
+
+	if kr.active == "" {
+		return nil, errors.New("models: keyring is empty")
+	}
+
+	return kr, nil
+}
+
+// ActiveKeyID returns the ID of the key used to encrypt new content.
+func (kr *Keyring) ActiveKeyID() string {
+	return kr.active
+}
+
+// WrapKey encrypts a data key with the active key.
+func (kr *Keyring) WrapKey(dek []byte) (string, []byte, error) {
+	wrapped, err := seal(kr.keys[kr.active], dek)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return kr.active, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key with the key identified by keyID.
+func (kr *Keyring) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := kr.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	return open(kek, wrapped)
+}
+
+// encrypt wraps data in an encryption envelope:
+//
+//	[flag][key ID length][key ID][wrapped key length (2 bytes)][wrapped key][nonce + ciphertext]
+//
+// A fresh data key is generated for every value and wrapped with the active key.
+func encrypt(keys KeyEncrypter, data []byte) ([]byte, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := keys.WrapKey(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(dek, data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 4+len(keyID)+len(wrapped)+len(ciphertext))
+	out = append(out, encryptedFlag, byte(len(keyID)))
+	out = append(out, keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	out = append(out, ciphertext...)
+
+	return out, nil
+}
+
+// decrypt opens an encryption envelope created by encrypt.
+func decrypt(keys KeyEncrypter, data []byte) ([]byte, error) {
+	keyID, wrapped, ciphertext, err := parseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if keys == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	dek, err := keys.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dek, ciphertext)
+}
+
+// envelopeKeyID returns the ID of the key that encrypted data, and false if data isn't encrypted.
+func envelopeKeyID(data []byte) (string, bool) {
+	keyID, _, _, err := parseEnvelope(data)
+	if err != nil {
+		return "", false
+	}
+
+	return keyID, true
+}
+
+// parseEnvelope splits an encryption envelope into its key ID, wrapped data key and ciphertext.
+func parseEnvelope(data []byte) (keyID string, wrapped, ciphertext []byte, err error) {
+	if len(data) < 2 || data[0] != encryptedFlag {
+		return "", nil, nil, ErrMalformedEnvelope
+	}
+
+	n := int(data[1])
+	data = data[2:]
+	if len(data) < n+2 {
+		return "", nil, nil, ErrMalformedEnvelope
+	}
+	keyID, data = string(data[:n]), data[n:]
+
+	m := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < m {
+		return "", nil, nil, ErrMalformedEnvelope
+	}
+
+	return keyID, data[:m], data[m:], nil
+}
+
+// seal encrypts plaintext with AES-GCM and prepends the random nonce.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a value produced by seal.
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformedEnvelope
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+// newGCM returns an AES-GCM cipher for the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
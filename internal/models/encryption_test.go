@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestContentCodecEncryption(t *testing.T) {
+
+	t.Parallel()
+
+	oldRing, err := ParseKeyring("k1:" + testKey('a'))
+	assert.NilError(t, err)
+
+	newRing, err := ParseKeyring("k2:" + testKey('b') + ",k1:" + testKey('a'))
+	assert.NilError(t, err)
+
+	content := strings.Repeat("An old silent pond...\n", 100)
+
+	oldCodec := ContentCodec{Threshold: 64, Codec: CodecZstd, Keys: oldRing}
+	encoded, err := oldCodec.Encode(content)
+	assert.NilError(t, err)
+
+	keyID, ok := envelopeKeyID(encoded)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, keyID, "k1")
+
+	// The rotated keyring can still read content encrypted with the previous key.
+	newCodec := ContentCodec{Threshold: 64, Codec: CodecZstd, Keys: newRing}
+	decoded, err := newCodec.Decode(encoded)
+	assert.NilError(t, err)
+	assert.Equal(t, decoded, content)
+
+	// Reading encrypted content without the key fails.
+	_, err = ContentCodec{}.Decode(encoded)
+	assert.Equal(t, errors.Is(err, ErrUnknownKey), true)
+}
+
+func TestParseKeyring(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		spec       string
+		wantActive string
+		wantErr    bool
+	}{
+		{
+			name:       "Single key",
+			spec:       "k1:" + testKey('a'),
+			wantActive: "k1",
+		},
+		{
+			name:       "First key is active",
+			spec:       "k2:" + testKey('b') + ", k1:" + testKey('a'),
+			wantActive: "k2",
+		},
+		{
+			name:    "Empty",
+			spec:    "",
+			wantErr: true,
+		},
+		{
+			name:    "Short key",
+			spec:    "k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+			wantErr: true,
+		},
+		{
+			name:    "Missing ID",
+			spec:    testKey('a'),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := ParseKeyring(tt.spec)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			if err == nil {
+				assert.Equal(t, kr.ActiveKeyID(), tt.wantActive)
+			}
+		})
+	}
+}
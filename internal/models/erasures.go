@@ -0,0 +1,216 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// erasureStep deletes or anonymizes one kind of personal data of a user being erased.
+type erasureStep struct {
+	name string // name describes the data in summaries, such as "snippets deleted".
+	stmt string // stmt is run with the user's ID for each ?, or their email address if byEmail is set.
+
+	byEmail bool
+}
+
+// ownSnippets selects the snippets owned by the user being erased outside organizations. They're
+// deleted, while snippets of organizations belong to the organization and are only anonymized.
+const ownSnippets = `(SELECT id FROM snippets WHERE owner_id = ? AND org_id IS NULL)`
+
+// erasureSteps lists the personal data of a user in the order it's erased. The rows that depend on
+// the user's snippets go before the snippets themselves, and the users row goes last.
+var erasureSteps = []erasureStep{
+	{name: "views deleted", stmt: `DELETE FROM snippet_views WHERE snippet_id IN ` + ownSnippets},
+	{name: "access log entries deleted", stmt: `DELETE FROM snippet_accesses WHERE snippet_id IN ` + ownSnippets},
+	{name: "trending scores deleted", stmt: `DELETE FROM snippet_trending WHERE snippet_id IN ` + ownSnippets},
+	{name: "collection entries deleted", stmt: `DELETE FROM collection_snippets
+    WHERE snippet_id IN ` + ownSnippets + ` OR collection_id IN (SELECT id FROM collections WHERE owner_id = ?)`},
+	{name: "favorites deleted", stmt: `DELETE FROM favorites WHERE snippet_id IN ` + ownSnippets + ` OR user_id = ?`},
+	{name: "short links deleted", stmt: `DELETE FROM short_links WHERE snippet_id IN ` + ownSnippets},
+	{name: "share links deleted", stmt: `DELETE FROM share_links WHERE snippet_id IN ` + ownSnippets + ` OR created_by = ?`},
+	{name: "permission rules deleted", stmt: `DELETE FROM snippet_permissions WHERE snippet_id IN ` + ownSnippets + ` OR user_id = ?`},
+	{name: "metadata deleted", stmt: `DELETE FROM snippet_metadata WHERE snippet_id IN ` + ownSnippets},
+	{name: "webmentions deleted", stmt: `DELETE FROM webmentions WHERE snippet_id IN ` + ownSnippets},
+	{name: "revisions deleted", stmt: `DELETE FROM snippet_revisions WHERE snippet_id IN ` + ownSnippets},
+	{name: "drafts deleted", stmt: `DELETE FROM snippet_drafts WHERE user_id = ?`},
+	{name: "edit locks deleted", stmt: `DELETE FROM snippet_locks WHERE user_id = ?`},
+	{name: "snippets deleted", stmt: `DELETE FROM snippets WHERE owner_id = ? AND org_id IS NULL`},
+	{name: "organization snippets anonymized", stmt: `UPDATE snippets SET owner_id = NULL, creator_ip = NULL, creator_ua = NULL WHERE owner_id = ?`},
+	{name: "edits anonymized", stmt: `UPDATE snippets SET updated_by = NULL WHERE updated_by = ?`},
+	{name: "revisions anonymized", stmt: `UPDATE snippet_revisions SET created_by = NULL WHERE created_by = ?`},
+	{name: "short links anonymized", stmt: `UPDATE short_links SET created_by = 0 WHERE created_by = ?`},
+	{name: "collections deleted", stmt: `DELETE FROM collections WHERE owner_id = ?`},
+	{name: "memberships deleted", stmt: `DELETE FROM organization_members WHERE user_id = ?`},
+	{name: "invitations received deleted", stmt: `DELETE FROM organization_invitations WHERE email = ?`, byEmail: true},
+	{name: "invitations sent anonymized", stmt: `UPDATE organization_invitations SET invited_by = 0 WHERE invited_by = ?`},
+	{name: "saved searches deleted", stmt: `DELETE FROM saved_searches WHERE user_id = ?`},
+	{name: "digest subscriptions deleted", stmt: `DELETE FROM digest_subscriptions WHERE user_id = ?`},
+	{name: "expiry reminders deleted", stmt: `DELETE FROM expiry_reminders WHERE user_id = ?`},
+	{name: "preferences deleted", stmt: `DELETE FROM user_preferences WHERE user_id = ?`},
+	{name: "data exports deleted", stmt: `DELETE FROM data_exports WHERE user_id = ?`},
+	{name: "API tokens deleted", stmt: `DELETE FROM api_tokens WHERE user_id = ?`},
+	{name: "email suppressions deleted", stmt: `DELETE FROM email_suppressions WHERE email = ?`, byEmail: true},
+	{name: "filter hits anonymized", stmt: `UPDATE filter_hits SET user_id = 0 WHERE user_id = ?`},
+	{name: "filter rules anonymized", stmt: `UPDATE filter_rules SET created_by = 0 WHERE created_by = ?`},
+	{name: "impersonations anonymized", stmt: `UPDATE impersonations SET user_id = 0 WHERE user_id = ?`},
+	{name: "impersonations by admin anonymized", stmt: `UPDATE impersonations SET admin_id = 0 WHERE admin_id = ?`},
+	{name: "users deleted", stmt: `DELETE FROM users WHERE id = ?`},
+}
+
+// Erasure is the tombstone left by erasing a user. It holds no personal data: the email address is
+// only kept as a hash, which proves the erasure to someone who knows the address.
+type Erasure struct {
+	ID          int       // ID is the unique identifier of the erasure.
+	UserID      int       // UserID is the ID the erased user had.
+	EmailHash   string    // EmailHash is EmailHash of the address the user had.
+	RequestedBy int       // RequestedBy is the ID of the user who asked for the erasure, or 0 for snippetboxctl.
+	Erased      time.Time // Erased is when the data was erased.
+	Summary     string    // Summary lists the number of rows deleted or anonymized, such as "snippets deleted: 2".
+}
+
+// EmailHash returns the hash of an email address stored in erasure tombstones. Addresses are
+// compared without regard to case or surrounding space.
+func EmailHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErasureModel wraps a sql.DB connection pool and erases the personal data of users. It's shared
+// by the web application and the erase command of snippetboxctl.
+type ErasureModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps tombstones. It defaults to the system clock.
+}
+
+type ErasureModelInterface interface {
+	Erase(userID, requestedBy int) (*Erasure, error)
+	Recent(limit int) ([]*Erasure, error)
+	ByEmail(email string) ([]*Erasure, error)
+}
+
+// erasureColumns is the column list selected by every query that returns erasures.
+const erasureColumns = `id, user_id, email_hash, requested_by, erased, summary`
+
+// Erase deletes or anonymizes everything stored about a user in one transaction, and records a
+// tombstone of the erasure in the same transaction. Snippets the user owns in an organization are
+// kept without their owner. It returns ErrNoRecord if the user doesn't exist, and ErrLastOwner if
+// they're the only owner of an organization that has other members.
+//
+// Sessions aren't erased, since they're stored encoded; the web application removes those of the
+// user after Erase returns. Sessions left behind no longer log anyone in, since the user is gone.
+func (em *ErasureModel) Erase(userID, requestedBy int) (*Erasure, error) {
+
+	tx, err := em.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRow(`SELECT email FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Like RemoveMember, refuse to leave an organization whose members can't manage it.
+	var orphaned int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM organization_members m
+    WHERE m.user_id = ? AND m.role = ?
+    AND NOT EXISTS (SELECT 1 FROM organization_members o WHERE o.org_id = m.org_id AND o.user_id <> m.user_id AND o.role = ?)
+    AND EXISTS (SELECT 1 FROM organization_members o WHERE o.org_id = m.org_id AND o.user_id <> m.user_id)`,
+		userID, RoleOwner, RoleOwner).Scan(&orphaned)
+	if err != nil {
+		return nil, err
+	}
+	if orphaned > 0 {
+		return nil, ErrLastOwner
+	}
+
+	var summary []string
+	for _, step := range erasureSteps {
+		args := make([]any, strings.Count(step.stmt, "?"))
+		for i := range args {
+			if step.byEmail {
+				args[i] = email
+			} else {
+				args[i] = userID
+			}
+		}
+
+		res, err := tx.Exec(step.stmt, args...)
+		if err != nil {
+			return nil, fmt.Errorf("models: erasing %s: %w", step.name, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			summary = append(summary, fmt.Sprintf("%s: %d", step.name, n))
+		}
+	}
+
+	e := &Erasure{
+		UserID:      userID,
+		EmailHash:   EmailHash(email),
+		RequestedBy: requestedBy,
+		Erased:      currentTime(em.Clock),
+		Summary:     strings.Join(summary, ", "),
+	}
+
+	res, err := tx.Exec(`INSERT INTO erasures (user_id, email_hash, requested_by, erased, summary) VALUES (?, ?, ?, ?, ?)`,
+		e.UserID, e.EmailHash, e.RequestedBy, e.Erased, e.Summary)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	e.ID = int(id)
+
+	return e, tx.Commit()
+}
+
+// Recent returns the most recent erasures, newest first.
+func (em *ErasureModel) Recent(limit int) ([]*Erasure, error) {
+	return em.query(`SELECT `+erasureColumns+` FROM erasures ORDER BY id DESC LIMIT ?`, limit)
+}
+
+// ByEmail returns the erasures of users who had an email address, newest first.
+func (em *ErasureModel) ByEmail(email string) ([]*Erasure, error) {
+	return em.query(`SELECT `+erasureColumns+` FROM erasures WHERE email_hash = ? ORDER BY id DESC`, EmailHash(email))
+}
+
+// query runs a statement that selects erasureColumns and returns the scanned erasures.
+func (em *ErasureModel) query(stmt string, args ...any) ([]*Erasure, error) {
+
+	rows, err := em.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	erasures := []*Erasure{}
+	for rows.Next() {
+		e := &Erasure{}
+		if err := rows.Scan(&e.ID, &e.UserID, &e.EmailHash, &e.RequestedBy, &e.Erased, &e.Summary); err != nil {
+			return nil, err
+		}
+		erasures = append(erasures, e)
+	}
+
+	return erasures, rows.Err()
+}
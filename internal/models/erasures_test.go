@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestErasureModel(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	own, err := sm.Insert("Mine", "content", 30, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, sm.RecordClient(own, "192.0.2.1", "curl/8.0"))
+
+	cm := &CollectionModel{DB: db}
+	collection, err := cm.Insert(1, "Favourites", false)
+	assert.NilError(t, err)
+	assert.NilError(t, cm.AddSnippet(collection, own))
+
+	// Bob joins Alice's organization and becomes its second owner, so that Alice can be erased.
+	um := &UserModel{DB: db}
+	assert.NilError(t, um.Insert("Bob", "bob", "bob@example.com", "pa$$word"))
+	bob, err := um.Authenticate("bob@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	om := &OrganizationModel{DB: db}
+	org, err := om.Insert("Acme", "acme", 1)
+	assert.NilError(t, err)
+	_, err = db.Exec(`INSERT INTO organization_members (org_id, user_id, role, joined) VALUES (?, ?, ?, NOW())`, org, bob, RoleMember)
+	assert.NilError(t, err)
+
+	shared, err := sm.Insert("Shared", "content", 30, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, sm.SetOrg(shared, org))
+	assert.NilError(t, sm.RecordClient(shared, "192.0.2.1", "curl/8.0"))
+
+	em := &ErasureModel{DB: db}
+
+	_, err = em.Erase(1, 1)
+	assert.Equal(t, errors.Is(err, ErrLastOwner), true)
+
+	_, err = db.Exec(`UPDATE organization_members SET role = ? WHERE user_id = ?`, RoleOwner, bob)
+	assert.NilError(t, err)
+
+	e, err := em.Erase(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, e.EmailHash, EmailHash("alice@example.com"))
+
+	exists, err := um.Exists(1)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+
+	_, err = sm.Get(own)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	var collections int
+	assert.NilError(t, db.QueryRow(`SELECT COUNT(*) FROM collections`).Scan(&collections))
+	assert.Equal(t, collections, 0)
+
+	// The organization keeps its snippet, without anything identifying Alice.
+	s, err := sm.Get(shared)
+	assert.NilError(t, err)
+	assert.Equal(t, s.OwnerID, 0)
+
+	var ip, ua *string
+	assert.NilError(t, db.QueryRow(`SELECT creator_ip, creator_ua FROM snippets WHERE id = ?`, shared).Scan(&ip, &ua))
+	assert.Equal(t, ip == nil && ua == nil, true)
+
+	_, err = em.Erase(1, 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	found, err := em.ByEmail(" Alice@Example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 1)
+	assert.Equal(t, found[0].ID, e.ID)
+	assert.Equal(t, found[0].Summary, e.Summary)
+
+	found, err = em.ByEmail("bob@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, len(found), 0)
+}
@@ -14,4 +14,20 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrDuplicateUsername = errors.New("models: duplicate username")
+
+	ErrDuplicateCollection = errors.New("models: duplicate collection name")
+
+	ErrDuplicateOrganization = errors.New("models: duplicate organization slug")
+
+	ErrDuplicateSavedSearch = errors.New("models: duplicate saved search name")
+
+	ErrDuplicateSlug = errors.New("models: duplicate snippet slug")
+
+	ErrLastOwner = errors.New("models: organization would have no owner")
+
+	ErrPermissionDenied = errors.New("models: permission denied")
+
+	ErrLocked = errors.New("models: snippet is locked by another editor")
 )
@@ -0,0 +1,111 @@
+package models
+
+import "database/sql"
+
+// EachSnippet calls fn for every snippet in the database in ID order, including expired and held
+// ones, with its content decoded. It stops at the first error returned by fn.
+func (sm *SnippetModel) EachSnippet(fn func(*Snippet) error) error {
+
+	rows, err := sm.DB.Query(`SELECT ` + snippetColumns + ` FROM snippets ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := sm.scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Restore inserts a snippet with all of its fields, including its ID, as produced by EachSnippet.
+// An ID of 0 assigns a new ID. The content is encoded with the model's content settings. It returns
+// the ID of the inserted snippet. The restored version of the snippet becomes its first revision.
+func (sm *SnippetModel) Restore(s *Snippet) (int, error) {
+
+	encoded, err := sm.Content.Encode(s.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	hash, simhash := fingerprints(s.Content)
+
+	stmt := `INSERT INTO snippets (id, ulid, title, content, created, expires, updated, updated_by, owner_id, held, language, pinned, private, content_hash, simhash, shadowed,
+    slug, search_text, line_count, char_count, byte_size, reading_seconds)
+    VALUES(NULLIF(?, 0), NULLIF(?, ''), ?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?)`
+
+	args := []any{s.ID, s.ULID, s.Title, encoded, s.Created, sql.NullTime{Time: s.Expires, Valid: !s.Permanent()}, s.Updated, s.UpdatedBy, s.OwnerID, s.Held, s.Language, s.Pinned, s.Private, hash, simhash, s.Shadowed,
+		s.Slug, sm.searchText(s.Content)}
+
+	tx, err := sm.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(stmt, append(args, statsColumns(s.Content)...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := addRevision(tx, int(id), s.Title, encoded, s.Updated, s.UpdatedBy); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// EachUser calls fn for every user in the database in ID order, including their hashed password.
+// It stops at the first error returned by fn.
+func (um *UserModel) EachUser(fn func(*User) error) error {
+
+	stmt := `SELECT id, name, COALESCE(username, ''), email, hashed_password, created, admin FROM users ORDER BY id`
+
+	rows, err := um.DB.Query(stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := &User{}
+		err := rows.Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.HashedPassword, &u.Created, &u.Admin)
+		if err != nil {
+			return err
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Restore inserts a user with all of its fields, including its ID and hashed password, as produced
+// by EachUser. An ID of 0 assigns a new ID. It returns the ID of the inserted user.
+func (um *UserModel) Restore(u *User) (int, error) {
+
+	stmt := `INSERT INTO users (id, name, username, email, hashed_password, created, admin)
+    VALUES(NULLIF(?, 0), ?, NULLIF(?, ''), ?, ?, ?, ?)`
+
+	res, err := um.DB.Exec(stmt, u.ID, u.Name, u.Username, u.Email, u.HashedPassword, u.Created, u.Admin)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
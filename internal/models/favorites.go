@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// FavoriteModel wraps a sql.DB connection pool and provides methods for the favorites table, which
+// holds the snippets users starred.
+type FavoriteModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps new stars. It defaults to the system clock.
+}
+
+type FavoriteModelInterface interface {
+	Star(userID, snippetID int) error
+	Unstar(userID, snippetID int) error
+	Starred(userID, snippetID int) (bool, error)
+	Count(snippetID int) (int, error)
+	SnippetIDs(userID int) ([]int, error)
+}
+
+// Star adds a snippet to the favorites of a user. Starring a snippet twice does nothing.
+func (fm *FavoriteModel) Star(userID, snippetID int) error {
+
+	stmt := `INSERT IGNORE INTO favorites (user_id, snippet_id, created) VALUES (?, ?, ?)`
+
+	_, err := fm.DB.Exec(stmt, userID, snippetID, currentTime(fm.Clock))
+
+	return err
+}
+
+// Unstar removes a snippet from the favorites of a user.
+func (fm *FavoriteModel) Unstar(userID, snippetID int) error {
+
+	_, err := fm.DB.Exec(`DELETE FROM favorites WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+
+	return err
+}
+
+// Starred reports whether a user starred a snippet.
+func (fm *FavoriteModel) Starred(userID, snippetID int) (bool, error) {
+
+	var starred bool
+	err := fm.DB.QueryRow(`SELECT EXISTS (SELECT true FROM favorites WHERE user_id = ? AND snippet_id = ?)`,
+		userID, snippetID).Scan(&starred)
+
+	return starred, err
+}
+
+// Count returns the number of users who starred a snippet.
+func (fm *FavoriteModel) Count(snippetID int) (int, error) {
+
+	var n int
+	err := fm.DB.QueryRow(`SELECT COUNT(*) FROM favorites WHERE snippet_id = ?`, snippetID).Scan(&n)
+
+	return n, err
+}
+
+// SnippetIDs returns the IDs of the snippets a user starred, most recently starred first.
+func (fm *FavoriteModel) SnippetIDs(userID int) ([]int, error) {
+
+	rows, err := fm.DB.Query(`SELECT snippet_id FROM favorites WHERE user_id = ? ORDER BY created DESC, snippet_id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
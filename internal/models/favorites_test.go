@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestFavoriteModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	fm := &FavoriteModel{DB: db}
+
+	// Starring twice counts once.
+	assert.NilError(t, fm.Star(1, 1))
+	assert.NilError(t, fm.Star(1, 1))
+	assert.NilError(t, fm.Star(2, 1))
+
+	n, err := fm.Count(1)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	starred, err := fm.Starred(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, true)
+
+	ids, err := fm.SnippetIDs(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(ids), 1)
+	assert.Equal(t, ids[0], 1)
+
+	assert.NilError(t, fm.Unstar(1, 1))
+
+	starred, err = fm.Starred(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, false)
+
+	n, err = fm.Count(1)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// FilterRule is a content filter rule managed by admins, in the syntax of the filter package:
+// an action (hold, shadow or reject), a kind (word, regex or urls) and a pattern.
+type FilterRule struct {
+	ID        int       // ID is the unique identifier of the rule.
+	Action    string    // Action is what happens to snippets the rule matches.
+	Kind      string    // Kind says how the pattern is matched.
+	Pattern   string    // Pattern is the word, regular expression or URL count of the rule.
+	CreatedBy int       // CreatedBy is the ID of the admin who added the rule, or 0 if they've been erased.
+	Created   time.Time // Created is when the rule was added.
+}
+
+// FilterHit records a snippet caught by the content filter, for the audit trail moderators review.
+type FilterHit struct {
+	ID        int       // ID is the unique identifier of the hit.
+	RuleID    int       // RuleID is the ID of the rule that matched, or 0 for rules from the blocklist file.
+	Rule      string    // Rule is the rule as it read when it matched, such as "hold word casino".
+	Action    string    // Action is what was done to the snippet.
+	UserID    int       // UserID is the ID of the user who wrote the snippet, or 0 if they've been erased.
+	SnippetID int       // SnippetID is the ID of the snippet, or 0 if it was rejected.
+	ULID      string    // ULID is the public identifier of the snippet, or empty for old snippets. RecordHit ignores it.
+	Country   string    // Country is the country code of the client, or empty if it isn't known.
+	Created   time.Time // Created is when the filter matched.
+}
+
+// PublicID returns the identifier to use in links to the snippet.
+func (h *FilterHit) PublicID() string {
+	s := Snippet{ID: h.SnippetID, ULID: h.ULID}
+	return s.PublicID()
+}
+
+// FilterModel wraps a sql.DB connection pool and provides methods for the filter_rules and
+// filter_hits tables.
+type FilterModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps new rules and hits. It defaults to the system clock.
+}
+
+type FilterModelInterface interface {
+	Rules() ([]*FilterRule, error)
+	InsertRule(action, kind, pattern string, createdBy int) (int, error)
+	DeleteRule(id int) error
+	RecordHit(hit FilterHit) error
+	RecentHits(limit int) ([]*FilterHit, error)
+}
+
+// Rules returns every rule in the order they were added.
+func (fm *FilterModel) Rules() ([]*FilterRule, error) {
+
+	rows, err := fm.DB.Query(`SELECT id, action, kind, pattern, created_by, created FROM filter_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*FilterRule{}
+	for rows.Next() {
+		r := &FilterRule{}
+		if err := rows.Scan(&r.ID, &r.Action, &r.Kind, &r.Pattern, &r.CreatedBy, &r.Created); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// InsertRule adds a rule and returns its ID. The rule is expected to have been compiled with the
+// filter package already, so that a rule that doesn't compile never reaches the table.
+func (fm *FilterModel) InsertRule(action, kind, pattern string, createdBy int) (int, error) {
+
+	stmt := `INSERT INTO filter_rules (action, kind, pattern, created_by, created) VALUES (?, ?, ?, ?, ?)`
+
+	res, err := fm.DB.Exec(stmt, action, kind, pattern, createdBy, currentTime(fm.Clock))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
+
+// DeleteRule deletes a rule. Its hits are kept, with the rule as it read. It returns ErrNoRecord if
+// there's no rule with the ID.
+func (fm *FilterModel) DeleteRule(id int) error {
+
+	res, err := fm.DB.Exec(`DELETE FROM filter_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// RecordHit adds a hit to the audit trail. Its ID and creation time are set by the model.
+func (fm *FilterModel) RecordHit(hit FilterHit) error {
+
+	stmt := `INSERT INTO filter_hits (rule_id, rule, action, user_id, snippet_id, country, created) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := fm.DB.Exec(stmt, hit.RuleID, hit.Rule, hit.Action, hit.UserID, hit.SnippetID, hit.Country, currentTime(fm.Clock))
+
+	return err
+}
+
+// RecentHits returns the most recent hits, newest first.
+func (fm *FilterModel) RecentHits(limit int) ([]*FilterHit, error) {
+
+	stmt := `SELECT h.id, h.rule_id, h.rule, h.action, h.user_id, h.snippet_id, COALESCE(s.ulid, ''), h.country, h.created FROM filter_hits h
+    LEFT JOIN snippets s ON s.id = h.snippet_id
+    ORDER BY h.id DESC LIMIT ?`
+
+	rows, err := fm.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []*FilterHit{}
+	for rows.Next() {
+		h := &FilterHit{}
+		if err := rows.Scan(&h.ID, &h.RuleID, &h.Rule, &h.Action, &h.UserID, &h.SnippetID, &h.ULID, &h.Country, &h.Created); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+
+	return hits, rows.Err()
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestFilterModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	fm := &FilterModel{DB: db}
+
+	id, err := fm.InsertRule("shadow", "word", "casino", 1)
+	assert.NilError(t, err)
+
+	rules, err := fm.Rules()
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 1)
+	assert.Equal(t, rules[0].Action, "shadow")
+	assert.Equal(t, rules[0].Pattern, "casino")
+	assert.Equal(t, rules[0].CreatedBy, 1)
+
+	assert.NilError(t, fm.RecordHit(FilterHit{RuleID: id, Rule: "shadow word casino", Action: "shadow", UserID: 1, SnippetID: 1}))
+	assert.NilError(t, fm.RecordHit(FilterHit{Rule: "reject word spam", Action: "reject", UserID: 1, Country: "FR"}))
+
+	hits, err := fm.RecentHits(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(hits), 2)
+	assert.Equal(t, hits[0].Rule, "reject word spam")
+	assert.Equal(t, hits[0].SnippetID, 0)
+	assert.Equal(t, hits[0].Country, "FR")
+	assert.Equal(t, hits[1].RuleID, id)
+
+	// Hits outlive the rule that recorded them.
+	assert.NilError(t, fm.DeleteRule(id))
+	assert.Equal(t, errors.Is(fm.DeleteRule(id), ErrNoRecord), true)
+
+	hits, err = fm.RecentHits(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(hits), 2)
+
+	// A shadowed snippet keeps the mark until it's cleared.
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	sid, err := sm.Insert("Casino night", "content", 7, 1)
+	assert.NilError(t, err)
+
+	assert.NilError(t, sm.SetShadowed(sid, true))
+	s, err := sm.Get(sid)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Shadowed, true)
+
+	// Snippets can be held and shadowed from the moment they're inserted.
+	held, err := sm.InsertWith("Casino night", "content", 7, 1, Visibility{Held: true, Shadowed: true})
+	assert.NilError(t, err)
+
+	s, err = sm.Get(held)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Held, true)
+	assert.Equal(t, s.Shadowed, true)
+
+	// Hits link to the snippet by its public ID.
+	assert.NilError(t, fm.RecordHit(FilterHit{Rule: "hold word casino", Action: "hold", UserID: 1, SnippetID: held}))
+	hits, err = fm.RecentHits(1)
+	assert.NilError(t, err)
+	assert.Equal(t, hits[0].PublicID(), s.PublicID())
+}
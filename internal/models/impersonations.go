@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// Impersonation records an admin taking over the session of a user, for the audit trail of the
+// admin dashboard.
+type Impersonation struct {
+	ID      int       // ID is the unique identifier of the impersonation.
+	AdminID int       // AdminID is the ID of the admin, or 0 if they've been erased.
+	UserID  int       // UserID is the ID of the impersonated user, or 0 if they've been erased.
+	Reason  string    // Reason is why the admin impersonated the user, such as the support ticket.
+	IP      string    // IP is the address the admin impersonated the user from.
+	Country string    // Country is the country code of IP, or empty if it isn't known.
+	Started time.Time // Started is when the impersonation started.
+	Ended   time.Time // Ended is when the impersonation was stopped, or the zero time while it lasts.
+	Actions int       // Actions is the number of requests the admin made during the impersonation.
+}
+
+// ImpersonationAction records a request an admin made while impersonating a user.
+type ImpersonationAction struct {
+	ID              int       // ID is the unique identifier of the action.
+	ImpersonationID int       // ImpersonationID is the ID of the impersonation it was made during.
+	Method          string    // Method is the HTTP method of the request.
+	Path            string    // Path is the path of the request, without the query string.
+	Status          int       // Status is the status of the response.
+	Created         time.Time // Created is when the request was made.
+}
+
+// ImpersonationModel wraps a sql.DB connection pool and provides methods for the impersonations
+// table.
+type ImpersonationModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps impersonations. It defaults to the system clock.
+}
+
+type ImpersonationModelInterface interface {
+	Start(adminID, userID int, reason, ip, country string) (int, error)
+	Stop(id int) error
+	Get(id int) (*Impersonation, error)
+	Recent(limit int) ([]*Impersonation, error)
+	RecordAction(impersonationID int, method, path string, status int) error
+	Actions(impersonationID int) ([]*ImpersonationAction, error)
+}
+
+// Start records the start of an impersonation and returns its ID.
+func (im *ImpersonationModel) Start(adminID, userID int, reason, ip, country string) (int, error) {
+
+	stmt := `INSERT INTO impersonations (admin_id, user_id, reason, ip, country, started) VALUES (?, ?, ?, ?, ?, ?)`
+
+	res, err := im.DB.Exec(stmt, adminID, userID, reason, ip, country, currentTime(im.Clock))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
+
+// Stop records the end of an impersonation. Stopping one that has already ended keeps the time it
+// first ended. It returns ErrNoRecord if there's no impersonation with the ID.
+func (im *ImpersonationModel) Stop(id int) error {
+
+	var exists bool
+	err := im.DB.QueryRow(`SELECT EXISTS(SELECT true FROM impersonations WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	_, err = im.DB.Exec(`UPDATE impersonations SET ended = ? WHERE id = ? AND ended IS NULL`, currentTime(im.Clock), id)
+
+	return err
+}
+
+// Get returns the impersonation with the ID, or ErrNoRecord if there's none.
+func (im *ImpersonationModel) Get(id int) (*Impersonation, error) {
+
+	stmt := `SELECT ` + impersonationColumns + ` FROM impersonations i WHERE i.id = ?`
+
+	i, err := scanImpersonation(im.DB.QueryRow(stmt, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+
+	return i, err
+}
+
+// Recent returns the most recent impersonations, newest first.
+func (im *ImpersonationModel) Recent(limit int) ([]*Impersonation, error) {
+
+	stmt := `SELECT ` + impersonationColumns + ` FROM impersonations i ORDER BY i.id DESC LIMIT ?`
+
+	rows, err := im.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	impersonations := []*Impersonation{}
+	for rows.Next() {
+		i, err := scanImpersonation(rows)
+		if err != nil {
+			return nil, err
+		}
+		impersonations = append(impersonations, i)
+	}
+
+	return impersonations, rows.Err()
+}
+
+// impersonationColumns are the columns scanImpersonation reads, from the impersonations table
+// aliased as i.
+const impersonationColumns = `i.id, i.admin_id, i.user_id, i.reason, i.ip, i.country, i.started, i.ended,
+    (SELECT COUNT(*) FROM impersonation_actions a WHERE a.impersonation_id = i.id)`
+
+// scanImpersonation reads an impersonation from a row of impersonationColumns.
+func scanImpersonation(row rowScanner) (*Impersonation, error) {
+	i := &Impersonation{}
+	var ended sql.NullTime
+	if err := row.Scan(&i.ID, &i.AdminID, &i.UserID, &i.Reason, &i.IP, &i.Country, &i.Started, &ended, &i.Actions); err != nil {
+		return nil, err
+	}
+	i.Ended = ended.Time
+
+	return i, nil
+}
+
+// maxActionPath is the number of characters of a path the impersonation_actions table holds.
+const maxActionPath = 255
+
+// RecordAction adds a request made during an impersonation to its audit trail. Paths longer than
+// the column are cut.
+func (im *ImpersonationModel) RecordAction(impersonationID int, method, path string, status int) error {
+
+	stmt := `INSERT INTO impersonation_actions (impersonation_id, method, path, status, created) VALUES (?, ?, ?, ?, ?)`
+
+	path = truncateRunes(strings.ToValidUTF8(path, "\uFFFD"), maxActionPath)
+	_, err := im.DB.Exec(stmt, impersonationID, method, path, status, currentTime(im.Clock))
+
+	return err
+}
+
+// Actions returns the requests made during an impersonation, in the order they were made.
+func (im *ImpersonationModel) Actions(impersonationID int) ([]*ImpersonationAction, error) {
+
+	stmt := `SELECT id, impersonation_id, method, path, status, created FROM impersonation_actions
+    WHERE impersonation_id = ? ORDER BY id`
+
+	rows, err := im.DB.Query(stmt, impersonationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*ImpersonationAction{}
+	for rows.Next() {
+		a := &ImpersonationAction{}
+		if err := rows.Scan(&a.ID, &a.ImpersonationID, &a.Method, &a.Path, &a.Status, &a.Created); err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestImpersonationModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	im := &ImpersonationModel{DB: newTestDB(t)}
+
+	id, err := im.Start(1, 2, "Ticket #42", "192.0.2.1", "DE")
+	assert.NilError(t, err)
+
+	recent, err := im.Recent(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(recent), 1)
+	assert.Equal(t, recent[0].AdminID, 1)
+	assert.Equal(t, recent[0].UserID, 2)
+	assert.Equal(t, recent[0].Reason, "Ticket #42")
+	assert.Equal(t, recent[0].Country, "DE")
+	assert.Equal(t, recent[0].Ended.IsZero(), true)
+
+	assert.NilError(t, im.Stop(id))
+	assert.Equal(t, errors.Is(im.Stop(id+1), ErrNoRecord), true)
+
+	recent, err = im.Recent(10)
+	assert.NilError(t, err)
+	assert.Equal(t, recent[0].Ended.IsZero(), false)
+
+	// The requests made during an impersonation are kept with it.
+	assert.NilError(t, im.RecordAction(id, "POST", "/snippet/delete/1", 303))
+	assert.NilError(t, im.RecordAction(id, "POST", "/account/tokens", 403))
+
+	actions, err := im.Actions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(actions), 2)
+	assert.Equal(t, actions[0].Path, "/snippet/delete/1")
+	assert.Equal(t, actions[1].Status, 403)
+
+	i, err := im.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, i.Actions, 2)
+
+	_, err = im.Get(id + 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,37 @@
+package models
+
+// License is a well-known license authors can put their snippets under.
+type License struct {
+	ID   string // ID is the SPDX identifier of the license, such as "MIT".
+	Name string // Name is the full name of the license.
+}
+
+// URL returns the page of the license on the SPDX license list, which has its text.
+func (l License) URL() string {
+	return "https://spdx.org/licenses/" + l.ID + ".html"
+}
+
+// Licenses lists the licenses offered when writing a snippet, in the order they're offered.
+// Authors can name any other license instead, which is stored as they wrote it.
+var Licenses = []License{
+	{ID: "MIT", Name: "MIT License"},
+	{ID: "Apache-2.0", Name: "Apache License 2.0"},
+	{ID: "BSD-3-Clause", Name: `BSD 3-Clause "New" or "Revised" License`},
+	{ID: "GPL-3.0-or-later", Name: "GNU General Public License v3.0 or later"},
+	{ID: "MPL-2.0", Name: "Mozilla Public License 2.0"},
+	{ID: "Unlicense", Name: "The Unlicense"},
+	{ID: "CC0-1.0", Name: "Creative Commons Zero v1.0 Universal"},
+	{ID: "CC-BY-4.0", Name: "Creative Commons Attribution 4.0 International"},
+}
+
+// KnownLicense returns the well-known license with the SPDX identifier id, and whether there is
+// one.
+func KnownLicense(id string) (License, bool) {
+	for _, l := range Licenses {
+		if l.ID == id {
+			return l, true
+		}
+	}
+
+	return License{}, false
+}
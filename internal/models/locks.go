@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// LockLifetime is how long an edit lock lasts unless its holder renews it. The edit form renews
+// it well before then while it's open.
+const LockLifetime = 2 * time.Minute
+
+// EditLock is an advisory lock on a snippet, held by the user who has its edit form open.
+type EditLock struct {
+	SnippetID int       // SnippetID is the ID of the locked snippet.
+	UserID    int       // UserID is the ID of the user holding the lock.
+	UserName  string    // UserName is the name of the user holding the lock.
+	Acquired  time.Time // Acquired is when the user opened the edit form.
+	Expires   time.Time // Expires is when the lock lapses unless it's renewed.
+}
+
+// EditLockModel wraps a sql.DB connection pool and provides methods for the snippet_locks table.
+type EditLockModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock decides which locks have expired. It defaults to the system clock.
+}
+
+type EditLockModelInterface interface {
+	Acquire(snippetID, userID int) (*EditLock, error)
+	Release(snippetID, userID int) error
+}
+
+// Acquire takes the lock on a snippet for a user, or renews it if they already hold it. If another
+// user holds an unexpired lock, it returns their lock and ErrLocked.
+func (lm *EditLockModel) Acquire(snippetID, userID int) (*EditLock, error) {
+
+	tx, err := lm.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := currentTime(lm.Clock)
+
+	_, err = tx.Exec(`DELETE FROM snippet_locks WHERE snippet_id = ? AND expires <= ?`, snippetID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the holder's own lock is renewed; someone else's is left as it is.
+	_, err = tx.Exec(`INSERT INTO snippet_locks (snippet_id, user_id, acquired, expires) VALUES (?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE expires = IF(user_id = VALUES(user_id), VALUES(expires), expires)`,
+		snippetID, userID, now, now.Add(LockLifetime))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &EditLock{SnippetID: snippetID}
+
+	err = tx.QueryRow(`SELECT l.user_id, COALESCE(u.name, ''), l.acquired, l.expires
+    FROM snippet_locks l LEFT JOIN users u ON u.id = l.user_id WHERE l.snippet_id = ?`, snippetID).
+		Scan(&l.UserID, &l.UserName, &l.Acquired, &l.Expires)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if l.UserID != userID {
+		return l, ErrLocked
+	}
+
+	return l, nil
+}
+
+// Release gives up the lock of a user on a snippet. It does nothing if they don't hold it.
+func (lm *EditLockModel) Release(snippetID, userID int) error {
+
+	_, err := lm.DB.Exec(`DELETE FROM snippet_locks WHERE snippet_id = ? AND user_id = ?`, snippetID, userID)
+
+	return err
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestEditLockModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	lm := &EditLockModel{DB: db, Clock: frozen}
+
+	lock, err := lm.Acquire(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, lock.UserID, 1)
+	assert.Equal(t, lock.UserName, "Alice Jones")
+
+	// Another editor is told who holds the lock, and doesn't take it over.
+	lock, err = lm.Acquire(1, 2)
+	assert.Equal(t, errors.Is(err, ErrLocked), true)
+	assert.Equal(t, lock.UserID, 1)
+
+	// Renewing keeps the lock for longer.
+	frozen.Advance(time.Minute)
+	lock, err = lm.Acquire(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, lock.Expires.Equal(frozen.Now().Add(LockLifetime)), true)
+
+	// A lock that isn't renewed lapses.
+	frozen.Advance(LockLifetime)
+	lock, err = lm.Acquire(1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, lock.UserID, 2)
+
+	// Only the holder can release the lock.
+	assert.NilError(t, lm.Release(1, 1))
+	_, err = lm.Acquire(1, 1)
+	assert.Equal(t, errors.Is(err, ErrLocked), true)
+
+	assert.NilError(t, lm.Release(1, 2))
+	_, err = lm.Acquire(1, 1)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// The limits of the metadata of a snippet.
+const (
+	MaxMetadataFields = 10  // MaxMetadataFields is the number of pairs a snippet can have.
+	MaxMetadataName   = 40  // MaxMetadataName is the length of the longest name, in characters.
+	MaxMetadataValue  = 200 // MaxMetadataValue is the length of the longest value, in characters.
+)
+
+// MetadataField is a name/value pair an author describes a snippet with, such as "os: linux".
+type MetadataField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Metadata returns the metadata of a snippet in the order its author wrote it.
+func (sm *SnippetModel) Metadata(id int) ([]MetadataField, error) {
+
+	rows, err := sm.DB.Query(`SELECT name, value FROM snippet_metadata WHERE snippet_id = ? ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []MetadataField{}
+	for rows.Next() {
+		var f MetadataField
+		if err := rows.Scan(&f.Name, &f.Value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, rows.Err()
+}
+
+// SetMetadata replaces the metadata of a snippet with fields, which must be within the limits and
+// have distinct names. An empty fields removes it.
+func (sm *SnippetModel) SetMetadata(id int, fields []MetadataField) error {
+
+	if err := checkMetadata(fields); err != nil {
+		return err
+	}
+
+	tx, err := sm.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM snippet_metadata WHERE snippet_id = ?`, id); err != nil {
+		return err
+	}
+
+	for i, f := range fields {
+		_, err := tx.Exec(`INSERT INTO snippet_metadata (snippet_id, position, name, value) VALUES (?, ?, ?, ?)`, id, i, f.Name, f.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// checkMetadata reports fields over the limits, and names used twice.
+func checkMetadata(fields []MetadataField) error {
+	if len(fields) > MaxMetadataFields {
+		return fmt.Errorf("models: %d metadata fields, at most %d are allowed", len(fields), MaxMetadataFields)
+	}
+
+	names := map[string]bool{}
+	for _, f := range fields {
+		if f.Name == "" || utf8.RuneCountInString(f.Name) > MaxMetadataName || utf8.RuneCountInString(f.Value) > MaxMetadataValue {
+			return errors.New("models: metadata name or value out of bounds")
+		}
+		if names[f.Name] {
+			return fmt.Errorf("models: metadata name %q used twice", f.Name)
+		}
+		names[f.Name] = true
+	}
+
+	return nil
+}
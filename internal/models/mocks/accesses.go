@@ -0,0 +1,74 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// AccessModel is an in-memory implementation of models.AccessModelInterface.
+type AccessModel struct {
+	Clock clock.Clock // Clock decides which entries are past the retention period. It defaults to the system clock.
+
+	mu       sync.Mutex
+	accesses []models.Access
+}
+
+// NewAccessModel returns an empty AccessModel.
+func NewAccessModel() *AccessModel {
+	return &AccessModel{}
+}
+
+func (am *AccessModel) Add(accesses []models.Access) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.accesses = append(am.accesses, accesses...)
+
+	return nil
+}
+
+func (am *AccessModel) BySnippet(snippetID int, limit int) ([]*models.Access, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	accesses := []*models.Access{}
+	for i := range am.accesses {
+		if am.accesses[i].SnippetID == snippetID {
+			a := am.accesses[i]
+			accesses = append(accesses, &a)
+		}
+	}
+
+	sort.SliceStable(accesses, func(i, j int) bool {
+		return accesses[i].Accessed.After(accesses[j].Accessed)
+	})
+
+	if len(accesses) > limit {
+		accesses = accesses[:limit]
+	}
+
+	return accesses, nil
+}
+
+func (am *AccessModel) Purge(retention time.Duration) (int64, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	cutoff := clock.Now(am.Clock).Add(-retention)
+
+	kept := []models.Access{}
+	for _, a := range am.accesses {
+		if !a.Accessed.Before(cutoff) {
+			kept = append(kept, a)
+		}
+	}
+
+	n := int64(len(am.accesses) - len(kept))
+	am.accesses = kept
+
+	return n, nil
+}
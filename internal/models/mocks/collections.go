@@ -0,0 +1,154 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// CollectionModel is an in-memory implementation of models.CollectionModelInterface.
+type CollectionModel struct {
+	mu          sync.Mutex
+	collections map[int]*models.Collection
+	snippets    map[int][]int // snippets maps a collection ID to its snippet IDs, most recently added first.
+	nextID      int
+}
+
+// NewCollectionModel returns a CollectionModel with one private collection of the mock user,
+// holding mockSnippet.
+func NewCollectionModel() *CollectionModel {
+	return &CollectionModel{
+		collections: map[int]*models.Collection{
+			1: {ID: 1, OwnerID: 1, Name: "Poems", Created: clock.Now(nil)},
+		},
+		snippets: map[int][]int{1: {mockSnippet.ID}},
+		nextID:   2,
+	}
+}
+
+func (cm *CollectionModel) Insert(ownerID int, name string, public bool) (int, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for _, c := range cm.collections {
+		if c.OwnerID == ownerID && c.Name == name {
+			return 0, models.ErrDuplicateCollection
+		}
+	}
+
+	id := cm.nextID
+	cm.nextID++
+	cm.collections[id] = &models.Collection{ID: id, OwnerID: ownerID, Name: name, Public: public, Created: clock.Now(nil)}
+
+	return id, nil
+}
+
+func (cm *CollectionModel) Get(id int) (*models.Collection, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	c, ok := cm.collections[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return cm.withCount(c), nil
+}
+
+func (cm *CollectionModel) ByOwner(ownerID int) ([]*models.Collection, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	collections := []*models.Collection{}
+	for _, c := range cm.collections {
+		if c.OwnerID == ownerID {
+			collections = append(collections, cm.withCount(c))
+		}
+	}
+
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Name < collections[j].Name
+	})
+
+	return collections, nil
+}
+
+func (cm *CollectionModel) Update(id int, name string, public bool) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	c, ok := cm.collections[id]
+	if !ok {
+		return nil
+	}
+
+	for _, other := range cm.collections {
+		if other.ID != id && other.OwnerID == c.OwnerID && other.Name == name {
+			return models.ErrDuplicateCollection
+		}
+	}
+
+	c.Name, c.Public = name, public
+
+	return nil
+}
+
+func (cm *CollectionModel) Delete(id int) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, ok := cm.collections[id]; !ok {
+		return models.ErrNoRecord
+	}
+
+	delete(cm.collections, id)
+	delete(cm.snippets, id)
+
+	return nil
+}
+
+func (cm *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for _, id := range cm.snippets[collectionID] {
+		if id == snippetID {
+			return nil
+		}
+	}
+
+	cm.snippets[collectionID] = append([]int{snippetID}, cm.snippets[collectionID]...)
+
+	return nil
+}
+
+func (cm *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	ids := []int{}
+	for _, id := range cm.snippets[collectionID] {
+		if id != snippetID {
+			ids = append(ids, id)
+		}
+	}
+	cm.snippets[collectionID] = ids
+
+	return nil
+}
+
+func (cm *CollectionModel) SnippetIDs(collectionID int) ([]int, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	return append([]int{}, cm.snippets[collectionID]...), nil
+}
+
+// withCount returns a copy of a collection with its snippet count filled in.
+func (cm *CollectionModel) withCount(c *models.Collection) *models.Collection {
+	cp := *c
+	cp.Snippets = len(cm.snippets[c.ID])
+	return &cp
+}
@@ -0,0 +1,151 @@
+package mocks
+
+import (
+	"math"
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// DataExportModel is an in-memory implementation of models.DataExportModelInterface. Collect
+// reports the profile from mockUsers and the snippets of Snippets the user owns.
+type DataExportModel struct {
+	Clock    clock.Clock   // Clock timestamps exports. It defaults to the system clock.
+	Snippets *SnippetModel // Snippets holds the snippets reported by Collect.
+
+	mu       sync.Mutex
+	exports  []*models.DataExport
+	archives map[int][]byte
+}
+
+// NewDataExportModel returns a DataExportModel without exports, for the snippets of snippets.
+func NewDataExportModel(snippets *SnippetModel) *DataExportModel {
+	return &DataExportModel{Snippets: snippets, archives: map[int][]byte{}}
+}
+
+func (dm *DataExportModel) Request(userID int) (*models.DataExport, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for _, e := range dm.exports {
+		if e.UserID == userID && (e.Status == models.ExportPending || e.Status == models.ExportBuilding) {
+			cp := *e
+			return &cp, nil
+		}
+	}
+
+	e := &models.DataExport{ID: len(dm.exports) + 1, UserID: userID, Status: models.ExportPending, Requested: clock.Now(dm.Clock)}
+	dm.exports = append(dm.exports, e)
+
+	cp := *e
+	return &cp, nil
+}
+
+func (dm *DataExportModel) Latest(userID int) (*models.DataExport, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for i := len(dm.exports) - 1; i >= 0; i-- {
+		if e := dm.exports[i]; e.UserID == userID {
+			cp := *e
+			return &cp, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (dm *DataExportModel) Pending() ([]*models.DataExport, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	pending := []*models.DataExport{}
+	for _, e := range dm.exports {
+		if e.Status == models.ExportPending {
+			cp := *e
+			pending = append(pending, &cp)
+		}
+	}
+
+	return pending, nil
+}
+
+func (dm *DataExportModel) Claim(e *models.DataExport) (bool, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	current := dm.exports[e.ID-1]
+	if current.Status != models.ExportPending {
+		return false, nil
+	}
+	current.Status = models.ExportBuilding
+
+	return true, nil
+}
+
+func (dm *DataExportModel) Complete(id int, archive []byte) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	now := clock.Now(dm.Clock)
+
+	e := dm.exports[id-1]
+	e.Status, e.Completed, e.Expires = models.ExportReady, now, now.Add(models.DataExportLifetime)
+	dm.archives[id] = archive
+
+	return nil
+}
+
+func (dm *DataExportModel) Fail(id int) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	dm.exports[id-1].Status = models.ExportFailed
+
+	return nil
+}
+
+func (dm *DataExportModel) Archive(id, userID int) ([]byte, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if id < 1 || id > len(dm.exports) {
+		return nil, models.ErrNoRecord
+	}
+
+	e := dm.exports[id-1]
+	if e.UserID != userID || e.Status != models.ExportReady || !e.Expires.After(clock.Now(dm.Clock)) {
+		return nil, models.ErrNoRecord
+	}
+
+	return dm.archives[id], nil
+}
+
+func (dm *DataExportModel) Collect(userID int) (*models.UserData, error) {
+	d := &models.UserData{DigestFrequency: models.DigestOff, Preferences: models.DefaultPreferences}
+
+	found := false
+	for _, u := range mockUsers {
+		if u.ID == userID {
+			d.Profile = models.UserProfile{ID: u.ID, Name: u.Name, Username: u.Username, Email: u.Email, Created: u.Created, Admin: u.Admin}
+			found = true
+		}
+	}
+	if !found {
+		return nil, models.ErrNoRecord
+	}
+
+	for _, s := range dm.Snippets.list(math.MaxInt, func(s *models.Snippet) bool { return s.OwnerID == userID }) {
+		metadata, _ := dm.Snippets.Metadata(s.ID)
+		if len(metadata) == 0 {
+			metadata = nil
+		}
+		d.Snippets = append(d.Snippets, models.UserSnippet{
+			ID: s.ID, ULID: s.ULID, Title: s.Title, Content: s.Content, License: s.License, Metadata: metadata,
+			Created: s.Created, Expires: s.Expires, Updated: s.Updated,
+		})
+	}
+
+	return d, nil
+}
@@ -0,0 +1,114 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// DigestModel is an in-memory implementation of models.DigestModelInterface. Recipients are looked
+// up in mockUsers, and the activity it reports is whatever tests put in Activities.
+type DigestModel struct {
+	Clock      clock.Clock                       // Clock decides which digests are due. It defaults to the system clock.
+	Activities map[int][]*models.SnippetActivity // Activities maps a user ID to the activity returned by Activity.
+
+	mu            sync.Mutex
+	subscriptions map[int]*models.DigestRecipient
+}
+
+// NewDigestModel returns a DigestModel without subscriptions.
+func NewDigestModel() *DigestModel {
+	return &DigestModel{
+		Activities:    map[int][]*models.SnippetActivity{},
+		subscriptions: map[int]*models.DigestRecipient{},
+	}
+}
+
+func (dm *DigestModel) Frequency(userID int) (string, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if d, ok := dm.subscriptions[userID]; ok {
+		return d.Frequency, nil
+	}
+
+	return models.DigestOff, nil
+}
+
+func (dm *DigestModel) SetFrequency(userID int, frequency string) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if frequency == models.DigestOff {
+		delete(dm.subscriptions, userID)
+		return nil
+	}
+
+	if d, ok := dm.subscriptions[userID]; ok {
+		d.Frequency = frequency
+		return nil
+	}
+
+	d := &models.DigestRecipient{UserID: userID, Frequency: frequency}
+	for _, u := range mockUsers {
+		if u.ID == userID {
+			d.Name, d.Email = u.Name, u.Email
+		}
+	}
+	dm.subscriptions[userID] = d
+
+	return nil
+}
+
+func (dm *DigestModel) Due() ([]*models.DigestRecipient, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	now := clock.Now(dm.Clock)
+
+	due := []*models.DigestRecipient{}
+	for _, d := range dm.subscriptions {
+		period := 24 * time.Hour
+		if d.Frequency == models.DigestWeekly {
+			period *= 7
+		}
+		if d.LastSent.IsZero() || !d.LastSent.After(now.Add(-period)) {
+			cp := *d
+			due = append(due, &cp)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].UserID < due[j].UserID
+	})
+
+	return due, nil
+}
+
+func (dm *DigestModel) Claim(d *models.DigestRecipient) (bool, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	current, ok := dm.subscriptions[d.UserID]
+	if !ok || current.Frequency != d.Frequency || !current.LastSent.Equal(d.LastSent) {
+		return false, nil
+	}
+	current.LastSent = clock.Now(dm.Clock)
+
+	return true, nil
+}
+
+func (dm *DigestModel) Activity(userID int, since time.Time, limit int) ([]*models.SnippetActivity, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	activity := dm.Activities[userID]
+	if len(activity) > limit {
+		activity = activity[:limit]
+	}
+
+	return append([]*models.SnippetActivity{}, activity...), nil
+}
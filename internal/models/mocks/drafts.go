@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// DraftModel is an in-memory implementation of models.DraftModelInterface.
+type DraftModel struct {
+	Clock clock.Clock // Clock timestamps drafts. It defaults to the system clock.
+
+	mu     sync.Mutex
+	drafts map[int]*models.Draft
+}
+
+// NewDraftModel returns a DraftModel without drafts.
+func NewDraftModel() *DraftModel {
+	return &DraftModel{drafts: map[int]*models.Draft{}}
+}
+
+func (dm *DraftModel) Save(d models.Draft) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	d.Updated = clock.Now(dm.Clock)
+	dm.drafts[d.UserID] = &d
+
+	return nil
+}
+
+func (dm *DraftModel) Get(userID int) (*models.Draft, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	d, ok := dm.drafts[userID]
+	if !ok || !d.Updated.Add(models.DraftLifetime).After(clock.Now(dm.Clock)) {
+		return nil, models.ErrNoRecord
+	}
+	cp := *d
+
+	return &cp, nil
+}
+
+func (dm *DraftModel) Delete(userID, snippetID int) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if d, ok := dm.drafts[userID]; ok && d.SnippetID == snippetID {
+		delete(dm.drafts, userID)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// ErasureModel is an in-memory implementation of models.ErasureModelInterface. Erase removes the
+// user from Users and leaves everything else alone.
+type ErasureModel struct {
+	Clock clock.Clock // Clock timestamps tombstones. It defaults to the system clock.
+	Users *UserModel  // Users holds the users that can be erased.
+
+	mu       sync.Mutex
+	erasures []*models.Erasure
+}
+
+// NewErasureModel returns an ErasureModel without erasures, for the users of users.
+func NewErasureModel(users *UserModel) *ErasureModel {
+	return &ErasureModel{Users: users}
+}
+
+func (em *ErasureModel) Erase(userID, requestedBy int) (*models.Erasure, error) {
+	u := em.Users.remove(userID)
+	if u == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	e := &models.Erasure{
+		ID:          len(em.erasures) + 1,
+		UserID:      userID,
+		EmailHash:   models.EmailHash(u.Email),
+		RequestedBy: requestedBy,
+		Erased:      clock.Now(em.Clock),
+		Summary:     "users deleted: 1",
+	}
+	em.erasures = append(em.erasures, e)
+
+	return e, nil
+}
+
+func (em *ErasureModel) Recent(limit int) ([]*models.Erasure, error) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	erasures := []*models.Erasure{}
+	for i := len(em.erasures) - 1; i >= 0 && len(erasures) < limit; i-- {
+		erasures = append(erasures, em.erasures[i])
+	}
+
+	return erasures, nil
+}
+
+func (em *ErasureModel) ByEmail(email string) ([]*models.Erasure, error) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	hash := models.EmailHash(email)
+
+	erasures := []*models.Erasure{}
+	for i := len(em.erasures) - 1; i >= 0; i-- {
+		if em.erasures[i].EmailHash == hash {
+			erasures = append(erasures, em.erasures[i])
+		}
+	}
+
+	return erasures, nil
+}
@@ -0,0 +1,65 @@
+package mocks
+
+import (
+	"slices"
+	"sync"
+)
+
+// FavoriteModel is an in-memory implementation of models.FavoriteModelInterface.
+type FavoriteModel struct {
+	mu        sync.Mutex
+	favorites map[int][]int // favorites maps a user ID to the IDs of the snippets they starred, most recently starred first.
+}
+
+// NewFavoriteModel returns a FavoriteModel without favorites.
+func NewFavoriteModel() *FavoriteModel {
+	return &FavoriteModel{favorites: map[int][]int{}}
+}
+
+func (fm *FavoriteModel) Star(userID, snippetID int) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if !slices.Contains(fm.favorites[userID], snippetID) {
+		fm.favorites[userID] = append([]int{snippetID}, fm.favorites[userID]...)
+	}
+
+	return nil
+}
+
+func (fm *FavoriteModel) Unstar(userID, snippetID int) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	fm.favorites[userID] = slices.DeleteFunc(fm.favorites[userID], func(id int) bool { return id == snippetID })
+
+	return nil
+}
+
+func (fm *FavoriteModel) Starred(userID, snippetID int) (bool, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	return slices.Contains(fm.favorites[userID], snippetID), nil
+}
+
+func (fm *FavoriteModel) Count(snippetID int) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	n := 0
+	for _, ids := range fm.favorites {
+		if slices.Contains(ids, snippetID) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (fm *FavoriteModel) SnippetIDs(userID int) ([]int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	return append([]int{}, fm.favorites[userID]...), nil
+}
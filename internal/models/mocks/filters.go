@@ -0,0 +1,93 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// FilterModel is an in-memory implementation of models.FilterModelInterface.
+type FilterModel struct {
+	Clock clock.Clock // Clock timestamps new rules and hits. It defaults to the system clock.
+
+	// Snippets supplies the public IDs of the snippets hits link to. Without it they're linked
+	// by their integer ID.
+	Snippets *SnippetModel
+
+	mu     sync.Mutex
+	rules  []*models.FilterRule
+	hits   []*models.FilterHit
+	nextID int
+}
+
+// NewFilterModel returns a FilterModel without rules or hits.
+func NewFilterModel() *FilterModel {
+	return &FilterModel{nextID: 1}
+}
+
+func (fm *FilterModel) Rules() ([]*models.FilterRule, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	return append([]*models.FilterRule{}, fm.rules...), nil
+}
+
+func (fm *FilterModel) InsertRule(action, kind, pattern string, createdBy int) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	id := fm.nextID
+	fm.nextID++
+	fm.rules = append(fm.rules, &models.FilterRule{
+		ID:        id,
+		Action:    action,
+		Kind:      kind,
+		Pattern:   pattern,
+		CreatedBy: createdBy,
+		Created:   clock.Now(fm.Clock),
+	})
+
+	return id, nil
+}
+
+func (fm *FilterModel) DeleteRule(id int) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	for i, r := range fm.rules {
+		if r.ID == id {
+			fm.rules = append(fm.rules[:i], fm.rules[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (fm *FilterModel) RecordHit(hit models.FilterHit) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	hit.ID = len(fm.hits) + 1
+	hit.Created = clock.Now(fm.Clock)
+	fm.hits = append(fm.hits, &hit)
+
+	return nil
+}
+
+func (fm *FilterModel) RecentHits(limit int) ([]*models.FilterHit, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	hits := []*models.FilterHit{}
+	for i := len(fm.hits) - 1; i >= 0 && len(hits) < limit; i-- {
+		hit := *fm.hits[i]
+		if fm.Snippets != nil {
+			hit.ULID = fm.Snippets.ulidOf(hit.SnippetID)
+		}
+		hits = append(hits, &hit)
+	}
+
+	return hits, nil
+}
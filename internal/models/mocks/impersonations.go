@@ -0,0 +1,120 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// ImpersonationModel is an in-memory implementation of models.ImpersonationModelInterface.
+type ImpersonationModel struct {
+	Clock clock.Clock // Clock timestamps impersonations. It defaults to the system clock.
+
+	mu             sync.Mutex
+	impersonations []*models.Impersonation
+	actions        []*models.ImpersonationAction
+}
+
+// NewImpersonationModel returns an ImpersonationModel without impersonations.
+func NewImpersonationModel() *ImpersonationModel {
+	return &ImpersonationModel{}
+}
+
+func (im *ImpersonationModel) Start(adminID, userID int, reason, ip, country string) (int, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	i := &models.Impersonation{
+		ID:      len(im.impersonations) + 1,
+		AdminID: adminID,
+		UserID:  userID,
+		Reason:  reason,
+		IP:      ip,
+		Country: country,
+		Started: clock.Now(im.Clock),
+	}
+	im.impersonations = append(im.impersonations, i)
+
+	return i.ID, nil
+}
+
+func (im *ImpersonationModel) Stop(id int) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if id < 1 || id > len(im.impersonations) {
+		return models.ErrNoRecord
+	}
+
+	if i := im.impersonations[id-1]; i.Ended.IsZero() {
+		i.Ended = clock.Now(im.Clock)
+	}
+
+	return nil
+}
+
+func (im *ImpersonationModel) Get(id int) (*models.Impersonation, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	if id < 1 || id > len(im.impersonations) {
+		return nil, models.ErrNoRecord
+	}
+
+	return im.withActions(im.impersonations[id-1]), nil
+}
+
+func (im *ImpersonationModel) Recent(limit int) ([]*models.Impersonation, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	impersonations := []*models.Impersonation{}
+	for i := len(im.impersonations) - 1; i >= 0 && len(impersonations) < limit; i-- {
+		impersonations = append(impersonations, im.withActions(im.impersonations[i]))
+	}
+
+	return impersonations, nil
+}
+
+func (im *ImpersonationModel) RecordAction(impersonationID int, method, path string, status int) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.actions = append(im.actions, &models.ImpersonationAction{
+		ID:              len(im.actions) + 1,
+		ImpersonationID: impersonationID,
+		Method:          method,
+		Path:            path,
+		Status:          status,
+		Created:         clock.Now(im.Clock),
+	})
+
+	return nil
+}
+
+func (im *ImpersonationModel) Actions(impersonationID int) ([]*models.ImpersonationAction, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	actions := []*models.ImpersonationAction{}
+	for _, a := range im.actions {
+		if a.ImpersonationID == impersonationID {
+			c := *a
+			actions = append(actions, &c)
+		}
+	}
+
+	return actions, nil
+}
+
+// withActions returns a copy of an impersonation with the number of its actions.
+func (im *ImpersonationModel) withActions(i *models.Impersonation) *models.Impersonation {
+	c := *i
+	for _, a := range im.actions {
+		if a.ImpersonationID == c.ID {
+			c.Actions++
+		}
+	}
+	return &c
+}
@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// EditLockModel is an in-memory implementation of models.EditLockModelInterface.
+type EditLockModel struct {
+	Clock clock.Clock // Clock decides which locks have expired. It defaults to the system clock.
+
+	mu    sync.Mutex
+	users *UserModel
+	locks map[int]*models.EditLock
+}
+
+// NewEditLockModel returns an EditLockModel without locks, which names lock holders after the
+// users of users.
+func NewEditLockModel(users *UserModel) *EditLockModel {
+	return &EditLockModel{users: users, locks: map[int]*models.EditLock{}}
+}
+
+func (lm *EditLockModel) Acquire(snippetID, userID int) (*models.EditLock, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	now := clock.Now(lm.Clock)
+
+	l, ok := lm.locks[snippetID]
+	switch {
+	case !ok || !l.Expires.After(now):
+		l = &models.EditLock{SnippetID: snippetID, UserID: userID, Acquired: now}
+		if u, err := lm.users.Get(userID); err == nil {
+			l.UserName = u.Name
+		}
+		lm.locks[snippetID] = l
+	case l.UserID != userID:
+		cp := *l
+		return &cp, models.ErrLocked
+	}
+	l.Expires = now.Add(models.LockLifetime)
+
+	cp := *l
+
+	return &cp, nil
+}
+
+func (lm *EditLockModel) Release(snippetID, userID int) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if l, ok := lm.locks[snippetID]; ok && l.UserID == userID {
+		delete(lm.locks, snippetID)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// OrganizationModel is an in-memory implementation of models.OrganizationModelInterface. Member
+// names are looked up in mockUsers, so members who signed up during a test are listed without one.
+type OrganizationModel struct {
+	Clock clock.Clock // Clock decides which invitations have expired. It defaults to the system clock.
+
+	mu          sync.Mutex
+	orgs        map[int]*models.Organization
+	members     map[int]map[int]*models.Member // members maps an organization ID to its members by user ID.
+	invitations map[string]*models.Invitation  // invitations maps tokens to invitations.
+	nextID      int
+}
+
+// NewOrganizationModel returns an empty OrganizationModel.
+func NewOrganizationModel() *OrganizationModel {
+	return &OrganizationModel{
+		orgs:        map[int]*models.Organization{},
+		members:     map[int]map[int]*models.Member{},
+		invitations: map[string]*models.Invitation{},
+		nextID:      1,
+	}
+}
+
+func (om *OrganizationModel) Insert(name, slug string, ownerID int) (int, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	for _, o := range om.orgs {
+		if o.Slug == slug {
+			return 0, models.ErrDuplicateOrganization
+		}
+	}
+
+	id := om.nextID
+	om.nextID++
+	om.orgs[id] = &models.Organization{ID: id, Name: name, Slug: slug, Created: clock.Now(om.Clock)}
+	om.members[id] = map[int]*models.Member{}
+	om.addMember(id, ownerID, models.RoleOwner)
+
+	return id, nil
+}
+
+func (om *OrganizationModel) Get(id int) (*models.Organization, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	o, ok := om.orgs[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	cp := *o
+
+	return &cp, nil
+}
+
+func (om *OrganizationModel) GetBySlug(slug string) (*models.Organization, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	for _, o := range om.orgs {
+		if o.Slug == slug {
+			cp := *o
+			return &cp, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (om *OrganizationModel) ByMember(userID int) ([]*models.Organization, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	orgs := []*models.Organization{}
+	for id, members := range om.members {
+		if _, ok := members[userID]; ok {
+			cp := *om.orgs[id]
+			orgs = append(orgs, &cp)
+		}
+	}
+
+	sort.Slice(orgs, func(i, j int) bool {
+		return orgs[i].Name < orgs[j].Name
+	})
+
+	return orgs, nil
+}
+
+func (om *OrganizationModel) Role(orgID, userID int) (string, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	if m, ok := om.members[orgID][userID]; ok {
+		return m.Role, nil
+	}
+
+	return "", nil
+}
+
+func (om *OrganizationModel) Members(orgID int) ([]*models.Member, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	members := []*models.Member{}
+	for _, m := range om.members[orgID] {
+		cp := *m
+		members = append(members, &cp)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return members[i].Role == models.RoleOwner
+		}
+		return members[i].Username < members[j].Username
+	})
+
+	return members, nil
+}
+
+func (om *OrganizationModel) RemoveMember(orgID, userID int) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	m, ok := om.members[orgID][userID]
+	if !ok {
+		return models.ErrNoRecord
+	}
+
+	if m.Role == models.RoleOwner {
+		owners := 0
+		for _, other := range om.members[orgID] {
+			if other.Role == models.RoleOwner {
+				owners++
+			}
+		}
+		if owners <= 1 {
+			return models.ErrLastOwner
+		}
+	}
+
+	delete(om.members[orgID], userID)
+
+	return nil
+}
+
+func (om *OrganizationModel) Invite(orgID, invitedBy int, email, role string, expires time.Time) (string, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	o, ok := om.orgs[orgID]
+	if !ok {
+		return "", models.ErrNoRecord
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	om.invitations[token] = &models.Invitation{
+		ID:        om.nextID,
+		OrgID:     orgID,
+		OrgName:   o.Name,
+		OrgSlug:   o.Slug,
+		Email:     email,
+		Role:      role,
+		InvitedBy: invitedBy,
+		Created:   clock.Now(om.Clock),
+		Expires:   expires.Truncate(time.Second),
+	}
+	om.nextID++
+
+	return token, nil
+}
+
+func (om *OrganizationModel) Invitations(orgID int) ([]*models.Invitation, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	invitations := []*models.Invitation{}
+	for _, i := range om.invitations {
+		if i.OrgID == orgID && i.Expires.After(clock.Now(om.Clock)) {
+			cp := *i
+			invitations = append(invitations, &cp)
+		}
+	}
+
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].ID > invitations[j].ID
+	})
+
+	return invitations, nil
+}
+
+func (om *OrganizationModel) Invitation(token string) (*models.Invitation, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	i, ok := om.invitations[token]
+	if !ok || !i.Expires.After(clock.Now(om.Clock)) {
+		return nil, models.ErrNoRecord
+	}
+	cp := *i
+
+	return &cp, nil
+}
+
+func (om *OrganizationModel) AcceptInvitation(token string, userID int, email string) (*models.Invitation, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	i, ok := om.invitations[token]
+	if !ok || !i.Expires.After(clock.Now(om.Clock)) || !strings.EqualFold(i.Email, email) {
+		return nil, models.ErrNoRecord
+	}
+
+	delete(om.invitations, token)
+
+	if _, ok := om.members[i.OrgID][userID]; !ok {
+		om.addMember(i.OrgID, userID, i.Role)
+	}
+
+	return i, nil
+}
+
+// addMember adds a user to an organization, taking their name from mockUsers.
+func (om *OrganizationModel) addMember(orgID, userID int, role string) {
+	m := &models.Member{UserID: userID, Role: role, Joined: clock.Now(om.Clock)}
+
+	for _, u := range mockUsers {
+		if u.ID == userID {
+			m.Name, m.Username = u.Name, u.Username
+		}
+	}
+
+	om.members[orgID][userID] = m
+}
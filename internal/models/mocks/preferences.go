@@ -0,0 +1,42 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// PreferenceModel is an in-memory implementation of models.PreferenceModelInterface.
+type PreferenceModel struct {
+	mu          sync.Mutex
+	preferences map[int]models.Preferences // preferences maps a user ID to their preferences.
+}
+
+// NewPreferenceModel returns a PreferenceModel in which no user has chosen any preferences.
+func NewPreferenceModel() *PreferenceModel {
+	return &PreferenceModel{preferences: map[int]models.Preferences{}}
+}
+
+func (pm *PreferenceModel) Get(userID int) (models.Preferences, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if p, ok := pm.preferences[userID]; ok {
+		return p, nil
+	}
+
+	return models.DefaultPreferences, nil
+}
+
+func (pm *PreferenceModel) Set(userID int, p models.Preferences) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if p == models.DefaultPreferences {
+		delete(pm.preferences, userID)
+	} else {
+		pm.preferences[userID] = p
+	}
+
+	return nil
+}
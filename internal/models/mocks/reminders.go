@@ -0,0 +1,120 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// reminderKey signs the extension tokens of the mock.
+var reminderKey = []byte("mock reminder key of 32 bytes!!!")
+
+// ReminderModel is an in-memory implementation of models.ReminderModelInterface. Reminders are due
+// for the snippets of Snippets, whose owners are looked up in mockUsers.
+type ReminderModel struct {
+	Clock    clock.Clock   // Clock decides which reminders are due. It defaults to the system clock.
+	Snippets *SnippetModel // Snippets holds the snippets reminders are sent for.
+
+	mu       sync.Mutex
+	days     map[int]int       // days maps a user ID to the days before expiry they're reminded.
+	reminded map[int]time.Time // reminded maps a snippet ID to the expiry it was reminded about.
+}
+
+// NewReminderModel returns a ReminderModel without subscriptions, for the snippets of snippets.
+func NewReminderModel(snippets *SnippetModel) *ReminderModel {
+	return &ReminderModel{
+		Snippets: snippets,
+		days:     map[int]int{},
+		reminded: map[int]time.Time{},
+	}
+}
+
+func (rm *ReminderModel) Days(userID int) (int, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.days[userID], nil
+}
+
+func (rm *ReminderModel) SetDays(userID int, days int) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if days == 0 {
+		delete(rm.days, userID)
+	} else {
+		rm.days[userID] = days
+	}
+
+	return nil
+}
+
+func (rm *ReminderModel) Due() ([]*models.ExpiryReminder, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.Snippets.mu.Lock()
+	defer rm.Snippets.mu.Unlock()
+
+	now := clock.Now(rm.Clock)
+
+	due := []*models.ExpiryReminder{}
+	for _, s := range rm.Snippets.snippets {
+		days, ok := rm.days[s.OwnerID]
+		if !ok || !s.Expires.After(now) || s.Expires.After(now.AddDate(0, 0, days)) || rm.reminded[s.ID].Equal(s.Expires) {
+			continue
+		}
+
+		r := &models.ExpiryReminder{SnippetID: s.ID, ULID: s.ULID, Title: s.Title, Expires: s.Expires, UserID: s.OwnerID}
+		for _, u := range mockUsers {
+			if u.ID == s.OwnerID {
+				r.Name, r.Email = u.Name, u.Email
+			}
+		}
+		due = append(due, r)
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Expires.Before(due[j].Expires)
+	})
+
+	return due, nil
+}
+
+func (rm *ReminderModel) Claim(r *models.ExpiryReminder) (bool, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.reminded[r.SnippetID].Equal(r.Expires) {
+		return false, nil
+	}
+	rm.reminded[r.SnippetID] = r.Expires
+
+	return true, nil
+}
+
+func (rm *ReminderModel) Token(r *models.ExpiryReminder) string {
+	return models.SignExtendToken(reminderKey, r.SnippetID, r.Expires)
+}
+
+func (rm *ReminderModel) Extend(snippetID int, token string, by time.Duration) (time.Time, error) {
+	expires, err := models.ParseExtendToken(reminderKey, snippetID, token)
+	if err != nil {
+		return time.Time{}, models.ErrNoRecord
+	}
+
+	rm.Snippets.mu.Lock()
+	defer rm.Snippets.mu.Unlock()
+
+	s, ok := rm.Snippets.snippets[snippetID]
+	// Tokens hold the expiry to the second.
+	if !ok || s.Expires.Unix() != expires.Unix() || !s.Expires.After(clock.Now(rm.Clock)) {
+		return time.Time{}, models.ErrNoRecord
+	}
+	s.Expires = s.Expires.Add(by)
+
+	return s.Expires, nil
+}
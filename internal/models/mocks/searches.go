@@ -0,0 +1,156 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// SavedSearchModel is an in-memory implementation of models.SavedSearchModelInterface.
+type SavedSearchModel struct {
+	Clock clock.Clock // Clock timestamps new saved searches. It defaults to the system clock.
+
+	// Snippets supplies the newest snippet new saved searches start from. Without it they start
+	// from the beginning.
+	Snippets *SnippetModel
+
+	mu       sync.Mutex
+	searches map[int]*models.SavedSearch
+	nextID   int
+}
+
+// NewSavedSearchModel returns an empty SavedSearchModel.
+func NewSavedSearchModel() *SavedSearchModel {
+	return &SavedSearchModel{
+		searches: map[int]*models.SavedSearch{},
+		nextID:   1,
+	}
+}
+
+func (ssm *SavedSearchModel) Insert(userID int, name string, q models.SearchQuery, notify bool) (int, error) {
+	lastSeenID := ssm.lastSnippetID()
+
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+
+	for _, s := range ssm.searches {
+		if s.UserID == userID && s.Name == name {
+			return 0, models.ErrDuplicateSavedSearch
+		}
+	}
+
+	id := ssm.nextID
+	ssm.nextID++
+	ssm.searches[id] = &models.SavedSearch{
+		ID:         id,
+		UserID:     userID,
+		Name:       name,
+		Query:      q,
+		Notify:     notify,
+		LastSeenID: lastSeenID,
+		Created:    clock.Now(ssm.Clock),
+	}
+
+	return id, nil
+}
+
+func (ssm *SavedSearchModel) Get(id int) (*models.SavedSearch, error) {
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+
+	s, ok := ssm.searches[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	cp := *s
+
+	return &cp, nil
+}
+
+func (ssm *SavedSearchModel) ByUser(userID int) ([]*models.SavedSearch, error) {
+	searches := ssm.list(func(s *models.SavedSearch) bool {
+		return s.UserID == userID
+	})
+
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].Name < searches[j].Name
+	})
+
+	return searches, nil
+}
+
+func (ssm *SavedSearchModel) SetNotify(id int, notify bool) error {
+	lastSeenID := ssm.lastSnippetID()
+
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+
+	if s, ok := ssm.searches[id]; ok {
+		if notify && !s.Notify {
+			s.LastSeenID = lastSeenID
+		}
+		s.Notify = notify
+	}
+
+	return nil
+}
+
+func (ssm *SavedSearchModel) Delete(id int) error {
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+
+	delete(ssm.searches, id)
+
+	return nil
+}
+
+func (ssm *SavedSearchModel) Notifying() ([]*models.SavedSearch, error) {
+	searches := ssm.list(func(s *models.SavedSearch) bool {
+		return s.Notify
+	})
+
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].ID < searches[j].ID
+	})
+
+	return searches, nil
+}
+
+func (ssm *SavedSearchModel) Advance(s *models.SavedSearch, lastSeenID int) (bool, error) {
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+
+	current, ok := ssm.searches[s.ID]
+	if !ok || !current.Notify || current.LastSeenID != s.LastSeenID {
+		return false, nil
+	}
+	current.LastSeenID = lastSeenID
+
+	return true, nil
+}
+
+// lastSnippetID returns the ID of the newest snippet of Snippets, or 0 without it.
+func (ssm *SavedSearchModel) lastSnippetID() int {
+	if ssm.Snippets == nil {
+		return 0
+	}
+	return ssm.Snippets.lastID()
+}
+
+// list returns copies of the saved searches matching keep.
+func (ssm *SavedSearchModel) list(keep func(*models.SavedSearch) bool) []*models.SavedSearch {
+	ssm.mu.Lock()
+	defer ssm.mu.Unlock()
+
+	searches := []*models.SavedSearch{}
+	for _, s := range ssm.searches {
+		if keep(s) {
+			cp := *s
+			searches = append(searches, &cp)
+		}
+	}
+
+	return searches
+}
@@ -0,0 +1,91 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// shareKey signs the tokens of the mock share links.
+var shareKey = []byte("0123456789abcdef0123456789abcdef")
+
+// ShareModel is an in-memory implementation of models.ShareModelInterface. Its tokens are signed
+// like real ones, so handler tests exercise the same verification.
+type ShareModel struct {
+	Clock clock.Clock // Clock decides which links have expired. It defaults to the system clock.
+
+	mu     sync.Mutex
+	shares map[int]*models.Share
+	nextID int
+}
+
+// NewShareModel returns an empty ShareModel.
+func NewShareModel() *ShareModel {
+	return &ShareModel{shares: map[int]*models.Share{}, nextID: 1}
+}
+
+func (sm *ShareModel) Insert(snippetID, userID int, expires time.Time) (*models.Share, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	share := &models.Share{ID: sm.nextID, SnippetID: snippetID, CreatedBy: userID, Created: clock.Now(sm.Clock), Expires: expires.Truncate(time.Second)}
+	sm.shares[share.ID] = share
+	sm.nextID++
+
+	return share, nil
+}
+
+func (sm *ShareModel) BySnippet(snippetID int) ([]*models.Share, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	shares := []*models.Share{}
+	for _, s := range sm.shares {
+		if s.SnippetID == snippetID && s.Expires.After(clock.Now(sm.Clock)) {
+			shares = append(shares, s)
+		}
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].ID > shares[j].ID
+	})
+
+	return shares, nil
+}
+
+func (sm *ShareModel) Delete(snippetID, id int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.shares[id]
+	if !ok || s.SnippetID != snippetID {
+		return models.ErrNoRecord
+	}
+	delete(sm.shares, id)
+
+	return nil
+}
+
+func (sm *ShareModel) Token(share *models.Share) string {
+	return models.SignShareToken(shareKey, share)
+}
+
+func (sm *ShareModel) Verify(snippetID int, token string) (*models.Share, error) {
+	id, expires, err := models.ParseShareToken(shareKey, snippetID, token)
+	if err != nil || !expires.After(clock.Now(sm.Clock)) {
+		return nil, models.ErrNoRecord
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.shares[id]
+	if !ok || s.SnippetID != snippetID || !s.Expires.After(clock.Now(sm.Clock)) {
+		return nil, models.ErrNoRecord
+	}
+
+	return s, nil
+}
@@ -0,0 +1,80 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// ShortLinkModel is an in-memory implementation of models.ShortLinkModelInterface.
+type ShortLinkModel struct {
+	mu    sync.Mutex
+	links map[string]*models.ShortLink
+}
+
+// NewShortLinkModel returns an empty ShortLinkModel.
+func NewShortLinkModel() *ShortLinkModel {
+	return &ShortLinkModel{links: map[string]*models.ShortLink{}}
+}
+
+func (sm *ShortLinkModel) Create(snippetID, userID int) (*models.ShortLink, bool, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, l := range sm.links {
+		if l.SnippetID == snippetID {
+			cp := *l
+			return &cp, false, nil
+		}
+	}
+
+	code, err := models.NewShortCode()
+	if err != nil {
+		return nil, false, err
+	}
+
+	l := &models.ShortLink{Code: code, SnippetID: snippetID, CreatedBy: userID, Created: clock.Now(nil)}
+	sm.links[code] = l
+
+	cp := *l
+	return &cp, true, nil
+}
+
+func (sm *ShortLinkModel) Get(code string) (*models.ShortLink, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	l, ok := sm.links[code]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	cp := *l
+	return &cp, nil
+}
+
+func (sm *ShortLinkModel) ForSnippet(snippetID int) (*models.ShortLink, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, l := range sm.links {
+		if l.SnippetID == snippetID {
+			cp := *l
+			return &cp, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (sm *ShortLinkModel) Click(code string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if l, ok := sm.links[code]; ok {
+		l.Clicks++
+	}
+
+	return nil
+}
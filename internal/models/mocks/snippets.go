@@ -1,34 +1,665 @@
 package mocks
 
 import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/oklog/ulid/v2"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/fingerprint"
 	"snippetbox.adcon.dev/internal/models"
 )
 
-var mockSnippet = &models.Snippet{
+// mockSnippet is the snippet every new SnippetModel starts with.
+var mockSnippet = models.Snippet{
 	ID:      1,
+	ULID:    "01HV6Z9K1QX8M3N5P7R9T2V4W6",
 	Title:   "An old silent pond",
 	Content: "An old silent pond...",
 	Created: time.Now(),
-	Expires: time.Now(),
+	Expires: time.Now().Add(24 * time.Hour),
+	Updated: time.Now(),
+	OwnerID: 1,
 }
 
-type SnippetModel struct{}
+// SnippetModel is an in-memory implementation of models.SnippetModelInterface. Snippets written
+// through it can be read back, so handler tests can follow a snippet from creation to display.
+type SnippetModel struct {
+	Clock       clock.Clock // Clock decides which snippets have expired. It defaults to the system clock.
+	TrendingIDs []int       // TrendingIDs is the trending ranking returned by Trending, highest first.
+
+	// Organizations supplies the roles of organization members for Permission. Without it nobody
+	// is a member of any organization.
+	Organizations *OrganizationModel
+
+	mu        sync.Mutex
+	snippets  map[int]*models.Snippet
+	rules     map[int][]models.PermissionRule // rules maps a snippet ID to its permission rules.
+	metadata  map[int][]models.MetadataField  // metadata maps a snippet ID to its metadata.
+	revisions map[int][]*models.Revision      // revisions maps a snippet ID to its revisions, oldest first.
+	nextID    int
+}
+
+// NewSnippetModel returns a SnippetModel holding mockSnippet.
+func NewSnippetModel() *SnippetModel {
+	s := mockSnippet
+	s.Stats = models.ComputeStats(s.Content)
+
+	return &SnippetModel{
+		snippets:  map[int]*models.Snippet{s.ID: &s},
+		rules:     map[int][]models.PermissionRule{},
+		metadata:  map[int][]models.MetadataField{},
+		revisions: map[int][]*models.Revision{s.ID: {revisionOf(&s, 1)}},
+		nextID:    s.ID + 1,
+	}
+}
 
-func (sm *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+// revisionOf returns the current version of a snippet as its revision with the number.
+func revisionOf(s *models.Snippet, number int) *models.Revision {
+	return &models.Revision{
+		SnippetID: s.ID,
+		Number:    number,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   s.Updated,
+		CreatedBy: s.UpdatedBy,
+	}
+}
+
+// Add stores a snippet as-is, assigning it an ID if it doesn't have one, computing the statistics
+// of its content and recording it as its first revision, and returns the ID. It lets tests set up
+// snippets with fields that Insert doesn't take.
+func (sm *SnippetModel) Add(s *models.Snippet) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if s.ID == 0 {
+		s.ID = sm.nextID
+	}
+	if s.ID >= sm.nextID {
+		sm.nextID = s.ID + 1
+	}
+
+	s.Stats = models.ComputeStats(s.Content)
+	sm.snippets[s.ID] = s
+	sm.revisions[s.ID] = []*models.Revision{revisionOf(s, 1)}
+
+	return s.ID
+}
+
+func (sm *SnippetModel) Insert(title string, content string, expires int, userID int) (int, error) {
+	return sm.InsertWith(title, content, expires, userID, models.Visibility{})
+}
+
+func (sm *SnippetModel) InsertWith(title string, content string, expires int, userID int, vis models.Visibility) (int, error) {
+	now := clock.Now(sm.Clock)
+
+	var expiry time.Time
+	if expires != models.NeverExpires {
+		expiry = now.AddDate(0, 0, expires)
+	}
+
+	return sm.Add(&models.Snippet{
+		ULID:      ulid.Make().String(),
+		Title:     title,
+		Content:   content,
+		Created:   now,
+		Expires:   expiry,
+		Updated:   now,
+		UpdatedBy: userID,
+		OwnerID:   userID,
+		Private:   vis.Private,
+		Held:      vis.Held,
+		Shadowed:  vis.Shadowed,
+	}), nil
 }
 
 func (sm *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
-	case 1:
-		return mockSnippet, nil
-	default:
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok || !sm.live(s) {
+		return nil, models.ErrNoRecord
+	}
+
+	return s, nil
+}
+
+func (sm *SnippetModel) GetByULID(id string) (*models.Snippet, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, s := range sm.snippets {
+		if s.ULID == id && sm.live(s) {
+			return s, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (sm *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, s := range sm.snippets {
+		if slug != "" && s.Slug == slug && sm.live(s) {
+			return s, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (sm *SnippetModel) SlugTaken(slug string) (bool, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, s := range sm.snippets {
+		if s.Slug == slug {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (sm *SnippetModel) SetSlug(id int, slug string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, s := range sm.snippets {
+		if slug != "" && s.Slug == slug && s.ID != id {
+			return models.ErrDuplicateSlug
+		}
+	}
+
+	if s, ok := sm.snippets[id]; ok {
+		s.Slug = slug
+	}
+
+	return nil
+}
+
+func (sm *SnippetModel) List(page, pageSize int) ([]*models.Snippet, int, error) {
+	snippets := sm.list(math.MaxInt, func(s *models.Snippet) bool {
+		return sm.live(s) && !s.Held && !s.Private
+	})
+
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Pinned && !snippets[j].Pinned
+	})
+
+	total := len(snippets)
+	snippets = snippets[min((page-1)*pageSize, total):]
+
+	return snippets[:min(pageSize, len(snippets))], total, nil
+}
+
+func (sm *SnippetModel) RecordClient(id int, ip, userAgent string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if s, ok := sm.snippets[id]; ok {
+		s.CreatorIP, s.CreatorUA = ip, userAgent
+	}
+
+	return nil
+}
+
+func (sm *SnippetModel) Recent(limit int) ([]*models.Snippet, error) {
+	return sm.list(limit, func(s *models.Snippet) bool {
+		return true
+	}), nil
+}
+
+func (sm *SnippetModel) ByOwner(ownerID int, limit int) ([]*models.Snippet, error) {
+	return sm.list(limit, func(s *models.Snippet) bool {
+		return s.OwnerID == ownerID && sm.live(s) && !s.Held && !s.Private
+	}), nil
+}
+
+func (sm *SnippetModel) SetHeld(id int, held bool) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Held = held
+
+	return nil
+}
+
+func (sm *SnippetModel) SetShadowed(id int, shadowed bool) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Shadowed = shadowed
+
+	return nil
+}
+
+func (sm *SnippetModel) SetPinned(id int, pinned bool) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Pinned = pinned
+
+	return nil
+}
+
+func (sm *SnippetModel) SetPrivate(id int, private bool) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.Private = private
+
+	return nil
+}
+
+func (sm *SnippetModel) SetOrg(id int, orgID int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	s.OrgID = orgID
+
+	return nil
+}
+
+func (sm *SnippetModel) SetLanguage(id int, language string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if s, ok := sm.snippets[id]; ok {
+		s.Language = language
+	}
+
+	return nil
+}
+
+func (sm *SnippetModel) SetLicense(id int, license string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if s, ok := sm.snippets[id]; ok {
+		s.License = license
+	}
+
+	return nil
+}
+
+func (sm *SnippetModel) ByOrg(orgID int, limit int) ([]*models.Snippet, error) {
+	return sm.list(limit, func(s *models.Snippet) bool {
+		return s.OrgID == orgID && sm.live(s) && !s.Held
+	}), nil
+}
+
+func (sm *SnippetModel) Trending(limit int) ([]*models.Snippet, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	for _, id := range sm.TrendingIDs {
+		if s, ok := sm.snippets[id]; ok && sm.live(s) && !s.Held && !s.Private && len(snippets) < limit {
+			snippets = append(snippets, s)
+		}
+	}
+
+	return snippets, nil
+}
+
+func (sm *SnippetModel) IncrementViews(counts map[int]int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for id, n := range counts {
+		if s, ok := sm.snippets[id]; ok {
+			s.Views += n
+		}
+	}
+
+	return nil
+}
+
+func (sm *SnippetModel) MostViewed(limit int) ([]*models.Snippet, error) {
+	snippets := sm.list(math.MaxInt, func(s *models.Snippet) bool {
+		return sm.live(s) && !s.Held && !s.Private && s.Views > 0
+	})
+
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Views > snippets[j].Views
+	})
+
+	return snippets[:min(limit, len(snippets))], nil
+}
+
+func (sm *SnippetModel) Search(q models.SearchQuery, afterID int, limit int) ([]*models.Snippet, error) {
+	return sm.list(limit, func(s *models.Snippet) bool {
+		return s.ID > afterID && sm.live(s) && !s.Held && !s.Private && q.Matches(s) && sm.hasMetadata(s.ID, q.Metadata)
+	}), nil
+}
+
+// FullTextSearch ranks the snippets with every word of the query in their title or content by how
+// often the words appear, which is close enough to MySQL's relevance for tests.
+func (sm *SnippetModel) FullTextSearch(q models.SearchQuery, offset, limit int) ([]*models.Snippet, error) {
+	words := q.Words()
+	score := func(s *models.Snippet) int {
+		text := strings.ToLower(s.Title + "\n" + s.Content)
+		n := 0
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				return 0
+			}
+			n += strings.Count(text, word)
+		}
+		return n + 1
+	}
+
+	snippets := sm.list(math.MaxInt, func(s *models.Snippet) bool {
+		return sm.live(s) && !s.Held && !s.Private && score(s) > 0 &&
+			(q.Language == "" || strings.EqualFold(s.Language, q.Language)) && sm.hasMetadata(s.ID, q.Metadata)
+	})
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return score(snippets[i]) > score(snippets[j])
+	})
+
+	snippets = snippets[min(offset, len(snippets)):]
+	return snippets[:min(limit, len(snippets))], nil
+}
+
+// hasMetadata reports whether a snippet has every pair of fields. The caller holds sm.mu.
+func (sm *SnippetModel) hasMetadata(id int, fields []models.MetadataField) bool {
+	for _, f := range fields {
+		if !slices.Contains(sm.metadata[id], f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (sm *SnippetModel) Metadata(id int) ([]models.MetadataField, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return append([]models.MetadataField{}, sm.metadata[id]...), nil
+}
+
+func (sm *SnippetModel) SetMetadata(id int, fields []models.MetadataField) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.metadata[id] = append([]models.MetadataField(nil), fields...)
+
+	return nil
+}
+
+func (sm *SnippetModel) Duplicate(content string) (*models.Snippet, error) {
+	candidates := sm.list(math.MaxInt, func(s *models.Snippet) bool {
+		return sm.live(s) && !s.Held && !s.Private
+	})
+
+	hash := fingerprint.Hash(content)
+	simhash, near := fingerprint.Simhash(content)
+
+	var best *models.Snippet
+	bestDistance := fingerprint.MaxDistance + 1
+
+	// Candidates are newest first, so ties go to the oldest.
+	for _, s := range candidates {
+		if fingerprint.Hash(s.Content) == hash {
+			best, bestDistance = s, -1
+			continue
+		}
+		if bestDistance < 0 || !near {
+			continue
+		}
+		if h, ok := fingerprint.Simhash(s.Content); ok && fingerprint.Distance(h, simhash) <= bestDistance {
+			best, bestDistance = s, fingerprint.Distance(h, simhash)
+		}
+	}
+
+	if best == nil {
 		return nil, models.ErrNoRecord
 	}
+
+	return best, nil
+}
+
+func (sm *SnippetModel) Permission(id, userID int) (string, error) {
+	s, err := sm.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	var memberRole string
+	if s.OrgID != 0 && sm.Organizations != nil {
+		memberRole, _ = sm.Organizations.Role(s.OrgID, userID)
+	}
+
+	var userRule, roleRule string
+	for _, r := range sm.ruleList(id) {
+		switch {
+		case r.UserID != 0 && r.UserID == userID:
+			userRule = r.Level
+		case r.Role != "" && r.Role == memberRole:
+			roleRule = r.Level
+		}
+	}
+
+	return models.ResolvePermission(s, userID, memberRole, userRule, roleRule), nil
 }
 
-func (sm *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (sm *SnippetModel) Update(id int, title, content string, userID int, admin bool) error {
+	level, err := sm.Permission(id, userID)
+	if err != nil {
+		return err
+	}
+	if !admin && !models.Allows(level, models.PermissionWrite) {
+		return models.ErrPermissionDenied
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s := sm.snippets[id]
+	s.Title, s.Content = title, content
+	s.Updated, s.UpdatedBy = clock.Now(sm.Clock), userID
+	s.Stats = models.ComputeStats(content)
+	sm.revisions[id] = append(sm.revisions[id], revisionOf(s, len(sm.revisions[id])+1))
+
+	return nil
+}
+
+func (sm *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return append([]*models.Revision{}, sm.revisions[snippetID]...), nil
+}
+
+func (sm *SnippetModel) Revision(snippetID, number int) (*models.Revision, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	revisions := sm.revisions[snippetID]
+	if number < 1 || number > len(revisions) {
+		return nil, models.ErrNoRecord
+	}
+
+	return revisions[number-1], nil
+}
+
+func (sm *SnippetModel) Permissions(id int) ([]*models.PermissionRule, error) {
+	rules := []*models.PermissionRule{}
+	for _, r := range sm.ruleList(id) {
+		r := r
+		rules = append(rules, &r)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].UserID != rules[j].UserID {
+			return rules[i].UserID < rules[j].UserID
+		}
+		return rules[i].Role < rules[j].Role
+	})
+
+	return rules, nil
+}
+
+func (sm *SnippetModel) SetPermission(actorID int, rule models.PermissionRule) error {
+	level, err := sm.Permission(rule.SnippetID, actorID)
+	if err != nil {
+		return err
+	}
+	if level != models.PermissionManage {
+		return models.ErrPermissionDenied
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	rules := []models.PermissionRule{}
+	for _, r := range sm.rules[rule.SnippetID] {
+		if r.UserID != rule.UserID || r.Role != rule.Role {
+			rules = append(rules, r)
+		}
+	}
+	if rule.Level != "" {
+		rules = append(rules, rule)
+	}
+	sm.rules[rule.SnippetID] = rules
+
+	return nil
+}
+
+func (sm *SnippetModel) Delete(id, ownerID int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok || s.OwnerID != ownerID || !s.Deleted.IsZero() {
+		return models.ErrNoRecord
+	}
+	s.Deleted = clock.Now(sm.Clock)
+
+	return nil
+}
+
+func (sm *SnippetModel) Trash(ownerID int) ([]*models.Snippet, error) {
+	snippets := sm.list(math.MaxInt, func(s *models.Snippet) bool {
+		return s.OwnerID == ownerID && !s.Deleted.IsZero()
+	})
+
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Deleted.After(snippets[j].Deleted)
+	})
+
+	return snippets, nil
+}
+
+func (sm *SnippetModel) Undelete(id, ownerID int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok || s.OwnerID != ownerID || s.Deleted.IsZero() {
+		return models.ErrNoRecord
+	}
+	s.Deleted = time.Time{}
+
+	return nil
+}
+
+func (sm *SnippetModel) Purge(id, ownerID int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.snippets[id]
+	if !ok || s.OwnerID != ownerID || s.Deleted.IsZero() {
+		return models.ErrNoRecord
+	}
+	delete(sm.snippets, id)
+	delete(sm.revisions, id)
+
+	return nil
+}
+
+// live reports whether a snippet hasn't expired yet and isn't in the trash.
+func (sm *SnippetModel) live(s *models.Snippet) bool {
+	return (s.Permanent() || s.Expires.After(clock.Now(sm.Clock))) && s.Deleted.IsZero()
+}
+
+// lastID returns the ID of the newest snippet.
+// ulidOf returns the ULID of the snippet with the ID, deleted or not, or an empty string if there's
+// no such snippet.
+func (sm *SnippetModel) ulidOf(id int) string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if s, ok := sm.snippets[id]; ok {
+		return s.ULID
+	}
+	return ""
+}
+
+func (sm *SnippetModel) lastID() int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return sm.nextID - 1
+}
+
+// ruleList returns a copy of the permission rules of a snippet.
+func (sm *SnippetModel) ruleList(id int) []models.PermissionRule {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return append([]models.PermissionRule{}, sm.rules[id]...)
+}
+
+// list returns up to limit snippets matching keep, newest first.
+func (sm *SnippetModel) list(limit int, keep func(*models.Snippet) bool) []*models.Snippet {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	for _, s := range sm.snippets {
+		if keep(s) {
+			snippets = append(snippets, s)
+		}
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].ID > snippets[j].ID
+	})
+
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+
+	return snippets
 }
@@ -0,0 +1,79 @@
+package mocks
+
+import (
+	"strings"
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/mailer"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// SuppressionModel is an in-memory implementation of models.SuppressionModelInterface.
+type SuppressionModel struct {
+	Clock clock.Clock // Clock timestamps bounces. It defaults to the system clock.
+
+	mu           sync.Mutex
+	suppressions map[string]*models.Suppression
+}
+
+// NewSuppressionModel returns a SuppressionModel without bounces.
+func NewSuppressionModel() *SuppressionModel {
+	return &SuppressionModel{suppressions: map[string]*models.Suppression{}}
+}
+
+func (sm *SuppressionModel) Suppressed(email string) (bool, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.suppressions[strings.ToLower(email)]
+
+	return ok && s.IsSuppressed(), nil
+}
+
+func (sm *SuppressionModel) RecordBounce(email string, bounce mailer.Bounce) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	email = strings.ToLower(email)
+	s, ok := sm.suppressions[email]
+	if !ok {
+		s = &models.Suppression{Email: email}
+		sm.suppressions[email] = s
+	}
+
+	now := clock.Now(sm.Clock)
+	if bounce.Kind == mailer.BounceSoft {
+		s.SoftBounces++
+	}
+	s.LastKind = bounce.Kind
+	s.LastDetail = bounce.Detail
+	s.LastBounce = now
+	if !s.IsSuppressed() && (bounce.Kind != mailer.BounceSoft || s.SoftBounces >= models.SoftBounceLimit) {
+		s.Suppressed = now
+	}
+
+	return nil
+}
+
+func (sm *SuppressionModel) Get(email string) (*models.Suppression, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.suppressions[strings.ToLower(email)]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	c := *s
+	return &c, nil
+}
+
+func (sm *SuppressionModel) Reactivate(email string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	delete(sm.suppressions, strings.ToLower(email))
+
+	return nil
+}
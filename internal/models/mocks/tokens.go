@@ -0,0 +1,83 @@
+package mocks
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// TokenModel is an in-memory implementation of models.TokenModelInterface.
+type TokenModel struct {
+	Clock clock.Clock // Clock timestamps tokens and their use. It defaults to the system clock.
+
+	mu     sync.Mutex
+	tokens map[string]*models.APIToken // tokens maps the hash of a token to its record.
+	nextID int
+}
+
+// NewTokenModel returns a TokenModel without tokens.
+func NewTokenModel() *TokenModel {
+	return &TokenModel{tokens: map[string]*models.APIToken{}, nextID: 1}
+}
+
+func (tm *TokenModel) Insert(userID int, name, scope string) (string, *models.APIToken, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	token := fmt.Sprintf("%stest-token-%d", models.TokenPrefix, tm.nextID)
+	t := &models.APIToken{ID: tm.nextID, UserID: userID, Name: name, Scope: scope, Created: clock.Now(tm.Clock)}
+	tm.tokens[models.HashAPIToken(token)] = t
+	tm.nextID++
+
+	cp := *t
+
+	return token, &cp, nil
+}
+
+func (tm *TokenModel) Authenticate(token string) (*models.APIToken, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	t, ok := tm.tokens[models.HashAPIToken(token)]
+	if !ok {
+		return nil, models.ErrInvalidCredentials
+	}
+	t.LastUsed = clock.Now(tm.Clock)
+
+	cp := *t
+
+	return &cp, nil
+}
+
+func (tm *TokenModel) ByUser(userID int) ([]*models.APIToken, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tokens := []*models.APIToken{}
+	for _, t := range tm.tokens {
+		if t.UserID == userID {
+			cp := *t
+			tokens = append(tokens, &cp)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b *models.APIToken) int { return b.ID - a.ID })
+
+	return tokens, nil
+}
+
+func (tm *TokenModel) Revoke(id, userID int) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for hash, t := range tm.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(tm.tokens, hash)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
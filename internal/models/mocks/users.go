@@ -1,31 +1,188 @@
 package mocks
 
-import "snippetbox.adcon.dev/internal/models"
+import (
+	"sync"
+	"time"
 
-type UserModel struct{}
+	"snippetbox.adcon.dev/internal/models"
+)
 
-func (um *UserModel) Insert(name, email, password string) error {
-	switch email {
-	case "dupe@example.com":
-		return models.ErrDuplicateEmail
-	default:
-		return nil
+// mockUsers are the users every new UserModel starts with. Alice is an admin; the "dupe" user
+// makes the duplicate email and username paths easy to reach. Both have the password "pa$$word".
+var mockUsers = []models.User{
+	{
+		ID:       1,
+		Name:     "Alice Jones",
+		Username: "alice",
+		Email:    "alice@example.com",
+		Created:  time.Now(),
+		Admin:    true,
+	},
+	{
+		ID:       2,
+		Name:     "Dupe",
+		Username: "dupe",
+		Email:    "dupe@example.com",
+		Created:  time.Now(),
+	},
+}
+
+// mockPassword is the password of the mock users.
+const mockPassword = "pa$$word"
+
+// UserModel is an in-memory implementation of models.UserModelInterface. Passwords are kept in
+// plain text, which is fine for tests.
+type UserModel struct {
+	mu        sync.Mutex
+	users     []*models.User
+	passwords map[int]string
+}
+
+// NewUserModel returns a UserModel holding mockUsers.
+func NewUserModel() *UserModel {
+	um := &UserModel{passwords: map[int]string{}}
+
+	for _, u := range mockUsers {
+		u := u
+		um.users = append(um.users, &u)
+		um.passwords[u.ID] = mockPassword
 	}
+
+	return um
+}
+
+func (um *UserModel) Insert(name, username, email, password string) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for _, u := range um.users {
+		switch {
+		case u.Email == email:
+			return models.ErrDuplicateEmail
+		case u.Username == username:
+			return models.ErrDuplicateUsername
+		}
+	}
+
+	u := &models.User{
+		ID:       len(um.users) + 1,
+		Name:     name,
+		Username: username,
+		Email:    email,
+		Created:  time.Now(),
+	}
+	um.users = append(um.users, u)
+	um.passwords[u.ID] = password
+
+	return nil
 }
 
 func (um *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
-		return 1, nil
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for _, u := range um.users {
+		if u.Email == email && um.passwords[u.ID] == password {
+			return u.ID, nil
+		}
 	}
 
 	return 0, models.ErrInvalidCredentials
 }
 
 func (um *UserModel) Exists(id int) (bool, error) {
-	switch id {
-	case 1:
-		return true, nil
-	default:
-		return false, nil
+	return um.byID(id) != nil, nil
+}
+
+func (um *UserModel) IsAdmin(id int) (bool, error) {
+	u := um.byID(id)
+
+	return u != nil && u.Admin, nil
+}
+
+func (um *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	current, ok := um.passwords[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	if current != currentPassword {
+		return models.ErrInvalidCredentials
+	}
+	um.passwords[id] = newPassword
+
+	return nil
+}
+
+func (um *UserModel) Get(id int) (*models.User, error) {
+	u := um.byID(id)
+	if u == nil {
+		return nil, models.ErrNoRecord
 	}
+
+	return u, nil
+}
+
+func (um *UserModel) GetByUsername(username string) (*models.User, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for _, u := range um.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (um *UserModel) ExistsByEmail(email string) (bool, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for _, u := range um.users {
+		if u.Email == email {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (um *UserModel) ExistsByUsername(username string) (bool, error) {
+	_, err := um.GetByUsername(username)
+
+	return err == nil, nil
+}
+
+// byID returns the user with the given ID, or nil.
+func (um *UserModel) byID(id int) *models.User {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for _, u := range um.users {
+		if u.ID == id {
+			return u
+		}
+	}
+
+	return nil
+}
+
+// remove deletes the user with the given ID and returns it, or nil if there's none.
+func (um *UserModel) remove(id int) *models.User {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	for i, u := range um.users {
+		if u.ID == id {
+			um.users = append(um.users[:i], um.users[i+1:]...)
+			delete(um.passwords, id)
+			return u
+		}
+	}
+
+	return nil
 }
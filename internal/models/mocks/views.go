@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// ViewModel is an in-memory implementation of models.ViewModelInterface.
+type ViewModel struct {
+	mu     sync.Mutex
+	counts map[models.ViewKey]int
+}
+
+// NewViewModel returns a ViewModel with three views of mockSnippet today.
+func NewViewModel() *ViewModel {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	return &ViewModel{
+		counts: map[models.ViewKey]int{{SnippetID: mockSnippet.ID, Day: today}: 3},
+	}
+}
+
+func (vm *ViewModel) Add(counts map[models.ViewKey]int) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	for key, n := range counts {
+		vm.counts[key] += n
+	}
+
+	return nil
+}
+
+func (vm *ViewModel) SnippetStats(snippetID int, days int) (*models.ViewStats, error) {
+	return vm.stats(days, func(key models.ViewKey) bool {
+		return key.SnippetID == snippetID
+	}), nil
+}
+
+func (vm *ViewModel) SiteStats(days int) (*models.ViewStats, error) {
+	return vm.stats(days, func(key models.ViewKey) bool {
+		return true
+	}), nil
+}
+
+// stats totals the views of the keys matching keep by day.
+func (vm *ViewModel) stats(days int, keep func(models.ViewKey) bool) *models.ViewStats {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	byDay := map[string]int{}
+	for key, n := range vm.counts {
+		if keep(key) {
+			byDay[key.Day.Format(time.DateOnly)] += n
+		}
+	}
+
+	return models.NewViewStats(time.Now().UTC(), days, byDay)
+}
@@ -0,0 +1,93 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/models"
+)
+
+// WebmentionModel is an in-memory implementation of models.WebmentionModelInterface.
+type WebmentionModel struct {
+	Clock clock.Clock // Clock timestamps mentions. It defaults to the system clock.
+
+	mu       sync.Mutex
+	mentions []*models.Webmention
+}
+
+// NewWebmentionModel returns a WebmentionModel without mentions.
+func NewWebmentionModel() *WebmentionModel {
+	return &WebmentionModel{}
+}
+
+func (wm *WebmentionModel) Receive(snippetID int, source, target string) (int, error) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	for _, m := range wm.mentions {
+		if m.SnippetID == snippetID && m.Source == source {
+			m.Target = target
+			m.Status = models.WebmentionPending
+			m.Received = clock.Now(wm.Clock)
+			return m.ID, nil
+		}
+	}
+
+	m := &models.Webmention{
+		ID:        len(wm.mentions) + 1,
+		SnippetID: snippetID,
+		Source:    source,
+		Target:    target,
+		Status:    models.WebmentionPending,
+		Received:  clock.Now(wm.Clock),
+	}
+	wm.mentions = append(wm.mentions, m)
+
+	return m.ID, nil
+}
+
+func (wm *WebmentionModel) Pending(limit int) ([]*models.Webmention, error) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	mentions := []*models.Webmention{}
+	for _, m := range wm.mentions {
+		if m.Status == models.WebmentionPending && len(mentions) < limit {
+			c := *m
+			mentions = append(mentions, &c)
+		}
+	}
+
+	return mentions, nil
+}
+
+func (wm *WebmentionModel) SetStatus(id int, status, title string) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	for _, m := range wm.mentions {
+		if m.ID == id {
+			m.Status = status
+			m.Title = title
+			m.Verified = clock.Now(wm.Clock)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (wm *WebmentionModel) BySnippet(snippetID int) ([]*models.Webmention, error) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	mentions := []*models.Webmention{}
+	for _, m := range wm.mentions {
+		if m.SnippetID == snippetID && m.Status == models.WebmentionVerified {
+			c := *m
+			mentions = append(mentions, &c)
+		}
+	}
+
+	return mentions, nil
+}
@@ -0,0 +1,362 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// The roles of organization members.
+const (
+	RoleOwner  = "owner"  // Owners manage the members and invitations of the organization.
+	RoleMember = "member" // Members read and write the snippets of the organization.
+)
+
+// ValidRole reports whether role is a known organization role.
+func ValidRole(role string) bool {
+	return role == RoleOwner || role == RoleMember
+}
+
+// Organization is a team of users that own snippets together.
+type Organization struct {
+	ID      int       // ID is the unique identifier of the organization.
+	Name    string    // Name is the display name.
+	Slug    string    // Slug identifies the organization in URLs and is unique.
+	Created time.Time // Created is the time the organization was created.
+}
+
+// Member is a user belonging to an organization.
+type Member struct {
+	UserID   int       // UserID is the ID of the user.
+	Name     string    // Name is the name of the user.
+	Username string    // Username is the username of the user.
+	Role     string    // Role is RoleOwner or RoleMember.
+	Joined   time.Time // Joined is the time the user joined.
+}
+
+// Invitation is a pending invitation to join an organization.
+type Invitation struct {
+	ID        int       // ID is the unique identifier of the invitation.
+	OrgID     int       // OrgID is the ID of the organization.
+	OrgName   string    // OrgName is the name of the organization.
+	OrgSlug   string    // OrgSlug is the slug of the organization.
+	Email     string    // Email is the address the invitation was sent to. Only that user can accept it.
+	Role      string    // Role is the role the user gets on accepting.
+	InvitedBy int       // InvitedBy is the ID of the member who sent the invitation.
+	Created   time.Time // Created is the time the invitation was sent.
+	Expires   time.Time // Expires is the time after which the invitation can't be accepted.
+}
+
+// OrganizationModel wraps a sql.DB connection pool and provides methods for the organizations,
+// organization_members and organization_invitations tables.
+type OrganizationModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps new records and decides which invitations have expired. It defaults to the system clock.
+}
+
+type OrganizationModelInterface interface {
+	Insert(name, slug string, ownerID int) (int, error)
+	Get(id int) (*Organization, error)
+	GetBySlug(slug string) (*Organization, error)
+	ByMember(userID int) ([]*Organization, error)
+	Role(orgID, userID int) (string, error)
+	Members(orgID int) ([]*Member, error)
+	RemoveMember(orgID, userID int) error
+	Invite(orgID, invitedBy int, email, role string, expires time.Time) (string, error)
+	Invitations(orgID int) ([]*Invitation, error)
+	Invitation(token string) (*Invitation, error)
+	AcceptInvitation(token string, userID int, email string) (*Invitation, error)
+}
+
+// invitationColumns is the column list selected by every query that returns invitations.
+const invitationColumns = `i.id, i.org_id, o.name, o.slug, i.email, i.role, i.invited_by, i.created, i.expires`
+
+// Insert creates an organization with ownerID as its first owner and returns its ID. It returns
+// ErrDuplicateOrganization if the slug is taken.
+func (om *OrganizationModel) Insert(name, slug string, ownerID int) (int, error) {
+
+	tx, err := om.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := currentTime(om.Clock)
+
+	res, err := tx.Exec(`INSERT INTO organizations (name, slug, created) VALUES (?, ?, ?)`, name, slug, now)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "organizations_uc_slug") {
+			return 0, ErrDuplicateOrganization
+		}
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO organization_members (org_id, user_id, role, joined) VALUES (?, ?, ?, ?)`, id, ownerID, RoleOwner, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// Get returns an organization by its ID.
+func (om *OrganizationModel) Get(id int) (*Organization, error) {
+	return om.one(`SELECT id, name, slug, created FROM organizations WHERE id = ?`, id)
+}
+
+// GetBySlug returns an organization by its slug.
+func (om *OrganizationModel) GetBySlug(slug string) (*Organization, error) {
+	return om.one(`SELECT id, name, slug, created FROM organizations WHERE slug = ?`, slug)
+}
+
+// ByMember returns the organizations a user belongs to, ordered by name.
+func (om *OrganizationModel) ByMember(userID int) ([]*Organization, error) {
+
+	stmt := `SELECT o.id, o.name, o.slug, o.created FROM organizations o
+    JOIN organization_members m ON m.org_id = o.id WHERE m.user_id = ? ORDER BY o.name`
+
+	rows, err := om.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []*Organization{}
+	for rows.Next() {
+		o := &Organization{}
+		if err := rows.Scan(&o.ID, &o.Name, &o.Slug, &o.Created); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+
+	return orgs, rows.Err()
+}
+
+// Role returns the role of a user in an organization, or an empty string if they aren't a member.
+func (om *OrganizationModel) Role(orgID, userID int) (string, error) {
+
+	var role string
+
+	err := om.DB.QueryRow(`SELECT role FROM organization_members WHERE org_id = ? AND user_id = ?`, orgID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return role, err
+}
+
+// Members returns the members of an organization, owners first and then by username.
+func (om *OrganizationModel) Members(orgID int) ([]*Member, error) {
+
+	stmt := `SELECT u.id, u.name, u.username, m.role, m.joined FROM organization_members m
+    JOIN users u ON u.id = m.user_id WHERE m.org_id = ? ORDER BY m.role = 'owner' DESC, u.username`
+
+	rows, err := om.DB.Query(stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		m := &Member{}
+		if err := rows.Scan(&m.UserID, &m.Name, &m.Username, &m.Role, &m.Joined); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// RemoveMember removes a user from an organization. It returns ErrNoRecord if they aren't a
+// member and ErrLastOwner if they're its only owner, since nobody could manage it afterwards.
+func (om *OrganizationModel) RemoveMember(orgID, userID int) error {
+
+	tx, err := om.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the owners so that two owners can't remove each other at the same time.
+	var owners int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM organization_members WHERE org_id = ? AND role = ? FOR UPDATE`, orgID, RoleOwner).Scan(&owners)
+	if err != nil {
+		return err
+	}
+
+	var role string
+	err = tx.QueryRow(`SELECT role FROM organization_members WHERE org_id = ? AND user_id = ?`, orgID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	if role == RoleOwner && owners <= 1 {
+		return ErrLastOwner
+	}
+
+	if _, err := tx.Exec(`DELETE FROM organization_members WHERE org_id = ? AND user_id = ?`, orgID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Invite records an invitation to join an organization with the given role and returns the token
+// to send to the invited address. Only a hash of the token is stored.
+func (om *OrganizationModel) Invite(orgID, invitedBy int, email, role string, expires time.Time) (string, error) {
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO organization_invitations (org_id, email, role, token_hash, invited_by, created, expires)
+    VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = om.DB.Exec(stmt, orgID, email, role, hashInvitationToken(token), invitedBy, currentTime(om.Clock), expires.UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Invitations returns the unexpired invitations of an organization, newest first.
+func (om *OrganizationModel) Invitations(orgID int) ([]*Invitation, error) {
+
+	stmt := `SELECT ` + invitationColumns + ` FROM organization_invitations i
+    JOIN organizations o ON o.id = i.org_id WHERE i.org_id = ? AND i.expires > ? ORDER BY i.id DESC`
+
+	rows, err := om.DB.Query(stmt, orgID, currentTime(om.Clock))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+	for rows.Next() {
+		i := &Invitation{}
+		if err := scanInvitation(rows, i); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+
+	return invitations, rows.Err()
+}
+
+// Invitation returns the unexpired invitation with the given token, or ErrNoRecord if there's none.
+func (om *OrganizationModel) Invitation(token string) (*Invitation, error) {
+
+	stmt := `SELECT ` + invitationColumns + ` FROM organization_invitations i
+    JOIN organizations o ON o.id = i.org_id WHERE i.token_hash = ? AND i.expires > ?`
+
+	i := &Invitation{}
+	err := scanInvitation(om.DB.QueryRow(stmt, hashInvitationToken(token), currentTime(om.Clock)), i)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// AcceptInvitation adds a user to the organization of an invitation and deletes the invitation.
+// The user's email address has to be the one the invitation was sent to; otherwise, or if the
+// token is unknown or expired, it returns ErrNoRecord. A user who is already a member keeps their
+// role.
+func (om *OrganizationModel) AcceptInvitation(token string, userID int, email string) (*Invitation, error) {
+
+	invitation, err := om.Invitation(token)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(invitation.Email, email) {
+		return nil, ErrNoRecord
+	}
+
+	tx, err := om.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Deleting the invitation first makes sure it's only used once, even by concurrent requests.
+	res, err := tx.Exec(`DELETE FROM organization_invitations WHERE id = ?`, invitation.ID)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrNoRecord
+	}
+
+	stmt := `INSERT IGNORE INTO organization_members (org_id, user_id, role, joined) VALUES (?, ?, ?, ?)`
+
+	if _, err := tx.Exec(stmt, invitation.OrgID, userID, invitation.Role, currentTime(om.Clock)); err != nil {
+		return nil, err
+	}
+
+	return invitation, tx.Commit()
+}
+
+// one returns the organization selected by a query, or ErrNoRecord if there's none.
+func (om *OrganizationModel) one(stmt string, args ...any) (*Organization, error) {
+
+	o := &Organization{}
+	err := om.DB.QueryRow(stmt, args...).Scan(&o.ID, &o.Name, &o.Slug, &o.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// scanInvitation reads a row of invitationColumns into i.
+func scanInvitation(row rowScanner, i *Invitation) error {
+	return row.Scan(&i.ID, &i.OrgID, &i.OrgName, &i.OrgSlug, &i.Email, &i.Role, &i.InvitedBy, &i.Created, &i.Expires)
+}
+
+// newInvitationToken returns a random invitation token.
+func newInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashInvitationToken returns the hash of a token as stored in the token_hash column.
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
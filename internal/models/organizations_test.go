@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestOrganizationModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	um, err := NewUserModel(db)
+	assert.NilError(t, err)
+	assert.NilError(t, um.Insert("Bob", "bob", "bob@example.com", "pa$$word"))
+
+	alice, err := um.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, alice.Email, "alice@example.com")
+
+	bob, err := um.GetByUsername("bob")
+	assert.NilError(t, err)
+
+	om := &OrganizationModel{DB: db}
+
+	id, err := om.Insert("Haiku Club", "haiku", alice.ID)
+	assert.NilError(t, err)
+
+	_, err = om.Insert("Other", "haiku", bob.ID)
+	assert.Equal(t, errors.Is(err, ErrDuplicateOrganization), true)
+
+	role, err := om.Role(id, alice.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, role, RoleOwner)
+
+	role, err = om.Role(id, bob.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, role, "")
+
+	token, err := om.Invite(id, alice.ID, "bob@example.com", RoleMember, time.Now().Add(time.Hour))
+	assert.NilError(t, err)
+
+	invitations, err := om.Invitations(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(invitations), 1)
+	assert.Equal(t, invitations[0].OrgSlug, "haiku")
+
+	// Only the invited address can accept, and only once.
+	_, err = om.AcceptInvitation(token, alice.ID, alice.Email)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	invitation, err := om.AcceptInvitation(token, bob.ID, "Bob@Example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, invitation.OrgID, id)
+
+	_, err = om.AcceptInvitation(token, bob.ID, bob.Email)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	members, err := om.Members(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(members), 2)
+	assert.Equal(t, members[0].Username, "alice")
+	assert.Equal(t, members[1].Role, RoleMember)
+
+	orgs, err := om.ByMember(bob.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(orgs), 1)
+
+	err = om.RemoveMember(id, alice.ID)
+	assert.Equal(t, errors.Is(err, ErrLastOwner), true)
+
+	assert.NilError(t, om.RemoveMember(id, bob.ID))
+
+	err = om.RemoveMember(id, bob.ID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// Expired invitations can't be used.
+	token, err = om.Invite(id, alice.ID, "bob@example.com", RoleMember, time.Now().Add(-time.Hour))
+	assert.NilError(t, err)
+	_, err = om.Invitation(token)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// Snippets of the organization are listed for its members, private ones included.
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	snippetID, err := sm.Insert("Team haiku", "Five, seven, five", 7, alice.ID)
+	assert.NilError(t, err)
+	assert.NilError(t, sm.SetPrivate(snippetID, true))
+	assert.NilError(t, sm.SetOrg(snippetID, id))
+	assert.NilError(t, sm.SetOrg(snippetID, id))
+
+	snippets, err := sm.ByOrg(id, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].OrgID, id)
+
+	err = sm.SetOrg(999, id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
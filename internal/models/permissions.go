@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// The permission levels a user can have on a snippet, from least to most. Rules can set the first
+// three; PermissionManage is reserved for the owner of the snippet and the owners of its
+// organization.
+const (
+	PermissionNone   = "none"   // The user can't see the snippet, unless it's public.
+	PermissionRead   = "read"   // The user can see the snippet.
+	PermissionWrite  = "write"  // The user can edit the snippet.
+	PermissionManage = "manage" // The user can edit the snippet and change who may access it.
+)
+
+// permissionRanks orders the permission levels.
+var permissionRanks = map[string]int{PermissionNone: 0, PermissionRead: 1, PermissionWrite: 2, PermissionManage: 3}
+
+// PermissionRule sets the permission level of one member, or of every member with a role, on a
+// snippet of an organization.
+type PermissionRule struct {
+	SnippetID int    // SnippetID is the ID of the snippet.
+	UserID    int    // UserID is the member the rule applies to, or 0 for a rule for a role.
+	Role      string // Role is the role the rule applies to, or empty for a rule for a member.
+	Level     string // Level is PermissionNone, PermissionRead or PermissionWrite.
+}
+
+// Allows reports whether a permission level includes another, such as PermissionWrite including
+// PermissionRead.
+func Allows(level, want string) bool {
+	return permissionRanks[level] >= permissionRanks[want]
+}
+
+// ValidRuleLevel reports whether level can be set by a permission rule.
+func ValidRuleLevel(level string) bool {
+	return level == PermissionNone || level == PermissionRead || level == PermissionWrite
+}
+
+// ResolvePermission returns the permission level of a user on a snippet. memberRole is the user's
+// role in the snippet's organization, or empty if they aren't a member; userRule and roleRule are
+// the levels of the rules for the user and for their role, or empty if there are none.
+//
+// The owner of the snippet and the owners of its organization can manage it. Other members get
+// the level of their own rule, else of the rule for their role, else write. Everyone else can read
+// the snippet if it's public and not held for moderation.
+func ResolvePermission(s *Snippet, userID int, memberRole, userRule, roleRule string) string {
+	switch {
+	case userID != 0 && userID == s.OwnerID:
+		return PermissionManage
+	case s.OrgID != 0 && memberRole == RoleOwner:
+		return PermissionManage
+	case s.OrgID != 0 && memberRole != "":
+		if userRule != "" {
+			return userRule
+		}
+		if roleRule != "" {
+			return roleRule
+		}
+		return PermissionWrite
+	case !s.Private && !s.Held:
+		return PermissionRead
+	default:
+		return PermissionNone
+	}
+}
+
+// Permission returns the permission level of a user on a snippet; userID is 0 for anonymous
+// visitors. It returns ErrNoRecord if the snippet doesn't exist.
+func (sm *SnippetModel) Permission(id, userID int) (string, error) {
+	return sm.permission(sm.DB, id, userID)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// permission looks up what ResolvePermission needs in a single query.
+func (sm *SnippetModel) permission(q querier, id, userID int) (string, error) {
+
+	stmt := `SELECT COALESCE(s.owner_id, 0), COALESCE(s.org_id, 0), s.private, s.held,
+        COALESCE((SELECT m.role FROM organization_members m WHERE m.org_id = s.org_id AND m.user_id = ?), ''),
+        COALESCE((SELECT p.level FROM snippet_permissions p WHERE p.snippet_id = s.id AND p.user_id = ? AND p.role = ''), ''),
+        COALESCE((SELECT p.level FROM snippet_permissions p JOIN organization_members m ON m.org_id = s.org_id AND m.role = p.role
+            WHERE p.snippet_id = s.id AND p.user_id = 0 AND m.user_id = ?), '')
+    FROM snippets s WHERE s.id = ?`
+
+	s := &Snippet{ID: id}
+	var memberRole, userRule, roleRule string
+
+	err := q.QueryRow(stmt, userID, userID, userID, id).Scan(&s.OwnerID, &s.OrgID, &s.Private, &s.Held, &memberRole, &userRule, &roleRule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoRecord
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return ResolvePermission(s, userID, memberRole, userRule, roleRule), nil
+}
+
+// Update replaces the title and content of a snippet on behalf of a user, records them as its
+// last writer and keeps the new version as a revision. It returns ErrPermissionDenied unless the user may write the snippet or is an admin.
+func (sm *SnippetModel) Update(id int, title, content string, userID int, admin bool) error {
+
+	tx, err := sm.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	level, err := sm.permission(tx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !admin && !Allows(level, PermissionWrite) {
+		return ErrPermissionDenied
+	}
+
+	encoded, err := sm.Content.Encode(content)
+	if err != nil {
+		return err
+	}
+
+	hash, simhash := fingerprints(content)
+
+	stats := statsColumns(content)
+	now := currentTime(sm.Clock)
+
+	_, err = tx.Exec(`UPDATE snippets SET title = ?, content = ?, content_hash = ?, simhash = ?, search_text = ?, updated = ?, updated_by = NULLIF(?, 0),
+    line_count = ?, char_count = ?, byte_size = ?, reading_seconds = ? WHERE id = ?`,
+		title, encoded, hash, simhash, sm.searchText(content), now, userID, stats[0], stats[1], stats[2], stats[3], id)
+	if err != nil {
+		return err
+	}
+
+	if err := addRevision(tx, id, title, encoded, now, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Permissions returns the permission rules of a snippet, rules for roles first.
+func (sm *SnippetModel) Permissions(id int) ([]*PermissionRule, error) {
+
+	rows, err := sm.DB.Query(`SELECT snippet_id, user_id, role, level FROM snippet_permissions WHERE snippet_id = ? ORDER BY user_id, role`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*PermissionRule{}
+	for rows.Next() {
+		r := &PermissionRule{}
+		if err := rows.Scan(&r.SnippetID, &r.UserID, &r.Role, &r.Level); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// SetPermission adds or replaces a permission rule of a snippet on behalf of a user, or removes it
+// if its level is empty. It returns ErrPermissionDenied unless the user can manage the snippet.
+func (sm *SnippetModel) SetPermission(actorID int, rule PermissionRule) error {
+
+	if (rule.UserID == 0) == (rule.Role == "") || (rule.Role != "" && !ValidRole(rule.Role)) {
+		return errors.New("models: a permission rule needs either a member or a role")
+	}
+	if rule.Level != "" && !ValidRuleLevel(rule.Level) {
+		return errors.New("models: invalid permission level " + rule.Level)
+	}
+
+	level, err := sm.Permission(rule.SnippetID, actorID)
+	if err != nil {
+		return err
+	}
+	if level != PermissionManage {
+		return ErrPermissionDenied
+	}
+
+	if rule.Level == "" {
+		_, err = sm.DB.Exec(`DELETE FROM snippet_permissions WHERE snippet_id = ? AND user_id = ? AND role = ?`, rule.SnippetID, rule.UserID, rule.Role)
+		return err
+	}
+
+	stmt := `INSERT INTO snippet_permissions (snippet_id, user_id, role, level) VALUES (?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE level = VALUES(level)`
+
+	_, err = sm.DB.Exec(stmt, rule.SnippetID, rule.UserID, rule.Role, rule.Level)
+
+	return err
+}
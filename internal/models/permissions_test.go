@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestResolvePermission(t *testing.T) {
+
+	t.Parallel()
+
+	public := &Snippet{OwnerID: 1, OrgID: 7}
+	private := &Snippet{OwnerID: 1, OrgID: 7, Private: true}
+
+	tests := []struct {
+		name       string
+		snippet    *Snippet
+		userID     int
+		memberRole string
+		userRule   string
+		roleRule   string
+		want       string
+	}{
+		{"Owner", private, 1, "", "", "", PermissionManage},
+		{"Organization owner", private, 2, RoleOwner, PermissionNone, "", PermissionManage},
+		{"Member by default", private, 3, RoleMember, "", "", PermissionWrite},
+		{"Rule for the role", private, 3, RoleMember, "", PermissionRead, PermissionRead},
+		{"Rule for the member wins", private, 3, RoleMember, PermissionWrite, PermissionNone, PermissionWrite},
+		{"Outsider on a private snippet", private, 4, "", "", "", PermissionNone},
+		{"Outsider on a public snippet", public, 4, "", "", "", PermissionRead},
+		{"Anonymous", public, 0, "", "", "", PermissionRead},
+		{"Held", &Snippet{OwnerID: 1, Held: true}, 4, "", "", "", PermissionNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResolvePermission(tt.snippet, tt.userID, tt.memberRole, tt.userRule, tt.roleRule)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+
+	assert.Equal(t, Allows(PermissionManage, PermissionWrite), true)
+	assert.Equal(t, Allows(PermissionRead, PermissionWrite), false)
+}
+
+func TestSnippetPermissions(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	um, err := NewUserModel(db)
+	assert.NilError(t, err)
+	assert.NilError(t, um.Insert("Bob", "bob", "bob@example.com", "pa$$word"))
+	bob, err := um.GetByUsername("bob")
+	assert.NilError(t, err)
+
+	om := &OrganizationModel{DB: db}
+	orgID, err := om.Insert("Haiku Club", "haiku", 1)
+	assert.NilError(t, err)
+	token, err := om.Invite(orgID, 1, "bob@example.com", RoleMember, time.Now().Add(time.Hour))
+	assert.NilError(t, err)
+	_, err = om.AcceptInvitation(token, bob.ID, "bob@example.com")
+	assert.NilError(t, err)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+	id, err := sm.Insert("Team haiku", "Five, seven, five", 7, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, sm.SetOrg(id, orgID))
+
+	level, err := sm.Permission(id, bob.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, level, PermissionWrite)
+
+	assert.NilError(t, sm.Update(id, "Revised", "Five, seven, five again", bob.ID, false))
+	s, err := sm.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "Revised")
+	assert.Equal(t, s.UpdatedBy, bob.ID)
+
+	// Only those who manage the snippet can change its rules.
+	err = sm.SetPermission(bob.ID, PermissionRule{SnippetID: id, Role: RoleMember, Level: PermissionRead})
+	assert.Equal(t, errors.Is(err, ErrPermissionDenied), true)
+
+	assert.NilError(t, sm.SetPermission(1, PermissionRule{SnippetID: id, Role: RoleMember, Level: PermissionRead}))
+
+	err = sm.Update(id, "Vandalised", "Oops", bob.ID, false)
+	assert.Equal(t, errors.Is(err, ErrPermissionDenied), true)
+
+	assert.NilError(t, sm.SetPermission(1, PermissionRule{SnippetID: id, UserID: bob.ID, Level: PermissionWrite}))
+	level, err = sm.Permission(id, bob.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, level, PermissionWrite)
+
+	rules, err := sm.Permissions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 2)
+	assert.Equal(t, rules[0].Role, RoleMember)
+
+	assert.NilError(t, sm.SetPermission(1, PermissionRule{SnippetID: id, UserID: bob.ID}))
+	rules, err = sm.Permissions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(rules), 1)
+
+	// Outside the organization the snippet is only readable.
+	level, err = sm.Permission(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, level, PermissionRead)
+
+	_, err = sm.Permission(999, 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
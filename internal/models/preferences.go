@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// NeverExpires is the number of days of snippets that are kept until they're deleted.
+const NeverExpires = -1
+
+// ExpiryDays are the numbers of days a snippet can be kept for, as offered on the snippet form.
+var ExpiryDays = []int{NeverExpires, 365, 7, 1}
+
+// Preferences are the defaults a user's snippet form is filled in with.
+type Preferences struct {
+	DefaultExpires  int    `json:"default_expires"`            // DefaultExpires is one of ExpiryDays.
+	DefaultPrivate  bool   `json:"default_private"`            // DefaultPrivate keeps new snippets out of listings.
+	DefaultLanguage string `json:"default_language,omitempty"` // DefaultLanguage is a language, or empty to detect it.
+}
+
+// DefaultPreferences are the preferences of users who haven't chosen any.
+var DefaultPreferences = Preferences{DefaultExpires: 365}
+
+// PreferenceModel wraps a sql.DB connection pool and provides methods for the user_preferences
+// table.
+type PreferenceModel struct {
+	DB *sql.DB // DB is the database connection pool.
+}
+
+type PreferenceModelInterface interface {
+	Get(userID int) (Preferences, error)
+	Set(userID int, p Preferences) error
+}
+
+// Get returns the preferences of a user, or DefaultPreferences if they haven't chosen any.
+func (pm *PreferenceModel) Get(userID int) (Preferences, error) {
+
+	var p Preferences
+
+	err := pm.DB.QueryRow(`SELECT default_expires, default_private, default_language FROM user_preferences WHERE user_id = ?`, userID).
+		Scan(&p.DefaultExpires, &p.DefaultPrivate, &p.DefaultLanguage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultPreferences, nil
+	}
+
+	return p, err
+}
+
+// Set saves the preferences of a user. Preferences equal to DefaultPreferences remove their row.
+func (pm *PreferenceModel) Set(userID int, p Preferences) error {
+
+	if !ValidExpiryDays(p.DefaultExpires) {
+		return errors.New("models: invalid default expiry " + strconv.Itoa(p.DefaultExpires))
+	}
+
+	if p == DefaultPreferences {
+		_, err := pm.DB.Exec(`DELETE FROM user_preferences WHERE user_id = ?`, userID)
+		return err
+	}
+
+	_, err := pm.DB.Exec(`INSERT INTO user_preferences (user_id, default_expires, default_private, default_language) VALUES (?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE default_expires = VALUES(default_expires), default_private = VALUES(default_private), default_language = VALUES(default_language)`,
+		userID, p.DefaultExpires, p.DefaultPrivate, p.DefaultLanguage)
+
+	return err
+}
+
+// ValidExpiryDays reports whether days is one of ExpiryDays.
+func ValidExpiryDays(days int) bool {
+	for _, d := range ExpiryDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
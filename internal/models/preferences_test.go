@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestPreferenceModel(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	pm := PreferenceModel{DB: db}
+
+	p, err := pm.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, p, DefaultPreferences)
+
+	want := Preferences{DefaultExpires: 7, DefaultPrivate: true, DefaultLanguage: "go"}
+	assert.NilError(t, pm.Set(1, want))
+	p, err = pm.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, p, want)
+
+	if err := pm.Set(1, Preferences{DefaultExpires: 30}); err == nil {
+		t.Error("got no error for an expiry the snippet form doesn't offer")
+	}
+
+	// Going back to the defaults removes the row.
+	assert.NilError(t, pm.Set(1, DefaultPreferences))
+	var rows int
+	assert.NilError(t, db.QueryRow(`SELECT COUNT(*) FROM user_preferences`).Scan(&rows))
+	assert.Equal(t, rows, 0)
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// purgeTarget describes one kind of data that expires and can be purged.
+type purgeTarget struct {
+	name  string // name describes the data in summaries, such as "expired snippets".
+	table string // table is the table the rows are deleted from.
+	where string // where selects the rows to delete. Each ? is replaced with the current time.
+}
+
+// purgeTargets lists the expiring data in the order it's purged. Snippets stay in the trash for
+// 30 days. Views, accesses, collection entries, favorites, short links, permission rules, metadata,
+// webmentions, revisions and drafts are purged after snippets so that those of snippets deleted in the same
+// run are removed too.
+var purgeTargets = []purgeTarget{
+	{"expired snippets", "snippets", "expires < ?"},
+	{"snippets in the trash for 30 days", "snippets", "deleted_at < DATE_SUB(?, INTERVAL 30 DAY)"},
+	{"views of deleted snippets", "snippet_views", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"access log of deleted snippets", "snippet_accesses", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"collection entries of deleted snippets", "collection_snippets", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"favorites of deleted snippets", "favorites", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"short links of deleted snippets", "short_links", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"permission rules of deleted snippets", "snippet_permissions", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"metadata of deleted snippets", "snippet_metadata", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"webmentions of deleted snippets", "webmentions", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"revisions of deleted snippets", "snippet_revisions", "snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"drafts of deleted snippets", "snippet_drafts", "snippet_id <> 0 AND snippet_id NOT IN (SELECT id FROM snippets)"},
+	{"expired drafts", "snippet_drafts", "expires < ?"},
+	{"expired edit locks", "snippet_locks", "expires < ?"},
+	{"expired share links", "share_links", "expires < ?"},
+	{"expired invitations", "organization_invitations", "expires < ?"},
+	{"expired data exports", "data_exports", "expires < ?"},
+	{"expired sessions", "sessions", "expiry < ?"},
+}
+
+// PurgeCount is the number of rows purged, or that would be purged, for one kind of data.
+type PurgeCount struct {
+	Name string
+	Rows int64
+}
+
+// PurgeModel deletes expired data. It's shared by the background job of the web server and the
+// purge command of snippetboxctl.
+type PurgeModel struct {
+	DB        *sql.DB
+	BatchSize int         // BatchSize is the maximum number of rows deleted per statement; 0 means 1000.
+	Clock     clock.Clock // Clock decides what has expired. It defaults to the system clock.
+}
+
+// Purge deletes expired data in batches and returns the number of rows deleted for each kind of
+// data. With dryRun set nothing is deleted and the counts are the rows that would be.
+func (pm *PurgeModel) Purge(dryRun bool) ([]PurgeCount, error) {
+
+	counts := []PurgeCount{}
+
+	for _, target := range purgeTargets {
+		var n int64
+		var err error
+
+		if dryRun {
+			n, err = pm.count(target)
+		} else {
+			n, err = pm.delete(target)
+		}
+
+		counts = append(counts, PurgeCount{Name: target.name, Rows: n})
+
+		if err != nil {
+			return counts, fmt.Errorf("purging %s: %w", target.name, err)
+		}
+	}
+
+	return counts, nil
+}
+
+// count returns the number of rows of a target that are due to be purged.
+func (pm *PurgeModel) count(target purgeTarget) (int64, error) {
+
+	var n int64
+
+	err := pm.DB.QueryRow(`SELECT COUNT(*) FROM `+target.table+` WHERE `+target.where, pm.args(target)...).Scan(&n)
+
+	return n, err
+}
+
+// delete removes the rows of a target in batches, so that large purges don't hold locks for long,
+// and returns the total number of rows deleted.
+func (pm *PurgeModel) delete(target purgeTarget) (int64, error) {
+
+	batchSize := pm.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	stmt := `DELETE FROM ` + target.table + ` WHERE ` + target.where + ` LIMIT ?`
+
+	var total int64
+
+	for {
+		res, err := pm.DB.Exec(stmt, append(pm.args(target), batchSize)...)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// args returns the arguments for the placeholders in the where clause of a target.
+func (pm *PurgeModel) args(target purgeTarget) []any {
+	now := clock.Now(pm.Clock)
+
+	args := []any{}
+	for i := 0; i < strings.Count(target.where, "?"); i++ {
+		args = append(args, now)
+	}
+
+	return args
+}
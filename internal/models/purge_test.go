@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestPurgeModelPurge(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO snippets (title, content, created, expires, updated) VALUES
+    ('Live', 'a', UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 DAY), UTC_TIMESTAMP()),
+    ('Expired 1', 'b', UTC_TIMESTAMP(), DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY), UTC_TIMESTAMP()),
+    ('Expired 2', 'c', UTC_TIMESTAMP(), DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY), UTC_TIMESTAMP())`)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`INSERT INTO snippet_views (snippet_id, day, views) VALUES (1, '2024-01-01', 3), (2, '2024-01-01', 5)`)
+	assert.NilError(t, err)
+
+	_, err = db.Exec(`INSERT INTO sessions (token, data, expiry) VALUES
+    ('live', '', DATE_ADD(UTC_TIMESTAMP(6), INTERVAL 1 DAY)),
+    ('stale', '', DATE_SUB(UTC_TIMESTAMP(6), INTERVAL 1 DAY))`)
+	assert.NilError(t, err)
+
+	pm := &PurgeModel{DB: db, BatchSize: 1}
+
+	// A dry run only counts; snippet 2 still exists, so its views aren't due yet.
+	counts, err := pm.Purge(true)
+	assert.NilError(t, err)
+	assert.Equal(t, counts[0].Rows, int64(2))
+	assert.Equal(t, counts[1].Rows, int64(0))
+	assert.Equal(t, counts[2].Rows, int64(1))
+
+	counts, err = pm.Purge(false)
+	assert.NilError(t, err)
+	assert.Equal(t, counts[0].Rows, int64(2))
+	assert.Equal(t, counts[1].Rows, int64(1))
+	assert.Equal(t, counts[2].Rows, int64(1))
+
+	counts, err = pm.Purge(true)
+	assert.NilError(t, err)
+	for _, c := range counts {
+		assert.Equal(t, c.Rows, int64(0))
+	}
+}
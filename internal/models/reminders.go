@@ -0,0 +1,207 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// ReminderDays are the numbers of days before expiry a reminder can be sent. 0 turns reminders off.
+var ReminderDays = []int{0, 1, 3, 7}
+
+// ValidReminderDays reports whether days is one of ReminderDays.
+func ValidReminderDays(days int) bool {
+	for _, d := range ReminderDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiryReminder is a snippet about to expire whose owner wants to be reminded.
+type ExpiryReminder struct {
+	SnippetID int       // SnippetID is the ID of the snippet.
+	ULID      string    // ULID is the public identifier of the snippet, or empty for old snippets.
+	Title     string    // Title is the title of the snippet.
+	Expires   time.Time // Expires is when the snippet expires.
+	UserID    int       // UserID is the ID of the owner.
+	Name      string    // Name is the name of the owner.
+	Email     string    // Email is the address the reminder is sent to.
+}
+
+// PublicID returns the identifier to use in links to the snippet.
+func (r *ExpiryReminder) PublicID() string {
+	s := Snippet{ID: r.SnippetID, ULID: r.ULID}
+	return s.PublicID()
+}
+
+// ReminderModel wraps a sql.DB connection pool and provides methods for the expiry_reminders table
+// and the snippets reminders are sent for. The links extending snippets from a reminder are signed
+// with Key, and only work until the snippet is extended.
+type ReminderModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Key   []byte      // Key signs extension tokens. It's the share link key.
+	Clock clock.Clock // Clock decides which reminders are due. It defaults to the system clock.
+}
+
+type ReminderModelInterface interface {
+	Days(userID int) (int, error)
+	SetDays(userID int, days int) error
+	Due() ([]*ExpiryReminder, error)
+	Claim(r *ExpiryReminder) (bool, error)
+	Token(r *ExpiryReminder) string
+	Extend(snippetID int, token string, by time.Duration) (time.Time, error)
+}
+
+// Days returns how many days before expiry a user wants to be reminded, 0 if they don't.
+func (rm *ReminderModel) Days(userID int) (int, error) {
+
+	var days int
+
+	err := rm.DB.QueryRow(`SELECT days FROM expiry_reminders WHERE user_id = ?`, userID).Scan(&days)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+
+	return days, err
+}
+
+// SetDays sets how many days before expiry a user is reminded. 0 turns reminders off.
+func (rm *ReminderModel) SetDays(userID int, days int) error {
+
+	if !ValidReminderDays(days) {
+		return errors.New("models: invalid reminder days " + strconv.Itoa(days))
+	}
+
+	if days == 0 {
+		_, err := rm.DB.Exec(`DELETE FROM expiry_reminders WHERE user_id = ?`, userID)
+		return err
+	}
+
+	stmt := `INSERT INTO expiry_reminders (user_id, days) VALUES (?, ?)
+    ON DUPLICATE KEY UPDATE days = VALUES(days)`
+
+	_, err := rm.DB.Exec(stmt, userID, days)
+
+	return err
+}
+
+// Due returns the unexpired snippets expiring within the number of days their owner chose that
+// they weren't reminded about yet, soonest first.
+func (rm *ReminderModel) Due() ([]*ExpiryReminder, error) {
+
+	now := currentTime(rm.Clock)
+
+	stmt := `SELECT s.id, COALESCE(s.ulid, ''), s.title, s.expires, u.id, u.name, u.email FROM snippets s
+    JOIN expiry_reminders r ON r.user_id = s.owner_id
+    JOIN users u ON u.id = s.owner_id
+    WHERE s.expires > ? AND s.deleted_at IS NULL AND s.expires <= DATE_ADD(?, INTERVAL r.days DAY) AND NOT s.reminded_expires <=> s.expires
+    ORDER BY s.expires, s.id`
+
+	rows, err := rm.DB.Query(stmt, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []*ExpiryReminder{}
+	for rows.Next() {
+		r := &ExpiryReminder{}
+		if err := rows.Scan(&r.SnippetID, &r.ULID, &r.Title, &r.Expires, &r.UserID, &r.Name, &r.Email); err != nil {
+			return nil, err
+		}
+		due = append(due, r)
+	}
+
+	return due, rows.Err()
+}
+
+// Claim records that a reminder returned by Due is being sent. It reports false if it was claimed
+// since by another server, or the snippet's expiry changed, in which case it mustn't be sent.
+func (rm *ReminderModel) Claim(r *ExpiryReminder) (bool, error) {
+
+	res, err := rm.DB.Exec(`UPDATE snippets SET reminded_expires = expires WHERE id = ? AND expires = ? AND NOT reminded_expires <=> expires`,
+		r.SnippetID, r.Expires)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// Token returns the token of the link extending the snippet of a reminder.
+func (rm *ReminderModel) Token(r *ExpiryReminder) string {
+	return SignExtendToken(rm.Key, r.SnippetID, r.Expires)
+}
+
+// Extend pushes the expiry of a snippet back by the given duration if token was signed for the
+// snippet's current expiry, and returns the new expiry. Since the expiry changes, a token only works
+// once. It returns ErrNoRecord if the token is invalid or used, or the snippet has expired.
+func (rm *ReminderModel) Extend(snippetID int, token string, by time.Duration) (time.Time, error) {
+
+	expires, err := ParseExtendToken(rm.Key, snippetID, token)
+	if err != nil {
+		return time.Time{}, ErrNoRecord
+	}
+
+	extended := expires.Add(by)
+
+	res, err := rm.DB.Exec(`UPDATE snippets SET expires = ? WHERE id = ? AND expires = ? AND expires > ?`,
+		extended, snippetID, expires, currentTime(rm.Clock))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n == 0 {
+		return time.Time{}, ErrNoRecord
+	}
+
+	return extended, nil
+}
+
+// SignExtendToken returns the token of a link extending a snippet with the given expiry: the expiry
+// followed by an HMAC-SHA256 signature over it and the snippet ID.
+func SignExtendToken(key []byte, snippetID int, expires time.Time) string {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(expires.Unix()))
+
+	return shareTokenEncoding.EncodeToString(append(payload, signExtend(key, snippetID, payload)...))
+}
+
+// ParseExtendToken checks the signature of an extension token for a snippet and returns the expiry
+// it was made for.
+func ParseExtendToken(key []byte, snippetID int, token string) (time.Time, error) {
+	data, err := shareTokenEncoding.DecodeString(token)
+	if err != nil || len(data) != 8+sha256.Size {
+		return time.Time{}, errors.New("models: malformed extension token")
+	}
+
+	payload, sig := data[:8], data[8:]
+	if !hmac.Equal(sig, signExtend(key, snippetID, payload)) {
+		return time.Time{}, errors.New("models: invalid extension token signature")
+	}
+
+	return time.Unix(int64(binary.BigEndian.Uint64(payload)), 0).UTC(), nil
+}
+
+// signExtend computes the signature of an extension token payload for a snippet. The prefix keeps
+// share tokens and extension tokens, signed with the same key, from being used for each other.
+func signExtend(key []byte, snippetID int, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("extend:" + strconv.Itoa(snippetID) + ":"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
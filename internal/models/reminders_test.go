@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestExtendToken(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	expires := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	token := SignExtendToken(key, 7, expires)
+
+	got, err := ParseExtendToken(key, 7, token)
+	assert.NilError(t, err)
+	assert.Equal(t, got, expires)
+
+	// Tokens only work for the snippet they were made for.
+	_, err = ParseExtendToken(key, 8, token)
+	assert.Equal(t, err != nil, true)
+
+	// Share tokens signed with the same key aren't extension tokens.
+	_, err = ParseExtendToken(key, 7, SignShareToken(key, &Share{ID: 1, SnippetID: 7, Expires: expires}))
+	assert.Equal(t, err != nil, true)
+}
+
+func TestReminderModel(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	soon, err := sm.Insert("Soon", "content", 1, 1)
+	assert.NilError(t, err)
+	_, err = sm.Insert("Later", "content", 30, 1)
+	assert.NilError(t, err)
+
+	rm := &ReminderModel{DB: db, Key: []byte("0123456789abcdef0123456789abcdef")}
+
+	assert.NilError(t, rm.SetDays(1, 3))
+	days, err := rm.Days(1)
+	assert.NilError(t, err)
+	assert.Equal(t, days, 3)
+
+	due, err := rm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].SnippetID, soon)
+	assert.Equal(t, due[0].Email, "alice@example.com")
+
+	claimed, err := rm.Claim(due[0])
+	assert.NilError(t, err)
+	assert.Equal(t, claimed, true)
+
+	claimed, err = rm.Claim(due[0])
+	assert.NilError(t, err)
+	assert.Equal(t, claimed, false)
+
+	token := rm.Token(due[0])
+
+	extended, err := rm.Extend(soon, token, 24*time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, extended, due[0].Expires.Add(24*time.Hour))
+
+	_, err = rm.Extend(soon, token, 24*time.Hour)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// The extended snippet is due for a reminder again once it's close to its new expiry.
+	due, err = rm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 1)
+	assert.Equal(t, due[0].Expires, extended)
+
+	assert.NilError(t, rm.SetDays(1, 0))
+	due, err = rm.Due()
+	assert.NilError(t, err)
+	assert.Equal(t, len(due), 0)
+}
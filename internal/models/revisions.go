@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Revision is a version of a snippet, as it was written by a user.
+type Revision struct {
+	SnippetID int       // SnippetID is the ID of the snippet.
+	Number    int       // Number counts the versions of the snippet from 1, the version it was created with.
+	Title     string    // Title is the title of the snippet in this version.
+	Content   string    // Content is the content of the snippet in this version.
+	Created   time.Time // Created is when the version was written.
+	CreatedBy int       // CreatedBy is the ID of the user who wrote it, or 0 if unknown.
+}
+
+// revisionColumns is the column list selected by every query that returns revisions.
+const revisionColumns = `snippet_id, number, title, content, created, COALESCE(created_by, 0)`
+
+// addRevision records a version of a snippet, numbered after the last one, with its content
+// already encoded.
+func addRevision(tx *sql.Tx, snippetID int, title string, encoded []byte, created time.Time, userID int) error {
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, number, title, content, created, created_by)
+    SELECT ?, COALESCE(MAX(number), 0) + 1, ?, ?, ?, NULLIF(?, 0) FROM snippet_revisions WHERE snippet_id = ?`
+
+	_, err := tx.Exec(stmt, snippetID, title, encoded, created, userID, snippetID)
+
+	return err
+}
+
+// Revisions returns the versions of a snippet, oldest first.
+func (sm *SnippetModel) Revisions(snippetID int) ([]*Revision, error) {
+
+	rows, err := sm.DB.Query(`SELECT `+revisionColumns+` FROM snippet_revisions WHERE snippet_id = ? ORDER BY number`, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		r, err := sm.scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// Revision returns a version of a snippet by its number, or ErrNoRecord if there's none.
+func (sm *SnippetModel) Revision(snippetID, number int) (*Revision, error) {
+
+	row := sm.DB.QueryRow(`SELECT `+revisionColumns+` FROM snippet_revisions WHERE snippet_id = ? AND number = ?`, snippetID, number)
+
+	r, err := sm.scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+
+	return r, err
+}
+
+// scanRevision reads a revision row and decodes its content.
+func (sm *SnippetModel) scanRevision(row rowScanner) (*Revision, error) {
+
+	r := &Revision{}
+	var content []byte
+	if err := row.Scan(&r.SnippetID, &r.Number, &r.Title, &content, &r.Created, &r.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	var err error
+	r.Content, err = sm.Content.Decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("models: snippet %d revision %d: %w", r.SnippetID, r.Number, err)
+	}
+
+	return r, nil
+}
+
+// reencryptRevisions rewrites the content of every revision that isn't encrypted with the active
+// key, like Reencrypt does for snippets, and returns the number of rewritten revisions.
+func (sm *SnippetModel) reencryptRevisions(batchSize int) (int, error) {
+
+	active := sm.Content.Keys.ActiveKeyID()
+
+	type revisionKey struct{ snippetID, number int }
+
+	count := 0
+	last := revisionKey{}
+
+	for {
+		rows, err := sm.DB.Query(`SELECT snippet_id, number, content FROM snippet_revisions
+    WHERE (snippet_id, number) > (?, ?) ORDER BY snippet_id, number LIMIT ?`, last.snippetID, last.number, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		updates := map[revisionKey][]byte{}
+		seen := 0
+		for rows.Next() {
+			var key revisionKey
+			var data []byte
+			if err := rows.Scan(&key.snippetID, &key.number, &data); err != nil {
+				rows.Close()
+				return count, err
+			}
+			seen++
+			last = key
+
+			if keyID, ok := envelopeKeyID(data); ok && keyID == active {
+				continue
+			}
+
+			content, err := sm.Content.Decode(data)
+			if err != nil {
+				rows.Close()
+				return count, fmt.Errorf("models: snippet %d revision %d: %w", key.snippetID, key.number, err)
+			}
+
+			updates[key], err = sm.Content.Encode(content)
+			if err != nil {
+				rows.Close()
+				return count, err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, err
+		}
+
+		for key, data := range updates {
+			if _, err := sm.DB.Exec(`UPDATE snippet_revisions SET content = ? WHERE snippet_id = ? AND number = ?`,
+				data, key.snippetID, key.number); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if seen < batchSize {
+			return count, nil
+		}
+	}
+}
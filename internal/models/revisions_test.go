@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestSnippetModelRevisions(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	id, err := sm.Insert("First draft", "one\ntwo\n", 30, 1)
+	assert.NilError(t, err)
+
+	assert.NilError(t, sm.Update(id, "Second draft", "one\nthree\n", 1, false))
+
+	revisions, err := sm.Revisions(id)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, revisions[0].Number, 1)
+	assert.Equal(t, revisions[0].Title, "First draft")
+	assert.Equal(t, revisions[0].Content, "one\ntwo\n")
+	assert.Equal(t, revisions[1].Number, 2)
+	assert.Equal(t, revisions[1].Content, "one\nthree\n")
+	assert.Equal(t, revisions[1].CreatedBy, 1)
+
+	r, err := sm.Revision(id, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, r.Title, "Second draft")
+
+	_, err = sm.Revision(id, 3)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,358 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// SearchQuery is a search for snippets: words that must all appear in the title, and optionally
+// the language the snippet must be in and metadata it must have.
+type SearchQuery struct {
+	Text     string          // Text holds the words to look for, separated by spaces.
+	Language string          // Language restricts the search to one language, or is empty for any.
+	Metadata []MetadataField // Metadata holds pairs the snippet must have. Saved searches don't keep them.
+}
+
+// IsZero reports whether the query has neither words nor a language nor metadata, and so matches
+// everything.
+func (q SearchQuery) IsZero() bool {
+	return strings.TrimSpace(q.Text) == "" && q.Language == "" && len(q.Metadata) == 0
+}
+
+// Values returns the query as the parameters of the search page. Metadata pairs are "meta"
+// parameters in the form "name:value".
+func (q SearchQuery) Values() url.Values {
+	v := url.Values{"q": {q.Text}}
+	if q.Language != "" {
+		v.Set("language", q.Language)
+	}
+	for _, f := range q.Metadata {
+		v.Add("meta", f.Name+":"+f.Value)
+	}
+	return v
+}
+
+// Matches reports whether a snippet matches the words and language of the query. It's what Search
+// does in the database, for code that already holds the snippets; snippets don't hold their
+// metadata, so it's not checked.
+func (q SearchQuery) Matches(s *Snippet) bool {
+	if q.Language != "" && !strings.EqualFold(s.Language, q.Language) {
+		return false
+	}
+
+	title := strings.ToLower(s.Title)
+	for _, word := range strings.Fields(q.Text) {
+		if !strings.Contains(title, strings.ToLower(word)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Words returns the words of the query as full-text search sees them: lowercase runs of letters,
+// digits and underscores. Everything else, including the operators of MySQL's boolean mode,
+// separates words.
+func (q SearchQuery) Words() []string {
+	return strings.FieldsFunc(strings.ToLower(q.Text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// SavedSearch is a search a user saved under a name.
+type SavedSearch struct {
+	ID         int         // ID is the unique identifier of the saved search.
+	UserID     int         // UserID is the ID of the user who saved it.
+	Name       string      // Name is unique among the saved searches of the user.
+	Query      SearchQuery // Query is the search.
+	Notify     bool        // Notify reports whether the user is emailed about new matches.
+	LastSeenID int         // LastSeenID is the newest snippet the search was checked against for notifications.
+	Created    time.Time   // Created is when the search was saved.
+}
+
+// URL returns the address of the search page running the search.
+func (s *SavedSearch) URL() string {
+	return "/search?" + s.Query.Values().Encode()
+}
+
+// Search retrieves the most recently created listed snippets matching a query that are newer than
+// the snippet afterID, with the words anywhere in their title. It's how saved searches find new
+// matches; the search page ranks matches in the content too with FullTextSearch.
+func (sm *SnippetModel) Search(q SearchQuery, afterID int, limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private AND id > ?`
+	args := []any{currentTime(sm.Clock), afterID}
+
+	for _, word := range strings.Fields(q.Text) {
+		stmt += ` AND title LIKE ?`
+		args = append(args, "%"+escapeLike(word)+"%")
+	}
+
+	filters, filterArgs := searchFilters(q)
+	stmt += filters + ` ORDER BY id DESC LIMIT ?`
+	args = append(append(args, filterArgs...), limit)
+
+	return sm.query(stmt, args...)
+}
+
+// FullTextSearch retrieves the listed snippets with every word of a query, or a word starting
+// with it, in their title or content, best matches first, skipping the first offset of them.
+// Content encrypted at rest isn't indexed, so those snippets are only found by their title.
+func (sm *SnippetModel) FullTextSearch(q SearchQuery, offset, limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private`
+	args := []any{currentTime(sm.Clock)}
+
+	// In boolean mode, "+word*" requires a word starting with word.
+	words := q.Words()
+	against := ""
+	for _, word := range words {
+		against += "+" + word + "* "
+	}
+	if against != "" {
+		stmt += ` AND MATCH(title, search_text) AGAINST (? IN BOOLEAN MODE)`
+		args = append(args, against)
+	}
+
+	filters, filterArgs := searchFilters(q)
+	stmt += filters
+	args = append(args, filterArgs...)
+
+	if against != "" {
+		stmt += ` ORDER BY MATCH(title, search_text) AGAINST (? IN BOOLEAN MODE) DESC, id DESC`
+		args = append(args, against)
+	} else {
+		stmt += ` ORDER BY id DESC`
+	}
+	stmt += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	return sm.query(stmt, args...)
+}
+
+// searchFilters returns the conditions restricting a search to the language and metadata of a
+// query, to be added to its WHERE clause, and their arguments.
+func searchFilters(q SearchQuery) (string, []any) {
+	stmt, args := "", []any{}
+
+	if q.Language != "" {
+		stmt += ` AND language = ?`
+		args = append(args, q.Language)
+	}
+	for _, f := range q.Metadata {
+		stmt += ` AND EXISTS (SELECT true FROM snippet_metadata m WHERE m.snippet_id = snippets.id AND m.name = ? AND m.value = ?)`
+		args = append(args, f.Name, f.Value)
+	}
+
+	return stmt, args
+}
+
+// searchText returns the value of the search_text column for content: the content itself, or NULL
+// when content is encrypted at rest, which it would otherwise give away.
+func (sm *SnippetModel) searchText(content string) any {
+	if sm.Content.Keys != nil {
+		return nil
+	}
+	return content
+}
+
+// BackfillSearchText stores the plain text of the snippets written before full-text search
+// indexed their content, in batches of batchSize, and returns the number of snippets updated.
+// Encrypted content isn't indexed, so there's nothing to do when encryption is configured.
+func (sm *SnippetModel) BackfillSearchText(batchSize int) (int, error) {
+
+	if sm.Content.Keys != nil {
+		return 0, errors.New("models: encrypted content isn't indexed for search")
+	}
+
+	count := 0
+
+	for {
+		rows, err := sm.DB.Query(`SELECT id, content FROM snippets WHERE search_text IS NULL ORDER BY id LIMIT ?`, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		contents := map[int]string{}
+		for rows.Next() {
+			var id int
+			var data []byte
+			if err := rows.Scan(&id, &data); err != nil {
+				rows.Close()
+				return count, err
+			}
+
+			content, err := sm.Content.Decode(data)
+			if err != nil {
+				rows.Close()
+				return count, fmt.Errorf("models: snippet %d: %w", id, err)
+			}
+			contents[id] = content
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, err
+		}
+
+		for id, content := range contents {
+			if _, err := sm.DB.Exec(`UPDATE snippets SET search_text = ? WHERE id = ?`, content, id); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(contents) < batchSize {
+			return count, nil
+		}
+	}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern so that s matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SavedSearchModel wraps a sql.DB connection pool and provides methods for the saved_searches
+// table.
+type SavedSearchModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps new saved searches. It defaults to the system clock.
+}
+
+type SavedSearchModelInterface interface {
+	Insert(userID int, name string, q SearchQuery, notify bool) (int, error)
+	Get(id int) (*SavedSearch, error)
+	ByUser(userID int) ([]*SavedSearch, error)
+	SetNotify(id int, notify bool) error
+	Delete(id int) error
+	Notifying() ([]*SavedSearch, error)
+	Advance(s *SavedSearch, lastSeenID int) (bool, error)
+}
+
+// savedSearchColumns is the column list selected by every query that returns saved searches.
+const savedSearchColumns = `id, user_id, name, query, language, notify, last_seen_id, created`
+
+// Insert saves a search and returns its ID. Notifications only report snippets created after it
+// was saved. It returns ErrDuplicateSavedSearch if the user already has a search with the name.
+func (ssm *SavedSearchModel) Insert(userID int, name string, q SearchQuery, notify bool) (int, error) {
+
+	stmt := `INSERT INTO saved_searches (user_id, name, query, language, notify, last_seen_id, created)
+    SELECT ?, ?, ?, ?, ?, COALESCE(MAX(id), 0), ? FROM snippets`
+
+	res, err := ssm.DB.Exec(stmt, userID, name, q.Text, q.Language, notify, currentTime(ssm.Clock))
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "saved_searches_uc_user_name") {
+			return 0, ErrDuplicateSavedSearch
+		}
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
+
+// Get returns the saved search with the given ID, or ErrNoRecord.
+func (ssm *SavedSearchModel) Get(id int) (*SavedSearch, error) {
+
+	row := ssm.DB.QueryRow(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = ?`, id)
+
+	s, err := scanSavedSearch(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+
+	return s, err
+}
+
+// ByUser returns the saved searches of a user by name.
+func (ssm *SavedSearchModel) ByUser(userID int) ([]*SavedSearch, error) {
+	return ssm.query(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE user_id = ? ORDER BY name`, userID)
+}
+
+// SetNotify turns the notifications of a saved search on or off. Turning them on skips the
+// snippets created while they were off.
+func (ssm *SavedSearchModel) SetNotify(id int, notify bool) error {
+
+	stmt := `UPDATE saved_searches SET
+    last_seen_id = IF(? AND NOT notify, (SELECT COALESCE(MAX(id), 0) FROM snippets), last_seen_id), notify = ?
+    WHERE id = ?`
+
+	_, err := ssm.DB.Exec(stmt, notify, notify, id)
+
+	return err
+}
+
+// Delete removes a saved search.
+func (ssm *SavedSearchModel) Delete(id int) error {
+
+	_, err := ssm.DB.Exec(`DELETE FROM saved_searches WHERE id = ?`, id)
+
+	return err
+}
+
+// Notifying returns the saved searches whose owners want to hear about new matches.
+func (ssm *SavedSearchModel) Notifying() ([]*SavedSearch, error) {
+	return ssm.query(`SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE notify ORDER BY id`)
+}
+
+// Advance records that a saved search returned by Notifying was checked against the snippets up to
+// lastSeenID. It reports false if the search was advanced since by another server, in which case
+// the new matches mustn't be reported again.
+func (ssm *SavedSearchModel) Advance(s *SavedSearch, lastSeenID int) (bool, error) {
+
+	res, err := ssm.DB.Exec(`UPDATE saved_searches SET last_seen_id = ? WHERE id = ? AND notify AND last_seen_id = ?`,
+		lastSeenID, s.ID, s.LastSeenID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n == 1, err
+}
+
+// query runs a statement that selects savedSearchColumns and returns the scanned saved searches.
+func (ssm *SavedSearchModel) query(stmt string, args ...any) ([]*SavedSearch, error) {
+
+	rows, err := ssm.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []*SavedSearch{}
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+
+	return searches, rows.Err()
+}
+
+// scanSavedSearch scans a row of savedSearchColumns.
+func scanSavedSearch(row rowScanner) (*SavedSearch, error) {
+	s := &SavedSearch{}
+
+	err := row.Scan(&s.ID, &s.UserID, &s.Name, &s.Query.Text, &s.Query.Language, &s.Notify, &s.LastSeenID, &s.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestSearchQueryMatches(t *testing.T) {
+	t.Parallel()
+
+	s := &Snippet{Title: "Retry loop with backoff", Language: "go"}
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  bool
+	}{
+		{name: "Every word", query: SearchQuery{Text: "BACKOFF retry"}, want: true},
+		{name: "Missing word", query: SearchQuery{Text: "retry jitter"}, want: false},
+		{name: "Language", query: SearchQuery{Text: "loop", Language: "Go"}, want: true},
+		{name: "Other language", query: SearchQuery{Language: "python"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.query.Matches(s), tt.want)
+		})
+	}
+}
+
+func TestSearchQueryWords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "Lowercase", text: "Retry LOOP", want: []string{"retry", "loop"}},
+		{name: "Operators", text: `+retry -loop "back*off"`, want: []string{"retry", "loop", "back", "off"}},
+		{name: "Identifiers", text: "max_retries(3)", want: []string{"max_retries", "3"}},
+		{name: "Empty", text: " ~@ ", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words := SearchQuery{Text: tt.text}.Words()
+			assert.Equal(t, len(words), len(tt.want))
+			for i := range tt.want {
+				assert.Equal(t, words[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestSnippetModelFullTextSearch(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	inTitle, err := sm.Insert("Retry loop with backoff", "for attempt := range 5 {}", 7, 1)
+	assert.NilError(t, err)
+	inContent, err := sm.Insert("Waiting", "sleep with exponential backoff between attempts", 7, 1)
+	assert.NilError(t, err)
+	_, err = sm.Insert("Unrelated", "nothing to see here", 7, 1)
+	assert.NilError(t, err)
+
+	// Words are found in the title and the content, and as the start of longer words.
+	results, err := sm.FullTextSearch(SearchQuery{Text: "backoff"}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 2)
+
+	results, err = sm.FullTextSearch(SearchQuery{Text: "expon"}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, inContent)
+
+	// Every word must appear.
+	results, err = sm.FullTextSearch(SearchQuery{Text: "backoff retry"}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, inTitle)
+
+	// Results are paginated.
+	results, err = sm.FullTextSearch(SearchQuery{Text: "backoff"}, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+
+	// Boolean mode operators in the query are taken as separators.
+	results, err = sm.FullTextSearch(SearchQuery{Text: `-backoff "sleep`}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, inContent)
+}
+
+func TestSavedSearchModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	old, err := sm.Insert("100% retry loop", "for {}", 30, 1)
+	assert.NilError(t, err)
+
+	// Wildcards in the query match literally.
+	results, err := sm.Search(SearchQuery{Text: "100%"}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, old)
+
+	results, err = sm.Search(SearchQuery{Text: "1_0"}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 0)
+
+	ssm := &SavedSearchModel{DB: db}
+
+	id, err := ssm.Insert(1, "Loops", SearchQuery{Text: "loop"}, true)
+	assert.NilError(t, err)
+
+	_, err = ssm.Insert(1, "Loops", SearchQuery{Text: "other"}, false)
+	assert.Equal(t, errors.Is(err, ErrDuplicateSavedSearch), true)
+
+	// Snippets created before the search was saved aren't new.
+	s, err := ssm.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.LastSeenID, old)
+
+	newer, err := sm.Insert("Event loop", "select {}", 30, 1)
+	assert.NilError(t, err)
+
+	notifying, err := ssm.Notifying()
+	assert.NilError(t, err)
+	assert.Equal(t, len(notifying), 1)
+
+	matches, err := sm.Search(notifying[0].Query, notifying[0].LastSeenID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(matches), 1)
+	assert.Equal(t, matches[0].ID, newer)
+
+	advanced, err := ssm.Advance(notifying[0], newer)
+	assert.NilError(t, err)
+	assert.Equal(t, advanced, true)
+
+	advanced, err = ssm.Advance(notifying[0], newer)
+	assert.NilError(t, err)
+	assert.Equal(t, advanced, false)
+
+	assert.NilError(t, ssm.SetNotify(id, false))
+
+	notifying, err = ssm.Notifying()
+	assert.NilError(t, err)
+	assert.Equal(t, len(notifying), 0)
+
+	assert.NilError(t, ssm.Delete(id))
+
+	_, err = ssm.Get(id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// ShareKeySize is the size in bytes of the key share tokens are signed with.
+const ShareKeySize = 32
+
+// Share is a link that lets anyone holding it view a private snippet until it expires.
+type Share struct {
+	ID        int       // ID is the unique identifier of the link.
+	SnippetID int       // SnippetID is the ID of the shared snippet.
+	CreatedBy int       // CreatedBy is the ID of the user who created the link.
+	Created   time.Time // Created is the time the link was created.
+	Expires   time.Time // Expires is the time after which the link stops working.
+}
+
+// ShareModel wraps a sql.DB connection pool and provides methods for the share_links table. The
+// tokens in share links are signed with Key rather than stored, so a link can be shown again from
+// its row, and deleting the row revokes it.
+type ShareModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Key   []byte      // Key signs share tokens. Changing it invalidates every link.
+	Clock clock.Clock // Clock decides which links have expired. It defaults to the system clock.
+}
+
+type ShareModelInterface interface {
+	Insert(snippetID, userID int, expires time.Time) (*Share, error)
+	BySnippet(snippetID int) ([]*Share, error)
+	Delete(snippetID, id int) error
+	Token(share *Share) string
+	Verify(snippetID int, token string) (*Share, error)
+}
+
+// Insert creates a share link for a snippet.
+func (sm *ShareModel) Insert(snippetID, userID int, expires time.Time) (*Share, error) {
+
+	share := &Share{SnippetID: snippetID, CreatedBy: userID, Created: currentTime(sm.Clock), Expires: expires.UTC().Truncate(time.Second)}
+
+	stmt := `INSERT INTO share_links (snippet_id, created_by, created, expires) VALUES (?, ?, ?, ?)`
+
+	res, err := sm.DB.Exec(stmt, share.SnippetID, share.CreatedBy, share.Created, share.Expires)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	share.ID = int(id)
+
+	return share, nil
+}
+
+// BySnippet returns the unexpired share links of a snippet, newest first.
+func (sm *ShareModel) BySnippet(snippetID int) ([]*Share, error) {
+
+	stmt := `SELECT id, snippet_id, created_by, created, expires FROM share_links
+    WHERE snippet_id = ? AND expires > ? ORDER BY id DESC`
+
+	rows, err := sm.DB.Query(stmt, snippetID, currentTime(sm.Clock))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*Share{}
+	for rows.Next() {
+		s := &Share{}
+		if err := rows.Scan(&s.ID, &s.SnippetID, &s.CreatedBy, &s.Created, &s.Expires); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	return shares, rows.Err()
+}
+
+// Delete revokes a share link of a snippet. It returns ErrNoRecord if the snippet has no such link.
+func (sm *ShareModel) Delete(snippetID, id int) error {
+
+	res, err := sm.DB.Exec(`DELETE FROM share_links WHERE id = ? AND snippet_id = ?`, id, snippetID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Token returns the token of a share link.
+func (sm *ShareModel) Token(share *Share) string {
+	return SignShareToken(sm.Key, share)
+}
+
+// Verify checks a token presented for a snippet and returns its share link. It returns
+// ErrNoRecord if the token is malformed, wasn't signed for the snippet, has expired or was
+// revoked.
+func (sm *ShareModel) Verify(snippetID int, token string) (*Share, error) {
+
+	id, expires, err := ParseShareToken(sm.Key, snippetID, token)
+	if err != nil {
+		return nil, ErrNoRecord
+	}
+	if !expires.After(clock.Now(sm.Clock)) {
+		return nil, ErrNoRecord
+	}
+
+	stmt := `SELECT id, snippet_id, created_by, created, expires FROM share_links
+    WHERE id = ? AND snippet_id = ? AND expires > ?`
+
+	s := &Share{}
+	err = sm.DB.QueryRow(stmt, id, snippetID, currentTime(sm.Clock)).Scan(&s.ID, &s.SnippetID, &s.CreatedBy, &s.Created, &s.Expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// shareTokenEncoding encodes share tokens so that they can be used in URLs as they are.
+var shareTokenEncoding = base64.RawURLEncoding
+
+// SignShareToken returns the token of a share link: its ID and expiry followed by an HMAC-SHA256
+// signature over them and the snippet ID, so that a token only works for the snippet it was made
+// for and its expiry can't be extended.
+func SignShareToken(key []byte, share *Share) string {
+	payload := make([]byte, 16)
+	binary.BigEndian.PutUint64(payload[:8], uint64(share.ID))
+	binary.BigEndian.PutUint64(payload[8:], uint64(share.Expires.Unix()))
+
+	return shareTokenEncoding.EncodeToString(append(payload, signShare(key, share.SnippetID, payload)...))
+}
+
+// ParseShareToken checks the signature of a token for a snippet and returns the ID and expiry of
+// its share link. It doesn't check whether the link has expired or was revoked.
+func ParseShareToken(key []byte, snippetID int, token string) (int, time.Time, error) {
+	data, err := shareTokenEncoding.DecodeString(token)
+	if err != nil || len(data) != 16+sha256.Size {
+		return 0, time.Time{}, errors.New("models: malformed share token")
+	}
+
+	payload, sig := data[:16], data[16:]
+	if !hmac.Equal(sig, signShare(key, snippetID, payload)) {
+		return 0, time.Time{}, errors.New("models: invalid share token signature")
+	}
+
+	id := int(binary.BigEndian.Uint64(payload[:8]))
+	expires := time.Unix(int64(binary.BigEndian.Uint64(payload[8:])), 0).UTC()
+
+	return id, expires, nil
+}
+
+// signShare computes the signature of a share token payload for a snippet.
+func signShare(key []byte, snippetID int, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("share:" + strconv.Itoa(snippetID) + ":"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ParseShareKey decodes a base64 share signing key. It returns nil if s is empty.
+func ParseShareKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("models: invalid share key: %w", err)
+	}
+	if len(key) < ShareKeySize {
+		return nil, fmt.Errorf("models: share key must be at least %d bytes, got %d", ShareKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+// NewShareKey returns a random share signing key.
+func NewShareKey() ([]byte, error) {
+	key := make([]byte, ShareKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestShareToken(t *testing.T) {
+
+	t.Parallel()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	token := SignShareToken(key, &Share{ID: 42, SnippetID: 7, Expires: expires})
+
+	id, got, err := ParseShareToken(key, 7, token)
+	assert.NilError(t, err)
+	assert.Equal(t, id, 42)
+	assert.Equal(t, got, expires)
+
+	tests := []struct {
+		name      string
+		key       []byte
+		snippetID int
+		token     string
+	}{
+		{"Other snippet", key, 8, token},
+		{"Other key", []byte("fedcba9876543210fedcba9876543210"), 7, token},
+		{"Extended expiry", key, 7, SignShareToken([]byte("wrong"), &Share{ID: 42, SnippetID: 7, Expires: expires.AddDate(1, 0, 0)})},
+		{"Truncated", key, 7, token[:20]},
+		{"Not base64", key, 7, "not a token!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseShareToken(tt.key, tt.snippetID, tt.token)
+			assert.Equal(t, err != nil, true)
+		})
+	}
+}
+
+func TestParseShareKey(t *testing.T) {
+
+	t.Parallel()
+
+	key, err := ParseShareKey("")
+	assert.NilError(t, err)
+	assert.Equal(t, key == nil, true)
+
+	key, err = ParseShareKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	assert.NilError(t, err)
+	assert.Equal(t, len(key), 32)
+
+	_, err = ParseShareKey("c2hvcnQ=")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestShareModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	now := clock.NewFrozen(time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	sm := &ShareModel{DB: db, Key: []byte("0123456789abcdef0123456789abcdef"), Clock: now}
+
+	share, err := sm.Insert(1, 1, now.Now().Add(time.Hour))
+	assert.NilError(t, err)
+
+	token := sm.Token(share)
+
+	got, err := sm.Verify(1, token)
+	assert.NilError(t, err)
+	assert.Equal(t, got.ID, share.ID)
+
+	_, err = sm.Verify(2, token)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	shares, err := sm.BySnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(shares), 1)
+
+	now.Advance(2 * time.Hour)
+
+	_, err = sm.Verify(1, token)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	share, err = sm.Insert(1, 1, now.Now().Add(time.Hour))
+	assert.NilError(t, err)
+	token = sm.Token(share)
+
+	assert.NilError(t, sm.Delete(1, share.ID))
+	assert.Equal(t, errors.Is(sm.Delete(1, share.ID), ErrNoRecord), true)
+
+	_, err = sm.Verify(1, token)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// ShortCodeLength is the length of generated short link codes. 62^6 codes leave collisions rare
+// enough that a few retries always find a free one.
+const ShortCodeLength = 6
+
+// shortCodeAlphabet holds the characters of short link codes.
+const shortCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// shortCodeAttempts is the number of codes tried before giving up on creating a short link.
+const shortCodeAttempts = 5
+
+// ShortLink maps a compact code to a snippet.
+type ShortLink struct {
+	Code      string    // Code is the part of the URL after /x/.
+	SnippetID int       // SnippetID is the ID of the snippet the link redirects to.
+	CreatedBy int       // CreatedBy is the ID of the user who created the link.
+	Created   time.Time // Created is the time the link was created.
+	Clicks    int       // Clicks is the number of times the link was followed.
+}
+
+// ShortLinkModel wraps a sql.DB connection pool and provides methods for the short_links table.
+type ShortLinkModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps new links. It defaults to the system clock.
+}
+
+type ShortLinkModelInterface interface {
+	Create(snippetID, userID int) (*ShortLink, bool, error)
+	Get(code string) (*ShortLink, error)
+	ForSnippet(snippetID int) (*ShortLink, error)
+	Click(code string) error
+}
+
+// Create returns the short link of a snippet, creating it with a new random code if the snippet
+// doesn't have one yet. The boolean reports whether the link was created. Codes that are already
+// taken are retried with a new code.
+func (sm *ShortLinkModel) Create(snippetID, userID int) (*ShortLink, bool, error) {
+
+	link, err := sm.ForSnippet(snippetID)
+	if err == nil {
+		return link, false, nil
+	}
+	if !errors.Is(err, ErrNoRecord) {
+		return nil, false, err
+	}
+
+	stmt := `INSERT INTO short_links (code, snippet_id, created_by, created) VALUES (?, ?, ?, ?)`
+
+	for attempt := 0; attempt < shortCodeAttempts; attempt++ {
+		code, err := NewShortCode()
+		if err != nil {
+			return nil, false, err
+		}
+
+		link := &ShortLink{Code: code, SnippetID: snippetID, CreatedBy: userID, Created: currentTime(sm.Clock)}
+
+		_, err = sm.DB.Exec(stmt, link.Code, link.SnippetID, link.CreatedBy, link.Created)
+		if err == nil {
+			return link, true, nil
+		}
+
+		var mySQLError *mysql.MySQLError
+		if !errors.As(err, &mySQLError) || mySQLError.Number != 1062 {
+			return nil, false, err
+		}
+
+		// Another request created the snippet's link first; use it.
+		if strings.Contains(mySQLError.Message, "short_links_uc_snippet") {
+			link, err := sm.ForSnippet(snippetID)
+			return link, false, err
+		}
+
+		// Otherwise the code was taken, so try another.
+	}
+
+	return nil, false, fmt.Errorf("models: no free short code after %d attempts", shortCodeAttempts)
+}
+
+// Get returns the short link with the given code.
+func (sm *ShortLinkModel) Get(code string) (*ShortLink, error) {
+	return sm.one(`SELECT code, snippet_id, created_by, created, clicks FROM short_links WHERE code = ?`, code)
+}
+
+// ForSnippet returns the short link of a snippet.
+func (sm *ShortLinkModel) ForSnippet(snippetID int) (*ShortLink, error) {
+	return sm.one(`SELECT code, snippet_id, created_by, created, clicks FROM short_links WHERE snippet_id = ?`, snippetID)
+}
+
+// Click counts a use of a short link.
+func (sm *ShortLinkModel) Click(code string) error {
+
+	_, err := sm.DB.Exec(`UPDATE short_links SET clicks = clicks + 1 WHERE code = ?`, code)
+
+	return err
+}
+
+// one returns the short link selected by a query, or ErrNoRecord if there's none.
+func (sm *ShortLinkModel) one(stmt string, args ...any) (*ShortLink, error) {
+
+	l := &ShortLink{}
+	err := sm.DB.QueryRow(stmt, args...).Scan(&l.Code, &l.SnippetID, &l.CreatedBy, &l.Created, &l.Clicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// NewShortCode returns a random short link code.
+func NewShortCode() (string, error) {
+	base := big.NewInt(int64(len(shortCodeAlphabet)))
+
+	code := make([]byte, ShortCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return "", err
+		}
+		code[i] = shortCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// IsShortCode reports whether s could be a short link code, so that handlers can reject other
+// paths without a query.
+func IsShortCode(s string) bool {
+	if s == "" || len(s) > 16 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !strings.ContainsRune(shortCodeAlphabet, rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
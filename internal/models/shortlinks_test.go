@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestShortCode(t *testing.T) {
+
+	t.Parallel()
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := NewShortCode()
+		assert.NilError(t, err)
+		assert.Equal(t, len(code), ShortCodeLength)
+		assert.Equal(t, IsShortCode(code), true)
+		seen[code] = true
+	}
+	assert.Equal(t, len(seen), 100)
+
+	assert.Equal(t, IsShortCode(""), false)
+	assert.Equal(t, IsShortCode("abc-12"), false)
+	assert.Equal(t, IsShortCode("abcdefghijklmnopq"), false)
+}
+
+func TestShortLinkModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm := &ShortLinkModel{DB: db}
+
+	link, created, err := sm.Create(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, created, true)
+
+	again, created, err := sm.Create(1, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, created, false)
+	assert.Equal(t, again.Code, link.Code)
+
+	// Codes are case-sensitive.
+	swapped := []byte(link.Code)
+	for i, c := range swapped {
+		switch {
+		case c >= 'a' && c <= 'z':
+			swapped[i] = c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z':
+			swapped[i] = c - 'A' + 'a'
+		}
+	}
+	if string(swapped) != link.Code {
+		_, err = sm.Get(string(swapped))
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	}
+
+	assert.NilError(t, sm.Click(link.Code))
+	assert.NilError(t, sm.Click(link.Code))
+
+	got, err := sm.Get(link.Code)
+	assert.NilError(t, err)
+	assert.Equal(t, got.SnippetID, 1)
+	assert.Equal(t, got.Clicks, 2)
+}
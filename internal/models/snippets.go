@@ -5,33 +5,214 @@ package models
 import (
 	"database/sql" // Package for interacting with SQL databases.
 	"errors"       // Package for creating error messages.
+	"fmt"          // Package for formatted I/O.
+	"strconv"      // Package for converting numeric types to strings.
+	"strings"      // Package for manipulating strings.
 	"time"         // Package for measuring and displaying time.
+	"unicode"      // Package for classifying characters.
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/oklog/ulid/v2"
+
+	"snippetbox.adcon.dev/internal/clock"
 )
 
+// Visibility is who sees a new snippet from the moment it's inserted, so that a snippet that must
+// be hidden is never visible in between. The zero value is a listed snippet.
+type Visibility struct {
+	Private  bool // Private keeps the snippet out of listings; its owner can share it with links.
+	Held     bool // Held hides the snippet until a moderator approves it.
+	Shadowed bool // Shadowed marks a held snippet as caught by a shadow rule.
+}
+
 // Snippet represents a snippet in the application. It is used to hold data related to a snippet.
 // A snippet consists of an ID, a title, content, and timestamps for when the snippet was created and when it expires.
 type Snippet struct {
 	ID      int       // ID is the unique identifier for the snippet.
+	ULID    string    // ULID is the public identifier used in links. It's empty for snippets created before ULIDs were introduced.
 	Title   string    // Title is the title of the snippet.
 	Content string    // Content is the content of the snippet.
 	Created time.Time // Created is the time when the snippet was created.
-	Expires time.Time // Expires is the time when the snippet expires.
+	Expires time.Time // Expires is the time when the snippet expires, or zero if it never does.
+
+	Updated   time.Time // Updated is the time when the snippet was last written.
+	UpdatedBy int       // UpdatedBy is the ID of the user who last wrote the snippet, or 0 if unknown.
+	OwnerID   int       // OwnerID is the ID of the user who created the snippet, or 0 if unknown.
+	Held      bool      // Held is true while the snippet is waiting for moderation and hidden from listings.
+	Shadowed  bool      // Shadowed is true if the snippet was held by a shadow rule, which its owner isn't told about.
+	Language  string    // Language is the programming language of the content, or empty if unknown.
+	Pinned    bool      // Pinned is true if an admin pinned the snippet to the top of the home page.
+	Private   bool      // Private snippets are unlisted and only visible to their owner, admins and share links.
+	OrgID     int       // OrgID is the ID of the organization owning the snippet together with its owner, or 0.
+	License   string    // License is the SPDX identifier of a well-known license, the name of another license, or empty.
+	Deleted   time.Time // Deleted is when the owner moved the snippet to the trash, or zero if it isn't there.
+	Views     int       // Views is the number of times the snippet was viewed, once per visitor in a while.
+	Slug      string    // Slug is the custom slug the author chose for the snippet's /s/ URL, or empty.
+
+	Stats ContentStats // Stats are the line count, size and reading time of the content.
+
+	// CreatorIP and CreatorUA hold the address and user agent of the client that created the snippet.
+	// They're only recorded when client capture is enabled, are scrubbed after the retention period,
+	// and are only loaded by the moderation queries.
+	CreatorIP string
+	CreatorUA string
 }
 
 // SnippetModel wraps a sql.DB connection pool and provides methods for interacting with the snippets table in the database.
 // It holds prepared SQL statements for inserting a snippet, getting a snippet, and getting the latest snippets.
 // This struct is useful for encapsulating the database operations related to snippets.
 type SnippetModel struct {
-	DB         *sql.DB   // DB is the database connection pool.
-	InsertStmt *sql.Stmt // InsertStmt is the prepared statement for inserting a snippet.
-	GetStmt    *sql.Stmt // GetStmt is the prepared statement for getting a snippet.
-	LatestStmt *sql.Stmt // LatestStmt is the prepared statement for getting the latest snippets.
+	DB            *sql.DB   // DB is the database connection pool.
+	InsertStmt    *sql.Stmt // InsertStmt is the prepared statement for inserting a snippet.
+	GetStmt       *sql.Stmt // GetStmt is the prepared statement for getting a snippet.
+	GetByULIDStmt *sql.Stmt // GetByULIDStmt is the prepared statement for getting a snippet by its ULID.
+	ListStmt      *sql.Stmt // ListStmt is the prepared statement for getting a page of the latest snippets.
+
+	// Content controls how snippet content is compressed before it's stored. The zero value
+	// stores content uncompressed.
+	Content ContentCodec
+
+	// Clock decides which snippets have expired and timestamps new ones. It defaults to the system
+	// clock.
+	Clock clock.Clock
 }
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(title string, content string, expires int, userID int) (int, error)
+	InsertWith(title string, content string, expires int, userID int, vis Visibility) (int, error)
 	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	GetByULID(id string) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	SlugTaken(slug string) (bool, error)
+	SetSlug(id int, slug string) error
+	List(page, pageSize int) ([]*Snippet, int, error)
+	RecordClient(id int, ip, userAgent string) error
+	Recent(limit int) ([]*Snippet, error)
+	ByOwner(ownerID int, limit int) ([]*Snippet, error)
+	SetHeld(id int, held bool) error
+	SetShadowed(id int, shadowed bool) error
+	SetPinned(id int, pinned bool) error
+	SetPrivate(id int, private bool) error
+	SetOrg(id int, orgID int) error
+	SetLanguage(id int, language string) error
+	SetLicense(id int, license string) error
+	Metadata(id int) ([]MetadataField, error)
+	SetMetadata(id int, fields []MetadataField) error
+	ByOrg(orgID int, limit int) ([]*Snippet, error)
+	Permission(id, userID int) (string, error)
+	Update(id int, title, content string, userID int, admin bool) error
+	Permissions(id int) ([]*PermissionRule, error)
+	SetPermission(actorID int, rule PermissionRule) error
+	Trending(limit int) ([]*Snippet, error)
+	IncrementViews(counts map[int]int) error
+	MostViewed(limit int) ([]*Snippet, error)
+	Search(q SearchQuery, afterID int, limit int) ([]*Snippet, error)
+	FullTextSearch(q SearchQuery, offset, limit int) ([]*Snippet, error)
+	Duplicate(content string) (*Snippet, error)
+	Delete(id, ownerID int) error
+	Trash(ownerID int) ([]*Snippet, error)
+	Undelete(id, ownerID int) error
+	Purge(id, ownerID int) error
+	Revisions(snippetID int) ([]*Revision, error)
+	Revision(snippetID, number int) (*Revision, error)
+}
+
+// LicenseURL returns the page with the text of the snippet's license, or an empty string if it has
+// none or its author named a license that isn't well-known.
+func (s *Snippet) LicenseURL() string {
+	if l, ok := KnownLicense(s.License); ok {
+		return l.URL()
+	}
+	return ""
+}
+
+// Permanent reports whether the snippet never expires.
+func (s *Snippet) Permanent() bool {
+	return s.Expires.IsZero()
+}
+
+// Edited reports whether the snippet has been written since it was created.
+func (s *Snippet) Edited() bool {
+	return s.Updated.After(s.Created)
+}
+
+// PublicID returns the identifier to use in links to the snippet: its ULID if it has one, and its
+// integer ID otherwise.
+func (s *Snippet) PublicID() string {
+	if s.ULID != "" {
+		return s.ULID
+	}
+	return strconv.Itoa(s.ID)
+}
+
+// The size of the summaries of snippets shown on listing pages.
+const (
+	summaryLines = 3   // summaryLines is the number of lines of content in a summary.
+	summaryChars = 200 // summaryChars is the number of characters a summary is cut to.
+)
+
+// summaryMore marks a summary that doesn't hold the whole content.
+const summaryMore = "... more"
+
+// Summary returns the start of the content, for listing pages: its first lines, skipping blank
+// ones at the start, cut to a couple of hundred characters and without control characters other
+// than tabs. A summary that doesn't hold the whole content ends with "... more". It's plain text,
+// to be escaped by the page.
+func (s *Snippet) Summary() string {
+	lines := strings.Split(strings.ReplaceAll(s.Content, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	more := len(lines) > summaryLines
+	if more {
+		lines = lines[:summaryLines]
+	}
+
+	summary := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, strings.Join(lines, "\n"))
+
+	if runes := []rune(summary); len(runes) > summaryChars {
+		summary = string(runes[:summaryChars])
+		more = true
+	}
+
+	summary = strings.TrimRightFunc(summary, unicode.IsSpace)
+	if more {
+		summary += " " + summaryMore
+	}
+
+	return summary
+}
+
+// IsULID reports whether id is a well-formed ULID, so that handlers can tell public identifiers
+// apart from integer IDs.
+func IsULID(id string) bool {
+	_, err := ulid.ParseStrict(id)
+	return err == nil
+}
+
+// currentTime returns the time of c, or of the system clock if c is nil, in UTC and truncated to
+// the second precision of DATETIME columns.
+func currentTime(c clock.Clock) time.Time {
+	return clock.Now(c).Truncate(time.Second)
+}
+
+// snippetColumns is the column list selected by every query that returns snippets. It must match
+// the order of the destinations in scanSnippet.
+const snippetColumns = `id, COALESCE(ulid, ''), title, content, created, expires, updated, COALESCE(updated_by, 0), COALESCE(owner_id, 0), held, language, pinned, private, COALESCE(org_id, 0), shadowed, license, views, COALESCE(slug, ''),
+    line_count, char_count, byte_size, reading_seconds`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
 // NewSnippetModel creates a new SnippetModel with a given database connection.
@@ -40,8 +221,9 @@ type SnippetModelInterface interface {
 // This function is useful for setting up the SnippetModel with the SQL statements it needs to interact with the database.
 func NewSnippetModel(db *sql.DB) (*SnippetModel, error) {
 	// Define the SQL for inserting a snippet.
-	insert := `INSERT INTO snippets (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	insert := `INSERT INTO snippets (ulid, title, content, created, expires, updated, updated_by, owner_id, private, held, shadowed,
+    content_hash, simhash, search_text, line_count, char_count, byte_size, reading_seconds)
+    VALUES(?, ?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	// Prepare the SQL statement.
 	// If there's an error (for example, if the SQL statement is invalid), return nil and the error.
@@ -51,8 +233,8 @@ func NewSnippetModel(db *sql.DB) (*SnippetModel, error) {
 	}
 
 	// Define the SQL for getting a snippet.
-	get := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	get := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND id = ?`
 
 	// Prepare the SQL statement.
 	// If there's an error (for example, if the SQL statement is invalid), return nil and the error.
@@ -61,26 +243,50 @@ func NewSnippetModel(db *sql.DB) (*SnippetModel, error) {
 		return nil, err
 	}
 
-	// Define the SQL for getting the latest snippets.
-	latest := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	// Define the SQL for getting a snippet by its ULID.
+	getByULID := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND ulid = ?`
 
 	// Prepare the SQL statement.
 	// If there's an error (for example, if the SQL statement is invalid), return nil and the error.
-	latestStmt, err := db.Prepare(latest)
+	getByULIDStmt, err := db.Prepare(getByULID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Define the SQL for getting a page of the latest snippets.
+	list := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private ORDER BY pinned DESC, id DESC LIMIT ? OFFSET ?`
+
+	// Prepare the SQL statement.
+	// If there's an error (for example, if the SQL statement is invalid), return nil and the error.
+	listStmt, err := db.Prepare(list)
 	if err != nil {
 		return nil, err
 	}
 
 	// Return a new SnippetModel with the database connection and the prepared statements.
-	return &SnippetModel{db, insertStmt, getStmt, latestStmt}, nil
+	return &SnippetModel{
+		DB:            db,
+		InsertStmt:    insertStmt,
+		GetStmt:       getStmt,
+		GetByULIDStmt: getByULIDStmt,
+		ListStmt:      listStmt,
+	}, nil
 }
 
 // Insert inserts a new snippet into the database. It starts a new transaction, executes the prepared statement for inserting a snippet,
 // commits the transaction, and retrieves the ID of the new snippet. If there's an error at any point (for example, if the transaction can't be started,
 // if the SQL statement is invalid, if the transaction can't be committed, or if the ID can't be retrieved), it returns 0 and the error.
 // If there's no error, it returns the ID of the new snippet and nil for the error.
-func (sm *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// The userID is recorded as the owner and last writer of the snippet; pass 0 if the writer isn't known.
+// Snippets kept for NeverExpires days never expire.
+func (sm *SnippetModel) Insert(title string, content string, expires int, userID int) (int, error) {
+	return sm.InsertWith(title, content, expires, userID, Visibility{})
+}
+
+// InsertWith inserts a new snippet like Insert, with the given visibility.
+func (sm *SnippetModel) InsertWith(title string, content string, expires int, userID int, vis Visibility) (int, error) {
 
 	// Start a new transaction.
 	// If there's an error (for example, if the transaction can't be started), return 0 and the error.
@@ -92,16 +298,20 @@ func (sm *SnippetModel) Insert(title string, content string, expires int) (int,
 	// Use the defer keyword to ensure that the transaction is rolled back if any subsequent code returns an error.
 	defer tx.Rollback()
 
-	// Execute the prepared statement for inserting a snippet.
-	// If there's an error (for example, if the SQL statement is invalid), return 0 and the error.
-	res, err := tx.Stmt(sm.InsertStmt).Exec(title, content, expires)
+	// Encode the content, compressing it if it's above the configured threshold.
+	encoded, err := sm.Content.Encode(content)
 	if err != nil {
 		return 0, err
 	}
 
-	// Commit the transaction.
-	// If there's an error (for example, if the transaction can't be committed), return 0 and the error.
-	if err := tx.Commit(); err != nil {
+	// Execute the prepared statement for inserting a snippet.
+	// If there's an error (for example, if the SQL statement is invalid), return 0 and the error.
+	now := currentTime(sm.Clock)
+	hash, simhash := fingerprints(content)
+	args := []any{ulid.Make().String(), title, encoded, now, expiry(now, expires), now, userID, userID, vis.Private, vis.Held, vis.Shadowed,
+		hash, simhash, sm.searchText(content)}
+	res, err := tx.Stmt(sm.InsertStmt).Exec(append(args, statsColumns(content)...)...)
+	if err != nil {
 		return 0, err
 	}
 
@@ -112,23 +322,101 @@ func (sm *SnippetModel) Insert(title string, content string, expires int) (int,
 		return 0, err
 	}
 
+	// Record the version the snippet was created with as its first revision.
+	if err := addRevision(tx, int(id), title, encoded, now, userID); err != nil {
+		return 0, err
+	}
+
+	// Commit the transaction.
+	// If there's an error (for example, if the transaction can't be committed), return 0 and the error.
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	// If there's no error, return the ID of the new snippet and nil for the error.
 	return int(id), nil
 }
 
+// expiry returns the expiry of a snippet kept for a number of days from now, which is NULL for
+// snippets that never expire.
+func expiry(now time.Time, days int) sql.NullTime {
+	if days == NeverExpires {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: now.AddDate(0, 0, days), Valid: true}
+}
+
 // Get retrieves a snippet from the database based on its ID. It executes the prepared statement for getting a snippet,
 // and scans the result into a new Snippet struct. If there's an error (for example, if the SQL statement is invalid),
 // it handles it accordingly: if the error is that no rows were returned from the query, it returns nil and the ErrNoRecord error;
 // if it's a different error, it returns nil and the error. If there's no error, it returns the Snippet struct and nil for the error.
 func (sm *SnippetModel) Get(id int) (*Snippet, error) {
 
+	// Execute the prepared statement for getting a snippet and scan the result into a Snippet struct.
+	return sm.scan(sm.GetStmt.QueryRow(currentTime(sm.Clock), id))
+}
+
+// GetByULID retrieves a snippet from the database based on its ULID. It behaves like Get, returning
+// the ErrNoRecord error if no unexpired snippet has the given ULID.
+func (sm *SnippetModel) GetByULID(id string) (*Snippet, error) {
+
+	// Execute the prepared statement for getting a snippet by ULID and scan the result into a Snippet struct.
+	return sm.scan(sm.GetByULIDStmt.QueryRow(currentTime(sm.Clock), id))
+}
+
+// GetBySlug retrieves a snippet by its custom slug. It behaves like Get, returning ErrNoRecord if no
+// live snippet has the slug.
+func (sm *SnippetModel) GetBySlug(slug string) (*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND slug = ?`
+
+	return sm.scan(sm.DB.QueryRow(stmt, currentTime(sm.Clock), slug))
+}
+
+// SlugTaken reports whether any snippet, live or not, already has the slug.
+func (sm *SnippetModel) SlugTaken(slug string) (bool, error) {
+
+	var taken bool
+	err := sm.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM snippets WHERE slug = ?)`, slug).Scan(&taken)
+
+	return taken, err
+}
+
+// SetSlug sets the custom slug of a snippet, or removes it if slug is empty. It returns
+// ErrDuplicateSlug if another snippet has the slug.
+func (sm *SnippetModel) SetSlug(id int, slug string) error {
+
+	_, err := sm.DB.Exec(`UPDATE snippets SET slug = NULLIF(?, '') WHERE id = ?`, slug, id)
+	if isDuplicateSlug(err) {
+		return ErrDuplicateSlug
+	}
+
+	return err
+}
+
+// isDuplicateSlug reports whether err is the violation of the uniqueness of snippet slugs.
+func isDuplicateSlug(err error) bool {
+	var mySQLError *mysql.MySQLError
+	return errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "snippets_uc_slug")
+}
+
+// scan reads a single snippet row into a new Snippet struct and decodes its content. If the row
+// doesn't exist, it returns nil and the ErrNoRecord error; any other error is returned as-is.
+// Columns selected after snippetColumns are scanned into the extra destinations.
+func (sm *SnippetModel) scan(row rowScanner, extra ...any) (*Snippet, error) {
+
 	// Create a new Snippet struct.
 	s := &Snippet{}
+	var content []byte
+	var expires sql.NullTime
+	var stats storedStats
 
-	// Execute the prepared statement for getting a snippet.
-	// Scan the result into the Snippet struct.
+	// Scan the row into the Snippet struct.
 	// If there's an error (for example, if the SQL statement is invalid), handle it in the next block.
-	err := sm.GetStmt.QueryRow(id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	dest := []any{&s.ID, &s.ULID, &s.Title, &content, &s.Created, &expires, &s.Updated, &s.UpdatedBy, &s.OwnerID, &s.Held, &s.Language, &s.Pinned, &s.Private, &s.OrgID, &s.Shadowed, &s.License, &s.Views, &s.Slug}
+	dest = append(dest, stats.dest()...)
+	err := row.Scan(append(dest, extra...)...)
 	// If there's an error...
 	if err != nil {
 		// If the error is that no rows were returned from the query, return nil and the ErrNoRecord error.
@@ -140,20 +428,40 @@ func (sm *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	s.Expires = expires.Time
+
+	// Decode the stored content, decompressing and decrypting it if necessary.
+	s.Content, err = sm.Content.Decode(content)
+	if err != nil {
+		return nil, err
+	}
+	s.Stats = stats.stats(s.Content)
+
 	// If there's no error, return the Snippet struct and nil for the error.
 	return s, nil
 }
 
-// Latest retrieves the 10 most recently created snippets that have not expired from the database. It executes the prepared statement for getting the latest snippets,
-// and scans the results into a slice of Snippet structs. If there's an error (for example, if the SQL statement is invalid),
-// it returns nil and the error. If there's no error, it returns the slice of Snippet structs and nil for the error.
-func (sm *SnippetModel) Latest() ([]*Snippet, error) {
+// List retrieves a page of pageSize listed snippets that have not expired from the database, pinned snippets first
+// and then the most recently created, along with the number of such snippets on all pages. Pages start at 1.
+// It executes the prepared statement for getting a page of the latest snippets, and scans the results into a slice
+// of Snippet structs. If there's an error (for example, if the SQL statement is invalid), it returns nil and the error.
+func (sm *SnippetModel) List(page, pageSize int) ([]*Snippet, int, error) {
+
+	now := currentTime(sm.Clock)
+
+	// Count the listed snippets, so that the pages can be numbered.
+	var total int
+	err := sm.DB.QueryRow(`SELECT COUNT(*) FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private`, now).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// Execute the prepared statement for getting the latest snippets.
+	// Execute the prepared statement for getting the page of snippets.
 	// If there's an error (for example, if the SQL statement is invalid), return nil and the error.
-	rows, err := sm.LatestStmt.Query()
+	rows, err := sm.ListStmt.Query(now, pageSize, (page-1)*pageSize)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	// Use the defer keyword to ensure that the rows are closed at the end, even if an error occurs.
 	defer rows.Close()
@@ -163,22 +471,389 @@ func (sm *SnippetModel) Latest() ([]*Snippet, error) {
 
 	// Loop over the rows.
 	for rows.Next() {
-		// For each row, create a new Snippet struct.
-		s := &Snippet{}
-		// Scan the row into the Snippet struct.
+		// For each row, scan it into a new Snippet struct.
 		// If there's an error (for example, if the row can't be scanned), return nil and the error.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := sm.scan(rows)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		// Append the Snippet struct to the slice.
 		snippets = append(snippets, s)
 	}
 	// If there's an error with the rows (for example, if there's a problem with the iteration), return nil and the error.
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// If there's no error, return the slice of Snippet structs, the total and nil for the error.
+	return snippets, total, nil
+}
+
+// Reencrypt rewrites the content of every snippet and revision that isn't encrypted with the active
+// key, for example after a key rotation or after encryption has been enabled on an existing
+// database. Rows are processed in batches of batchSize, and the number of rewritten rows is
+// returned.
+func (sm *SnippetModel) Reencrypt(batchSize int) (int, error) {
+
+	// Re-encryption only makes sense when a keyring is configured.
+	if sm.Content.Keys == nil {
+		return 0, errors.New("models: no encryption keys configured")
+	}
+	active := sm.Content.Keys.ActiveKeyID()
+
+	count, lastID := 0, 0
+
+	for {
+		// Fetch the next batch of rows.
+		rows, err := sm.DB.Query(`SELECT id, content FROM snippets WHERE id > ? ORDER BY id LIMIT ?`, lastID, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		// Collect the rows that need rewriting, decoding them with whichever key encrypted them.
+		updates := map[int][]byte{}
+		seen := 0
+		for rows.Next() {
+			var id int
+			var data []byte
+			if err := rows.Scan(&id, &data); err != nil {
+				rows.Close()
+				return count, err
+			}
+			seen++
+			lastID = id
+
+			if keyID, ok := envelopeKeyID(data); ok && keyID == active {
+				continue
+			}
+
+			content, err := sm.Content.Decode(data)
+			if err != nil {
+				rows.Close()
+				return count, fmt.Errorf("models: snippet %d: %w", id, err)
+			}
+
+			updates[id], err = sm.Content.Encode(content)
+			if err != nil {
+				rows.Close()
+				return count, err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, err
+		}
+
+		// Write the re-encrypted content back. Encrypted content isn't kept in plain text for
+		// full-text search, so that it's only searched by title from now on.
+		for id, data := range updates {
+			if _, err := sm.DB.Exec(`UPDATE snippets SET content = ?, search_text = NULL WHERE id = ?`, data, id); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		// Stop once a short batch signals the end of the table, and go on with the revisions.
+		if seen < batchSize {
+			n, err := sm.reencryptRevisions(batchSize)
+			return count + n, err
+		}
+	}
+}
+
+// SetHeld holds a snippet for moderation or releases it.
+func (sm *SnippetModel) SetHeld(id int, held bool) error {
+	return sm.setFlag("held", id, held)
+}
+
+// SetShadowed marks a held snippet as caught by a shadow rule, or clears the mark.
+func (sm *SnippetModel) SetShadowed(id int, shadowed bool) error {
+	return sm.setFlag("shadowed", id, shadowed)
+}
+
+// SetPinned pins a snippet to the top of the home page or unpins it.
+func (sm *SnippetModel) SetPinned(id int, pinned bool) error {
+	return sm.setFlag("pinned", id, pinned)
+}
+
+// SetPrivate makes a snippet private or lists it again.
+func (sm *SnippetModel) SetPrivate(id int, private bool) error {
+	return sm.setFlag("private", id, private)
+}
+
+// SetLanguage sets the programming language of a snippet, or clears it if language is empty.
+func (sm *SnippetModel) SetLanguage(id int, language string) error {
+
+	_, err := sm.DB.Exec(`UPDATE snippets SET language = ? WHERE id = ?`, language, id)
+
+	return err
+}
+
+// SetLicense sets the license of a snippet, or clears it if license is empty.
+func (sm *SnippetModel) SetLicense(id int, license string) error {
+
+	_, err := sm.DB.Exec(`UPDATE snippets SET license = ? WHERE id = ?`, license, id)
+
+	return err
+}
+
+// DetectLanguages sets the language of the snippets that have none to the one detect finds from
+// their title and content, in batches of batchSize, and returns the number of snippets updated.
+// Snippets detect can't place are left without a language.
+func (sm *SnippetModel) DetectLanguages(detect func(title, content string) string, batchSize int) (int, error) {
+
+	count, lastID := 0, 0
+
+	for {
+		rows, err := sm.DB.Query(`SELECT id, title, content FROM snippets WHERE language = '' AND id > ? ORDER BY id LIMIT ?`, lastID, batchSize)
+		if err != nil {
+			return count, err
+		}
+
+		languages := map[int]string{}
+		seen := 0
+		for rows.Next() {
+			var title string
+			var data []byte
+			if err := rows.Scan(&lastID, &title, &data); err != nil {
+				rows.Close()
+				return count, err
+			}
+			seen++
+
+			content, err := sm.Content.Decode(data)
+			if err != nil {
+				rows.Close()
+				return count, fmt.Errorf("models: snippet %d: %w", lastID, err)
+			}
+
+			if language := detect(title, content); language != "" {
+				languages[lastID] = language
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return count, err
+		}
+
+		for id, language := range languages {
+			if err := sm.SetLanguage(id, language); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if seen < batchSize {
+			return count, nil
+		}
+	}
+}
+
+// SetOrg puts a snippet in an organization, or takes it out of its organization if orgID is 0.
+// It returns ErrNoRecord if the snippet doesn't exist.
+func (sm *SnippetModel) SetOrg(id int, orgID int) error {
+
+	res, err := sm.DB.Exec(`UPDATE snippets SET org_id = NULLIF(?, 0) WHERE id = ?`, orgID, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sm.exists(id)
+	}
+
+	return nil
+}
+
+// setFlag sets a boolean column of a snippet. It returns ErrNoRecord if the snippet doesn't exist.
+func (sm *SnippetModel) setFlag(column string, id int, value bool) error {
+
+	res, err := sm.DB.Exec(`UPDATE snippets SET `+column+` = ? WHERE id = ?`, value, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// MySQL only counts rows that changed, so a snippet that already had the value isn't
+	// affected and its existence has to be checked separately.
+	if n == 0 {
+		return sm.exists(id)
+	}
+
+	return nil
+}
+
+// exists returns ErrNoRecord if there's no snippet with the given ID.
+func (sm *SnippetModel) exists(id int) error {
+
+	var exists bool
+	err := sm.DB.QueryRow(`SELECT EXISTS(SELECT true FROM snippets WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// ByOwner retrieves the most recently created unexpired snippets of a user that aren't held or
+// private.
+func (sm *SnippetModel) ByOwner(ownerID int, limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND owner_id = ? AND NOT held AND NOT private ORDER BY id DESC LIMIT ?`
+
+	return sm.query(stmt, currentTime(sm.Clock), ownerID, limit)
+}
+
+// ByOrg retrieves the most recently created unexpired snippets of an organization, including
+// private ones, which its members can see. Held snippets are left out.
+func (sm *SnippetModel) ByOrg(orgID int, limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND org_id = ? AND NOT held ORDER BY id DESC LIMIT ?`
+
+	return sm.query(stmt, currentTime(sm.Clock), orgID, limit)
+}
+
+// query runs a statement that selects snippetColumns and returns the scanned snippets.
+func (sm *SnippetModel) query(stmt string, args ...any) ([]*Snippet, error) {
+
+	rows, err := sm.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := sm.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// BackfillULIDs assigns a ULID to every snippet that was created before ULIDs were introduced and
+// returns the number of snippets that were updated.
+func (sm *SnippetModel) BackfillULIDs() (int, error) {
+
+	// Collect the IDs of the snippets without a ULID.
+	rows, err := sm.DB.Query(`SELECT id FROM snippets WHERE ulid IS NULL ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Assign each of them a fresh ULID.
+	for i, id := range ids {
+		if _, err := sm.DB.Exec(`UPDATE snippets SET ulid = ? WHERE id = ? AND ulid IS NULL`, ulid.Make().String(), id); err != nil {
+			return i, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// RecordClient stores the address and user agent of the client that created a snippet. The user
+// agent is truncated to fit the column, and bytes of it that aren't valid UTF-8 are replaced.
+func (sm *SnippetModel) RecordClient(id int, ip, userAgent string) error {
+
+	userAgent = truncateRunes(strings.ToValidUTF8(userAgent, "\uFFFD"), maxUserAgent)
+
+	_, err := sm.DB.Exec(`UPDATE snippets SET creator_ip = ?, creator_ua = ? WHERE id = ?`, ip, userAgent, id)
+
+	return err
+}
+
+// maxUserAgent is the number of characters of a user agent the creator_ua column holds.
+const maxUserAgent = 255
+
+// truncateRunes cuts s to at most n characters, without splitting one.
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// ScrubClientInfo removes the recorded client address and user agent from snippets created more than
+// retention ago, and returns the number of snippets that were scrubbed.
+func (sm *SnippetModel) ScrubClientInfo(retention time.Duration) (int64, error) {
+
+	stmt := `UPDATE snippets SET creator_ip = NULL, creator_ua = NULL
+    WHERE (creator_ip IS NOT NULL OR creator_ua IS NOT NULL) AND created < ?`
+
+	res, err := sm.DB.Exec(stmt, currentTime(sm.Clock).Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Recent retrieves the most recently created snippets for moderation, including expired ones and
+// the recorded client information. Content is decoded like in Get.
+func (sm *SnippetModel) Recent(limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + `, COALESCE(creator_ip, ''), COALESCE(creator_ua, '')
+    FROM snippets ORDER BY id DESC LIMIT ?`
+
+	rows, err := sm.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		var ip, userAgent string
+
+		s, err := sm.scan(rows, &ip, &userAgent)
+		if err != nil {
+			return nil, err
+		}
+		s.CreatorIP, s.CreatorUA = ip, userAgent
+
+		snippets = append(snippets, s)
+	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	// If there's no error, return the slice of Snippet structs and nil for the error.
 	return snippets, nil
 }
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestSnippetModelLatestPinned(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+	sm.Clock = clock.NewFrozen(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	first, err := sm.Insert("First", "a", 7, 0)
+	assert.NilError(t, err)
+	second, err := sm.Insert("Second", "b", 7, 0)
+	assert.NilError(t, err)
+
+	latest, total, err := sm.List(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, latest[0].ID, second)
+
+	// Later pages hold the older snippets.
+	latest, total, err = sm.List(2, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, total, 2)
+	assert.Equal(t, len(latest), 1)
+	assert.Equal(t, latest[0].ID, first)
+
+	// Pinning twice isn't an error, even though MySQL reports no changed rows the second time.
+	assert.NilError(t, sm.SetPinned(first, true))
+	assert.NilError(t, sm.SetPinned(first, true))
+
+	latest, _, err = sm.List(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].ID, first)
+	assert.Equal(t, latest[0].Pinned, true)
+
+	assert.Equal(t, sm.SetPinned(999, true), ErrNoRecord)
+}
+
+func TestSnippetModelNeverExpires(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	now := clock.NewFrozen(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+	sm.Clock = now
+
+	id, err := sm.Insert("Forever", "a", NeverExpires, 0)
+	assert.NilError(t, err)
+
+	now.Advance(100 * 365 * 24 * time.Hour)
+
+	s, err := sm.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Permanent(), true)
+
+	latest, _, err := sm.List(1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].ID, id)
+}
+
+func TestSnippetSummary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "Short",
+			content: "fmt.Println(\"hello\")\n",
+			want:    "fmt.Println(\"hello\")",
+		},
+		{
+			name:    "Lines",
+			content: "\n\n  package main\r\n\r\nfunc main() {\n\tprintln(1)\n}\n",
+			want:    "  package main\n\nfunc main() { ... more",
+		},
+		{
+			name:    "Long line",
+			content: strings.Repeat("é", 250),
+			want:    strings.Repeat("é", 200) + " ... more",
+		},
+		{
+			name:    "Control characters",
+			content: "a\x1b[31mred\x00\tb",
+			want:    "a[31mred\tb",
+		},
+		{
+			name:    "Empty",
+			content: "\n \n",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snippet{Content: tt.content}
+			assert.Equal(t, s.Summary(), tt.want)
+		})
+	}
+}
+
+func TestSnippetLicenseURL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, (&Snippet{License: "MIT"}).LicenseURL(), "https://spdx.org/licenses/MIT.html")
+	assert.Equal(t, (&Snippet{License: "Company internal use only"}).LicenseURL(), "")
+	assert.Equal(t, (&Snippet{}).LicenseURL(), "")
+}
+
+func TestTruncateRunes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, truncateRunes("curl/8.0", 255), "curl/8.0")
+	assert.Equal(t, truncateRunes("héllo", 2), "hé")
+	assert.Equal(t, truncateRunes(strings.Repeat("é", 300), maxUserAgent), strings.Repeat("é", maxUserAgent))
+}
+
+func TestSnippetModelMetadata(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	first, err := sm.Insert("First", "a", 7, 0)
+	assert.NilError(t, err)
+	second, err := sm.Insert("Second", "b", 7, 0)
+	assert.NilError(t, err)
+
+	assert.NilError(t, sm.SetMetadata(first, []MetadataField{{"shell", "bash"}, {"os", "linux"}}))
+	assert.NilError(t, sm.SetMetadata(second, []MetadataField{{"os", "darwin"}}))
+
+	// The pairs keep the order they were written in.
+	metadata, err := sm.Metadata(first)
+	assert.NilError(t, err)
+	assert.Equal(t, len(metadata), 2)
+	assert.Equal(t, metadata[0], MetadataField{"shell", "bash"})
+
+	results, err := sm.Search(SearchQuery{Metadata: []MetadataField{{"os", "linux"}}}, 0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, first)
+
+	if err := sm.SetMetadata(first, []MetadataField{{"os", "linux"}, {"os", "bsd"}}); err == nil {
+		t.Error("got no error for a name used twice")
+	}
+
+	assert.NilError(t, sm.SetMetadata(first, nil))
+	metadata, err = sm.Metadata(first)
+	assert.NilError(t, err)
+	assert.Equal(t, len(metadata), 0)
+}
+
+func TestSnippetModelSlug(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	first, err := sm.Insert("First", "a", 30, 0)
+	assert.NilError(t, err)
+	second, err := sm.Insert("Second", "b", 30, 0)
+	assert.NilError(t, err)
+
+	assert.NilError(t, sm.SetSlug(first, "frog-haiku"))
+
+	s, err := sm.GetBySlug("frog-haiku")
+	assert.NilError(t, err)
+	assert.Equal(t, s.ID, first)
+	assert.Equal(t, s.Slug, "frog-haiku")
+
+	taken, err := sm.SlugTaken("frog-haiku")
+	assert.NilError(t, err)
+	assert.Equal(t, taken, true)
+
+	err = sm.SetSlug(second, "frog-haiku")
+	assert.Equal(t, errors.Is(err, ErrDuplicateSlug), true)
+
+	// Removing the slug frees it.
+	assert.NilError(t, sm.SetSlug(first, ""))
+	_, err = sm.GetBySlug("frog-haiku")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	assert.NilError(t, sm.SetSlug(second, "frog-haiku"))
+}
+
+func TestSnippetModelInsertPrivate(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	id, err := sm.InsertWith("Secret", "a", 7, 1, Visibility{Private: true})
+	assert.NilError(t, err)
+
+	s, err := sm.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Private, true)
+
+	latest, _, err := sm.List(1, 10)
+	assert.NilError(t, err)
+	for _, l := range latest {
+		assert.Equal(t, l.ID == id, false)
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// readingWordsPerMinute is the reading speed reading times are estimated with. Code is read more
+// slowly than prose, so it's on the slow side.
+const readingWordsPerMinute = 200
+
+// ContentStats are statistics of the content of a snippet.
+type ContentStats struct {
+	Lines       int           // Lines is the number of lines, not counting a final line break.
+	Chars       int           // Chars is the number of characters.
+	Bytes       int           // Bytes is the size of the content in UTF-8.
+	ReadingTime time.Duration // ReadingTime is how long reading the content takes, in whole seconds.
+}
+
+// ComputeStats returns the statistics of content.
+func ComputeStats(content string) ContentStats {
+	lines := 0
+	if content != "" {
+		lines = strings.Count(content, "\n") + 1
+		if strings.HasSuffix(content, "\n") {
+			lines--
+		}
+	}
+
+	words := len(strings.Fields(content))
+	seconds := (words*60 + readingWordsPerMinute - 1) / readingWordsPerMinute
+
+	return ContentStats{
+		Lines:       lines,
+		Chars:       utf8.RuneCountInString(content),
+		Bytes:       len(content),
+		ReadingTime: time.Duration(seconds) * time.Second,
+	}
+}
+
+// ReadingMinutes returns the reading time in whole minutes, rounded up, or 0 for empty content.
+func (c ContentStats) ReadingMinutes() int {
+	return int((c.ReadingTime + time.Minute - 1) / time.Minute)
+}
+
+// statsColumns are the values of the line_count, char_count, byte_size and reading_seconds columns
+// for content.
+func statsColumns(content string) []any {
+	c := ComputeStats(content)
+	return []any{c.Lines, c.Chars, c.Bytes, int(c.ReadingTime / time.Second)}
+}
+
+// storedStats holds the statistics columns of a snippet row, which are NULL for snippets written
+// before they were stored.
+type storedStats struct {
+	lines, chars, bytes, seconds sql.NullInt64
+}
+
+// dest returns the scan destinations of the columns.
+func (st *storedStats) dest() []any {
+	return []any{&st.lines, &st.chars, &st.bytes, &st.seconds}
+}
+
+// stats returns the stored statistics, or computes them from content if they weren't stored.
+func (st *storedStats) stats(content string) ContentStats {
+	if !st.lines.Valid || !st.chars.Valid || !st.bytes.Valid || !st.seconds.Valid {
+		return ComputeStats(content)
+	}
+
+	return ContentStats{
+		Lines:       int(st.lines.Int64),
+		Chars:       int(st.chars.Int64),
+		Bytes:       int(st.bytes.Int64),
+		ReadingTime: time.Duration(st.seconds.Int64) * time.Second,
+	}
+}
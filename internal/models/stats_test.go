@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    ContentStats
+	}{
+		{
+			name:    "Empty",
+			content: "",
+			want:    ContentStats{},
+		},
+		{
+			name:    "One line",
+			content: "fmt.Println(\"héllo\")",
+			want:    ContentStats{Lines: 1, Chars: 20, Bytes: 21, ReadingTime: time.Second},
+		},
+		{
+			name:    "Final line break",
+			content: "a\nb\n",
+			want:    ContentStats{Lines: 2, Chars: 4, Bytes: 4, ReadingTime: time.Second},
+		},
+		{
+			name:    "Long",
+			content: strings.Repeat("word ", 300),
+			want:    ContentStats{Lines: 1, Chars: 1500, Bytes: 1500, ReadingTime: 90 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ComputeStats(tt.content), tt.want)
+		})
+	}
+
+	assert.Equal(t, ContentStats{ReadingTime: 90 * time.Second}.ReadingMinutes(), 2)
+	assert.Equal(t, ContentStats{}.ReadingMinutes(), 0)
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+	"snippetbox.adcon.dev/internal/mailer"
+)
+
+// SoftBounceLimit is the number of soft bounces after which an address is suppressed. Hard bounces
+// and complaints suppress it straight away.
+const SoftBounceLimit = 3
+
+// maxBounceDetail is the length the details of bounces are cut to.
+const maxBounceDetail = 500
+
+// Suppression is the delivery state of an address email bounced from.
+type Suppression struct {
+	Email       string    // Email is the address.
+	SoftBounces int       // SoftBounces is the number of soft bounces of the address.
+	LastKind    string    // LastKind is the kind of the latest bounce, such as mailer.BounceHard.
+	LastDetail  string    // LastDetail explains the latest bounce.
+	LastBounce  time.Time // LastBounce is when the address last bounced.
+	Suppressed  time.Time // Suppressed is when email to the address stopped, or the zero time if it's still sent.
+}
+
+// IsSuppressed reports whether email to the address has stopped.
+func (s *Suppression) IsSuppressed() bool {
+	return !s.Suppressed.IsZero()
+}
+
+// SuppressionModel wraps a sql.DB connection pool and provides methods for the email_suppressions
+// table. It implements mailer.SuppressionList.
+type SuppressionModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps bounces. It defaults to the system clock.
+}
+
+type SuppressionModelInterface interface {
+	Suppressed(email string) (bool, error)
+	RecordBounce(email string, bounce mailer.Bounce) error
+	Get(email string) (*Suppression, error)
+	Reactivate(email string) error
+}
+
+// Suppressed reports whether email to the address has stopped. Addresses are compared without
+// regard to case.
+func (sm *SuppressionModel) Suppressed(email string) (bool, error) {
+
+	var suppressed bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM email_suppressions WHERE email = ? AND suppressed IS NOT NULL)`
+
+	err := sm.DB.QueryRow(stmt, strings.ToLower(email)).Scan(&suppressed)
+
+	return suppressed, err
+}
+
+// RecordBounce records a bounce of the address, and suppresses it if it's a hard bounce or a
+// complaint, or the soft bounce that reaches SoftBounceLimit.
+func (sm *SuppressionModel) RecordBounce(email string, bounce mailer.Bounce) error {
+
+	now := currentTime(sm.Clock)
+
+	soft := 0
+	var suppressed sql.NullTime
+	if bounce.Kind == mailer.BounceSoft {
+		soft = 1
+	} else {
+		suppressed = sql.NullTime{Time: now, Valid: true}
+	}
+
+	detail := bounce.Detail
+	if len(detail) > maxBounceDetail {
+		detail = strings.ToValidUTF8(detail[:maxBounceDetail], "")
+	}
+
+	// The assignments run in order, so the check against the limit sees the updated count.
+	stmt := `INSERT INTO email_suppressions (email, soft_bounces, last_kind, last_detail, last_bounce, suppressed)
+    VALUES (?, ?, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE soft_bounces = soft_bounces + VALUES(soft_bounces), last_kind = VALUES(last_kind),
+        last_detail = VALUES(last_detail), last_bounce = VALUES(last_bounce),
+        suppressed = COALESCE(suppressed, VALUES(suppressed), IF(soft_bounces >= ?, VALUES(last_bounce), NULL))`
+
+	_, err := sm.DB.Exec(stmt, strings.ToLower(email), soft, bounce.Kind, detail, now, suppressed, SoftBounceLimit)
+
+	return err
+}
+
+// Get returns the delivery state of the address. It returns ErrNoRecord if the address never
+// bounced.
+func (sm *SuppressionModel) Get(email string) (*Suppression, error) {
+
+	stmt := `SELECT email, soft_bounces, last_kind, last_detail, last_bounce, suppressed
+    FROM email_suppressions WHERE email = ?`
+
+	s := &Suppression{}
+	var suppressed sql.NullTime
+
+	err := sm.DB.QueryRow(stmt, strings.ToLower(email)).Scan(&s.Email, &s.SoftBounces, &s.LastKind, &s.LastDetail, &s.LastBounce, &suppressed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	s.Suppressed = suppressed.Time
+
+	return s, nil
+}
+
+// Reactivate forgets the bounces of the address, so that email is sent to it again. It's what users
+// do once they've fixed their mailbox.
+func (sm *SuppressionModel) Reactivate(email string) error {
+
+	_, err := sm.DB.Exec(`DELETE FROM email_suppressions WHERE email = ?`, strings.ToLower(email))
+
+	return err
+}
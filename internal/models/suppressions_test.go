@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/mailer"
+)
+
+func TestSuppressionModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	sm := &SuppressionModel{DB: newTestDB(t)}
+
+	_, err := sm.Get("bob@example.com")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// Soft bounces suppress the address once they reach the limit.
+	for i := 1; i <= SoftBounceLimit; i++ {
+		suppressed, err := sm.Suppressed("bob@example.com")
+		assert.NilError(t, err)
+		assert.Equal(t, suppressed, false)
+
+		assert.NilError(t, sm.RecordBounce("Bob@example.com", mailer.Bounce{Kind: mailer.BounceSoft, Detail: "452 Mailbox full"}))
+	}
+
+	s, err := sm.Get("bob@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, s.SoftBounces, SoftBounceLimit)
+	assert.Equal(t, s.LastDetail, "452 Mailbox full")
+	assert.Equal(t, s.IsSuppressed(), true)
+
+	// A hard bounce suppresses the address straight away.
+	assert.NilError(t, sm.RecordBounce("carol@example.com", mailer.Bounce{Kind: mailer.BounceHard, Detail: "550 No such user"}))
+	suppressed, err := sm.Suppressed("carol@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, suppressed, true)
+
+	assert.NilError(t, sm.Reactivate("carol@example.com"))
+	suppressed, err = sm.Suppressed("carol@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, suppressed, false)
+}
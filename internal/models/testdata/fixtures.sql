@@ -0,0 +1,7 @@
+INSERT INTO users (name, username, email, hashed_password, created) VALUES (
+    'Alice Jones',
+    'alice',
+    'alice@example.com',
+    '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
+    '2022-01-01 10:00:00'
+);
@@ -1,3 +0,0 @@
-DROP TABLE users;
-
-DROP TABLE snippets;
\ No newline at end of file
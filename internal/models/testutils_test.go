@@ -2,61 +2,181 @@ package models
 
 import (
 	"database/sql"
+	"flag"
+	"fmt"
 	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"snippetbox.adcon.dev/internal/migrations"
+)
+
+// testDSNEnv names the environment variable holding the DSN of a MySQL server to run the
+// integration tests against. The user needs to be able to create and drop databases. When it isn't
+// set, the tests start a disposable MySQL container with Docker instead.
+const testDSNEnv = "SNIPPETBOX_TEST_DSN"
+
+// testMySQLImage is the image of the disposable container.
+const testMySQLImage = "mysql:8.0"
+
+var (
+	testServerOnce sync.Once
+	testServerDSN  *mysql.Config // testServerDSN points at the server, without a database name.
+	testServerErr  error
+	testServerStop func()
+
+	testDBCount atomic.Int64
 )
 
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	code := m.Run()
+
+	if testServerStop != nil {
+		testServerStop()
+	}
+
+	os.Exit(code)
+}
+
 func newTestUserModel(t *testing.T) (*UserModel, error) {
 
-	db, err := sql.Open("mysql", "test_web:pass@/test_snippetbox?parseTime=true&multiStatements=true")
+	return NewUserModel(newTestDB(t))
+}
+
+// newTestDB creates a fresh database for a single test, applies the migrations and the fixtures in
+// testdata/fixtures.sql, and drops the database again when the test ends. Each test gets its own
+// database, so integration tests can run in parallel. The test is skipped if no MySQL server is
+// available.
+func newTestDB(t *testing.T) *sql.DB {
+
+	testServerOnce.Do(startTestServer)
+	if testServerErr != nil {
+		t.Skipf("models: no MySQL server for integration tests: %s", testServerErr)
+	}
+
+	name := fmt.Sprintf("test_snippetbox_%d_%d", os.Getpid(), testDBCount.Add(1))
+
+	server, err := sql.Open("mysql", testServerDSN.FormatDSN())
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer server.Close()
 
-	script, err := os.ReadFile("./testdata/setup.sql")
+	_, err = server.Exec("CREATE DATABASE " + name + " CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci")
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = db.Exec(string(script))
+
+	cfg := testServerDSN.Clone()
+	cfg.DBName = name
+	cfg.ParseTime = true
+	cfg.MultiStatements = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	insert := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	t.Cleanup(func() {
+		db.Close()
+
+		server, err := sql.Open("mysql", testServerDSN.FormatDSN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+
+		if _, err := server.Exec("DROP DATABASE " + name); err != nil {
+			t.Fatal(err)
+		}
+	})
 
-	insertStmt, err := db.Prepare(insert)
+	if _, err := migrations.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := os.ReadFile("./testdata/fixtures.sql")
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// startTestServer finds the MySQL server for the integration tests, starting a container if no
+// DSN was given in the environment.
+func startTestServer() {
+	if dsn := os.Getenv(testDSNEnv); dsn != "" {
+		testServerDSN, testServerErr = mysql.ParseDSN(dsn)
+		if testServerErr == nil {
+			testServerDSN.DBName = ""
+		}
+		return
 	}
 
-	auth := `SELECT id, hashed_password FROM users WHERE email = ?`
+	if _, err := exec.LookPath("docker"); err != nil {
+		testServerErr = fmt.Errorf("%s isn't set and docker isn't available", testDSNEnv)
+		return
+	}
 
-	authStmt, err := db.Prepare(auth)
+	out, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--env", "MYSQL_ROOT_PASSWORD=pass",
+		"--publish", "127.0.0.1::3306",
+		testMySQLImage).Output()
 	if err != nil {
-		return nil, err
+		testServerErr = fmt.Errorf("starting container: %w", err)
+		return
 	}
+	id := strings.TrimSpace(string(out))
 
-	exists := `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`
+	testServerStop = func() {
+		exec.Command("docker", "stop", id).Run()
+	}
 
-	existsStmt, err := db.Prepare(exists)
+	out, err = exec.Command("docker", "port", id, "3306/tcp").Output()
 	if err != nil {
-		return nil, err
+		testServerErr = fmt.Errorf("finding container port: %w", err)
+		return
 	}
+	addr := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
 
-	t.Cleanup(func() {
+	cfg := mysql.NewConfig()
+	cfg.User = "root"
+	cfg.Passwd = "pass"
+	cfg.Net = "tcp"
+	cfg.Addr = addr
 
-		script, err := os.ReadFile("./testdata/teardown.sql")
-		if err != nil {
-			t.Fatal(err)
+	// MySQL takes a while to initialise a new data directory, so wait for it to accept connections.
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		testServerErr = err
+		return
+	}
+	defer db.Close()
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		err = db.Ping()
+		if err == nil {
+			break
 		}
-		_, err = db.Exec(string(script))
-		if err != nil {
-			t.Fatal(err)
+		if time.Now().After(deadline) {
+			testServerErr = fmt.Errorf("waiting for container: %w", err)
+			return
 		}
+		time.Sleep(time.Second)
+	}
 
-		db.Close()
-	})
-
-	return &UserModel{db, insertStmt, authStmt, existsStmt}, nil
+	testServerDSN = cfg
 }
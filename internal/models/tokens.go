@@ -0,0 +1,171 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// The scopes of API tokens.
+const (
+	ScopeRead  = "read"  // ScopeRead tokens can only read.
+	ScopeWrite = "write" // ScopeWrite tokens can also create and change snippets.
+)
+
+// TokenPrefix starts every API token, so that leaked tokens are easy to recognise and search for.
+const TokenPrefix = "sbx_"
+
+// APIToken is a token a user's scripts authenticate to the API with.
+type APIToken struct {
+	ID       int       // ID is the unique identifier of the token.
+	UserID   int       // UserID is the ID of the user the token acts as.
+	Name     string    // Name describes what the token is for, such as "deploy script".
+	Scope    string    // Scope is ScopeRead or ScopeWrite.
+	Created  time.Time // Created is when the token was created.
+	LastUsed time.Time // LastUsed is when the token last authenticated a request, or the zero time if it never did.
+}
+
+// CanWrite reports whether the token may make changes.
+func (t *APIToken) CanWrite() bool {
+	return t.Scope == ScopeWrite
+}
+
+// ValidScope reports whether scope is the scope of an API token.
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite
+}
+
+// TokenModel wraps a sql.DB connection pool and provides methods for the api_tokens table.
+type TokenModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps tokens and their use. It defaults to the system clock.
+}
+
+type TokenModelInterface interface {
+	Insert(userID int, name, scope string) (string, *APIToken, error)
+	Authenticate(token string) (*APIToken, error)
+	ByUser(userID int) ([]*APIToken, error)
+	Revoke(id, userID int) error
+}
+
+// Insert creates a token for a user and returns it, along with its record. The token itself isn't
+// stored, so this is the only time it can be shown.
+func (tm *TokenModel) Insert(userID int, name, scope string) (string, *APIToken, error) {
+
+	token, err := newAPIToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	t := &APIToken{UserID: userID, Name: name, Scope: scope, Created: currentTime(tm.Clock)}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scope, created) VALUES (?, ?, ?, ?, ?)`
+
+	res, err := tm.DB.Exec(stmt, userID, name, HashAPIToken(token), scope, t.Created)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", nil, err
+	}
+	t.ID = int(id)
+
+	return token, t, nil
+}
+
+// Authenticate returns the record of a token and records that it was used. It returns
+// ErrInvalidCredentials if the token doesn't exist or was revoked.
+func (tm *TokenModel) Authenticate(token string) (*APIToken, error) {
+
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	t := &APIToken{}
+	var lastUsed sql.NullTime
+
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens WHERE token_hash = ?`
+
+	err := tm.DB.QueryRow(stmt, HashAPIToken(token)).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.LastUsed = currentTime(tm.Clock)
+	if _, err := tm.DB.Exec(`UPDATE api_tokens SET last_used = ? WHERE id = ?`, t.LastUsed, t.ID); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ByUser returns the tokens of a user, newest first.
+func (tm *TokenModel) ByUser(userID int) ([]*APIToken, error) {
+
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := tm.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+	for rows.Next() {
+		t := &APIToken{}
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed); err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Revoke deletes a token of a user. It returns ErrNoRecord if the user has no token with the ID.
+func (tm *TokenModel) Revoke(id, userID int) error {
+
+	res, err := tm.DB.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// newAPIToken returns a random API token.
+func newAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashAPIToken returns the hash of a token as stored in the token_hash column.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
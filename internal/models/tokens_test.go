@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestTokenModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	frozen := clock.NewFrozen(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC))
+	tm := &TokenModel{DB: db, Clock: frozen}
+
+	token, record, err := tm.Insert(1, "deploy script", ScopeRead)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.HasPrefix(token, TokenPrefix), true)
+	assert.Equal(t, record.CanWrite(), false)
+
+	// Only the hash is stored.
+	var stored string
+	assert.NilError(t, db.QueryRow(`SELECT token_hash FROM api_tokens WHERE id = ?`, record.ID).Scan(&stored))
+	assert.Equal(t, stored, HashAPIToken(token))
+
+	frozen.Advance(time.Hour)
+	got, err := tm.Authenticate(token)
+	assert.NilError(t, err)
+	assert.Equal(t, got.UserID, 1)
+	assert.Equal(t, got.Scope, ScopeRead)
+
+	tokens, err := tm.ByUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].LastUsed.Equal(frozen.Now()), true)
+
+	_, err = tm.Authenticate(token + "x")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// Only the owner can revoke a token, and revoked tokens stop working.
+	assert.Equal(t, errors.Is(tm.Revoke(record.ID, 2), ErrNoRecord), true)
+	assert.NilError(t, tm.Revoke(record.ID, 1))
+	_, err = tm.Authenticate(token)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+}
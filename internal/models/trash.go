@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// Delete moves a snippet of a user to the trash, which hides it everywhere but on the trash page
+// of its owner. It returns ErrNoRecord if the user owns no snippet with the ID outside the trash.
+func (sm *SnippetModel) Delete(id, ownerID int) error {
+
+	return sm.trashExec(`UPDATE snippets SET deleted_at = ? WHERE id = ? AND owner_id = ? AND deleted_at IS NULL`,
+		currentTime(sm.Clock), id, ownerID)
+}
+
+// Trash retrieves the snippets a user moved to the trash, most recently deleted first, with the
+// time they were deleted.
+func (sm *SnippetModel) Trash(ownerID int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + `, deleted_at FROM snippets
+    WHERE owner_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+
+	rows, err := sm.DB.Query(stmt, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		var deleted time.Time
+
+		s, err := sm.scan(rows, &deleted)
+		if err != nil {
+			return nil, err
+		}
+		s.Deleted = deleted
+
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Undelete restores a snippet of a user from the trash. It returns ErrNoRecord if the user has no
+// snippet with the ID in the trash.
+func (sm *SnippetModel) Undelete(id, ownerID int) error {
+
+	return sm.trashExec(`UPDATE snippets SET deleted_at = NULL WHERE id = ? AND owner_id = ? AND deleted_at IS NOT NULL`,
+		id, ownerID)
+}
+
+// Purge deletes a snippet of a user in the trash for good. Its views, short links and other data
+// are removed by the next run of the purge job. It returns ErrNoRecord if the user has no snippet
+// with the ID in the trash.
+func (sm *SnippetModel) Purge(id, ownerID int) error {
+
+	return sm.trashExec(`DELETE FROM snippets WHERE id = ? AND owner_id = ? AND deleted_at IS NOT NULL`, id, ownerID)
+}
+
+// trashExec runs a statement that changes a single snippet, and returns ErrNoRecord if it changed
+// none.
+func (sm *SnippetModel) trashExec(stmt string, args ...any) error {
+
+	res, err := sm.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
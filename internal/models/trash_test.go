@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestSnippetTrash(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	now := clock.NewFrozen(time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+	sm.Clock = now
+
+	id, err := sm.Insert("Short-lived", "Gone tomorrow", 7, 1)
+	assert.NilError(t, err)
+
+	// Only the owner can delete the snippet, and only once.
+	assert.Equal(t, errors.Is(sm.Delete(id, 2), ErrNoRecord), true)
+	assert.NilError(t, sm.Delete(id, 1))
+	assert.Equal(t, errors.Is(sm.Delete(id, 1), ErrNoRecord), true)
+
+	_, err = sm.Get(id)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	trash, err := sm.Trash(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(trash), 1)
+	assert.Equal(t, trash[0].ID, id)
+	assert.Equal(t, trash[0].Deleted, now.Now())
+
+	trash, err = sm.Trash(2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(trash), 0)
+
+	assert.Equal(t, errors.Is(sm.Undelete(id, 2), ErrNoRecord), true)
+	assert.NilError(t, sm.Undelete(id, 1))
+
+	_, err = sm.Get(id)
+	assert.NilError(t, err)
+
+	// Snippets can only be purged from the trash.
+	assert.Equal(t, errors.Is(sm.Purge(id, 1), ErrNoRecord), true)
+	assert.NilError(t, sm.Delete(id, 1))
+	assert.NilError(t, sm.Purge(id, 1))
+
+	trash, err = sm.Trash(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(trash), 0)
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+)
+
+// TrendingSize is the number of snippets kept in the trending ranking.
+const TrendingSize = 100
+
+// RefreshTrending rebuilds the trending ranking from the views of the last number of days. Each
+// day's views count half as much for every halfLife that has passed since, so that a snippet
+// that's popular today ranks above one that was popular last week. Only snippets that are listed
+// on the home page are ranked. It returns the number of ranked snippets.
+func (vm *ViewModel) RefreshTrending(days int, halfLife time.Duration) (int, error) {
+
+	now := currentTime(vm.Clock)
+	today := now.Format(time.DateOnly)
+
+	tx, err := vm.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM snippet_trending`); err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO snippet_trending (snippet_id, score, computed)
+    SELECT v.snippet_id, SUM(v.views * POW(0.5, DATEDIFF(?, v.day) / ?)) AS score, ?
+    FROM snippet_views v JOIN snippets s ON s.id = v.snippet_id
+    WHERE v.day >= ? AND (s.expires IS NULL OR s.expires > ?) AND s.deleted_at IS NULL AND NOT s.held AND NOT s.private
+    GROUP BY v.snippet_id ORDER BY score DESC LIMIT ?`
+
+	res, err := tx.Exec(stmt, today, halfLife.Hours()/24, now, firstDay(now, days).Format(time.DateOnly), now, TrendingSize)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), tx.Commit()
+}
+
+// Trending retrieves the highest ranked snippets of the trending ranking that are still listed.
+func (sm *SnippetModel) Trending(limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets JOIN snippet_trending t ON t.snippet_id = id
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private ORDER BY t.score DESC, id DESC LIMIT ?`
+
+	return sm.query(stmt, currentTime(sm.Clock), limit)
+}
+
+// IncrementViews adds to the view counts of snippets, keyed by snippet ID, in a single transaction.
+func (sm *SnippetModel) IncrementViews(counts map[int]int) error {
+
+	tx, err := sm.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE snippets SET views = views + ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, n := range counts {
+		if _, err := stmt.Exec(n, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MostViewed retrieves the listed snippets that were viewed the most of all time.
+func (sm *SnippetModel) MostViewed(limit int) ([]*Snippet, error) {
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+    WHERE (expires IS NULL OR expires > ?) AND deleted_at IS NULL AND NOT held AND NOT private AND views > 0 ORDER BY views DESC, id DESC LIMIT ?`
+
+	return sm.query(stmt, currentTime(sm.Clock), limit)
+}
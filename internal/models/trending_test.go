@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+func TestRefreshTrending(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	now := clock.NewFrozen(time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+	sm.Clock = now
+
+	old, err := sm.Insert("Popular last week", "a", 30, 0)
+	assert.NilError(t, err)
+	fresh, err := sm.Insert("Popular today", "b", 30, 0)
+	assert.NilError(t, err)
+	held, err := sm.Insert("Held", "c", 30, 0)
+	assert.NilError(t, err)
+	assert.NilError(t, sm.SetHeld(held, true))
+
+	vm := &ViewModel{DB: db, Clock: now}
+	err = vm.Add(map[ViewKey]int{
+		{SnippetID: old, Day: time.Date(2030, 1, 5, 0, 0, 0, 0, time.UTC)}:    40,
+		{SnippetID: fresh, Day: time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC)}: 10,
+		{SnippetID: held, Day: time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC)}:  100,
+	})
+	assert.NilError(t, err)
+
+	// With a one-day half-life, 40 views five days ago are worth 1.25 views today.
+	n, err := vm.RefreshTrending(7, 24*time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	trending, err := sm.Trending(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(trending), 2)
+	assert.Equal(t, trending[0].ID, fresh)
+	assert.Equal(t, trending[1].ID, old)
+}
+
+func TestSnippetModelMostViewed(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	sm, err := NewSnippetModel(db)
+	assert.NilError(t, err)
+
+	less, err := sm.Insert("Viewed a little", "a", 30, 0)
+	assert.NilError(t, err)
+	more, err := sm.Insert("Viewed a lot", "b", 30, 0)
+	assert.NilError(t, err)
+	_, err = sm.Insert("Never viewed", "c", 30, 0)
+	assert.NilError(t, err)
+
+	assert.NilError(t, sm.IncrementViews(map[int]int{less: 2, more: 5}))
+	assert.NilError(t, sm.IncrementViews(map[int]int{less: 1}))
+
+	s, err := sm.Get(less)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Views, 3)
+
+	popular, err := sm.MostViewed(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(popular), 2)
+	assert.Equal(t, popular[0].ID, more)
+	assert.Equal(t, popular[1].ID, less)
+}
@@ -8,14 +8,18 @@ import (
 
 	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
+
+	"snippetbox.adcon.dev/internal/clock"
 )
 
 type User struct {
 	ID             int
 	Name           string
+	Username       string
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Admin          bool
 }
 
 type UserModel struct {
@@ -23,18 +27,28 @@ type UserModel struct {
 	InsertStmt *sql.Stmt
 	AuthStmt   *sql.Stmt
 	ExistsStmt *sql.Stmt
+	AdminStmt  *sql.Stmt
+
+	// Clock timestamps new users. It defaults to the system clock.
+	Clock clock.Clock
 }
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	Insert(name, username, email, password string) error
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
+	IsAdmin(id int) (bool, error)
+	PasswordUpdate(id int, currentPassword, newPassword string) error
+	Get(id int) (*User, error)
+	GetByUsername(username string) (*User, error)
+	ExistsByEmail(email string) (bool, error)
+	ExistsByUsername(username string) (bool, error)
 }
 
 func NewUserModel(db *sql.DB) (*UserModel, error) {
 
-	insert := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	insert := `INSERT INTO users (name, username, email, hashed_password, created)
+	VALUES(?, ?, ?, ?, ?)`
 
 	insertStmt, err := db.Prepare(insert)
 	if err != nil {
@@ -55,10 +69,23 @@ func NewUserModel(db *sql.DB) (*UserModel, error) {
 		return nil, err
 	}
 
-	return &UserModel{db, insertStmt, authStmt, existsStmt}, nil
+	admin := `SELECT EXISTS(SELECT true FROM users WHERE id = ? AND admin)`
+
+	adminStmt, err := db.Prepare(admin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserModel{
+		DB:         db,
+		InsertStmt: insertStmt,
+		AuthStmt:   authStmt,
+		ExistsStmt: existsStmt,
+		AdminStmt:  adminStmt,
+	}, nil
 }
 
-func (um *UserModel) Insert(name, email, password string) error {
+func (um *UserModel) Insert(name, username, email, password string) error {
 
 	// Start a new transaction.
 	// If there's an error (for example, if the transaction can't be started), return 0 and the error.
@@ -75,13 +102,16 @@ func (um *UserModel) Insert(name, email, password string) error {
 		return err
 	}
 
-	_, err = um.InsertStmt.Exec(name, email, hashedPassword)
+	_, err = um.InsertStmt.Exec(name, username, email, hashedPassword, currentTime(um.Clock))
 	if err != nil {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
 				return ErrDuplicateEmail
 			}
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_username") {
+				return ErrDuplicateUsername
+			}
 		}
 		return err
 	}
@@ -129,3 +159,131 @@ func (um *UserModel) Exists(id int) (bool, error) {
 
 	return exists, err
 }
+
+// IsAdmin reports whether the user with the given ID exists and has admin rights.
+func (um *UserModel) IsAdmin(id int) (bool, error) {
+
+	var admin bool
+
+	err := um.AdminStmt.QueryRow(id).Scan(&admin)
+
+	return admin, err
+}
+
+// SetAdmin grants or revokes admin rights for the user with the given email address. It returns
+// ErrNoRecord if there's no such user.
+func (um *UserModel) SetAdmin(email string, admin bool) error {
+
+	result, err := um.DB.Exec(`UPDATE users SET admin = ? WHERE email = ?`, admin, email)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// RowsAffected doesn't count rows whose value didn't change, so check that the user exists.
+	if rows == 0 {
+		exists, err := um.ExistsByEmail(email)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// PasswordUpdate replaces the password of a user after checking their current password. It returns
+// ErrInvalidCredentials if the current password is wrong.
+func (um *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+
+	var currentHashedPassword []byte
+
+	err := um.DB.QueryRow(`SELECT hashed_password FROM users WHERE id = ?`, id).Scan(&currentHashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	_, err = um.DB.Exec(`UPDATE users SET hashed_password = ? WHERE id = ?`, newHashedPassword, id)
+
+	return err
+}
+
+// Get retrieves the details of the user with the given ID, except their password. It returns
+// ErrNoRecord if there's no such user.
+func (um *UserModel) Get(id int) (*User, error) {
+
+	u := &User{}
+
+	stmt := `SELECT id, name, username, email, created, admin FROM users WHERE id = ?`
+
+	err := um.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Username, &u.Email, &u.Created, &u.Admin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// GetByUsername retrieves the public details of the user with the given username. It returns
+// ErrNoRecord if there's no such user.
+func (um *UserModel) GetByUsername(username string) (*User, error) {
+
+	u := &User{}
+
+	stmt := `SELECT id, name, username, created FROM users WHERE username = ?`
+
+	err := um.DB.QueryRow(stmt, username).Scan(&u.ID, &u.Name, &u.Username, &u.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ExistsByEmail reports whether an account is registered with the given email address.
+func (um *UserModel) ExistsByEmail(email string) (bool, error) {
+
+	var exists bool
+
+	err := um.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE email = ?)`, email).Scan(&exists)
+
+	return exists, err
+}
+
+// ExistsByUsername reports whether an account is registered with the given username.
+func (um *UserModel) ExistsByUsername(username string) (bool, error) {
+
+	var exists bool
+
+	err := um.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE username = ?)`, username).Scan(&exists)
+
+	return exists, err
+}
@@ -0,0 +1,136 @@
+// Package models contains the application's data models.
+package models
+
+// Import the necessary packages.
+import (
+	"database/sql" // Package for interacting with SQL databases.
+	"time"         // Package for measuring and displaying time.
+
+	"snippetbox.adcon.dev/internal/clock" // Import the clock package.
+)
+
+// DailyViews holds the number of views recorded on a single day.
+type DailyViews struct {
+	Day   time.Time // Day is the UTC date the views were recorded on.
+	Views int       // Views is the number of views on that day.
+}
+
+// ViewStats summarizes the views over a range of days, oldest day first.
+type ViewStats struct {
+	Days  []DailyViews // Days holds one entry for every day in the range, including days without views.
+	Total int          // Total is the sum of the views over the range.
+}
+
+// ViewKey identifies a per-day view counter.
+type ViewKey struct {
+	SnippetID int       // SnippetID is the ID of the viewed snippet.
+	Day       time.Time // Day is the UTC date of the views.
+}
+
+// ViewModel wraps a sql.DB connection pool and provides methods for interacting with the snippet_views
+// table, which holds the number of views of each snippet aggregated by day.
+type ViewModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock decides which day is today. It defaults to the system clock.
+}
+
+type ViewModelInterface interface {
+	Add(counts map[ViewKey]int) error
+	SnippetStats(snippetID int, days int) (*ViewStats, error)
+	SiteStats(days int) (*ViewStats, error)
+}
+
+// Add increments the per-day counters by the given amounts in a single transaction.
+func (vm *ViewModel) Add(counts map[ViewKey]int) error {
+
+	tx, err := vm.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO snippet_views (snippet_id, day, views) VALUES (?, ?, ?)
+    ON DUPLICATE KEY UPDATE views = views + VALUES(views)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for key, n := range counts {
+		if _, err := stmt.Exec(key.SnippetID, key.Day.Format(time.DateOnly), n); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SnippetStats returns the daily views of a snippet over the last number of days, including today.
+func (vm *ViewModel) SnippetStats(snippetID int, days int) (*ViewStats, error) {
+
+	stmt := `SELECT day, views FROM snippet_views
+    WHERE snippet_id = ? AND day >= ?`
+
+	today := clock.Now(vm.Clock)
+
+	return vm.stats(today, days, stmt, snippetID, firstDay(today, days).Format(time.DateOnly))
+}
+
+// SiteStats returns the daily views of all snippets over the last number of days, including today.
+func (vm *ViewModel) SiteStats(days int) (*ViewStats, error) {
+
+	stmt := `SELECT day, SUM(views) FROM snippet_views
+    WHERE day >= ? GROUP BY day`
+
+	today := clock.Now(vm.Clock)
+
+	return vm.stats(today, days, stmt, firstDay(today, days).Format(time.DateOnly))
+}
+
+// stats runs a query returning (day, views) rows and fills in the days without views.
+func (vm *ViewModel) stats(today time.Time, days int, query string, args ...any) (*ViewStats, error) {
+
+	rows, err := vm.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byDay := map[string]int{}
+	for rows.Next() {
+		var day time.Time
+		var views int
+		if err := rows.Scan(&day, &views); err != nil {
+			return nil, err
+		}
+		byDay[day.Format(time.DateOnly)] = views
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewViewStats(today, days, byDay), nil
+}
+
+// NewViewStats builds the stats for the number of days up to and including today from a map of
+// views keyed by date in time.DateOnly format.
+func NewViewStats(today time.Time, days int, byDay map[string]int) *ViewStats {
+
+	stats := &ViewStats{}
+	start := firstDay(today, days)
+
+	for i := 0; i < days; i++ {
+		day := start.AddDate(0, 0, i)
+		views := byDay[day.Format(time.DateOnly)]
+
+		stats.Days = append(stats.Days, DailyViews{Day: day, Views: views})
+		stats.Total += views
+	}
+
+	return stats
+}
+
+// firstDay returns the first day of a range of days ending with today.
+func firstDay(today time.Time, days int) time.Time {
+	return time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days)
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestNewViewStats(t *testing.T) {
+
+	t.Parallel()
+
+	today := time.Date(2024, 3, 2, 15, 4, 0, 0, time.UTC)
+
+	stats := NewViewStats(today, 3, map[string]int{
+		"2024-02-29": 4,
+		"2024-03-02": 1,
+		"2024-02-01": 100,
+	})
+
+	assert.Equal(t, len(stats.Days), 3)
+	assert.Equal(t, stats.Total, 5)
+
+	assert.Equal(t, stats.Days[0].Day.Format(time.DateOnly), "2024-02-29")
+	assert.Equal(t, stats.Days[0].Views, 4)
+	assert.Equal(t, stats.Days[1].Views, 0)
+	assert.Equal(t, stats.Days[2].Day.Format(time.DateOnly), "2024-03-02")
+	assert.Equal(t, stats.Days[2].Views, 1)
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"snippetbox.adcon.dev/internal/clock"
+)
+
+// The statuses of a webmention.
+const (
+	WebmentionPending  = "pending"  // WebmentionPending mentions haven't been verified yet.
+	WebmentionVerified = "verified" // WebmentionVerified mentions link to the snippet and are shown.
+	WebmentionRejected = "rejected" // WebmentionRejected mentions couldn't be verified.
+)
+
+// Webmention is a mention of a snippet by a page on another site.
+type Webmention struct {
+	ID        int       // ID is the unique identifier of the mention.
+	SnippetID int       // SnippetID is the ID of the snippet mentioned.
+	Source    string    // Source is the URL of the page that mentions the snippet.
+	Target    string    // Target is the URL of the snippet the source links to.
+	Status    string    // Status is one of WebmentionPending, WebmentionVerified and WebmentionRejected.
+	Title     string    // Title is the title of the source page, if it has one.
+	Received  time.Time // Received is when the mention was last sent.
+	Verified  time.Time // Verified is when the mention was last verified, or zero if it hasn't been.
+}
+
+// WebmentionModel wraps a sql.DB connection pool and provides methods for the webmentions table.
+type WebmentionModel struct {
+	DB    *sql.DB     // DB is the database connection pool.
+	Clock clock.Clock // Clock timestamps mentions. It defaults to the system clock.
+}
+
+type WebmentionModelInterface interface {
+	Receive(snippetID int, source, target string) (int, error)
+	Pending(limit int) ([]*Webmention, error)
+	SetStatus(id int, status, title string) error
+	BySnippet(snippetID int) ([]*Webmention, error)
+}
+
+// webmentionColumns is the column list selected by every query that returns mentions.
+const webmentionColumns = `id, snippet_id, source, target, status, title, received, verified`
+
+// Receive stores a mention as pending and returns its ID. A mention of the snippet by the same
+// source replaces the earlier one, since the source page may have changed.
+func (wm *WebmentionModel) Receive(snippetID int, source, target string) (int, error) {
+
+	stmt := `INSERT INTO webmentions (snippet_id, source, target, status, received) VALUES (?, ?, ?, ?, ?)
+    ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), target = VALUES(target), status = VALUES(status), received = VALUES(received)`
+
+	res, err := wm.DB.Exec(stmt, snippetID, source, target, WebmentionPending, currentTime(wm.Clock))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
+
+// Pending returns the oldest mentions waiting to be verified.
+func (wm *WebmentionModel) Pending(limit int) ([]*Webmention, error) {
+	return wm.query(`SELECT `+webmentionColumns+` FROM webmentions WHERE status = ? ORDER BY received, id LIMIT ?`,
+		WebmentionPending, limit)
+}
+
+// SetStatus records the outcome of verifying a mention, with the title of the source page. It
+// returns ErrNoRecord if there's no mention with the ID.
+func (wm *WebmentionModel) SetStatus(id int, status, title string) error {
+
+	res, err := wm.DB.Exec(`UPDATE webmentions SET status = ?, title = ?, verified = ? WHERE id = ?`,
+		status, title, currentTime(wm.Clock), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// BySnippet returns the verified mentions of a snippet, oldest first.
+func (wm *WebmentionModel) BySnippet(snippetID int) ([]*Webmention, error) {
+	return wm.query(`SELECT `+webmentionColumns+` FROM webmentions WHERE snippet_id = ? AND status = ? ORDER BY received, id`,
+		snippetID, WebmentionVerified)
+}
+
+// query runs a statement that selects webmentionColumns and returns the scanned mentions.
+func (wm *WebmentionModel) query(stmt string, args ...any) ([]*Webmention, error) {
+
+	rows, err := wm.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []*Webmention{}
+	for rows.Next() {
+		m := &Webmention{}
+		var verified sql.NullTime
+		if err := rows.Scan(&m.ID, &m.SnippetID, &m.Source, &m.Target, &m.Status, &m.Title, &m.Received, &verified); err != nil {
+			return nil, err
+		}
+		m.Verified = verified.Time
+		mentions = append(mentions, m)
+	}
+
+	return mentions, rows.Err()
+}
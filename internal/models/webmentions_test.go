@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestWebmentionModel(t *testing.T) {
+
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	wm := &WebmentionModel{DB: newTestDB(t)}
+
+	id, err := wm.Receive(1, "https://blog.example/post", "https://snippetbox.example/snippet/view/1")
+	assert.NilError(t, err)
+
+	_, err = wm.Receive(1, "https://other.example/page", "https://snippetbox.example/snippet/view/1")
+	assert.NilError(t, err)
+
+	pending, err := wm.Pending(10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 2)
+	assert.Equal(t, pending[0].ID, id)
+
+	assert.NilError(t, wm.SetStatus(id, WebmentionVerified, "A post"))
+	assert.NilError(t, wm.SetStatus(pending[1].ID, WebmentionRejected, ""))
+	assert.Equal(t, errors.Is(wm.SetStatus(999, WebmentionVerified, ""), ErrNoRecord), true)
+
+	mentions, err := wm.BySnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(mentions), 1)
+	assert.Equal(t, mentions[0].Title, "A post")
+	assert.Equal(t, mentions[0].Verified.IsZero(), false)
+
+	// Sending a mention again resets it to pending until it's verified again.
+	again, err := wm.Receive(1, "https://blog.example/post", "https://snippetbox.example/snippet/view/1")
+	assert.NilError(t, err)
+	assert.Equal(t, again, id)
+
+	mentions, err = wm.BySnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(mentions), 0)
+}
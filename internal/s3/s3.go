@@ -0,0 +1,280 @@
+// Package s3 is a small client for S3-compatible object storage, such as Amazon S3, Cloudflare R2,
+// Backblaze B2 or MinIO, with the few operations off-site backups need. Requests are signed with
+// AWS Signature Version 4 and address buckets by path, as in https://endpoint/bucket/key, which
+// every compatible service accepts.
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyHash is the SHA-256 hash of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Client talks to one bucket of an S3-compatible service.
+type Client struct {
+	Endpoint   string       // Endpoint is the base URL of the service, such as https://s3.eu-west-1.amazonaws.com.
+	Region     string       // Region is the region the bucket is in, such as eu-west-1. Services without regions take us-east-1 or auto.
+	Bucket     string       // Bucket is the name of the bucket.
+	AccessKey  string       // AccessKey is the ID of the access key.
+	SecretKey  string       // SecretKey is the secret of the access key.
+	HTTPClient *http.Client // HTTPClient sends the requests. It defaults to http.DefaultClient.
+}
+
+// Object is an object in a bucket.
+type Object struct {
+	Key          string    // Key is the name of the object.
+	Size         int64     // Size is the size of the object in bytes.
+	LastModified time.Time // LastModified is when the object was uploaded.
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int    // StatusCode is the HTTP status of the response.
+	Code       string // Code is the error code, such as NoSuchBucket or AccessDenied.
+	Message    string // Message describes the error.
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// Put uploads size bytes from body as the object key. hash is the hex-encoded SHA-256 hash of the
+// content, which the service checks it against.
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, size int64, hash string) error {
+	req, err := c.newRequest(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	resp, err := c.do(req, hash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// List returns the objects whose keys start with prefix, in the order of their keys.
+func (c *Client) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	var token string
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := c.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.do(req, emptyHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: reading object list: %w", err)
+		}
+
+		for _, o := range result.Contents {
+			objects = append(objects, Object{Key: o.Key, Size: o.Size, LastModified: o.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete deletes the object key. Deleting an object that doesn't exist isn't an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req, emptyHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// newRequest returns a request for the object key, or for the bucket if key is empty.
+func (c *Client) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", err)
+	}
+
+	// The path is escaped like the canonical request of the signature expects, which escapes more
+	// than net/url does.
+	path := "/" + c.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u.RawPath = u.EscapedPath() + escapePath(path)
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// do signs and sends a request whose payload has the given hash, and turns error responses into
+// an *Error.
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	c.sign(req, payloadHash, time.Now().UTC())
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+
+		e := &Error{StatusCode: resp.StatusCode}
+		// The errors of some proxies aren't XML; the status alone is reported then.
+		var body struct {
+			Code    string
+			Message string
+		}
+		if xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+			e.Code, e.Message = body.Code, body.Message
+		}
+
+		return nil, e
+	}
+
+	return resp, nil
+}
+
+// sign adds the headers of AWS Signature Version 4 to a request, with every header set so far
+// signed.
+func (c *Client) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// The canonical headers are the host and every header set on the request, lower-cased and
+	// sorted.
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + c.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), day)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery encodes query parameters sorted by name and value, with spaces as %20, as
+// Signature Version 4 requires.
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, escape(name)+"="+escape(value))
+		}
+	}
+	sort.Strings(params)
+
+	return strings.Join(params, "&")
+}
+
+// escapePath escapes each segment of a path like escape.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = escape(s)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// escape percent-encodes everything but unreserved characters.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// hashHex returns the hex-encoded SHA-256 hash of b.
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
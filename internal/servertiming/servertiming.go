@@ -0,0 +1,139 @@
+// Package servertiming measures where the time of a request goes, such as rendering templates and
+// running database statements, and formats the measurements as a Server-Timing header, which
+// browser developer tools show next to the request.
+//
+// The models and templates don't take a request context, so measurements are attributed to the
+// request handled by the goroutine that takes them: the handler tracks its Timings for the
+// duration of the request, and code further down adds to them with Add.
+//
+//	t := servertiming.New()
+//	defer servertiming.Track(t)()
+//	...
+//	servertiming.Add("db", d)
+//
+// Measurements taken by goroutines the handler starts aren't attributed to the request.
+package servertiming
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metric is the total time of one kind of work during a request.
+type Metric struct {
+	Name     string        // Name identifies the metric in the header, such as "db".
+	Duration time.Duration // Duration is the time spent on the work.
+	Count    int           // Count is the number of times the work was measured.
+}
+
+// Timings collects the metrics of a request. It's safe for concurrent use.
+type Timings struct {
+	mu      sync.Mutex
+	metrics []Metric
+}
+
+// New returns empty Timings.
+func New() *Timings {
+	return &Timings{}
+}
+
+// Add adds d to the metric name, creating it if it's the first measurement.
+func (t *Timings) Add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.metrics {
+		if t.metrics[i].Name == name {
+			t.metrics[i].Duration += d
+			t.metrics[i].Count++
+			return
+		}
+	}
+	t.metrics = append(t.metrics, Metric{Name: name, Duration: d, Count: 1})
+}
+
+// Metrics returns the metrics in the order they were first measured.
+func (t *Timings) Metrics() []Metric {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Metric(nil), t.metrics...)
+}
+
+// Header returns the metrics as the value of a Server-Timing header, with durations in
+// milliseconds, such as "db;dur=1.25;desc=\"3 calls\", render;dur=0.8".
+func (t *Timings) Header() string {
+	metrics := t.Metrics()
+	parts := make([]string, len(metrics))
+
+	for i, m := range metrics {
+		parts[i] = m.Name + ";dur=" + strconv.FormatFloat(float64(m.Duration)/float64(time.Millisecond), 'f', 2, 64)
+		if m.Count > 1 {
+			parts[i] += fmt.Sprintf(";desc=\"%d calls\"", m.Count)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// tracked holds the Timings of the goroutines handling requests, by goroutine ID, and active counts
+// them, so that looking up the calling goroutine is skipped while nothing is tracked.
+var (
+	tracked sync.Map
+	active  atomic.Int64
+)
+
+// Track attributes the measurements the calling goroutine adds to t, until the returned function
+// is called.
+func Track(t *Timings) (stop func()) {
+	id := goroutineID()
+	tracked.Store(id, t)
+	active.Add(1)
+
+	return func() {
+		tracked.Delete(id)
+		active.Add(-1)
+	}
+}
+
+// Add adds d to the metric name of the Timings the calling goroutine tracks. It does nothing if the
+// goroutine doesn't track any, such as for background jobs.
+func Add(name string, d time.Duration) {
+	if t := Current(); t != nil {
+		t.Add(name, d)
+	}
+}
+
+// Current returns the Timings the calling goroutine tracks, or nil.
+func Current() *Timings {
+	if active.Load() == 0 {
+		return nil
+	}
+
+	t, ok := tracked.Load(goroutineID())
+	if !ok {
+		return nil
+	}
+	return t.(*Timings)
+}
+
+// goroutineID returns the ID of the calling goroutine, which the runtime only tells in the header
+// of a stack trace: "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
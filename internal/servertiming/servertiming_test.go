@@ -0,0 +1,50 @@
+package servertiming
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestHeader(t *testing.T) {
+
+	t.Parallel()
+
+	timings := New()
+	timings.Add("db", time.Millisecond)
+	timings.Add("render", 2500*time.Microsecond)
+	timings.Add("db", 250*time.Microsecond)
+
+	assert.Equal(t, timings.Header(), `db;dur=1.25;desc="2 calls", render;dur=2.50`)
+	assert.Equal(t, New().Header(), "")
+}
+
+func TestTrack(t *testing.T) {
+
+	t.Parallel()
+
+	timings := New()
+	stop := Track(timings)
+
+	Add("db", time.Millisecond)
+	assert.Equal(t, Current(), timings)
+
+	// Other goroutines don't add to the Timings.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		Add("db", time.Second)
+	}()
+	wg.Wait()
+
+	stop()
+	Add("db", time.Second)
+
+	assert.Equal(t, Current() == nil, true)
+	metrics := timings.Metrics()
+	assert.Equal(t, len(metrics), 1)
+	assert.Equal(t, metrics[0], Metric{Name: "db", Duration: time.Millisecond, Count: 1})
+}
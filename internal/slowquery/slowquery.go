@@ -0,0 +1,306 @@
+// Package slowquery times the statements run through a database/sql driver and reports those that
+// take longer than a threshold, so that production slowdowns can be traced to the code and query
+// behind them.
+//
+// It wraps a driver.Connector, so it works for every query of a *sql.DB without changing the code
+// that runs them:
+//
+//	db := sql.OpenDB(slowquery.Connector(connector, time.Second, report))
+//
+// Observe wraps a connector the same way to pass the duration of every statement on, such as to
+// measure the database time of requests.
+//
+// A statement is timed until its result is available, so for queries the time spent reading the
+// rows isn't counted.
+package slowquery
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// maxSQL is the length statements are cut to in reports.
+const maxSQL = 500
+
+// Query describes a statement that took longer than the threshold.
+type Query struct {
+	Name     string        // Name is the function that ran the statement, such as "models.(*SnippetModel).Get".
+	SQL      string        // SQL is the statement, on one line.
+	Args     string        // Args summarizes the bound parameters, without the contents of strings and bytes.
+	Duration time.Duration // Duration is how long the statement took.
+}
+
+// Connector returns a connector for the connections of c that calls report for every statement
+// taking threshold or longer.
+func Connector(c driver.Connector, threshold time.Duration, report func(Query)) driver.Connector {
+	return &connector{Connector: c, timer: &timer{threshold: threshold, report: report}}
+}
+
+// Observe returns a connector for the connections of c that calls observe with the duration of
+// every statement.
+func Observe(c driver.Connector, observe func(time.Duration)) driver.Connector {
+	return &connector{Connector: c, timer: &timer{observe: observe}}
+}
+
+// timer reports the statements that take too long, and passes the duration of every statement to
+// observe.
+type timer struct {
+	threshold time.Duration
+	report    func(Query)
+	observe   func(time.Duration)
+}
+
+// observeStatement times a statement that started at start, and reports it if it took too long.
+// Statements the driver skipped are run again in another way, and timed then.
+func (t *timer) observeStatement(start time.Time, query string, args []driver.NamedValue, err error) {
+	d := time.Since(start)
+	if err == driver.ErrSkip {
+		return
+	}
+
+	if t.observe != nil {
+		t.observe(d)
+	}
+	if t.report == nil || d < t.threshold {
+		return
+	}
+
+	t.report(Query{
+		Name:     caller(),
+		SQL:      compact(query),
+		Args:     summarize(args),
+		Duration: d,
+	})
+}
+
+// connector wraps the connections of a driver.Connector.
+type connector struct {
+	driver.Connector
+	timer *timer
+}
+
+// Connect opens a connection with the wrapped connector.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: dc, timer: c.timer}, nil
+}
+
+// conn times the statements run on a driver connection. The optional interfaces of the driver are
+// passed through, and answered the way database/sql expects from drivers without them.
+type conn struct {
+	driver.Conn
+	timer *timer
+}
+
+// ExecContext runs a statement without preparing it, if the driver supports that.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.timer.observeStatement(start, query, args, err)
+
+	return result, err
+}
+
+// QueryContext runs a query without preparing it, if the driver supports that.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.timer.observeStatement(start, query, args, err)
+
+	return rows, err
+}
+
+// PrepareContext prepares a statement, which is timed when it's run.
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		s   driver.Stmt
+		err error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = preparer.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{Stmt: s, query: query, timer: c.timer}, nil
+}
+
+// Prepare prepares a statement without a context.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// BeginTx starts a transaction.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping checks the connection.
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession prepares the connection for reuse.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the connection can be reused.
+func (c *conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue converts a parameter the way the driver does.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// stmt times the runs of a prepared statement.
+type stmt struct {
+	driver.Stmt
+	query string
+	timer *timer
+}
+
+// ExecContext runs the statement.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+
+	var (
+		result driver.Result
+		err    error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = s.Stmt.Exec(values(args))
+	}
+	s.timer.observeStatement(start, s.query, args, err)
+
+	return result, err
+}
+
+// QueryContext runs the statement as a query.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	s.timer.observeStatement(start, s.query, args, err)
+
+	return rows, err
+}
+
+// CheckNamedValue converts a parameter the way the driver's statement does.
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// values returns the values of named parameters, for drivers that don't take names.
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vs[i] = arg.Value
+	}
+	return vs
+}
+
+// caller returns the name of the function outside database/sql and this package that ran the
+// statement. It's only looked up for slow statements, since walking the stack isn't free.
+func caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		if !strings.HasPrefix(fn, "database/sql.") && !strings.Contains(fn, "/internal/slowquery.") {
+			// Keep the package name but not its path.
+			return fn[strings.LastIndex(fn, "/")+1:]
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// compact puts a statement on one line and cuts it to maxSQL bytes.
+func compact(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxSQL {
+		query = strings.ToValidUTF8(query[:maxSQL], "") + "..."
+	}
+	return query
+}
+
+// summarize describes the bound parameters of a statement. Numbers, booleans and times are shown,
+// since they're what usually explains a slow query, but strings and bytes only by their length, so
+// that passwords, email addresses and snippet content don't end up in the log.
+func summarize(args []driver.NamedValue) string {
+	parts := make([]string, len(args))
+
+	for i, arg := range args {
+		switch v := arg.Value.(type) {
+		case nil:
+			parts[i] = "NULL"
+		case string:
+			parts[i] = fmt.Sprintf("string(%d)", len(v))
+		case []byte:
+			parts[i] = fmt.Sprintf("[]byte(%d)", len(v))
+		case time.Time:
+			parts[i] = v.UTC().Format(time.RFC3339)
+		case int64, uint64, float64, bool:
+			parts[i] = fmt.Sprint(v)
+		default:
+			parts[i] = fmt.Sprintf("%T", v)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
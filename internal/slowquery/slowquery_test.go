@@ -0,0 +1,131 @@
+package slowquery_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+	"snippetbox.adcon.dev/internal/slowquery"
+)
+
+// fakeConnector connects to a database that takes delay to run every statement. Its connections
+// only run prepared statements, like a driver without the optional interfaces.
+type fakeConnector struct {
+	delay time.Duration
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn fakeConnector
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt(c), nil }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type fakeStmt fakeConnector
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	time.Sleep(s.delay)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	time.Sleep(s.delay)
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestConnector(t *testing.T) {
+	var reported []slowquery.Query
+	report := func(q slowquery.Query) { reported = append(reported, q) }
+
+	t.Run("Slow", func(t *testing.T) {
+		reported = nil
+		db := sql.OpenDB(slowquery.Connector(fakeConnector{delay: 5 * time.Millisecond}, time.Millisecond, report))
+		defer db.Close()
+
+		_, err := db.Exec("UPDATE users\n\tSET name = ?, hashed_password = ?, created = ?\n\tWHERE id = ?",
+			"Alice", []byte("secret"), time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC), 42)
+		assert.NilError(t, err)
+
+		rows, err := db.Query("SELECT id FROM snippets WHERE expires > UTC_TIMESTAMP() AND private = ?", false)
+		assert.NilError(t, err)
+		rows.Close()
+
+		assert.Equal(t, len(reported), 2)
+
+		q := reported[0]
+		assert.Equal(t, q.Name, "slowquery_test.TestConnector.func2")
+		assert.Equal(t, q.SQL, "UPDATE users SET name = ?, hashed_password = ?, created = ? WHERE id = ?")
+		assert.Equal(t, q.Args, "string(5), []byte(6), 2024-03-17T10:15:00Z, 42")
+		assert.Equal(t, q.Duration >= 5*time.Millisecond, true)
+
+		assert.StringContains(t, reported[1].SQL, "SELECT id FROM snippets")
+		assert.Equal(t, reported[1].Args, "false")
+
+		// The contents of strings and bytes stay out of the report.
+		for _, q := range reported {
+			if strings.Contains(q.Args, "Alice") || strings.Contains(q.Args, "secret") {
+				t.Errorf("args %q show parameter values", q.Args)
+			}
+		}
+	})
+
+	t.Run("Fast", func(t *testing.T) {
+		reported = nil
+		db := sql.OpenDB(slowquery.Connector(fakeConnector{}, time.Hour, report))
+		defer db.Close()
+
+		_, err := db.Exec("DELETE FROM sessions WHERE expiry < ?", time.Now())
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(reported), 0)
+	})
+
+	t.Run("Long statement", func(t *testing.T) {
+		reported = nil
+		db := sql.OpenDB(slowquery.Connector(fakeConnector{}, 0, report))
+		defer db.Close()
+
+		_, err := db.Exec("SELECT " + strings.Repeat("id, ", 500) + "id FROM snippets")
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(reported), 1)
+		assert.Equal(t, len(reported[0].SQL), 503)
+		assert.Equal(t, strings.HasSuffix(reported[0].SQL, "..."), true)
+	})
+}
+
+func TestObserve(t *testing.T) {
+	var durations []time.Duration
+	observe := func(d time.Duration) { durations = append(durations, d) }
+
+	db := sql.OpenDB(slowquery.Observe(fakeConnector{delay: time.Millisecond}, observe))
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM sessions WHERE expiry < ?", time.Now())
+	assert.NilError(t, err)
+
+	rows, err := db.Query("SELECT id FROM snippets")
+	assert.NilError(t, err)
+	rows.Close()
+
+	assert.Equal(t, len(durations), 2)
+	for _, d := range durations {
+		assert.Equal(t, d >= time.Millisecond, true)
+	}
+}
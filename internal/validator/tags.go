@@ -0,0 +1,191 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rule is a single parsed validation rule from a `validate` struct tag.
+type rule struct {
+	name  string // name is the rule name, such as "required" or "maxrunes".
+	param string // param is the text after the "=", if any.
+}
+
+// field holds the parsed rules for one struct field.
+type field struct {
+	index []int  // index is the field's index sequence for reflect.Value.FieldByIndex.
+	key   string // key is the name used for the field's errors, taken from its `form` tag.
+	rules []rule // rules are the field's rules in declaration order.
+}
+
+// fieldCache maps struct types to their parsed fields, so that tags are only parsed once per type.
+var fieldCache sync.Map
+
+// CheckStruct validates the fields of a struct (or pointer to struct) against the rules declared in
+// their `validate` tags and records any failures with AddFieldError. Errors are keyed by the field's
+// `form` tag, falling back to the lowercased field name. Rules are checked in order and every
+// failing rule adds its message, except that a failing "required" rule skips the field's remaining
+// rules, since they would only repeat that the value is missing.
+//
+// The supported rules are:
+//
+//	required       the string must not be blank
+//	maxrunes=N     the string must have at most N runes
+//	minrunes=N     the string must have at least N runes
+//	maxbytes=N     the string must be at most N bytes long
+//	email          the string must be an email address
+//	url            the string must be an http or https URL
+//	slug           the string must be a slug
+//	oneof=a|b|c    the value must equal one of the listed values
+//	range=MIN|MAX  the integer must be between MIN and MAX, inclusive
+//
+// Malformed tags are programming errors and cause a panic.
+func (v *Validator) CheckStruct(s any) {
+	rv := reflect.Indirect(reflect.ValueOf(s))
+
+	for _, f := range fieldsOf(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+
+		for _, r := range f.rules {
+			ok, message := r.check(fv)
+			v.CheckField(ok, f.key, message)
+
+			if !ok && r.name == "required" {
+				break
+			}
+		}
+	}
+}
+
+// fieldsOf returns the parsed validation rules for the fields of a struct type.
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	fields := []field{}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if key == "" || key == "-" {
+			key = strings.ToLower(sf.Name)
+		}
+
+		f := field{index: sf.Index, key: key}
+
+		for _, part := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+			r := rule{name: name, param: param}
+			r.validate(sf)
+			f.rules = append(f.rules, r)
+		}
+
+		fields = append(fields, f)
+	}
+
+	fieldCache.Store(t, fields)
+
+	return fields
+}
+
+// validate panics if the rule can't be applied to the struct field.
+func (r rule) validate(sf reflect.StructField) {
+	kind := sf.Type.Kind()
+
+	switch r.name {
+	case "required", "email", "url", "slug":
+		if kind != reflect.String {
+			panic(fmt.Sprintf("validator: rule %q on field %s requires a string", r.name, sf.Name))
+		}
+	case "maxrunes", "minrunes", "maxbytes":
+		if kind != reflect.String {
+			panic(fmt.Sprintf("validator: rule %q on field %s requires a string", r.name, sf.Name))
+		}
+		if _, err := strconv.Atoi(r.param); err != nil {
+			panic(fmt.Sprintf("validator: rule %q on field %s has an invalid parameter %q", r.name, sf.Name, r.param))
+		}
+	case "oneof":
+		if r.param == "" {
+			panic(fmt.Sprintf("validator: rule %q on field %s needs at least one value", r.name, sf.Name))
+		}
+		if kind != reflect.String && !isInt(kind) {
+			panic(fmt.Sprintf("validator: rule %q on field %s requires a string or integer", r.name, sf.Name))
+		}
+	case "range":
+		min, max, ok := strings.Cut(r.param, "|")
+		_, errMin := strconv.Atoi(min)
+		_, errMax := strconv.Atoi(max)
+		if !ok || errMin != nil || errMax != nil || !isInt(kind) {
+			panic(fmt.Sprintf("validator: rule %q on field %s must be range=MIN|MAX on an integer", r.name, sf.Name))
+		}
+	default:
+		panic(fmt.Sprintf("validator: unknown rule %q on field %s", r.name, sf.Name))
+	}
+}
+
+// check applies the rule to a field value and returns whether it passed, along with the error
+// message to record if it didn't.
+func (r rule) check(fv reflect.Value) (bool, string) {
+	n, _ := strconv.Atoi(r.param)
+
+	switch r.name {
+	case "required":
+		return NotBlank(fv.String()), "This field cannot be blank"
+	case "maxrunes":
+		return MaxRunes(fv.String(), n), fmt.Sprintf("This field cannot be more than %d characters long", n)
+	case "minrunes":
+		return MinRunes(fv.String(), n), fmt.Sprintf("This field must be at least %d characters long", n)
+	case "maxbytes":
+		return MaxBytes(fv.String(), n), fmt.Sprintf("This field cannot be more than %d bytes long", n)
+	case "email":
+		return Matches(fv.String(), EmailRX), "This field must be a valid email address"
+	case "url":
+		return IsURL(fv.String()), "This field must be a valid URL"
+	case "slug":
+		return IsSlug(fv.String()), "This field may only contain lowercase letters, digits and hyphens"
+	case "oneof":
+		values := strings.Split(r.param, "|")
+		var value string
+		if fv.Kind() == reflect.String {
+			value = fv.String()
+		} else {
+			value = strconv.FormatInt(fv.Int(), 10)
+		}
+		return AllowedValue(value, values...), "This field must equal " + listValues(values)
+	case "range":
+		minText, maxText, _ := strings.Cut(r.param, "|")
+		min, _ := strconv.Atoi(minText)
+		max, _ := strconv.Atoi(maxText)
+		return InRange(fv.Int(), int64(min), int64(max)), fmt.Sprintf("This field must be between %d and %d", min, max)
+	}
+
+	return true, ""
+}
+
+// listValues formats values as "a, b or c".
+func listValues(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+// isInt reports whether kind is a signed integer kind.
+func isInt(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+type testForm struct {
+	Title     string `form:"title" validate:"required,maxrunes=5,slug"`
+	Email     string `form:"email" validate:"required,email"`
+	Expires   int    `form:"expires" validate:"oneof=1|7|365"`
+	Count     int    `validate:"range=1|10"`
+	Untouched string `form:"untouched"`
+	Validator `form:"-"`
+}
+
+func TestCheckStruct(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		form       testForm
+		wantErrors map[string][]string
+	}{
+		{
+			name:       "Valid",
+			form:       testForm{Title: "pond", Email: "bob@example.com", Expires: 7, Count: 3},
+			wantErrors: map[string][]string{},
+		},
+		{
+			name: "Blank fields stop at required",
+			form: testForm{Expires: 7, Count: 3},
+			wantErrors: map[string][]string{
+				"title": {"This field cannot be blank"},
+				"email": {"This field cannot be blank"},
+			},
+		},
+		{
+			name: "Rule failures",
+			form: testForm{Title: "pond", Email: "bob@example.", Expires: 2, Count: 11},
+			wantErrors: map[string][]string{
+				"email":   {"This field must be a valid email address"},
+				"expires": {"This field must equal 1, 7 or 365"},
+				"count":   {"This field must be between 1 and 10"},
+			},
+		},
+		{
+			name: "Every failing rule is kept",
+			form: testForm{Title: "Silent pond", Email: "bob@example.com", Expires: 7, Count: 3},
+			wantErrors: map[string][]string{
+				"title": {
+					"This field cannot be more than 5 characters long",
+					"This field may only contain lowercase letters, digits and hyphens",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := tt.form
+			form.CheckStruct(&form)
+
+			assert.Equal(t, len(form.FieldErrors), len(tt.wantErrors))
+			for key, want := range tt.wantErrors {
+				assert.Equal(t, len(form.FieldErrors[key]), len(want))
+				for i := range want {
+					assert.Equal(t, form.FieldErrors[key][i], want[i])
+				}
+				assert.Equal(t, form.FieldError(key), want[0])
+			}
+		})
+	}
+}
+
+func TestCheckStructInvalidTag(t *testing.T) {
+
+	t.Parallel()
+
+	defer func() {
+		assert.Equal(t, recover() != nil, true)
+	}()
+
+	var form struct {
+		Title string `validate:"maxrunes=many"`
+		Validator
+	}
+	form.CheckStruct(form)
+}
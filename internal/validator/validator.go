@@ -2,16 +2,21 @@
 package validator
 
 import (
+	"cmp"
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode/utf8"
 )
 
+// SlugRX matches lowercase, URL-safe identifiers made of letters, digits and single hyphens.
+var SlugRX = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 // Validator is a struct that holds field errors.
 type Validator struct {
-	FieldErrors    map[string]string // FieldErrors is a map of field names to error messages.
+	FieldErrors    map[string][]string // FieldErrors maps field names to their error messages, in the order they were added.
 	NonFieldErrors []string
 }
 
@@ -20,16 +25,31 @@ func (v *Validator) Valid() bool {
 	return len(v.FieldErrors) == 0 && len(v.NonFieldErrors) == 0
 }
 
-// AddFieldError adds an error message for a field to the validator.
+// AddFieldError adds an error message for a field to the validator. A field can collect several
+// messages; adding the same message twice has no effect.
 func (v *Validator) AddFieldError(key, message string) {
 
 	if v.FieldErrors == nil {
-		v.FieldErrors = make(map[string]string)
+		v.FieldErrors = make(map[string][]string)
 	}
 
-	if _, exists := v.FieldErrors[key]; !exists {
-		v.FieldErrors[key] = message
+	for _, existing := range v.FieldErrors[key] {
+		if existing == message {
+			return
+		}
 	}
+
+	v.FieldErrors[key] = append(v.FieldErrors[key], message)
+}
+
+// FieldError returns the first error message recorded for a field, or an empty string if the field
+// has no errors.
+func (v *Validator) FieldError(key string) string {
+	if messages := v.FieldErrors[key]; len(messages) > 0 {
+		return messages[0]
+	}
+
+	return ""
 }
 
 func (v *Validator) AddNonFieldError(message string) {
@@ -66,6 +86,46 @@ func MinRunes(value string, minCount int) bool {
 	return utf8.RuneCountInString(value) >= minCount
 }
 
+// Equal checks if two strings are identical, for example a password and its confirmation.
+func Equal(a, b string) bool {
+	return a == b
+}
+
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// IsURL checks if a string is an absolute http or https URL with a host.
+func IsURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// IsSlug checks if a string is a valid slug (see SlugRX).
+func IsSlug(value string) bool {
+	return SlugRX.MatchString(value)
+}
+
+// MaxBytes checks if a string is no more than a certain number of bytes long.
+func MaxBytes(value string, maxBytes int) bool {
+	return len(value) <= maxBytes
+}
+
+// InRange checks if a value is between min and max, inclusive.
+func InRange[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
+
+// OneOfString checks if a string equals one of the allowed values, ignoring case.
+func OneOfString(value string, allowedValues ...string) bool {
+	for _, allowedVal := range allowedValues {
+		if strings.EqualFold(value, allowedVal) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package validator
+
+import (
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestIsURL(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "HTTPS", value: "https://example.com/hook", want: true},
+		{name: "HTTP with port", value: "http://localhost:4000", want: true},
+		{name: "Missing scheme", value: "example.com", want: false},
+		{name: "Other scheme", value: "ftp://example.com", want: false},
+		{name: "Missing host", value: "https://", want: false},
+		{name: "Empty", value: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, IsURL(tt.value), tt.want)
+		})
+	}
+}
+
+func TestIsSlug(t *testing.T) {
+
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "Simple", value: "bob", want: true},
+		{name: "Hyphenated", value: "old-silent-pond-2", want: true},
+		{name: "Uppercase", value: "Bob", want: false},
+		{name: "Leading hyphen", value: "-bob", want: false},
+		{name: "Double hyphen", value: "old--pond", want: false},
+		{name: "Underscore", value: "old_pond", want: false},
+		{name: "Empty", value: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, IsSlug(tt.value), tt.want)
+		})
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+
+	t.Parallel()
+
+	assert.Equal(t, MaxBytes("pond", 4), true)
+	assert.Equal(t, MaxBytes("pond", 3), false)
+	// "ō" is one rune but two bytes.
+	assert.Equal(t, MaxBytes("Bashō", 5), false)
+	assert.Equal(t, MaxBytes("Bashō", 6), true)
+}
+
+func TestInRange(t *testing.T) {
+
+	t.Parallel()
+
+	assert.Equal(t, InRange(1, 1, 10), true)
+	assert.Equal(t, InRange(10, 1, 10), true)
+	assert.Equal(t, InRange(0, 1, 10), false)
+	assert.Equal(t, InRange(11, 1, 10), false)
+	assert.Equal(t, InRange(2.5, 1.0, 3.0), true)
+}
+
+func TestOneOfString(t *testing.T) {
+
+	t.Parallel()
+
+	assert.Equal(t, OneOfString("go", "go", "rust"), true)
+	assert.Equal(t, OneOfString("Go", "go", "rust"), true)
+	assert.Equal(t, OneOfString("python", "go", "rust"), false)
+	assert.Equal(t, OneOfString("go"), false)
+}
+
+func TestEqual(t *testing.T) {
+
+	t.Parallel()
+
+	assert.Equal(t, Equal("pa$$word", "pa$$word"), true)
+	assert.Equal(t, Equal("pa$$word", "pa$$wORD"), false)
+	assert.Equal(t, Equal("", ""), true)
+}
+
+func TestAddFieldError(t *testing.T) {
+
+	t.Parallel()
+
+	var v Validator
+	assert.Equal(t, v.FieldError("title"), "")
+
+	v.AddFieldError("title", "first")
+	v.AddFieldError("title", "second")
+	v.AddFieldError("title", "first")
+
+	assert.Equal(t, len(v.FieldErrors["title"]), 2)
+	assert.Equal(t, v.FieldErrors["title"][1], "second")
+	assert.Equal(t, v.FieldError("title"), "first")
+	assert.Equal(t, v.Valid(), false)
+}
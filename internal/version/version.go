@@ -0,0 +1,108 @@
+// Package version reports which build of the application is running. Release builds set the
+// version, commit and date with the linker:
+//
+//	go build -ldflags "-X snippetbox.adcon.dev/internal/version.version=v1.2.3 \
+//	    -X snippetbox.adcon.dev/internal/version.commit=$(git rev-parse HEAD) \
+//	    -X snippetbox.adcon.dev/internal/version.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/web
+//
+// Values that aren't set fall back to the build information the go command embeds, such as the
+// VCS revision of a build from a git checkout.
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags "-X"; see the package documentation.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// Info describes a build.
+type Info struct {
+	Version   string `json:"version"`              // Version is the release version, or "devel" for an unreleased build.
+	Commit    string `json:"commit,omitempty"`     // Commit is the VCS revision the build was made from.
+	Date      string `json:"date,omitempty"`       // Date is when the build was made, or the time of the commit.
+	Modified  bool   `json:"modified,omitempty"`   // Modified is set when the working tree had uncommitted changes.
+	GoVersion string `json:"go_version,omitempty"` // GoVersion is the version of Go the binary was built with.
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the information about the running build. It's computed once.
+func Get() Info {
+	once.Do(func() {
+		info = read(debug.ReadBuildInfo)
+	})
+	return info
+}
+
+// read combines the linker-provided values with the embedded build information.
+func read(readBuildInfo func() (*debug.BuildInfo, bool)) Info {
+	i := Info{Version: version, Commit: commit, Date: date}
+
+	if bi, ok := readBuildInfo(); ok {
+		i.GoVersion = bi.GoVersion
+
+		if i.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			i.Version = bi.Main.Version
+		}
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if i.Commit == "" {
+					i.Commit = s.Value
+				}
+			case "vcs.time":
+				if i.Date == "" {
+					i.Date = s.Value
+				}
+			case "vcs.modified":
+				i.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if i.Version == "" {
+		i.Version = "devel"
+	}
+
+	return i
+}
+
+// String returns a one-line summary such as "v1.2.3 (commit 0123abc, built 2024-04-12T10:00:00Z, go1.22.1)".
+func (i Info) String() string {
+	s := i.Version
+
+	details := ""
+	if i.Commit != "" {
+		c := i.Commit
+		if len(c) > 7 {
+			c = c[:7]
+		}
+		if i.Modified {
+			c += "+dirty"
+		}
+		details += ", commit " + c
+	}
+	if i.Date != "" {
+		details += ", built " + i.Date
+	}
+	if i.GoVersion != "" {
+		details += ", " + i.GoVersion
+	}
+
+	if details != "" {
+		s += fmt.Sprintf(" (%s)", details[2:])
+	}
+
+	return s
+}
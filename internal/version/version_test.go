@@ -0,0 +1,62 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestRead(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		info     *debug.BuildInfo
+		ldflags  [3]string
+		expected Info
+		str      string
+	}{
+		{
+			name:     "No build information",
+			expected: Info{Version: "devel"},
+			str:      "devel",
+		},
+		{
+			name: "VCS settings",
+			info: &debug.BuildInfo{
+				GoVersion: "go1.22.1",
+				Main:      debug.Module{Version: "(devel)"},
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "0123456789abcdef"},
+					{Key: "vcs.time", Value: "2024-04-12T10:00:00Z"},
+					{Key: "vcs.modified", Value: "true"},
+				},
+			},
+			expected: Info{Version: "devel", Commit: "0123456789abcdef", Date: "2024-04-12T10:00:00Z", Modified: true, GoVersion: "go1.22.1"},
+			str:      "devel (commit 0123456+dirty, built 2024-04-12T10:00:00Z, go1.22.1)",
+		},
+		{
+			name: "Linker flags win",
+			info: &debug.BuildInfo{
+				GoVersion: "go1.22.1",
+				Main:      debug.Module{Version: "v0.0.0-20240412100000-0123456789ab"},
+				Settings:  []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef"}},
+			},
+			ldflags:  [3]string{"v1.2.3", "fedcba9876543210", "2024-05-01T00:00:00Z"},
+			expected: Info{Version: "v1.2.3", Commit: "fedcba9876543210", Date: "2024-05-01T00:00:00Z", GoVersion: "go1.22.1"},
+			str:      "v1.2.3 (commit fedcba9, built 2024-05-01T00:00:00Z, go1.22.1)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, commit, date = tt.ldflags[0], tt.ldflags[1], tt.ldflags[2]
+			t.Cleanup(func() { version, commit, date = "", "", "" })
+
+			i := read(func() (*debug.BuildInfo, bool) { return tt.info, tt.info != nil })
+
+			assert.Equal(t, i, tt.expected)
+			assert.Equal(t, i.String(), tt.str)
+		})
+	}
+}
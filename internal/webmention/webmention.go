@@ -0,0 +1,273 @@
+// Package webmention implements both sides of Webmention (https://www.w3.org/TR/webmention/):
+// discovering the endpoint of a page and sending it a mention, and verifying that the source of a
+// mention received links to its target.
+//
+// Every request goes to URLs chosen by other people, so servers should make them with SafeClient,
+// which refuses to connect to loopback, private and other non-public addresses.
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxBody is the number of bytes of a page read to find its endpoint or its links.
+const maxBody = 1 << 20
+
+// maxTitle is the length titles of source pages are cut to.
+const maxTitle = 200
+
+var (
+	// ErrNoEndpoint is returned by Discover when the target doesn't accept Webmentions.
+	ErrNoEndpoint = errors.New("webmention: no endpoint")
+
+	// ErrNoLink is returned by Verify when the source doesn't link to the target.
+	ErrNoLink = errors.New("webmention: source doesn't link to target")
+
+	// ErrForbiddenAddress is returned when SafeClient is asked to connect to a non-public address.
+	ErrForbiddenAddress = errors.New("webmention: address not allowed")
+)
+
+var (
+	// tagRX matches the opening tags of links and anchors.
+	tagRX = regexp.MustCompile(`(?is)<(?:link|a)\b[^>]*>`)
+
+	// attrRX matches an attribute of a tag, with its value in one of the three quoting styles.
+	attrRX = regexp.MustCompile(`(?is)\b(rel|href)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+	// linkHeaderRX matches one link of a Link header.
+	linkHeaderRX = regexp.MustCompile(`<([^>]*)>\s*((?:;\s*[^;,]*)*)`)
+
+	// titleRX matches the title of an HTML page.
+	titleRX = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	// urlRX matches the absolute web URLs in text, up to the characters that usually end them in
+	// prose and Markdown.
+	urlRX = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+)
+
+// SafeClient returns an HTTP client that times out after timeout and only connects to public
+// addresses, so that Webmentions can't be used to reach the server's own network.
+func SafeClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !Public(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+		},
+	}
+}
+
+// Public reports whether addr is a public unicast address.
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// Discover returns the Webmention endpoint of target, from its Link header or from the first link
+// or anchor of the page with a rel of "webmention". It returns ErrNoEndpoint if there's none.
+func Discover(ctx context.Context, client *http.Client, target string) (string, error) {
+	resp, body, err := get(ctx, client, target)
+	if err != nil {
+		return "", err
+	}
+	base := resp.Request.URL
+
+	for _, header := range resp.Header.Values("Link") {
+		for _, m := range linkHeaderRX.FindAllStringSubmatch(header, -1) {
+			if hasRel(linkParam(m[2], "rel"), "webmention") {
+				return resolve(base, m[1])
+			}
+		}
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		for _, tag := range tagRX.FindAllString(string(body), -1) {
+			attrs := attributes(tag)
+			if href, ok := attrs["href"]; ok && hasRel(attrs["rel"], "webmention") {
+				return resolve(base, href)
+			}
+		}
+	}
+
+	return "", ErrNoEndpoint
+}
+
+// Send notifies endpoint that source mentions target.
+func Send(ctx context.Context, client *http.Client, endpoint, source, target string) error {
+	form := url.Values{"source": {source}, "target": {target}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webmention: endpoint answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Verify fetches source and checks that it links to target. It returns the title of the source
+// page, if it has one, and ErrNoLink if the page doesn't link to target.
+func Verify(ctx context.Context, client *http.Client, source, target string) (string, error) {
+	_, body, err := get(ctx, client, source)
+	if err != nil {
+		return "", err
+	}
+
+	if !linksTo(string(body), target) {
+		return "", ErrNoLink
+	}
+
+	var title string
+	if m := titleRX.FindSubmatch(body); m != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+		if len(title) > maxTitle {
+			title = strings.ToValidUTF8(title[:maxTitle], "")
+		}
+	}
+
+	return title, nil
+}
+
+// Links returns the absolute web URLs in text, such as the links of a Markdown document, in the
+// order they first appear.
+func Links(text string) []string {
+	var links []string
+	seen := map[string]bool{}
+
+	for _, link := range urlRX.FindAllString(text, -1) {
+		link = strings.TrimRight(link, ".,;:!?*_")
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// IsWebURL reports whether s is an absolute http or https URL with a host.
+func IsWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// get fetches a page and reads the start of its body. Responses other than 200 are errors.
+func get(ctx context.Context, client *http.Client, target string) (*http.Response, []byte, error) {
+	if !IsWebURL(target) {
+		return nil, nil, fmt.Errorf("webmention: %q is not a web URL", target)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("webmention: %s answered %s", target, resp.Status)
+	}
+
+	return resp, body, nil
+}
+
+// linksTo reports whether a page links to target: as the href of a link or anchor, or, for pages
+// that aren't HTML, anywhere in the text.
+func linksTo(body, target string) bool {
+	for _, tag := range tagRX.FindAllString(body, -1) {
+		if href, ok := attributes(tag)["href"]; ok && html.UnescapeString(href) == target {
+			return true
+		}
+	}
+
+	return !strings.Contains(strings.ToLower(body), "<html") && strings.Contains(body, target)
+}
+
+// attributes returns the rel and href attributes of a tag.
+func attributes(tag string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attrRX.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
+// linkParam returns the value of a parameter of a Link header link, such as rel in
+// `; rel="webmention"`.
+func linkParam(params, name string) string {
+	for _, param := range strings.Split(params, ";") {
+		key, value, ok := strings.Cut(param, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
+
+// hasRel reports whether a space-separated list of link relations holds rel.
+func hasRel(rels, rel string) bool {
+	for _, r := range strings.Fields(rels) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns ref resolved against the URL of the page it was found on. An empty ref is the
+// page itself.
+func resolve(base *url.URL, ref string) (string, error) {
+	u, err := base.Parse(html.UnescapeString(strings.TrimSpace(ref)))
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
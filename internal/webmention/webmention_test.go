@@ -0,0 +1,139 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adcon.dev/internal/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `<https://example.com/style.css>; rel="stylesheet"`)
+		w.Header().Add("Link", `</endpoint?via=header>; rel="other webmention"`)
+		fmt.Fprint(w, `<html><link rel="webmention" href="/ignored"></html>`)
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link href="endpoint?a=1&amp;b=2" rel='webmention'></head></html>`)
+	})
+	mux.HandleFunc("/anchor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p><a rel=webmention href="https://mentions.example/in">Mentions</a></p>`)
+	})
+	mux.HandleFunc("/none", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p><a href="/webmention">Not an endpoint</a></p>`)
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr error
+	}{
+		{path: "/header", want: ts.URL + "/endpoint?via=header"},
+		{path: "/link", want: ts.URL + "/endpoint?a=1&b=2"},
+		{path: "/anchor", want: "https://mentions.example/in"},
+		{path: "/none", wantErr: ErrNoEndpoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			endpoint, err := Discover(context.Background(), ts.Client(), ts.URL+tt.path)
+
+			assert.Equal(t, endpoint, tt.want)
+			assert.Equal(t, errors.Is(err, tt.wantErr), true)
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	var got url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	err := Send(context.Background(), ts.Client(), ts.URL, "https://a.example/post", "https://b.example/page")
+	assert.NilError(t, err)
+	assert.Equal(t, got.Get("source"), "https://a.example/post")
+	assert.Equal(t, got.Get("target"), "https://b.example/page")
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	target := "https://snippetbox.example/~alice/01HV6Z9K1QX8M3N5P7R9T2V4W6"
+
+	pages := map[string]string{
+		"/linked":    `<html><title> A &amp; B </title><a href="` + target + `">snippet</a></html>`,
+		"/mentioned": `<html><title>Mentioned</title><p>` + target + `</p></html>`,
+		"/text":      `See ` + target + ` for details.`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer ts.Close()
+
+	title, err := Verify(context.Background(), ts.Client(), ts.URL+"/linked", target)
+	assert.NilError(t, err)
+	assert.Equal(t, title, "A & B")
+
+	_, err = Verify(context.Background(), ts.Client(), ts.URL+"/mentioned", target)
+	assert.Equal(t, errors.Is(err, ErrNoLink), true)
+
+	_, err = Verify(context.Background(), ts.Client(), ts.URL+"/text", target)
+	assert.NilError(t, err)
+
+	_, err = Verify(context.Background(), ts.Client(), ts.URL+"/deleted", target)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestLinks(t *testing.T) {
+	t.Parallel()
+
+	text := "# Links\n\nSee [the spec](https://www.w3.org/TR/webmention/) and https://example.com/a.\n" +
+		"Again: <https://example.com/a>, and http://example.org/b?c=d#e!\n"
+
+	assert.Equal(t, strings.Join(Links(text), " "), "https://www.w3.org/TR/webmention/ https://example.com/a http://example.org/b?c=d#e")
+}
+
+func TestSafeClient(t *testing.T) {
+	t.Parallel()
+
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "192.168.0.1", "169.254.169.254", "::1", "fd00::1", "0.0.0.0", "::ffff:127.0.0.1"} {
+		assert.Equal(t, Public(netip.MustParseAddr(addr)), false)
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:2800:220:1::1"} {
+		assert.Equal(t, Public(netip.MustParseAddr(addr)), true)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	_, err := SafeClient(time.Second).Get(ts.URL)
+	assert.Equal(t, errors.Is(err, ErrForbiddenAddress), true)
+}
@@ -0,0 +1,3 @@
+-- Add columns for the address and user agent of the client that created a snippet.
+-- They're only filled in when the server runs with -capture-client-info.
+ALTER TABLE snippets ADD COLUMN creator_ip VARCHAR(45) NULL, ADD COLUMN creator_ua VARCHAR(255) NULL;
@@ -0,0 +1,2 @@
+-- Add the moderation hold flag to an existing `snippets` table.
+ALTER TABLE snippets ADD COLUMN held BOOLEAN NOT NULL DEFAULT FALSE AFTER owner_id;
@@ -0,0 +1,2 @@
+-- Add the programming language to an existing `snippets` table.
+ALTER TABLE snippets ADD COLUMN language VARCHAR(32) NOT NULL DEFAULT '' AFTER held;
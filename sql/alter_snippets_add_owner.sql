@@ -0,0 +1,7 @@
+-- Add the owner of each snippet to an existing `snippets` table.
+-- Snippets created before owners were recorded are attributed to their last writer, if known.
+ALTER TABLE snippets ADD COLUMN owner_id INTEGER NULL AFTER updated_by;
+
+UPDATE snippets SET owner_id = updated_by;
+
+CREATE INDEX idx_snippets_owner ON snippets(owner_id);
@@ -0,0 +1,5 @@
+-- Add the public ULID identifier to an existing `snippets` table.
+-- Existing rows are left without a ULID; run `snippetboxctl backfill-ulids` to assign them.
+ALTER TABLE snippets ADD COLUMN ulid CHAR(26) NULL AFTER id;
+
+CREATE UNIQUE INDEX idx_snippets_ulid ON snippets(ulid);
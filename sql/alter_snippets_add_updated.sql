@@ -0,0 +1,7 @@
+-- Add the `updated` and `updated_by` audit columns to an existing `snippets` table.
+ALTER TABLE snippets ADD COLUMN updated DATETIME NULL, ADD COLUMN updated_by INTEGER NULL;
+
+-- Existing snippets were last written when they were created.
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets MODIFY updated DATETIME NOT NULL;
@@ -0,0 +1,3 @@
+-- Store snippet content as binary so that large pastes can be compressed.
+-- Existing rows keep their plain-text content and are read back unchanged.
+ALTER TABLE snippets MODIFY content MEDIUMBLOB NOT NULL;
@@ -0,0 +1,5 @@
+-- Add the admin flag to an existing `users` table.
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Grant admin rights to an existing account, for example:
+-- UPDATE users SET admin = TRUE WHERE email = 'alice@example.com';
@@ -0,0 +1,5 @@
+-- Add unique usernames to an existing `users` table.
+-- Users created before usernames were introduced have none until one is assigned.
+ALTER TABLE users ADD COLUMN username VARCHAR(30) NULL AFTER name;
+
+ALTER TABLE users ADD CONSTRAINT users_uc_username UNIQUE (username);
@@ -1,7 +1,8 @@
-CREATE DATABASE test_snippetbox CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
-
+-- The integration tests create a database per test named test_snippetbox_*, so the test user
+-- needs privileges on all of them. Run the tests with
+-- SNIPPETBOX_TEST_DSN='test_web:pass@/' go test ./...
 CREATE USER 'test_web'@'localhost';
 
-GRANT CREATE, DROP, ALTER, INDEX, SELECT, INSERT, UPDATE, DELETE ON test_snippetbox.* TO 'test_web'@'localhost';
+GRANT CREATE, DROP, ALTER, INDEX, SELECT, INSERT, UPDATE, DELETE ON `test\_snippetbox\_%`.* TO 'test_web'@'localhost';
 
 ALTER USER 'test_web'@'localhost' IDENTIFIED BY 'pass';
\ No newline at end of file
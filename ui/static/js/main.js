@@ -13,4 +13,191 @@ for (let i = 0; i < navLinks.length; i++) {
         // Break the loop as we've found the active link
         break;
     }
-}
\ No newline at end of file
+}
+// Warn about taken or invalid values in inputs marked with a data-check attribute (on the signup
+// form) as soon as the user leaves the field, instead of after the form is submitted.
+const checkedInputs = document.querySelectorAll("input[data-check]");
+
+for (let i = 0; i < checkedInputs.length; i++) {
+    let input = checkedInputs[i];
+
+    input.addEventListener("change", function () {
+        let field = input.dataset.check;
+
+        // Remove the warning from any previous check
+        let previous = input.parentNode.querySelector(".check-warning");
+        if (previous) {
+            previous.remove();
+        }
+
+        if (input.value === "") {
+            return;
+        }
+
+        fetch("/user/check?" + new URLSearchParams({[field]: input.value}))
+            .then(function (response) {
+                return response.ok ? response.json() : null;
+            })
+            .then(function (result) {
+                if (!result || !result[field] || result[field].available) {
+                    return;
+                }
+
+                // Show the reason the value can't be used just above the input
+                let warning = document.createElement("label");
+                warning.className = "error check-warning";
+                warning.textContent = result[field].message;
+                input.parentNode.insertBefore(warning, input);
+            })
+            .catch(function () {});
+    });
+}
+// Save the snippet forms marked with a data-draft attribute as a draft while the user types, so
+// that their work survives a closed tab. The attribute holds the ID of the snippet being edited,
+// or is empty for a new snippet. Saves are spaced out, and submitting the form cancels a pending
+// one, since the server discards the draft once the snippet is saved.
+const draftForms = document.querySelectorAll("form[data-draft]");
+
+for (let i = 0; i < draftForms.length; i++) {
+    let form = draftForms[i];
+    let timer = null;
+
+    let saveDraft = function () {
+        timer = null;
+
+        let body = new URLSearchParams({
+            snippet: form.dataset.draft,
+            title: form.elements["title"].value,
+            content: form.elements["content"].value,
+        });
+
+        fetch("/snippet/draft", {method: "POST", body: body}).catch(function () {});
+    };
+
+    form.addEventListener("input", function () {
+        if (timer === null) {
+            timer = setTimeout(saveDraft, 2000);
+        }
+    });
+
+    form.addEventListener("submit", function () {
+        clearTimeout(timer);
+        timer = null;
+    });
+}
+
+// Hold the edit lock of the snippet forms marked with a data-lock attribute while they're open, so
+// that other editors are told someone else is editing. The attribute holds the ID of the snippet.
+// The lock is renewed well before it expires, and released when the page is left. If someone else
+// holds it, the notice above the form says who.
+const lockForms = document.querySelectorAll("form[data-lock]");
+
+for (let i = 0; i < lockForms.length; i++) {
+    let form = lockForms[i];
+    let notice = form.querySelector("div.lock");
+    let id = encodeURIComponent(form.dataset.lock);
+
+    let renewLock = function () {
+        fetch("/snippet/lock/" + id, {method: "POST"})
+            .then(function (response) {
+                if (response.status === 204) {
+                    notice.hidden = true;
+                } else if (response.status === 409) {
+                    return response.json().then(function (lock) {
+                        notice.textContent = lock.holder + " has been editing this snippet since " + lock.since + ". Saving may overwrite their changes.";
+                        notice.hidden = false;
+                    });
+                }
+            })
+            .catch(function () {});
+    };
+
+    setInterval(renewLock, 30000);
+
+    window.addEventListener("pagehide", function () {
+        navigator.sendBeacon("/snippet/unlock/" + id);
+    });
+}
+// Mark the lines of the snippet blocks marked with a data-permalink attribute that are selected in
+// the URL fragment, such as #L10 or #L10-L20. Clicking a line number selects its line, and
+// shift-clicking another extends the selection to it. The "copy link to selection" button copies a
+// link to the permalink in the attribute that also marks the lines on the server, with the
+// "lines" query parameter.
+const lineBlocks = document.querySelectorAll("div.lines[data-permalink]");
+
+for (let i = 0; i < lineBlocks.length; i++) {
+    let block = lineBlocks[i];
+    let lines = block.querySelectorAll("span.line");
+    let button = block.querySelector("button.copy-lines");
+    let first = null;
+
+    let parseRange = function (hash) {
+        let match = /^#L(\d+)(?:-L(\d+))?$/.exec(hash);
+        if (!match) {
+            return null;
+        }
+        let start = parseInt(match[1], 10);
+        let end = match[2] ? parseInt(match[2], 10) : start;
+        return start <= end ? [start, end] : null;
+    };
+
+    let select = function (range) {
+        for (let j = 0; j < lines.length; j++) {
+            lines[j].classList.toggle("hl", j + 1 >= range[0] && j + 1 <= range[1]);
+        }
+
+        let single = range[0] === range[1];
+        let query = single ? String(range[0]) : range[0] + "-" + range[1];
+        let fragment = single ? "L" + range[0] : "L" + range[0] + "-L" + range[1];
+        button.dataset.link = (block.dataset.permalink || location.pathname) + "?lines=" + query + "#" + fragment;
+        button.hidden = false;
+    };
+
+    let selectFragment = function () {
+        let range = parseRange(location.hash);
+        if (range) {
+            select(range);
+        }
+        return range;
+    };
+
+    block.addEventListener("click", function (event) {
+        let link = event.target.closest("a.lnlinks");
+        if (!link) {
+            return;
+        }
+
+        let line = parseInt(link.getAttribute("href").slice(2), 10);
+        if (event.shiftKey && first !== null) {
+            event.preventDefault();
+            let range = [Math.min(first, line), Math.max(first, line)];
+            history.replaceState(null, "", "#L" + range[0] + "-L" + range[1]);
+            select(range);
+            return;
+        }
+
+        first = line;
+    });
+
+    window.addEventListener("hashchange", selectFragment);
+
+    // Ranges aren't the ID of an element, so scroll to their first line.
+    let range = selectFragment();
+    if (range) {
+        let start = document.getElementById("L" + range[0]);
+        if (start) {
+            start.scrollIntoView();
+        }
+    }
+
+    button.addEventListener("click", function () {
+        navigator.clipboard.writeText(location.origin + button.dataset.link)
+            .then(function () {
+                button.textContent = "Link copied";
+                setTimeout(function () {
+                    button.textContent = "Copy link to selection";
+                }, 2000);
+            })
+            .catch(function () {});
+    });
+}